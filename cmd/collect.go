@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"text/tabwriter"
+
+	"filesystem-exporter/internal/audit"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/coordinator"
+	"filesystem-exporter/internal/limits"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/report"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+// runCollect implements `filesystem-exporter collect`: it runs the selected
+// filesystem and directory collections once, synchronously, and prints what
+// they measured instead of serving metrics. It returns the process exit code.
+func runCollect(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("collect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var configPath, format string
+	flags.StringVar(&configPath, "config", "", "Path to configuration file (default: $CONFIG_PATH or config.yaml)")
+	flags.StringVar(&format, "format", "table", "Output format: table or json")
+
+	var groups []string
+	flags.Func("group", "Only collect this filesystem or directory group (repeatable)", func(name string) error {
+		groups = append(groups, name)
+		return nil
+	})
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if format != "table" && format != "json" {
+		_, _ = fmt.Fprintf(stderr, "invalid format %q (must be table or json)\n", format)
+		return 2
+	}
+
+	// Keep stdout for the results
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	configPath = resolveConfigPath(configPath)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	if err := scopeCollection(cfg, groups); err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if err := cfg.CheckRoot(os.Geteuid()); err != nil {
+		_, _ = fmt.Fprintf(stderr, "refusing to collect: %v\n", err)
+		return 1
+	}
+
+	if cfg.CPU.GOMAXPROCS > 0 {
+		runtime.GOMAXPROCS(cfg.CPU.GOMAXPROCS)
+	}
+
+	auditLog, err := audit.Open(cfg.Security.AuditLog)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "failed to open the command audit log: %v\n", err)
+		return 1
+	}
+
+	audit.SetDefault(auditLog)
+
+	defer func() { _ = auditLog.Close() }()
+
+	var limiter *limits.Limiter
+	if cfg.CommandLimits.IsEnabled() {
+		limiter, err = limits.New(cfg.CommandLimits, cfg.DirectoryGroups())
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "failed to set up command limits: %v\n", err)
+			return 1
+		}
+	}
+
+	registry := promexporter_metrics.NewRegistry("filesystem_exporter_info")
+	coord := coordinator.NewCoordinator(cfg, metrics.NewFilesystemRegistry(registry, cfg.GetCustomLabelNames()...), limiter, nil)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failing := coord.RunOnce(ctx)
+
+	result, err := report.Build(registry.GetRegistry())
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(result); err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return 1
+		}
+	} else {
+		printCollection(stdout, result)
+	}
+
+	for _, item := range failing {
+		message := "collection failed"
+		if len(item.RecentErrors) > 0 {
+			message = item.RecentErrors[len(item.RecentErrors)-1].Message
+		}
+
+		_, _ = fmt.Fprintf(stderr, "%s %s: %s\n", item.Type, item.Name, message)
+	}
+
+	if len(failing) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// scopeCollection narrows cfg to the named filesystems and directory groups
+// (all of them when names is empty) and turns off everything an ad-hoc run
+// shouldn't do: the additional collectors, whose results aren't printed, and
+// webhook alerts. The mount probe stays so hung network mounts are skipped.
+func scopeCollection(cfg *config.Config, names []string) error {
+	cfg.ZFS.Enabled = false
+	cfg.Btrfs.Enabled = false
+	cfg.Quotas.Enabled = false
+	cfg.Kubernetes.Enabled = false
+	cfg.Docker.Enabled = false
+	cfg.Buckets.Enabled = false
+	cfg.Alerts.Enabled = false
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	var filesystems []config.FilesystemConfig
+
+	directories := make(map[string]config.DirectoryGroup)
+
+	for _, name := range names {
+		found := false
+
+		for _, fs := range cfg.Filesystems {
+			if fs.Name == name {
+				filesystems = append(filesystems, fs)
+				found = true
+			}
+		}
+
+		if group, exists := cfg.Directory(name); exists {
+			directories[name] = group
+			found = true
+		}
+
+		if !found {
+			return fmt.Errorf("no filesystem or directory group named %q", name)
+		}
+	}
+
+	cfg.Filesystems = filesystems
+	cfg.Directories = directories
+
+	return nil
+}
+
+// printCollection writes the measurements as aligned tables
+func printCollection(w io.Writer, result *report.Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if len(result.Volumes) > 0 {
+		_, _ = fmt.Fprintln(tw, "FILESYSTEM\tMOUNT POINT\tSIZE\tAVAILABLE\tUSED")
+
+		for _, v := range result.Volumes {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.1f%%\n",
+				v.Name, v.MountPoint, config.ByteSize(v.SizeBytes), config.ByteSize(v.AvailableBytes), v.UsedRatio*100)
+		}
+
+		if len(result.Directories) > 0 {
+			_, _ = fmt.Fprintln(tw)
+		}
+	}
+
+	if len(result.Directories) > 0 {
+		_, _ = fmt.Fprintln(tw, "GROUP\tDIRECTORY\tLEVEL\tSIZE")
+
+		for _, d := range result.Directories {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Group, d.Path, d.SubdirectoryLevel, config.ByteSize(d.SizeBytes))
+		}
+	}
+
+	_ = tw.Flush()
+}
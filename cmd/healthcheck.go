@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+// runHealthcheck implements `filesystem-exporter healthcheck`: it requests
+// the running exporter's /health, or /ready with --ready, at the address in
+// its config and exits 0 on a 200, so container HEALTHCHECKs don't need curl
+// or wget in the image. It returns the process exit code.
+func runHealthcheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var configPath string
+	flags.StringVar(&configPath, "config", "", "Path to configuration file (default: $CONFIG_PATH or config.yaml)")
+
+	var ready bool
+	flags.BoolVar(&ready, "ready", false, "Check /ready instead of /health")
+
+	var timeout time.Duration
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "How long to wait for the response")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	configPath = resolveConfigPath(configPath)
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	path := "/health"
+	if ready {
+		path = "/ready"
+	}
+
+	client, url := healthClient(cfg, path)
+	client.Timeout = timeout
+
+	resp, err := client.Get(url)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = fmt.Fprintf(stderr, "unhealthy: %s: %s\n", resp.Status, body)
+		return 1
+	}
+
+	_, _ = stdout.Write(body)
+
+	return 0
+}
+
+// healthClient returns a client for the exporter's own server and the URL of
+// path on it: the listen socket, or else server.host and server.port with
+// wildcard hosts reached over loopback. Its certificate is for the names
+// clients use rather than loopback, so it isn't verified.
+func healthClient(cfg *config.Config, path string) (*http.Client, string) {
+	transport := &http.Transport{}
+
+	scheme := "http"
+	if cfg.TLS.IsEnabled() {
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // Only ever the local exporter
+	}
+
+	host := cfg.Server.Host
+
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+
+	if socket, ok := cfg.GetListenSocket(); ok {
+		address = "exporter"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+	}
+
+	return &http.Client{Transport: transport}, scheme + "://" + address + path
+}
@@ -1,21 +1,63 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
-	"log"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
 
+	"filesystem-exporter/internal/api"
+	"filesystem-exporter/internal/audit"
+	"filesystem-exporter/internal/auth"
 	"filesystem-exporter/internal/config"
 	"filesystem-exporter/internal/coordinator"
+	"filesystem-exporter/internal/digest"
+	"filesystem-exporter/internal/frontend"
+	"filesystem-exporter/internal/groupmetrics"
+	"filesystem-exporter/internal/health"
+	"filesystem-exporter/internal/limits"
+	"filesystem-exporter/internal/logging"
 	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/probe"
+	"filesystem-exporter/internal/pushgateway"
+	"filesystem-exporter/internal/report"
+	"filesystem-exporter/internal/signals"
+	"filesystem-exporter/internal/systemd"
+	"filesystem-exporter/internal/tlsserver"
+	"filesystem-exporter/internal/tracing"
 	"filesystem-exporter/internal/version"
 	"github.com/d0ugal/promexporter/app"
-	"github.com/d0ugal/promexporter/logging"
 	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/d0ugal/promexporter/server"
+	promexporter_tracing "github.com/d0ugal/promexporter/tracing"
+	promexporter_version "github.com/d0ugal/promexporter/version"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// exporterName is shown on the dashboard and names the HTTP spans
+const exporterName = "Filesystem Exporter"
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:], os.Stdout, os.Stderr))
+		case "collect":
+			os.Exit(runCollect(os.Args[2:], os.Stdout, os.Stderr))
+		case "mounts":
+			os.Exit(runMounts(os.Args[2:], os.Stdout, os.Stderr))
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	// Parse command line flags
 	var showVersion bool
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
@@ -23,6 +65,9 @@ func main() {
 
 	var configPath string
 	flag.StringVar(&configPath, "config", "", "Path to configuration file")
+
+	var once bool
+	flag.BoolVar(&once, "once", false, "Collect every item once, push the results to pushgateway.url and exit")
 	flag.Parse()
 
 	// Show version if requested
@@ -31,14 +76,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Use environment variable if config flag is not provided
-	if configPath == "" {
-		if envConfig := os.Getenv("CONFIG_PATH"); envConfig != "" {
-			configPath = envConfig
-		} else {
-			configPath = "config.yaml"
-		}
-	}
+	configPath = resolveConfigPath(configPath)
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
@@ -47,42 +85,294 @@ func main() {
 	}
 
 	// Configure logging
-	logging.Configure(&logging.Config{
-		Level:  cfg.Logging.Level,
-		Format: cfg.Logging.Format,
-	})
+	logging.Configure(cfg)
+
+	if err := cfg.CheckRoot(os.Geteuid()); err != nil {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
+
+	if once && cfg.Pushgateway.URL == "" {
+		slog.Error("--once requires pushgateway.url to be configured")
+		os.Exit(1)
+	}
 
 	// Validation already checks that at least one filesystem or directory is configured
 	slog.Info("Initializing filesystem-exporter",
 		"pid", os.Getpid(),
-		"num_directories", len(cfg.Directories),
+		"num_directories", len(cfg.DirectoryGroups()),
 		"num_filesystems", len(cfg.Filesystems))
 
+	for path, groups := range cfg.DuplicateDirectoryGroups() {
+		slog.Warn("Directory groups resolve to the same path and will export duplicate series",
+			"canonical_path", path,
+			"groups", groups)
+	}
+
 	// Initialize metrics registry using promexporter
 	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_info")
 
 	// Add custom metrics to the registry
-	filesystemRegistry := metrics.NewFilesystemRegistry(metricsRegistry)
+	filesystemRegistry := metrics.NewFilesystemRegistry(metricsRegistry, cfg.GetCustomLabelNames()...)
 
-	// Build the app first (without the collector) so we can get its tracer
-	// to wire into the coordinator. Then re-build with the collector attached
-	// so app.Run() owns the coordinator's lifecycle and SIGTERM cancels its
-	// context cleanly instead of leaking goroutines under context.Background().
-	application := app.New("Filesystem Exporter").
+	// Build sets up promexporter's logging, tracing, profiling and version
+	// metric. Its server is never started; run takes its place.
+	application := app.New(exporterName).
 		WithConfig(&cfg.BaseConfig).
 		WithMetrics(metricsRegistry).
 		WithVersionInfo(version.Version, version.Commit, version.BuildDate).
 		Build()
 
-	tracer := application.GetTracer()
-	coord := coordinator.NewCoordinator(cfg, filesystemRegistry, tracer)
-	application.WithCollector(coord)
+	// Build configures logging too, without per-component levels or the
+	// rate limit
+	logging.Configure(cfg)
+
+	if cfg.CPU.GOMAXPROCS > 0 {
+		slog.Info("Capping GOMAXPROCS", "gomaxprocs", cfg.CPU.GOMAXPROCS, "previous", runtime.GOMAXPROCS(cfg.CPU.GOMAXPROCS))
+	}
+
+	// Records are written straight through, so exiting without closing it
+	// loses nothing
+	auditLog, err := audit.Open(cfg.Security.AuditLog)
+	if err != nil {
+		slog.Error("Failed to open the command audit log", "error", err)
+		os.Exit(1)
+	}
 
-	slog.Info("Initialization complete, starting application.Run()",
-		"pid", os.Getpid())
+	audit.SetDefault(auditLog)
+
+	var limiter *limits.Limiter
+	if cfg.CommandLimits.IsEnabled() {
+		limiter, err = limits.New(cfg.CommandLimits, cfg.DirectoryGroups())
+		if err != nil {
+			slog.Error("Failed to set up command limits", "error", err)
+			os.Exit(1)
+		}
+
+		slog.Info("Command resource limits enabled",
+			"cpu_seconds", cfg.CommandLimits.CPUSeconds,
+			"max_open_files", cfg.CommandLimits.MaxOpenFiles,
+			"memory_bytes", cfg.CommandLimits.Memory.Bytes())
+	}
+
+	// Collection spans go through a provider of our own, which samples and
+	// scrubs paths; promexporter's only traces its HTTP server
+	tracer, err := tracing.NewTracer(cfg)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	coord := coordinator.NewCoordinator(cfg, filesystemRegistry, limiter, tracer)
+
+	if once {
+		code := runOnce(cfg, coord, metricsRegistry.GetRegistry())
+		tracer.Stop()
+		os.Exit(code)
+	}
+
+	// Started in order and stopped in the same order on SIGINT or SIGTERM, so
+	// cancelling the context shuts the coordinator down cleanly instead of
+	// leaking goroutines under context.Background()
+	collectors := []app.Collector{coord}
+
+	// Started after the coordinator, so READY=1 follows the scheduler starting
+	collectors = append(collectors, systemd.NewNotifier(coord))
+
+	// SIGUSR1 dumps diagnostics and SIGUSR2 collects everything, for hosts
+	// where the API can't be reached
+	collectors = append(collectors, signals.NewHandler(cfg, coord))
+
+	var (
+		tlsConfig     *tls.Config
+		authenticator *auth.Authenticator
+	)
+
+	if cfg.TLS.IsEnabled() {
+		reloader, err := tlsserver.NewReloader(cfg.TLS, filesystemRegistry)
+		if err != nil {
+			slog.Error("Failed to load TLS certificate", "error", err)
+			os.Exit(1)
+		}
+
+		tlsConfig = reloader.TLSConfig()
+		collectors = append(collectors, reloader)
+	}
+
+	if cfg.Auth.IsEnabled() {
+		authenticator, err = auth.New(cfg.Auth, filesystemRegistry)
+		if err != nil {
+			slog.Error("Failed to load authentication credentials", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// The promexporter server supports neither TLS, authentication, Unix
+	// sockets, extra routes nor collecting on scrape. Its handler is served
+	// in-process behind the frontend on the configured address instead, and
+	// its own listener is never opened.
+	backend, err := frontend.PromexporterHandler(server.New(&cfg.BaseConfig, metricsRegistry, exporterName, &promexporter_version.Info{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+	}, application.GetTracer()))
+	if err != nil {
+		slog.Error("Failed to set up the HTTP server", "error", err)
+		os.Exit(1)
+	}
+
+	addr := cfg.Listen
+	if addr == "" {
+		addr = net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	}
+
+	socketMode, _ := cfg.GetSocketMode() // Validated at load time
+
+	httpServer := frontend.NewServer(addr, socketMode, backend, tlsConfig, authenticator, cfg.ServerTimeouts)
+
+	if cfg.Probe.Enabled {
+		httpServer.Handle("/probe", "probe", probe.NewHandler(cfg, filesystemRegistry, tracer))
+	}
 
-	// Run the application
-	if err := application.Run(); err != nil {
-		log.Fatalf("Failed to run application: %v", err)
+	if cfg.HasScrapeCollection() {
+		httpServer.HandleMetrics(coord.RefreshOnScrape)
 	}
+
+	if cfg.GroupMetrics.Enabled {
+		var refresh func(context.Context)
+		if cfg.HasScrapeCollection() {
+			refresh = coord.RefreshOnScrape
+		}
+
+		handler, err := groupmetrics.NewHandler(cfg, metricsRegistry.GetRegistry(), authenticator, filesystemRegistry, refresh)
+		if err != nil {
+			slog.Error("Failed to load group metrics credentials", "error", err)
+			os.Exit(1)
+		}
+
+		httpServer.HandleOwnAuth(groupmetrics.Pattern, handler)
+	}
+
+	// The dashboard's activity feed, which can't reach the API's own
+	// listener
+	if cfg.API.Enabled {
+		events := api.NewEventStream(coord)
+		httpServer.Handle("GET /api/v1/events", "events", events)
+		httpServer.RegisterOnShutdown(events.Close)
+	}
+
+	if cfg.Health.Enabled {
+		checker := health.NewChecker(cfg, coord)
+		httpServer.HandleHealth(checker.HealthHandler(), checker.ReadyHandler())
+	}
+
+	if err := httpServer.Listen(); err != nil {
+		slog.Error("Failed to listen", "error", err, "address", addr)
+		os.Exit(1)
+	}
+
+	collectors = append(collectors, httpServer)
+
+	slog.Info("Serving HTTP", "address", addr, "tls", tlsConfig != nil, "client_certificates", cfg.TLS.ClientCAFile != "", "auth", authenticator != nil)
+
+	// The JSON API runs on its own listener, so it can be reached from
+	// somewhere other than the metrics
+	if cfg.API.Enabled {
+		var signer *report.Signer
+		if cfg.Signing.IsEnabled() {
+			signer, err = report.LoadSigner(cfg.Signing.PrivateKeyFile, cfg.Signing.KeyID)
+			if err != nil {
+				slog.Error("Failed to load report signing key", "error", err)
+				os.Exit(1)
+			}
+
+			slog.Info("Report signing enabled", "key_id", signer.KeyID())
+		}
+
+		collectors = append(collectors, api.NewServer(cfg, coord, filesystemRegistry, signer, tlsConfig, authenticator))
+	}
+
+	if cfg.Digest.Enabled {
+		collectors = append(collectors, digest.NewSender(cfg, coord, filesystemRegistry))
+	}
+
+	// Last, so the spans of the other collectors stopping are exported
+	collectors = append(collectors, tracer)
+
+	slog.Info("Initialization complete, starting collectors", "pid", os.Getpid())
+
+	run(collectors, application.GetTracer())
+}
+
+// run starts the collectors in order and waits for SIGINT or SIGTERM, then
+// stops them in the same order and flushes promexporter's HTTP spans. It
+// stands in for promexporter's app.Run, which would also start its own server.
+// The profiler Build starts isn't reachable from here, so it stops with the
+// process.
+func run(collectors []app.Collector, httpTracer *promexporter_tracing.Tracer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, collector := range collectors {
+		collector.Start(ctx)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	slog.Info("Shutting down gracefully...")
+	cancel()
+
+	for _, collector := range collectors {
+		collector.Stop()
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	if err := httpTracer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down HTTP tracing", "error", err)
+	}
+}
+
+// resolveConfigPath falls back to $CONFIG_PATH, then config.yaml, when the
+// config flag is not provided
+func resolveConfigPath(configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+
+	if envConfig := os.Getenv("CONFIG_PATH"); envConfig != "" {
+		return envConfig
+	}
+
+	return "config.yaml"
+}
+
+// runOnce collects every item a single time and pushes the results, for
+// scans run from cron. It returns the exit code, which is non-zero if any
+// item failed or the push did.
+func runOnce(cfg *config.Config, coord *coordinator.Coordinator, gatherer prometheus.Gatherer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failing := coord.RunOnce(ctx)
+	for _, item := range failing {
+		slog.Error("Collection failed", "type", item.Type, "name", item.Name, "consecutive_failures", item.ConsecutiveFailures)
+	}
+
+	// Failed items are pushed too, so they show up in collection_failed_total
+	if err := pushgateway.Push(ctx, cfg.Pushgateway, gatherer); err != nil {
+		slog.Error("Failed to push metrics", "error", err)
+		return 1
+	}
+
+	slog.Info("Pushed metrics", "url", cfg.Pushgateway.URL, "job", cfg.Pushgateway.Job, "failed_items", len(failing))
+
+	if len(failing) > 0 {
+		return 1
+	}
+
+	return 0
 }
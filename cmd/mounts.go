@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/mounts"
+	"gopkg.in/yaml.v3"
+)
+
+// mountUsage is a listed mount with its usage, if statfs answered in time
+type mountUsage struct {
+	mounts.Mount
+
+	usage fsstat.Usage
+	err   error
+}
+
+// runMounts implements `filesystem-exporter mounts`: it lists the host's
+// mounted filesystems with their usage, or as filesystems entries to paste
+// into a config. It returns the process exit code.
+func runMounts(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("mounts", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var all, asYAML bool
+	flags.BoolVar(&all, "all", false, "Include virtual filesystems such as proc and tmpfs, and empty ones")
+	flags.BoolVar(&asYAML, "yaml", false, "Print a filesystems config section instead of a table")
+
+	var timeout time.Duration
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "How long to wait for each mount's usage, so hung network mounts don't block")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	list, err := mounts.List()
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var listed []mountUsage
+
+	for _, mount := range list {
+		if mount.IsPseudo() && !all {
+			continue
+		}
+
+		m := mountUsage{Mount: mount}
+		m.usage, m.err = statWithTimeout(mount.MountPoint, timeout)
+
+		if m.err == nil && m.usage.Size == 0 && !all {
+			continue
+		}
+
+		listed = append(listed, m)
+	}
+
+	if asYAML {
+		return printMountsYAML(stdout, stderr, listed)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DEVICE\tMOUNT POINT\tTYPE\tSIZE\tAVAILABLE\tUSED")
+
+	for _, m := range listed {
+		if m.err != nil {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t-\t-\t%v\n", m.Device, m.MountPoint, m.FSType, m.err)
+			continue
+		}
+
+		used := float64(m.usage.Size-m.usage.Available) / float64(max(m.usage.Size, 1))
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.1f%%\n",
+			m.Device, m.MountPoint, m.FSType, config.ByteSize(m.usage.Size), config.ByteSize(m.usage.Available), used*100)
+	}
+
+	_ = tw.Flush()
+
+	return 0
+}
+
+// statWithTimeout runs fsstat.Stat, giving up after timeout. A statfs call
+// against a hung server may never return, so it is left running.
+func statWithTimeout(path string, timeout time.Duration) (fsstat.Usage, error) {
+	type result struct {
+		usage fsstat.Usage
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		usage, err := fsstat.Stat(path)
+		done <- result{usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.usage, r.err
+	case <-time.After(timeout):
+		return fsstat.Usage{}, fmt.Errorf("no response after %s", timeout)
+	}
+}
+
+// printMountsYAML prints a filesystems section with one entry per mount
+func printMountsYAML(stdout, stderr io.Writer, listed []mountUsage) int {
+	type entry struct {
+		Name       string `yaml:"name"`
+		MountPoint string `yaml:"mount_point"`
+		Device     string `yaml:"device"`
+		Interval   string `yaml:"interval"`
+	}
+
+	var section struct {
+		Filesystems []entry `yaml:"filesystems"`
+	}
+
+	names := make(map[string]int)
+
+	for _, m := range listed {
+		name := mountName(m.MountPoint)
+
+		// Names must be unique, but /mnt/data and "/mnt data" both map to mnt-data
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, names[name])
+		}
+
+		section.Filesystems = append(section.Filesystems, entry{
+			Name:       name,
+			MountPoint: m.MountPoint,
+			Device:     m.Device,
+			Interval:   "5m",
+		})
+	}
+
+	// Two space indents, like config.example.yaml
+	encoder := yaml.NewEncoder(stdout)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(section); err != nil {
+		_, _ = fmt.Fprintf(stderr, "failed to render filesystems: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// mountName derives a filesystem name from a mount point: / is root, /mnt/data
+// is mnt-data and C:\ is c
+func mountName(mountPoint string) string {
+	name := strings.Trim(filepath.ToSlash(mountPoint), "/")
+	name = strings.ToLower(strings.TrimSuffix(name, ":"))
+
+	if name == "" {
+		return "root"
+	}
+
+	return strings.NewReplacer("/", "-", " ", "-", ":", "").Replace(name)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"filesystem-exporter/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// runValidate implements `filesystem-exporter validate`: it loads and
+// validates the configuration like a normal start, then prints the effective
+// settings with defaults and computed timeouts filled in instead of starting
+// collection. It returns the process exit code.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var configPath string
+	flags.StringVar(&configPath, "config", "", "Path to configuration file (default: $CONFIG_PATH or config.yaml)")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	configPath = resolveConfigPath(configPath)
+
+	// A normal start tolerates a missing file so the exporter can be
+	// configured from the environment alone, but a validation run is about
+	// the file
+	if _, err := os.Stat(configPath); err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	for path, groups := range cfg.DuplicateDirectoryGroups() {
+		_, _ = fmt.Fprintf(stderr, "warning: directory groups %v resolve to the same path %s and will export duplicate series\n", groups, path)
+	}
+
+	out, err := yaml.Marshal(cfg.GetDisplayConfig())
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "failed to render configuration: %v\n", err)
+		return 1
+	}
+
+	_, _ = fmt.Fprintf(stdout, "# %s is valid\n%s", configPath, out)
+
+	return 0
+}
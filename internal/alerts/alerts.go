@@ -0,0 +1,257 @@
+// Package alerts evaluates simple threshold rules after each collection and
+// posts notifications to webhooks, for deployments without Alertmanager
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+)
+
+// Notification statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// queueSize bounds the notifications waiting to be sent. Collection never
+// blocks on a slow webhook; notifications beyond this are dropped.
+const queueSize = 64
+
+// Notification is a change in, or reminder of, an alert's state
+type Notification struct {
+	Status    string    `json:"status"`
+	Rule      string    `json:"rule"`
+	ItemType  string    `json:"item_type"`
+	ItemName  string    `json:"item_name"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Summary   string    `json:"summary"`
+	Time      time.Time `json:"time"`
+
+	webhooks []string // Names of the webhooks to send to; empty for all
+}
+
+// ruleState tracks one rule for one item between collections
+type ruleState struct {
+	breaches int // Consecutive breaching collections
+	firing   bool
+	lastSent time.Time
+}
+
+// Manager evaluates alert rules and delivers notifications in the background
+type Manager struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+
+	mu     sync.Mutex
+	states map[string]*ruleState // Keyed by rule, item type and item name
+
+	notifications chan Notification
+	wg            sync.WaitGroup
+}
+
+// NewManager creates a new alert manager
+func NewManager(cfg *config.Config, m *metrics.FilesystemRegistry) *Manager {
+	return &Manager{
+		config:        cfg,
+		metrics:       m,
+		states:        make(map[string]*ruleState),
+		notifications: make(chan Notification, queueSize),
+	}
+}
+
+// Start starts delivering notifications until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx)
+	}()
+}
+
+// Wait blocks until the delivery loop has exited after ctx was cancelled
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// run posts queued notifications to their webhooks one at a time
+func (m *Manager) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-m.notifications:
+			for _, webhook := range m.config.Alerts.Webhooks {
+				if !targets(n.webhooks, webhook.Name) {
+					continue
+				}
+
+				if err := send(ctx, webhook, n); err != nil {
+					m.metrics.WebhookNotifications.WithLabelValues(webhook.Name, "failure").Inc()
+					slog.Error("Failed to send alert notification", "webhook", webhook.Name, "rule", n.Rule, "item", n.ItemName, "error", err)
+
+					continue
+				}
+
+				m.metrics.WebhookNotifications.WithLabelValues(webhook.Name, "success").Inc()
+			}
+		}
+	}
+}
+
+// ObserveUsedRatio evaluates used_ratio_above rules for a collected filesystem
+func (m *Manager) ObserveUsedRatio(name, mountPoint string, usedRatio float64) {
+	for _, rule := range m.config.Alerts.Rules {
+		if rule.UsedRatioAbove == 0 || (rule.Filesystem != "" && rule.Filesystem != name) {
+			continue
+		}
+
+		summary := fmt.Sprintf("Filesystem %s (%s) is %.1f%% full, above %.1f%%", name, mountPoint, usedRatio*100, rule.UsedRatioAbove*100)
+		if usedRatio <= rule.UsedRatioAbove {
+			summary = fmt.Sprintf("Filesystem %s (%s) is back to %.1f%% full", name, mountPoint, usedRatio*100)
+		}
+
+		m.evaluate(rule, "filesystem", name, usedRatio, rule.UsedRatioAbove, usedRatio > rule.UsedRatioAbove, summary)
+	}
+}
+
+// ObserveSize evaluates size_above rules for a directory group's total
+func (m *Manager) ObserveSize(groupName string, sizeBytes int64) {
+	for _, rule := range m.config.Alerts.Rules {
+		if rule.SizeAbove == 0 || (rule.Directory != "" && rule.Directory != groupName) {
+			continue
+		}
+
+		size := config.ByteSize(sizeBytes)
+
+		summary := fmt.Sprintf("Directory group %s is %s, above %s", groupName, size, rule.SizeAbove)
+		if size <= rule.SizeAbove {
+			summary = fmt.Sprintf("Directory group %s is back to %s", groupName, size)
+		}
+
+		m.evaluate(rule, "directory", groupName, float64(sizeBytes), float64(rule.SizeAbove), size > rule.SizeAbove, summary)
+	}
+}
+
+// ObserveFailures evaluates failures_at_least rules after a collection, with
+// the item's consecutive failures (0 after a success)
+func (m *Manager) ObserveFailures(itemType, name string, failures int) {
+	for _, rule := range m.config.Alerts.Rules {
+		if rule.FailuresAtLeast == 0 {
+			continue
+		}
+
+		if (rule.Filesystem != "" && (itemType != "filesystem" || rule.Filesystem != name)) ||
+			(rule.Directory != "" && (itemType != "directory" || rule.Directory != name)) {
+			continue
+		}
+
+		summary := fmt.Sprintf("Collection of %s %s has failed %d times in a row", itemType, name, failures)
+		if failures < rule.FailuresAtLeast {
+			summary = fmt.Sprintf("Collection of %s %s is succeeding again", itemType, name)
+		}
+
+		m.evaluate(rule, itemType, name, float64(failures), float64(rule.FailuresAtLeast), failures >= rule.FailuresAtLeast, summary)
+	}
+}
+
+// evaluate advances a rule's state for an item. An alert fires once the rule
+// has been breached for rule.For consecutive collections, is re-sent every
+// repeat_interval while it stays breached, and resolves on the first
+// collection that doesn't breach it.
+func (m *Manager) evaluate(rule config.AlertRule, itemType, itemName string, value, threshold float64, breached bool, summary string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := rule.Name + "\x00" + itemType + "\x00" + itemName
+
+	state, exists := m.states[key]
+	if !exists {
+		state = &ruleState{}
+		m.states[key] = state
+	}
+
+	now := time.Now()
+	status := ""
+
+	switch {
+	case !breached:
+		state.breaches = 0
+
+		if state.firing {
+			state.firing = false
+			status = StatusResolved
+		}
+	case !state.firing:
+		state.breaches++
+
+		if state.breaches >= rule.For {
+			state.firing = true
+			status = StatusFiring
+		}
+	case now.Sub(state.lastSent) >= m.config.Alerts.RepeatInterval.Duration:
+		status = StatusFiring
+	}
+
+	firing := 0.0
+	if state.firing {
+		firing = 1
+	}
+
+	m.metrics.AlertFiringGauge.WithLabelValues(rule.Name, itemType, itemName).Set(firing)
+
+	if status == "" {
+		return
+	}
+
+	state.lastSent = now
+
+	n := Notification{
+		Status:    status,
+		Rule:      rule.Name,
+		ItemType:  itemType,
+		ItemName:  itemName,
+		Value:     value,
+		Threshold: threshold,
+		Summary:   summary,
+		Time:      now,
+		webhooks:  rule.Webhooks,
+	}
+
+	slog.Info("Alert "+status, "rule", rule.Name, "item_type", itemType, "item_name", itemName, "summary", summary)
+
+	select {
+	case m.notifications <- n:
+	default:
+		for _, webhook := range m.config.Alerts.Webhooks {
+			if targets(n.webhooks, webhook.Name) {
+				m.metrics.WebhookNotifications.WithLabelValues(webhook.Name, "dropped").Inc()
+			}
+		}
+
+		slog.Warn("Alert notification queue full, dropping notification", "rule", rule.Name, "item_name", itemName)
+	}
+}
+
+// targets reports whether a notification for the given webhook names should
+// go to webhook. No names means every webhook.
+func targets(names []string, webhook string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		if name == webhook {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,170 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+func newTestManager(rules []config.AlertRule, webhooks []config.WebhookConfig) *Manager {
+	cfg := &config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled:        true,
+			RepeatInterval: config.Duration{Duration: time.Hour},
+			Rules:          rules,
+			Webhooks:       webhooks,
+		},
+	}
+
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	return NewManager(cfg, registry)
+}
+
+// drain returns the notifications queued so far
+func drain(m *Manager) []Notification {
+	var queued []Notification
+
+	for {
+		select {
+		case n := <-m.notifications:
+			queued = append(queued, n)
+		default:
+			return queued
+		}
+	}
+}
+
+func TestEvaluateDebounce(t *testing.T) {
+	m := newTestManager([]config.AlertRule{{Name: "full", UsedRatioAbove: 0.9, For: 2}}, nil)
+
+	m.ObserveUsedRatio("data", "/data", 0.95)
+
+	if queued := drain(m); len(queued) != 0 {
+		t.Fatalf("expected no notification after one breach, got %+v", queued)
+	}
+
+	m.ObserveUsedRatio("data", "/data", 0.95)
+
+	queued := drain(m)
+	if len(queued) != 1 || queued[0].Status != StatusFiring || queued[0].ItemName != "data" {
+		t.Fatalf("expected one firing notification, got %+v", queued)
+	}
+
+	// Still firing, but within the repeat interval
+	m.ObserveUsedRatio("data", "/data", 0.96)
+
+	if queued := drain(m); len(queued) != 0 {
+		t.Fatalf("expected no repeat within repeat_interval, got %+v", queued)
+	}
+
+	m.ObserveUsedRatio("data", "/data", 0.5)
+
+	queued = drain(m)
+	if len(queued) != 1 || queued[0].Status != StatusResolved {
+		t.Fatalf("expected one resolved notification, got %+v", queued)
+	}
+}
+
+func TestEvaluateScope(t *testing.T) {
+	m := newTestManager([]config.AlertRule{
+		{Name: "big", Directory: "media", SizeAbove: 1 << 30, For: 1},
+		{Name: "failing", Filesystem: "data", FailuresAtLeast: 3, For: 1},
+	}, nil)
+
+	m.ObserveSize("backups", 2<<30)
+	m.ObserveFailures("directory", "data", 5)
+	m.ObserveFailures("filesystem", "data", 2)
+
+	if queued := drain(m); len(queued) != 0 {
+		t.Fatalf("expected rules to ignore other items, got %+v", queued)
+	}
+
+	m.ObserveSize("media", 3<<29)
+	m.ObserveFailures("filesystem", "data", 3)
+
+	queued := drain(m)
+	if len(queued) != 2 {
+		t.Fatalf("expected two firing notifications, got %+v", queued)
+	}
+
+	if queued[0].Summary != "Directory group media is 1.5GiB, above 1.0GiB" {
+		t.Errorf("unexpected summary %q", queued[0].Summary)
+	}
+}
+
+func TestSend(t *testing.T) {
+	type request struct {
+		contentType string
+		title       string
+		auth        string
+		body        string
+	}
+
+	received := make(chan request, 3)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{r.Header.Get("Content-Type"), r.Header.Get("Title"), r.Header.Get("Authorization"), string(body)}
+	}))
+	defer server.Close()
+
+	n := Notification{Status: StatusFiring, Rule: "full", ItemType: "filesystem", ItemName: "data", Value: 0.95, Threshold: 0.9, Summary: "Filesystem data is full"}
+
+	webhooks := []config.WebhookConfig{
+		{Name: "generic", Type: config.WebhookTypeGeneric, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "slack", Type: config.WebhookTypeSlack, URL: server.URL},
+		{Name: "ntfy", Type: config.WebhookTypeNtfy, URL: server.URL},
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Timeout = config.Duration{Duration: 5 * time.Second}
+
+		if err := send(context.Background(), webhook, n); err != nil {
+			t.Fatalf("%s: send failed: %v", webhook.Name, err)
+		}
+	}
+
+	generic := <-received
+
+	var decoded Notification
+	if err := json.Unmarshal([]byte(generic.body), &decoded); err != nil || decoded.Rule != "full" || decoded.Value != 0.95 {
+		t.Errorf("unexpected generic payload %s (%v)", generic.body, err)
+	}
+
+	if generic.auth != "Bearer secret" {
+		t.Errorf("expected configured headers to be sent, got %q", generic.auth)
+	}
+
+	if slack := <-received; !strings.Contains(slack.body, `"text":":rotating_light: *[FIRING] full*\nFilesystem data is full"`) {
+		t.Errorf("unexpected slack payload %s", slack.body)
+	}
+
+	ntfy := <-received
+	if ntfy.body != "Filesystem data is full" || ntfy.title != "[FIRING] full" || !strings.HasPrefix(ntfy.contentType, "text/plain") {
+		t.Errorf("unexpected ntfy request %+v", ntfy)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	webhook := config.WebhookConfig{Name: "generic", URL: server.URL, Timeout: config.Duration{Duration: 5 * time.Second}}
+
+	err := send(context.Background(), webhook, Notification{Status: StatusFiring})
+	if err == nil || !strings.Contains(err.Error(), "bad token") {
+		t.Fatalf("expected the response body in the error, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"filesystem-exporter/internal/config"
+)
+
+// send posts a notification to a webhook in the webhook's format
+func send(ctx context.Context, webhook config.WebhookConfig, n Notification) error {
+	body, contentType, err := payload(webhook.Type, n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhook.Timeout.Duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	if webhook.Type == config.WebhookTypeNtfy {
+		req.Header.Set("Title", fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status), n.Rule))
+		req.Header.Set("Tags", ntfyTag(n.Status))
+
+		if n.Status == StatusFiring {
+			req.Header.Set("Priority", "high")
+		}
+	}
+
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("POST returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// payload encodes a notification for a webhook type, returning the body and
+// its content type
+func payload(webhookType string, n Notification) ([]byte, string, error) {
+	switch webhookType {
+	case config.WebhookTypeSlack:
+		icon := ":rotating_light:"
+		if n.Status == StatusResolved {
+			icon = ":white_check_mark:"
+		}
+
+		body, err := json.Marshal(map[string]string{
+			"text": fmt.Sprintf("%s *[%s] %s*\n%s", icon, strings.ToUpper(n.Status), n.Rule, n.Summary),
+		})
+
+		return body, "application/json", err
+	case config.WebhookTypeNtfy:
+		return []byte(n.Summary), "text/plain; charset=utf-8", nil
+	default:
+		body, err := json.Marshal(n)
+
+		return body, "application/json", err
+	}
+}
+
+// ntfyTag picks the emoji tag ntfy shows next to the title
+func ntfyTag(status string) string {
+	if status == StatusResolved {
+		return "white_check_mark"
+	}
+
+	return "rotating_light"
+}
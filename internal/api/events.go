@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/state"
+)
+
+// eventKeepAlive is how often an idle stream gets a comment, so proxies
+// between it and the client don't close it
+const eventKeepAlive = 30 * time.Second
+
+// EventSource publishes job lifecycle events, as the coordinator does
+type EventSource interface {
+	SubscribeEvents() (<-chan state.Event, func())
+}
+
+// EventStream serves job lifecycle events as server-sent events, one per
+// queued, started, finished or failed job. The JSON API serves one, and the
+// main listener another for the dashboard's activity feed.
+type EventStream struct {
+	source EventSource
+
+	done      chan struct{} // Closed by Close to end every stream
+	closeOnce sync.Once
+}
+
+// NewEventStream creates a stream of the events source publishes
+func NewEventStream(source EventSource) *EventStream {
+	return &EventStream{source: source, done: make(chan struct{})}
+}
+
+// Close ends every stream. Servers call it as they shut down, since they
+// otherwise wait for streams that never end.
+func (e *EventStream) Close() {
+	e.closeOnce.Do(func() { close(e.done) })
+}
+
+// ServeHTTP streams events until the client goes away or Close is called
+func (e *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := e.source.SubscribeEvents()
+	defer unsubscribe()
+
+	controller := http.NewResponseController(w)
+
+	// The stream outlasts the server's write timeout
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// A comment, so the client sees the stream open straight away
+	if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil {
+		return
+	}
+
+	if err := controller.Flush(); err != nil {
+		slog.Error("Failed to stream events", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-e.done:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("Failed to encode event", "error", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
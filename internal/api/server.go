@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"filesystem-exporter/internal/auth"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/coordinator"
+	"filesystem-exporter/internal/drain"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/report"
+	"filesystem-exporter/internal/state"
+)
+
+// maxConfigBodyBytes bounds the settings of a directory group in a request
+const maxConfigBodyBytes = 1 << 20
+
+// Server serves the exporter's JSON API on its own listener, separate from
+// the metrics so it can be firewalled or authenticated differently. Once
+// shutdown starts it answers new requests with a 503 while those already in
+// flight finish.
+type Server struct {
+	config      *config.Config
+	coordinator *coordinator.Coordinator
+	metrics     *metrics.FilesystemRegistry
+	signer      *report.Signer // nil when signing is disabled
+	events      *EventStream
+	server      *http.Server
+	drainer     *drain.Drainer
+}
+
+// NewServer creates a new API server. signer may be nil to serve unsigned
+// reports, tlsConfig nil to serve plain HTTP and authenticator nil to accept
+// anonymous requests.
+func NewServer(cfg *config.Config, coord *coordinator.Coordinator, m *metrics.FilesystemRegistry, signer *report.Signer, tlsConfig *tls.Config, authenticator *auth.Authenticator) *Server {
+	s := &Server{
+		config:      cfg,
+		coordinator: coord,
+		metrics:     m,
+		signer:      signer,
+		events:      NewEventStream(coord),
+	}
+
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+
+	var handler http.Handler = mux
+	if authenticator != nil {
+		handler = authenticator.Wrap("api", mux)
+	}
+
+	s.server = &http.Server{
+		Addr:      net.JoinHostPort(cfg.API.Host, strconv.Itoa(cfg.API.Port)),
+		TLSConfig: tlsConfig,
+	}
+	s.drainer = drain.New("api", s.server, cfg.API.HTTPTimeoutsConfig)
+	s.server.Handler = s.drainer.Handler(handler, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+	}))
+	s.server.RegisterOnShutdown(s.events.Close)
+
+	return s
+}
+
+// registerRoutes registers all API routes on the mux
+func (s *Server) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/state", s.handleState)
+	mux.HandleFunc("GET /api/v1/items/{name}/errors", s.handleItemErrors)
+	mux.HandleFunc("GET /api/v1/report", s.handleReport)
+	mux.Handle("GET /api/v1/events", s.events)
+
+	if s.config.API.ConfigUpdates.Enabled {
+		mux.HandleFunc("PUT /api/v1/config/directories/{name}", s.handlePutDirectory)
+		mux.HandleFunc("DELETE /api/v1/config/directories/{name}", s.handleDeleteDirectory)
+	}
+}
+
+// Start starts serving in the background. Listener errors are logged since
+// the collector interface has no way to report them. Draining starts as soon
+// as ctx is cancelled rather than when Stop is called, since the run loop
+// stops collectors one at a time and the coordinator can take a while.
+func (s *Server) Start(ctx context.Context) {
+	slog.Info("Starting API server", "address", s.server.Addr)
+
+	go func() {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			err = s.server.ListenAndServe()
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("API server failed", "error", err, "address", s.server.Addr)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		s.drainer.Shutdown()
+	}()
+}
+
+// Stop blocks until in-flight requests have drained or the shutdown grace
+// period has passed
+func (s *Server) Stop() {
+	s.drainer.Shutdown()
+}
+
+// handleState returns the full scheduler/worker state
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.coordinator.GetState(r.Context()))
+}
+
+// itemErrorsResponse is the JSON body returned by the item errors endpoint
+type itemErrorsResponse struct {
+	Name                string              `json:"name"`
+	Type                string              `json:"type"`
+	ConsecutiveFailures int                 `json:"consecutive_failures"`
+	Errors              []state.ErrorRecord `json:"errors"`
+}
+
+// handleItemErrors returns the recent failures of an item. An optional
+// ?type=filesystem|directory disambiguates items sharing a name.
+func (s *Server) handleItemErrors(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	itemType := r.URL.Query().Get("type")
+
+	if itemType != "" && itemType != "filesystem" && itemType != "directory" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid item type: %s", itemType))
+		return
+	}
+
+	items := s.coordinator.GetItems(r.Context(), itemType, name)
+	if len(items) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("item not found: %s", name))
+		return
+	}
+
+	response := make([]itemErrorsResponse, 0, len(items))
+	for _, item := range items {
+		recentErrors := item.RecentErrors
+		if recentErrors == nil {
+			recentErrors = []state.ErrorRecord{}
+		}
+
+		response = append(response, itemErrorsResponse{
+			Name:                item.Name,
+			Type:                item.Type,
+			ConsecutiveFailures: item.ConsecutiveFailures,
+			Errors:              recentErrors,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleReport returns the latest scan results, wrapped in a signed envelope
+// when signing is configured
+func (s *Server) handleReport(w http.ResponseWriter, _ *http.Request) {
+	scanReport, err := report.Build(s.metrics.GetRegistry())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if s.signer == nil {
+		writeJSON(w, http.StatusOK, scanReport)
+		return
+	}
+
+	envelope, err := s.signer.Seal(scanReport)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope)
+}
+
+// directoryResponse is the JSON body returned when a directory group is set
+type directoryResponse struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Interval string `json:"interval"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// handlePutDirectory adds a directory group, or replaces one added before,
+// from the YAML or JSON settings in the body
+func (s *Server) handlePutDirectory(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigBodyBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		writeError(w, status, err.Error())
+
+		return
+	}
+
+	created, err := s.coordinator.SetDirectory(r.Context(), name, body)
+	if err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
+
+	group, _ := s.config.Directory(name)
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	writeJSON(w, status, directoryResponse{
+		Name:     name,
+		Path:     group.Path,
+		Interval: group.Interval.String(),
+		Enabled:  group.IsEnabled(),
+	})
+}
+
+// handleDeleteDirectory removes a directory group added through the API
+func (s *Server) handleDeleteDirectory(w http.ResponseWriter, r *http.Request) {
+	if err := s.coordinator.DeleteDirectory(r.Context(), r.PathValue("name")); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDirectoryError answers a failed change to a directory group
+func writeDirectoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, config.ErrInvalidDirectory):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, config.ErrDirectoryNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, config.ErrDirectoryFromFiles), errors.Is(err, coordinator.ErrDirectoryBusy):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Failed to encode API response", "error", err)
+	}
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
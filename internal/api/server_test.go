@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"filesystem-exporter/internal/state"
+)
+
+type fakeEventSource struct {
+	events chan state.Event
+}
+
+func (f *fakeEventSource) SubscribeEvents() (<-chan state.Event, func()) {
+	return f.events, func() {}
+}
+
+func TestEventStream(t *testing.T) {
+	source := &fakeEventSource{events: make(chan state.Event, 1)}
+	stream := NewEventStream(source)
+
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("expected an event stream, got %s", contentType)
+	}
+
+	source.events <- state.Event{Type: state.EventStarted, ItemType: "directory", Name: "home", JobID: "job-1"}
+
+	reader := bufio.NewReader(resp.Body)
+
+	var lines []string
+
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream: %v", err)
+		}
+
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+
+	if lines[0] != "event: started" || !strings.Contains(lines[1], `"job_id":"job-1"`) {
+		t.Errorf("expected the started event, got %q", lines)
+	}
+
+	// Close ends the stream, as the server shutting down does
+	stream.Close()
+
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("expected the stream to end cleanly, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package audit keeps an append-only log of the external commands the
+// exporter runs, one JSON object per line, as evidence of exactly what it
+// executes with its privileges.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Record is one line of the log
+type Record struct {
+	Time            time.Time `json:"time"`
+	Binary          string    `json:"binary"` // Resolved path of the executable
+	Args            []string  `json:"args"`
+	PID             int       `json:"pid,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	ExitCode        int       `json:"exit_code"` // -1 when killed by a signal or never started
+	OutputBytes     int       `json:"output_bytes"`
+	JobID           string    `json:"job_id,omitempty"`
+	TraceID         string    `json:"trace_id,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Logger writes records to a file, syslog or both
+type Logger struct {
+	mutex   sync.Mutex
+	writers []io.WriteCloser
+}
+
+var (
+	defaultMutex  sync.RWMutex
+	defaultLogger *Logger
+)
+
+// Open opens the destinations of security.audit_log. It returns nil when
+// none is configured, and a nil Logger discards records.
+func Open(cfg config.AuditLogConfig) (*Logger, error) {
+	if !cfg.IsEnabled() {
+		return nil, nil
+	}
+
+	l := &Logger{}
+
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+
+		l.writers = append(l.writers, file)
+	}
+
+	if cfg.Syslog {
+		writer, err := openSyslog()
+		if err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+
+		l.writers = append(l.writers, writer)
+	}
+
+	return l, nil
+}
+
+// Close closes the log's destinations
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var errs []error
+
+	for _, writer := range l.writers {
+		errs = append(errs, writer.Close())
+	}
+
+	l.writers = nil
+
+	return errors.Join(errs...)
+}
+
+// Write appends a record. A record that can't be written is logged, but
+// doesn't fail the command it describes.
+func (l *Logger) Write(record Record) {
+	if l == nil {
+		return
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		slog.Warn("Failed to encode audit record", "binary", record.Binary, "error", err)
+		return
+	}
+
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, writer := range l.writers {
+		if _, err := writer.Write(line); err != nil {
+			slog.Warn("Failed to write audit record", "binary", record.Binary, "error", err)
+		}
+	}
+}
+
+// SetDefault makes l the log Command writes to
+func SetDefault(l *Logger) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	defaultLogger = l
+}
+
+// Command records a finished command in the default log: cmd as it was run,
+// when it started, the size of its output and the error it returned. jobID
+// is the collection job it ran for, if any; the trace ID comes from ctx.
+func Command(ctx context.Context, cmd *exec.Cmd, jobID string, start time.Time, outputBytes int, err error) {
+	defaultMutex.RLock()
+	l := defaultLogger
+	defaultMutex.RUnlock()
+
+	if l == nil {
+		return
+	}
+
+	record := Record{
+		Time:            start.UTC(),
+		Binary:          cmd.Path,
+		Args:            cmd.Args[1:],
+		DurationSeconds: time.Since(start).Seconds(),
+		ExitCode:        -1,
+		OutputBytes:     outputBytes,
+		JobID:           jobID,
+	}
+
+	if cmd.Process != nil {
+		record.PID = cmd.Process.Pid
+	}
+
+	if cmd.ProcessState != nil {
+		record.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.TraceID = spanContext.TraceID().String()
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	l.Write(record)
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+func TestCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	l, err := Open(config.AuditLogConfig{File: path})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	SetDefault(l)
+	defer SetDefault(nil)
+
+	ok := exec.Command("go", "version")
+	start := time.Now()
+
+	output, err := ok.Output()
+	if err != nil {
+		t.Skipf("go not runnable: %v", err)
+	}
+
+	Command(context.Background(), ok, "job-1", start, len(output), nil)
+
+	missing := exec.Command("/nonexistent/du", "-s", "/")
+	err = missing.Run()
+	Command(context.Background(), missing, "", time.Now(), 0, err)
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer file.Close()
+
+	var records []Record
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	first := records[0]
+	if first.ExitCode != 0 || first.JobID != "job-1" || first.OutputBytes != len(output) || first.PID == 0 ||
+		len(first.Args) != 1 || first.Args[0] != "version" || !filepath.IsAbs(first.Binary) {
+		t.Errorf("unexpected record %+v", first)
+	}
+
+	second := records[1]
+	if second.ExitCode != -1 || second.Error == "" || second.Binary != "/nonexistent/du" {
+		t.Errorf("unexpected record for a command that didn't start %+v", second)
+	}
+
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the log to be created with mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestOpen_Disabled(t *testing.T) {
+	l, err := Open(config.AuditLogConfig{})
+	if err != nil || l != nil {
+		t.Fatalf("expected no logger, got %v, %v", l, err)
+	}
+
+	// A nil logger discards records
+	l.Write(Record{Binary: "du"})
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// openSyslog connects to the local syslog daemon, logging as the daemon
+// facility at info level
+func openSyslog() (io.WriteCloser, error) {
+	return syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "filesystem-exporter")
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package audit
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog is not available on Windows, where validation rejects it
+func openSyslog() (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not available on windows")
+}
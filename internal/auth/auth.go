@@ -0,0 +1,200 @@
+// Package auth checks basic auth and bearer token credentials on requests to
+// the HTTP server and API
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator accepts requests carrying any of the configured credentials
+type Authenticator struct {
+	users   map[string][]byte // Username to bcrypt hash
+	tokens  [][]byte
+	metrics *metrics.FilesystemRegistry
+
+	// Credentials that already passed a bcrypt comparison, keyed by a hash of
+	// username and password, so scrapes don't each pay for bcrypt
+	verifiedMutex sync.Mutex
+	verified      map[[sha256.Size]byte]bool
+}
+
+// New loads the configured credentials, reading any files once
+func New(cfg config.AuthConfig, m *metrics.FilesystemRegistry) (*Authenticator, error) {
+	a := &Authenticator{
+		users:    make(map[string][]byte),
+		metrics:  m,
+		verified: make(map[[sha256.Size]byte]bool),
+	}
+
+	if err := a.load(cfg); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// With returns an authenticator accepting cfg's credentials as well as a's.
+// a may be nil, for only cfg's.
+func (a *Authenticator) With(cfg config.AuthConfig, m *metrics.FilesystemRegistry) (*Authenticator, error) {
+	combined, err := New(cfg, m)
+	if err != nil || a == nil {
+		return combined, err
+	}
+
+	for username, hash := range a.users {
+		if _, exists := combined.users[username]; !exists {
+			combined.users[username] = hash
+		}
+	}
+
+	combined.tokens = append(combined.tokens, a.tokens...)
+
+	return combined, nil
+}
+
+// load adds the credentials in cfg
+func (a *Authenticator) load(cfg config.AuthConfig) error {
+	if cfg.BasicAuthUsersFile != "" {
+		users, err := readUsersFile(cfg.BasicAuthUsersFile)
+		if err != nil {
+			return err
+		}
+
+		for username, hash := range users {
+			a.users[username] = []byte(hash)
+		}
+	}
+
+	for username, hash := range cfg.BasicAuthUsers {
+		a.users[username] = []byte(hash)
+	}
+
+	for username, hash := range a.users {
+		if _, err := bcrypt.Cost(hash); err != nil {
+			return fmt.Errorf("password of basic auth user '%s' is not a bcrypt hash: %w", username, err)
+		}
+	}
+
+	token := cfg.BearerToken
+
+	if cfg.BearerTokenFile != "" {
+		data, err := os.ReadFile(cfg.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read bearer token: %w", err)
+		}
+
+		token = strings.TrimSpace(string(data))
+		if token == "" {
+			return fmt.Errorf("bearer token file %s is empty", cfg.BearerTokenFile)
+		}
+	}
+
+	if token != "" {
+		a.tokens = append(a.tokens, []byte(token))
+	}
+
+	return nil
+}
+
+// Wrap rejects requests to next without valid credentials with a 401. server
+// names the listener in the failure metric.
+func (a *Authenticator) Wrap(server string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		a.metrics.AuthFailuresCounter.WithLabelValues(server).Inc()
+
+		if len(a.users) > 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="filesystem-exporter", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authenticated reports whether a request carries valid credentials
+func (a *Authenticator) authenticated(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && len(a.tokens) > 0 {
+		matched := 0
+		for _, expected := range a.tokens {
+			matched |= subtle.ConstantTimeCompare([]byte(token), expected)
+		}
+
+		return matched == 1
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	hash, exists := a.users[username]
+	if !exists {
+		return false
+	}
+
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+
+	a.verifiedMutex.Lock()
+	verified := a.verified[key]
+	a.verifiedMutex.Unlock()
+
+	if verified {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+
+	a.verifiedMutex.Lock()
+	a.verified[key] = true
+	a.verifiedMutex.Unlock()
+
+	return true
+}
+
+// readUsersFile parses username:bcrypt-hash lines, as written by
+// htpasswd -B. Blank lines and lines starting with # are skipped.
+func readUsersFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read basic auth users: %w", err)
+	}
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("%s line %d: expected username:bcrypt-hash", path, line)
+		}
+
+		users[username] = hash
+	}
+
+	return users, scanner.Err()
+}
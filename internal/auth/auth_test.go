@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAuthenticator(t *testing.T, cfg config.AuthConfig) *Authenticator {
+	t.Helper()
+
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	a, err := New(cfg, registry)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	return a
+}
+
+func hash(t *testing.T, password string) string {
+	t.Helper()
+
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(h)
+}
+
+func TestWrap(t *testing.T) {
+	usersFile := filepath.Join(t.TempDir(), "users")
+	if err := os.WriteFile(usersFile, []byte("# scrapers\nprometheus:"+hash(t, "scrape")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAuthenticator(t, config.AuthConfig{
+		BasicAuthUsers:     map[string]string{"admin": hash(t, "secret")},
+		BasicAuthUsersFile: usersFile,
+		BearerToken:        "token",
+	})
+
+	handler := a.Wrap("metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		setup    func(r *http.Request)
+		expected int
+	}{
+		{"anonymous", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"basic auth again from cache", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"basic auth from file", func(r *http.Request) { r.SetBasicAuth("prometheus", "scrape") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+		{"unknown user", func(r *http.Request) { r.SetBasicAuth("nobody", "secret") }, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"wrong bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		tt.setup(req)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.expected, rec.Code)
+		}
+
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	emptyToken := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(emptyToken, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cfg := range map[string]config.AuthConfig{
+		"plain text password": {BasicAuthUsers: map[string]string{"admin": "secret"}},
+		"missing users file":  {BasicAuthUsersFile: filepath.Join(t.TempDir(), "missing")},
+		"empty token file":    {BearerTokenFile: emptyToken},
+	} {
+		if _, err := New(cfg, registry); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	server := newTestAuthenticator(t, config.AuthConfig{BearerToken: "server"})
+
+	combined, err := server.With(config.AuthConfig{BearerToken: "team"}, registry)
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+
+	alone, err := (*Authenticator)(nil).With(config.AuthConfig{BearerToken: "team"}, registry)
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		a        *Authenticator
+		token    string
+		expected bool
+	}{
+		{"server token on server", server, "server", true},
+		{"team token on server", server, "team", false},
+		{"server token on combined", combined, "server", true},
+		{"team token on combined", combined, "team", true},
+		{"team token alone", alone, "team", true},
+		{"server token alone", alone, "server", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics/group/uploads", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		if got := tt.a.authenticated(req); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package btrfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filesystem-exporter/internal/audit"
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Qgroup is a single row of `btrfs qgroup show --raw` output
+type Qgroup struct {
+	ID              string
+	Path            string // Only printed by newer btrfs-progs
+	ReferencedBytes int64
+	ExclusiveBytes  int64
+}
+
+// Allocation is the block group usage of one type/profile pair from
+// `btrfs filesystem usage -b`, e.g. Data,single or Metadata,DUP
+type Allocation struct {
+	Type      string
+	Profile   string
+	SizeBytes int64
+	UsedBytes int64
+}
+
+var (
+	qgroupIDPattern   = regexp.MustCompile(`^\d+/\d+$`)
+	allocationPattern = regexp.MustCompile(`^([A-Za-z]+),([A-Za-z0-9]+):\s+Size:([\d,.']+),\s+Used:([\d,.']+)`)
+)
+
+// Collector periodically reports qgroup and allocation usage for btrfs mounts
+type Collector struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new btrfs collector
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	return &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+	}
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "Btrfs collector", c.config.GetBtrfsInterval(), c.Collect, "mount_points", c.config.Btrfs.MountPoints)
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// Collect collects every configured mount point in turn
+func (c *Collector) Collect(ctx context.Context) {
+	for _, mountPoint := range c.config.Btrfs.MountPoints {
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.collect(ctx, mountPoint)
+	}
+}
+
+// collect runs a single collection for one mount point and records its outcome.
+// Qgroups and allocation are exported independently, so a filesystem without
+// quotas enabled still reports its allocation.
+func (c *Collector) collect(ctx context.Context, mountPoint string) {
+	ctx, span := c.startSpan(ctx, "btrfs.collect", trace.WithAttributes(
+		attribute.String("btrfs.mount_point", mountPoint),
+	))
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "btrfs", nil)
+
+	startTime := time.Now()
+	labels := []string{mountPoint, strconv.Itoa(int(c.config.GetBtrfsInterval().Seconds())), "btrfs"}
+
+	var errs []error
+
+	if output, err := c.runBtrfs(ctx, mountPoint, "filesystem", "usage", "-b"); err != nil {
+		errs = append(errs, err)
+	} else if allocations, err := ParseUsage(output); err != nil {
+		errs = append(errs, err)
+	} else {
+		c.exportAllocations(mountPoint, allocations)
+		span.SetAttributes(attribute.Int("btrfs.allocations", len(allocations)))
+	}
+
+	if output, err := c.runBtrfs(ctx, mountPoint, "qgroup", "show", "--raw"); err != nil {
+		errs = append(errs, err)
+	} else if qgroups, err := ParseQgroups(output); err != nil {
+		errs = append(errs, err)
+	} else {
+		c.exportQgroups(mountPoint, qgroups)
+		span.SetAttributes(attribute.Int("btrfs.qgroups", len(qgroups)))
+	}
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("Btrfs collection failed", "mount_point", mountPoint, "error", err, "error_class", reason)
+
+		return
+	}
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetStatus(codes.Ok, "btrfs collection completed")
+	slog.Debug("Btrfs collection completed", "mount_point", mountPoint, "duration", duration)
+}
+
+// exportAllocations replaces the allocation series for a mount point
+func (c *Collector) exportAllocations(mountPoint string, allocations []Allocation) {
+	c.metrics.BtrfsAllocationSizeGauge.DeletePartialMatch(prometheus.Labels{"mount_point": mountPoint})
+	c.metrics.BtrfsAllocationUsedGauge.DeletePartialMatch(prometheus.Labels{"mount_point": mountPoint})
+
+	for _, allocation := range allocations {
+		c.metrics.BtrfsAllocationSizeGauge.WithLabelValues(mountPoint, allocation.Type, allocation.Profile).Set(float64(allocation.SizeBytes))
+		c.metrics.BtrfsAllocationUsedGauge.WithLabelValues(mountPoint, allocation.Type, allocation.Profile).Set(float64(allocation.UsedBytes))
+	}
+}
+
+// exportQgroups replaces the qgroup series for a mount point
+func (c *Collector) exportQgroups(mountPoint string, qgroups []Qgroup) {
+	c.metrics.BtrfsQgroupReferencedGauge.DeletePartialMatch(prometheus.Labels{"mount_point": mountPoint})
+	c.metrics.BtrfsQgroupExclusiveGauge.DeletePartialMatch(prometheus.Labels{"mount_point": mountPoint})
+
+	for _, qgroup := range qgroups {
+		c.metrics.BtrfsQgroupReferencedGauge.WithLabelValues(mountPoint, qgroup.ID, qgroup.Path).Set(float64(qgroup.ReferencedBytes))
+		c.metrics.BtrfsQgroupExclusiveGauge.WithLabelValues(mountPoint, qgroup.ID, qgroup.Path).Set(float64(qgroup.ExclusiveBytes))
+	}
+}
+
+// runBtrfs executes a btrfs subcommand on a mount point with the configured timeout
+func (c *Collector) runBtrfs(ctx context.Context, mountPoint string, args ...string) ([]byte, error) {
+	timeout := c.config.GetBtrfsTimeout()
+	args = append(args, mountPoint)
+
+	ctx, span := c.startSpan(ctx, "command.btrfs", trace.WithAttributes(
+		attribute.String("command.args", strings.Join(args, " ")),
+		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	execStart := time.Now()
+	cmd := utils.CommandContext(timeoutCtx, "btrfs", args...)
+	output, err := cmd.Output()
+	execDuration := time.Since(execStart)
+	audit.Command(ctx, cmd, "", execStart, len(output), err)
+	c.metrics.CommandDurationHist.WithLabelValues("btrfs", "btrfs", mountPoint).Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			return nil, fmt.Errorf("btrfs %s timed out after %s", args[0], timeout)
+		}
+
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("btrfs %s %s failed: %w: %s", args[0], args[1], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return nil, fmt.Errorf("btrfs %s %s failed: %w", args[0], args[1], err)
+	}
+
+	return output, nil
+}
+
+// ParseQgroups parses the output of `btrfs qgroup show --raw`, skipping the
+// header and separator lines
+func ParseQgroups(output []byte) ([]Qgroup, error) {
+	var qgroups []Qgroup
+
+	for i, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !qgroupIDPattern.MatchString(fields[0]) {
+			continue
+		}
+
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 fields, got %d", i+1, len(fields))
+		}
+
+		referenced, err := utils.ParseLocalizedInt(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid referenced value '%s': %w", i+1, fields[1], err)
+		}
+
+		exclusive, err := utils.ParseLocalizedInt(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid exclusive value '%s': %w", i+1, fields[2], err)
+		}
+
+		qgroups = append(qgroups, Qgroup{
+			ID:              fields[0],
+			Path:            strings.Join(fields[3:], " "),
+			ReferencedBytes: referenced,
+			ExclusiveBytes:  exclusive,
+		})
+	}
+
+	return qgroups, nil
+}
+
+// ParseUsage extracts the per type/profile allocation lines from the output
+// of `btrfs filesystem usage -b`
+func ParseUsage(output []byte) ([]Allocation, error) {
+	var allocations []Allocation
+
+	for _, line := range strings.Split(string(output), "\n") {
+		match := allocationPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		size, err := utils.ParseLocalizedInt(match[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid allocation size '%s': %w", match[3], err)
+		}
+
+		used, err := utils.ParseLocalizedInt(match[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid allocation used '%s': %w", match[4], err)
+		}
+
+		allocations = append(allocations, Allocation{
+			Type:      strings.ToLower(match[1]),
+			Profile:   strings.ToLower(match[2]),
+			SizeBytes: size,
+			UsedBytes: used,
+		})
+	}
+
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("no allocation lines found in btrfs filesystem usage output")
+	}
+
+	return allocations, nil
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
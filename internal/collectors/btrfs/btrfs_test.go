@@ -0,0 +1,90 @@
+package btrfs
+
+import "testing"
+
+func TestParseQgroups(t *testing.T) {
+	for name, output := range map[string]string{
+		"legacy": `qgroupid         rfer         excl 
+--------         ----         ---- 
+0/5             16384        16384 
+0/256      1073741824     10485760 
+`,
+		"with path": `Qgroupid    Referenced    Exclusive   Path 
+--------    ----------    ---------   ---- 
+0/5              16384        16384   <toplevel> 
+0/256       1073741824     10485760   home 
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			qgroups, err := ParseQgroups([]byte(output))
+			if err != nil {
+				t.Fatalf("ParseQgroups failed: %v", err)
+			}
+
+			if len(qgroups) != 2 {
+				t.Fatalf("expected 2 qgroups, got %d", len(qgroups))
+			}
+
+			home := qgroups[1]
+			if home.ID != "0/256" || home.ReferencedBytes != 1073741824 || home.ExclusiveBytes != 10485760 {
+				t.Errorf("unexpected qgroup %+v", home)
+			}
+
+			if name == "with path" && home.Path != "home" {
+				t.Errorf("expected path home, got %q", home.Path)
+			}
+		})
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	output := `Overall:
+    Device size:                107374182400
+    Device allocated:            10766778368
+    Used:                         5469372416
+
+Data,single: Size:8589934592, Used:5452595200 (63.48%)
+   /dev/sda1    8589934592
+
+Metadata,DUP: Size:1073741824, Used:8372224 (0.78%)
+   /dev/sda1    2147483648
+
+System,DUP: Size:8388608, Used:16384 (0.20%)
+   /dev/sda1      16777216
+
+Unallocated:
+   /dev/sda1    96607404032
+`
+
+	allocations, err := ParseUsage([]byte(output))
+	if err != nil {
+		t.Fatalf("ParseUsage failed: %v", err)
+	}
+
+	if len(allocations) != 3 {
+		t.Fatalf("expected 3 allocations, got %d", len(allocations))
+	}
+
+	metadata := allocations[1]
+	if metadata.Type != "metadata" || metadata.Profile != "dup" ||
+		metadata.SizeBytes != 1073741824 || metadata.UsedBytes != 8372224 {
+		t.Errorf("unexpected allocation %+v", metadata)
+	}
+
+	if _, err := ParseUsage([]byte("ERROR: not a btrfs filesystem\n")); err == nil {
+		t.Error("expected error for output without allocations")
+	}
+}
+
+func TestParseUsage_Localized(t *testing.T) {
+	output := []byte("Data,single: Size:1,073,741,824, Used:536,870,912 (50.00%)\n")
+
+	allocations, err := ParseUsage(output)
+	if err != nil {
+		t.Fatalf("ParseUsage failed: %v", err)
+	}
+
+	if allocations[0].SizeBytes != 1073741824 || allocations[0].UsedBytes != 536870912 {
+		t.Errorf("unexpected allocation: %+v", allocations[0])
+	}
+}
@@ -0,0 +1,145 @@
+package bucket
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Collector periodically reports the size and object count of buckets on
+// S3-compatible endpoints
+type Collector struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	// Clients keyed by endpoint name
+	clients map[string]*Client
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new bucket collector
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	clients := make(map[string]*Client, len(cfg.Buckets.Endpoints))
+
+	for _, endpoint := range cfg.Buckets.Endpoints {
+		client, err := NewClient(endpoint.URL, endpoint.Region, endpoint.AccessKeyID, endpoint.SecretAccessKey, endpoint.VirtualHostStyle)
+		if err != nil {
+			// Validation already checked the URL
+			slog.Error("Invalid bucket endpoint", "endpoint", endpoint.Name, "error", err)
+			continue
+		}
+
+		clients[endpoint.Name] = client
+	}
+
+	return &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+		clients: clients,
+	}
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "Bucket collector", c.config.GetBucketsInterval(), c.Collect, "endpoints", len(c.config.Buckets.Endpoints))
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// Collect collects every configured bucket in turn
+func (c *Collector) Collect(ctx context.Context) {
+	for _, endpoint := range c.config.Buckets.Endpoints {
+		client, ok := c.clients[endpoint.Name]
+		if !ok {
+			continue
+		}
+
+		for _, bucket := range endpoint.Buckets {
+			if ctx.Err() != nil {
+				return
+			}
+
+			c.collect(ctx, client, endpoint.Name, bucket)
+		}
+	}
+}
+
+// collect lists one bucket and records the outcome. Failed buckets keep their
+// previous values so a flaky endpoint doesn't blank dashboards.
+func (c *Collector) collect(ctx context.Context, client *Client, endpoint, bucket string) {
+	timeout := c.config.GetBucketsTimeout()
+
+	ctx, span := c.startSpan(ctx, "bucket.collect", trace.WithAttributes(
+		attribute.String("bucket.endpoint", endpoint),
+		attribute.String("bucket.name", bucket),
+		attribute.Float64("bucket.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "bucket", nil)
+
+	startTime := time.Now()
+	labels := []string{endpoint + "/" + bucket, strconv.Itoa(int(c.config.GetBucketsInterval().Seconds())), "bucket"}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	usage, pages, err := client.BucketUsage(timeoutCtx, bucket)
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("Bucket collection failed", "endpoint", endpoint, "bucket", bucket, "pages", pages, "error", err, "error_class", reason)
+
+		return
+	}
+
+	c.metrics.BucketSizeGauge.WithLabelValues(endpoint, bucket).Set(float64(usage.SizeBytes))
+	c.metrics.BucketObjectsGauge.WithLabelValues(endpoint, bucket).Set(float64(usage.Objects))
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetAttributes(
+		attribute.Int64("bucket.size_bytes", usage.SizeBytes),
+		attribute.Int64("bucket.objects", usage.Objects),
+		attribute.Int("bucket.pages", pages),
+	)
+	span.SetStatus(codes.Ok, "bucket collection completed")
+
+	slog.Debug("Bucket collection completed", "endpoint", endpoint, "bucket", bucket, "objects", usage.Objects, "duration", duration)
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
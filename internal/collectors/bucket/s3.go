@@ -0,0 +1,270 @@
+package bucket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Usage is the total size and object count of a bucket
+type Usage struct {
+	SizeBytes int64
+	Objects   int64
+}
+
+// listBucketResult is the subset of the ListObjectsV2 response we need
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Size int64 `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// s3Error is the error document returned by S3-compatible endpoints
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Client lists buckets on one S3-compatible endpoint
+type Client struct {
+	endpoint         *url.URL
+	region           string
+	accessKeyID      string
+	secretAccessKey  string
+	virtualHostStyle bool
+	httpClient       *http.Client
+
+	// now is overridable so request signing can be tested
+	now func() time.Time
+}
+
+// NewClient creates a client for an S3-compatible endpoint. Requests are
+// signed with AWS Signature Version 4 unless accessKeyID is empty.
+func NewClient(endpoint, region, accessKeyID, secretAccessKey string, virtualHostStyle bool) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint url '%s': %w", endpoint, err)
+	}
+
+	return &Client{
+		endpoint:         u,
+		region:           region,
+		accessKeyID:      accessKeyID,
+		secretAccessKey:  secretAccessKey,
+		virtualHostStyle: virtualHostStyle,
+		httpClient:       &http.Client{},
+		now:              time.Now,
+	}, nil
+}
+
+// BucketUsage sums the size of every object in a bucket, following
+// ListObjectsV2 pagination until the listing is complete
+func (c *Client) BucketUsage(ctx context.Context, bucket string) (Usage, int, error) {
+	var (
+		usage Usage
+		pages int
+		token string
+	)
+
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		result, err := c.listObjects(ctx, bucket, query)
+		if err != nil {
+			return Usage{}, pages, err
+		}
+
+		pages++
+
+		for _, object := range result.Contents {
+			usage.SizeBytes += object.Size
+			usage.Objects++
+		}
+
+		if !result.IsTruncated {
+			return usage, pages, nil
+		}
+
+		if result.NextContinuationToken == "" {
+			return Usage{}, pages, fmt.Errorf("bucket %s: truncated listing without a continuation token", bucket)
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+// listObjects fetches one ListObjectsV2 page
+func (c *Client) listObjects(ctx context.Context, bucket string, query url.Values) (*listBucketResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.bucketURL(bucket, query).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sign(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s: %w", bucket, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s: failed to read response: %w", bucket, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var s3Err s3Error
+		if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("bucket %s: %s: %s", bucket, s3Err.Code, s3Err.Message)
+		}
+
+		return nil, fmt.Errorf("bucket %s: unexpected status %s", bucket, resp.Status)
+	}
+
+	var result listBucketResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("bucket %s: invalid ListObjectsV2 response: %w", bucket, err)
+	}
+
+	return &result, nil
+}
+
+// bucketURL addresses a bucket either path style (<host>/<bucket>) or
+// virtual host style (<bucket>.<host>)
+func (c *Client) bucketURL(bucket string, query url.Values) *url.URL {
+	u := *c.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+
+	if c.virtualHostStyle {
+		u.Host = bucket + "." + u.Host
+		u.Path = base + "/"
+	} else {
+		u.Path = base + "/" + bucket
+	}
+
+	u.RawQuery = canonicalQuery(query)
+
+	return &u
+}
+
+// sign adds AWS Signature Version 4 headers to a request with an empty body
+func (c *Client) sign(req *http.Request) {
+	if c.accessKeyID == "" {
+		return
+	}
+
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.EscapedPath(), false),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + emptyPayloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var parts []string
+
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except RFC 3986 unreserved characters.
+// Already escaped paths are passed with encodeSlash false and keep their
+// existing escapes.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		case ch == '%' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+
+	return b.String()
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
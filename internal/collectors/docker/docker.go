@@ -0,0 +1,268 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DiskUsage is the subset of the Engine API's GET /system/df response we
+// export, the same data `docker system df -v` prints
+type DiskUsage struct {
+	LayersSize int64 `json:"LayersSize"`
+	Images     []struct {
+		ID         string   `json:"Id"`
+		RepoTags   []string `json:"RepoTags"`
+		Size       int64    `json:"Size"`
+		SharedSize int64    `json:"SharedSize"`
+	} `json:"Images"`
+	Containers []struct {
+		ID     string   `json:"Id"`
+		Names  []string `json:"Names"`
+		Image  string   `json:"Image"`
+		SizeRw int64    `json:"SizeRw"`
+	} `json:"Containers"`
+	Volumes []struct {
+		Name      string `json:"Name"`
+		Driver    string `json:"Driver"`
+		UsageData *struct {
+			Size int64 `json:"Size"` // -1 when the driver doesn't report usage
+		} `json:"UsageData"`
+	} `json:"Volumes"`
+	BuildCache []struct {
+		Size int64 `json:"Size"`
+	} `json:"BuildCache"`
+}
+
+// Collector periodically reports Docker image, container and volume disk usage
+type Collector struct {
+	config     *config.Config
+	metrics    *metrics.FilesystemRegistry
+	tracer     *tracing.Tracer
+	httpClient *http.Client
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new Docker collector talking to the configured socket
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	socket := cfg.Docker.Socket
+
+	return &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "Docker collector", c.config.GetDockerInterval(), c.Collect, "socket", c.config.Docker.Socket)
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// Collect runs a single collection and records its outcome
+func (c *Collector) Collect(ctx context.Context) {
+	ctx, span := c.startSpan(ctx, "docker.collect")
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "docker", nil)
+
+	startTime := time.Now()
+	labels := []string{"docker", strconv.Itoa(int(c.config.GetDockerInterval().Seconds())), "docker"}
+
+	usage, err := c.diskUsage(ctx)
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("Docker collection failed", "error", err, "error_class", reason)
+
+		return
+	}
+
+	c.export(usage)
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetAttributes(
+		attribute.Int("docker.images", len(usage.Images)),
+		attribute.Int("docker.containers", len(usage.Containers)),
+		attribute.Int("docker.volumes", len(usage.Volumes)),
+		attribute.Float64("docker.duration_seconds", duration.Seconds()),
+	)
+	span.SetStatus(codes.Ok, "docker collection completed")
+
+	slog.Debug("Docker collection completed", "images", len(usage.Images), "volumes", len(usage.Volumes), "duration", duration)
+}
+
+// export replaces the Docker series with the latest disk usage, dropping
+// images, containers and volumes that have been removed
+func (c *Collector) export(usage *DiskUsage) {
+	c.metrics.DockerVolumeSizeGauge.Reset()
+	c.metrics.DockerImageSizeGauge.Reset()
+	c.metrics.DockerImageSharedSizeGauge.Reset()
+	c.metrics.DockerContainerWritableGauge.Reset()
+
+	for _, volume := range usage.Volumes {
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+
+		c.metrics.DockerVolumeSizeGauge.WithLabelValues(volume.Name, volume.Driver).Set(float64(volume.UsageData.Size))
+	}
+
+	for _, image := range usage.Images {
+		id, name := shortID(image.ID), imageName(image.RepoTags)
+
+		c.metrics.DockerImageSizeGauge.WithLabelValues(id, name).Set(float64(image.Size))
+
+		// SharedSize is -1 when the daemon didn't calculate it
+		if image.SharedSize >= 0 {
+			c.metrics.DockerImageSharedSizeGauge.WithLabelValues(id, name).Set(float64(image.SharedSize))
+		}
+	}
+
+	for _, container := range usage.Containers {
+		c.metrics.DockerContainerWritableGauge.WithLabelValues(containerName(container.Names, container.ID), container.Image).Set(float64(container.SizeRw))
+	}
+
+	var buildCacheSize int64
+	for _, record := range usage.BuildCache {
+		buildCacheSize += record.Size
+	}
+
+	c.metrics.DockerLayersSizeGauge.Set(float64(usage.LayersSize))
+	c.metrics.DockerBuildCacheSizeGauge.Set(float64(buildCacheSize))
+}
+
+// diskUsage calls GET /system/df on the Docker socket
+func (c *Collector) diskUsage(ctx context.Context) (*DiskUsage, error) {
+	timeout := c.config.GetDockerTimeout()
+
+	ctx, span := c.startSpan(ctx, "docker.system_df", trace.WithAttributes(
+		attribute.String("docker.socket", c.config.Docker.Socket),
+		attribute.Float64("docker.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The host is ignored when dialling the unix socket
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, "http://docker/system/df", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("docker system df timed out after %s", timeout)
+		}
+
+		return nil, fmt.Errorf("docker system df failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker system df response: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("docker.response_size_bytes", len(body)))
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker system df returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return ParseDiskUsage(body)
+}
+
+// ParseDiskUsage decodes a /system/df response
+func ParseDiskUsage(body []byte) (*DiskUsage, error) {
+	var usage DiskUsage
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return nil, fmt.Errorf("invalid docker system df response: %w", err)
+	}
+
+	return &usage, nil
+}
+
+// shortID trims an image ID to the 12 hex digits the docker CLI shows
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+
+	return id
+}
+
+// imageName returns the first repository tag of an image, or "<none>" for
+// dangling images
+func imageName(repoTags []string) string {
+	for _, tag := range repoTags {
+		if tag != "<none>:<none>" {
+			return tag
+		}
+	}
+
+	return "<none>"
+}
+
+// containerName returns a container's primary name without the leading slash
+func containerName(names []string, id string) string {
+	if len(names) > 0 {
+		return strings.TrimPrefix(names[0], "/")
+	}
+
+	return shortID(id)
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
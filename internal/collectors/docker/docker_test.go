@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	promexporter_config "github.com/d0ugal/promexporter/config"
+)
+
+// systemDF is a trimmed /system/df response from Docker 24
+const systemDF = `{
+  "LayersSize": 1092588,
+  "Images": [
+    {"Id": "sha256:2b8fd9751c4c0f5dd266fcae00707e67a2545ef34f9a29354585f93dac906749", "RepoTags": ["busybox:latest"], "Size": 1092588, "SharedSize": 0},
+    {"Id": "sha256:9b1f9c2d5e0a4c1b8f3e6d7a0b2c4e6f8a0b2c4d6e8f0a2b4c6d8e0f2a4b6c8", "RepoTags": ["<none>:<none>"], "Size": 5000, "SharedSize": -1}
+  ],
+  "Containers": [
+    {"Id": "e575172ed11dc01bfce087fb27bee502db149e1a0fad7c296ad300bbff178148", "Names": ["/top"], "Image": "busybox", "SizeRw": 2048}
+  ],
+  "Volumes": [
+    {"Name": "pgdata", "Driver": "local", "UsageData": {"Size": 10920104, "RefCount": 1}},
+    {"Name": "nfs-share", "Driver": "local", "UsageData": {"Size": -1, "RefCount": 0}}
+  ],
+  "BuildCache": [{"Size": 300}, {"Size": 700}]
+}`
+
+func TestParseDiskUsage(t *testing.T) {
+	usage, err := ParseDiskUsage([]byte(systemDF))
+	if err != nil {
+		t.Fatalf("ParseDiskUsage failed: %v", err)
+	}
+
+	if usage.LayersSize != 1092588 || len(usage.Images) != 2 || len(usage.Containers) != 1 || len(usage.Volumes) != 2 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	if usage.Volumes[0].UsageData.Size != 10920104 || usage.Volumes[1].UsageData.Size != -1 {
+		t.Errorf("unexpected volume sizes: %+v", usage.Volumes)
+	}
+
+	if name := imageName(usage.Images[1].RepoTags); name != "<none>" {
+		t.Errorf("expected dangling image name <none>, got %s", name)
+	}
+
+	if id := shortID(usage.Images[0].ID); id != "2b8fd9751c4c" {
+		t.Errorf("unexpected short id %s", id)
+	}
+
+	if name := containerName(usage.Containers[0].Names, usage.Containers[0].ID); name != "top" {
+		t.Errorf("unexpected container name %s", name)
+	}
+}
+
+func TestDiskUsage_Socket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/system/df" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, systemDF)
+	}))
+	server.Listener = listener
+	server.Start()
+
+	defer server.Close()
+
+	cfg := &config.Config{
+		Docker: config.DockerConfig{
+			Socket:  socket,
+			Timeout: promexporter_config.Duration{Duration: 5 * time.Second},
+		},
+	}
+
+	usage, err := NewCollector(cfg, nil, nil).diskUsage(context.Background())
+	if err != nil {
+		t.Fatalf("diskUsage failed: %v", err)
+	}
+
+	if len(usage.BuildCache) != 2 || usage.Containers[0].SizeRw != 2048 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
@@ -0,0 +1,161 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Service account files mounted into every pod
+const (
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// PodRef identifies a pod
+type PodRef struct {
+	Namespace string
+	Name      string
+}
+
+// ClaimRef identifies the PersistentVolumeClaim bound to a PersistentVolume
+type ClaimRef struct {
+	Namespace string
+	Name      string
+}
+
+// objectList is the subset of a pod or PersistentVolume list we need
+type objectList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"metadata"`
+		Spec struct {
+			ClaimRef *struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"claimRef"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// APIClient reads pods and PersistentVolumes from the Kubernetes API server
+type APIClient struct {
+	baseURL    string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// NewInClusterClient creates a client from the service account and
+// environment Kubernetes provides to every pod
+func NewInClusterClient() (*APIClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+
+	caData, err := os.ReadFile(serviceAccountCA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in %s", serviceAccountCA)
+	}
+
+	return &APIClient{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		tokenFile: serviceAccountToken,
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// PodsOnNode maps the UID of every pod scheduled on nodeName to its name
+func (c *APIClient) PodsOnNode(ctx context.Context, nodeName string) (map[string]PodRef, error) {
+	query := url.Values{"fieldSelector": {"spec.nodeName=" + nodeName}}
+
+	list, err := c.list(ctx, "/api/v1/pods?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make(map[string]PodRef, len(list.Items))
+	for _, item := range list.Items {
+		pods[item.Metadata.UID] = PodRef{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+	}
+
+	return pods, nil
+}
+
+// BoundClaims maps PersistentVolume names to the claims bound to them
+func (c *APIClient) BoundClaims(ctx context.Context) (map[string]ClaimRef, error) {
+	list, err := c.list(ctx, "/api/v1/persistentvolumes")
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]ClaimRef, len(list.Items))
+
+	for _, item := range list.Items {
+		if item.Spec.ClaimRef != nil {
+			claims[item.Metadata.Name] = ClaimRef{Namespace: item.Spec.ClaimRef.Namespace, Name: item.Spec.ClaimRef.Name}
+		}
+	}
+
+	return claims, nil
+}
+
+// list performs an authenticated GET of a list endpoint. The token is re-read
+// every time since projected service account tokens are rotated.
+func (c *APIClient) list(ctx context.Context, path string) (*objectList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: failed to read response: %w", path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var list objectList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("GET %s: invalid response: %w", path, err)
+	}
+
+	return &list, nil
+}
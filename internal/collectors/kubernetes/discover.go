@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// csiPlugin is the volume plugin directory of CSI volumes, whose data is
+// mounted on a "mount" subdirectory
+const csiPlugin = "kubernetes.io~csi"
+
+// ephemeralPlugins are volume plugins that never hold PersistentVolumes
+var ephemeralPlugins = map[string]bool{
+	"kubernetes.io~empty-dir":    true,
+	"kubernetes.io~configmap":    true,
+	"kubernetes.io~secret":       true,
+	"kubernetes.io~projected":    true,
+	"kubernetes.io~downward-api": true,
+}
+
+// Volume is a volume mounted into a pod, found under the kubelet's pods directory
+type Volume struct {
+	PodUID string
+	Plugin string // e.g. kubernetes.io~csi or kubernetes.io~nfs
+	Name   string // The PersistentVolume name for PV-backed volumes
+	Path   string // Where the volume's data is mounted
+}
+
+// Discover lists the non-ephemeral volumes of every pod, following the
+// kubelet layout <pods dir>/<pod uid>/volumes/<plugin>/<volume>
+func Discover(podsDir string) ([]Volume, error) {
+	pods, err := os.ReadDir(podsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumes []Volume
+
+	for _, pod := range pods {
+		if !pod.IsDir() {
+			continue
+		}
+
+		volumesDir := filepath.Join(podsDir, pod.Name(), "volumes")
+
+		plugins, err := os.ReadDir(volumesDir)
+		if err != nil {
+			// Pods are created and removed while we look
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, plugin := range plugins {
+			if !plugin.IsDir() || ephemeralPlugins[plugin.Name()] {
+				continue
+			}
+
+			entries, err := os.ReadDir(filepath.Join(volumesDir, plugin.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+
+				path := filepath.Join(volumesDir, plugin.Name(), entry.Name())
+				if plugin.Name() == csiPlugin {
+					path = filepath.Join(path, "mount")
+				}
+
+				volumes = append(volumes, Volume{
+					PodUID: pod.Name(),
+					Plugin: plugin.Name(),
+					Name:   entry.Name(),
+					Path:   path,
+				})
+			}
+		}
+	}
+
+	return volumes, nil
+}
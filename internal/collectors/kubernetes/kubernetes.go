@@ -0,0 +1,253 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statTimeout bounds each statfs, since network-backed volumes can hang
+const statTimeout = 5 * time.Second
+
+// Collector periodically reports the capacity of PersistentVolumes mounted
+// into pods on this node
+type Collector struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	// api is nil unless resolve_names is enabled and in-cluster config was found
+	api *APIClient
+
+	// Volume paths whose statfs has not returned yet; they are skipped until it does
+	hungMutex sync.Mutex
+	hung      map[string]bool
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new PVC collector
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	c := &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+		hung:    make(map[string]bool),
+	}
+
+	if cfg.Kubernetes.ResolveNames {
+		api, err := NewInClusterClient()
+		if err != nil {
+			slog.Error("Kubernetes name resolution disabled", "error", err)
+		} else {
+			c.api = api
+		}
+	}
+
+	return c
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "Kubernetes PVC collector", c.config.GetKubernetesInterval(), c.Collect, "pods_dir", c.config.Kubernetes.PodsDir, "resolve_names", c.api != nil)
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// collect discovers mounted volumes and records the outcome. Volumes are still
+// exported, without names, when the API server can't be reached.
+func (c *Collector) Collect(ctx context.Context) {
+	ctx, span := c.startSpan(ctx, "kubernetes.collect", trace.WithAttributes(
+		attribute.String("kubernetes.pods_dir", c.config.Kubernetes.PodsDir),
+	))
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "kubernetes", nil)
+
+	startTime := time.Now()
+	labels := []string{"kubernetes", strconv.Itoa(int(c.config.GetKubernetesInterval().Seconds())), "kubernetes"}
+
+	exported, err := c.collectVolumes(ctx, span)
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("Kubernetes PVC collection failed", "error", err, "error_class", reason)
+
+		return
+	}
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetAttributes(attribute.Int("kubernetes.volumes", exported))
+	span.SetStatus(codes.Ok, "kubernetes collection completed")
+
+	slog.Debug("Kubernetes PVC collection completed", "volumes", exported, "duration", duration)
+}
+
+// collectVolumes exports every mounted volume and returns how many were exported
+func (c *Collector) collectVolumes(ctx context.Context, span trace.Span) (int, error) {
+	volumes, err := Discover(c.config.Kubernetes.PodsDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to discover pod volumes: %w", err)
+	}
+
+	pods, claims, resolveErr := c.resolveNames(ctx)
+
+	c.metrics.PVCSizeGauge.Reset()
+	c.metrics.PVCAvailableGauge.Reset()
+	c.metrics.PVCUsedGauge.Reset()
+
+	exported := 0
+
+	for _, volume := range volumes {
+		usage, ok := c.statVolume(volume)
+		if !ok {
+			continue
+		}
+
+		pod, claim := pods[volume.PodUID], claims[volume.Name]
+		labelValues := []string{claim.Namespace, claim.Name, pod.Name, volume.PodUID, volume.Name}
+
+		// Unbound volumes still get the pod's namespace
+		if labelValues[0] == "" {
+			labelValues[0] = pod.Namespace
+		}
+
+		c.metrics.PVCSizeGauge.WithLabelValues(labelValues...).Set(float64(usage.Size))
+		c.metrics.PVCAvailableGauge.WithLabelValues(labelValues...).Set(float64(usage.Available))
+		c.metrics.PVCUsedGauge.WithLabelValues(labelValues...).Set(float64(usage.Size - usage.Available))
+
+		exported++
+	}
+
+	span.SetAttributes(attribute.Int("kubernetes.volumes_discovered", len(volumes)))
+
+	return exported, resolveErr
+}
+
+// resolveNames looks up pod names and PVC bindings when enabled
+func (c *Collector) resolveNames(ctx context.Context) (map[string]PodRef, map[string]ClaimRef, error) {
+	if c.api == nil {
+		return nil, nil, nil
+	}
+
+	ctx, span := c.startSpan(ctx, "kubernetes.resolve_names")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, statTimeout)
+	defer cancel()
+
+	pods, podsErr := c.api.PodsOnNode(ctx, c.config.Kubernetes.NodeName)
+	claims, claimsErr := c.api.BoundClaims(ctx)
+
+	err := errors.Join(podsErr, claimsErr)
+	if err != nil {
+		span.RecordError(err)
+		err = fmt.Errorf("failed to resolve pod and PVC names: %w", err)
+	}
+
+	return pods, claims, err
+}
+
+// statVolume reads the usage of a mounted volume with a deadline. Volumes
+// that aren't mounted (yet, or any more) are skipped rather than reporting
+// the kubelet's own filesystem.
+func (c *Collector) statVolume(volume Volume) (fsstat.Usage, bool) {
+	c.hungMutex.Lock()
+	hung := c.hung[volume.Path]
+	c.hungMutex.Unlock()
+
+	if hung {
+		return fsstat.Usage{}, false
+	}
+
+	type result struct {
+		usage fsstat.Usage
+		err   error
+	}
+
+	done := make(chan result, 1)
+	finished := false // Guarded by hungMutex
+
+	go func() {
+		usage, err := statIfMounted(volume.Path)
+
+		c.hungMutex.Lock()
+		finished = true
+		delete(c.hung, volume.Path)
+		c.hungMutex.Unlock()
+
+		done <- result{usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			slog.Debug("Skipping pod volume", "path", volume.Path, "error", r.err)
+			return fsstat.Usage{}, false
+		}
+
+		return r.usage, true
+	case <-time.After(statTimeout):
+		c.hungMutex.Lock()
+		if !finished {
+			c.hung[volume.Path] = true
+		}
+		c.hungMutex.Unlock()
+
+		slog.Warn("statfs on pod volume timed out", "path", volume.Path, "timeout", statTimeout)
+
+		return fsstat.Usage{}, false
+	}
+}
+
+// statIfMounted returns the usage of path if it is a mount point
+func statIfMounted(path string) (fsstat.Usage, error) {
+	mounted, err := fsstat.IsMountPoint(path)
+	if err != nil {
+		return fsstat.Usage{}, err
+	}
+
+	if !mounted {
+		return fsstat.Usage{}, fmt.Errorf("%s is %w", path, utils.ErrNotMounted)
+	}
+
+	return fsstat.Stat(path)
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
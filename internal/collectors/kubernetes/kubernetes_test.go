@@ -0,0 +1,98 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	podsDir := t.TempDir()
+
+	for _, dir := range []string{
+		"pod-a/volumes/kubernetes.io~csi/pvc-1111/mount",
+		"pod-a/volumes/kubernetes.io~projected/kube-api-access-xyz",
+		"pod-a/volumes/kubernetes.io~empty-dir/cache",
+		"pod-b/volumes/kubernetes.io~nfs/pv-nfs",
+		"pod-c/plugins",
+	} {
+		if err := os.MkdirAll(filepath.Join(podsDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	volumes, err := Discover(podsDir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	expected := map[string]Volume{
+		"pvc-1111": {PodUID: "pod-a", Plugin: "kubernetes.io~csi", Name: "pvc-1111", Path: filepath.Join(podsDir, "pod-a/volumes/kubernetes.io~csi/pvc-1111/mount")},
+		"pv-nfs":   {PodUID: "pod-b", Plugin: "kubernetes.io~nfs", Name: "pv-nfs", Path: filepath.Join(podsDir, "pod-b/volumes/kubernetes.io~nfs/pv-nfs")},
+	}
+
+	if len(volumes) != len(expected) {
+		t.Fatalf("expected %d volumes, got %+v", len(expected), volumes)
+	}
+
+	for _, volume := range volumes {
+		if volume != expected[volume.Name] {
+			t.Errorf("unexpected volume %+v", volume)
+		}
+	}
+}
+
+func TestAPIClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/pods":
+			if r.URL.Query().Get("fieldSelector") != "spec.nodeName=node-1" {
+				http.Error(w, "missing field selector", http.StatusBadRequest)
+				return
+			}
+
+			fmt.Fprint(w, `{"items":[{"metadata":{"name":"postgres-0","namespace":"db","uid":"pod-a"}}]}`)
+		case "/api/v1/persistentvolumes":
+			fmt.Fprint(w, `{"items":[
+				{"metadata":{"name":"pvc-1111"},"spec":{"claimRef":{"namespace":"db","name":"data-postgres-0"}}},
+				{"metadata":{"name":"pv-available"},"spec":{}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &APIClient{baseURL: server.URL, tokenFile: tokenFile, httpClient: server.Client()}
+
+	pods, err := client.PodsOnNode(context.Background(), "node-1")
+	if err != nil {
+		t.Fatalf("PodsOnNode failed: %v", err)
+	}
+
+	if pods["pod-a"] != (PodRef{Namespace: "db", Name: "postgres-0"}) {
+		t.Errorf("unexpected pods %+v", pods)
+	}
+
+	claims, err := client.BoundClaims(context.Background())
+	if err != nil {
+		t.Fatalf("BoundClaims failed: %v", err)
+	}
+
+	if len(claims) != 1 || claims["pvc-1111"] != (ClaimRef{Namespace: "db", Name: "data-postgres-0"}) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
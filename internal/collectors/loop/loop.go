@@ -0,0 +1,47 @@
+package loop
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Loop drives one of the optional collectors: it collects straight away and
+// then on every interval until its context is cancelled. The zero value is
+// ready to use.
+type Loop struct {
+	wg sync.WaitGroup
+}
+
+// Start runs collect in the background. name is logged with logArgs when the
+// loop starts, and again when it stops.
+func (l *Loop) Start(ctx context.Context, name string, interval time.Duration, collect func(context.Context), logArgs ...any) {
+	l.wg.Add(1)
+
+	go func() {
+		defer l.wg.Done()
+
+		slog.Info(name+" started", append([]any{"interval", interval}, logArgs...)...)
+
+		collect(ctx)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info(name + " stopping")
+				return
+			case <-ticker.C:
+				collect(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the loop has exited after its context was cancelled
+func (l *Loop) Wait() {
+	l.wg.Wait()
+}
@@ -0,0 +1,38 @@
+package loop
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoop_CollectsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var collections atomic.Int32
+
+	var l Loop
+	l.Start(ctx, "Test collector", 10*time.Millisecond, func(context.Context) {
+		collections.Add(1)
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for collections.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	l.Wait()
+
+	stopped := collections.Load()
+	if stopped < 3 {
+		t.Fatalf("expected an immediate collection and then one per interval, got %d", stopped)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if collections.Load() != stopped {
+		t.Errorf("expected no collections after Wait returned, got %d more", collections.Load()-stopped)
+	}
+}
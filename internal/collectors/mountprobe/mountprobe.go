@@ -0,0 +1,295 @@
+package mountprobe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Failure reasons recorded by the probe
+const (
+	ReasonTimeout = "timeout" // statfs did not return within the deadline
+	ReasonStale   = "stale"   // ESTALE: the server no longer recognises the file handle
+	ReasonError   = "error"   // Any other statfs error
+)
+
+// mountEscapes decodes the octal escapes used in /proc/mounts
+var mountEscapes = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// mountStatus is the probe state of one network mount
+type mountStatus struct {
+	volume    string
+	reachable bool
+	reason    string
+
+	// inFlight is set while a statfs call is outstanding. A call against a
+	// hung server may never return, so at most one is left running per mount
+	// and later rounds report a timeout instead of piling up goroutines.
+	inFlight bool
+}
+
+// Prober periodically checks that the configured network mounts respond to
+// statfs within a short deadline
+type Prober struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	// Probe state keyed by mount point
+	mu       sync.RWMutex
+	statuses map[string]*mountStatus
+
+	loop loop.Loop
+}
+
+// NewProber creates a new network mount prober
+func NewProber(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Prober {
+	return &Prober{
+		config:   cfg,
+		metrics:  m,
+		tracer:   tracer,
+		statuses: make(map[string]*mountStatus),
+	}
+}
+
+// Start starts the probe loop. It stops when ctx is cancelled.
+func (p *Prober) Start(ctx context.Context) {
+	p.loop.Start(ctx, "Mount probe", p.config.GetMountProbeInterval(), p.Collect,
+		"timeout", p.config.GetMountProbeTimeout(),
+		"fs_types", p.config.GetMountProbeFSTypes(),
+	)
+}
+
+// Wait blocks until the probe loop has exited after ctx was cancelled. A
+// statfs call stuck on a hung server is not waited for.
+func (p *Prober) Wait() {
+	p.loop.Wait()
+}
+
+// Unreachable reports whether path lies on a probed mount that failed its
+// most recent probe, returning that mount point. Paths on mounts that have
+// not been probed are assumed to be reachable.
+func (p *Prober) Unreachable(path string) (string, bool) {
+	path = filepath.Clean(path)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for mountPoint, status := range p.statuses {
+		if status.reachable {
+			continue
+		}
+
+		if path == mountPoint || strings.HasPrefix(path, mountPoint+string(filepath.Separator)) {
+			return mountPoint, true
+		}
+	}
+
+	return "", false
+}
+
+// Collect probes every configured filesystem that is currently mounted with
+// a network filesystem type
+func (p *Prober) Collect(ctx context.Context) {
+	types, err := readMountTypes()
+	if err != nil {
+		slog.Error("Mount probe failed to read mount table", "error", err)
+		return
+	}
+
+	networkTypes := p.config.GetMountProbeFSTypes()
+
+	for _, fs := range p.config.Filesystems {
+		if ctx.Err() != nil {
+			return
+		}
+
+		mountPoint := filepath.Clean(fs.MountPoint)
+
+		if !fs.IsEnabled() || !slices.Contains(networkTypes, types[mountPoint]) {
+			p.forget(fs.Name, mountPoint)
+			continue
+		}
+
+		p.probe(ctx, fs.Name, mountPoint)
+	}
+}
+
+// probe runs statfs against one mount with the configured deadline and
+// records the outcome
+func (p *Prober) probe(ctx context.Context, volume, mountPoint string) {
+	timeout := p.config.GetMountProbeTimeout()
+
+	ctx, span := p.startSpan(ctx, "mount_probe.probe", trace.WithAttributes(
+		attribute.String("filesystem.name", volume),
+		attribute.String("filesystem.mount_point", mountPoint),
+		attribute.Float64("probe.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	defer utils.RecoverPanic(span, p.metrics.PanicsCounter, "mount_probe", nil)
+
+	p.mu.Lock()
+
+	status, exists := p.statuses[mountPoint]
+	if !exists {
+		status = &mountStatus{volume: volume, reachable: true}
+		p.statuses[mountPoint] = status
+	}
+
+	if status.inFlight {
+		p.mu.Unlock()
+		span.AddEvent("previous_probe_still_running")
+		p.record(span, volume, mountPoint, ReasonTimeout, fmt.Errorf("previous statfs on %s has not returned", mountPoint))
+
+		return
+	}
+
+	status.inFlight = true
+	p.mu.Unlock()
+
+	done := make(chan error, 1)
+	start := time.Now()
+
+	go func() {
+		_, err := fsstat.Stat(mountPoint)
+
+		p.mu.Lock()
+		status.inFlight = false
+		p.mu.Unlock()
+
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+		p.record(span, volume, mountPoint, ReasonTimeout, fmt.Errorf("statfs on %s did not return within %s", mountPoint, timeout))
+	case err := <-done:
+		span.SetAttributes(attribute.Float64("probe.duration_seconds", time.Since(start).Seconds()))
+
+		switch {
+		case err == nil:
+			p.record(span, volume, mountPoint, "", nil)
+		case errors.Is(err, syscall.ESTALE):
+			p.record(span, volume, mountPoint, ReasonStale, err)
+		default:
+			p.record(span, volume, mountPoint, ReasonError, err)
+		}
+	}
+}
+
+// record updates the reachability state and metrics after a probe. An empty
+// reason means the mount responded.
+func (p *Prober) record(span trace.Span, volume, mountPoint, reason string, err error) {
+	reachable := reason == ""
+
+	p.mu.Lock()
+	status := p.statuses[mountPoint]
+	wasReachable, previousReason := status.reachable, status.reason
+	status.reachable, status.reason = reachable, reason
+	p.mu.Unlock()
+
+	labels := p.config.GetFilesystemLabels(volume)
+
+	if reachable {
+		p.metrics.VolumeReachableGauge.WithLabelValues(p.metrics.ItemLabelValues(labels, volume, mountPoint)...).Set(1)
+		span.SetStatus(codes.Ok, "mount reachable")
+
+		if !wasReachable {
+			slog.Info("Network mount reachable again", "volume", volume, "mount_point", mountPoint)
+		}
+
+		return
+	}
+
+	p.metrics.VolumeReachableGauge.WithLabelValues(p.metrics.ItemLabelValues(labels, volume, mountPoint)...).Set(0)
+	p.metrics.VolumeProbeFailuresCounter.WithLabelValues(p.metrics.ItemLabelValues(labels, volume, mountPoint, reason)...).Inc()
+
+	span.RecordError(err)
+	span.SetAttributes(attribute.String("probe.failure_reason", reason))
+	span.SetStatus(codes.Error, err.Error())
+
+	if wasReachable || previousReason != reason {
+		slog.Warn("Network mount unreachable", "volume", volume, "mount_point", mountPoint, "reason", reason, "error", err)
+	}
+}
+
+// forget drops a mount that is no longer a network mount, e.g. after it was
+// unmounted, so it stops being reported
+func (p *Prober) forget(volume, mountPoint string) {
+	p.mu.Lock()
+	_, exists := p.statuses[mountPoint]
+	delete(p.statuses, mountPoint)
+	p.mu.Unlock()
+
+	if exists {
+		p.metrics.VolumeReachableGauge.DeleteLabelValues(p.metrics.ItemLabelValues(p.config.GetFilesystemLabels(volume), volume, mountPoint)...)
+	}
+}
+
+// readMountTypes reads the filesystem type of every mount point
+func readMountTypes() (map[string]string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer f.Close()
+
+	return parseMountTypes(f)
+}
+
+// parseMountTypes maps mount points to filesystem types from /proc/mounts
+// formatted input. The last entry wins since later mounts shadow earlier ones.
+func parseMountTypes(r io.Reader) (map[string]string, error) {
+	types := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		types[mountEscapes.Replace(fields[1])] = fields[2]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	return types, nil
+}
+
+// startSpan is a helper to start an OTEL span
+func (p *Prober) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if p.tracer != nil && p.tracer.IsEnabled() {
+		return p.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
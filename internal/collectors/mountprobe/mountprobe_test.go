@@ -0,0 +1,56 @@
+package mountprobe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMountTypes(t *testing.T) {
+	input := `/dev/sda1 / ext4 rw,relatime 0 0
+nas:/export/media /mnt/media nfs4 rw,relatime,vers=4.2 0 0
+//files/share /mnt/my\040share cifs rw 0 0
+tmpfs /mnt/media tmpfs rw 0 0
+`
+
+	types, err := parseMountTypes(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"/":             "ext4",
+		"/mnt/media":    "tmpfs", // Later mounts shadow earlier ones
+		"/mnt/my share": "cifs",
+	}
+
+	for mountPoint, fsType := range expected {
+		if types[mountPoint] != fsType {
+			t.Errorf("expected %s to be %s, got %q", mountPoint, fsType, types[mountPoint])
+		}
+	}
+}
+
+func TestProber_Unreachable(t *testing.T) {
+	p := NewProber(nil, nil, nil)
+	p.statuses["/mnt/nas"] = &mountStatus{volume: "nas", reachable: false, reason: ReasonStale}
+	p.statuses["/mnt/ok"] = &mountStatus{volume: "ok", reachable: true}
+
+	tests := []struct {
+		path       string
+		mountPoint string
+		expected   bool
+	}{
+		{"/mnt/nas", "/mnt/nas", true},
+		{"/mnt/nas/projects/", "/mnt/nas", true},
+		{"/mnt/nasty", "", false},
+		{"/mnt/ok/data", "", false},
+		{"/srv", "", false},
+	}
+
+	for _, tt := range tests {
+		mountPoint, unreachable := p.Unreachable(tt.path)
+		if unreachable != tt.expected || mountPoint != tt.mountPoint {
+			t.Errorf("Unreachable(%s) = (%q, %v), expected (%q, %v)", tt.path, mountPoint, unreachable, tt.mountPoint, tt.expected)
+		}
+	}
+}
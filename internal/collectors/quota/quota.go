@@ -0,0 +1,168 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/quotactl"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Collector periodically reports user and group quota usage and limits
+type Collector struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new quota collector
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	return &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+	}
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "Quota collector", c.config.GetQuotasInterval(), c.Collect, "filesystems", len(c.config.Quotas.Filesystems))
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// Collect collects every configured filesystem in turn
+func (c *Collector) Collect(ctx context.Context) {
+	for _, fs := range c.config.Quotas.Filesystems {
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.collect(ctx, fs)
+	}
+}
+
+// collect reads every configured quota type for one filesystem and records
+// the outcome. Each type is exported independently so a filesystem with only
+// user quotas enabled still reports them.
+func (c *Collector) collect(ctx context.Context, fs config.QuotaFilesystem) {
+	_, span := c.startSpan(ctx, "quota.collect", trace.WithAttributes(
+		attribute.String("quota.volume", fs.Name),
+		attribute.String("quota.mount_point", fs.MountPoint),
+	))
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "quota", nil)
+
+	startTime := time.Now()
+	labels := []string{fs.Name, strconv.Itoa(int(c.config.GetQuotasInterval().Seconds())), "quota"}
+
+	err := c.collectFilesystem(fs, span)
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("Quota collection failed", "volume", fs.Name, "mount_point", fs.MountPoint, "error", err, "error_class", reason)
+
+		return
+	}
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetStatus(codes.Ok, "quota collection completed")
+	slog.Debug("Quota collection completed", "volume", fs.Name, "duration", duration)
+}
+
+// collectFilesystem resolves the device and exports each quota type
+func (c *Collector) collectFilesystem(fs config.QuotaFilesystem, span trace.Span) error {
+	device := fs.Device
+	if device == "" {
+		var err error
+
+		device, err = quotactl.DeviceForMount(fs.MountPoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	span.SetAttributes(attribute.String("quota.device", device))
+
+	var errs []error
+
+	for _, quotaType := range fs.Types {
+		kind, gauges := quotactl.User, c.metrics.UserQuota
+		if quotaType == config.QuotaTypeGroup {
+			kind, gauges = quotactl.Group, c.metrics.GroupQuota
+		}
+
+		usages, err := quotactl.List(device, kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		export(gauges, fs.Name, usages)
+		span.SetAttributes(attribute.Int(fmt.Sprintf("quota.%s_entries", kind), len(usages)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// export replaces the quota series for a volume
+func export(gauges metrics.QuotaGauges, volume string, usages []quotactl.Usage) {
+	vecs := []*prometheus.GaugeVec{
+		gauges.UsedBytes, gauges.SoftLimitBytes, gauges.HardLimitBytes,
+		gauges.UsedInodes, gauges.SoftLimitInodes, gauges.HardLimitInodes,
+	}
+
+	for _, vec := range vecs {
+		vec.DeletePartialMatch(prometheus.Labels{"volume": volume})
+	}
+
+	for _, usage := range usages {
+		id := strconv.FormatUint(uint64(usage.ID), 10)
+
+		gauges.UsedBytes.WithLabelValues(volume, id).Set(float64(usage.UsedBytes))
+		gauges.SoftLimitBytes.WithLabelValues(volume, id).Set(float64(usage.SoftLimitBytes))
+		gauges.HardLimitBytes.WithLabelValues(volume, id).Set(float64(usage.HardLimitBytes))
+		gauges.UsedInodes.WithLabelValues(volume, id).Set(float64(usage.UsedInodes))
+		gauges.SoftLimitInodes.WithLabelValues(volume, id).Set(float64(usage.SoftLimitInodes))
+		gauges.HardLimitInodes.WithLabelValues(volume, id).Set(float64(usage.HardLimitInodes))
+	}
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"testing"
+
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/quotactl"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestExport(t *testing.T) {
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	gauges := m.UserQuota
+
+	export(gauges, "home", []quotactl.Usage{
+		{ID: 1000, UsedBytes: 4096, SoftLimitBytes: 1 << 20, HardLimitBytes: 2 << 20, UsedInodes: 3, HardLimitInodes: 100},
+		{ID: 1001, UsedBytes: 8192},
+	})
+	export(gauges, "scratch", []quotactl.Usage{{ID: 1000, UsedBytes: 1}})
+
+	if used := testutil.ToFloat64(gauges.UsedBytes.WithLabelValues("home", "1000")); used != 4096 {
+		t.Errorf("expected 4096 used bytes, got %g", used)
+	}
+
+	if limit := testutil.ToFloat64(gauges.HardLimitBytes.WithLabelValues("home", "1000")); limit != 2<<20 {
+		t.Errorf("expected a %d byte hard limit, got %g", 2<<20, limit)
+	}
+
+	if inodes := testutil.ToFloat64(gauges.HardLimitInodes.WithLabelValues("home", "1000")); inodes != 100 {
+		t.Errorf("expected a 100 inode hard limit, got %g", inodes)
+	}
+
+	// 1001's quota entry was removed
+	export(gauges, "home", []quotactl.Usage{{ID: 1000, UsedBytes: 2048}})
+
+	if series := testutil.CollectAndCount(gauges.UsedBytes); series != 2 {
+		t.Errorf("expected 1001's series to be deleted and scratch's kept, got %d series", series)
+	}
+
+	if used := testutil.ToFloat64(gauges.UsedBytes.WithLabelValues("scratch", "1000")); used != 1 {
+		t.Errorf("expected scratch to be untouched, got %g", used)
+	}
+}
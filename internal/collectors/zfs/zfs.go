@@ -0,0 +1,238 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/audit"
+	"filesystem-exporter/internal/collectors/loop"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// listProperties are the columns requested from zfs list, in order
+var listProperties = []string{"name", "used", "avail", "refer", "compressratio"}
+
+// Dataset is a single row of zfs list output
+type Dataset struct {
+	Name            string
+	UsedBytes       int64
+	AvailableBytes  int64
+	ReferencedBytes int64
+	CompressRatio   float64
+}
+
+// Pool returns the pool a dataset belongs to
+func (d Dataset) Pool() string {
+	pool, _, _ := strings.Cut(d.Name, "/")
+	return pool
+}
+
+// Collector periodically reports per-dataset ZFS usage
+type Collector struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+
+	// Datasets exported by the previous collection, so vanished ones can be removed
+	mu   sync.Mutex
+	seen map[string]string
+
+	loop loop.Loop
+}
+
+// NewCollector creates a new ZFS collector
+func NewCollector(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Collector {
+	return &Collector{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+		seen:    make(map[string]string),
+	}
+}
+
+// Start starts the collection loop. It stops when ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	c.loop.Start(ctx, "ZFS collector", c.config.GetZFSInterval(), c.Collect, "datasets", c.config.ZFS.Datasets)
+}
+
+// Wait blocks until the collection loop has exited after ctx was cancelled
+func (c *Collector) Wait() {
+	c.loop.Wait()
+}
+
+// Collect runs a single collection and records its outcome
+func (c *Collector) Collect(ctx context.Context) {
+	ctx, span := c.startSpan(ctx, "zfs.collect")
+	defer span.End()
+
+	defer utils.RecoverPanic(span, c.metrics.PanicsCounter, "zfs", nil)
+
+	startTime := time.Now()
+	labels := []string{"zfs", strconv.Itoa(int(c.config.GetZFSInterval().Seconds())), "zfs"}
+
+	datasets, err := c.listDatasets(ctx)
+
+	c.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		reason := utils.ClassifyFailure(err)
+		c.metrics.CollectionFailedCounter.WithLabelValues(append(labels, reason)...).Inc()
+		slog.Error("ZFS collection failed", "error", err, "error_class", reason)
+
+		return
+	}
+
+	c.export(datasets)
+
+	duration := time.Since(startTime)
+
+	c.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
+	c.metrics.CollectionDuration.WithLabelValues(labels...).Set(duration.Seconds())
+	c.metrics.CollectionDurationHist.WithLabelValues(labels...).Observe(duration.Seconds())
+	c.metrics.CollectionTimestampGauge.WithLabelValues(labels...).Set(float64(time.Now().Unix()))
+
+	span.SetAttributes(
+		attribute.Int("zfs.datasets", len(datasets)),
+		attribute.Float64("zfs.duration_seconds", duration.Seconds()),
+	)
+	span.SetStatus(codes.Ok, "zfs collection completed")
+
+	slog.Debug("ZFS collection completed", "datasets", len(datasets), "duration", duration)
+}
+
+// export updates the dataset gauges and removes datasets that have disappeared
+func (c *Collector) export(datasets []Dataset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]string, len(datasets))
+
+	for _, dataset := range datasets {
+		pool := dataset.Pool()
+		current[dataset.Name] = pool
+
+		c.metrics.ZFSDatasetUsedGauge.WithLabelValues(dataset.Name, pool).Set(float64(dataset.UsedBytes))
+		c.metrics.ZFSDatasetAvailableGauge.WithLabelValues(dataset.Name, pool).Set(float64(dataset.AvailableBytes))
+		c.metrics.ZFSDatasetReferencedGauge.WithLabelValues(dataset.Name, pool).Set(float64(dataset.ReferencedBytes))
+		c.metrics.ZFSDatasetCompressRatioGauge.WithLabelValues(dataset.Name, pool).Set(dataset.CompressRatio)
+	}
+
+	for name, pool := range c.seen {
+		if _, exists := current[name]; exists {
+			continue
+		}
+
+		c.metrics.ZFSDatasetUsedGauge.DeleteLabelValues(name, pool)
+		c.metrics.ZFSDatasetAvailableGauge.DeleteLabelValues(name, pool)
+		c.metrics.ZFSDatasetReferencedGauge.DeleteLabelValues(name, pool)
+		c.metrics.ZFSDatasetCompressRatioGauge.DeleteLabelValues(name, pool)
+	}
+
+	c.seen = current
+}
+
+// listDatasets runs zfs list and parses its output
+func (c *Collector) listDatasets(ctx context.Context) ([]Dataset, error) {
+	timeout := c.config.GetZFSTimeout()
+
+	ctx, span := c.startSpan(ctx, "command.zfs_list", trace.WithAttributes(
+		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"list", "-H", "-p", "-t", "filesystem,volume", "-o", strings.Join(listProperties, ",")}
+	args = append(args, c.config.ZFS.Datasets...)
+
+	execStart := time.Now()
+	cmd := utils.CommandContext(timeoutCtx, "zfs", args...)
+	output, err := cmd.Output()
+	execDuration := time.Since(execStart)
+	audit.Command(ctx, cmd, "", execStart, len(output), err)
+	c.metrics.CommandDurationHist.WithLabelValues("zfs", "zfs", "zfs").Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			return nil, fmt.Errorf("zfs list timed out after %s", timeout)
+		}
+
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("zfs list failed: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return nil, fmt.Errorf("zfs list failed: %w", err)
+	}
+
+	return ParseList(output)
+}
+
+// ParseList parses the tab separated output of
+// `zfs list -H -p -o name,used,avail,refer,compressratio`
+func ParseList(output []byte) ([]Dataset, error) {
+	var datasets []Dataset
+
+	for i, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != len(listProperties) {
+			return nil, fmt.Errorf("line %d: expected %d fields, got %d", i+1, len(listProperties), len(fields))
+		}
+
+		dataset := Dataset{Name: fields[0]}
+
+		for j, target := range []*int64{&dataset.UsedBytes, &dataset.AvailableBytes, &dataset.ReferencedBytes} {
+			value, err := utils.ParseLocalizedInt(fields[j+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s value '%s': %w", i+1, listProperties[j+1], fields[j+1], err)
+			}
+
+			*target = value
+		}
+
+		// Older releases print the ratio with a trailing "x" even with -p
+		ratio, err := utils.ParseLocalizedFloat(strings.TrimSuffix(fields[4], "x"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid compressratio value '%s': %w", i+1, fields[4], err)
+		}
+
+		dataset.CompressRatio = ratio
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, nil
+}
+
+// startSpan is a helper to start an OTEL span
+func (c *Collector) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c.tracer != nil && c.tracer.IsEnabled() {
+		return c.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return ctx, trace.SpanFromContext(ctx)
+}
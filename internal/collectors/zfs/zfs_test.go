@@ -0,0 +1,56 @@
+package zfs
+
+import "testing"
+
+func TestParseList(t *testing.T) {
+	output := []byte("tank\t1099511627776\t2199023255552\t98304\t1.52\n" +
+		"tank/home\t536870912000\t2199023255552\t536870912000\t1.00x\n\n")
+
+	datasets, err := ParseList(output)
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+
+	if len(datasets) != 2 {
+		t.Fatalf("expected 2 datasets, got %d", len(datasets))
+	}
+
+	home := datasets[1]
+	if home.Name != "tank/home" || home.Pool() != "tank" {
+		t.Errorf("unexpected name/pool %s/%s", home.Name, home.Pool())
+	}
+
+	if home.UsedBytes != 536870912000 || home.AvailableBytes != 2199023255552 || home.ReferencedBytes != 536870912000 {
+		t.Errorf("unexpected sizes: %+v", home)
+	}
+
+	if datasets[0].CompressRatio != 1.52 || home.CompressRatio != 1 {
+		t.Errorf("unexpected compress ratios %g, %g", datasets[0].CompressRatio, home.CompressRatio)
+	}
+}
+
+func TestParseList_Invalid(t *testing.T) {
+	for _, output := range []string{
+		"tank\t1\t2\n",
+		"tank\tone\t2\t3\t1.00\n",
+		"tank\t1\t2\t3\tfast\n",
+	} {
+		if _, err := ParseList([]byte(output)); err == nil {
+			t.Errorf("expected error parsing %q", output)
+		}
+	}
+}
+
+func TestParseList_Localized(t *testing.T) {
+	// Output from a de_DE appliance that ignores -p for grouping
+	output := []byte("tank/data\t1.099.511.627.776\t2.199.023.255.552\t98.304\t1,52x\n")
+
+	datasets, err := ParseList(output)
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+
+	if datasets[0].UsedBytes != 1099511627776 || datasets[0].ReferencedBytes != 98304 || datasets[0].CompressRatio != 1.52 {
+		t.Errorf("unexpected dataset: %+v", datasets[0])
+	}
+}
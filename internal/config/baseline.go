@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BaselineConfig points at a file of expected directory sizes, e.g. the
+// sizes measured on the source of a migration
+type BaselineConfig struct {
+	File string `yaml:"file"` // CSV (group,path,expected) or YAML list of the same fields
+}
+
+// BaselineEntry is one expected size. An empty path means the group's root.
+type BaselineEntry struct {
+	Group    string   `yaml:"group"`
+	Path     string   `yaml:"path"`
+	Expected ByteSize `yaml:"expected"`
+}
+
+// loadBaseline reads baseline.file and merges it with the expected_size of
+// each group, keyed by group and cleaned path
+func (c *Config) loadBaseline() error {
+	c.expectedSizes = make(map[string]map[string]int64)
+
+	var entries []BaselineEntry
+
+	if c.Baseline.File != "" {
+		var err error
+
+		entries, err = readBaselineFile(c.Baseline.File)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, group := range c.Directories {
+		if group.ExpectedSize > 0 {
+			entries = append(entries, BaselineEntry{Group: name, Expected: group.ExpectedSize})
+		}
+	}
+
+	for i, entry := range entries {
+		group, exists := c.Directories[entry.Group]
+		if !exists {
+			return fmt.Errorf("entry %d references unknown directory group '%s'", i+1, entry.Group)
+		}
+
+		if entry.Expected < 0 {
+			return fmt.Errorf("entry %d for group '%s' has a negative expected size", i+1, entry.Group)
+		}
+
+		path := group.Path
+		if entry.Path != "" {
+			path = filepath.Clean(entry.Path)
+		}
+
+		// Series are labelled with the path scanned, which differs from the
+		// configured one when it goes through a symlink
+		if rel, err := filepath.Rel(group.Path, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = filepath.Join(c.GetDirectoryPath(group), rel)
+		}
+
+		if c.expectedSizes[entry.Group] == nil {
+			c.expectedSizes[entry.Group] = make(map[string]int64)
+		}
+
+		c.expectedSizes[entry.Group][path] = entry.Expected.Bytes()
+	}
+
+	return nil
+}
+
+// readBaselineFile parses a CSV or YAML baseline, chosen by file extension
+func readBaselineFile(path string) ([]BaselineEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var entries []BaselineEntry
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		return entries, nil
+	case ".csv":
+		return parseBaselineCSV(strings.NewReader(string(data)))
+	default:
+		return nil, fmt.Errorf("unsupported baseline file %s (must be .csv, .yaml or .yml)", path)
+	}
+}
+
+// parseBaselineCSV reads group,path,expected rows. Expected sizes may use
+// units like "1.2TiB", and a header row is skipped.
+func parseBaselineCSV(r io.Reader) ([]BaselineEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var entries []BaselineEntry
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(entries) == 0 && strings.EqualFold(record[0], "group") {
+			continue
+		}
+
+		expected, err := ParseByteSize(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		entries = append(entries, BaselineEntry{Group: record[0], Path: record[1], Expected: expected})
+	}
+}
+
+// GetExpectedSize returns the baseline size of a directory series, if any
+func (c *Config) GetExpectedSize(groupName, path string) (int64, bool) {
+	expected, exists := c.expectedSizes[groupName][filepath.Clean(path)]
+
+	return expected, exists
+}
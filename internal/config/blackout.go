@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlackoutWindow is a recurring local time range, such as "Mon-Fri
+// 08:00-18:00", during which a directory group isn't collected
+type BlackoutWindow struct {
+	days  [7]bool // Indexed by time.Weekday, the day each window starts on
+	start int     // Minutes after midnight
+	end   int     // Minutes after midnight; before start when crossing midnight
+}
+
+// ParseBlackoutWindow parses a window of an optional day or day range
+// ("Mon", "Mon-Fri", "Fri-Mon" or a comma separated list of either) and a
+// time range in 24-hour local time. A time range ending before it starts,
+// like "22:00-02:00", runs past midnight into the next day.
+func ParseBlackoutWindow(window string) (BlackoutWindow, error) {
+	var w BlackoutWindow
+
+	fields := strings.Fields(window)
+
+	switch len(fields) {
+	case 1:
+		for day := range w.days {
+			w.days[day] = true
+		}
+	case 2:
+		if err := w.parseDays(fields[0]); err != nil {
+			return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': %w", window, err)
+		}
+	default:
+		return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': expected e.g. \"Mon-Fri 08:00-18:00\"", window)
+	}
+
+	startStr, endStr, found := strings.Cut(fields[len(fields)-1], "-")
+	if !found {
+		return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': expected a time range like 08:00-18:00", window)
+	}
+
+	var err error
+
+	if w.start, err = parseClock(startStr); err != nil {
+		return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': %w", window, err)
+	}
+
+	if w.end, err = parseClock(endStr); err != nil {
+		return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': %w", window, err)
+	}
+
+	if w.start == w.end {
+		return BlackoutWindow{}, fmt.Errorf("invalid blackout window '%s': start and end are the same", window)
+	}
+
+	return w, nil
+}
+
+// parseDays marks the days of a comma separated list of days and day ranges
+func (w *BlackoutWindow) parseDays(days string) error {
+	for _, part := range strings.Split(days, ",") {
+		firstStr, lastStr, isRange := strings.Cut(part, "-")
+
+		first, err := parseWeekday(firstStr)
+		if err != nil {
+			return err
+		}
+
+		last := first
+		if isRange {
+			if last, err = parseWeekday(lastStr); err != nil {
+				return err
+			}
+		}
+
+		// Ranges may wrap around the week, e.g. Fri-Mon
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Contains reports whether t falls inside the window, in t's location
+func (w BlackoutWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if w.start < w.end {
+		return w.days[day] && minute >= w.start && minute < w.end
+	}
+
+	// Crossing midnight: the evening of a window day or the early hours of
+	// the day after one
+	return (w.days[day] && minute >= w.start) || (w.days[(day+6)%7] && minute < w.end)
+}
+
+// parseWeekday parses a day name, in full or abbreviated to at least three
+// letters
+func parseWeekday(name string) (time.Weekday, error) {
+	if len(name) >= 3 {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.HasPrefix(strings.ToLower(day.String()), strings.ToLower(name)) {
+				return day, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("invalid day '%s'", name)
+}
+
+// parseClock parses HH:MM into minutes after midnight. 24:00 is allowed as
+// the end of the day.
+func parseClock(clock string) (int, error) {
+	hourStr, minuteStr, found := strings.Cut(clock, ":")
+
+	hour, hourErr := strconv.Atoi(hourStr)
+	minute, minuteErr := strconv.Atoi(minuteStr)
+
+	if !found || hourErr != nil || minuteErr != nil || hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute > 0) {
+		return 0, fmt.Errorf("invalid time '%s' (must be HH:MM)", clock)
+	}
+
+	return hour*60 + minute, nil
+}
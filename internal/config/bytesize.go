@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes that can be written in YAML either as an integer
+// or as a string with a unit, e.g. "500GiB", "1.5TB" or "200G"
+type ByteSize int64
+
+// byteUnits maps unit suffixes to multipliers. SI units (KB, MB, ...) are
+// powers of 1000, IEC units (KiB, MiB, ...) and bare letters (K, M, ...) are
+// powers of 1024, matching du/df conventions.
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"K":   1 << 10,
+	"M":   1 << 20,
+	"G":   1 << 30,
+	"T":   1 << 40,
+	"P":   1 << 50,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+	"PIB": 1 << 50,
+}
+
+// ParseByteSize parses a human readable size such as "500GiB" into bytes
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split == -1 {
+		split = len(s)
+	}
+
+	number, unit := s[:split], strings.ToUpper(strings.TrimSpace(s[split:]))
+
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit '%s' in '%s'", unit, s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %w", s, err)
+	}
+
+	bytes := value * multiplier
+	if bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("size '%s' is too large", s)
+	}
+
+	return ByteSize(bytes), nil
+}
+
+// UnmarshalYAML implements custom unmarshaling for sizes with units
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case int:
+		*b = ByteSize(v)
+	case int64:
+		*b = ByteSize(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return fmt.Errorf("size %d is too large", v)
+		}
+
+		*b = ByteSize(v)
+	case float64:
+		*b = ByteSize(v)
+	case string:
+		parsed, err := ParseByteSize(v)
+		if err != nil {
+			return err
+		}
+
+		*b = parsed
+	default:
+		return fmt.Errorf("size must be an integer number of bytes or a string such as '500GiB'")
+	}
+
+	return nil
+}
+
+// Bytes returns the size as an int64
+func (b ByteSize) Bytes() int64 {
+	return int64(b)
+}
+
+// String formats the size with the largest IEC unit that keeps it at least 1,
+// e.g. "1.5GiB"
+func (b ByteSize) String() string {
+	const unit = 1024
+
+	if b < unit && b > -unit {
+		return fmt.Sprintf("%dB", int64(b))
+	}
+
+	value := float64(b)
+	exp := 0
+
+	for math.Abs(value) >= unit*unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", value/unit, "KMGTP"[exp])
+}
@@ -5,10 +5,16 @@ import (
 	"fmt"
 	"html/template"
 	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	promexporter_config "github.com/d0ugal/promexporter/config"
@@ -18,299 +24,2484 @@ import (
 //go:embed templates/*.html
 var templateFiles embed.FS
 
+// commandsAvailable reports whether the df, du, zfs and btrfs commands can
+// exist on this platform. Windows has none of them, so only the native
+// statfs and walk modes are offered there.
+const commandsAvailable = runtime.GOOS != "windows"
+
 // Duration uses promexporter Duration type
 type Duration = promexporter_config.Duration
 
-type Config struct {
-	promexporter_config.BaseConfig
+type Config struct {
+	promexporter_config.BaseConfig `yaml:",inline"`
+
+	Filesystems []FilesystemConfig        `yaml:"filesystems"`
+	Directories map[string]DirectoryGroup `yaml:"directories"`
+
+	// Settings every directory group in the config files starts from
+	Defaults   DirectoryGroup   `yaml:"defaults" env:"-"`
+	API        APIConfig        `yaml:"api"`
+	Security   SecurityConfig   `yaml:"security"`
+	Signing    SigningConfig    `yaml:"signing"`
+	ZFS        ZFSConfig        `yaml:"zfs"`
+	Btrfs      BtrfsConfig      `yaml:"btrfs"`
+	Quotas     QuotasConfig     `yaml:"quotas"`
+	MountProbe MountProbeConfig `yaml:"mount_probe"`
+	Buckets    BucketsConfig    `yaml:"buckets"`
+	Docker     DockerConfig     `yaml:"docker"`
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+
+	CommandLimits CommandLimitsConfig `yaml:"command_limits"`
+	Digest        DigestConfig        `yaml:"digest"`
+	Baseline      BaselineConfig      `yaml:"baseline"`
+	Pushgateway   PushgatewayConfig   `yaml:"pushgateway"`
+	Alerts        AlertsConfig        `yaml:"alerts"`
+	Probe         ProbeConfig         `yaml:"probe"`
+	GroupMetrics  GroupMetricsConfig  `yaml:"group_metrics"`
+	Health        HealthConfig        `yaml:"health"`
+
+	AdaptiveInterval  AdaptiveIntervalConfig  `yaml:"adaptive_interval"`
+	Retry             RetryConfig             `yaml:"retry"` // Defaults for every filesystem and directory group
+	DeviceConcurrency DeviceConcurrencyConfig `yaml:"device_concurrency"`
+	DfBatch           DfBatchConfig           `yaml:"df_batch"`
+	CollectionMode    string                  `yaml:"collection_mode"` // Default collection mode of filesystems: "scheduled" (default) or "on_scrape"
+	ScrapeCollection  ScrapeCollectionConfig  `yaml:"scrape_collection"`
+	Growth            GrowthConfig            `yaml:"growth"`
+	Queue             QueueConfig             `yaml:"queue"`
+	Shutdown          ShutdownConfig          `yaml:"shutdown"`
+	Signals           SignalsConfig           `yaml:"signals"`
+	CPU               CPUConfig               `yaml:"cpu"`
+
+	DirectoryTimestamps DirectoryTimestampsConfig `yaml:"directory_timestamps"`
+
+	// Glob patterns of files whose filesystems and directories are merged in,
+	// e.g. conf.d/*.yaml
+	Include []string `yaml:"include"`
+
+	// server.tls, server.auth, server.listen, server.socket_mode and the
+	// server's timeouts, read separately since server belongs to BaseConfig
+	TLS            TLSConfig          `yaml:"-" env:"server_tls"`
+	Auth           AuthConfig         `yaml:"-" env:"server_auth"`
+	Listen         string             `yaml:"-" env:"server_listen"`      // unix:///path/to.sock instead of host and port
+	SocketMode     string             `yaml:"-" env:"server_socket_mode"` // Octal permissions of the socket (default: 0660)
+	ServerTimeouts HTTPTimeoutsConfig `yaml:"-" env:"server"`             // server.read_timeout, server.shutdown_grace and so on
+
+	// metrics.collection.apply_default_interval, read separately since
+	// metrics belongs to BaseConfig. Items without an interval inherit
+	// metrics.collection.default_interval instead of failing validation.
+	ApplyDefaultInterval bool `yaml:"-" env:"metrics_collection_apply_default_interval"`
+
+	// logging.levels and logging.rate_limit, read separately since logging
+	// belongs to BaseConfig
+	LogLevels    map[string]string  `yaml:"-" env:"logging_levels"` // Levels by component, such as scheduler or collectors/zfs
+	LogRateLimit LogRateLimitConfig `yaml:"-" env:"logging_rate_limit"`
+
+	// tracing.sample_ratio and tracing.scrub_paths, read separately since
+	// tracing belongs to BaseConfig
+	TracingSampleRatio float64 `yaml:"-" env:"tracing_sample_ratio"` // Share of traces kept, 0-1 (default: 1)
+	TracingScrubPaths  string  `yaml:"-" env:"tracing_scrub_paths"`  // "hash" or "drop" paths in spans before export (default: kept)
+
+	// Expected sizes by group and path, from baseline.file and expected_size
+	expectedSizes map[string]map[string]int64
+
+	// Directory groups added through the API, as given, and the custom label
+	// names the metrics were registered with, which they must keep within
+	runtimeDirectories map[string]yaml.Node
+	labelNames         []string
+
+	// directoriesMu guards Directories while the exporter runs, since the
+	// API can change directory groups. The map is replaced rather than
+	// changed, so one returned by DirectoryGroups stays consistent.
+	directoriesMu sync.RWMutex
+
+	// updatesMu keeps changes to directory groups, and their overlay file,
+	// one at a time
+	updatesMu sync.Mutex
+}
+
+// What tracing.scrub_paths does with paths in spans
+const (
+	TracingScrubHash = "hash" // Replace each path with a hash of it
+	TracingScrubDrop = "drop" // Remove attributes holding a path, and redact paths in other values
+)
+
+// What a full job queue does with a new job
+const (
+	QueueOverflowBlock      = "block"       // Wait for room (default)
+	QueueOverflowDropNewest = "drop_newest" // Drop the new job
+	QueueOverflowDropOldest = "drop_oldest" // Drop the job that has waited longest
+	QueueOverflowCoalesce   = "coalesce"    // Drop the new job if one for the same item is waiting, otherwise wait
+)
+
+// Digest schedules
+const (
+	DigestScheduleDaily  = "daily"
+	DigestScheduleWeekly = "weekly"
+)
+
+// SMTP connection security modes
+const (
+	SMTPTLSStartTLS = "starttls" // Upgrade a plain connection with STARTTLS
+	SMTPTLSImplicit = "tls"      // Connect over TLS from the start (usually port 465)
+	SMTPTLSNone     = "none"     // Plain text, only sensible for a local relay
+)
+
+// DigestConfig configures the periodic capacity report sent by email, for
+// installations without dashboards
+type DigestConfig struct {
+	Enabled  bool       `yaml:"enabled"`
+	Schedule string     `yaml:"schedule"` // "daily" (default) or "weekly"
+	Time     string     `yaml:"time"`     // Local time of day to send at, as HH:MM (default: 08:00)
+	Weekday  string     `yaml:"weekday"`  // Day weekly digests are sent on (default: monday)
+	TopN     int        `yaml:"top_n"`    // Rows in the fullest volumes and biggest growers tables (default: 5)
+	Subject  string     `yaml:"subject"`  // Default: "Filesystem capacity report for <hostname>"
+	SMTP     SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig is the mail server digests are sent through
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`     // Default: 587, or 465 with tls: tls
+	TLS      string   `yaml:"tls"`      // "starttls" (default), "tls" or "none"
+	Username string   `yaml:"username"` // Empty to send without authentication
+	Password string   `yaml:"password"` // Default: SMTP_PASSWORD
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// Webhook payload formats
+const (
+	WebhookTypeGeneric = "generic" // JSON object describing the alert
+	WebhookTypeSlack   = "slack"   // Slack incoming webhook message
+	WebhookTypeNtfy    = "ntfy"    // Plain text message to an ntfy topic URL
+)
+
+// AlertsConfig configures built-in threshold alerts sent to webhooks, for
+// deployments without Alertmanager
+type AlertsConfig struct {
+	Enabled        bool            `yaml:"enabled"`
+	RepeatInterval Duration        `yaml:"repeat_interval"` // How often a still-firing alert is re-sent (default: 4h)
+	Rules          []AlertRule     `yaml:"rules"`
+	Webhooks       []WebhookConfig `yaml:"webhooks"`
+}
+
+// AlertRule is one condition evaluated after each collection. Exactly one of
+// used_ratio_above, size_above and failures_at_least must be set.
+type AlertRule struct {
+	Name            string   `yaml:"name"`
+	Filesystem      string   `yaml:"filesystem"`        // Only evaluate this filesystem (default: all)
+	Directory       string   `yaml:"directory"`         // Only evaluate this directory group (default: all)
+	UsedRatioAbove  float64  `yaml:"used_ratio_above"`  // Filesystem used ratio, 0-1
+	SizeAbove       ByteSize `yaml:"size_above"`        // Directory group total size, e.g. "2TiB"
+	FailuresAtLeast int      `yaml:"failures_at_least"` // Consecutive failed collections of any item
+	For             int      `yaml:"for"`               // Consecutive breaching collections before firing (default: 1)
+	Webhooks        []string `yaml:"webhooks"`          // Names of the webhooks to notify (default: all)
+}
+
+// WebhookConfig is an endpoint alert notifications are posted to
+type WebhookConfig struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`    // "generic" (default), "slack" or "ntfy"
+	URL     string            `yaml:"url"`     // For ntfy, the topic URL, e.g. https://ntfy.sh/my-disks
+	Headers map[string]string `yaml:"headers"` // Extra request headers, e.g. Authorization
+	Timeout Duration          `yaml:"timeout"` // Default: 10s
+}
+
+// TLSConfig serves the HTTP server and API over HTTPS. The files are
+// re-read when they change, so renewed certificates need no restart.
+type TLSConfig struct {
+	CertFile       string   `yaml:"cert_file"`
+	KeyFile        string   `yaml:"key_file"`
+	ClientCAFile   string   `yaml:"client_ca_file"`  // Require client certificates signed by this CA (mTLS)
+	ReloadInterval Duration `yaml:"reload_interval"` // How often the files are checked for changes (default: 30s)
+}
+
+// IsEnabled reports whether server.tls is configured
+func (t TLSConfig) IsEnabled() bool {
+	return t.CertFile != ""
+}
+
+// AuthConfig requires credentials for the HTTP server and API, in the style
+// of the Prometheus web config file. /health stays open for probes.
+type AuthConfig struct {
+	BasicAuthUsers     map[string]string `yaml:"basic_auth_users"`      // Username to bcrypt hash
+	BasicAuthUsersFile string            `yaml:"basic_auth_users_file"` // htpasswd-style username:bcrypt-hash lines
+	BearerToken        string            `yaml:"bearer_token"`          // Default: FILESYSTEM_EXPORTER_BEARER_TOKEN
+	BearerTokenFile    string            `yaml:"bearer_token_file"`
+}
+
+// IsEnabled reports whether any credentials are configured
+func (a AuthConfig) IsEnabled() bool {
+	return len(a.BasicAuthUsers) > 0 || a.BasicAuthUsersFile != "" || a.BearerToken != "" || a.BearerTokenFile != ""
+}
+
+// Probe modules
+const (
+	ProbeModuleDirectory  = "directory"  // Walk the target and report its size
+	ProbeModuleFilesystem = "filesystem" // statfs the filesystem containing the target
+)
+
+// GroupMetricsConfig enables GET /metrics/group/{name}, which serves only the
+// series of one directory group or filesystem
+type GroupMetricsConfig struct {
+	Enabled bool                  `yaml:"enabled"`
+	Auth    map[string]AuthConfig `yaml:"auth" env:"-"` // Credentials accepted by an item's endpoint besides server.auth's, by item name
+}
+
+// ProbeConfig enables GET /probe, which measures an ad-hoc path per scrape in
+// the style of the snmp and blackbox exporters
+type ProbeConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	AllowedPaths  []string `yaml:"allowed_paths"`  // Targets must be one of these or below them
+	Timeout       Duration `yaml:"timeout"`        // Upper bound per probe, lowered to the scrape timeout (default: 30s)
+	MaxConcurrent int      `yaml:"max_concurrent"` // Probes run at once; more wait their turn (default: 2)
+}
+
+// HealthConfig makes /health reflect how the items' collections are going
+// and adds /ready, which passes once every item has been collected
+type HealthConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	FailureThreshold int     `yaml:"failure_threshold"`  // Consecutive failed collections that make an item unhealthy (default: 3)
+	StaleIntervals   float64 `yaml:"stale_intervals"`    // Intervals without a successful collection that make an item unhealthy (default: 2)
+	FailWhenDegraded bool    `yaml:"fail_when_degraded"` // Answer 503 rather than 200 while any item is unhealthy
+}
+
+// AdaptiveIntervalConfig stretches the interval of items whose collections
+// take up too much of it, and shrinks it back as they speed up
+type AdaptiveIntervalConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	Threshold     float64 `yaml:"threshold"`      // Share of the interval a collection may take (default: 0.5)
+	MaxMultiplier float64 `yaml:"max_multiplier"` // Cap on the stretched interval, as a multiple of the configured one (default: 4)
+}
+
+// GrowthConfig estimates how fast volumes and directory groups grow, and
+// when volumes will fill, by linear regression over their recent sizes
+type GrowthConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Window     Duration `yaml:"window"`      // How far back the regression looks (default: 24h)
+	MinSamples int      `yaml:"min_samples"` // Collections needed in the window before estimating (default: 3)
+	StateFile  string   `yaml:"state_file"`  // File the history is saved to, so it survives restarts (default: memory only)
+}
+
+// DirectoryTimestampsConfig exposes directory size samples with the time they
+// were measured, so Prometheus records that instead of the scrape time. Off
+// by default since Prometheus drops samples older than its head block and
+// doesn't mark timestamped series stale.
+type DirectoryTimestampsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// DfBatchConfig runs one df for all the df mode filesystems sharing an
+// interval, rather than one per filesystem
+type DfBatchConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// ScrapeCollectionConfig bounds the collections of on_scrape filesystems
+// run while /metrics is served
+type ScrapeCollectionConfig struct {
+	Budget   Duration `yaml:"budget"`    // Longest a scrape waits for its collections (default: 5s)
+	CacheTTL Duration `yaml:"cache_ttl"` // Collections newer than this aren't repeated for a scrape (default: 10s)
+}
+
+// DeviceConcurrencyConfig runs directory collections on different devices in
+// parallel while those sharing a device still run one at a time, so a single
+// disk isn't thrashed by competing scans
+type DeviceConcurrencyConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	MaxWorkers int  `yaml:"max_workers"` // Directory collections run at once across all devices (default: 4)
+}
+
+// QueueConfig sizes the filesystem and directory job queues and says what
+// happens when one is full, e.g. while a hung mount holds up its worker
+type QueueConfig struct {
+	Size           int    `yaml:"size"`            // Jobs each queue holds (default: 100)
+	OverflowPolicy string `yaml:"overflow_policy"` // "block" (default), "drop_newest", "drop_oldest" or "coalesce"
+}
+
+// CPUConfig keeps scan work to part of the host's CPUs, so a big walk on a
+// shared machine doesn't take every core
+type CPUConfig struct {
+	Affinity   string `yaml:"affinity"`   // CPUs collection jobs and their commands run on, e.g. "0-3,6" (Linux only)
+	GOMAXPROCS int    `yaml:"gomaxprocs"` // Cap on the threads running Go code at once (0 leaves Go's default)
+}
+
+// AffinityCPUs returns the CPUs of cpu.affinity, which validation has
+// already checked, or nil when it isn't set
+func (c CPUConfig) AffinityCPUs() []int {
+	if c.Affinity == "" {
+		return nil
+	}
+
+	cpus, _ := ParseCPUList(c.Affinity)
+
+	return cpus
+}
+
+// LogRateLimitConfig limits how often the same message is logged
+type LogRateLimitConfig struct {
+	Burst    int      `yaml:"burst"`    // Times a message may be logged each interval (default: 0, unlimited)
+	Interval Duration `yaml:"interval"` // Default: 1m
+}
+
+// ShutdownConfig controls what happens to collections in progress on SIGTERM
+type ShutdownConfig struct {
+	DrainTimeout Duration `yaml:"drain_timeout"` // How long running collections may finish before they're aborted (default: 15s)
+}
+
+// SignalsConfig controls the diagnostics SIGUSR1 dumps
+type SignalsConfig struct {
+	DumpFile string `yaml:"dump_file"` // File the diagnostics are written to as JSON (default: the log)
+}
+
+// PushgatewayConfig is where one-shot runs (--once) push their results, for
+// scans run from cron rather than scraped
+type PushgatewayConfig struct {
+	URL      string            `yaml:"url"`      // e.g. http://pushgateway:9091
+	Job      string            `yaml:"job"`      // Default: filesystem_exporter
+	Grouping map[string]string `yaml:"grouping"` // Extra grouping labels (default: instance=<hostname>)
+	Username string            `yaml:"username"` // Empty to push without basic auth
+	Password string            `yaml:"password"` // Default: PUSHGATEWAY_PASSWORD
+	Timeout  Duration          `yaml:"timeout"`  // Default: 30s
+}
+
+// CommandLimitsConfig caps the resources of spawned df and du processes so a
+// pathological scan cannot exhaust the host. Zero values disable a limit.
+type CommandLimitsConfig struct {
+	CPUSeconds   uint64   `yaml:"cpu_seconds"`    // RLIMIT_CPU per command
+	MaxOpenFiles uint64   `yaml:"max_open_files"` // RLIMIT_NOFILE per command
+	Memory       ByteSize `yaml:"memory"`         // memory.max of the cgroup commands run in, e.g. "512MiB"
+	CgroupPath   string   `yaml:"cgroup_path"`    // cgroup v2 directory for commands, required with memory
+	IOCgroupPath string   `yaml:"io_cgroup_path"` // cgroup v2 directory with the io controller delegated, required with a group's io_limit on read bandwidth or IOPS
+
+	// How long a timed out command's process group has to exit after SIGTERM
+	// before it's sent SIGKILL (default: 5s)
+	KillGrace Duration `yaml:"kill_grace"`
+}
+
+// IsEnabled returns true if any command limit is configured
+func (l CommandLimitsConfig) IsEnabled() bool {
+	return l.CPUSeconds > 0 || l.MaxOpenFiles > 0 || l.Memory > 0 || l.IOCgroupPath != ""
+}
+
+// IOLimitConfig bounds the I/O of a directory group's scans. Zero values
+// disable a limit.
+type IOLimitConfig struct {
+	FilesPerSecond     int      `yaml:"files_per_second"`      // Files and directories stat'ed per second by native walks
+	ReadBytesPerSecond ByteSize `yaml:"read_bytes_per_second"` // io.max rbps of du and exec scanners, e.g. "20MiB"
+	ReadIOPS           int      `yaml:"read_iops"`             // io.max riops of du and exec scanners
+}
+
+// LimitsCommands reports whether the limit applies to scanner commands,
+// which then run in a cgroup of their own
+func (l IOLimitConfig) LimitsCommands() bool {
+	return l.ReadBytesPerSecond > 0 || l.ReadIOPS > 0
+}
+
+// KubernetesConfig configures discovery of PersistentVolume mounts under the
+// kubelet's pods directory, for running as a DaemonSet
+type KubernetesConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Interval     Duration `yaml:"interval"`      // Collection interval (default: metrics default_interval)
+	PodsDir      string   `yaml:"pods_dir"`      // Kubelet pods directory (default: /var/lib/kubelet/pods)
+	ResolveNames bool     `yaml:"resolve_names"` // Look up pod and PVC names from the API server using the in-cluster service account
+	NodeName     string   `yaml:"node_name"`     // Node whose pods are looked up (default: NODE_NAME environment variable)
+}
+
+// DockerConfig configures the Docker collector, which attributes disk usage to
+// images, containers and named volumes via the Engine API's /system/df
+type DockerConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Interval Duration `yaml:"interval"` // Collection interval (default: metrics default_interval)
+	Timeout  Duration `yaml:"timeout"`  // Request timeout (default: 10% of interval)
+	Socket   string   `yaml:"socket"`   // Docker Engine API socket (default: /var/run/docker.sock)
+}
+
+// BucketsConfig configures the object storage collector, which sizes buckets
+// on S3-compatible endpoints by listing their objects
+type BucketsConfig struct {
+	Enabled   bool             `yaml:"enabled"`
+	Interval  Duration         `yaml:"interval"` // Collection interval (default: metrics default_interval)
+	Timeout   Duration         `yaml:"timeout"`  // Timeout for listing one bucket (default: 10% of interval)
+	Endpoints []BucketEndpoint `yaml:"endpoints"`
+}
+
+// BucketEndpoint is an S3-compatible endpoint and the buckets to size on it
+type BucketEndpoint struct {
+	Name             string   `yaml:"name"`               // Exported as the endpoint label
+	URL              string   `yaml:"url"`                // e.g. "https://s3.eu-west-1.amazonaws.com" or "http://minio:9000"
+	Region           string   `yaml:"region"`             // Signing region (default: us-east-1)
+	AccessKeyID      string   `yaml:"access_key_id"`      // Default: AWS_ACCESS_KEY_ID; empty for anonymous access
+	SecretAccessKey  string   `yaml:"secret_access_key"`  // Default: AWS_SECRET_ACCESS_KEY
+	VirtualHostStyle bool     `yaml:"virtual_host_style"` // Address buckets as <bucket>.<host> instead of <host>/<bucket>
+	Buckets          []string `yaml:"buckets"`
+}
+
+// DefaultMountProbeFSTypes are the filesystem types probed when
+// mount_probe.fs_types is not set
+var DefaultMountProbeFSTypes = []string{"nfs", "nfs4", "cifs", "smb3"}
+
+// MountProbeConfig configures the network mount health probe, which runs
+// statfs with a short deadline so an unresponsive server is detected without
+// blocking the df path
+type MountProbeConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Interval Duration `yaml:"interval"` // Probe interval (default: 30s)
+	Timeout  Duration `yaml:"timeout"`  // Deadline for each statfs (default: 5s)
+	FSTypes  []string `yaml:"fs_types"` // Mount types treated as network mounts (default: nfs, nfs4, cifs, smb3)
+}
+
+// Quota types that can be collected from a filesystem
+const (
+	QuotaTypeUser  = "user"
+	QuotaTypeGroup = "group"
+)
+
+// QuotasConfig configures the user and group quota collector, which reads
+// usage and limits with the quotactl syscall
+type QuotasConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Interval    Duration          `yaml:"interval"` // Collection interval (default: metrics default_interval)
+	Filesystems []QuotaFilesystem `yaml:"filesystems"`
+}
+
+// QuotaFilesystem is a filesystem with quotas enabled
+type QuotaFilesystem struct {
+	Name       string   `yaml:"name"`        // Exported as the volume label
+	MountPoint string   `yaml:"mount_point"` // Mount point of the filesystem
+	Device     string   `yaml:"device"`      // Block device (default: looked up from the mount table)
+	Types      []string `yaml:"types"`       // "user" and/or "group" (default: both)
+}
+
+// ZFSConfig configures the ZFS dataset collector, which reports each dataset
+// individually since df numbers on pools are misleading
+type ZFSConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Interval Duration `yaml:"interval"` // Collection interval (default: metrics default_interval)
+	Timeout  Duration `yaml:"timeout"`  // zfs list timeout (default: 10% of interval)
+	Datasets []string `yaml:"datasets"` // Datasets to report (default: all)
+}
+
+// BtrfsConfig configures the btrfs collector, which reports qgroup and
+// allocation usage since du overcounts extents shared between snapshots
+type BtrfsConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Interval    Duration `yaml:"interval"`     // Collection interval (default: metrics default_interval)
+	Timeout     Duration `yaml:"timeout"`      // Per-command timeout (default: 10% of interval)
+	MountPoints []string `yaml:"mount_points"` // Mount points of the btrfs filesystems to report
+}
+
+// SigningConfig configures Ed25519 signing of JSON scan reports
+type SigningConfig struct {
+	PrivateKeyFile string `yaml:"private_key_file"` // PEM encoded PKCS#8 Ed25519 key; empty disables signing
+	KeyID          string `yaml:"key_id"`           // Identifier included in signed reports (default: public key fingerprint)
+}
+
+// IsEnabled returns true if report signing is configured
+func (s *SigningConfig) IsEnabled() bool {
+	return s.PrivateKeyFile != ""
+}
+
+// SecurityConfig restricts what the exporter is allowed to do on the host
+type SecurityConfig struct {
+	// NoExec refuses to run any external commands. Filesystems default to
+	// statfs and directories to walk mode, and configs that explicitly
+	// require df/du fail validation.
+	NoExec bool `yaml:"no_exec"`
+
+	// AuditLog records every external command that is run
+	AuditLog AuditLogConfig `yaml:"audit_log"`
+
+	// The user and group df, du and exec scanners run as, each by name or
+	// ID, instead of the exporter's own. The group defaults to the user's
+	// primary group.
+	RunAsUser  string `yaml:"run_as_user"`
+	RunAsGroup string `yaml:"run_as_group"`
+
+	// AllowRoot lets the exporter itself run as root, which it otherwise
+	// refuses to
+	AllowRoot bool `yaml:"allow_root"`
+
+	// AllowedRoots, when set, are the only paths directory groups may scan,
+	// along with everything below them
+	AllowedRoots []string `yaml:"allowed_roots"`
+}
+
+// AuditLogConfig is where the JSON lines audit log of external commands goes
+type AuditLogConfig struct {
+	File   string `yaml:"file"`   // Appended to, created with mode 0600 if missing
+	Syslog bool   `yaml:"syslog"` // Also send each line to the local syslog (not on Windows)
+}
+
+// IsEnabled reports whether the audit log has a destination
+func (a AuditLogConfig) IsEnabled() bool {
+	return a.File != "" || a.Syslog
+}
+
+// Collection modes for filesystems
+const (
+	FilesystemModeDf     = "df"
+	FilesystemModeStatfs = "statfs"
+)
+
+// When filesystems are collected
+const (
+	CollectionModeScheduled = "scheduled" // On the filesystem's interval (default)
+	CollectionModeOnScrape  = "on_scrape" // When /metrics is scraped, with the interval as a fallback
+)
+
+// Collection modes for directory groups
+const (
+	DirectoryModeDu           = "du"
+	DirectoryModeWalk         = "walk"
+	DirectoryModeProjectQuota = "project_quota" // Read usage from the path's XFS/ext4 project quota
+	DirectoryModeSample       = "sample"        // Estimate usage from a sample of subdirectories between full walks
+	DirectoryModeExec         = "exec"          // Run a user-supplied scanner printing "<bytes> <path>" lines
+)
+
+// Forms of the directory label of a group's series
+const (
+	DirectoryLabelAbsolute = "absolute" // The full path (default)
+	DirectoryLabelRelative = "relative" // The path relative to the group's root, "." for the root itself
+	DirectoryLabelBasename = "basename" // The last element of the path
+)
+
+// What a directory group's sizes measure
+const (
+	SizeModeDiskUsage = "disk_usage" // Space allocated on disk, like du (default)
+	SizeModeApparent  = "apparent"   // Bytes of data in the files, like ls -l
+)
+
+// Which symbolic links directory collection follows
+const (
+	FollowSymlinksNever      = "never"       // Count the links themselves, like du (default)
+	FollowSymlinksWithinRoot = "within_root" // Follow links to targets inside the group's path (walk only)
+	FollowSymlinksAlways     = "always"      // Follow every link, like du -L
+)
+
+// I/O scheduling classes of a directory group's commands, as in ionice(1)
+const (
+	IOPrioClassRealtime   = "realtime"    // Served before everything else (needs CAP_SYS_ADMIN)
+	IOPrioClassBestEffort = "best-effort" // The kernel's default class
+	IOPrioClassIdle       = "idle"        // Only served when no other process wants the disk
+)
+
+// Ways of keeping a directory group within max_series
+const (
+	SeriesStrategyTop   = "top"   // Keep the largest directories and drop the rest
+	SeriesStrategyOther = "other" // Like top, but sum the rest into a __other__ series per level
+)
+
+// APIConfig configures the exporter's JSON API listener
+type APIConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"` // Listen host (default: 127.0.0.1)
+	Port    int    `yaml:"port"` // Listen port (default: 8081)
+
+	HTTPTimeoutsConfig `yaml:",inline"`
+
+	ConfigUpdates ConfigUpdatesConfig `yaml:"config_updates"`
+}
+
+// HTTPTimeoutsConfig bounds the requests of an HTTP listener and how long
+// they may run once it shuts down
+type HTTPTimeoutsConfig struct {
+	ReadTimeout   Duration `yaml:"read_timeout"`   // Time to read a whole request (default: 30s)
+	WriteTimeout  Duration `yaml:"write_timeout"`  // Time to write a response, including building reports or probing (default: 2m)
+	IdleTimeout   Duration `yaml:"idle_timeout"`   // Keep-alive idle time (default: 2m)
+	ShutdownGrace Duration `yaml:"shutdown_grace"` // How long in-flight requests may run after SIGTERM (default: 10s)
+}
+
+// setDefaults fills in the timeouts left unset
+func (t *HTTPTimeoutsConfig) setDefaults() {
+	if t.ReadTimeout.Duration == 0 {
+		t.ReadTimeout = Duration{Duration: 30 * time.Second}
+	}
+
+	if t.WriteTimeout.Duration == 0 {
+		t.WriteTimeout = Duration{Duration: 2 * time.Minute}
+	}
+
+	if t.IdleTimeout.Duration == 0 {
+		t.IdleTimeout = Duration{Duration: 2 * time.Minute}
+	}
+
+	if t.ShutdownGrace.Duration == 0 {
+		t.ShutdownGrace = Duration{Duration: 10 * time.Second}
+	}
+}
+
+// validate checks that no timeout is negative
+func (t HTTPTimeoutsConfig) validate() error {
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"read_timeout", t.ReadTimeout},
+		{"write_timeout", t.WriteTimeout},
+		{"idle_timeout", t.IdleTimeout},
+		{"shutdown_grace", t.ShutdownGrace},
+	}
+
+	for _, timeout := range timeouts {
+		if timeout.value.Duration < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", timeout.name, timeout.value.Duration)
+		}
+	}
+
+	return nil
+}
+
+// ConfigUpdatesConfig lets the API add, replace and remove directory groups
+// at runtime. Only groups added this way can be changed; those in the config
+// files can't.
+type ConfigUpdatesConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	OverlayFile string `yaml:"overlay_file"` // Writable file the groups are kept in across restarts (default: lost on restart)
+}
+
+type FilesystemConfig struct {
+	Name       string      `yaml:"name"`
+	MountPoint string      `yaml:"mount_point"`
+	Device     string      `yaml:"device"`
+	Interval   Duration    `yaml:"interval"`
+	Timeout    Duration    `yaml:"timeout"`           // Timeout for df command execution (default: 10% of interval)
+	Mode       string      `yaml:"mode"`              // "df" (default) or "statfs"
+	Collection string      `yaml:"collection_mode"`   // "scheduled" or "on_scrape" (default: the global collection_mode)
+	Quota      ByteSize    `yaml:"quota"`             // Soft quota on used bytes, e.g. "500GiB" (0 disables)
+	QuotaBytes int64       `yaml:"quota_bytes"`       // Alternative to quota as a plain byte count
+	CoveredBy  []string    `yaml:"covered_by"`        // Directory groups that together cover the mount, for drift reporting
+	Enabled    *bool       `yaml:"enabled,omitempty"` // Set to false to keep the filesystem in the config without collecting it
+	Priority   int         `yaml:"priority"`          // Queued jobs with a higher priority are collected first (default: 0)
+	Retry      RetryConfig `yaml:"retry"`             // Retries of failed collections (default: the global retry section)
+
+	Labels map[string]string `yaml:"labels"` // Static labels added to the volume's series, e.g. team: platform
+}
+
+// IsEnabled reports whether the filesystem is collected (default: true)
+func (fs FilesystemConfig) IsEnabled() bool {
+	return fs.Enabled == nil || *fs.Enabled
+}
+
+type DirectoryGroup struct {
+	Path               string        `yaml:"path"`
+	SubdirectoryLevels int           `yaml:"subdirectory_levels"`
+	Interval           Duration      `yaml:"interval"`
+	Timeout            Duration      `yaml:"timeout"`             // Timeout for du command execution (default: 5m)
+	SmoothingAlpha     float64       `yaml:"smoothing_alpha"`     // EMA smoothing factor for the companion smoothed series (0 disables)
+	TopN               int           `yaml:"top_n"`               // Export the N largest immediate children (0 disables)
+	Mode               string        `yaml:"mode"`                // "du" (default), "walk" (native, no external command), "project_quota", "sample" or "exec"
+	Command            []string      `yaml:"command"`             // Scanner argv for mode exec; "{path}" is replaced by the path, which is otherwise appended
+	SizeMode           string        `yaml:"size_mode"`           // "disk_usage" (default) or "apparent"
+	FollowSymlinks     string        `yaml:"follow_symlinks"`     // "never" (default), "within_root" (walk only) or "always"
+	GroupByOwner       bool          `yaml:"group_by_owner"`      // Export usage per owning uid/gid (needs a native walk; a second pass in du mode)
+	HardlinksTotal     bool          `yaml:"hardlinks_total"`     // Also export the size counting every hardlink to a file (mode walk only)
+	NcduExport         string        `yaml:"ncdu_export"`         // File to write an ncdu JSON export of each walk to (mode walk only)
+	IOLimit            IOLimitConfig `yaml:"io_limit"`            // Bounds on the scans' disk I/O
+	IOPrioClass        string        `yaml:"ioprio_class"`        // I/O scheduling class of du and exec commands: "idle", "best-effort" or "realtime" (default: inherited)
+	IOPrioLevel        *int          `yaml:"ioprio_level"`        // Priority within best-effort or realtime, 0 (highest) to 7 (default: 4)
+	Nice               int           `yaml:"nice"`                // CPU nice value of du and exec commands, -20 to 19 (0 leaves it unchanged)
+	RunAsUser          string        `yaml:"run_as_user"`         // User du and exec commands run as, by name or uid (default: security.run_as_user)
+	RunAsGroup         string        `yaml:"run_as_group"`        // Their group, by name or gid (default: the user's primary group)
+	Quota              ByteSize      `yaml:"quota"`               // Soft quota on the group's total size, e.g. "500GiB" (0 disables)
+	QuotaBytes         int64         `yaml:"quota_bytes"`         // Alternative to quota as a plain byte count
+	ProjectID          uint32        `yaml:"project_id"`          // Project quota ID for project_quota mode (default: read from path)
+	ExpectedSize       ByteSize      `yaml:"expected_size"`       // Baseline size of the group's root for variance alerts (0 disables)
+	DropPageCache      bool          `yaml:"drop_page_cache"`     // Drop directories from the page cache after walking them (Linux only)
+	Sandbox            bool          `yaml:"sandbox"`             // Confine native walks to reading within path (Linux, needs Landlock)
+	Retry              RetryConfig   `yaml:"retry"`               // Retries of failed collections (default: the global retry section)
+	SampleFraction     float64       `yaml:"sample_fraction"`     // Share of subdirectories rescanned each interval in sample mode (default: 0.1)
+	FullScanInterval   Duration      `yaml:"full_scan_interval"`  // How often sample mode corrects itself with a full walk (default: 24h)
+	MaxSeries          int           `yaml:"max_series"`          // Cap on the group's directory series per collection (0 = unlimited)
+	MaxSeriesStrategy  string        `yaml:"max_series_strategy"` // "other" (default) or "top"
+	LabelPath          string        `yaml:"label_path"`          // Form of the directory label: "absolute" (default), "relative" or "basename"
+	Enabled            *bool         `yaml:"enabled,omitempty"`   // Set to false to keep the group in the config without collecting it
+	BlackoutWindows    []string      `yaml:"blackout_windows"`    // Local times scheduled collections are skipped, e.g. "Mon-Fri 08:00-18:00"
+	ConcurrencyGroup   string        `yaml:"concurrency_group"`   // Groups sharing a name run one at a time instead of by device (device_concurrency only)
+	Priority           int           `yaml:"priority"`            // Queued jobs with a higher priority are collected first (default: 0)
+
+	Labels map[string]string `yaml:"labels"` // Static labels added to the group's series, e.g. team: platform
+
+	// CanonicalPath is Path with symlinks resolved, filled in at load time
+	CanonicalPath string `yaml:"-"`
+}
+
+// IsEnabled reports whether the group is collected (default: true)
+func (d DirectoryGroup) IsEnabled() bool {
+	return d.Enabled == nil || *d.Enabled
+}
+
+// InBlackoutWindow reports whether t falls in one of the group's blackout
+// windows, which validation has already checked
+func (d DirectoryGroup) InBlackoutWindow(t time.Time) bool {
+	for _, window := range d.BlackoutWindows {
+		if w, err := ParseBlackoutWindow(window); err == nil && w.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadConfig loads configuration from an optional YAML file, then overlays environment variables.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+			}
+
+			// Settings nested in BaseConfig sections
+			var extra struct {
+				Server struct {
+					TLS        TLSConfig  `yaml:"tls"`
+					Auth       AuthConfig `yaml:"auth"`
+					Listen     string     `yaml:"listen"`
+					SocketMode string     `yaml:"socket_mode"`
+
+					HTTPTimeoutsConfig `yaml:",inline"`
+				} `yaml:"server"`
+				Metrics struct {
+					Collection struct {
+						ApplyDefaultInterval bool `yaml:"apply_default_interval"`
+					} `yaml:"collection"`
+				} `yaml:"metrics"`
+				Logging struct {
+					Levels    map[string]string  `yaml:"levels"`
+					RateLimit LogRateLimitConfig `yaml:"rate_limit"`
+				} `yaml:"logging"`
+				Tracing struct {
+					SampleRatio float64 `yaml:"sample_ratio"`
+					ScrubPaths  string  `yaml:"scrub_paths"`
+				} `yaml:"tracing"`
+			}
+
+			if err := yaml.Unmarshal(data, &extra); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+			}
+
+			config.TLS = extra.Server.TLS
+			config.Auth = extra.Server.Auth
+			config.Listen = extra.Server.Listen
+			config.SocketMode = extra.Server.SocketMode
+			config.ServerTimeouts = extra.Server.HTTPTimeoutsConfig
+			config.ApplyDefaultInterval = extra.Metrics.Collection.ApplyDefaultInterval
+			config.LogLevels = extra.Logging.Levels
+			config.LogRateLimit = extra.Logging.RateLimit
+			config.TracingSampleRatio = extra.Tracing.SampleRatio
+			config.TracingScrubPaths = extra.Tracing.ScrubPaths
+
+			if err := config.applyDirectoryDefaults(data); err != nil {
+				return nil, fmt.Errorf("failed to apply defaults in %s: %w", path, err)
+			}
+
+			if err := config.loadIncludes(path); err != nil {
+				return nil, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+
+	// Apply generic environment variables (TRACING_ENABLED, PROFILING_ENABLED, etc.)
+	if err := promexporter_config.ApplyGenericEnvVars(&config.BaseConfig); err != nil {
+		return nil, fmt.Errorf("failed to apply generic environment variables: %w", err)
+	}
+
+	// Overlay exporter-specific environment variables
+	if err := applyEnvVars(&config); err != nil {
+		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	}
+
+	if err := config.loadDirectoryOverlay(); err != nil {
+		return nil, err
+	}
+
+	// Expand {{ .Hostname }} / {{ .Env.NAME }} in names and paths
+	templateData, err := newTemplateData()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expandTemplates(&config, templateData); err != nil {
+		return nil, fmt.Errorf("failed to expand config templates: %w", err)
+	}
+
+	// Set defaults
+	setDefaults(&config)
+
+	// Validate configuration
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	config.canonicalizeDirectoryPaths()
+	config.labelNames = config.GetCustomLabelNames()
+
+	if err := config.loadBaseline(); err != nil {
+		return nil, fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	return &config, nil
+}
+
+// setDefaults sets default values for configuration
+func setDefaults(config *Config) {
+	if config.Server.Host == "" {
+		config.Server.Host = "0.0.0.0"
+	}
+
+	if config.Server.Port == 0 {
+		config.Server.Port = 8080
+	}
+
+	if config.Logging.Level == "" {
+		config.Logging.Level = "info"
+	}
+
+	if config.Logging.Format == "" {
+		config.Logging.Format = "json"
+	}
+
+	if config.LogRateLimit.Interval.Duration == 0 {
+		config.LogRateLimit.Interval = Duration{Duration: time.Minute}
+	}
+
+	if config.API.Host == "" {
+		config.API.Host = "127.0.0.1"
+	}
+
+	if config.API.Port == 0 {
+		config.API.Port = 8081
+	}
+
+	config.API.setDefaults()
+	config.ServerTimeouts.setDefaults()
+
+	if !config.Metrics.Collection.DefaultIntervalSet {
+		config.Metrics.Collection.DefaultInterval = promexporter_config.Duration{Duration: time.Second * 30}
+	}
+
+	if config.ApplyDefaultInterval {
+		for i := range config.Filesystems {
+			if config.Filesystems[i].Interval.Duration == 0 {
+				config.Filesystems[i].Interval = config.Metrics.Collection.DefaultInterval
+			}
+		}
+	}
+
+	if config.CollectionMode == "" {
+		config.CollectionMode = CollectionModeScheduled
+	}
+
+	// Collection modes default to the exec-based tools unless exec is disabled
+	// or the tools don't exist on this platform
+	for i := range config.Filesystems {
+		if config.Filesystems[i].Quota == 0 {
+			config.Filesystems[i].Quota = ByteSize(config.Filesystems[i].QuotaBytes)
+		}
+
+		if config.Filesystems[i].Collection == "" {
+			config.Filesystems[i].Collection = config.CollectionMode
+		}
+
+		if config.Filesystems[i].Mode == "" {
+			config.Filesystems[i].Mode = FilesystemModeDf
+			if config.Security.NoExec || !commandsAvailable {
+				config.Filesystems[i].Mode = FilesystemModeStatfs
+			}
+		}
+	}
+
+	for name, group := range config.Directories {
+		config.Directories[name] = setDirectoryDefaults(config, group)
+	}
+
+	for i := range config.Quotas.Filesystems {
+		if len(config.Quotas.Filesystems[i].Types) == 0 {
+			config.Quotas.Filesystems[i].Types = []string{QuotaTypeUser, QuotaTypeGroup}
+		}
+	}
+
+	if config.Kubernetes.PodsDir == "" {
+		config.Kubernetes.PodsDir = "/var/lib/kubelet/pods"
+	}
+
+	if config.Kubernetes.NodeName == "" {
+		config.Kubernetes.NodeName = os.Getenv("NODE_NAME")
+	}
+
+	if config.Docker.Socket == "" {
+		config.Docker.Socket = "/var/run/docker.sock"
+	}
+
+	for i := range config.Buckets.Endpoints {
+		endpoint := &config.Buckets.Endpoints[i]
+
+		if endpoint.Region == "" {
+			endpoint.Region = "us-east-1"
+		}
+
+		if endpoint.AccessKeyID == "" && endpoint.SecretAccessKey == "" {
+			endpoint.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+			endpoint.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+	}
+
+	if config.Digest.Schedule == "" {
+		config.Digest.Schedule = DigestScheduleDaily
+	}
+
+	if config.Digest.Time == "" {
+		config.Digest.Time = "08:00"
+	}
+
+	if config.Digest.Weekday == "" {
+		config.Digest.Weekday = "monday"
+	}
+
+	if config.Digest.TopN == 0 {
+		config.Digest.TopN = 5
+	}
+
+	if config.Digest.SMTP.TLS == "" {
+		config.Digest.SMTP.TLS = SMTPTLSStartTLS
+	}
+
+	if config.Digest.SMTP.Port == 0 {
+		config.Digest.SMTP.Port = 587
+		if config.Digest.SMTP.TLS == SMTPTLSImplicit {
+			config.Digest.SMTP.Port = 465
+		}
+	}
+
+	if config.Digest.SMTP.Password == "" {
+		config.Digest.SMTP.Password = os.Getenv("SMTP_PASSWORD")
+	}
+
+	if config.Pushgateway.Job == "" {
+		config.Pushgateway.Job = "filesystem_exporter"
+	}
+
+	// Without an instance label every host pushing to the same job would
+	// replace the others' metrics
+	if _, exists := config.Pushgateway.Grouping["instance"]; !exists {
+		if hostname, err := os.Hostname(); err == nil {
+			if config.Pushgateway.Grouping == nil {
+				config.Pushgateway.Grouping = make(map[string]string)
+			}
+
+			config.Pushgateway.Grouping["instance"] = hostname
+		}
+	}
+
+	if config.Pushgateway.Password == "" {
+		config.Pushgateway.Password = os.Getenv("PUSHGATEWAY_PASSWORD")
+	}
+
+	if config.Pushgateway.Timeout.Duration == 0 {
+		config.Pushgateway.Timeout = Duration{Duration: 30 * time.Second}
+	}
+
+	if config.SocketMode == "" {
+		config.SocketMode = "0660"
+	}
+
+	if config.TracingSampleRatio == 0 {
+		config.TracingSampleRatio = 1
+	}
+
+	if config.TLS.ReloadInterval.Duration == 0 {
+		config.TLS.ReloadInterval = Duration{Duration: 30 * time.Second}
+	}
+
+	if config.Probe.Timeout.Duration == 0 {
+		config.Probe.Timeout = Duration{Duration: 30 * time.Second}
+	}
+
+	if config.Probe.MaxConcurrent == 0 {
+		config.Probe.MaxConcurrent = 2
+	}
+
+	if config.Health.FailureThreshold == 0 {
+		config.Health.FailureThreshold = 3
+	}
+
+	if config.Health.StaleIntervals == 0 {
+		config.Health.StaleIntervals = 2
+	}
+
+	if config.AdaptiveInterval.Threshold == 0 {
+		config.AdaptiveInterval.Threshold = 0.5
+	}
+
+	config.Retry = config.Retry.withDefaults(defaultRetry)
+
+	if config.AdaptiveInterval.MaxMultiplier == 0 {
+		config.AdaptiveInterval.MaxMultiplier = 4
+	}
+
+	if config.Queue.Size == 0 {
+		config.Queue.Size = 100
+	}
+
+	if config.Queue.OverflowPolicy == "" {
+		config.Queue.OverflowPolicy = QueueOverflowBlock
+	}
+
+	if config.CommandLimits.KillGrace.Duration == 0 {
+		config.CommandLimits.KillGrace = Duration{Duration: 5 * time.Second}
+	}
+
+	if config.Shutdown.DrainTimeout.Duration == 0 {
+		config.Shutdown.DrainTimeout = Duration{Duration: 15 * time.Second}
+	}
+
+	if config.DeviceConcurrency.MaxWorkers == 0 {
+		config.DeviceConcurrency.MaxWorkers = 4
+	}
+
+	if config.ScrapeCollection.Budget.Duration == 0 {
+		config.ScrapeCollection.Budget = Duration{Duration: 5 * time.Second}
+	}
+
+	if config.ScrapeCollection.CacheTTL.Duration == 0 {
+		config.ScrapeCollection.CacheTTL = Duration{Duration: 10 * time.Second}
+	}
+
+	if config.Growth.Window.Duration == 0 {
+		config.Growth.Window = Duration{Duration: 24 * time.Hour}
+	}
+
+	if config.Growth.MinSamples == 0 {
+		config.Growth.MinSamples = 3
+	}
+
+	if config.Alerts.RepeatInterval.Duration == 0 {
+		config.Alerts.RepeatInterval = Duration{Duration: 4 * time.Hour}
+	}
+
+	for i := range config.Alerts.Rules {
+		if config.Alerts.Rules[i].For == 0 {
+			config.Alerts.Rules[i].For = 1
+		}
+	}
+
+	for i := range config.Alerts.Webhooks {
+		if config.Alerts.Webhooks[i].Type == "" {
+			config.Alerts.Webhooks[i].Type = WebhookTypeGeneric
+		}
+
+		if config.Alerts.Webhooks[i].Timeout.Duration == 0 {
+			config.Alerts.Webhooks[i].Timeout = Duration{Duration: 10 * time.Second}
+		}
+	}
+
+	// No hardcoded defaults - if no filesystems are configured, that's fine
+	// Filesystems are optional
+	// Intervals must be explicitly specified - no defaults
+}
+
+// setDirectoryDefaults returns a directory group with the defaults its
+// settings leave unset filled in
+func setDirectoryDefaults(config *Config, group DirectoryGroup) DirectoryGroup {
+	if config.ApplyDefaultInterval && group.Interval.Duration == 0 {
+		group.Interval = config.Metrics.Collection.DefaultInterval
+	}
+
+	if group.Quota == 0 {
+		group.Quota = ByteSize(group.QuotaBytes)
+	}
+
+	if group.Mode == "" {
+		group.Mode = DirectoryModeDu
+		if config.Security.NoExec || !commandsAvailable {
+			group.Mode = DirectoryModeWalk
+		}
+	}
+
+	if group.SizeMode == "" {
+		group.SizeMode = SizeModeDiskUsage
+	}
+
+	if group.FollowSymlinks == "" {
+		group.FollowSymlinks = FollowSymlinksNever
+	}
+
+	if group.LabelPath == "" {
+		group.LabelPath = DirectoryLabelAbsolute
+	}
+
+	if group.MaxSeries > 0 && group.MaxSeriesStrategy == "" {
+		group.MaxSeriesStrategy = SeriesStrategyOther
+	}
+
+	if group.Mode == DirectoryModeSample {
+		if group.SampleFraction == 0 {
+			group.SampleFraction = 0.1
+		}
+
+		if group.FullScanInterval.Duration == 0 {
+			group.FullScanInterval = Duration{Duration: 24 * time.Hour}
+		}
+	}
+
+	return group
+}
+
+// Validate performs comprehensive validation of the configuration
+func (c *Config) Validate() error {
+	// Validate server configuration
+	if err := c.validateServerConfig(); err != nil {
+		return fmt.Errorf("server config: %w", err)
+	}
+
+	// Validate logging configuration
+	if err := c.validateLoggingConfig(); err != nil {
+		return fmt.Errorf("logging config: %w", err)
+	}
+
+	// Validate metrics configuration
+	if err := c.validateMetricsConfig(); err != nil {
+		return fmt.Errorf("metrics config: %w", err)
+	}
+
+	// Validate API configuration
+	if err := c.validateAPIConfig(); err != nil {
+		return fmt.Errorf("api config: %w", err)
+	}
+
+	// Validate filesystem configuration
+	if err := c.validateFilesystemsConfig(); err != nil {
+		return fmt.Errorf("filesystems config: %w", err)
+	}
+
+	// Validate directory configuration
+	if err := c.validateDirectoriesConfig(); err != nil {
+		return fmt.Errorf("directories config: %w", err)
+	}
+
+	// Validate filesystem coverage now both filesystems and directories are known
+	if err := c.validateCoverageConfig(); err != nil {
+		return fmt.Errorf("filesystems config: %w", err)
+	}
+
+	// Validate ZFS configuration
+	if err := c.validateZFSConfig(); err != nil {
+		return fmt.Errorf("zfs config: %w", err)
+	}
+
+	// Validate btrfs configuration
+	if err := c.validateBtrfsConfig(); err != nil {
+		return fmt.Errorf("btrfs config: %w", err)
+	}
+
+	// Validate quotas configuration
+	if err := c.validateQuotasConfig(); err != nil {
+		return fmt.Errorf("quotas config: %w", err)
+	}
+
+	// Validate mount probe configuration
+	if err := c.validateMountProbeConfig(); err != nil {
+		return fmt.Errorf("mount probe config: %w", err)
+	}
+
+	// Validate command limits
+	if err := c.validateCommandLimitsConfig(); err != nil {
+		return fmt.Errorf("command limits config: %w", err)
+	}
+
+	// Validate kubernetes configuration
+	if err := c.validateKubernetesConfig(); err != nil {
+		return fmt.Errorf("kubernetes config: %w", err)
+	}
+
+	// Validate docker configuration
+	if err := c.validateDockerConfig(); err != nil {
+		return fmt.Errorf("docker config: %w", err)
+	}
+
+	// Validate buckets configuration
+	if err := c.validateBucketsConfig(); err != nil {
+		return fmt.Errorf("buckets config: %w", err)
+	}
+
+	if err := c.validateDigestConfig(); err != nil {
+		return fmt.Errorf("digest config: %w", err)
+	}
+
+	if err := c.validateTLSConfig(); err != nil {
+		return fmt.Errorf("server tls config: %w", err)
+	}
+
+	if err := c.validateListenConfig(); err != nil {
+		return fmt.Errorf("server config: %w", err)
+	}
+
+	if err := c.validateAuthConfig(); err != nil {
+		return fmt.Errorf("server auth config: %w", err)
+	}
+
+	if err := c.validatePushgatewayConfig(); err != nil {
+		return fmt.Errorf("pushgateway config: %w", err)
+	}
+
+	if err := c.validateAlertsConfig(); err != nil {
+		return fmt.Errorf("alerts config: %w", err)
+	}
+
+	if err := c.validateProbeConfig(); err != nil {
+		return fmt.Errorf("probe config: %w", err)
+	}
+
+	if err := c.validateGroupMetricsConfig(); err != nil {
+		return fmt.Errorf("group metrics config: %w", err)
+	}
+
+	if err := c.validateTracingConfig(); err != nil {
+		return fmt.Errorf("tracing config: %w", err)
+	}
+
+	if c.Health.FailureThreshold < 0 {
+		return fmt.Errorf("health config: failure_threshold cannot be negative, got %d", c.Health.FailureThreshold)
+	}
+
+	if c.Health.StaleIntervals < 0 {
+		return fmt.Errorf("health config: stale_intervals cannot be negative, got %g", c.Health.StaleIntervals)
+	}
+
+	if err := c.validateAdaptiveIntervalConfig(); err != nil {
+		return fmt.Errorf("adaptive interval config: %w", err)
+	}
+
+	if err := c.Retry.validate(); err != nil {
+		return fmt.Errorf("retry config: %w", err)
+	}
+
+	if err := c.validateQueueConfig(); err != nil {
+		return fmt.Errorf("queue config: %w", err)
+	}
+
+	if err := c.validateCPUConfig(); err != nil {
+		return fmt.Errorf("cpu config: %w", err)
+	}
+
+	if c.CollectionMode != CollectionModeScheduled && c.CollectionMode != CollectionModeOnScrape {
+		return fmt.Errorf("invalid collection_mode '%s' (must be scheduled or on_scrape)", c.CollectionMode)
+	}
+
+	if c.ScrapeCollection.Budget.Duration < 0 {
+		return fmt.Errorf("scrape collection config: budget cannot be negative, got %s", c.ScrapeCollection.Budget.Duration)
+	}
+
+	if c.ScrapeCollection.CacheTTL.Duration < 0 {
+		return fmt.Errorf("scrape collection config: cache_ttl cannot be negative, got %s", c.ScrapeCollection.CacheTTL.Duration)
+	}
+
+	if c.Growth.Window.Duration < 0 {
+		return fmt.Errorf("growth config: window cannot be negative, got %s", c.Growth.Window.Duration)
+	}
+
+	if c.Growth.MinSamples < 2 {
+		return fmt.Errorf("growth config: min_samples must be at least 2, got %d", c.Growth.MinSamples)
+	}
+
+	if c.DeviceConcurrency.Enabled && c.DeviceConcurrency.MaxWorkers < 1 {
+		return fmt.Errorf("device concurrency config: max_workers must be at least 1, got %d", c.DeviceConcurrency.MaxWorkers)
+	}
+
+	if c.CommandLimits.KillGrace.Duration < 0 {
+		return fmt.Errorf("command limits config: kill_grace must not be negative, got %s", c.CommandLimits.KillGrace.Duration)
+	}
+
+	if c.Shutdown.DrainTimeout.Duration < 0 {
+		return fmt.Errorf("shutdown config: drain_timeout must not be negative, got %s", c.Shutdown.DrainTimeout.Duration)
+	}
+
+	if c.Security.AuditLog.Syslog && runtime.GOOS == "windows" {
+		return fmt.Errorf("security config: audit_log syslog is not available on windows")
+	}
+
+	if _, err := ResolveRunAs(c.Security.RunAsUser, c.Security.RunAsGroup); err != nil {
+		return fmt.Errorf("security config: %w", err)
+	}
+
+	if c.Security.RunAsUser != "" && runtime.GOOS == "windows" {
+		return fmt.Errorf("security config: run_as_user is not available on windows")
+	}
+
+	for _, root := range c.Security.AllowedRoots {
+		if err := ValidatePath(root, nil); err != nil {
+			return fmt.Errorf("security config: allowed_roots: %w", err)
+		}
+	}
+
+	// Require at least one filesystem, directory or collector to be configured
+	if len(c.Filesystems) == 0 && len(c.Directories) == 0 && !c.ZFS.Enabled && !c.Btrfs.Enabled && !c.Quotas.Enabled && !c.Buckets.Enabled && !c.Docker.Enabled && !c.Kubernetes.Enabled {
+		return fmt.Errorf("at least one filesystem or directory must be configured")
+	}
+
+	return nil
+}
+
+func (c *Config) validateServerConfig() error {
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %d", c.Server.Port)
+	}
+
+	return c.ServerTimeouts.validate()
+}
+
+func (c *Config) validateAPIConfig() error {
+	if c.API.ConfigUpdates.Enabled {
+		if !c.API.Enabled {
+			return fmt.Errorf("config_updates needs the API enabled")
+		}
+
+		// Anyone who can change a group can have any readable tree scanned
+		if !c.Auth.IsEnabled() {
+			return fmt.Errorf("config_updates needs server.auth, so only authenticated clients can change directory groups")
+		}
+	}
+
+	if !c.API.Enabled {
+		return nil
+	}
+
+	if c.API.Port < 1 || c.API.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %d", c.API.Port)
+	}
+
+	if c.API.Host == c.Server.Host && c.API.Port == c.Server.Port {
+		return fmt.Errorf("api listener %s:%d conflicts with the metrics server", c.API.Host, c.API.Port)
+	}
+
+	return c.API.validate()
+}
+
+func (c *Config) validateLoggingConfig() error {
+	validLevels := map[string]bool{
+		"debug": true,
+		"info":  true,
+		"warn":  true,
+		"error": true,
+	}
+	if !validLevels[c.Logging.Level] {
+		return fmt.Errorf("invalid logging level: %s", c.Logging.Level)
+	}
+
+	for component, level := range c.LogLevels {
+		if component == "" {
+			return fmt.Errorf("levels cannot have an empty component")
+		}
+
+		if !validLevels[level] {
+			return fmt.Errorf("invalid logging level for %s: %s", component, level)
+		}
+	}
+
+	if c.LogRateLimit.Burst < 0 {
+		return fmt.Errorf("rate_limit burst cannot be negative, got %d", c.LogRateLimit.Burst)
+	}
+
+	if c.LogRateLimit.Interval.Duration < 0 {
+		return fmt.Errorf("rate_limit interval cannot be negative, got %s", c.LogRateLimit.Interval.Duration)
+	}
+
+	validFormats := map[string]bool{
+		"json": true,
+		"text": true,
+	}
+	if !validFormats[c.Logging.Format] {
+		return fmt.Errorf("invalid logging format: %s", c.Logging.Format)
+	}
+
+	return nil
+}
+
+func (c *Config) validateMetricsConfig() error {
+	if c.Metrics.Collection.DefaultInterval.Seconds() < 1 {
+		return fmt.Errorf("default interval must be at least 1 second, got %d", c.Metrics.Collection.DefaultInterval.Seconds())
+	}
+
+	if c.Metrics.Collection.DefaultInterval.Seconds() > 86400 {
+		return fmt.Errorf("default interval must be at most 86400 seconds (24 hours), got %d", c.Metrics.Collection.DefaultInterval.Seconds())
+	}
+
+	return nil
+}
+
+func (c *Config) validateFilesystemsConfig() error {
+	// Filesystems are optional - if none are configured, that's fine
+	if len(c.Filesystems) == 0 {
+		return nil
+	}
+
+	for _, fs := range c.Filesystems {
+		if fs.Name == "" {
+			return fmt.Errorf("filesystem name cannot be empty")
+		}
+
+		if !filepath.IsAbs(fs.MountPoint) {
+			return fmt.Errorf("filesystem mount point must be absolute: %s", fs.MountPoint)
+		}
+
+		if err := c.Security.checkAllowed(fs.MountPoint); err != nil {
+			return fmt.Errorf("filesystem '%s': %w", fs.Name, err)
+		}
+
+		if fs.Interval.Duration == 0 {
+			return fmt.Errorf("filesystem '%s' must have an interval specified (or set metrics.collection.apply_default_interval)", fs.Name)
+		}
+
+		if fs.Interval.Seconds() < 1 {
+			return fmt.Errorf("filesystem interval must be at least 1 second, got %d", fs.Interval.Seconds())
+		}
+
+		if fs.Quota < 0 {
+			return fmt.Errorf("filesystem '%s' quota cannot be negative", fs.Name)
+		}
+
+		if err := fs.Retry.validate(); err != nil {
+			return fmt.Errorf("filesystem '%s' retry: %w", fs.Name, err)
+		}
+
+		if err := validateCustomLabels(fs.Labels); err != nil {
+			return fmt.Errorf("filesystem '%s' %w", fs.Name, err)
+		}
+
+		switch fs.Mode {
+		case FilesystemModeDf:
+			if c.Security.NoExec {
+				return fmt.Errorf("filesystem '%s' uses mode df, which requires exec but security.no_exec is set", fs.Name)
+			}
+
+			if !commandsAvailable {
+				return fmt.Errorf("filesystem '%s' uses mode df, which is not available on %s (use statfs)", fs.Name, runtime.GOOS)
+			}
+		case FilesystemModeStatfs:
+		default:
+			return fmt.Errorf("filesystem '%s' has invalid mode '%s' (must be df or statfs)", fs.Name, fs.Mode)
+		}
+
+		switch fs.Collection {
+		case CollectionModeScheduled, CollectionModeOnScrape:
+		default:
+			return fmt.Errorf("filesystem '%s' has invalid collection_mode '%s' (must be scheduled or on_scrape)", fs.Name, fs.Collection)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateDirectoriesConfig() error {
+	for name, group := range c.Directories {
+		if name == "" {
+			return fmt.Errorf("directory group name cannot be empty")
+		}
+
+		if err := ValidatePath(group.Path, c.Security.AllowedRoots); err != nil {
+			return fmt.Errorf("directory '%s': %w", name, err)
+		}
+
+		// Intervals must be explicitly specified unless apply_default_interval
+		// filled them in
+		if group.Interval.Duration == 0 {
+			return fmt.Errorf("directory '%s' must have an interval specified (or set metrics.collection.apply_default_interval)", name)
+		}
+
+		if group.Interval.Seconds() < 1 {
+			return fmt.Errorf("directory interval must be at least 1 second, got %d", group.Interval.Seconds())
+		}
+
+		switch group.Mode {
+		case DirectoryModeDu:
+			if c.Security.NoExec {
+				return fmt.Errorf("directory '%s' uses mode du, which requires exec but security.no_exec is set", name)
+			}
+
+			if !commandsAvailable {
+				return fmt.Errorf("directory '%s' uses mode du, which is not available on %s (use walk)", name, runtime.GOOS)
+			}
+		case DirectoryModeWalk:
+		case DirectoryModeProjectQuota:
+			// A project quota only reports the total for the whole tree
+			if group.SubdirectoryLevels > 0 || group.TopN > 0 || group.GroupByOwner {
+				return fmt.Errorf("directory '%s' uses mode project_quota, which doesn't support subdirectory_levels, top_n or group_by_owner", name)
+			}
+		case DirectoryModeSample:
+			// Only the group's total is estimated
+			if group.SubdirectoryLevels > 0 || group.TopN > 0 || group.GroupByOwner {
+				return fmt.Errorf("directory '%s' uses mode sample, which doesn't support subdirectory_levels, top_n or group_by_owner", name)
+			}
+
+			if group.SampleFraction <= 0 || group.SampleFraction > 1 {
+				return fmt.Errorf("directory '%s' sample_fraction must be greater than 0 and at most 1, got %g", name, group.SampleFraction)
+			}
+
+			if group.FullScanInterval.Duration < group.Interval.Duration {
+				return fmt.Errorf("directory '%s' full_scan_interval (%s) cannot be shorter than its interval (%s)", name, group.FullScanInterval, group.Interval)
+			}
+		case DirectoryModeExec:
+			if c.Security.NoExec {
+				return fmt.Errorf("directory '%s' uses mode exec, which requires exec but security.no_exec is set", name)
+			}
+
+			if !commandsAvailable {
+				return fmt.Errorf("directory '%s' uses mode exec, which is not available on %s (use walk)", name, runtime.GOOS)
+			}
+
+			if len(group.Command) == 0 || group.Command[0] == "" {
+				return fmt.Errorf("directory '%s' uses mode exec but has no command", name)
+			}
+
+			// The scanner only reports directory sizes
+			if group.TopN > 0 || group.GroupByOwner {
+				return fmt.Errorf("directory '%s' uses mode exec, which doesn't support top_n or group_by_owner", name)
+			}
+		default:
+			return fmt.Errorf("directory '%s' has invalid mode '%s' (must be du, walk, project_quota, sample or exec)", name, group.Mode)
+		}
+
+		if len(group.Command) > 0 && group.Mode != DirectoryModeExec {
+			return fmt.Errorf("directory '%s' command only applies to mode exec", name)
+		}
+
+		switch group.SizeMode {
+		case SizeModeDiskUsage:
+		case SizeModeApparent:
+			// A project quota only counts the blocks charged to it
+			if group.Mode == DirectoryModeProjectQuota {
+				return fmt.Errorf("directory '%s' uses mode project_quota, which doesn't support size_mode apparent", name)
+			}
+		default:
+			return fmt.Errorf("directory '%s' has invalid size_mode '%s' (must be disk_usage or apparent)", name, group.SizeMode)
+		}
+
+		switch group.FollowSymlinks {
+		case FollowSymlinksNever:
+		case FollowSymlinksWithinRoot, FollowSymlinksAlways:
+			switch {
+			case group.Mode == DirectoryModeDu && group.FollowSymlinks == FollowSymlinksWithinRoot:
+				return fmt.Errorf("directory '%s' follow_symlinks within_root needs mode walk (du can only follow every link)", name)
+			case group.Mode == DirectoryModeProjectQuota, group.Mode == DirectoryModeSample, group.Mode == DirectoryModeExec:
+				return fmt.Errorf("directory '%s' uses mode %s, which doesn't support follow_symlinks", name, group.Mode)
+			case group.Mode == DirectoryModeWalk && runtime.GOOS != "linux":
+				// Loops can't be detected without inode numbers
+				return fmt.Errorf("directory '%s' follow_symlinks with mode walk is not available on %s (use du)", name, runtime.GOOS)
+			}
+		default:
+			return fmt.Errorf("directory '%s' has invalid follow_symlinks '%s' (must be never, within_root or always)", name, group.FollowSymlinks)
+		}
+
+		if group.Quota < 0 {
+			return fmt.Errorf("directory '%s' quota cannot be negative", name)
+		}
+
+		if group.TopN < 0 {
+			return fmt.Errorf("directory '%s' top_n cannot be negative, got %d", name, group.TopN)
+		}
+
+		// du counts hardlinks once without saying how much it left out
+		if group.HardlinksTotal && group.Mode != DirectoryModeWalk {
+			return fmt.Errorf("directory '%s' hardlinks_total needs mode walk", name)
+		}
+
+		if group.NcduExport != "" && group.Mode != DirectoryModeWalk {
+			return fmt.Errorf("directory '%s' ncdu_export needs mode walk", name)
+		}
+
+		if err := c.validateIOLimit(name, group); err != nil {
+			return err
+		}
+
+		if err := validateCommandPriority(name, group); err != nil {
+			return err
+		}
+
+		if group.RunAsUser != "" || group.RunAsGroup != "" {
+			if group.Mode != DirectoryModeDu && group.Mode != DirectoryModeExec {
+				return fmt.Errorf("directory '%s' run_as_user and run_as_group only apply to the commands of mode du or exec", name)
+			}
+
+			if _, err := ResolveRunAs(group.RunAsUser, group.RunAsGroup); err != nil {
+				return fmt.Errorf("directory '%s': %w", name, err)
+			}
+		}
+
+		if group.DropPageCache {
+			if runtime.GOOS != "linux" {
+				return fmt.Errorf("directory '%s' drop_page_cache is not available on %s", name, runtime.GOOS)
+			}
+
+			if group.Mode != DirectoryModeWalk && group.Mode != DirectoryModeSample && !group.GroupByOwner {
+				return fmt.Errorf("directory '%s' drop_page_cache only applies to native walks (use mode walk, sample or group_by_owner)", name)
+			}
+		}
+
+		if group.Sandbox {
+			switch {
+			case group.Mode != DirectoryModeWalk && group.Mode != DirectoryModeSample && !group.GroupByOwner:
+				return fmt.Errorf("directory '%s' sandbox only applies to native walks (use mode walk, sample or group_by_owner)", name)
+			case runtime.GOOS != "linux":
+				return fmt.Errorf("directory '%s' sandbox needs Landlock, which is not available on %s", name, runtime.GOOS)
+			case group.FollowSymlinks == FollowSymlinksAlways:
+				return fmt.Errorf("directory '%s' sandbox would stop follow_symlinks always following links out of the path (use within_root)", name)
+			}
+		}
+
+		if err := group.Retry.validate(); err != nil {
+			return fmt.Errorf("directory '%s' retry: %w", name, err)
+		}
+
+		if group.SmoothingAlpha < 0 || group.SmoothingAlpha > 1 {
+			return fmt.Errorf("directory '%s' smoothing_alpha must be between 0 and 1, got %g", name, group.SmoothingAlpha)
+		}
+
+		switch group.LabelPath {
+		case DirectoryLabelAbsolute, DirectoryLabelRelative:
+		case DirectoryLabelBasename:
+			// Deeper levels repeat names, e.g. a/logs and b/logs
+			if group.SubdirectoryLevels > 1 {
+				return fmt.Errorf("directory '%s' label_path basename is ambiguous with subdirectory_levels above 1 (use relative)", name)
+			}
+		default:
+			return fmt.Errorf("directory '%s' has invalid label_path '%s' (must be absolute, relative or basename)", name, group.LabelPath)
+		}
+
+		if group.MaxSeries < 0 {
+			return fmt.Errorf("directory '%s' max_series cannot be negative, got %d", name, group.MaxSeries)
+		}
+
+		if group.MaxSeries > 0 {
+			switch group.MaxSeriesStrategy {
+			case SeriesStrategyTop:
+			case SeriesStrategyOther:
+				// The root and one __other__ series per level are always exported
+				if group.MaxSeries <= group.SubdirectoryLevels+1 {
+					return fmt.Errorf("directory '%s' max_series must be above subdirectory_levels + 1 with strategy other, got %d", name, group.MaxSeries)
+				}
+			default:
+				return fmt.Errorf("directory '%s' has invalid max_series_strategy '%s' (must be top or other)", name, group.MaxSeriesStrategy)
+			}
+		}
+
+		if err := validateCustomLabels(group.Labels); err != nil {
+			return fmt.Errorf("directory '%s' %w", name, err)
+		}
+
+		for _, window := range group.BlackoutWindows {
+			if _, err := ParseBlackoutWindow(window); err != nil {
+				return fmt.Errorf("directory '%s' %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateCoverageConfig() error {
+	for _, fs := range c.Filesystems {
+		for _, groupName := range fs.CoveredBy {
+			group, exists := c.Directories[groupName]
+			if !exists {
+				return fmt.Errorf("filesystem '%s' covered_by references unknown directory group '%s'", fs.Name, groupName)
+			}
+
+			rel, err := filepath.Rel(fs.MountPoint, group.Path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+				return fmt.Errorf("filesystem '%s' covered_by group '%s' path %s is outside %s", fs.Name, groupName, group.Path, fs.MountPoint)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateZFSConfig() error {
+	if !c.ZFS.Enabled {
+		return nil
+	}
+
+	if c.Security.NoExec {
+		return fmt.Errorf("the zfs collector runs the zfs command, which is not allowed when security.no_exec is set")
+	}
+
+	if !commandsAvailable {
+		return fmt.Errorf("the zfs collector is not available on %s", runtime.GOOS)
+	}
+
+	if c.ZFS.Interval.Duration != 0 && c.ZFS.Interval.Seconds() < 1 {
+		return fmt.Errorf("zfs interval must be at least 1 second, got %d", c.ZFS.Interval.Seconds())
+	}
+
+	for _, dataset := range c.ZFS.Datasets {
+		if dataset == "" || strings.HasPrefix(dataset, "-") {
+			return fmt.Errorf("invalid zfs dataset name '%s'", dataset)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateBtrfsConfig() error {
+	if !c.Btrfs.Enabled {
+		return nil
+	}
+
+	if c.Security.NoExec {
+		return fmt.Errorf("the btrfs collector runs the btrfs command, which is not allowed when security.no_exec is set")
+	}
+
+	if !commandsAvailable {
+		return fmt.Errorf("the btrfs collector is not available on %s", runtime.GOOS)
+	}
+
+	if c.Btrfs.Interval.Duration != 0 && c.Btrfs.Interval.Seconds() < 1 {
+		return fmt.Errorf("btrfs interval must be at least 1 second, got %d", c.Btrfs.Interval.Seconds())
+	}
+
+	if len(c.Btrfs.MountPoints) == 0 {
+		return fmt.Errorf("at least one btrfs mount point must be configured")
+	}
+
+	for _, mountPoint := range c.Btrfs.MountPoints {
+		if !filepath.IsAbs(mountPoint) {
+			return fmt.Errorf("btrfs mount point must be absolute: %s", mountPoint)
+		}
+
+		if err := c.Security.checkAllowed(mountPoint); err != nil {
+			return fmt.Errorf("btrfs: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateQuotasConfig() error {
+	if !c.Quotas.Enabled {
+		return nil
+	}
+
+	if c.Quotas.Interval.Duration != 0 && c.Quotas.Interval.Seconds() < 1 {
+		return fmt.Errorf("quotas interval must be at least 1 second, got %d", c.Quotas.Interval.Seconds())
+	}
+
+	if len(c.Quotas.Filesystems) == 0 {
+		return fmt.Errorf("at least one quota filesystem must be configured")
+	}
+
+	names := make(map[string]bool)
+
+	for _, fs := range c.Quotas.Filesystems {
+		if fs.Name == "" {
+			return fmt.Errorf("quota filesystem name cannot be empty")
+		}
+
+		if names[fs.Name] {
+			return fmt.Errorf("duplicate quota filesystem name '%s'", fs.Name)
+		}
+
+		names[fs.Name] = true
+
+		if !filepath.IsAbs(fs.MountPoint) {
+			return fmt.Errorf("quota filesystem mount point must be absolute: %s", fs.MountPoint)
+		}
+
+		if err := c.Security.checkAllowed(fs.MountPoint); err != nil {
+			return fmt.Errorf("quota filesystem '%s': %w", fs.Name, err)
+		}
+
+		for _, quotaType := range fs.Types {
+			if quotaType != QuotaTypeUser && quotaType != QuotaTypeGroup {
+				return fmt.Errorf("quota filesystem '%s' has invalid type '%s' (must be user or group)", fs.Name, quotaType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateIOLimit checks a group's io_limit against the way it is scanned:
+// a native walk paces itself, while commands are limited by their cgroup
+func (c *Config) validateIOLimit(name string, group DirectoryGroup) error {
+	limit := group.IOLimit
+
+	if limit.FilesPerSecond < 0 || limit.ReadBytesPerSecond < 0 || limit.ReadIOPS < 0 {
+		return fmt.Errorf("directory '%s' io_limit values cannot be negative", name)
+	}
+
+	nativeWalk := group.Mode == DirectoryModeWalk || group.Mode == DirectoryModeSample || group.GroupByOwner
+
+	if limit.FilesPerSecond > 0 && !nativeWalk {
+		return fmt.Errorf("directory '%s' io_limit files_per_second only applies to native walks (use mode walk, sample or group_by_owner)", name)
+	}
+
+	if !limit.LimitsCommands() {
+		return nil
+	}
+
+	switch {
+	case group.Mode != DirectoryModeDu && group.Mode != DirectoryModeExec:
+		return fmt.Errorf("directory '%s' io_limit read_bytes_per_second and read_iops only apply to mode du or exec (use files_per_second)", name)
+	case group.GroupByOwner:
+		return fmt.Errorf("directory '%s' io_limit read_bytes_per_second and read_iops don't apply to the native walk of group_by_owner (use files_per_second)", name)
+	case runtime.GOOS != "linux":
+		return fmt.Errorf("directory '%s' io_limit read_bytes_per_second and read_iops need cgroups, which are not available on %s", name, runtime.GOOS)
+	case c.CommandLimits.IOCgroupPath == "":
+		return fmt.Errorf("directory '%s' io_limit read_bytes_per_second and read_iops need command_limits.io_cgroup_path", name)
+	}
+
+	return nil
+}
+
+// validateCommandPriority checks the scheduling priority given to a group's
+// du or exec commands
+func validateCommandPriority(name string, group DirectoryGroup) error {
+	if group.IOPrioClass == "" && group.IOPrioLevel == nil && group.Nice == 0 {
+		return nil
+	}
+
+	if group.Mode != DirectoryModeDu && group.Mode != DirectoryModeExec {
+		return fmt.Errorf("directory '%s' ioprio_class, ioprio_level and nice only apply to the commands of mode du or exec", name)
+	}
+
+	if group.Nice < -20 || group.Nice > 19 {
+		return fmt.Errorf("directory '%s' nice must be between -20 and 19, got %d", name, group.Nice)
+	}
+
+	switch group.IOPrioClass {
+	case "":
+		if group.IOPrioLevel != nil {
+			return fmt.Errorf("directory '%s' ioprio_level needs ioprio_class best-effort or realtime", name)
+		}
+
+		return nil
+	case IOPrioClassIdle:
+		if group.IOPrioLevel != nil {
+			return fmt.Errorf("directory '%s' ioprio_level doesn't apply to ioprio_class idle", name)
+		}
+	case IOPrioClassBestEffort, IOPrioClassRealtime:
+		if level := group.IOPrioLevel; level != nil && (*level < 0 || *level > 7) {
+			return fmt.Errorf("directory '%s' ioprio_level must be between 0 and 7, got %d", name, *level)
+		}
+	default:
+		return fmt.Errorf("directory '%s' has invalid ioprio_class '%s' (must be idle, best-effort or realtime)", name, group.IOPrioClass)
+	}
+
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("directory '%s' ioprio_class is not available on %s", name, runtime.GOOS)
+	}
+
+	return nil
+}
+
+// GetIOPrioLevel returns the group's priority within its I/O scheduling
+// class, 4 when not set like ionice
+func (d DirectoryGroup) GetIOPrioLevel() int {
+	if d.IOPrioLevel == nil {
+		return 4
+	}
+
+	return *d.IOPrioLevel
+}
+
+func (c *Config) validateCommandLimitsConfig() error {
+	limits := c.CommandLimits
+
+	if limits.Memory < 0 {
+		return fmt.Errorf("memory limit cannot be negative")
+	}
+
+	if limits.Memory > 0 && !filepath.IsAbs(limits.CgroupPath) {
+		return fmt.Errorf("a memory limit needs an absolute cgroup_path (a cgroup v2 directory the exporter can write to), got '%s'", limits.CgroupPath)
+	}
+
+	if limits.CgroupPath != "" && limits.Memory == 0 {
+		return fmt.Errorf("cgroup_path is only used with a memory limit")
+	}
+
+	if limits.IOCgroupPath != "" && !filepath.IsAbs(limits.IOCgroupPath) {
+		return fmt.Errorf("io_cgroup_path must be an absolute path to a cgroup v2 directory the exporter can write to, got '%s'", limits.IOCgroupPath)
+	}
+
+	return nil
+}
+
+func (c *Config) validateKubernetesConfig() error {
+	if !c.Kubernetes.Enabled {
+		return nil
+	}
+
+	if c.Kubernetes.Interval.Duration != 0 && c.Kubernetes.Interval.Seconds() < 1 {
+		return fmt.Errorf("kubernetes interval must be at least 1 second, got %d", c.Kubernetes.Interval.Seconds())
+	}
+
+	if !filepath.IsAbs(c.Kubernetes.PodsDir) {
+		return fmt.Errorf("kubernetes pods_dir must be an absolute path: %s", c.Kubernetes.PodsDir)
+	}
+
+	if c.Kubernetes.ResolveNames && c.Kubernetes.NodeName == "" {
+		return fmt.Errorf("resolve_names needs node_name or the NODE_NAME environment variable (set it from spec.nodeName with the downward API)")
+	}
+
+	return nil
+}
+
+func (c *Config) validateDockerConfig() error {
+	if !c.Docker.Enabled {
+		return nil
+	}
+
+	if c.Docker.Interval.Duration != 0 && c.Docker.Interval.Seconds() < 1 {
+		return fmt.Errorf("docker interval must be at least 1 second, got %d", c.Docker.Interval.Seconds())
+	}
+
+	if !filepath.IsAbs(c.Docker.Socket) {
+		return fmt.Errorf("docker socket must be an absolute path: %s", c.Docker.Socket)
+	}
+
+	return nil
+}
+
+func (c *Config) validateBucketsConfig() error {
+	if !c.Buckets.Enabled {
+		return nil
+	}
+
+	if c.Buckets.Interval.Duration != 0 && c.Buckets.Interval.Seconds() < 1 {
+		return fmt.Errorf("buckets interval must be at least 1 second, got %d", c.Buckets.Interval.Seconds())
+	}
+
+	if len(c.Buckets.Endpoints) == 0 {
+		return fmt.Errorf("at least one bucket endpoint must be configured")
+	}
+
+	names := make(map[string]bool)
 
-	Filesystems []FilesystemConfig        `yaml:"filesystems"`
-	Directories map[string]DirectoryGroup `yaml:"directories"`
+	for _, endpoint := range c.Buckets.Endpoints {
+		if endpoint.Name == "" {
+			return fmt.Errorf("bucket endpoint name cannot be empty")
+		}
+
+		if names[endpoint.Name] {
+			return fmt.Errorf("duplicate bucket endpoint name '%s'", endpoint.Name)
+		}
+
+		names[endpoint.Name] = true
+
+		u, err := url.Parse(endpoint.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("bucket endpoint '%s' url must be an absolute http(s) URL, got '%s'", endpoint.Name, endpoint.URL)
+		}
+
+		if (endpoint.AccessKeyID == "") != (endpoint.SecretAccessKey == "") {
+			return fmt.Errorf("bucket endpoint '%s' needs both access_key_id and secret_access_key, or neither for anonymous access", endpoint.Name)
+		}
+
+		if len(endpoint.Buckets) == 0 {
+			return fmt.Errorf("bucket endpoint '%s' has no buckets configured", endpoint.Name)
+		}
+
+		for _, bucket := range endpoint.Buckets {
+			if bucket == "" || strings.ContainsAny(bucket, "/ ") {
+				return fmt.Errorf("bucket endpoint '%s' has invalid bucket name '%s'", endpoint.Name, bucket)
+			}
+		}
+	}
+
+	return nil
 }
 
-type FilesystemConfig struct {
-	Name       string   `yaml:"name"`
-	MountPoint string   `yaml:"mount_point"`
-	Device     string   `yaml:"device"`
-	Interval   Duration `yaml:"interval"`
-	Timeout    Duration `yaml:"timeout"` // Timeout for df command execution (default: 10% of interval)
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabelNames are the labels of the series custom labels are added
+// to, which custom labels can't replace
+var reservedLabelNames = map[string]bool{
+	"device": true, "mount_point": true, "volume": true, "reason": true,
+	"group": true, "directory": true, "mode": true, "size_mode": true, "subdirectory_level": true,
+	"rank": true, "entry": true, "path": true, "canonical_path": true,
+	"uid": true, "user": true, "gid": true, "owner_group": true, "result": true,
 }
 
-type DirectoryGroup struct {
-	Path               string   `yaml:"path"`
-	SubdirectoryLevels int      `yaml:"subdirectory_levels"`
-	Interval           Duration `yaml:"interval"`
-	Timeout            Duration `yaml:"timeout"` // Timeout for du command execution (default: 5m)
+func validateCustomLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("has invalid label name '%s'", name)
+		}
+
+		if reservedLabelNames[name] {
+			return fmt.Errorf("label '%s' is reserved for the exporter's own labels", name)
+		}
+	}
+
+	return nil
 }
 
-// LoadConfig loads configuration from an optional YAML file, then overlays environment variables.
-func LoadConfig(path string) (*Config, error) {
-	var config Config
+// GetCustomLabelNames returns every static label name configured on a
+// filesystem or directory group, sorted. Series of items that don't set one
+// of them get an empty value, which Prometheus treats as the label being
+// absent.
+func (c *Config) GetCustomLabelNames() []string {
+	seen := make(map[string]bool)
 
-	if path != "" {
-		data, err := os.ReadFile(path)
-		if err == nil {
-			if err := yaml.Unmarshal(data, &config); err != nil {
-				return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	var names []string
+
+	add := func(labels map[string]string) {
+		for name := range labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
 			}
-		} else if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
 		}
 	}
 
-	// Apply generic environment variables (TRACING_ENABLED, PROFILING_ENABLED, etc.)
-	if err := promexporter_config.ApplyGenericEnvVars(&config.BaseConfig); err != nil {
-		return nil, fmt.Errorf("failed to apply generic environment variables: %w", err)
+	for _, fs := range c.Filesystems {
+		add(fs.Labels)
 	}
 
-	// Overlay exporter-specific environment variables
-	if err := applyEnvVars(&config); err != nil {
-		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	for _, group := range c.Directories {
+		add(group.Labels)
 	}
 
-	// Set defaults
-	setDefaults(&config)
+	sort.Strings(names)
 
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	return names
+}
+
+// GetFilesystem returns the filesystem with the name, or nil if there is none
+func (c *Config) GetFilesystem(name string) *FilesystemConfig {
+	for i := range c.Filesystems {
+		if c.Filesystems[i].Name == name {
+			return &c.Filesystems[i]
+		}
 	}
 
-	return &config, nil
+	return nil
 }
 
-// applyEnvVars overlays FILESYSTEM_EXPORTER_* environment variables onto cfg.
-func applyEnvVars(cfg *Config) error {
-	if address := os.Getenv("FILESYSTEM_EXPORTER_SERVER_ADDRESS"); address != "" {
-		if host, portStr, err := net.SplitHostPort(address); err == nil {
-			cfg.Server.Host = host
-
-			if port, err := strconv.Atoi(portStr); err != nil {
-				return fmt.Errorf("invalid server port in address: %w", err)
-			} else {
-				cfg.Server.Port = port
-			}
-		} else {
-			return fmt.Errorf("invalid server address format: %w", err)
+// GetFilesystemLabels returns the static labels of the named filesystem
+func (c *Config) GetFilesystemLabels(name string) map[string]string {
+	for _, fs := range c.Filesystems {
+		if fs.Name == name {
+			return fs.Labels
 		}
 	}
 
-	if level := os.Getenv("FILESYSTEM_EXPORTER_LOGGING_LEVEL"); level != "" {
-		cfg.Logging.Level = level
+	return nil
+}
+
+// GetDirectoryLabels returns the static labels of the named directory group
+func (c *Config) GetDirectoryLabels(name string) map[string]string {
+	group, _ := c.Directory(name)
+
+	return group.Labels
+}
+
+func (c *Config) validateTLSConfig() error {
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+
+	if c.TLS.ClientCAFile != "" && !c.TLS.IsEnabled() {
+		return fmt.Errorf("client_ca_file requires cert_file and key_file")
 	}
 
-	if format := os.Getenv("FILESYSTEM_EXPORTER_LOGGING_FORMAT"); format != "" {
-		cfg.Logging.Format = format
+	if c.TLS.ReloadInterval.Duration < 0 {
+		return fmt.Errorf("reload_interval cannot be negative")
 	}
 
-	if intervalStr := os.Getenv("FILESYSTEM_EXPORTER_METRICS_COLLECTION_DEFAULT_INTERVAL"); intervalStr != "" {
-		if interval, err := time.ParseDuration(intervalStr); err != nil {
-			return fmt.Errorf("invalid metrics default interval: %w", err)
-		} else {
-			cfg.Metrics.Collection.DefaultInterval = promexporter_config.Duration{Duration: interval}
-			cfg.Metrics.Collection.DefaultIntervalSet = true
+	return nil
+}
+
+func (c *Config) validateListenConfig() error {
+	if c.Listen != "" {
+		path, ok := c.GetListenSocket()
+		if !ok || !filepath.IsAbs(path) {
+			return fmt.Errorf("listen must be unix:// followed by an absolute socket path, got '%s'", c.Listen)
 		}
 	}
 
-	// Append directories defined in environment variables
-	cfg.loadDirectoriesFromEnv()
+	if _, err := c.GetSocketMode(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// loadDirectoriesFromEnv loads directory configuration from environment variables
-func (c *Config) loadDirectoriesFromEnv() {
-	// Initialize the map if it's nil
-	if c.Directories == nil {
-		c.Directories = make(map[string]DirectoryGroup)
+// HasScrapeCollection reports whether any enabled filesystem is collected
+// when /metrics is scraped
+func (c *Config) HasScrapeCollection() bool {
+	for _, fs := range c.Filesystems {
+		if fs.IsEnabled() && fs.Collection == CollectionModeOnScrape {
+			return true
+		}
 	}
 
-	// Look for directory environment variables in the format FILESYSTEM_EXPORTER_DIRECTORIES_N_NAME, etc.
-	for i := 0; i < 10; i++ { // Support up to 10 directories
-		nameKey := fmt.Sprintf("FILESYSTEM_EXPORTER_DIRECTORIES_%d_NAME", i)
-		pathKey := fmt.Sprintf("FILESYSTEM_EXPORTER_DIRECTORIES_%d_PATH", i)
-		levelsKey := fmt.Sprintf("FILESYSTEM_EXPORTER_DIRECTORIES_%d_SUBDIRECTORY_LEVELS", i)
+	return false
+}
 
-		name := os.Getenv(nameKey)
-		if name == "" {
-			continue // No more directories
-		}
+// GetListenSocket returns the path of the Unix socket set by server.listen
+func (c *Config) GetListenSocket() (string, bool) {
+	return strings.CutPrefix(c.Listen, "unix://")
+}
 
-		path := os.Getenv(pathKey)
-		if path == "" {
-			continue // Path is required
-		}
+// validateTracingConfig validates tracing.sample_ratio and tracing.scrub_paths
+func (c *Config) validateTracingConfig() error {
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
 
-		levelsStr := os.Getenv(levelsKey)
-		levels := 0
+	switch c.TracingScrubPaths {
+	case "", TracingScrubHash, TracingScrubDrop:
+	default:
+		return fmt.Errorf("scrub_paths must be '%s' or '%s', got '%s'", TracingScrubHash, TracingScrubDrop, c.TracingScrubPaths)
+	}
 
-		if levelsStr != "" {
-			if parsedLevels, err := strconv.Atoi(levelsStr); err == nil {
-				levels = parsedLevels
-			}
+	return nil
+}
+
+// GetSocketMode parses server.socket_mode
+func (c *Config) GetSocketMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("socket_mode must be octal permissions like 0660, got '%s'", c.SocketMode)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+func (c *Config) validateAuthConfig() error {
+	return c.Auth.validate()
+}
+
+func (a AuthConfig) validate() error {
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("bearer_token and bearer_token_file are mutually exclusive")
+	}
+
+	for username := range a.BasicAuthUsers {
+		if username == "" || strings.Contains(username, ":") {
+			return fmt.Errorf("invalid basic auth username '%s'", username)
 		}
+	}
+
+	return nil
+}
+
+func (c *Config) validatePushgatewayConfig() error {
+	if c.Pushgateway.URL == "" {
+		return nil
+	}
 
-		directory := DirectoryGroup{
-			Path:               path,
-			SubdirectoryLevels: levels,
+	u, err := url.Parse(c.Pushgateway.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got '%s'", c.Pushgateway.URL)
+	}
+
+	for name := range c.Pushgateway.Grouping {
+		if name == "job" || !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid grouping label '%s'", name)
 		}
+	}
 
-		c.Directories[name] = directory
-		// Directory loaded via environment variables - logging handled by promexporter
+	if c.Pushgateway.Timeout.Duration < 0 {
+		return fmt.Errorf("timeout cannot be negative")
 	}
 
-	// Total directories loaded - logging handled by promexporter
+	return nil
 }
 
-// setDefaults sets default values for configuration
-func setDefaults(config *Config) {
-	if config.Server.Host == "" {
-		config.Server.Host = "0.0.0.0"
+func (c *Config) validateAlertsConfig() error {
+	if !c.Alerts.Enabled {
+		return nil
 	}
 
-	if config.Server.Port == 0 {
-		config.Server.Port = 8080
+	if len(c.Alerts.Rules) == 0 || len(c.Alerts.Webhooks) == 0 {
+		return fmt.Errorf("at least one rule and one webhook must be configured")
 	}
 
-	if config.Logging.Level == "" {
-		config.Logging.Level = "info"
+	if c.Alerts.RepeatInterval.Duration < 0 {
+		return fmt.Errorf("repeat_interval cannot be negative")
 	}
 
-	if config.Logging.Format == "" {
-		config.Logging.Format = "json"
+	webhooks := make(map[string]bool)
+
+	for _, webhook := range c.Alerts.Webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("every webhook must have a name")
+		}
+
+		if webhooks[webhook.Name] {
+			return fmt.Errorf("duplicate webhook name '%s'", webhook.Name)
+		}
+
+		webhooks[webhook.Name] = true
+
+		switch webhook.Type {
+		case WebhookTypeGeneric, WebhookTypeSlack, WebhookTypeNtfy:
+		default:
+			return fmt.Errorf("webhook '%s' has invalid type '%s' (must be generic, slack or ntfy)", webhook.Name, webhook.Type)
+		}
+
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook '%s' url must be an absolute http(s) URL, got '%s'", webhook.Name, webhook.URL)
+		}
 	}
 
-	if !config.Metrics.Collection.DefaultIntervalSet {
-		config.Metrics.Collection.DefaultInterval = promexporter_config.Duration{Duration: time.Second * 30}
+	rules := make(map[string]bool)
+
+	for _, rule := range c.Alerts.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("every rule must have a name")
+		}
+
+		if rules[rule.Name] {
+			return fmt.Errorf("duplicate rule name '%s'", rule.Name)
+		}
+
+		rules[rule.Name] = true
+
+		conditions := 0
+
+		if rule.UsedRatioAbove != 0 {
+			conditions++
+
+			if rule.UsedRatioAbove < 0 || rule.UsedRatioAbove > 1 {
+				return fmt.Errorf("rule '%s' used_ratio_above must be between 0 and 1, got %g", rule.Name, rule.UsedRatioAbove)
+			}
+
+			if rule.Directory != "" {
+				return fmt.Errorf("rule '%s' uses used_ratio_above, which applies to filesystems, not directory groups", rule.Name)
+			}
+		}
+
+		if rule.SizeAbove != 0 {
+			conditions++
+
+			if rule.SizeAbove < 0 {
+				return fmt.Errorf("rule '%s' size_above cannot be negative", rule.Name)
+			}
+
+			if rule.Filesystem != "" {
+				return fmt.Errorf("rule '%s' uses size_above, which applies to directory groups, not filesystems", rule.Name)
+			}
+		}
+
+		if rule.FailuresAtLeast != 0 {
+			conditions++
+
+			if rule.FailuresAtLeast < 0 {
+				return fmt.Errorf("rule '%s' failures_at_least cannot be negative", rule.Name)
+			}
+		}
+
+		if conditions != 1 {
+			return fmt.Errorf("rule '%s' must set exactly one of used_ratio_above, size_above or failures_at_least", rule.Name)
+		}
+
+		if rule.Filesystem != "" && rule.Directory != "" {
+			return fmt.Errorf("rule '%s' can't be limited to both a filesystem and a directory group", rule.Name)
+		}
+
+		if rule.Filesystem != "" && !c.hasFilesystem(rule.Filesystem) {
+			return fmt.Errorf("rule '%s' references unknown filesystem '%s'", rule.Name, rule.Filesystem)
+		}
+
+		if _, exists := c.Directories[rule.Directory]; rule.Directory != "" && !exists {
+			return fmt.Errorf("rule '%s' references unknown directory group '%s'", rule.Name, rule.Directory)
+		}
+
+		if rule.For < 1 {
+			return fmt.Errorf("rule '%s' for must be at least 1, got %d", rule.Name, rule.For)
+		}
+
+		for _, name := range rule.Webhooks {
+			if !webhooks[name] {
+				return fmt.Errorf("rule '%s' references unknown webhook '%s'", rule.Name, name)
+			}
+		}
 	}
 
-	// No hardcoded defaults - if no filesystems are configured, that's fine
-	// Filesystems are optional
-	// Intervals must be explicitly specified - no defaults
+	return nil
 }
 
-// Validate performs comprehensive validation of the configuration
-func (c *Config) Validate() error {
-	// Validate server configuration
-	if err := c.validateServerConfig(); err != nil {
-		return fmt.Errorf("server config: %w", err)
+func (c *Config) validateProbeConfig() error {
+	if !c.Probe.Enabled {
+		return nil
 	}
 
-	// Validate logging configuration
-	if err := c.validateLoggingConfig(); err != nil {
-		return fmt.Errorf("logging config: %w", err)
+	if len(c.Probe.AllowedPaths) == 0 {
+		return fmt.Errorf("allowed_paths must list at least one path")
 	}
 
-	// Validate metrics configuration
-	if err := c.validateMetricsConfig(); err != nil {
-		return fmt.Errorf("metrics config: %w", err)
-	}
+	for _, path := range c.Probe.AllowedPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("allowed path must be absolute: %s", path)
+		}
 
-	// Validate filesystem configuration
-	if err := c.validateFilesystemsConfig(); err != nil {
-		return fmt.Errorf("filesystems config: %w", err)
+		if err := c.Security.checkAllowed(path); err != nil {
+			return fmt.Errorf("allowed_paths: %w", err)
+		}
 	}
 
-	// Validate directory configuration
-	if err := c.validateDirectoriesConfig(); err != nil {
-		return fmt.Errorf("directories config: %w", err)
+	if c.Probe.Timeout.Duration < 0 {
+		return fmt.Errorf("timeout cannot be negative")
 	}
 
-	// Require at least one filesystem or directory to be configured
-	if len(c.Filesystems) == 0 && len(c.Directories) == 0 {
-		return fmt.Errorf("at least one filesystem or directory must be configured")
+	if c.Probe.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent cannot be negative")
 	}
 
 	return nil
 }
 
-func (c *Config) validateServerConfig() error {
-	if c.Server.Port < 1 || c.Server.Port > 65535 {
-		return fmt.Errorf("port must be between 1 and 65535, got %d", c.Server.Port)
+func (c *Config) validateGroupMetricsConfig() error {
+	for name, auth := range c.GroupMetrics.Auth {
+		if _, ok := c.Directories[name]; !ok && c.GetFilesystem(name) == nil {
+			return fmt.Errorf("auth for '%s', which is neither a directory group nor a filesystem", name)
+		}
+
+		if !auth.IsEnabled() {
+			return fmt.Errorf("auth for '%s' has no credentials", name)
+		}
+
+		if err := auth.validate(); err != nil {
+			return fmt.Errorf("auth for '%s': %w", name, err)
+		}
 	}
 
 	return nil
 }
 
-func (c *Config) validateLoggingConfig() error {
-	validLevels := map[string]bool{
-		"debug": true,
-		"info":  true,
-		"warn":  true,
-		"error": true,
-	}
-	if !validLevels[c.Logging.Level] {
-		return fmt.Errorf("invalid logging level: %s", c.Logging.Level)
+func (c *Config) validateAdaptiveIntervalConfig() error {
+	if !c.AdaptiveInterval.Enabled {
+		return nil
 	}
 
-	validFormats := map[string]bool{
-		"json": true,
-		"text": true,
+	if c.AdaptiveInterval.Threshold <= 0 || c.AdaptiveInterval.Threshold > 1 {
+		return fmt.Errorf("threshold must be above 0 and at most 1, got %g", c.AdaptiveInterval.Threshold)
 	}
-	if !validFormats[c.Logging.Format] {
-		return fmt.Errorf("invalid logging format: %s", c.Logging.Format)
+
+	if c.AdaptiveInterval.MaxMultiplier < 1 {
+		return fmt.Errorf("max_multiplier must be at least 1, got %g", c.AdaptiveInterval.MaxMultiplier)
 	}
 
 	return nil
 }
 
-func (c *Config) validateMetricsConfig() error {
-	if c.Metrics.Collection.DefaultInterval.Seconds() < 1 {
-		return fmt.Errorf("default interval must be at least 1 second, got %d", c.Metrics.Collection.DefaultInterval.Seconds())
+func (c *Config) validateQueueConfig() error {
+	if c.Queue.Size < 1 {
+		return fmt.Errorf("size must be at least 1, got %d", c.Queue.Size)
 	}
 
-	if c.Metrics.Collection.DefaultInterval.Seconds() > 86400 {
-		return fmt.Errorf("default interval must be at most 86400 seconds (24 hours), got %d", c.Metrics.Collection.DefaultInterval.Seconds())
+	switch c.Queue.OverflowPolicy {
+	case QueueOverflowBlock, QueueOverflowDropNewest, QueueOverflowDropOldest, QueueOverflowCoalesce:
+		return nil
 	}
 
-	return nil
+	return fmt.Errorf("invalid overflow_policy '%s' (must be block, drop_newest, drop_oldest or coalesce)", c.Queue.OverflowPolicy)
 }
 
-func (c *Config) validateFilesystemsConfig() error {
-	// Filesystems are optional - if none are configured, that's fine
-	if len(c.Filesystems) == 0 {
+func (c *Config) validateCPUConfig() error {
+	if c.CPU.GOMAXPROCS < 0 {
+		return fmt.Errorf("gomaxprocs cannot be negative, got %d", c.CPU.GOMAXPROCS)
+	}
+
+	if c.CPU.Affinity == "" {
 		return nil
 	}
 
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("affinity is not available on %s", runtime.GOOS)
+	}
+
+	_, err := ParseCPUList(c.CPU.Affinity)
+
+	return err
+}
+
+// hasFilesystem reports whether a filesystem with the given name is configured
+func (c *Config) hasFilesystem(name string) bool {
 	for _, fs := range c.Filesystems {
-		if fs.Name == "" {
-			return fmt.Errorf("filesystem name cannot be empty")
+		if fs.Name == name {
+			return true
 		}
+	}
 
-		if !filepath.IsAbs(fs.MountPoint) {
-			return fmt.Errorf("filesystem mount point must be absolute: %s", fs.MountPoint)
-		}
+	return false
+}
 
-		if fs.Interval.Duration == 0 {
-			return fmt.Errorf("filesystem '%s' must have an interval specified", fs.Name)
-		}
+func (c *Config) validateDigestConfig() error {
+	if !c.Digest.Enabled {
+		return nil
+	}
+
+	if c.Digest.Schedule != DigestScheduleDaily && c.Digest.Schedule != DigestScheduleWeekly {
+		return fmt.Errorf("invalid schedule '%s' (must be daily or weekly)", c.Digest.Schedule)
+	}
+
+	if _, err := time.Parse("15:04", c.Digest.Time); err != nil {
+		return fmt.Errorf("invalid time '%s' (must be HH:MM)", c.Digest.Time)
+	}
+
+	if _, err := c.GetDigestWeekday(); err != nil {
+		return err
+	}
+
+	if c.Digest.TopN < 0 {
+		return fmt.Errorf("top_n cannot be negative, got %d", c.Digest.TopN)
+	}
+
+	smtp := c.Digest.SMTP
+
+	if smtp.Host == "" {
+		return fmt.Errorf("smtp host must be set")
+	}
+
+	if smtp.Port < 1 || smtp.Port > 65535 {
+		return fmt.Errorf("smtp port must be between 1 and 65535, got %d", smtp.Port)
+	}
+
+	switch smtp.TLS {
+	case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		return fmt.Errorf("invalid smtp tls mode '%s' (must be starttls, tls or none)", smtp.TLS)
+	}
+
+	if smtp.From == "" || len(smtp.To) == 0 {
+		return fmt.Errorf("smtp from and at least one to address must be set")
+	}
 
-		if fs.Interval.Seconds() < 1 {
-			return fmt.Errorf("filesystem interval must be at least 1 second, got %d", fs.Interval.Seconds())
+	for _, address := range append([]string{smtp.From}, smtp.To...) {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid email address '%s': %w", address, err)
 		}
 	}
 
 	return nil
 }
 
-func (c *Config) validateDirectoriesConfig() error {
-	for name, group := range c.Directories {
-		if name == "" {
-			return fmt.Errorf("directory group name cannot be empty")
-		}
+func (c *Config) validateMountProbeConfig() error {
+	if !c.MountProbe.Enabled {
+		return nil
+	}
 
-		if !filepath.IsAbs(group.Path) {
-			return fmt.Errorf("directory path must be absolute: %s", group.Path)
-		}
+	if c.MountProbe.Interval.Duration != 0 && c.MountProbe.Interval.Seconds() < 1 {
+		return fmt.Errorf("mount probe interval must be at least 1 second, got %d", c.MountProbe.Interval.Seconds())
+	}
 
-		// Intervals must be explicitly specified - no defaults
-		if group.Interval.Duration == 0 {
-			return fmt.Errorf("directory '%s' must have an interval specified", name)
-		}
+	if c.MountProbe.Timeout.Duration < 0 {
+		return fmt.Errorf("mount probe timeout cannot be negative")
+	}
 
-		if group.Interval.Seconds() < 1 {
-			return fmt.Errorf("directory interval must be at least 1 second, got %d", group.Interval.Seconds())
-		}
+	if c.MountProbe.Timeout.Duration > 0 && c.MountProbe.Timeout.Duration >= c.GetMountProbeInterval() {
+		return fmt.Errorf("mount probe timeout (%s) must be shorter than its interval (%s)", c.MountProbe.Timeout.Duration, c.GetMountProbeInterval())
 	}
 
 	return nil
@@ -331,6 +2522,76 @@ func (c *Config) GetFilesystemInterval(fs FilesystemConfig) int {
 	return fs.Interval.Seconds()
 }
 
+// canonicalizeDirectoryPaths resolves symlinks in directory group roots, so a
+// group configured through a symlink (e.g. /data -> /mnt/pool/data) scans the
+// tree it points at. Paths that can't be resolved yet, such as mounts that
+// aren't up, are kept as configured.
+func (c *Config) canonicalizeDirectoryPaths() {
+	for name, group := range c.Directories {
+		group.CanonicalPath = canonicalPath(group.Path)
+		c.Directories[name] = group
+	}
+}
+
+// canonicalPath resolves symlinks in path, or cleans it if it can't be
+// resolved
+func canonicalPath(path string) string {
+	canonical, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return canonical
+}
+
+// GetDirectoryPath returns the path a directory group is scanned at: its
+// canonical path when known, otherwise the path as configured
+func (c *Config) GetDirectoryPath(group DirectoryGroup) string {
+	if group.CanonicalPath != "" {
+		return group.CanonicalPath
+	}
+
+	return group.Path
+}
+
+// GetDirectoryLabel returns the directory label for a path collected by a
+// group, in the form set by its label_path
+func (c *Config) GetDirectoryLabel(group DirectoryGroup, path string) string {
+	switch group.LabelPath {
+	case DirectoryLabelRelative:
+		if rel, err := filepath.Rel(c.GetDirectoryPath(group), path); err == nil {
+			return rel
+		}
+	case DirectoryLabelBasename:
+		return filepath.Base(path)
+	}
+
+	return path
+}
+
+// DuplicateDirectoryGroups returns the sorted names of directory groups that
+// resolve to the same tree, keyed by canonical path. Such groups export the
+// same sizes twice under different group labels.
+func (c *Config) DuplicateDirectoryGroups() map[string][]string {
+	byPath := make(map[string][]string)
+
+	for name, group := range c.Directories {
+		path := c.GetDirectoryPath(group)
+		byPath[path] = append(byPath[path], name)
+	}
+
+	duplicates := make(map[string][]string)
+
+	for path, names := range byPath {
+		if len(names) > 1 {
+			sort.Strings(names)
+			duplicates[path] = names
+		}
+	}
+
+	return duplicates
+}
+
 // GetDirectoryInterval returns the interval for a directory group
 func (c *Config) GetDirectoryInterval(group DirectoryGroup) int {
 	if group.Interval.Duration == 0 {
@@ -344,7 +2605,7 @@ func (c *Config) GetDirectoryInterval(group DirectoryGroup) int {
 // GetDirectoryIntervalByName returns the interval for a directory group by name
 // This ensures we always get the interval from the config map, not a stale copy
 func (c *Config) GetDirectoryIntervalByName(groupName string) int {
-	if group, exists := c.Directories[groupName]; exists {
+	if group, exists := c.Directory(groupName); exists {
 		if group.Interval.Duration == 0 {
 			// This should not happen if validation passed, but handle gracefully
 			return 0
@@ -371,6 +2632,16 @@ func (c *Config) GetDirectoryTimeout(group DirectoryGroup) time.Duration {
 	return intervalDuration / 10
 }
 
+// GetDirectorySizeMode returns what a directory group's sizes measure,
+// disk_usage unless the group sets size_mode
+func (c *Config) GetDirectorySizeMode(name string) string {
+	if group, _ := c.Directory(name); group.SizeMode != "" {
+		return group.SizeMode
+	}
+
+	return SizeModeDiskUsage
+}
+
 // GetFilesystemTimeout returns the timeout for df command execution for a filesystem
 // Defaults to 10% of interval if not specified
 func (c *Config) GetFilesystemTimeout(fs FilesystemConfig) time.Duration {
@@ -385,6 +2656,134 @@ func (c *Config) GetFilesystemTimeout(fs FilesystemConfig) time.Duration {
 	return intervalDuration / 10
 }
 
+// GetZFSInterval returns the ZFS collection interval, falling back to the default interval
+func (c *Config) GetZFSInterval() time.Duration {
+	if c.ZFS.Interval.Duration > 0 {
+		return c.ZFS.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetZFSTimeout returns the timeout for zfs list, defaulting to 10% of the interval
+func (c *Config) GetZFSTimeout() time.Duration {
+	if c.ZFS.Timeout.Duration > 0 {
+		return c.ZFS.Timeout.Duration
+	}
+
+	return c.GetZFSInterval() / 10
+}
+
+// GetBtrfsInterval returns the btrfs collection interval, falling back to the default interval
+func (c *Config) GetBtrfsInterval() time.Duration {
+	if c.Btrfs.Interval.Duration > 0 {
+		return c.Btrfs.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetBtrfsTimeout returns the timeout for each btrfs command, defaulting to 10% of the interval
+func (c *Config) GetBtrfsTimeout() time.Duration {
+	if c.Btrfs.Timeout.Duration > 0 {
+		return c.Btrfs.Timeout.Duration
+	}
+
+	return c.GetBtrfsInterval() / 10
+}
+
+// GetQuotasInterval returns the quota collection interval, falling back to the default interval
+func (c *Config) GetQuotasInterval() time.Duration {
+	if c.Quotas.Interval.Duration > 0 {
+		return c.Quotas.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetKubernetesInterval returns the PVC collection interval, falling back to the default interval
+func (c *Config) GetKubernetesInterval() time.Duration {
+	if c.Kubernetes.Interval.Duration > 0 {
+		return c.Kubernetes.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetDockerInterval returns the Docker collection interval, falling back to the default interval
+func (c *Config) GetDockerInterval() time.Duration {
+	if c.Docker.Interval.Duration > 0 {
+		return c.Docker.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetDockerTimeout returns the timeout for the /system/df request, defaulting to 10% of the interval
+func (c *Config) GetDockerTimeout() time.Duration {
+	if c.Docker.Timeout.Duration > 0 {
+		return c.Docker.Timeout.Duration
+	}
+
+	return c.GetDockerInterval() / 10
+}
+
+// GetBucketsInterval returns the bucket collection interval, falling back to the default interval
+func (c *Config) GetBucketsInterval() time.Duration {
+	if c.Buckets.Interval.Duration > 0 {
+		return c.Buckets.Interval.Duration
+	}
+
+	return c.Metrics.Collection.DefaultInterval.Duration
+}
+
+// GetBucketsTimeout returns the timeout for listing one bucket, defaulting to 10% of the interval
+func (c *Config) GetBucketsTimeout() time.Duration {
+	if c.Buckets.Timeout.Duration > 0 {
+		return c.Buckets.Timeout.Duration
+	}
+
+	return c.GetBucketsInterval() / 10
+}
+
+// GetMountProbeInterval returns the network mount probe interval, defaulting to 30 seconds
+func (c *Config) GetMountProbeInterval() time.Duration {
+	if c.MountProbe.Interval.Duration > 0 {
+		return c.MountProbe.Interval.Duration
+	}
+
+	return 30 * time.Second
+}
+
+// GetMountProbeTimeout returns the deadline for each probe, defaulting to 5 seconds
+func (c *Config) GetMountProbeTimeout() time.Duration {
+	if c.MountProbe.Timeout.Duration > 0 {
+		return c.MountProbe.Timeout.Duration
+	}
+
+	return 5 * time.Second
+}
+
+// GetDigestWeekday returns the day weekly digests are sent on
+func (c *Config) GetDigestWeekday() (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), c.Digest.Weekday) {
+			return day, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid weekday '%s'", c.Digest.Weekday)
+}
+
+// GetMountProbeFSTypes returns the mount types treated as network mounts
+func (c *Config) GetMountProbeFSTypes() []string {
+	if len(c.MountProbe.FSTypes) > 0 {
+		return c.MountProbe.FSTypes
+	}
+
+	return DefaultMountProbeFSTypes
+}
+
 // GetDisplayConfig returns configuration data safe for display
 // Overrides BaseConfig to include filesystem and directory configuration
 func (c *Config) GetDisplayConfig() map[string]interface{} {
@@ -393,13 +2792,31 @@ func (c *Config) GetDisplayConfig() map[string]interface{} {
 
 	// Add filesystem configuration
 	if len(c.Filesystems) > 0 {
-		filesystems := make([]map[string]string, len(c.Filesystems))
+		filesystems := make([]map[string]interface{}, len(c.Filesystems))
 		for i, fs := range c.Filesystems {
-			filesystems[i] = map[string]string{
+			filesystems[i] = map[string]interface{}{
 				"name":        fs.Name,
 				"mount_point": fs.MountPoint,
 				"device":      fs.Device,
 				"interval":    fs.Interval.String(),
+				"timeout":     c.GetFilesystemTimeout(fs).String(),
+				"mode":        fs.Mode,
+			}
+
+			if fs.Quota > 0 {
+				filesystems[i]["quota_bytes"] = fs.Quota.Bytes()
+			}
+
+			if !fs.IsEnabled() {
+				filesystems[i]["enabled"] = false
+			}
+
+			if fs.Priority != 0 {
+				filesystems[i]["priority"] = fs.Priority
+			}
+
+			if retry := c.GetFilesystemRetry(fs); retry.MaxAttempts > 1 {
+				filesystems[i]["retry"] = retry.String()
 			}
 		}
 
@@ -409,13 +2826,110 @@ func (c *Config) GetDisplayConfig() map[string]interface{} {
 	}
 
 	// Add directory configuration
-	if len(c.Directories) > 0 {
+	if groups := c.DirectoryGroups(); len(groups) > 0 {
 		directories := make(map[string]map[string]interface{})
-		for name, dir := range c.Directories {
+		for name, dir := range groups {
 			directories[name] = map[string]interface{}{
 				"path":                dir.Path,
 				"subdirectory_levels": dir.SubdirectoryLevels,
 				"interval":            dir.Interval.String(),
+				"timeout":             c.GetDirectoryTimeout(dir).String(),
+				"mode":                dir.Mode,
+				"size_mode":           dir.SizeMode,
+			}
+
+			if len(dir.Command) > 0 {
+				directories[name]["command"] = dir.Command
+			}
+
+			if !dir.IsEnabled() {
+				directories[name]["enabled"] = false
+			}
+
+			if len(dir.BlackoutWindows) > 0 {
+				directories[name]["blackout_windows"] = dir.BlackoutWindows
+			}
+
+			if dir.ConcurrencyGroup != "" {
+				directories[name]["concurrency_group"] = dir.ConcurrencyGroup
+			}
+
+			if dir.Priority != 0 {
+				directories[name]["priority"] = dir.Priority
+			}
+
+			if dir.SmoothingAlpha > 0 {
+				directories[name]["smoothing_alpha"] = dir.SmoothingAlpha
+			}
+
+			if dir.TopN > 0 {
+				directories[name]["top_n"] = dir.TopN
+			}
+
+			if dir.FollowSymlinks != "" && dir.FollowSymlinks != FollowSymlinksNever {
+				directories[name]["follow_symlinks"] = dir.FollowSymlinks
+			}
+
+			if dir.NcduExport != "" {
+				directories[name]["ncdu_export"] = dir.NcduExport
+			}
+
+			if dir.HardlinksTotal {
+				directories[name]["hardlinks_total"] = true
+			}
+
+			if dir.IOPrioClass != "" {
+				directories[name]["ioprio_class"] = dir.IOPrioClass
+
+				if dir.IOPrioClass != IOPrioClassIdle {
+					directories[name]["ioprio_level"] = dir.GetIOPrioLevel()
+				}
+			}
+
+			if dir.Nice != 0 {
+				directories[name]["nice"] = dir.Nice
+			}
+
+			if dir.RunAsUser != "" {
+				directories[name]["run_as_user"] = dir.RunAsUser
+				directories[name]["run_as_group"] = dir.RunAsGroup
+			}
+
+			if dir.IOLimit != (IOLimitConfig{}) {
+				directories[name]["io_limit"] = map[string]interface{}{
+					"files_per_second":      dir.IOLimit.FilesPerSecond,
+					"read_bytes_per_second": dir.IOLimit.ReadBytesPerSecond.Bytes(),
+					"read_iops":             dir.IOLimit.ReadIOPS,
+				}
+			}
+
+			if dir.GroupByOwner {
+				directories[name]["group_by_owner"] = true
+			}
+
+			if dir.DropPageCache {
+				directories[name]["drop_page_cache"] = true
+			}
+
+			if dir.Sandbox {
+				directories[name]["sandbox"] = true
+			}
+
+			if retry := c.GetDirectoryRetry(dir); retry.MaxAttempts > 1 {
+				directories[name]["retry"] = retry.String()
+			}
+
+			if dir.Mode == DirectoryModeSample {
+				directories[name]["sample_fraction"] = dir.SampleFraction
+				directories[name]["full_scan_interval"] = dir.FullScanInterval.String()
+			}
+
+			if dir.Quota > 0 {
+				directories[name]["quota_bytes"] = dir.Quota.Bytes()
+			}
+
+			if dir.ProjectID > 0 {
+				directories[name]["project_id"] = dir.ProjectID
 			}
 		}
 
@@ -424,6 +2938,243 @@ func (c *Config) GetDisplayConfig() map[string]interface{} {
 		config["Directories"] = "None configured"
 	}
 
+	if c.ZFS.Enabled {
+		zfs := map[string]interface{}{
+			"interval": c.GetZFSInterval().String(),
+		}
+
+		if len(c.ZFS.Datasets) > 0 {
+			zfs["datasets"] = strings.Join(c.ZFS.Datasets, ", ")
+		}
+
+		config["ZFS"] = zfs
+	}
+
+	if c.Quotas.Enabled {
+		mountPoints := make([]string, len(c.Quotas.Filesystems))
+		for i, fs := range c.Quotas.Filesystems {
+			mountPoints[i] = fs.MountPoint
+		}
+
+		config["Quotas"] = map[string]interface{}{
+			"interval":     c.GetQuotasInterval().String(),
+			"mount_points": strings.Join(mountPoints, ", "),
+		}
+	}
+
+	if c.CommandLimits.IsEnabled() {
+		limits := map[string]interface{}{}
+
+		if c.CommandLimits.CPUSeconds > 0 {
+			limits["cpu_seconds"] = c.CommandLimits.CPUSeconds
+		}
+
+		if c.CommandLimits.MaxOpenFiles > 0 {
+			limits["max_open_files"] = c.CommandLimits.MaxOpenFiles
+		}
+
+		if c.CommandLimits.Memory > 0 {
+			limits["memory_bytes"] = c.CommandLimits.Memory.Bytes()
+			limits["cgroup_path"] = c.CommandLimits.CgroupPath
+		}
+
+		if c.CommandLimits.IOCgroupPath != "" {
+			limits["io_cgroup_path"] = c.CommandLimits.IOCgroupPath
+		}
+
+		config["CommandLimits"] = limits
+	}
+
+	if len(c.Security.AllowedRoots) > 0 {
+		config["AllowedRoots"] = strings.Join(c.Security.AllowedRoots, ", ")
+	}
+
+	if c.Security.RunAsUser != "" {
+		config["RunAs"] = map[string]interface{}{
+			"user":  c.Security.RunAsUser,
+			"group": c.Security.RunAsGroup,
+		}
+	}
+
+	if c.Security.AuditLog.IsEnabled() {
+		config["AuditLog"] = map[string]interface{}{
+			"file":   c.Security.AuditLog.File,
+			"syslog": c.Security.AuditLog.Syslog,
+		}
+	}
+
+	if c.CPU != (CPUConfig{}) {
+		config["CPU"] = map[string]interface{}{
+			"affinity":   c.CPU.Affinity,
+			"gomaxprocs": c.CPU.GOMAXPROCS,
+		}
+	}
+
+	if c.Kubernetes.Enabled {
+		config["Kubernetes"] = map[string]interface{}{
+			"interval":      c.GetKubernetesInterval().String(),
+			"pods_dir":      c.Kubernetes.PodsDir,
+			"resolve_names": c.Kubernetes.ResolveNames,
+			"node_name":     c.Kubernetes.NodeName,
+		}
+	}
+
+	if c.Docker.Enabled {
+		config["Docker"] = map[string]interface{}{
+			"interval": c.GetDockerInterval().String(),
+			"socket":   c.Docker.Socket,
+		}
+	}
+
+	if c.Buckets.Enabled {
+		endpoints := make([]string, len(c.Buckets.Endpoints))
+		for i, endpoint := range c.Buckets.Endpoints {
+			// Credentials are deliberately left out
+			endpoints[i] = fmt.Sprintf("%s (%s: %s)", endpoint.Name, endpoint.URL, strings.Join(endpoint.Buckets, ", "))
+		}
+
+		config["Buckets"] = map[string]interface{}{
+			"interval":  c.GetBucketsInterval().String(),
+			"endpoints": strings.Join(endpoints, "; "),
+		}
+	}
+
+	if c.Digest.Enabled {
+		schedule := c.Digest.Schedule + " at " + c.Digest.Time
+		if c.Digest.Schedule == DigestScheduleWeekly {
+			schedule = c.Digest.Schedule + " on " + c.Digest.Weekday + " at " + c.Digest.Time
+		}
+
+		// The password is deliberately left out
+		config["Digest"] = map[string]interface{}{
+			"schedule": schedule,
+			"smtp":     net.JoinHostPort(c.Digest.SMTP.Host, strconv.Itoa(c.Digest.SMTP.Port)) + " (" + c.Digest.SMTP.TLS + ")",
+			"to":       strings.Join(c.Digest.SMTP.To, ", "),
+		}
+	}
+
+	if c.Alerts.Enabled {
+		rules := make([]string, 0, len(c.Alerts.Rules))
+		for _, rule := range c.Alerts.Rules {
+			rules = append(rules, rule.Name)
+		}
+
+		webhooks := make([]string, 0, len(c.Alerts.Webhooks))
+		for _, webhook := range c.Alerts.Webhooks {
+			webhooks = append(webhooks, webhook.Name+" ("+webhook.Type+")")
+		}
+
+		// Webhook URLs are left out as they often embed tokens
+		config["Alerts"] = map[string]interface{}{
+			"rules":           strings.Join(rules, ", "),
+			"webhooks":        strings.Join(webhooks, ", "),
+			"repeat_interval": c.Alerts.RepeatInterval.String(),
+		}
+	}
+
+	if c.Probe.Enabled {
+		config["Probe"] = map[string]interface{}{
+			"allowed_paths":  strings.Join(c.Probe.AllowedPaths, ", "),
+			"timeout":        c.Probe.Timeout.String(),
+			"max_concurrent": c.Probe.MaxConcurrent,
+		}
+	}
+
+	if c.GroupMetrics.Enabled {
+		// Only which items have their own credentials, never the credentials
+		names := make([]string, 0, len(c.GroupMetrics.Auth))
+		for name := range c.GroupMetrics.Auth {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		config["GroupMetrics"] = map[string]interface{}{
+			"auth": strings.Join(names, ", "),
+		}
+	}
+
+	if c.Health.Enabled {
+		config["Health"] = map[string]interface{}{
+			"failure_threshold":  c.Health.FailureThreshold,
+			"stale_intervals":    c.Health.StaleIntervals,
+			"fail_when_degraded": c.Health.FailWhenDegraded,
+		}
+	}
+
+	if c.API.Enabled {
+		config["Activity"] = "Live job events from /api/v1/events"
+	}
+
+	if len(c.LogLevels) > 0 {
+		components := make([]string, 0, len(c.LogLevels))
+		for component, level := range c.LogLevels {
+			components = append(components, component+"="+level)
+		}
+
+		sort.Strings(components)
+
+		config["Log Levels"] = strings.Join(components, ", ")
+	}
+
+	if c.LogRateLimit.Burst > 0 {
+		config["Log Rate Limit"] = fmt.Sprintf("%d per %s", c.LogRateLimit.Burst, c.LogRateLimit.Interval.Duration)
+	}
+
+	if c.Tracing.IsEnabled() {
+		config["Tracing Sample Ratio"] = c.TracingSampleRatio
+		if c.TracingScrubPaths != "" {
+			config["Tracing Scrub Paths"] = c.TracingScrubPaths
+		}
+	}
+
+	if c.Listen != "" {
+		config["Listen"] = map[string]interface{}{
+			"socket":      c.Listen,
+			"socket_mode": c.SocketMode,
+		}
+	}
+
+	if c.TLS.IsEnabled() {
+		config["TLS"] = map[string]interface{}{
+			"cert_file":       c.TLS.CertFile,
+			"client_ca_file":  c.TLS.ClientCAFile,
+			"reload_interval": c.TLS.ReloadInterval.String(),
+		}
+	}
+
+	if c.Auth.IsEnabled() {
+		// Only which methods are in use, never the credentials
+		config["Auth"] = map[string]interface{}{
+			"basic_auth":   len(c.Auth.BasicAuthUsers) > 0 || c.Auth.BasicAuthUsersFile != "",
+			"bearer_token": c.Auth.BearerToken != "" || c.Auth.BearerTokenFile != "",
+		}
+	}
+
+	if c.Pushgateway.URL != "" {
+		// The password is deliberately left out
+		config["Pushgateway"] = map[string]interface{}{
+			"url":      c.Pushgateway.URL,
+			"job":      c.Pushgateway.Job,
+			"grouping": c.Pushgateway.Grouping,
+		}
+	}
+
+	if c.MountProbe.Enabled {
+		config["MountProbe"] = map[string]interface{}{
+			"interval": c.GetMountProbeInterval().String(),
+			"timeout":  c.GetMountProbeTimeout().String(),
+			"fs_types": strings.Join(c.GetMountProbeFSTypes(), ", "),
+		}
+	}
+
+	if c.Btrfs.Enabled {
+		config["Btrfs"] = map[string]interface{}{
+			"interval":     c.GetBtrfsInterval().String(),
+			"mount_points": strings.Join(c.Btrfs.MountPoints, ", "),
+		}
+	}
+
 	return config
 }
 
@@ -438,6 +3189,10 @@ func (c *Config) RenderConfigHTML(key string, value interface{}) (string, bool)
 		// Load and render the filesystems template
 		html, ok := c.renderTemplate("filesystems", value)
 		return html, ok
+	case "Activity":
+		// A feed of the job events streamed to the dashboard
+		html, ok := c.renderTemplate("activity", value)
+		return html, ok
 	}
 
 	return "", false
@@ -496,7 +3251,7 @@ func (c *Config) renderDirectoriesHTML(data interface{}) string {
 
 // renderFilesystemsHTML renders the filesystems configuration as HTML
 func (c *Config) renderFilesystemsHTML(data interface{}) string {
-	filesystems, ok := data.([]map[string]string)
+	filesystems, ok := data.([]map[string]interface{})
 	if !ok {
 		return ""
 	}
@@ -510,7 +3265,7 @@ func (c *Config) renderFilesystemsHTML(data interface{}) string {
 		for k, v := range item {
 			html += `<div class="object-item">`
 			html += `<span class="object-key">` + k + `:</span>`
-			html += `<span class="object-value">` + v + `</span>`
+			html += `<span class="object-value">` + fmt.Sprint(v) + `</span>`
 			html += `</div>`
 		}
 
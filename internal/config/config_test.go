@@ -0,0 +1,2356 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestConfig writes yaml to a temp file and loads it through LoadConfig
+func loadTestConfig(t *testing.T, yaml string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	return LoadConfig(path)
+}
+
+func TestLoadConfig_NoExecDefaultsToNativeModes(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+security:
+  no_exec: true
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mode := cfg.Filesystems[0].Mode; mode != FilesystemModeStatfs {
+		t.Errorf("expected filesystem mode %s, got %s", FilesystemModeStatfs, mode)
+	}
+
+	if mode := cfg.Directories["home"].Mode; mode != DirectoryModeWalk {
+		t.Errorf("expected directory mode %s, got %s", DirectoryModeWalk, mode)
+	}
+}
+
+func TestLoadConfig_NoExecRejectsExecModes(t *testing.T) {
+	_, err := loadTestConfig(t, `
+security:
+  no_exec: true
+directories:
+  home:
+    path: /home
+    interval: 5m
+    mode: du
+`)
+	if err == nil || !strings.Contains(err.Error(), "no_exec") {
+		t.Fatalf("expected no_exec validation error, got %v", err)
+	}
+}
+
+func TestLoadConfig_DefaultModes(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mode := cfg.Filesystems[0].Mode; mode != FilesystemModeDf {
+		t.Errorf("expected filesystem mode %s, got %s", FilesystemModeDf, mode)
+	}
+
+	if mode := cfg.Directories["home"].Mode; mode != DirectoryModeDu {
+		t.Errorf("expected directory mode %s, got %s", DirectoryModeDu, mode)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ByteSize
+	}{
+		{"1024", 1024},
+		{"500GiB", 500 << 30},
+		{"500gib", 500 << 30},
+		{"1.5TB", 1500000000000},
+		{"200G", 200 << 30},
+		{"10 MB", 10000000},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.input)
+		if err != nil {
+			t.Errorf("ParseByteSize(%q) returned error: %v", tt.input, err)
+			continue
+		}
+
+		if got != tt.expected {
+			t.Errorf("ParseByteSize(%q) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "GiB", "10XB", "-5G"} {
+		if _, err := ParseByteSize(input); err == nil {
+			t.Errorf("ParseByteSize(%q) expected error", input)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := map[ByteSize]string{
+		512:         "512B",
+		1536:        "1.5KiB",
+		500 << 30:   "500.0GiB",
+		3 << 40:     "3.0TiB",
+		-(10 << 20): "-10.0MiB",
+		2048 << 50:  "2048.0PiB",
+	}
+
+	for size, expected := range tests {
+		if got := size.String(); got != expected {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", int64(size), got, expected)
+		}
+	}
+}
+
+func TestLoadConfig_Quota(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+    quota_bytes: 1000
+directories:
+  home:
+    path: /home
+    interval: 5m
+    quota: 2GiB
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if quota := cfg.Filesystems[0].Quota; quota != 1000 {
+		t.Errorf("expected filesystem quota 1000, got %d", quota)
+	}
+
+	if quota := cfg.Directories["home"].Quota; quota != 2<<30 {
+		t.Errorf("expected directory quota %d, got %d", int64(2<<30), quota)
+	}
+
+	display := cfg.GetDisplayConfig()
+
+	if quota := display["Filesystems"].([]map[string]interface{})[0]["quota_bytes"]; quota != int64(1000) {
+		t.Errorf("expected the filesystem's displayed quota_bytes to be int64 1000, got %#v", quota)
+	}
+
+	if quota := display["Directories"].(map[string]map[string]interface{})["home"]["quota_bytes"]; quota != int64(2<<30) {
+		t.Errorf("expected the directory's displayed quota_bytes to be int64 %d, got %#v", int64(2<<30), quota)
+	}
+}
+
+func TestLoadConfig_Templates(t *testing.T) {
+	t.Setenv("FILESYSTEM_EXPORTER_TEST_SITE", "lon1")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("failed to get hostname: %v", err)
+	}
+
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: "{{ .Env.FILESYSTEM_EXPORTER_TEST_SITE }}-data"
+    mount_point: /srv/{{ .Hostname }}
+    interval: 1m
+directories:
+  "{{ .Env.FILESYSTEM_EXPORTER_TEST_SITE }}-home":
+    path: /home/{{ .Hostname }}
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if name := cfg.Filesystems[0].Name; name != "lon1-data" {
+		t.Errorf("expected filesystem name lon1-data, got %s", name)
+	}
+
+	if mount := cfg.Filesystems[0].MountPoint; mount != "/srv/"+hostname {
+		t.Errorf("expected mount point /srv/%s, got %s", hostname, mount)
+	}
+
+	group, ok := cfg.Directories["lon1-home"]
+	if !ok {
+		t.Fatalf("expected directory lon1-home, got %v", cfg.Directories)
+	}
+
+	if group.Path != "/home/"+hostname {
+		t.Errorf("expected path /home/%s, got %s", hostname, group.Path)
+	}
+}
+
+func TestLoadConfig_TemplateMissingEnv(t *testing.T) {
+	_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home/{{ .Env.FILESYSTEM_EXPORTER_TEST_UNSET }}
+    interval: 5m
+`)
+	if err == nil {
+		t.Fatal("expected error for unset environment variable")
+	}
+}
+
+func TestLoadConfig_QuotasDefaultTypes(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+quotas:
+  enabled: true
+  filesystems:
+    - name: home
+      mount_point: /home
+    - name: scratch
+      mount_point: /scratch
+      types: [group]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if types := cfg.Quotas.Filesystems[0].Types; len(types) != 2 {
+		t.Errorf("expected user and group quota types by default, got %v", types)
+	}
+
+	if types := cfg.Quotas.Filesystems[1].Types; len(types) != 1 || types[0] != QuotaTypeGroup {
+		t.Errorf("expected only group quotas, got %v", types)
+	}
+}
+
+func TestLoadConfig_QuotasInvalidType(t *testing.T) {
+	_, err := loadTestConfig(t, `
+quotas:
+  enabled: true
+  filesystems:
+    - name: home
+      mount_point: /home
+      types: [everyone]
+`)
+	if err == nil || !strings.Contains(err.Error(), "invalid type") {
+		t.Fatalf("expected invalid type error, got %v", err)
+	}
+}
+
+func TestLoadConfig_CoveredByValidation(t *testing.T) {
+	tests := map[string]string{
+		"unknown directory group": `
+filesystems:
+  - name: data
+    mount_point: /data
+    interval: 1m
+    covered_by: [missing]
+`,
+		"is outside": `
+filesystems:
+  - name: data
+    mount_point: /data
+    interval: 1m
+    covered_by: [home]
+directories:
+  home:
+    path: /home
+    interval: 5m
+`,
+	}
+
+	for expected, yaml := range tests {
+		_, err := loadTestConfig(t, yaml)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got %v", expected, err)
+		}
+	}
+
+	if _, err := loadTestConfig(t, `
+filesystems:
+  - name: data
+    mount_point: /data
+    interval: 1m
+    covered_by: [projects]
+directories:
+  projects:
+    path: /data/projects
+    interval: 5m
+`); err != nil {
+		t.Errorf("unexpected error for valid covered_by: %v", err)
+	}
+}
+
+func TestLoadConfig_MountProbeDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: nas
+    mount_point: /mnt/nas
+    interval: 1m
+mount_probe:
+  enabled: true
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.GetMountProbeInterval() != 30*time.Second {
+		t.Errorf("expected default interval 30s, got %s", cfg.GetMountProbeInterval())
+	}
+
+	if cfg.GetMountProbeTimeout() != 5*time.Second {
+		t.Errorf("expected default timeout 5s, got %s", cfg.GetMountProbeTimeout())
+	}
+
+	if !reflect.DeepEqual(cfg.GetMountProbeFSTypes(), DefaultMountProbeFSTypes) {
+		t.Errorf("expected default fs types, got %v", cfg.GetMountProbeFSTypes())
+	}
+
+	_, err = loadTestConfig(t, `
+filesystems:
+  - name: nas
+    mount_point: /mnt/nas
+    interval: 1m
+mount_probe:
+  enabled: true
+  interval: 10s
+  timeout: 10s
+`)
+	if err == nil || !strings.Contains(err.Error(), "must be shorter than its interval") {
+		t.Errorf("expected timeout validation error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Buckets(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "from-env")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-from-env")
+
+	cfg, err := loadTestConfig(t, `
+buckets:
+  enabled: true
+  endpoints:
+    - name: minio
+      url: http://minio:9000
+      buckets: [media]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	endpoint := cfg.Buckets.Endpoints[0]
+	if endpoint.Region != "us-east-1" || endpoint.AccessKeyID != "from-env" || endpoint.SecretAccessKey != "secret-from-env" {
+		t.Errorf("unexpected endpoint defaults: %+v", endpoint)
+	}
+
+	if display := fmt.Sprint(cfg.GetDisplayConfig()["Buckets"]); strings.Contains(display, "secret-from-env") {
+		t.Errorf("display config leaks the secret key: %s", display)
+	}
+
+	tests := map[string]string{
+		"absolute http(s) URL": `
+buckets:
+  enabled: true
+  endpoints:
+    - name: minio
+      url: minio:9000
+      buckets: [media]
+`,
+		"has no buckets": `
+buckets:
+  enabled: true
+  endpoints:
+    - name: minio
+      url: http://minio:9000
+`,
+		"needs both": `
+buckets:
+  enabled: true
+  endpoints:
+    - name: minio
+      url: http://minio:9000
+      access_key_id: only-id
+      buckets: [media]
+`,
+	}
+
+	for expected, yaml := range tests {
+		_, err := loadTestConfig(t, yaml)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestLoadConfig_CommandLimits(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+command_limits:
+  cpu_seconds: 600
+  memory: 512MiB
+  cgroup_path: /sys/fs/cgroup/filesystem-exporter/commands
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.CommandLimits.IsEnabled() || cfg.CommandLimits.Memory.Bytes() != 512<<20 {
+		t.Errorf("unexpected command limits: %+v", cfg.CommandLimits)
+	}
+
+	_, err = loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+command_limits:
+  memory: 512MiB
+`)
+	if err == nil || !strings.Contains(err.Error(), "cgroup_path") {
+		t.Errorf("expected cgroup_path error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Kubernetes(t *testing.T) {
+	t.Setenv("NODE_NAME", "worker-1")
+
+	cfg, err := loadTestConfig(t, `
+kubernetes:
+  enabled: true
+  resolve_names: true
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Kubernetes.PodsDir != "/var/lib/kubelet/pods" || cfg.Kubernetes.NodeName != "worker-1" {
+		t.Errorf("unexpected kubernetes defaults: %+v", cfg.Kubernetes)
+	}
+
+	t.Setenv("NODE_NAME", "")
+
+	_, err = loadTestConfig(t, `
+kubernetes:
+  enabled: true
+  resolve_names: true
+`)
+	if err == nil || !strings.Contains(err.Error(), "node_name") {
+		t.Errorf("expected node_name error, got %v", err)
+	}
+}
+
+func TestLoadConfig_APITimeouts(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+api:
+  enabled: true
+  write_timeout: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.API.WriteTimeout.Duration != 5*time.Minute || cfg.API.ReadTimeout.Duration != 30*time.Second || cfg.API.ShutdownGrace.Duration != 10*time.Second {
+		t.Errorf("unexpected api timeouts: %+v", cfg.API)
+	}
+
+	_, err = loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+api:
+  enabled: true
+  shutdown_grace: -1s
+`)
+	if err == nil || !strings.Contains(err.Error(), "shutdown_grace") {
+		t.Errorf("expected shutdown_grace error, got %v", err)
+	}
+}
+
+func TestLoadConfig_ServerTimeouts(t *testing.T) {
+	t.Setenv("FILESYSTEM_EXPORTER_SERVER_IDLE_TIMEOUT", "90s")
+
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+server:
+  write_timeout: 5m
+  shutdown_grace: 30s
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeouts := cfg.ServerTimeouts
+	if timeouts.WriteTimeout.Duration != 5*time.Minute || timeouts.ShutdownGrace.Duration != 30*time.Second || timeouts.IdleTimeout.Duration != 90*time.Second || timeouts.ReadTimeout.Duration != 30*time.Second {
+		t.Errorf("unexpected server timeouts: %+v", timeouts)
+	}
+
+	_, err = loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+server:
+  read_timeout: -1s
+`)
+	if err == nil || !strings.Contains(err.Error(), "read_timeout") {
+		t.Errorf("expected read_timeout error, got %v", err)
+	}
+}
+
+func TestLoadConfig_CanonicalDirectoryPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	target := filepath.Join(dir, "pool", "data")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "data")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	// TempDir itself may sit behind a symlink (e.g. /tmp on macOS)
+	canonicalTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadTestConfig(t, fmt.Sprintf(`
+directories:
+  linked:
+    path: %s
+    interval: 1m
+  direct:
+    path: %s
+    interval: 1m
+  missing:
+    path: %s/missing/
+    interval: 1m
+`, link, target, dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path := cfg.GetDirectoryPath(cfg.Directories["linked"]); path != canonicalTarget {
+		t.Errorf("expected linked group to resolve to %s, got %s", canonicalTarget, path)
+	}
+
+	if path := cfg.GetDirectoryPath(cfg.Directories["missing"]); path != filepath.Join(dir, "missing") {
+		t.Errorf("expected unresolvable path to be kept as configured, got %s", path)
+	}
+
+	expected := map[string][]string{canonicalTarget: {"direct", "linked"}}
+	if duplicates := cfg.DuplicateDirectoryGroups(); !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("expected duplicates %v, got %v", expected, duplicates)
+	}
+}
+
+func TestLoadConfig_Digest(t *testing.T) {
+	t.Setenv("SMTP_PASSWORD", "from-env")
+
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+digest:
+  enabled: true
+  schedule: weekly
+  weekday: Friday
+  smtp:
+    host: smtp.example.com
+    tls: tls
+    username: exporter
+    from: "Exporter <exporter@example.com>"
+    to: ["ops@example.com"]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Digest.SMTP.Port != 465 || cfg.Digest.SMTP.Password != "from-env" || cfg.Digest.Time != "08:00" {
+		t.Errorf("unexpected digest defaults: %+v", cfg.Digest)
+	}
+
+	if weekday, err := cfg.GetDigestWeekday(); err != nil || weekday != time.Friday {
+		t.Errorf("expected Friday, got %v (%v)", weekday, err)
+	}
+
+	if display := fmt.Sprint(cfg.GetDisplayConfig()["Digest"]); strings.Contains(display, "from-env") {
+		t.Errorf("display config leaks the SMTP password: %s", display)
+	}
+
+	tests := map[string]string{
+		"must be HH:MM": `
+  time: "8am"
+  smtp: {host: smtp.example.com, from: a@example.com, to: [b@example.com]}`,
+		"invalid weekday": `
+  schedule: weekly
+  weekday: someday
+  smtp: {host: smtp.example.com, from: a@example.com, to: [b@example.com]}`,
+		"at least one to address": `
+  smtp: {host: smtp.example.com, from: a@example.com}`,
+		"invalid email address": `
+  smtp: {host: smtp.example.com, from: a@example.com, to: [not an address]}`,
+	}
+
+	for expected, digest := range tests {
+		_, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+digest:
+  enabled: true`+digest)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestLoadConfig_Baseline(t *testing.T) {
+	dir := t.TempDir()
+
+	csvFile := filepath.Join(dir, "expected.csv")
+	if err := os.WriteFile(csvFile, []byte(`group,path,expected
+# Sizes measured on the old NAS
+media,,1.5TiB
+media,/srv/media/films/,800GiB
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	yamlFile := filepath.Join(dir, "expected.yaml")
+	if err := os.WriteFile(yamlFile, []byte(`
+- group: media
+  path: /srv/media/music
+  expected: 1234567
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, expected := range map[string]map[string]int64{
+		csvFile:  {"/srv/media": 3 << 39, "/srv/media/films": 800 << 30, "/srv/backups": 2 << 40},
+		yamlFile: {"/srv/media/music": 1234567, "/srv/backups": 2 << 40},
+	} {
+		cfg, err := loadTestConfig(t, fmt.Sprintf(`
+directories:
+  media:
+    path: /srv/media
+    interval: 1h
+  backups:
+    path: /srv/backups
+    interval: 1h
+    expected_size: 2TiB
+baseline:
+  file: %s
+`, file))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filepath.Base(file), err)
+		}
+
+		for path, size := range expected {
+			group := "media"
+			if path == "/srv/backups" {
+				group = "backups"
+			}
+
+			if got, exists := cfg.GetExpectedSize(group, path); !exists || got != size {
+				t.Errorf("%s: expected %s to be %d, got %d (%v)", filepath.Base(file), path, size, got, exists)
+			}
+		}
+	}
+
+	unknownFile := filepath.Join(dir, "unknown.csv")
+	if err := os.WriteFile(unknownFile, []byte("photos,,1TiB\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadTestConfig(t, fmt.Sprintf(`
+directories:
+  media:
+    path: /srv/media
+    interval: 1h
+baseline:
+  file: %s
+`, unknownFile))
+	if err == nil || !strings.Contains(err.Error(), "unknown directory group 'photos'") {
+		t.Errorf("expected unknown group error, got %v", err)
+	}
+}
+
+func TestLoadConfig_DropPageCache(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    mode: walk
+    drop_page_cache: true
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Directories["home"].DropPageCache {
+		t.Error("expected drop_page_cache to be set")
+	}
+
+	_, err = loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    mode: du
+    drop_page_cache: true
+`)
+	if err == nil || !strings.Contains(err.Error(), "drop_page_cache") {
+		t.Fatalf("expected drop_page_cache validation error for du mode, got %v", err)
+	}
+}
+
+func TestLoadConfig_SampleMode(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  archive:
+    path: /archive
+    interval: 15m
+    mode: sample
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	group := cfg.Directories["archive"]
+	if group.SampleFraction != 0.1 || group.FullScanInterval.Duration != 24*time.Hour {
+		t.Errorf("expected default sample_fraction 0.1 and full_scan_interval 24h, got %g and %s", group.SampleFraction, group.FullScanInterval)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		extra    string
+		expected string
+	}{
+		{"subdirectory levels", "subdirectory_levels: 1", "doesn't support"},
+		{"fraction too large", "sample_fraction: 1.5", "sample_fraction"},
+		{"full scan too often", "full_scan_interval: 5m", "full_scan_interval"},
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  archive:
+    path: /archive
+    interval: 15m
+    mode: sample
+    `+tt.extra+`
+`)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestLoadConfig_Pushgateway(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+pushgateway:
+  url: http://pushgateway:9091
+  grouping:
+    site: london
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+
+	pg := cfg.Pushgateway
+	if pg.Job != "filesystem_exporter" || pg.Timeout.Duration != 30*time.Second {
+		t.Errorf("unexpected defaults: job %q, timeout %s", pg.Job, pg.Timeout)
+	}
+
+	if pg.Grouping["instance"] != hostname || pg.Grouping["site"] != "london" {
+		t.Errorf("expected instance to default to the hostname alongside site, got %v", pg.Grouping)
+	}
+
+	for _, invalid := range []string{
+		"url: pushgateway:9091",
+		"url: http://pushgateway:9091\n  grouping:\n    job: other",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+pushgateway:
+  `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "pushgateway config") {
+			t.Errorf("expected pushgateway validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_Alerts(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  media:
+    path: /srv/media
+    interval: 5m
+alerts:
+  enabled: true
+  rules:
+    - name: big
+      directory: media
+      size_above: 2TiB
+  webhooks:
+    - name: ops
+      url: https://hooks.example.com/alerts
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	alerts := cfg.Alerts
+	if alerts.RepeatInterval.Duration != 4*time.Hour || alerts.Rules[0].For != 1 || alerts.Rules[0].SizeAbove != 2<<40 {
+		t.Errorf("unexpected rule defaults: %+v", alerts)
+	}
+
+	if alerts.Webhooks[0].Type != WebhookTypeGeneric || alerts.Webhooks[0].Timeout.Duration != 10*time.Second {
+		t.Errorf("unexpected webhook defaults: %+v", alerts.Webhooks[0])
+	}
+
+	for _, invalid := range []string{
+		"- name: both\n      used_ratio_above: 0.9\n      size_above: 1GiB",
+		"- name: none\n      for: 2",
+		"- name: scope\n      directory: media\n      used_ratio_above: 0.9",
+		"- name: unknown\n      directory: backups\n      size_above: 1GiB",
+		"- name: hook\n      failures_at_least: 3\n      webhooks: [pager]",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  media:
+    path: /srv/media
+    interval: 5m
+alerts:
+  enabled: true
+  webhooks:
+    - name: ops
+      url: https://hooks.example.com/alerts
+  rules:
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "alerts config") {
+			t.Errorf("expected alerts validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_ServerTLS(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+server:
+  port: 8443
+  tls:
+    cert_file: /etc/tls/tls.crt
+    key_file: /etc/tls/tls.key
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != 8443 || !cfg.TLS.IsEnabled() || cfg.TLS.KeyFile != "/etc/tls/tls.key" {
+		t.Errorf("expected server.tls alongside the server settings, got %+v and %+v", cfg.Server, cfg.TLS)
+	}
+
+	if cfg.TLS.ReloadInterval.Duration != 30*time.Second {
+		t.Errorf("expected a 30s reload interval by default, got %s", cfg.TLS.ReloadInterval)
+	}
+
+	for _, invalid := range []string{
+		"cert_file: /etc/tls/tls.crt",
+		"client_ca_file: /etc/tls/ca.crt",
+	} {
+		_, err := loadTestConfig(t, `
+server:
+  tls:
+    `+invalid+`
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+		if err == nil || !strings.Contains(err.Error(), "server tls config") {
+			t.Errorf("expected tls validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_ServerAuth(t *testing.T) {
+	t.Setenv("FILESYSTEM_EXPORTER_BEARER_TOKEN", "from-env")
+
+	cfg, err := loadTestConfig(t, `
+server:
+  auth:
+    basic_auth_users:
+      prometheus: $2y$10$abcdefghijklmnopqrstuu
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Auth.IsEnabled() || cfg.Auth.BearerToken != "from-env" || cfg.Auth.BasicAuthUsers["prometheus"] == "" {
+		t.Errorf("expected server.auth with the token from the environment, got %+v", cfg.Auth)
+	}
+
+	_, err = loadTestConfig(t, `
+server:
+  auth:
+    bearer_token_file: /run/secrets/token
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err == nil || !strings.Contains(err.Error(), "server auth config") {
+		t.Errorf("expected bearer_token and bearer_token_file to conflict, got %v", err)
+	}
+}
+
+func TestLoadConfig_ServerListen(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+server:
+  listen: unix:///run/filesystem-exporter.sock
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path, ok := cfg.GetListenSocket(); !ok || path != "/run/filesystem-exporter.sock" {
+		t.Errorf("expected the socket path, got %q", path)
+	}
+
+	if mode, err := cfg.GetSocketMode(); err != nil || mode != 0o660 {
+		t.Errorf("expected socket mode 0660 by default, got %s (%v)", mode, err)
+	}
+
+	for _, invalid := range []string{
+		"listen: 127.0.0.1:8080",
+		"listen: unix://relative.sock",
+		"socket_mode: rw-rw----",
+	} {
+		_, err := loadTestConfig(t, `
+server:
+  `+invalid+`
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+		if err == nil || !strings.Contains(err.Error(), "server config") {
+			t.Errorf("expected server validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_LoggingLevels(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+logging:
+  level: info
+  levels:
+    scheduler: warn
+    collectors/zfs: debug
+  rate_limit:
+    burst: 10
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.LogLevels["collectors/zfs"] != "debug" || cfg.LogRateLimit.Burst != 10 || cfg.LogRateLimit.Interval.Duration != time.Minute {
+		t.Errorf("expected levels and a rate limit of 10 a minute, got %v and %+v", cfg.LogLevels, cfg.LogRateLimit)
+	}
+
+	for _, invalid := range []string{
+		"levels:\n    scheduler: verbose",
+		"rate_limit:\n    burst: -1",
+	} {
+		_, err := loadTestConfig(t, `
+logging:
+  `+invalid+`
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+		if err == nil || !strings.Contains(err.Error(), "logging config") {
+			t.Errorf("expected logging validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_Tracing(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+tracing:
+  enabled: true
+  scrub_paths: hash
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.TracingSampleRatio != 1 || cfg.TracingScrubPaths != TracingScrubHash {
+		t.Errorf("expected every trace kept and paths hashed, got %g and %q", cfg.TracingSampleRatio, cfg.TracingScrubPaths)
+	}
+
+	for _, invalid := range []string{
+		"sample_ratio: 1.5",
+		"sample_ratio: -0.1",
+		"scrub_paths: remove",
+	} {
+		_, err := loadTestConfig(t, `
+tracing:
+  `+invalid+`
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+		if err == nil || !strings.Contains(err.Error(), "tracing config") {
+			t.Errorf("expected tracing validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestSetDirectory(t *testing.T) {
+	overlay := filepath.Join(t.TempDir(), "overlay.yaml")
+	root := t.TempDir()
+
+	configYAML := `
+server:
+  auth:
+    bearer_token: secret
+api:
+  enabled: true
+  config_updates:
+    enabled: true
+    overlay_file: ` + overlay + `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    labels:
+      team: platform
+`
+
+	cfg, err := loadTestConfig(t, configYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	created, err := cfg.SetDirectory("scratch", []byte(`{"path": "`+root+`", "interval": "1m", "labels": {"team": "data"}}`))
+	if err != nil || !created {
+		t.Fatalf("expected the group to be created, got %v, %v", created, err)
+	}
+
+	group, exists := cfg.Directory("scratch")
+	if !exists || group.Mode == "" || group.CanonicalPath == "" || group.Labels["team"] != "data" {
+		t.Errorf("expected the group with defaults filled in, got %+v", group)
+	}
+
+	if created, err := cfg.SetDirectory("scratch", []byte("path: "+root+"\ninterval: 2m\n")); err != nil || created {
+		t.Errorf("expected the group to be replaced, got %v, %v", created, err)
+	}
+
+	for name, settings := range map[string]string{
+		"missing interval": "path: " + root,
+		"unknown setting":  "path: " + root + "\ninterval: 1m\nsize: 10",
+		"unknown label":    "path: " + root + "\ninterval: 1m\nlabels: {owner: me}",
+		"not a mapping":    "- " + root,
+	} {
+		if _, err := cfg.SetDirectory("invalid", []byte(settings)); !errors.Is(err, ErrInvalidDirectory) {
+			t.Errorf("%s: expected an invalid group, got %v", name, err)
+		}
+	}
+
+	// Settings that would let an API client run commands or write files
+	for key, settings := range map[string]string{
+		"mode exec":    "mode: exec\ncommand: [/bin/true]",
+		"command":      "mode: du\ncommand: [/bin/true]",
+		"ncdu_export":  "mode: walk\nncdu_export: " + filepath.Join(root, "export.json"),
+		"run_as_user":  "run_as_user: \"65534\"",
+		"run_as_group": "run_as_group: \"65534\"",
+		"nice":         "nice: 10",
+		"ioprio_class": "ioprio_class: idle",
+		"ioprio_level": "ioprio_level: 7",
+	} {
+		_, err := cfg.SetDirectory("refused", []byte("path: "+root+"\ninterval: 1m\n"+settings))
+		if !errors.Is(err, ErrInvalidDirectory) || !strings.Contains(err.Error(), key) || !strings.Contains(err.Error(), "config files") {
+			t.Errorf("%s: expected the setting to be refused at runtime, got %v", key, err)
+		}
+	}
+
+	if _, err := cfg.SetDirectory("home", []byte("path: /srv\ninterval: 1m\n")); !errors.Is(err, ErrDirectoryFromFiles) {
+		t.Errorf("expected a group from the config files to be kept, got %v", err)
+	}
+
+	// The group is kept in the overlay file across restarts
+	reloaded, err := loadTestConfig(t, configYAML)
+	if err != nil {
+		t.Fatalf("unexpected error reloading: %v", err)
+	}
+
+	if group, exists := reloaded.Directory("scratch"); !exists || group.Interval.Duration != 2*time.Minute {
+		t.Errorf("expected the replaced group from the overlay file, got %+v", group)
+	}
+
+	if err := reloaded.DeleteDirectory("home"); !errors.Is(err, ErrDirectoryFromFiles) {
+		t.Errorf("expected a group from the config files to be kept, got %v", err)
+	}
+
+	if err := reloaded.DeleteDirectory("scratch"); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+
+	if err := reloaded.DeleteDirectory("scratch"); !errors.Is(err, ErrDirectoryNotFound) {
+		t.Errorf("expected the deleted group to be gone, got %v", err)
+	}
+
+	if reloaded, err := loadTestConfig(t, configYAML); err != nil || len(reloaded.Directories) != 1 {
+		t.Errorf("expected only the group from the config file after deleting, got %v, %v", reloaded, err)
+	}
+
+	// Changing what is scanned needs credentials
+	_, err = loadTestConfig(t, `
+api:
+  enabled: true
+  config_updates:
+    enabled: true
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err == nil || !strings.Contains(err.Error(), "server.auth") {
+		t.Errorf("expected config_updates without auth to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfig_Probe(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+probe:
+  enabled: true
+  allowed_paths: ["/srv/projects"]
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Probe.Timeout.Duration != 30*time.Second || cfg.Probe.MaxConcurrent != 2 {
+		t.Errorf("expected default timeout 30s and 2 concurrent probes, got %s and %d", cfg.Probe.Timeout.Duration, cfg.Probe.MaxConcurrent)
+	}
+
+	for _, invalid := range []string{
+		"enabled: true",
+		"enabled: true\n  allowed_paths: [\"relative\"]",
+	} {
+		_, err := loadTestConfig(t, `
+probe:
+  `+invalid+`
+directories:
+  home:
+    path: /home
+    interval: 5m
+`)
+		if err == nil || !strings.Contains(err.Error(), "probe config") {
+			t.Errorf("expected probe validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_CustomLabels(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    mode: statfs
+    interval: 5m
+    labels:
+      team: platform
+      tier: gold
+directories:
+  home:
+    path: /home
+    interval: 5m
+    labels:
+      owner: web
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if names := cfg.GetCustomLabelNames(); strings.Join(names, ",") != "owner,team,tier" {
+		t.Errorf("expected the sorted union of label names, got %v", names)
+	}
+
+	if labels := cfg.GetFilesystemLabels("root"); labels["team"] != "platform" {
+		t.Errorf("expected the filesystem's labels, got %v", labels)
+	}
+
+	if labels := cfg.GetDirectoryLabels("home"); labels["owner"] != "web" {
+		t.Errorf("expected the directory group's labels, got %v", labels)
+	}
+
+	for _, invalid := range []string{"volume", "__team", "team-name"} {
+		_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    labels:
+      `+invalid+`: platform
+`)
+		if err == nil || !strings.Contains(err.Error(), "directories config") {
+			t.Errorf("expected validation error for label %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_MaxSeries(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    subdirectory_levels: 2
+    max_series: 100
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strategy := cfg.Directories["home"].MaxSeriesStrategy; strategy != SeriesStrategyOther {
+		t.Errorf("expected strategy other by default, got %q", strategy)
+	}
+
+	for _, invalid := range []string{
+		"max_series: -1",
+		"max_series: 3",
+		"max_series: 100\n    max_series_strategy: random",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    subdirectory_levels: 2
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "directories config") {
+			t.Errorf("expected validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_LabelPath(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    subdirectory_levels: 2
+    label_path: relative
+  media:
+    path: /srv/media
+    interval: 5m
+    subdirectory_levels: 1
+    label_path: basename
+  logs:
+    path: /var/log
+    interval: 5m
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		group, path, expected string
+	}{
+		{"home", "/home", "."},
+		{"home", "/home/alice/projects", "alice/projects"},
+		{"media", "/srv/media/films", "films"},
+		{"logs", "/var/log/nginx", "/var/log/nginx"},
+	}
+
+	for _, tt := range tests {
+		if label := cfg.GetDirectoryLabel(cfg.Directories[tt.group], tt.path); label != tt.expected {
+			t.Errorf("%s %s: expected %q, got %q", tt.group, tt.path, tt.expected, label)
+		}
+	}
+
+	for _, invalid := range []string{
+		"label_path: short",
+		"label_path: basename\n    subdirectory_levels: 2",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "directories config") {
+			t.Errorf("expected validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_SizeMode(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+  media:
+    path: /srv/media
+    interval: 5m
+    size_mode: apparent
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mode := cfg.Directories["home"].SizeMode; mode != SizeModeDiskUsage {
+		t.Errorf("expected size_mode to default to %s, got %q", SizeModeDiskUsage, mode)
+	}
+
+	if mode := cfg.GetDirectorySizeMode("media"); mode != SizeModeApparent {
+		t.Errorf("expected size_mode %s, got %q", SizeModeApparent, mode)
+	}
+
+	for _, invalid := range []string{
+		"size_mode: logical",
+		"size_mode: apparent\n    mode: project_quota",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "directories config") {
+			t.Errorf("expected validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_HardlinksTotal(t *testing.T) {
+	if _, err := loadTestConfig(t, `
+directories:
+  backups:
+    path: /backups
+    interval: 5m
+    mode: walk
+    hardlinks_total: true
+`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := loadTestConfig(t, `
+directories:
+  backups:
+    path: /backups
+    interval: 5m
+    mode: du
+    hardlinks_total: true
+`)
+	if err == nil || !strings.Contains(err.Error(), "hardlinks_total needs mode walk") {
+		t.Errorf("expected hardlinks_total to require mode walk, got %v", err)
+	}
+}
+
+func TestLoadConfig_NcduExport(t *testing.T) {
+	_, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+    mode: du
+    ncdu_export: /var/lib/exports/home.json
+`)
+	if err == nil || !strings.Contains(err.Error(), "ncdu_export needs mode walk") {
+		t.Errorf("expected ncdu_export to require mode walk, got %v", err)
+	}
+}
+
+func TestLoadConfig_FollowSymlinks(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 5m
+  www:
+    path: /var/www
+    interval: 5m
+    mode: du
+    follow_symlinks: always
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if follow := cfg.Directories["home"].FollowSymlinks; follow != FollowSymlinksNever {
+		t.Errorf("expected follow_symlinks to default to %s, got %q", FollowSymlinksNever, follow)
+	}
+
+	for _, invalid := range []string{
+		"follow_symlinks: sometimes",
+		"follow_symlinks: within_root\n    mode: du",
+		"follow_symlinks: always\n    mode: sample",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  www:
+    path: /var/www
+    interval: 5m
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "follow_symlinks") {
+			t.Errorf("expected follow_symlinks validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_ExecMode(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  dedup:
+    path: /srv/dedup
+    interval: 1h
+    mode: exec
+    command: ["/usr/local/bin/dedup-scan", "--root", "{path}"]
+    subdirectory_levels: 1
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if command := cfg.Directories["dedup"].Command; len(command) != 3 || command[2] != "{path}" {
+		t.Errorf("unexpected command %q", command)
+	}
+
+	for _, invalid := range []string{
+		"mode: exec",
+		"mode: exec\n    command: [scan]\n    top_n: 5",
+		"mode: walk\n    command: [scan]",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  dedup:
+    path: /srv/dedup
+    interval: 1h
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "directories config") {
+			t.Errorf("expected validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_Include(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "conf.d")
+
+	if err := os.Mkdir(confd, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"config.yaml": `
+include:
+  - conf.d/*.yaml
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+`,
+		"conf.d/10-team.yaml": `
+directories:
+  team:
+    path: /srv/team
+    interval: 1h
+`,
+		"conf.d/20-backup.yaml": `
+filesystems:
+  - name: backup
+    mount_point: /mnt/backup
+    interval: 5m
+`,
+		"conf.d/30-empty.yaml": "# nothing yet\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.Filesystems) != 2 || cfg.Filesystems[1].Name != "backup" {
+		t.Errorf("expected root and the included backup filesystem, got %+v", cfg.Filesystems)
+	}
+
+	if _, exists := cfg.Directories["team"]; !exists {
+		t.Errorf("expected the included team group, got %v", cfg.Directories)
+	}
+
+	// Names can't be redefined by another file
+	duplicate := filepath.Join(confd, "40-duplicate.yaml")
+	if err := os.WriteFile(duplicate, []byte("directories:\n  team:\n    path: /srv/other\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("expected duplicate group error, got %v", err)
+	}
+
+	// Only filesystems and directories may be included
+	if err := os.WriteFile(duplicate, []byte("zfs:\n  enabled: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "only filesystems and directories") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestLoadConfig_EnvOverrides(t *testing.T) {
+	t.Setenv("FILESYSTEM_EXPORTER_LOGGING_LEVEL", "debug")
+	t.Setenv("FILESYSTEM_EXPORTER_TRACING_ENABLED", "true")
+	t.Setenv("FILESYSTEM_EXPORTER_FILESYSTEMS_0_TIMEOUT", "45s")
+	t.Setenv("FILESYSTEM_EXPORTER_FILESYSTEMS_0_LABELS_TEAM", "platform")
+	t.Setenv("FILESYSTEM_EXPORTER_FILESYSTEMS_1_NAME", "data")
+	t.Setenv("FILESYSTEM_EXPORTER_FILESYSTEMS_1_MOUNT_POINT", "/data")
+	t.Setenv("FILESYSTEM_EXPORTER_FILESYSTEMS_1_INTERVAL", "2m")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_HOME_INTERVAL", "1h")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_VAR_LOG_PATH", "/var/log")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_VAR_LOG_INTERVAL", "5m")
+	t.Setenv("FILESYSTEM_EXPORTER_MOUNT_PROBE_FS_TYPES", "nfs4, fuse.sshfs")
+	t.Setenv("FILESYSTEM_EXPORTER_SERVER_AUTH_BEARER_TOKEN", "from-env")
+	t.Setenv("FILESYSTEM_EXPORTER_NOT_A_SETTING", "ignored")
+
+	cfg, err := loadTestConfig(t, `
+logging:
+  level: warn
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+directories:
+  home:
+    path: /home
+    interval: 10m
+    subdirectory_levels: 1
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Logging.Level != "debug" || !cfg.Tracing.IsEnabled() {
+		t.Errorf("expected logging and tracing from the environment, got %+v %+v", cfg.Logging, cfg.Tracing)
+	}
+
+	if len(cfg.Filesystems) != 2 {
+		t.Fatalf("expected a filesystem added from the environment, got %+v", cfg.Filesystems)
+	}
+
+	root := cfg.Filesystems[0]
+	if root.Timeout.Duration != 45*time.Second || root.Labels["team"] != "platform" || root.Interval.Duration != time.Minute {
+		t.Errorf("expected root's timeout and labels overridden and the rest kept, got %+v", root)
+	}
+
+	if cfg.Filesystems[1].MountPoint != "/data" {
+		t.Errorf("expected data at /data, got %+v", cfg.Filesystems[1])
+	}
+
+	home := cfg.Directories["home"]
+	if home.Interval.Duration != time.Hour || home.SubdirectoryLevels != 1 {
+		t.Errorf("expected home's interval overridden and levels kept, got %+v", home)
+	}
+
+	varLog, exists := cfg.Directories["var_log"]
+	if !exists || varLog.Path != "/var/log" {
+		t.Errorf("expected var_log added from the environment, got %+v", cfg.Directories)
+	}
+
+	if fsTypes := cfg.MountProbe.FSTypes; len(fsTypes) != 2 || fsTypes[1] != "fuse.sshfs" {
+		t.Errorf("expected a comma separated list of mount types, got %v", fsTypes)
+	}
+
+	if cfg.Auth.BearerToken != "from-env" {
+		t.Errorf("expected server.auth.bearer_token from the environment, got %q", cfg.Auth.BearerToken)
+	}
+
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_HOME_INTERVAL", "soon")
+
+	if _, err := loadTestConfig(t, "directories:\n  home:\n    path: /home\n"); err == nil || !strings.Contains(err.Error(), "FILESYSTEM_EXPORTER_DIRECTORIES_HOME_INTERVAL") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestLoadConfig_EnvIndexedDirectories(t *testing.T) {
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_0_NAME", "build-cache")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_0_PATH", "/srv/cache:v2")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_0_INTERVAL", "10m")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_0_TIMEOUT", "2m")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_0_LABELS_TEAM", "ci")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_1_NAME", "home")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_1_SUBDIRECTORY_LEVELS", "2")
+
+	cfg, err := loadTestConfig(t, `
+directories:
+  home:
+    path: /home
+    interval: 1h
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache, exists := cfg.Directories["build-cache"]
+	if !exists || cache.Path != "/srv/cache:v2" || cache.Timeout.Duration != 2*time.Minute || cache.Labels["team"] != "ci" {
+		t.Errorf("expected build-cache from the environment, got %+v", cfg.Directories)
+	}
+
+	home := cfg.Directories["home"]
+	if home.Path != "/home" || home.Interval.Duration != time.Hour || home.SubdirectoryLevels != 2 {
+		t.Errorf("expected home's levels overridden and the rest kept, got %+v", home)
+	}
+
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_2_PATH", "/srv/orphan")
+
+	if _, err := loadTestConfig(t, ""); err == nil || !strings.Contains(err.Error(), "FILESYSTEM_EXPORTER_DIRECTORIES_2_NAME") {
+		t.Errorf("expected an error for a directory without a name, got %v", err)
+	}
+
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_2_NAME", "orphan")
+	t.Setenv("FILESYSTEM_EXPORTER_DIRECTORIES_2_PATHS", "/srv/orphan")
+
+	if _, err := loadTestConfig(t, ""); err == nil || !strings.Contains(err.Error(), "not a directory group setting") {
+		t.Errorf("expected an error for an unknown setting, got %v", err)
+	}
+}
+
+func TestLoadConfig_ApplyDefaultInterval(t *testing.T) {
+	items := `
+filesystems:
+  - name: root
+    mount_point: /
+  - name: data
+    mount_point: /data
+    interval: 1m
+directories:
+  home:
+    path: /home
+`
+
+	if _, err := loadTestConfig(t, items); err == nil || !strings.Contains(err.Error(), "must have an interval") {
+		t.Fatalf("expected missing intervals to fail by default, got %v", err)
+	}
+
+	cfg, err := loadTestConfig(t, `
+metrics:
+  collection:
+    default_interval: 10m
+    apply_default_interval: true
+`+items)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Filesystems[0].Interval.Duration != 10*time.Minute || cfg.Directories["home"].Interval.Duration != 10*time.Minute {
+		t.Errorf("expected missing intervals to inherit the default, got %+v %+v", cfg.Filesystems[0], cfg.Directories["home"])
+	}
+
+	if cfg.Filesystems[1].Interval.Duration != time.Minute {
+		t.Errorf("expected explicit intervals to be kept, got %v", cfg.Filesystems[1].Interval)
+	}
+}
+
+func TestLoadConfig_DirectoryDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+defaults:
+  interval: 1h
+  timeout: 10m
+  subdirectory_levels: 2
+  labels:
+    team: platform
+directories:
+  home:
+    path: /home
+  logs:
+    path: /var/log
+    interval: 5m
+    subdirectory_levels: 0
+    labels:
+      tier: gold
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	home := cfg.Directories["home"]
+	if home.Interval.Duration != time.Hour || home.Timeout.Duration != 10*time.Minute || home.SubdirectoryLevels != 2 || home.Labels["team"] != "platform" {
+		t.Errorf("expected home to take the defaults, got %+v", home)
+	}
+
+	logs := cfg.Directories["logs"]
+	if logs.Interval.Duration != 5*time.Minute || logs.Timeout.Duration != 10*time.Minute || logs.SubdirectoryLevels != 0 {
+		t.Errorf("expected logs to override the interval and levels, got %+v", logs)
+	}
+
+	if logs.Labels["team"] != "platform" || logs.Labels["tier"] != "gold" || len(home.Labels) != 1 {
+		t.Errorf("expected labels to be merged per group, got %v and %v", logs.Labels, home.Labels)
+	}
+
+	if _, err := loadTestConfig(t, "defaults:\n  path: /srv\n"); err == nil || !strings.Contains(err.Error(), "defaults cannot set path") {
+		t.Errorf("expected an error for a default path, got %v", err)
+	}
+}
+
+func TestLoadConfig_DisabledItems(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+  - name: scratch
+    mount_point: /scratch
+    interval: 1m
+    enabled: false
+directories:
+  home:
+    path: /home
+    interval: 1h
+  archive:
+    path: /srv/archive
+    interval: 1h
+    enabled: false
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Filesystems[0].IsEnabled() || cfg.Filesystems[1].IsEnabled() {
+		t.Errorf("expected only scratch to be disabled, got %+v", cfg.Filesystems)
+	}
+
+	if !cfg.Directories["home"].IsEnabled() || cfg.Directories["archive"].IsEnabled() {
+		t.Errorf("expected only archive to be disabled, got %+v", cfg.Directories)
+	}
+
+	display := fmt.Sprint(cfg.GetDisplayConfig()["Directories"])
+	if !strings.Contains(display, "enabled:false") {
+		t.Errorf("expected the display config to show disabled groups, got %s", display)
+	}
+}
+
+func TestLoadConfig_QueueOverflowPolicy(t *testing.T) {
+	const base = `
+filesystems:
+  - name: root
+    mount_point: /
+    interval: 1m
+`
+
+	cfg, err := loadTestConfig(t, base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Queue.Size != 100 || cfg.Queue.OverflowPolicy != QueueOverflowBlock {
+		t.Errorf("expected a blocking queue of 100 by default, got %+v", cfg.Queue)
+	}
+
+	_, err = loadTestConfig(t, base+`
+queue:
+  overflow_policy: drop_everything
+`)
+	if err == nil || !strings.Contains(err.Error(), "overflow_policy") {
+		t.Errorf("expected an invalid overflow_policy error, got %v", err)
+	}
+}
+
+func TestBlackoutWindow(t *testing.T) {
+	// 2024-06-03 is a Monday
+	at := func(day int, clock string) time.Time {
+		parsed, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return time.Date(2024, 6, 3+day, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window string
+		time   time.Time
+		want   bool
+	}{
+		{"Mon-Fri 08:00-18:00", at(0, "08:00"), true},
+		{"Mon-Fri 08:00-18:00", at(0, "18:00"), false},
+		{"Mon-Fri 08:00-18:00", at(5, "12:00"), false},
+		{"Sat,Sun 00:00-24:00", at(6, "23:59"), true},
+		{"Fri-Mon 12:00-13:00", at(0, "12:30"), true},
+		{"Fri-Mon 12:00-13:00", at(1, "12:30"), false},
+		{"Fri 22:00-02:00", at(4, "23:00"), true},
+		{"Fri 22:00-02:00", at(5, "01:59"), true},
+		{"Fri 22:00-02:00", at(3, "01:00"), false},
+		{"19:00-23:00", at(2, "20:00"), true},
+		{"monday 19:00-23:00", at(0, "20:00"), true},
+	}
+
+	for _, test := range tests {
+		window, err := ParseBlackoutWindow(test.window)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.window, err)
+		}
+
+		if got := window.Contains(test.time); got != test.want {
+			t.Errorf("%s contains %s: expected %v, got %v", test.window, test.time.Format("Mon 15:04"), test.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "Mon-Fri", "Mo 08:00-18:00", "Mon-Fri 8am-6pm", "Mon 08:00-08:00", "Mon 25:00-26:00", "Mon Tue 08:00-09:00"} {
+		if _, err := ParseBlackoutWindow(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLoadConfig_IOLimit(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+command_limits:
+  io_cgroup_path: /sys/fs/cgroup/filesystem-exporter/io
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    io_limit:
+      read_bytes_per_second: 20MiB
+      read_iops: 200
+  home:
+    path: /home
+    interval: 5m
+    mode: walk
+    io_limit:
+      files_per_second: 5000
+`)
+	if runtime.GOOS != "linux" {
+		if err == nil || !strings.Contains(err.Error(), "need cgroups") {
+			t.Errorf("expected read limits to need Linux, got %v", err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if limit := cfg.Directories["db"].IOLimit; !limit.LimitsCommands() || limit.ReadBytesPerSecond.Bytes() != 20<<20 || limit.ReadIOPS != 200 {
+		t.Errorf("unexpected db io_limit %+v", limit)
+	}
+
+	if limit := cfg.Directories["home"].IOLimit; limit.LimitsCommands() || limit.FilesPerSecond != 5000 {
+		t.Errorf("unexpected home io_limit %+v", limit)
+	}
+
+	if !cfg.CommandLimits.IsEnabled() {
+		t.Error("expected io_cgroup_path to enable command limits")
+	}
+
+	for _, invalid := range []string{
+		"mode: du\n    io_limit:\n      files_per_second: 100",
+		"mode: walk\n    io_limit:\n      read_iops: 100",
+		"mode: du\n    io_limit:\n      read_iops: -1",
+	} {
+		_, err := loadTestConfig(t, `
+command_limits:
+  io_cgroup_path: /sys/fs/cgroup/filesystem-exporter/io
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "io_limit") {
+			t.Errorf("expected io_limit validation error for %q, got %v", invalid, err)
+		}
+	}
+
+	_, err = loadTestConfig(t, `
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    io_limit:
+      read_iops: 100
+`)
+	if err == nil || !strings.Contains(err.Error(), "io_cgroup_path") {
+		t.Errorf("expected read_iops to need io_cgroup_path, got %v", err)
+	}
+}
+
+func TestLoadConfig_CommandPriority(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    nice: 10
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Directories["db"].Nice != 10 || cfg.Directories["db"].IOPrioClass != "" {
+		t.Errorf("unexpected priority %+v", cfg.Directories["db"])
+	}
+
+	if runtime.GOOS == "linux" {
+		cfg, err := loadTestConfig(t, `
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    ioprio_class: best-effort
+  logs:
+    path: /var/log
+    interval: 1h
+    ioprio_class: best-effort
+    ioprio_level: 7
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if level := cfg.Directories["db"].GetIOPrioLevel(); level != 4 {
+			t.Errorf("expected ioprio_level to default to 4, got %d", level)
+		}
+
+		if level := cfg.Directories["logs"].GetIOPrioLevel(); level != 7 {
+			t.Errorf("expected ioprio_level 7, got %d", level)
+		}
+	}
+
+	for _, invalid := range []string{
+		"ioprio_class: lowest",
+		"ioprio_class: best-effort\n    ioprio_level: 8",
+		"ioprio_class: idle\n    ioprio_level: 3",
+		"ioprio_level: 3",
+		"nice: 20",
+		"mode: walk\n    nice: 10",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  db:
+    path: /var/lib/postgresql
+    interval: 1h
+    `+invalid+`
+`)
+		if err == nil || !(strings.Contains(err.Error(), "ioprio") || strings.Contains(err.Error(), "nice")) {
+			t.Errorf("expected priority validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := ParseCPUList("0-3, 6,2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(cpus, []int{0, 1, 2, 3, 6}) {
+		t.Errorf("unexpected CPUs %v", cpus)
+	}
+
+	for _, invalid := range []string{"", "a", "3-1", "-1", "0-", "1,,2"} {
+		if _, err := ParseCPUList(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestLoadConfig_RunAs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("run_as_user is not available on windows")
+	}
+
+	cfg, err := loadTestConfig(t, `
+security:
+  run_as_user: "65534"
+directories:
+  home:
+    path: /home
+    interval: 1h
+  backups:
+    path: /srv/backups
+    interval: 1h
+    mode: du
+    run_as_user: "1234"
+    run_as_group: "5678"
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runAs, err := cfg.GetDirectoryRunAs("backups")
+	if err != nil || runAs == nil || runAs.UID != 1234 || runAs.GID != 5678 {
+		t.Errorf("expected the group's own user, got %+v, %v", runAs, err)
+	}
+
+	runAs, err = cfg.GetDirectoryRunAs("home")
+	if err != nil || runAs == nil || runAs.UID != 65534 {
+		t.Errorf("expected security's user, got %+v, %v", runAs, err)
+	}
+
+	for _, invalid := range []string{
+		"run_as_group: \"5678\"",
+		"run_as_user: no-such-user-here",
+		"mode: walk\n    run_as_user: \"1234\"",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  backups:
+    path: /srv/backups
+    interval: 1h
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "run_as") {
+			t.Errorf("expected run_as validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestCheckRoot(t *testing.T) {
+	for _, tt := range []struct {
+		euid      int
+		security  SecurityConfig
+		expectErr bool
+	}{
+		{1000, SecurityConfig{}, false},
+		{0, SecurityConfig{}, true},
+		{0, SecurityConfig{AllowRoot: true}, false},
+		{0, SecurityConfig{RunAsUser: "nobody"}, true},
+		{0, SecurityConfig{AllowRoot: true, RunAsUser: "nobody"}, false},
+	} {
+		cfg := &Config{Security: tt.security}
+		if err := cfg.CheckRoot(tt.euid); (err != nil) != tt.expectErr {
+			t.Errorf("euid %d with %+v: expected error %t, got %v", tt.euid, tt.security, tt.expectErr, err)
+		}
+	}
+}
+
+func TestLoadConfig_Sandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox needs Landlock")
+	}
+
+	cfg, err := loadTestConfig(t, `
+directories:
+  uploads:
+    path: /srv/uploads
+    interval: 1h
+    mode: walk
+    follow_symlinks: within_root
+    sandbox: true
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Directories["uploads"].Sandbox {
+		t.Error("expected sandbox to be set")
+	}
+
+	for _, invalid := range []string{
+		"mode: du\n    sandbox: true",
+		"mode: walk\n    follow_symlinks: always\n    sandbox: true",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  uploads:
+    path: /srv/uploads
+    interval: 1h
+    `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "sandbox") {
+			t.Errorf("expected sandbox validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_Retry(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+retry:
+  max_attempts: 3
+  max_delay: 1m
+filesystems:
+  - name: root
+    mount_point: /
+    device: sda1
+    interval: 5m
+  - name: nas
+    mount_point: /mnt/nas
+    device: nas
+    interval: 5m
+    retry:
+      max_attempts: 5
+      initial_delay: 10s
+directories:
+  uploads:
+    path: /srv/uploads
+    interval: 1h
+    retry:
+      max_attempts: 1
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := RetryConfig{
+		MaxAttempts:  3,
+		InitialDelay: Duration{Duration: 2 * time.Second},
+		MaxDelay:     Duration{Duration: time.Minute},
+		Multiplier:   2,
+	}
+	if got := cfg.GetFilesystemRetry(cfg.Filesystems[0]); got != want {
+		t.Errorf("expected the global retry policy %+v, got %+v", want, got)
+	}
+
+	want.MaxAttempts, want.InitialDelay = 5, Duration{Duration: 10 * time.Second}
+	if got := cfg.GetFilesystemRetry(cfg.Filesystems[1]); got != want {
+		t.Errorf("expected the filesystem's retry policy %+v, got %+v", want, got)
+	}
+
+	if got := cfg.GetDirectoryRetry(cfg.Directories["uploads"]); got.MaxAttempts != 1 || got.String() != "off" {
+		t.Errorf("expected retries off for the directory group, got %+v", got)
+	}
+
+	for _, invalid := range []string{
+		"max_attempts: -1",
+		"multiplier: 0.5",
+		"initial_delay: -1s",
+	} {
+		_, err := loadTestConfig(t, `
+directories:
+  uploads:
+    path: /srv/uploads
+    interval: 1h
+    retry:
+      `+invalid+`
+`)
+		if err == nil || !strings.Contains(err.Error(), "retry") {
+			t.Errorf("expected retry validation error for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestLoadConfig_CollectionMode(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+collection_mode: on_scrape
+filesystems:
+  - name: root
+    mount_point: /
+    device: sda1
+    interval: 5m
+  - name: nas
+    mount_point: /mnt/nas
+    device: nas
+    interval: 5m
+    collection_mode: scheduled
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Filesystems[0].Collection != CollectionModeOnScrape || cfg.Filesystems[1].Collection != CollectionModeScheduled {
+		t.Errorf("expected on_scrape from the global default and scheduled from the override, got %q and %q", cfg.Filesystems[0].Collection, cfg.Filesystems[1].Collection)
+	}
+
+	if !cfg.HasScrapeCollection() {
+		t.Error("expected scrape collection to be reported")
+	}
+
+	if cfg.ScrapeCollection.Budget.Duration != 5*time.Second || cfg.ScrapeCollection.CacheTTL.Duration != 10*time.Second {
+		t.Errorf("expected default budget 5s and cache_ttl 10s, got %+v", cfg.ScrapeCollection)
+	}
+
+	_, err = loadTestConfig(t, `
+filesystems:
+  - name: root
+    mount_point: /
+    device: sda1
+    interval: 5m
+    collection_mode: sometimes
+`)
+	if err == nil || !strings.Contains(err.Error(), "collection_mode") {
+		t.Errorf("expected collection_mode validation error, got %v", err)
+	}
+}
+
+func TestLoadConfig_GroupMetricsAuth(t *testing.T) {
+	for yaml, expected := range map[string]string{
+		"missing:\n      bearer_token: x": "neither a directory group nor a filesystem",
+		"uploads: {}":                     "has no credentials",
+		"uploads:\n      bearer_token: x\n      bearer_token_file: y": "mutually exclusive",
+	} {
+		_, err := loadTestConfig(t, `
+group_metrics:
+  enabled: true
+  auth:
+    `+yaml+`
+directories:
+  uploads:
+    path: /srv/uploads
+    interval: 1h
+`)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q for %q, got %v", expected, yaml, err)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are unix style")
+	}
+
+	for _, path := range []string{"/data/Movies [1080p]", "/data/what?", "/home/~user", "/data/*", "/data/two  spaces", "/data/-rf", "/data/", "/"} {
+		if err := ValidatePath(path, nil); err != nil {
+			t.Errorf("expected %q to be valid, got %v", path, err)
+		}
+	}
+
+	for _, path := range []string{"", "data", "/data/../etc", "/data/./x", "//data", "/data//x", "/data\x00"} {
+		if err := ValidatePath(path, nil); err == nil {
+			t.Errorf("expected %q to be rejected", path)
+		}
+	}
+
+	roots := []string{"/data", "/srv/"}
+
+	for path, allowed := range map[string]bool{"/data": true, "/data/x [1]": true, "/srv/a": true, "/database": false, "/": false, "/srv2": false} {
+		if err := ValidatePath(path, roots); (err == nil) != allowed {
+			t.Errorf("expected %q allowed=%v, got %v", path, allowed, err)
+		}
+	}
+}
+
+func TestLoadConfig_AllowedRoots(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are unix style")
+	}
+
+	const security = `
+security:
+  allowed_roots: ["/data", "/mnt"]
+`
+
+	_, err := loadTestConfig(t, security+`
+filesystems:
+  - name: data
+    mount_point: /mnt/data
+    interval: 5m
+directories:
+  movies:
+    path: /data/Movies [1080p]
+    interval: 1h
+probe:
+  enabled: true
+  allowed_paths: ["/data/projects"]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, outside := range []string{
+		"directories:\n  home:\n    path: /home\n    interval: 1h",
+		"filesystems:\n  - name: root\n    mount_point: /\n    interval: 5m",
+		"directories:\n  movies:\n    path: /data\n    interval: 1h\nprobe:\n  enabled: true\n  allowed_paths: [\"/srv\"]",
+		"directories:\n  movies:\n    path: /database\n    interval: 1h",
+	} {
+		if _, err := loadTestConfig(t, security+outside+"\n"); err == nil || !strings.Contains(err.Error(), "allowed_roots") {
+			t.Errorf("expected an allowed_roots error for %q, got %v", outside, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseCPUList parses a list of CPUs in the form of taskset and cpuset
+// files: comma separated CPU numbers and ranges, like "0-3,6". The CPUs are
+// returned in the order given, without duplicates.
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+
+		firstStr, lastStr, isRange := strings.Cut(part, "-")
+		if !isRange {
+			lastStr = firstStr
+		}
+
+		first, firstErr := strconv.Atoi(firstStr)
+		last, lastErr := strconv.Atoi(lastStr)
+
+		if firstErr != nil || lastErr != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid CPU list '%s': bad entry '%s' (expected e.g. \"0-3,6\")", list, part)
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	return cpus, nil
+}
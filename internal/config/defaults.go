@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyDirectoryDefaults rebuilds the directory groups of a config file on
+// top of the defaults section. Each group is decoded over a copy of the
+// defaults, so any setting a group gives, even a zero like
+// subdirectory_levels: 0, overrides the default and labels are merged.
+func (c *Config) applyDirectoryDefaults(data []byte) error {
+	if c.Defaults.Path != "" {
+		return fmt.Errorf("defaults cannot set path, which is different for every group")
+	}
+
+	var raw struct {
+		Directories map[string]yaml.Node `yaml:"directories"`
+	}
+
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for name, node := range raw.Directories {
+		group, err := c.decodeDirectoryGroup(&node)
+		if err != nil {
+			return fmt.Errorf("directory '%s': %w", name, err)
+		}
+
+		c.Directories[name] = group
+	}
+
+	return nil
+}
+
+// decodeDirectoryGroup decodes a directory group over the defaults
+func (c *Config) decodeDirectoryGroup(node *yaml.Node) (DirectoryGroup, error) {
+	group := c.newDirectoryGroup()
+
+	if err := node.Decode(&group); err != nil {
+		return DirectoryGroup{}, err
+	}
+
+	return group, nil
+}
+
+// newDirectoryGroup returns a copy of the defaults to decode a group over
+func (c *Config) newDirectoryGroup() DirectoryGroup {
+	group := c.Defaults
+	group.Labels = maps.Clone(c.Defaults.Labels)
+
+	return group
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Errors of SetDirectory and DeleteDirectory, for the API to answer with
+var (
+	ErrInvalidDirectory   = errors.New("invalid directory group")
+	ErrDirectoryNotFound  = errors.New("directory group not found")
+	ErrDirectoryFromFiles = errors.New("directory group is defined in the config files")
+)
+
+// directoryOverlay is the overlay file of groups added through the API
+type directoryOverlay struct {
+	Directories map[string]yaml.Node `yaml:"directories"`
+}
+
+// Directory returns the named directory group
+func (c *Config) Directory(name string) (DirectoryGroup, bool) {
+	c.directoriesMu.RLock()
+	defer c.directoriesMu.RUnlock()
+
+	group, exists := c.Directories[name]
+
+	return group, exists
+}
+
+// DirectoryGroups returns the directory groups by name. The map is shared and
+// must not be changed.
+func (c *Config) DirectoryGroups() map[string]DirectoryGroup {
+	c.directoriesMu.RLock()
+	defer c.directoriesMu.RUnlock()
+
+	return c.Directories
+}
+
+// SetDirectory adds the named directory group, or replaces one added before,
+// from its YAML or JSON settings. The group starts from the defaults section
+// and has to keep the whole config valid. It reports whether the group is
+// new.
+func (c *Config) SetDirectory(name string, data []byte) (bool, error) {
+	c.updatesMu.Lock()
+	defer c.updatesMu.Unlock()
+
+	if name == "" || strings.Contains(name, "{{") {
+		return false, fmt.Errorf("%w: invalid name '%s'", ErrInvalidDirectory, name)
+	}
+
+	current := c.DirectoryGroups()
+
+	_, exists := current[name]
+	if _, added := c.runtimeDirectories[name]; exists && !added {
+		return false, ErrDirectoryFromFiles
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidDirectory, err)
+	}
+
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return false, fmt.Errorf("%w: expected a mapping of settings", ErrInvalidDirectory)
+	}
+
+	group, err := c.buildDirectoryGroup(data)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidDirectory, err)
+	}
+
+	directories := maps.Clone(current)
+	if directories == nil {
+		directories = make(map[string]DirectoryGroup)
+	}
+
+	directories[name] = group
+
+	if err := c.validateDirectories(directories); err != nil {
+		return false, err
+	}
+
+	nodes := maps.Clone(c.runtimeDirectories)
+	if nodes == nil {
+		nodes = make(map[string]yaml.Node)
+	}
+
+	nodes[name] = *node.Content[0]
+
+	if err := c.writeDirectoryOverlay(nodes); err != nil {
+		return false, err
+	}
+
+	c.runtimeDirectories = nodes
+	c.replaceDirectories(directories)
+
+	return !exists, nil
+}
+
+// DeleteDirectory removes a directory group added by SetDirectory
+func (c *Config) DeleteDirectory(name string) error {
+	c.updatesMu.Lock()
+	defer c.updatesMu.Unlock()
+
+	current := c.DirectoryGroups()
+
+	if _, exists := current[name]; !exists {
+		return ErrDirectoryNotFound
+	}
+
+	if _, added := c.runtimeDirectories[name]; !added {
+		return ErrDirectoryFromFiles
+	}
+
+	directories := maps.Clone(current)
+	delete(directories, name)
+
+	// Alerts and group_metrics may still name it
+	if err := c.validateDirectories(directories); err != nil {
+		return err
+	}
+
+	nodes := maps.Clone(c.runtimeDirectories)
+	delete(nodes, name)
+
+	if err := c.writeDirectoryOverlay(nodes); err != nil {
+		return err
+	}
+
+	c.runtimeDirectories = nodes
+	c.replaceDirectories(directories)
+
+	return nil
+}
+
+// commandSettings are the directory group settings that choose what the
+// exporter runs or writes, or who and how its commands run as. Being allowed
+// to change groups through the API doesn't grant that, so groups set at
+// runtime can't give them.
+var commandSettings = []string{"command", "ncdu_export", "run_as_user", "run_as_group", "nice", "ioprio_class", "ioprio_level"}
+
+// buildDirectoryGroup decodes a group given at runtime over the defaults and
+// prepares it as loading would. Settings that need setting up at startup, or
+// that would let API clients run commands or write files, are refused.
+func (c *Config) buildDirectoryGroup(data []byte) (DirectoryGroup, error) {
+	var given map[string]any
+	if err := yaml.Unmarshal(data, &given); err != nil {
+		return DirectoryGroup{}, err
+	}
+
+	if given["mode"] == DirectoryModeExec {
+		return DirectoryGroup{}, fmt.Errorf("mode exec runs a command as the exporter, so can only be set in the config files")
+	}
+
+	for _, key := range commandSettings {
+		if _, exists := given[key]; exists {
+			return DirectoryGroup{}, fmt.Errorf("%s controls what the exporter runs or writes, so can only be set in the config files", key)
+		}
+	}
+
+	group := c.newDirectoryGroup()
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&group); err != nil && !errors.Is(err, io.EOF) {
+		return DirectoryGroup{}, err
+	}
+
+	templateData, err := newTemplateData()
+	if err != nil {
+		return DirectoryGroup{}, err
+	}
+
+	if group.Path, err = expandTemplate(group.Path, templateData); err != nil {
+		return DirectoryGroup{}, err
+	}
+
+	group = setDirectoryDefaults(c, group)
+
+	if group.IOLimit.LimitsCommands() {
+		return DirectoryGroup{}, fmt.Errorf("io_limit on commands needs a cgroup created at startup, so can only be set in the config files")
+	}
+
+	if group.ExpectedSize > 0 {
+		return DirectoryGroup{}, fmt.Errorf("expected_size is read with the baseline at startup, so can only be set in the config files")
+	}
+
+	for key := range group.Labels {
+		if !slices.Contains(c.labelNames, key) {
+			return DirectoryGroup{}, fmt.Errorf("label '%s' isn't used by any item in the config files, so has no series to go in", key)
+		}
+	}
+
+	group.CanonicalPath = canonicalPath(group.Path)
+
+	return group, nil
+}
+
+// validateDirectories validates the config as it would be with directories.
+// They're swapped in while it runs, which readers wait for.
+func (c *Config) validateDirectories(directories map[string]DirectoryGroup) error {
+	c.directoriesMu.Lock()
+	defer c.directoriesMu.Unlock()
+
+	current := c.Directories
+	c.Directories = directories
+
+	err := c.Validate()
+
+	c.Directories = current
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDirectory, err)
+	}
+
+	return nil
+}
+
+// replaceDirectories swaps in a new map of directory groups
+func (c *Config) replaceDirectories(directories map[string]DirectoryGroup) {
+	c.directoriesMu.Lock()
+	defer c.directoriesMu.Unlock()
+
+	c.Directories = directories
+}
+
+// loadDirectoryOverlay adds the groups kept in api.config_updates.overlay_file.
+// The file not existing yet is fine, since the first change creates it.
+func (c *Config) loadDirectoryOverlay() error {
+	path := c.API.ConfigUpdates.OverlayFile
+	if !c.API.ConfigUpdates.Enabled || path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read overlay file %s: %w", path, err)
+	}
+
+	var overlay directoryOverlay
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&overlay); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse overlay file %s: %w", path, err)
+	}
+
+	c.runtimeDirectories = make(map[string]yaml.Node, len(overlay.Directories))
+
+	for name, node := range overlay.Directories {
+		if _, exists := c.Directories[name]; exists {
+			return fmt.Errorf("directory group '%s' in %s is already defined in the config files", name, path)
+		}
+
+		group, err := c.decodeDirectoryGroup(&node)
+		if err != nil {
+			return fmt.Errorf("failed to parse directory group '%s' in %s: %w", name, path, err)
+		}
+
+		if c.Directories == nil {
+			c.Directories = make(map[string]DirectoryGroup)
+		}
+
+		c.Directories[name] = group
+		c.runtimeDirectories[name] = node
+	}
+
+	return nil
+}
+
+// writeDirectoryOverlay replaces the overlay file with nodes, if there is one
+func (c *Config) writeDirectoryOverlay(nodes map[string]yaml.Node) error {
+	path := c.API.ConfigUpdates.OverlayFile
+	if path == "" {
+		return nil
+	}
+
+	data, err := yaml.Marshal(directoryOverlay{Directories: nodes})
+	if err != nil {
+		return fmt.Errorf("failed to encode overlay file: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write overlay file %s: %w", path, err)
+	}
+
+	_, err = file.Write(data)
+	err = errors.Join(err, file.Close())
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to write overlay file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,390 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts every exporter environment variable
+const envPrefix = "FILESYSTEM_EXPORTER_"
+
+// applyEnvVars overlays FILESYSTEM_EXPORTER_* environment variables onto cfg,
+// taking precedence over the config file. Any setting can be given by its
+// YAML path in upper case with underscores: logging.level is
+// FILESYSTEM_EXPORTER_LOGGING_LEVEL, a directory group's interval is
+// FILESYSTEM_EXPORTER_DIRECTORIES_<NAME>_INTERVAL and the first filesystem's
+// mount point is FILESYSTEM_EXPORTER_FILESYSTEMS_0_MOUNT_POINT. Variables that
+// don't name a setting are ignored, so the prefix can be used for template
+// variables too.
+func applyEnvVars(cfg *Config) error {
+	if err := applyEnvAliases(cfg); err != nil {
+		return err
+	}
+
+	var names []string
+
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, envPrefix) {
+			names = append(names, name)
+		}
+	}
+
+	// Sorted, so FILESYSTEMS_0_* is applied before FILESYSTEMS_1_*
+	sort.Strings(names)
+
+	names, err := applyIndexedDirectories(cfg, names)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := setFromEnv(reflect.ValueOf(cfg).Elem(), strings.TrimPrefix(name, envPrefix), os.Getenv(name)); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	// Set by the YAML unmarshaler of the collection section, which an
+	// environment variable for the interval alone bypasses
+	if _, set := os.LookupEnv(envPrefix + "METRICS_COLLECTION_DEFAULT_INTERVAL"); set {
+		cfg.Metrics.Collection.DefaultIntervalSet = true
+	}
+
+	return nil
+}
+
+// applyEnvAliases handles the variables that predate the generic mapping and
+// don't follow a setting's path
+func applyEnvAliases(cfg *Config) error {
+	if address := os.Getenv(envPrefix + "SERVER_ADDRESS"); address != "" {
+		host, port, err := splitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid server address: %w", err)
+		}
+
+		cfg.Server.Host = host
+		cfg.Server.Port = port
+	}
+
+	if address := os.Getenv(envPrefix + "API_ADDRESS"); address != "" {
+		host, port, err := splitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid api address: %w", err)
+		}
+
+		cfg.API.Host = host
+		cfg.API.Port = port
+	}
+
+	if token := os.Getenv(envPrefix + "BEARER_TOKEN"); token != "" {
+		cfg.Auth.BearerToken = token
+	}
+
+	return nil
+}
+
+// applyIndexedDirectories adds the directory groups given by index, as
+// FILESYSTEM_EXPORTER_DIRECTORIES_<N>_NAME with any other DirectoryGroup
+// setting as FILESYSTEM_EXPORTER_DIRECTORIES_<N>_<SETTING>, for group names
+// that don't fit in a variable name. It returns the variables left for the
+// generic mapping.
+func applyIndexedDirectories(cfg *Config, names []string) ([]string, error) {
+	const directoriesPrefix = envPrefix + "DIRECTORIES_"
+
+	type indexedVar struct {
+		name string
+		key  string
+	}
+
+	var (
+		remaining []string
+		indexes   []int
+	)
+
+	settings := make(map[int][]indexedVar)
+
+	for _, name := range names {
+		indexStr, key, found := strings.Cut(strings.TrimPrefix(name, directoriesPrefix), "_")
+
+		index, err := strconv.Atoi(indexStr)
+		if !strings.HasPrefix(name, directoriesPrefix) || !found || err != nil || index < 0 {
+			remaining = append(remaining, name)
+			continue
+		}
+
+		if _, seen := settings[index]; !seen {
+			indexes = append(indexes, index)
+		}
+
+		settings[index] = append(settings[index], indexedVar{name: name, key: key})
+	}
+
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		nameVar := fmt.Sprintf("%s%d_NAME", directoriesPrefix, index)
+
+		groupName := os.Getenv(nameVar)
+		if groupName == "" {
+			return nil, fmt.Errorf("directory %d is configured in the environment without %s", index, nameVar)
+		}
+
+		// Settings from the file are kept unless overridden
+		group := cfg.Directories[groupName]
+
+		for _, setting := range settings[index] {
+			if setting.key == "NAME" {
+				continue
+			}
+
+			matched, err := setFromEnv(reflect.ValueOf(&group).Elem(), setting.key, os.Getenv(setting.name))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", setting.name, err)
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("%s is not a directory group setting", setting.name)
+			}
+		}
+
+		if cfg.Directories == nil {
+			cfg.Directories = make(map[string]DirectoryGroup)
+		}
+
+		cfg.Directories[groupName] = group
+	}
+
+	return remaining, nil
+}
+
+// splitHostPort splits a host:port address with a numeric port
+func splitHostPort(address string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: %w", err)
+	}
+
+	return host, port, nil
+}
+
+// setFromEnv sets the setting named by key, relative to v, to raw. key is
+// the rest of the variable name: a field's YAML name in upper case, a map
+// key or a slice index, then the rest of the path. It reports whether key
+// named a setting at all.
+func setFromEnv(v reflect.Value, key, raw string) (bool, error) {
+	if key == "" {
+		return true, setEnvValue(v, raw)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return false, nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return setFromEnv(v.Elem(), key, raw)
+
+	case reflect.Struct:
+		return setStructFromEnv(v, key, raw)
+
+	case reflect.Map:
+		return setMapFromEnv(v, key, raw)
+
+	case reflect.Slice:
+		return setSliceFromEnv(v, key, raw)
+	}
+
+	return false, nil
+}
+
+// setStructFromEnv matches key against the fields of a struct. Fields the
+// YAML skips can still be named with an env tag, and env:"-" hides a field.
+func setStructFromEnv(v reflect.Value, key, raw string) (bool, error) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+
+		if options == "inline" {
+			if matched, err := setFromEnv(v.Field(i), key, raw); matched {
+				return true, err
+			}
+
+			continue
+		}
+
+		switch {
+		case field.Tag.Get("env") == "-":
+			continue
+		case field.Tag.Get("env") != "":
+			name = field.Tag.Get("env")
+		case name == "-":
+			continue
+		case name == "":
+			name = strings.ToLower(field.Name)
+		}
+
+		name = envName(name)
+
+		if key == name {
+			return true, setEnvValue(v.Field(i), raw)
+		}
+
+		if rest, found := strings.CutPrefix(key, name+"_"); found {
+			if matched, err := setFromEnv(v.Field(i), rest, raw); matched {
+				return true, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// setMapFromEnv sets an entry of a map with string keys. A key already in the
+// map, e.g. a directory group from the file, is matched case-insensitively;
+// otherwise a new entry is added under the lower case name. For maps of
+// structs the entry's key is the shortest prefix that leaves a field name.
+func setMapFromEnv(v reflect.Value, key, raw string) (bool, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return false, nil
+	}
+
+	elemType := v.Type().Elem()
+	nested := elemType.Kind() == reflect.Struct || (elemType.Kind() == reflect.Pointer && elemType.Elem().Kind() == reflect.Struct)
+
+	// Copy an entry out, since map values can't be set in place
+	apply := func(mapKey, rest string, existing reflect.Value) (bool, error) {
+		elem := reflect.New(elemType).Elem()
+		if existing.IsValid() {
+			elem.Set(existing)
+		}
+
+		matched, err := setFromEnv(elem, rest, raw)
+		if !matched || err != nil {
+			return matched, err
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		v.SetMapIndex(reflect.ValueOf(mapKey).Convert(v.Type().Key()), elem)
+
+		return true, nil
+	}
+
+	// Longest existing keys first, so "home" doesn't claim HOME_CACHE_*
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i].String()) > len(keys[j].String()) })
+
+	for _, existing := range keys {
+		name := envName(existing.String())
+
+		if !nested {
+			if key == name {
+				return apply(existing.String(), "", v.MapIndex(existing))
+			}
+
+			continue
+		}
+
+		if rest, found := strings.CutPrefix(key, name+"_"); found {
+			if matched, err := apply(existing.String(), rest, v.MapIndex(existing)); matched {
+				return true, err
+			}
+		}
+	}
+
+	if !nested {
+		return apply(strings.ToLower(key), "", reflect.Value{})
+	}
+
+	for i := range len(key) {
+		if key[i] != '_' {
+			continue
+		}
+
+		if matched, err := apply(strings.ToLower(key[:i]), key[i+1:], reflect.Value{}); matched {
+			return true, err
+		}
+	}
+
+	return false, nil
+}
+
+// setSliceFromEnv sets a field of an element of a slice of structs, given by
+// its index. The slice grows to reach the index.
+func setSliceFromEnv(v reflect.Value, key, raw string) (bool, error) {
+	indexStr, rest, _ := strings.Cut(key, "_")
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil || index < 0 || rest == "" {
+		return false, nil
+	}
+
+	for v.Len() <= index {
+		v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+	}
+
+	return setFromEnv(v.Index(index), rest, raw)
+}
+
+// setEnvValue sets a whole setting from a variable's value. Strings are taken
+// as they are and lists of strings are comma separated; anything else, from
+// durations and sizes to whole sections, is parsed as YAML.
+func setEnvValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+		return nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "["):
+		values := reflect.MakeSlice(v.Type(), 0, 0)
+
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = reflect.Append(values, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+
+		v.Set(values)
+
+		return nil
+	}
+
+	return yaml.Unmarshal([]byte(raw), v.Addr().Interface())
+}
+
+// envName is how a YAML key or map key appears in a variable name
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includedConfig is what an included file may define
+type includedConfig struct {
+	Filesystems []FilesystemConfig   `yaml:"filesystems"`
+	Directories map[string]yaml.Node `yaml:"directories"` // Decoded over the main file's defaults
+}
+
+// loadIncludes merges the filesystems and directory groups of the files
+// matched by include into c. Relative patterns are resolved against the
+// directory of the main config file, files are read in lexical order and a
+// name defined twice is an error rather than silently overridden.
+func (c *Config) loadIncludes(configPath string) error {
+	if len(c.Include) == 0 {
+		return nil
+	}
+
+	sources := make(map[string]string)
+
+	for _, fs := range c.Filesystems {
+		sources["filesystem '"+fs.Name+"'"] = configPath
+	}
+
+	for name := range c.Directories {
+		sources["directory group '"+name+"'"] = configPath
+	}
+
+	define := func(key, path string) error {
+		if previous, exists := sources[key]; exists {
+			return fmt.Errorf("%s in %s is already defined in %s", key, path, previous)
+		}
+
+		sources[key] = path
+
+		return nil
+	}
+
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configPath), pattern)
+		}
+
+		// A pattern that matches nothing is fine, e.g. an empty conf.d
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %s: %w", pattern, err)
+		}
+
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			included, err := readIncludedConfig(path)
+			if err != nil {
+				return err
+			}
+
+			for _, fs := range included.Filesystems {
+				if err := define("filesystem '"+fs.Name+"'", path); err != nil {
+					return err
+				}
+
+				c.Filesystems = append(c.Filesystems, fs)
+			}
+
+			for name, node := range included.Directories {
+				if err := define("directory group '"+name+"'", path); err != nil {
+					return err
+				}
+
+				group, err := c.decodeDirectoryGroup(&node)
+				if err != nil {
+					return fmt.Errorf("failed to parse directory group '%s' in %s: %w", name, path, err)
+				}
+
+				if c.Directories == nil {
+					c.Directories = make(map[string]DirectoryGroup)
+				}
+
+				c.Directories[name] = group
+			}
+		}
+	}
+
+	return nil
+}
+
+// readIncludedConfig parses an included file, rejecting any other settings
+// so they aren't mistaken for overrides of the main file
+func readIncludedConfig(path string) (*includedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read included file %s: %w", path, err)
+	}
+
+	var included includedConfig
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	// An empty file, or one that's all comments, decodes to io.EOF
+	if err := decoder.Decode(&included); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse included file %s (only filesystems and directories are allowed): %w", path, err)
+	}
+
+	return &included, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ValidatePath checks a directory group's path: it must be absolute and
+// clean, and one of allowedRoots or below it when any are given. Names may
+// otherwise contain anything, brackets, spaces and tildes included, since
+// commands are run without a shell and the path is always given to them as
+// a single argument. A trailing separator is allowed, as older configs have
+// them.
+func ValidatePath(path string, allowedRoots []string) error {
+	if strings.ContainsRune(path, 0) {
+		return fmt.Errorf("path contains a NUL byte: %q", path)
+	}
+
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path must be absolute: %s", path)
+	}
+
+	trimmed := path
+	if len(trimmed) > 1 && strings.HasSuffix(trimmed, string(filepath.Separator)) && filepath.Dir(trimmed) != trimmed {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+
+	if clean := filepath.Clean(path); clean != trimmed {
+		return fmt.Errorf("path must be clean, without '.' or '..' elements or repeated separators (use %s): %s", clean, path)
+	}
+
+	if len(allowedRoots) > 0 && !withinRoots(filepath.Clean(path), allowedRoots) {
+		return fmt.Errorf("path is outside security.allowed_roots: %s", path)
+	}
+
+	return nil
+}
+
+// ValidateResolvedPath checks that path, with its symlinks resolved, is still
+// within allowedRoots, so a link can't lead a scan out of them. The path
+// must exist.
+func ValidateResolvedPath(path string, allowedRoots []string) error {
+	if len(allowedRoots) == 0 {
+		return nil
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	roots := make([]string, 0, len(allowedRoots))
+
+	for _, root := range allowedRoots {
+		if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolvedRoot
+		}
+
+		roots = append(roots, root)
+	}
+
+	if !withinRoots(resolved, roots) {
+		return fmt.Errorf("path resolves to %s, outside security.allowed_roots: %s", resolved, path)
+	}
+
+	return nil
+}
+
+// checkAllowed rejects a configured path outside allowed_roots
+func (s SecurityConfig) checkAllowed(path string) error {
+	if len(s.AllowedRoots) == 0 || withinRoots(filepath.Clean(path), s.AllowedRoots) {
+		return nil
+	}
+
+	return fmt.Errorf("path is outside security.allowed_roots: %s", path)
+}
+
+// withinRoots reports whether path is one of roots or below one
+func withinRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		root = filepath.Clean(root)
+
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryConfig is how a failed collection is retried within the same job.
+// Settings left unset on an item fall back to the global retry section.
+type RetryConfig struct {
+	MaxAttempts  int      `yaml:"max_attempts"`  // Attempts in all, including the first (default: 1, no retries)
+	InitialDelay Duration `yaml:"initial_delay"` // Wait before the first retry (default: 2s)
+	MaxDelay     Duration `yaml:"max_delay"`     // Longest wait between attempts (default: 30s)
+	Multiplier   float64  `yaml:"multiplier"`    // Growth of the wait after each retry (default: 2)
+}
+
+// withDefaults fills the settings r leaves unset from defaults
+func (r RetryConfig) withDefaults(defaults RetryConfig) RetryConfig {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if r.InitialDelay.Duration == 0 {
+		r.InitialDelay = defaults.InitialDelay
+	}
+
+	if r.MaxDelay.Duration == 0 {
+		r.MaxDelay = defaults.MaxDelay
+	}
+
+	if r.Multiplier == 0 {
+		r.Multiplier = defaults.Multiplier
+	}
+
+	return r
+}
+
+// validate checks the settings r sets, so items can leave any unset
+func (r RetryConfig) validate() error {
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts cannot be negative, got %d", r.MaxAttempts)
+	}
+
+	if r.InitialDelay.Duration < 0 || r.MaxDelay.Duration < 0 {
+		return fmt.Errorf("initial_delay and max_delay cannot be negative")
+	}
+
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1, got %g", r.Multiplier)
+	}
+
+	return nil
+}
+
+// String summarizes the policy for the displayed config
+func (r RetryConfig) String() string {
+	if r.MaxAttempts <= 1 {
+		return "off"
+	}
+
+	return fmt.Sprintf("%d attempts, %s to %s x%g", r.MaxAttempts, r.InitialDelay.Duration, r.MaxDelay.Duration, r.Multiplier)
+}
+
+// GetFilesystemRetry returns a filesystem's retry policy, with unset
+// settings taken from the global retry section
+func (c *Config) GetFilesystemRetry(fs FilesystemConfig) RetryConfig {
+	return fs.Retry.withDefaults(c.Retry)
+}
+
+// GetDirectoryRetry returns a directory group's retry policy, with unset
+// settings taken from the global retry section
+func (c *Config) GetDirectoryRetry(group DirectoryGroup) RetryConfig {
+	return group.Retry.withDefaults(c.Retry)
+}
+
+// defaultRetry is used for the global retry settings left unset
+var defaultRetry = RetryConfig{
+	MaxAttempts:  1,
+	InitialDelay: Duration{Duration: 2 * time.Second},
+	MaxDelay:     Duration{Duration: 30 * time.Second},
+	Multiplier:   2,
+}
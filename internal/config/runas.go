@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// RunAs is the user and group a command is started as
+type RunAs struct {
+	UID uint32
+	GID uint32
+}
+
+// ResolveRunAs looks up a run_as_user and run_as_group, each a name or a
+// numeric ID. Without a group, the user's primary group is used. It returns
+// nil when neither is set.
+func ResolveRunAs(userName, groupName string) (*RunAs, error) {
+	if userName == "" && groupName == "" {
+		return nil, nil
+	}
+
+	if userName == "" {
+		return nil, fmt.Errorf("run_as_group '%s' needs a run_as_user", groupName)
+	}
+
+	u, err := lookupUser(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user '%s' has a non-numeric uid '%s'", userName, u.Uid)
+	}
+
+	gidStr := u.Gid
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+
+		gidStr = g.Gid
+	}
+
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("group of '%s' has a non-numeric gid '%s'", userName, gidStr)
+	}
+
+	return &RunAs{UID: uint32(uid), GID: uint32(gid)}, nil
+}
+
+// lookupUser finds a user by name, or by uid when the name is numeric
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+
+	if _, numeric := strconv.ParseUint(name, 10, 32); numeric == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+
+		// An ID with no passwd entry is still a valid uid to run as
+		return &user.User{Uid: name, Gid: name}, nil
+	}
+
+	return nil, fmt.Errorf("unknown run_as_user '%s': %w", name, err)
+}
+
+// lookupGroup finds a group by name, or by gid when the name is numeric
+func lookupGroup(name string) (*user.Group, error) {
+	g, err := user.LookupGroup(name)
+	if err == nil {
+		return g, nil
+	}
+
+	if _, numeric := strconv.ParseUint(name, 10, 32); numeric == nil {
+		return &user.Group{Gid: name}, nil
+	}
+
+	return nil, fmt.Errorf("unknown run_as_group '%s': %w", name, err)
+}
+
+// GetDirectoryRunAs returns who a directory group's commands run as: the
+// group's run_as_user and run_as_group if set, or else security's. Both
+// were resolved by validation, so errors only come from the user database
+// changing since.
+func (c *Config) GetDirectoryRunAs(groupName string) (*RunAs, error) {
+	if group, exists := c.Directory(groupName); exists && group.RunAsUser != "" {
+		return ResolveRunAs(group.RunAsUser, group.RunAsGroup)
+	}
+
+	return ResolveRunAs(c.Security.RunAsUser, c.Security.RunAsGroup)
+}
+
+// CheckRoot refuses to run as root, given the effective uid, unless
+// security.allow_root says to. security.run_as_user isn't enough, since it
+// only changes who df, du and exec commands run as: walks, probes and
+// everything else the exporter does itself would still run as root.
+func (c *Config) CheckRoot(euid int) error {
+	if euid == 0 && !c.Security.AllowRoot {
+		return errors.New("running as root; run as an unprivileged user or set security.allow_root (security.run_as_user only applies to df, du and exec commands)")
+	}
+
+	return nil
+}
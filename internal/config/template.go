@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is the data available to templated names and paths, e.g.
+// "/srv/{{ .Hostname }}" or "{{ .Env.SITE }}-home"
+type TemplateData struct {
+	Hostname string
+	Env      map[string]string
+}
+
+// newTemplateData captures the hostname and environment of the current process
+func newTemplateData() (*TemplateData, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine hostname: %w", err)
+	}
+
+	env := make(map[string]string)
+
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+
+	return &TemplateData{Hostname: hostname, Env: env}, nil
+}
+
+// expandTemplate renders a single value. Strings without template actions are
+// returned unchanged and referencing an unset environment variable is an error.
+func expandTemplate(value string, data *TemplateData) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tmpl, err := template.New("config").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid template '%s': %w", value, err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to expand template '%s': %w", value, err)
+	}
+
+	return out.String(), nil
+}
+
+// expandTemplates resolves templated filesystem and directory names and paths
+func expandTemplates(config *Config, data *TemplateData) error {
+	for i := range config.Filesystems {
+		fs := &config.Filesystems[i]
+
+		for _, field := range []*string{&fs.Name, &fs.MountPoint, &fs.Device} {
+			expanded, err := expandTemplate(*field, data)
+			if err != nil {
+				return fmt.Errorf("filesystem '%s': %w", fs.Name, err)
+			}
+
+			*field = expanded
+		}
+	}
+
+	if len(config.Directories) == 0 {
+		return nil
+	}
+
+	directories := make(map[string]DirectoryGroup, len(config.Directories))
+
+	for name, group := range config.Directories {
+		expandedName, err := expandTemplate(name, data)
+		if err != nil {
+			return fmt.Errorf("directory '%s': %w", name, err)
+		}
+
+		group.Path, err = expandTemplate(group.Path, data)
+		if err != nil {
+			return fmt.Errorf("directory '%s': %w", name, err)
+		}
+
+		if _, exists := directories[expandedName]; exists {
+			return fmt.Errorf("directory '%s' expands to duplicate name '%s'", name, expandedName)
+		}
+
+		directories[expandedName] = group
+	}
+
+	config.Directories = directories
+
+	return nil
+}
@@ -2,21 +2,48 @@ package coordinator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"runtime"
+	"sync"
 	"time"
 
+	"filesystem-exporter/internal/alerts"
+	"filesystem-exporter/internal/collectors/btrfs"
+	"filesystem-exporter/internal/collectors/bucket"
+	"filesystem-exporter/internal/collectors/docker"
+	"filesystem-exporter/internal/collectors/kubernetes"
+	"filesystem-exporter/internal/collectors/mountprobe"
+	"filesystem-exporter/internal/collectors/quota"
+	"filesystem-exporter/internal/collectors/zfs"
 	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/growth"
+	"filesystem-exporter/internal/limits"
 	"filesystem-exporter/internal/metrics"
 	"filesystem-exporter/internal/queue"
 	"filesystem-exporter/internal/scheduler"
 	"filesystem-exporter/internal/state"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/walk"
 	"filesystem-exporter/internal/worker"
-	"github.com/d0ugal/promexporter/tracing"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrDirectoryBusy is returned by SetDirectory while a job for the group is
+// queued or running, since the new group's first job would run alongside it
+var ErrDirectoryBusy = errors.New("directory group is being collected, try again once its job is over")
+
+// collector is a self-scheduling collector that runs alongside the
+// filesystem and directory queues until its context is cancelled
+type collector interface {
+	Start(ctx context.Context)
+	Wait()
+	Collect(ctx context.Context)
+}
+
 // Coordinator coordinates all components
 type Coordinator struct {
 	config  *config.Config
@@ -34,23 +61,107 @@ type Coordinator struct {
 
 	// Scheduler
 	scheduler *scheduler.Scheduler
+
+	// Additional collectors (mount probe, zfs, btrfs, quotas, kubernetes, docker, buckets)
+	collectors []collector
+
+	// Threshold alerts, nil unless enabled
+	alerts *alerts.Manager
+
+	// Growth estimates, nil unless enabled
+	growth *growth.Tracker
+
+	// Lifecycle: cancel is non-nil while running, along with runCtx, the
+	// context Start was given; wg tracks the coordinator's own goroutines
+	lifecycleMutex sync.Mutex
+	cancel         context.CancelFunc
+	runCtx         context.Context
+	wg             sync.WaitGroup
+
+	// Closed when the refresh of on_scrape filesystems in progress is over;
+	// nil when none is
+	scrapeMutex sync.Mutex
+	scrapeDone  chan struct{}
 }
 
-// NewCoordinator creates a new coordinator
-func NewCoordinator(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Coordinator {
+// NewCoordinator creates a new coordinator. limiter may be nil when no
+// command limits are configured.
+func NewCoordinator(cfg *config.Config, m *metrics.FilesystemRegistry, limiter *limits.Limiter, tracer *tracing.Tracer) *Coordinator {
 	// Create state tracker
 	stateTracker := state.NewTracker(tracer)
 
 	// Create queues
-	fsQueue := queue.NewQueue("filesystem", 100, stateTracker, tracer)
-	dirQueue := queue.NewQueue("directory", 100, stateTracker, tracer)
+	fsQueue := queue.NewQueue("filesystem", cfg.Queue.Size, cfg.Queue.OverflowPolicy, stateTracker, m, tracer)
+	dirQueue := queue.NewQueue("directory", cfg.Queue.Size, cfg.Queue.OverflowPolicy, stateTracker, m, tracer)
+
+	var alertManager *alerts.Manager
+	if cfg.Alerts.Enabled {
+		alertManager = alerts.NewManager(cfg, m)
+	}
+
+	var growthTracker *growth.Tracker
+	if cfg.Growth.Enabled {
+		growthTracker = growth.NewTracker(cfg, m)
+	}
 
 	// Create workers
-	fsWorker := worker.NewWorker(fsQueue, m, stateTracker, cfg, tracer, "filesystem")
-	dirWorker := worker.NewWorker(dirQueue, m, stateTracker, cfg, tracer, "directory")
+	fsWorker := worker.NewWorker(fsQueue, m, stateTracker, cfg, limiter, alertManager, growthTracker, tracer, "filesystem")
+	dirWorker := worker.NewWorker(dirQueue, m, stateTracker, cfg, limiter, alertManager, growthTracker, tracer, "directory")
+
+	if cfg.DirectoryTimestamps.Enabled {
+		if err := m.EnableDirectoryTimestamps(); err != nil {
+			slog.Warn("Failed to enable directory timestamps, exposing directory sizes at scrape time", "error", err)
+		}
+	}
+
+	// Exported whether or not any group is sandboxed, to check a host first
+	if walk.SandboxSupported() {
+		m.WalkSandboxGauge.Set(1)
+	} else {
+		m.WalkSandboxGauge.Set(0)
+
+		for name, group := range cfg.DirectoryGroups() {
+			if group.Sandbox {
+				slog.Warn("Landlock is not available, so the sandboxed group's walks will fail", "group", name)
+			}
+		}
+	}
+
+	// Create optional collectors
+	var collectors []collector
+
+	var prober *mountprobe.Prober
+	if cfg.MountProbe.Enabled {
+		prober = mountprobe.NewProber(cfg, m, tracer)
+		collectors = append(collectors, prober)
+	}
 
 	// Create scheduler
-	sched := scheduler.NewScheduler(cfg, m, stateTracker, fsQueue, dirQueue, tracer)
+	sched := scheduler.NewScheduler(cfg, m, stateTracker, fsQueue, dirQueue, prober, tracer)
+
+	if cfg.ZFS.Enabled {
+		collectors = append(collectors, zfs.NewCollector(cfg, m, tracer))
+	}
+
+	if cfg.Btrfs.Enabled {
+		collectors = append(collectors, btrfs.NewCollector(cfg, m, tracer))
+	}
+
+	if cfg.Quotas.Enabled {
+		collectors = append(collectors, quota.NewCollector(cfg, m, tracer))
+	}
+
+	if cfg.Kubernetes.Enabled {
+		collectors = append(collectors, kubernetes.NewCollector(cfg, m, tracer))
+	}
+
+	if cfg.Docker.Enabled {
+		collectors = append(collectors, docker.NewCollector(cfg, m, tracer))
+	}
+
+	if cfg.Buckets.Enabled {
+		collectors = append(collectors, bucket.NewCollector(cfg, m, tracer))
+	}
 
 	return &Coordinator{
 		config:           cfg,
@@ -62,15 +173,30 @@ func NewCoordinator(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *t
 		filesystemWorker: fsWorker,
 		directoryWorker:  dirWorker,
 		scheduler:        sched,
+		collectors:       collectors,
+		alerts:           alertManager,
+		growth:           growthTracker,
 	}
 }
 
 // Start starts all components. The supplied context controls the lifetime of
 // every goroutine spawned by the coordinator (workers, scheduler tickers,
 // goroutine-count updater, queue-depth updater). Cancelling it shuts everything
-// down cleanly; this is what allows promexporter's app.Run() to manage our
-// lifecycle via app.WithCollector.
+// down cleanly; this is what allows the exporter's run loop to manage our
+// lifecycle like any other collector. Calling Start while already running is
+// a no-op; after Stop the coordinator can be started again.
 func (c *Coordinator) Start(ctx context.Context) {
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if c.cancel != nil {
+		slog.Warn("Coordinator already started")
+		return
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.runCtx = ctx
+
 	ctx, span := c.startSpan(ctx, "coordinator.start")
 	defer span.End()
 
@@ -83,20 +209,304 @@ func (c *Coordinator) Start(ctx context.Context) {
 	// Start scheduler
 	c.scheduler.Start(ctx)
 
+	// Start additional collectors
+	for _, col := range c.collectors {
+		col.Start(ctx)
+	}
+
+	if c.alerts != nil {
+		c.alerts.Start(ctx)
+	}
+
+	if c.growth != nil {
+		c.growth.Start(ctx)
+	}
+
+	c.wg.Add(3)
+
 	// Start goroutine count updater
-	go c.updateGoroutineCount(ctx)
+	go func() {
+		defer c.wg.Done()
+		c.updateGoroutineCount(ctx)
+	}()
 
 	// Start queue depth updater
-	go c.updateQueueDepths(ctx)
+	go func() {
+		defer c.wg.Done()
+		c.updateQueueDepths(ctx)
+	}()
+
+	// Start the supervisor of stuck workers
+	go func() {
+		defer c.wg.Done()
+		c.supervise(ctx)
+	}()
 
 	span.AddEvent("coordinator_started")
 	slog.Info("Coordinator started")
 }
 
-// Stop satisfies the promexporter app.Collector interface. All shutdown work is
-// driven by the context cancellation propagated from app.Run, so this is a
-// no-op — every goroutine spawned by Start respects ctx.Done().
-func (c *Coordinator) Stop() {}
+// Stop cancels the context owned by Start and blocks until every component has
+// exited. Tickers stop and no new jobs are started, but jobs in progress get
+// up to shutdown.drain_timeout to finish before they are aborted; jobs still
+// queued are discarded. It is safe to call more than once and after the
+// parent context has already been cancelled by the run loop.
+func (c *Coordinator) Stop() {
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if c.cancel == nil {
+		return
+	}
+
+	slog.Info("Stopping coordinator")
+
+	c.cancel()
+	c.cancel = nil
+	c.runCtx = nil
+
+	c.drain()
+	c.scheduler.Wait()
+
+	for _, col := range c.collectors {
+		col.Wait()
+	}
+
+	if c.alerts != nil {
+		c.alerts.Wait()
+	}
+
+	// Saved once the drained jobs have added their samples
+	if c.growth != nil {
+		c.growth.Wait()
+
+		if err := c.growth.Save(); err != nil {
+			slog.Warn("Failed to save growth history", "file", c.config.Growth.StateFile, "error", err)
+		}
+	}
+
+	c.wg.Wait()
+
+	slog.Info("Coordinator stopped")
+}
+
+// drain waits for the workers to finish the jobs they are running, aborting
+// those still running after the drain timeout, then discards the jobs left in
+// the queues so their results and spans are settled before exit
+func (c *Coordinator) drain() {
+	ctx := context.Background()
+
+	timeout := c.config.Shutdown.DrainTimeout.Duration
+	running := len(c.state.GetRunningJobs(ctx, "filesystem")) + len(c.state.GetRunningJobs(ctx, "directory"))
+
+	if running > 0 {
+		slog.Info("Waiting for running collections to finish", "running", running, "drain_timeout", timeout)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		c.filesystemWorker.Wait()
+		c.directoryWorker.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		slog.Warn("Aborting collections still running after the drain timeout",
+			"running", len(c.state.GetRunningJobs(ctx, "filesystem"))+len(c.state.GetRunningJobs(ctx, "directory")),
+			"drain_timeout", timeout,
+		)
+
+		c.filesystemWorker.Abort()
+		c.directoryWorker.Abort()
+
+		<-done
+	}
+
+	// Releases the job contexts of workers that drained in time
+	c.filesystemWorker.Abort()
+	c.directoryWorker.Abort()
+
+	if discarded := c.filesystemQueue.Discard(ctx) + c.directoryQueue.Discard(ctx); discarded > 0 {
+		slog.Info("Discarded queued collections", "count", discarded)
+	}
+}
+
+// superviseInterval is how often the workers' run loops are checked for
+// stuck jobs
+const superviseInterval = 30 * time.Second
+
+// CheckLiveness returns an error if the coordinator isn't running, a worker
+// has a stuck job the supervisor hasn't dealt with, or the scheduler has
+// stopped scheduling an item, for the systemd watchdog
+func (c *Coordinator) CheckLiveness(ctx context.Context) error {
+	c.lifecycleMutex.Lock()
+	running := c.cancel != nil
+	c.lifecycleMutex.Unlock()
+
+	if !running {
+		return errors.New("coordinator not running")
+	}
+
+	now := time.Now()
+
+	var errs []error
+
+	workers := map[string]*worker.Worker{"filesystem": c.filesystemWorker, "directory": c.directoryWorker}
+
+	for _, queueType := range []string{"filesystem", "directory"} {
+		for _, job := range c.state.GetRunningJobs(ctx, queueType) {
+			stuckAfter := workers[queueType].StuckAfter(job.Type, job.Name, job.Timeout)
+			if stuckAfter > 0 && now.Sub(job.StartedAt) > stuckAfter+2*superviseInterval {
+				errs = append(errs, fmt.Errorf("%s worker stuck on %s for %s", queueType, job.Name, now.Sub(job.StartedAt).Round(time.Second)))
+			}
+		}
+	}
+
+	if err := c.scheduler.CheckLiveness(now); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// RunOnce collects every configured item a single time instead of starting
+// the schedule, and returns the items that failed. The additional collectors
+// run first so mount probe results are known before any item is scanned.
+func (c *Coordinator) RunOnce(ctx context.Context) []*state.ItemState {
+	ctx, span := c.startSpan(ctx, "coordinator.run_once")
+	defer span.End()
+
+	var wg sync.WaitGroup
+
+	for _, col := range c.collectors {
+		wg.Go(func() { col.Collect(ctx) })
+	}
+
+	wg.Wait()
+
+	var filesystemJobs, directoryJobs []queue.Job
+
+	for _, job := range c.scheduler.Jobs(ctx) {
+		if job.Type == "filesystem" {
+			filesystemJobs = append(filesystemJobs, job)
+		} else {
+			directoryJobs = append(directoryJobs, job)
+		}
+	}
+
+	slog.Info("Collecting every item once",
+		"filesystems", len(filesystemJobs),
+		"directories", len(directoryJobs),
+	)
+
+	// One job at a time per type, as with the queues
+	wg.Go(func() {
+		for _, job := range filesystemJobs {
+			c.filesystemWorker.Process(ctx, job)
+		}
+	})
+
+	wg.Go(func() {
+		for _, job := range directoryJobs {
+			c.directoryWorker.Process(ctx, job)
+		}
+	})
+
+	wg.Wait()
+
+	return c.state.FailingItems(ctx)
+}
+
+// RefreshOnScrape collects the on_scrape filesystems whose latest collection
+// is older than scrape_collection.cache_ttl, for a scrape of /metrics. It
+// waits up to scrape_collection.budget; collections still running then carry
+// on, and the scrape gets the values from before. A scrape arriving while a
+// refresh is in progress waits for that one instead of starting another.
+func (c *Coordinator) RefreshOnScrape(ctx context.Context) {
+	ctx, span := c.startSpan(ctx, "coordinator.refresh_on_scrape")
+	defer span.End()
+
+	c.lifecycleMutex.Lock()
+
+	if c.cancel == nil {
+		c.lifecycleMutex.Unlock()
+		return
+	}
+
+	c.scrapeMutex.Lock()
+
+	done := c.scrapeDone
+	if done == nil {
+		done = make(chan struct{})
+		c.scrapeDone = done
+
+		// The jobs outlive the scrape, so they are cancelled along with the
+		// coordinator rather than the request
+		jobs := c.scrapeJobs(trace.ContextWithSpan(c.runCtx, span))
+		span.SetAttributes(attribute.Int("scrape.jobs", len(jobs)))
+
+		c.wg.Go(func() {
+			var wg sync.WaitGroup
+
+			for _, job := range jobs {
+				wg.Go(func() {
+					defer c.scheduler.ClearRunning("filesystem", job.Name)
+					c.filesystemWorker.Process(job.Context, job)
+				})
+			}
+
+			wg.Wait()
+
+			c.scrapeMutex.Lock()
+			c.scrapeDone = nil
+			c.scrapeMutex.Unlock()
+
+			close(done)
+		})
+	}
+
+	c.scrapeMutex.Unlock()
+	c.lifecycleMutex.Unlock()
+
+	timer := time.NewTimer(c.config.ScrapeCollection.Budget.Duration)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		slog.Debug("Serving scrape before its collections finished", "budget", c.config.ScrapeCollection.Budget.Duration)
+		span.SetAttributes(attribute.Bool("scrape.over_budget", true))
+	case <-ctx.Done():
+	}
+}
+
+// scrapeJobs returns jobs for the enabled on_scrape filesystems not collected
+// within scrape_collection.cache_ttl, skipping those already being collected
+func (c *Coordinator) scrapeJobs(ctx context.Context) []queue.Job {
+	var jobs []queue.Job
+
+	for _, fs := range c.config.Filesystems {
+		if !fs.IsEnabled() || fs.Collection != config.CollectionModeOnScrape {
+			continue
+		}
+
+		if item := c.state.GetItemState(ctx, "filesystem", fs.Name); item != nil && time.Since(item.LastEndTime) < c.config.ScrapeCollection.CacheTTL.Duration {
+			continue
+		}
+
+		if job, ok := c.scheduler.ScrapeJob(ctx, fs); ok {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
 
 // updateGoroutineCount periodically updates goroutine count metric
 func (c *Coordinator) updateGoroutineCount(ctx context.Context) {
@@ -114,6 +524,22 @@ func (c *Coordinator) updateGoroutineCount(ctx context.Context) {
 	}
 }
 
+// supervise periodically restarts worker run loops stuck on a job
+func (c *Coordinator) supervise(ctx context.Context) {
+	ticker := time.NewTicker(superviseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.filesystemWorker.Supervise(ctx, now)
+			c.directoryWorker.Supervise(ctx, now)
+		}
+	}
+}
+
 // updateQueueDepths periodically updates queue depth metrics
 func (c *Coordinator) updateQueueDepths(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
@@ -130,20 +556,11 @@ func (c *Coordinator) updateQueueDepths(ctx context.Context) {
 			c.metrics.QueueDepthGauge.With(prometheus.Labels{"queue_type": "filesystem"}).Set(float64(fsDepth))
 			c.metrics.QueueDepthGauge.With(prometheus.Labels{"queue_type": "directory"}).Set(float64(dirDepth))
 
-			// Update collection active metric
-			fsRunning := c.state.GetRunningJob(ctx, "filesystem")
-			dirRunning := c.state.GetRunningJob(ctx, "directory")
-
-			if fsRunning != nil {
-				c.metrics.CollectionActiveGauge.With(prometheus.Labels{"queue_type": "filesystem"}).Set(1)
-			} else {
-				c.metrics.CollectionActiveGauge.With(prometheus.Labels{"queue_type": "filesystem"}).Set(0)
-			}
-
-			if dirRunning != nil {
-				c.metrics.CollectionActiveGauge.With(prometheus.Labels{"queue_type": "directory"}).Set(1)
-			} else {
-				c.metrics.CollectionActiveGauge.With(prometheus.Labels{"queue_type": "directory"}).Set(0)
+			// Update collection active metric; directory jobs on different
+			// devices can run at once with device_concurrency
+			for _, queueType := range []string{"filesystem", "directory"} {
+				running := c.state.GetRunningJobs(ctx, queueType)
+				c.metrics.CollectionActiveGauge.With(prometheus.Labels{"queue_type": queueType}).Set(float64(len(running)))
 			}
 		}
 	}
@@ -154,6 +571,117 @@ func (c *Coordinator) GetState(ctx context.Context) map[string]any {
 	return c.state.GetAllStates(ctx)
 }
 
+// GetItems returns the state of every item with the given name. An empty
+// itemType matches both filesystems and directories.
+func (c *Coordinator) GetItems(ctx context.Context, itemType, name string) []*state.ItemState {
+	return c.state.FindItems(ctx, itemType, name)
+}
+
+// GetAllItems returns the state of every item
+func (c *Coordinator) GetAllItems(ctx context.Context) []*state.ItemState {
+	return c.state.AllItems(ctx)
+}
+
+// GetFailingItems returns the state of every item whose latest job failed
+func (c *Coordinator) GetFailingItems(ctx context.Context) []*state.ItemState {
+	return c.state.FailingItems(ctx)
+}
+
+// SubscribeEvents returns a channel receiving every job lifecycle event from
+// now on, and a function to stop receiving them
+func (c *Coordinator) SubscribeEvents() (<-chan state.Event, func()) {
+	return c.state.Subscribe()
+}
+
+// SetDirectory adds a directory group from its YAML or JSON settings, or
+// replaces one added before, and starts collecting it if the coordinator is
+// running. It reports whether the group is new, and returns ErrDirectoryBusy
+// while a job for the group is queued or running.
+func (c *Coordinator) SetDirectory(ctx context.Context, name string, data []byte) (bool, error) {
+	ctx, span := c.startSpan(ctx, "coordinator.set_directory")
+	defer span.End()
+
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	// Including a group deleted while its job was still running
+	if c.scheduler.DirectoryBusy(name) {
+		span.RecordError(ErrDirectoryBusy)
+		return false, ErrDirectoryBusy
+	}
+
+	created, err := c.config.SetDirectory(name, data)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+
+	// A replaced group starts over, without the series of its old settings
+	if !created {
+		c.scheduler.RemoveDirectory(ctx, name)
+	}
+
+	if c.cancel != nil {
+		c.scheduler.AddDirectory(c.runCtx, name)
+	}
+
+	slog.Info("Directory group set through the API", "group", name, "created", created)
+
+	return created, nil
+}
+
+// DeleteDirectory removes a directory group added by SetDirectory, stopping
+// its collection and deleting its series
+func (c *Coordinator) DeleteDirectory(ctx context.Context, name string) error {
+	ctx, span := c.startSpan(ctx, "coordinator.delete_directory")
+	defer span.End()
+
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if err := c.config.DeleteDirectory(name); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	c.scheduler.RemoveDirectory(ctx, name)
+
+	slog.Info("Directory group deleted through the API", "group", name)
+
+	return nil
+}
+
+// Diagnostics returns everything the exporter knows about its collection:
+// the state of every item, the scheduler's tickers and the depth of each
+// queue, for dumping when the API can't be reached
+func (c *Coordinator) Diagnostics(ctx context.Context) map[string]any {
+	diagnostics := c.state.GetAllStates(ctx)
+	diagnostics["scheduler"] = c.scheduler.Diagnostics()
+	diagnostics["queue_sizes"] = map[string]int{
+		"filesystem": c.filesystemQueue.Size(ctx),
+		"directory":  c.directoryQueue.Size(ctx),
+	}
+	diagnostics["goroutines"] = runtime.NumGoroutine()
+	diagnostics["time"] = time.Now()
+
+	return diagnostics
+}
+
+// CollectAll schedules a collection of every enabled item straight away. It
+// reports false when the coordinator isn't running.
+func (c *Coordinator) CollectAll() bool {
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if c.cancel == nil {
+		return false
+	}
+
+	c.scheduler.CollectAll(c.runCtx)
+
+	return true
+}
+
 // startSpan is a helper to start an OTEL span
 func (c *Coordinator) startSpan(ctx context.Context, name string, opts ...any) (context.Context, trace.Span) {
 	if c.tracer != nil && c.tracer.IsEnabled() {
@@ -2,6 +2,9 @@ package coordinator
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
@@ -15,8 +18,8 @@ import (
 // TestCoordinator_StopsOnContextCancel locks in the graceful-shutdown fix:
 // Coordinator.Start spawns goroutines (workers, scheduler tickers,
 // goroutine-count updater, queue-depth updater) under the supplied context.
-// Cancelling that context must cause every goroutine to exit, so app.Run()
-// can manage the coordinator's lifecycle via app.WithCollector.
+// Cancelling that context must cause every goroutine to exit, so the
+// exporter's run loop can manage the coordinator's lifecycle.
 func TestCoordinator_StopsOnContextCancel(t *testing.T) {
 	cfg := &config.Config{
 		BaseConfig: promexporter_config.BaseConfig{
@@ -31,7 +34,7 @@ func TestCoordinator_StopsOnContextCancel(t *testing.T) {
 
 	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_info")
 	filesystemMetrics := metrics.NewFilesystemRegistry(metricsRegistry)
-	coord := NewCoordinator(cfg, filesystemMetrics, nil)
+	coord := NewCoordinator(cfg, filesystemMetrics, nil, nil)
 
 	initial := runtime.NumGoroutine()
 
@@ -64,3 +67,329 @@ func TestCoordinator_StopsOnContextCancel(t *testing.T) {
 	t.Fatalf("coordinator goroutines did not exit after context cancellation: initial=%d, current=%d",
 		initial, runtime.NumGoroutine())
 }
+
+// TestCoordinator_StopIsIdempotentAndRestartable checks that Stop tears the
+// pipeline down without the parent context being cancelled, that repeated
+// Stop calls are safe, and that the coordinator can be started again.
+func TestCoordinator_StopIsIdempotentAndRestartable(t *testing.T) {
+	cfg := &config.Config{
+		BaseConfig: promexporter_config.BaseConfig{
+			Metrics: promexporter_config.MetricsConfig{
+				Collection: promexporter_config.CollectionConfig{
+					DefaultInterval:    promexporter_config.Duration{Duration: 30 * time.Second},
+					DefaultIntervalSet: true,
+				},
+			},
+		},
+	}
+
+	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_lifecycle_info")
+	filesystemMetrics := metrics.NewFilesystemRegistry(metricsRegistry)
+	coord := NewCoordinator(cfg, filesystemMetrics, nil, nil)
+
+	// Stop before Start must be a no-op
+	coord.Stop()
+
+	initial := runtime.NumGoroutine()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		coord.Start(ctx)
+		coord.Start(ctx) // second Start while running is ignored
+
+		coord.Stop()
+		coord.Stop()
+
+		// Stop waits for every goroutine, so the count should already be back
+		// near baseline without polling
+		if current := runtime.NumGoroutine(); current > initial+2 {
+			t.Fatalf("iteration %d: goroutines still running after Stop: initial=%d, current=%d", i, initial, current)
+		}
+	}
+}
+
+// TestCoordinator_RefreshOnScrape checks that a scrape collects on_scrape
+// filesystems again once their cached collection is older than cache_ttl
+func TestCoordinator_RefreshOnScrape(t *testing.T) {
+	cfg := &config.Config{
+		Filesystems: []config.FilesystemConfig{{
+			Name:       "scratch",
+			MountPoint: t.TempDir(),
+			Device:     "scratch",
+			Interval:   config.Duration{Duration: time.Hour},
+			Mode:       config.FilesystemModeStatfs,
+			Collection: config.CollectionModeOnScrape,
+		}},
+		ScrapeCollection: config.ScrapeCollectionConfig{
+			Budget:   config.Duration{Duration: 5 * time.Second},
+			CacheTTL: config.Duration{Duration: 300 * time.Millisecond},
+		},
+		Shutdown: config.ShutdownConfig{DrainTimeout: config.Duration{Duration: time.Second}},
+	}
+
+	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_scrape_info")
+	filesystemMetrics := metrics.NewFilesystemRegistry(metricsRegistry)
+	coord := NewCoordinator(cfg, filesystemMetrics, nil, nil)
+
+	ctx := context.Background()
+
+	// Not running yet, so there is nothing to refresh
+	coord.RefreshOnScrape(ctx)
+
+	coord.Start(ctx)
+	defer coord.Stop()
+
+	lastEnd := func() time.Time {
+		if item := coord.state.GetItemState(ctx, "filesystem", "scratch"); item != nil {
+			return item.LastEndTime
+		}
+
+		return time.Time{}
+	}
+
+	// Wait for the scheduled collection at startup
+	deadline := time.Now().Add(5 * time.Second)
+	for lastEnd().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the scheduled collection to finish")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	scheduled := lastEnd()
+
+	// Within cache_ttl the scheduled collection is reused
+	coord.RefreshOnScrape(ctx)
+
+	if lastEnd() != scheduled {
+		t.Error("expected no collection within cache_ttl")
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	coord.RefreshOnScrape(ctx)
+
+	if !lastEnd().After(scheduled) {
+		t.Error("expected the scrape to collect the filesystem once cache_ttl passed")
+	}
+}
+
+// TestCoordinator_SetDirectory checks that a directory group added at runtime
+// is registered and collected, and forgotten once deleted
+func TestCoordinator_SetDirectory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(path, []byte(`
+server:
+  auth:
+    bearer_token: secret
+api:
+  enabled: true
+  config_updates:
+    enabled: true
+directories:
+  home:
+    path: `+dir+`
+    interval: 1h
+    mode: walk
+`), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_set_directory_info")
+	filesystemMetrics := metrics.NewFilesystemRegistry(metricsRegistry)
+	coord := NewCoordinator(cfg, filesystemMetrics, nil, nil)
+
+	ctx := context.Background()
+	coord.Start(ctx)
+	defer coord.Stop()
+
+	created, err := coord.SetDirectory(ctx, "scratch", []byte("path: "+dir+"\ninterval: 1h\nmode: walk\n"))
+	if err != nil || !created {
+		t.Fatalf("expected the group to be created, got %v, %v", created, err)
+	}
+
+	// The first collection starts straight away
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		items := coord.GetItems(ctx, "directory", "scratch")
+		if len(items) == 1 && !items[0].LastSuccessTime.IsZero() {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the new group to be collected, got %+v", items)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := coord.DeleteDirectory(ctx, "scratch"); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+
+	if items := coord.GetItems(ctx, "directory", "scratch"); len(items) != 0 {
+		t.Errorf("expected the deleted group to be forgotten, got %+v", items)
+	}
+
+	if err := coord.DeleteDirectory(ctx, "home"); !errors.Is(err, config.ErrDirectoryFromFiles) {
+		t.Errorf("expected a group from the config file to be kept, got %v", err)
+	}
+}
+
+// TestCoordinator_SetDirectoryBusy checks that a group can't be replaced, or
+// added again after being deleted, while its job is still running
+func TestCoordinator_SetDirectoryBusy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mode exec is not available on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	// Groups added at runtime inherit a scanner that blocks until it's killed
+	// at shutdown
+	if err := os.WriteFile(path, []byte(`
+server:
+  auth:
+    bearer_token: secret
+api:
+  enabled: true
+  config_updates:
+    enabled: true
+shutdown:
+  drain_timeout: 10ms
+defaults:
+  mode: exec
+  command: ["/bin/sh", "-c", "exec sleep 30", "sh"]
+directories:
+  home:
+    path: `+dir+`
+    interval: 1h
+    mode: walk
+    command: []
+`), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_set_directory_busy_info")
+	coord := NewCoordinator(cfg, metrics.NewFilesystemRegistry(metricsRegistry), nil, nil)
+
+	ctx := context.Background()
+	coord.Start(ctx)
+	defer coord.Stop()
+
+	settings := []byte("path: " + dir + "\ninterval: 1h\n")
+
+	if _, err := coord.SetDirectory(ctx, "scratch", settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		items := coord.GetItems(ctx, "directory", "scratch")
+		if len(items) == 1 && items[0].Running {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the new group's job to be running, got %+v", items)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := coord.SetDirectory(ctx, "scratch", settings); !errors.Is(err, ErrDirectoryBusy) {
+		t.Errorf("expected replacing the group to wait for its job, got %v", err)
+	}
+
+	if err := coord.DeleteDirectory(ctx, "scratch"); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+
+	if _, err := coord.SetDirectory(ctx, "scratch", settings); !errors.Is(err, ErrDirectoryBusy) {
+		t.Errorf("expected adding the group again to wait for its old job, got %v", err)
+	}
+}
+
+func TestCoordinator_CollectAll(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(path, []byte(`
+directories:
+  home:
+    path: `+dir+`
+    interval: 1h
+    mode: walk
+`), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metricsRegistry := promexporter_metrics.NewRegistry("filesystem_exporter_test_collect_all_info")
+	filesystemMetrics := metrics.NewFilesystemRegistry(metricsRegistry)
+	coord := NewCoordinator(cfg, filesystemMetrics, nil, nil)
+
+	if coord.CollectAll() {
+		t.Error("expected nothing to be collected before the coordinator starts")
+	}
+
+	ctx := context.Background()
+	coord.Start(ctx)
+	defer coord.Stop()
+
+	// waitForCollection waits for the group's collection to end after since
+	waitForCollection := func(since time.Time) time.Time {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			items := coord.GetItems(ctx, "directory", "home")
+			if len(items) == 1 && items[0].LastEndTime.After(since) {
+				return items[0].LastEndTime
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the group to be collected, got %+v", items)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	first := waitForCollection(time.Time{})
+
+	if !coord.CollectAll() {
+		t.Fatal("expected a collection to be scheduled")
+	}
+
+	// Well before the hourly tick
+	waitForCollection(first)
+
+	diagnostics := coord.Diagnostics(ctx)
+
+	scheduler, ok := diagnostics["scheduler"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected scheduler diagnostics, got %+v", diagnostics)
+	}
+
+	if _, ok := scheduler["tickers"].(map[string]any)["directory/home"]; !ok {
+		t.Errorf("expected the group's ticker in the diagnostics, got %+v", scheduler)
+	}
+}
@@ -0,0 +1,154 @@
+package digest
+
+import (
+	"sort"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/report"
+	"filesystem-exporter/internal/state"
+)
+
+// Digest is the content of one capacity report email
+type Digest struct {
+	Hostname    string
+	GeneratedAt time.Time
+	Since       time.Time // When the previous digest was sent; zero for the first one
+	Fullest     []report.Volume
+	Growers     []Grower
+	Failing     []Failure
+}
+
+// Grower is a volume or directory group whose usage changed since the
+// previous digest
+type Grower struct {
+	Kind        string // "volume" or "directory"
+	Name        string
+	Path        string
+	UsedBytes   float64
+	GrowthBytes float64
+}
+
+// Failure is an item whose latest scan failed
+type Failure struct {
+	Type                string
+	Name                string
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorTime       time.Time
+}
+
+// Build assembles a digest from the latest report and the usage recorded when
+// the previous digest was sent. Growers are left empty without a baseline.
+func Build(rep *report.Report, failing []*state.ItemState, previous map[string]float64, since time.Time, topN int) *Digest {
+	d := &Digest{
+		GeneratedAt: rep.GeneratedAt,
+		Since:       since,
+	}
+
+	d.Fullest = append([]report.Volume(nil), rep.Volumes...)
+	sort.SliceStable(d.Fullest, func(i, j int) bool {
+		return d.Fullest[i].UsedRatio > d.Fullest[j].UsedRatio
+	})
+	d.Fullest = truncate(d.Fullest, topN)
+
+	if previous != nil {
+		for key, current := range Usage(rep) {
+			before, exists := previous[key]
+			if !exists || current.UsedBytes <= before {
+				continue
+			}
+
+			current.GrowthBytes = current.UsedBytes - before
+			d.Growers = append(d.Growers, current)
+		}
+
+		sort.Slice(d.Growers, func(i, j int) bool {
+			if d.Growers[i].GrowthBytes != d.Growers[j].GrowthBytes {
+				return d.Growers[i].GrowthBytes > d.Growers[j].GrowthBytes
+			}
+
+			return d.Growers[i].Name < d.Growers[j].Name
+		})
+		d.Growers = truncate(d.Growers, topN)
+	}
+
+	for _, item := range failing {
+		failure := Failure{
+			Type:                item.Type,
+			Name:                item.Name,
+			ConsecutiveFailures: item.ConsecutiveFailures,
+		}
+
+		if len(item.RecentErrors) > 0 {
+			last := item.RecentErrors[len(item.RecentErrors)-1]
+			failure.LastError = last.Message
+			failure.LastErrorTime = last.Time
+		}
+
+		d.Failing = append(d.Failing, failure)
+	}
+
+	return d
+}
+
+// Usage returns the used bytes of every volume and directory group root in a
+// report, keyed so the next digest can work out growth
+func Usage(rep *report.Report) map[string]Grower {
+	usage := make(map[string]Grower, len(rep.Volumes))
+
+	for _, volume := range rep.Volumes {
+		usage["volume\x00"+volume.Name] = Grower{
+			Kind:      "volume",
+			Name:      volume.Name,
+			Path:      volume.MountPoint,
+			UsedBytes: volume.SizeBytes - volume.AvailableBytes,
+		}
+	}
+
+	for _, directory := range rep.Directories {
+		if directory.SubdirectoryLevel != "0" {
+			continue
+		}
+
+		usage["directory\x00"+directory.Group] = Grower{
+			Kind:      "directory",
+			Name:      directory.Group,
+			Path:      directory.Path,
+			UsedBytes: directory.SizeBytes,
+		}
+	}
+
+	return usage
+}
+
+// nextSend returns the first scheduled send time after now
+func nextSend(now time.Time, cfg config.DigestConfig, weekday time.Weekday) time.Time {
+	at, _ := time.Parse("15:04", cfg.Time) // Validated at load time
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+
+	if cfg.Schedule == config.DigestScheduleWeekly {
+		next = next.AddDate(0, 0, (int(weekday)-int(next.Weekday())+7)%7)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+
+		return next
+	}
+
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// truncate keeps at most n elements
+func truncate[T any](items []T, n int) []T {
+	if len(items) > n {
+		return items[:n]
+	}
+
+	return items
+}
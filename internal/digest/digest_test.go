@@ -0,0 +1,193 @@
+package digest
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/report"
+	"filesystem-exporter/internal/state"
+)
+
+func TestNextSend(t *testing.T) {
+	// 2026-03-04 is a Wednesday
+	now := time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cfg      config.DigestConfig
+		weekday  time.Weekday
+		expected time.Time
+	}{
+		{"daily later today", config.DigestConfig{Schedule: "daily", Time: "18:00"}, 0, time.Date(2026, 3, 4, 18, 0, 0, 0, time.UTC)},
+		{"daily already passed", config.DigestConfig{Schedule: "daily", Time: "08:00"}, 0, time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC)},
+		{"weekly later this week", config.DigestConfig{Schedule: "weekly", Time: "08:00"}, time.Friday, time.Date(2026, 3, 6, 8, 0, 0, 0, time.UTC)},
+		{"weekly today later", config.DigestConfig{Schedule: "weekly", Time: "10:00"}, time.Wednesday, time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{"weekly today passed", config.DigestConfig{Schedule: "weekly", Time: "08:00"}, time.Wednesday, time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC)},
+		{"weekly next week", config.DigestConfig{Schedule: "weekly", Time: "08:00"}, time.Monday, time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if next := nextSend(now, tt.cfg, tt.weekday); !next.Equal(tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, next)
+		}
+	}
+}
+
+func testReport() *report.Report {
+	return &report.Report{
+		GeneratedAt: time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC),
+		Volumes: []report.Volume{
+			{Name: "root", MountPoint: "/", SizeBytes: 100 << 30, AvailableBytes: 50 << 30, UsedRatio: 0.5},
+			{Name: "data", MountPoint: "/data", SizeBytes: 1 << 40, AvailableBytes: 64 << 30, UsedRatio: 0.94},
+		},
+		Directories: []report.Directory{
+			{Group: "media", Path: "/data/media", SubdirectoryLevel: "0", SizeBytes: 500 << 30},
+			{Group: "media", Path: "/data/media/films", SubdirectoryLevel: "1", SizeBytes: 400 << 30},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	rep := testReport()
+	failing := []*state.ItemState{{
+		Type:                "directory",
+		Name:                "backups",
+		ConsecutiveFailures: 3,
+		RecentErrors:        []state.ErrorRecord{{Message: "old"}, {Message: "du timed out"}},
+	}}
+
+	first := Build(rep, failing, nil, time.Time{}, 5)
+
+	if len(first.Fullest) != 2 || first.Fullest[0].Name != "data" {
+		t.Errorf("expected the fullest volume first, got %+v", first.Fullest)
+	}
+
+	if first.Growers != nil {
+		t.Errorf("expected no growers without a baseline, got %+v", first.Growers)
+	}
+
+	if len(first.Failing) != 1 || first.Failing[0].LastError != "du timed out" {
+		t.Errorf("unexpected failures %+v", first.Failing)
+	}
+
+	previous := map[string]float64{
+		"volume\x00root":     60 << 30, // Shrank
+		"volume\x00data":     900 << 30,
+		"directory\x00media": 480 << 30,
+	}
+
+	second := Build(rep, nil, previous, rep.GeneratedAt.AddDate(0, 0, -1), 1)
+
+	if len(second.Fullest) != 1 {
+		t.Errorf("expected top_n to limit volumes, got %+v", second.Fullest)
+	}
+
+	if len(second.Growers) != 1 || second.Growers[0].Name != "data" || second.Growers[0].GrowthBytes != 60<<30 {
+		t.Errorf("expected data to be the biggest grower, got %+v", second.Growers)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	d := Build(testReport(), nil, nil, time.Time{}, 5)
+	d.Hostname = "nas"
+
+	cfg := config.DigestConfig{SMTP: config.SMTPConfig{From: "Exporter <exporter@example.com>", To: []string{"ops@example.com"}}}
+
+	message, err := compose(d, cfg, d.GeneratedAt)
+	if err != nil {
+		t.Fatalf("compose failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"Subject: Filesystem capacity report for nas\r\n",
+		"To: ops@example.com\r\n",
+		"Content-Type: multipart/alternative; boundary=",
+		"text/plain; charset=utf-8",
+		"text/html; charset=utf-8",
+		"94.0%  data (/data): 64.0 GiB free of 1.0 TiB",
+		"Growth is reported from the next digest onwards.",
+	} {
+		if !strings.Contains(string(message), expected) {
+			t.Errorf("expected message to contain %q:\n%s", expected, message)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+
+	// A minimal SMTP server that accepts one message
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ready")
+
+		var commands []string
+
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			commands = append(commands, line)
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				_ = tp.PrintfLine("250 localhost")
+			case line == "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+
+				data, _ := tp.ReadDotLines()
+				commands = append(commands, data...)
+				_ = tp.PrintfLine("250 queued")
+			case line == "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				received <- commands
+
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	cfg := config.SMTPConfig{
+		Host: host,
+		Port: portNumber,
+		TLS:  config.SMTPTLSNone,
+		From: "Exporter <exporter@example.com>",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	}
+
+	if err := deliver(context.Background(), cfg, []byte("Subject: test\r\n\r\nhello\r\n")); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+
+	commands := strings.Join(<-received, "\n")
+	for _, expected := range []string{"MAIL FROM:<exporter@example.com>", "RCPT TO:<ops@example.com>", "RCPT TO:<oncall@example.com>", "hello"} {
+		if !strings.Contains(commands, expected) {
+			t.Errorf("expected server to receive %q, got:\n%s", expected, commands)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+// sendTimeout bounds a whole SMTP conversation
+const sendTimeout = time.Minute
+
+//go:embed templates/digest.txt templates/digest.html
+var templateFiles embed.FS
+
+var templateFuncs = map[string]any{
+	"bytes":   formatBytes,
+	"percent": formatPercent,
+}
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(templateFuncs).ParseFS(templateFiles, "templates/digest.txt"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(templateFuncs).ParseFS(templateFiles, "templates/digest.html"))
+)
+
+// compose renders a digest into a multipart/alternative email with plain
+// text and HTML bodies
+func compose(d *Digest, cfg config.DigestConfig, now time.Time) ([]byte, error) {
+	var text, html bytes.Buffer
+
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return nil, fmt.Errorf("failed to render text digest: %w", err)
+	}
+
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return nil, fmt.Errorf("failed to render HTML digest: %w", err)
+	}
+
+	subject := cfg.Subject
+	if subject == "" {
+		subject = "Filesystem capacity report for " + d.Hostname
+	}
+
+	var body bytes.Buffer
+
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+
+	headers := []string{
+		"From: " + cfg.SMTP.From,
+		"To: " + strings.Join(cfg.SMTP.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + parts.Boundary(),
+	}
+
+	for _, header := range headers {
+		message.WriteString(header + "\r\n")
+	}
+
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// deliver sends a composed message through the configured SMTP server
+func deliver(ctx context.Context, cfg config.SMTPConfig, message []byte) error {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{}, Config: tlsConfig}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if cfg.TLS == config.SMTPTLSImplicit {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.NetDialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake with %s failed: %w", addr, err)
+	}
+	defer client.Close()
+
+	if cfg.TLS == config.SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS (set tls: none for a plain text relay)", addr)
+		}
+
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS with %s failed: %w", addr, err)
+		}
+	}
+
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("MAIL FROM rejected: %w", err)
+	}
+
+	for _, recipient := range cfg.To {
+		to, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return err
+		}
+
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("RCPT TO %s rejected: %w", to.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// formatBytes renders a byte count with binary units, e.g. "1.5 GiB"
+func formatBytes(b float64) string {
+	const unit = 1024
+
+	if b < unit {
+		return fmt.Sprintf("%.0f B", b)
+	}
+
+	exp := 0
+	for n := b / unit; n >= unit && exp < 4; n /= unit {
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", b/float64(uint64(1)<<(10*(exp+1))), "KMGTP"[exp])
+}
+
+// formatPercent renders a 0-1 ratio as a percentage
+func formatPercent(ratio float64) string {
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
@@ -0,0 +1,118 @@
+package digest
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/coordinator"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/report"
+)
+
+// Sender emails a capacity digest on the configured schedule. Growth is
+// measured from the last digest it delivered, so the first one after a
+// restart reports none.
+type Sender struct {
+	config      *config.Config
+	coordinator *coordinator.Coordinator
+	metrics     *metrics.FilesystemRegistry
+	hostname    string
+
+	// Usage when the last digest was sent, the baseline for growth. Only the
+	// run loop touches these.
+	previous     map[string]float64
+	previousTime time.Time
+
+	wg sync.WaitGroup
+}
+
+// NewSender creates a new digest sender
+func NewSender(cfg *config.Config, coord *coordinator.Coordinator, m *metrics.FilesystemRegistry) *Sender {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown host"
+	}
+
+	return &Sender{
+		config:      cfg,
+		coordinator: coord,
+		metrics:     m,
+		hostname:    hostname,
+	}
+}
+
+// Start starts the schedule loop. It stops when ctx is cancelled.
+func (s *Sender) Start(ctx context.Context) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx)
+	}()
+}
+
+// Stop blocks until the schedule loop has exited after ctx was cancelled
+func (s *Sender) Stop() {
+	s.wg.Wait()
+}
+
+// run sleeps until each scheduled send time
+func (s *Sender) run(ctx context.Context) {
+	weekday, _ := s.config.GetDigestWeekday() // Validated at load time
+
+	for {
+		next := nextSend(time.Now(), s.config.Digest, weekday)
+		slog.Info("Next capacity digest scheduled", "at", next, "to", s.config.Digest.SMTP.To)
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.send(ctx)
+		}
+	}
+}
+
+// send builds and emails one digest. A failed send keeps the old baseline so
+// the next digest reports growth over the whole period.
+func (s *Sender) send(ctx context.Context) {
+	rep, err := report.Build(s.metrics.GetRegistry())
+	if err != nil {
+		s.metrics.DigestsSentCounter.WithLabelValues("failure").Inc()
+		slog.Error("Failed to build capacity digest", "error", err)
+
+		return
+	}
+
+	d := Build(rep, s.coordinator.GetFailingItems(ctx), s.previous, s.previousTime, s.config.Digest.TopN)
+	d.Hostname = s.hostname
+
+	message, err := compose(d, s.config.Digest, time.Now())
+	if err == nil {
+		err = deliver(ctx, s.config.Digest.SMTP, message)
+	}
+
+	if err != nil {
+		s.metrics.DigestsSentCounter.WithLabelValues("failure").Inc()
+		slog.Error("Failed to send capacity digest", "error", err, "smtp_host", s.config.Digest.SMTP.Host)
+
+		return
+	}
+
+	s.metrics.DigestsSentCounter.WithLabelValues("success").Inc()
+	slog.Info("Capacity digest sent", "to", s.config.Digest.SMTP.To, "volumes", len(d.Fullest), "growers", len(d.Growers), "failing", len(d.Failing))
+
+	s.previous = make(map[string]float64)
+	for key, usage := range Usage(rep) {
+		s.previous[key] = usage.UsedBytes
+	}
+
+	s.previousTime = rep.GeneratedAt
+}
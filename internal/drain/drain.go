@@ -0,0 +1,96 @@
+// Package drain shuts HTTP servers down gracefully: once shutdown starts, new
+// requests are answered with a 503 while those already in flight have a grace
+// period to finish
+package drain
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+// Drainer applies a listener's configured timeouts to its server and drains
+// it on shutdown
+type Drainer struct {
+	name   string // Names the server in logs, e.g. "api"
+	server *http.Server
+	grace  time.Duration
+
+	// draining is set once shutdown starts so new requests get a 503
+	draining     atomic.Bool
+	shutdownOnce sync.Once
+}
+
+// New sets server's read, write and idle timeouts from timeouts, and returns
+// a Drainer that shuts it down within timeouts.ShutdownGrace. name identifies
+// the server in logs.
+func New(name string, server *http.Server, timeouts config.HTTPTimeoutsConfig) *Drainer {
+	server.ReadHeaderTimeout = 30 * time.Second
+	server.ReadTimeout = timeouts.ReadTimeout.Duration
+	server.WriteTimeout = timeouts.WriteTimeout.Duration
+	server.IdleTimeout = timeouts.IdleTimeout.Duration
+
+	return &Drainer{
+		name:   name,
+		server: server,
+		grace:  timeouts.ShutdownGrace.Duration,
+	}
+}
+
+// Handler passes requests to next until shutdown starts, then answers them
+// with rejected, telling clients on kept-alive connections to reconnect
+// elsewhere. rejected may be nil to answer with a plain text 503.
+func (d *Drainer) Handler(next, rejected http.Handler) http.Handler {
+	if rejected == nil {
+		rejected = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() {
+			w.Header().Set("Connection", "close")
+			rejected.ServeHTTP(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Draining reports whether shutdown has started
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Shutdown rejects new requests, waits up to the shutdown grace for in-flight
+// ones and then closes whatever connections are left. Concurrent callers all
+// return once it has finished.
+func (d *Drainer) Shutdown() {
+	d.shutdownOnce.Do(func() {
+		d.draining.Store(true)
+
+		slog.Info("Draining HTTP server", "server", d.name, "grace", d.grace)
+
+		ctx, cancel := context.WithTimeout(context.Background(), d.grace)
+		defer cancel()
+
+		if err := d.server.Shutdown(ctx); err != nil {
+			slog.Warn("Requests still running after shutdown grace, closing connections", "server", d.name, "error", err, "grace", d.grace)
+
+			if err := d.server.Close(); err != nil {
+				slog.Error("HTTP server close error", "server", d.name, "error", err)
+			}
+
+			return
+		}
+
+		slog.Info("HTTP server stopped", "server", d.name)
+	})
+}
@@ -0,0 +1,44 @@
+package drain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+func TestHandler(t *testing.T) {
+	server := &http.Server{}
+	d := New("test", server, config.HTTPTimeoutsConfig{
+		ReadTimeout:   config.Duration{Duration: time.Second},
+		WriteTimeout:  config.Duration{Duration: 2 * time.Second},
+		IdleTimeout:   config.Duration{Duration: 3 * time.Second},
+		ShutdownGrace: config.Duration{Duration: time.Second},
+	})
+
+	if server.ReadTimeout != time.Second || server.WriteTimeout != 2*time.Second || server.IdleTimeout != 3*time.Second {
+		t.Errorf("expected the configured timeouts, got %s, %s, %s", server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+
+	handler := d.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected 200 before shutdown, got %d", recorder.Code)
+	}
+
+	d.draining.Store(true)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Connection") != "close" {
+		t.Errorf("expected 503 with Connection: close while draining, got %d %v", recorder.Code, recorder.Header())
+	}
+}
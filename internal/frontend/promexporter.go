@@ -0,0 +1,30 @@
+package frontend
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"unsafe"
+
+	"github.com/d0ugal/promexporter/server"
+)
+
+// handlerType is http.Handler's reflect type
+var handlerType = reflect.TypeFor[http.Handler]()
+
+// PromexporterHandler returns the handler of a promexporter server, serving
+// its dashboard, /metrics and /health, so it can run in-process behind the
+// frontend. promexporter only serves it from a TCP listener of its own,
+// which would bypass TLS and authentication, so the handler is read from
+// the server's unexported router. TestPromexporterHandler catches an
+// upgrade that changes it.
+func PromexporterHandler(s *server.Server) (http.Handler, error) {
+	router := reflect.ValueOf(s).Elem().FieldByName("router")
+	if !router.IsValid() || !router.Type().Implements(handlerType) || router.IsNil() {
+		return nil, errors.New("the promexporter server has no router to serve in-process")
+	}
+
+	// Fields that aren't exported can't be read with Interface, but a pointer
+	// to one can be turned into a readable value
+	return reflect.NewAt(router.Type(), unsafe.Pointer(router.UnsafeAddr())).Elem().Interface().(http.Handler), nil
+}
@@ -0,0 +1,177 @@
+// Package frontend serves the exporter's HTTP endpoints, adding TLS,
+// authentication, Unix sockets and extra routes around the promexporter
+// server's handler, which supports none of them
+package frontend
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"filesystem-exporter/internal/auth"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/drain"
+)
+
+// Server listens on the configured server address in the promexporter
+// server's place. Requests it has no route of its own for go to the
+// promexporter server's handler, which runs in-process, so every request
+// passes the same TLS and authentication. Once shutdown starts it answers new
+// requests with a 503 while those already in flight finish.
+type Server struct {
+	server        *http.Server
+	drainer       *drain.Drainer
+	mux           *http.ServeMux
+	authenticator *auth.Authenticator
+	socketMode    os.FileMode  // Permissions of a Unix socket listener
+	listener      net.Listener // Opened by Listen
+	backend       http.Handler // The promexporter server's handler
+	health        http.Handler // The promexporter server's unless replaced by HandleHealth
+}
+
+// NewServer creates a server for addr, either host:port or
+// unix:///path/to.sock, passing requests without a route of their own to
+// backend and bounding requests by timeouts. tlsConfig may be nil to serve
+// plain HTTP and authenticator nil to accept anonymous requests; /health is
+// always open so liveness probes don't need credentials.
+func NewServer(addr string, socketMode os.FileMode, backend http.Handler, tlsConfig *tls.Config, authenticator *auth.Authenticator, timeouts config.HTTPTimeoutsConfig) *Server {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		authenticator: authenticator,
+		socketMode:    socketMode,
+		backend:       backend,
+		health:        backend,
+	}
+
+	s.mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { s.health.ServeHTTP(w, r) })
+	s.Handle("/", "metrics", backend)
+
+	s.server = &http.Server{
+		Addr:      addr,
+		TLSConfig: tlsConfig,
+	}
+	s.drainer = drain.New("http", s.server, timeouts)
+	s.server.Handler = s.drainer.Handler(s.mux, nil)
+
+	return s
+}
+
+// Handle serves pattern from handler behind the server's authentication.
+// name labels the failures it rejects in the auth failures metric. It must be
+// called before Start.
+func (s *Server) Handle(pattern, name string, handler http.Handler) {
+	if s.authenticator != nil {
+		handler = s.authenticator.Wrap(name, handler)
+	}
+
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleOwnAuth serves pattern from handler, leaving the handler to check
+// credentials. It must be called before Start.
+func (s *Server) HandleOwnAuth(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleHealth serves /health from health instead of the promexporter
+// server, and /ready from ready. Both stay open like /health always was. It
+// must be called before Start.
+func (s *Server) HandleHealth(health, ready http.Handler) {
+	s.health = health
+	s.mux.Handle("/ready", ready)
+}
+
+// HandleMetrics calls refresh before serving each authenticated scrape of
+// /metrics, so collections it runs are in the response. It must be called
+// before Start.
+func (s *Server) HandleMetrics(refresh func(ctx context.Context)) {
+	s.Handle("/metrics", "metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refresh(r.Context())
+		s.backend.ServeHTTP(w, r)
+	}))
+}
+
+// RegisterOnShutdown calls f as the server shuts down, to end responses that
+// would otherwise hold it up, such as event streams. It must be called before
+// Start.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.server.RegisterOnShutdown(f)
+}
+
+// Listen opens the TCP or Unix socket listener, so an address that can't be
+// used stops the exporter before it starts collecting. A socket left behind
+// by a previous run that didn't shut down cleanly is replaced, but any other
+// file at the path is left alone. Closing the listener removes the socket
+// again.
+func (s *Server) Listen() error {
+	path, ok := strings.CutPrefix(s.server.Addr, "unix://")
+	if !ok {
+		listener, err := net.Listen("tcp", s.server.Addr)
+		if err != nil {
+			return err
+		}
+
+		s.listener = listener
+
+		return nil
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, s.socketMode); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	s.listener = listener
+
+	return nil
+}
+
+// Start serves on the listener Listen opened, in the background, until ctx
+// is cancelled. Serving errors are logged since the collector interface has
+// no way to report them. Draining starts as soon as ctx is cancelled rather
+// than when Stop is called, as the API server's does.
+func (s *Server) Start(ctx context.Context) {
+	slog.Info("Starting HTTP server", "address", s.server.Addr, "tls", s.server.TLSConfig != nil)
+
+	go func() {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ServeTLS(s.listener, "", "")
+		} else {
+			err = s.server.Serve(s.listener)
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "error", err, "address", s.server.Addr)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		s.drainer.Shutdown()
+	}()
+}
+
+// Stop blocks until in-flight requests have drained or the shutdown grace
+// period has passed
+func (s *Server) Stop() {
+	s.drainer.Shutdown()
+}
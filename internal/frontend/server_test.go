@@ -0,0 +1,282 @@
+package frontend
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/auth"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_config "github.com/d0ugal/promexporter/config"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/d0ugal/promexporter/server"
+	"github.com/d0ugal/promexporter/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var testTimeouts = config.HTTPTimeoutsConfig{
+	ReadTimeout:   config.Duration{Duration: 30 * time.Second},
+	WriteTimeout:  config.Duration{Duration: 30 * time.Second},
+	IdleTimeout:   config.Duration{Duration: 30 * time.Second},
+	ShutdownGrace: config.Duration{Duration: 5 * time.Second},
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	authenticator, err := auth.New(config.AuthConfig{BearerToken: "token"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "exporter.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer("unix://"+socket, 0o600, backend, nil, authenticator, testTimeouts)
+
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	// The backend is served in-process, so the socket is the only listener
+	if network := srv.listener.Addr().Network(); network != "unix" {
+		t.Fatalf("expected to listen on the socket only, got a %s listener", network)
+	}
+
+	srv.Start(ctx)
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("expected the socket to exist: %v", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected socket mode 0600, got %s", info.Mode().Perm())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	for _, tt := range []struct {
+		path, token string
+		expected    int
+	}{
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/metrics", "token", http.StatusOK},
+		{"/health", "", http.StatusOK},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://exporter"+tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.path, err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expected {
+			t.Errorf("%s with token %q: expected %d, got %d", tt.path, tt.token, tt.expected, resp.StatusCode)
+		}
+	}
+
+	cancel()
+
+	// Shutdown closes the listener, which removes the socket
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(socket); os.IsNotExist(err) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected the socket to be removed on shutdown")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServerDrains checks that a request in flight at shutdown finishes
+// within the grace period while new ones get a 503
+func TestServerDrains(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer("127.0.0.1:0", 0, backend, nil, nil, testTimeouts)
+
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	srv.Start(ctx)
+
+	status := make(chan int, 1)
+
+	go func() {
+		resp, err := http.Get("http://" + srv.listener.Addr().String() + "/probe")
+		if err != nil {
+			status <- 0
+			return
+		}
+
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !srv.drainer.Draining() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the server to start draining")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for a new request while draining, got %d", rec.Code)
+	}
+
+	close(release)
+
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("expected the request in flight to finish, got %d", code)
+	}
+
+	srv.Stop()
+}
+
+func TestServerHandleHealth(t *testing.T) {
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	authenticator, err := auth.New(config.AuthConfig{BearerToken: "token"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer("127.0.0.1:0", 0, http.NotFoundHandler(), nil, authenticator, testTimeouts)
+	srv.HandleHealth(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) }),
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }),
+	)
+
+	// Both open without credentials
+	for path, expected := range map[string]int{"/health": http.StatusTeapot, "/ready": http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, rec.Code)
+		}
+	}
+}
+
+func TestServerHandleMetrics(t *testing.T) {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	authenticator, err := auth.New(config.AuthConfig{BearerToken: "token"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshes := 0
+
+	srv := NewServer("127.0.0.1:0", 0, backend, nil, authenticator, testTimeouts)
+	srv.HandleMetrics(func(context.Context) { refreshes++ })
+
+	// Unauthenticated scrapes don't trigger collections
+	rec := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusUnauthorized || refreshes != 0 {
+		t.Errorf("expected 401 without a refresh, got %d after %d refreshes", rec.Code, refreshes)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	rec = httptest.NewRecorder()
+	srv.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "/metrics" || refreshes != 1 {
+		t.Errorf("expected the scrape served after one refresh, got %d %q after %d refreshes", rec.Code, rec.Body.String(), refreshes)
+	}
+}
+
+func TestServerHandle_LabelsAuthFailures(t *testing.T) {
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	authenticator, err := auth.New(config.AuthConfig{BearerToken: "token"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer("127.0.0.1:0", 0, http.NotFoundHandler(), nil, authenticator, testTimeouts)
+	srv.Handle("/probe", "probe", http.NotFoundHandler())
+
+	for _, path := range []string{"/", "/probe", "/probe"} {
+		srv.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	for name, expected := range map[string]float64{"metrics": 1, "probe": 2} {
+		if failures := testutil.ToFloat64(registry.AuthFailuresCounter.WithLabelValues(name)); failures != expected {
+			t.Errorf("expected %g auth failures for %s, got %g", expected, name, failures)
+		}
+	}
+}
+
+func TestPromexporterHandler(t *testing.T) {
+	registry := promexporter_metrics.NewRegistry("filesystem_exporter_test_info")
+	registry.VersionInfo.WithLabelValues("test", "abc", "today").Set(1)
+
+	handler, err := PromexporterHandler(server.New(&promexporter_config.BaseConfig{}, registry, "Test Exporter", nil, &tracing.Tracer{}))
+	if err != nil {
+		t.Fatalf("PromexporterHandler failed: %v", err)
+	}
+
+	for path, expected := range map[string]string{
+		"/":        "Test Exporter",
+		"/metrics": "filesystem_exporter_test_info",
+		"/health":  "",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("%s: expected 200 containing %q, got %d %q", path, expected, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package fsstat
+
+// Usage holds capacity information for a mounted filesystem in bytes
+type Usage struct {
+	Size      int64 // Total size of the filesystem
+	Free      int64 // Free space including blocks reserved for root
+	Available int64 // Space available to unprivileged users
+}
@@ -0,0 +1,62 @@
+//go:build darwin || freebsd || dragonfly
+
+package fsstat
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// Stat returns usage for the filesystem containing path using statfs(2)
+func Stat(path string) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+
+	// Bavail is signed on FreeBSD and goes negative once root has dipped
+	// into the reserved blocks
+	available := int64(st.Bavail) //nolint:gosec // G115: block counts fit in int64
+	if available < 0 {
+		available = 0
+	}
+
+	bsize := int64(st.Bsize) //nolint:gosec // G115: block sizes fit in int64
+
+	//nolint:gosec // G115: block counts times block size fit in int64 for any real filesystem
+	return Usage{
+		Size:      int64(st.Blocks) * bsize,
+		Free:      int64(st.Bfree) * bsize,
+		Available: available * bsize,
+	}, nil
+}
+
+// IsMountPoint reports whether path is the root of a mounted filesystem, i.e.
+// it lives on a different device than its parent directory
+func IsMountPoint(path string) (bool, error) {
+	var st, parent unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return false, err
+	}
+
+	if err := unix.Stat(filepath.Join(path, ".."), &parent); err != nil {
+		return false, err
+	}
+
+	return st.Dev != parent.Dev || st.Ino == parent.Ino, nil
+}
+
+// DeviceID identifies the device holding path as major:minor
+func DeviceID(path string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // G115: st_dev is a device number, whatever its signedness
+	dev := uint64(st.Dev)
+
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev)), nil
+}
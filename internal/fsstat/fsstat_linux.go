@@ -0,0 +1,57 @@
+//go:build linux
+
+package fsstat
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Stat returns usage for the filesystem containing path using statfs(2)
+func Stat(path string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+
+	bsize := int64(st.Bsize) //nolint:unconvert // An int32 on 32-bit architectures
+
+	//nolint:gosec // G115: block counts times block size fit in int64 for any real filesystem
+	return Usage{
+		Size:      int64(st.Blocks) * bsize,
+		Free:      int64(st.Bfree) * bsize,
+		Available: int64(st.Bavail) * bsize,
+	}, nil
+}
+
+// IsMountPoint reports whether path is the root of a mounted filesystem, i.e.
+// it lives on a different device than its parent directory. Bind mounts from
+// the parent's own filesystem are not detected.
+func IsMountPoint(path string) (bool, error) {
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return false, err
+	}
+
+	if err := syscall.Stat(filepath.Join(path, ".."), &parent); err != nil {
+		return false, err
+	}
+
+	return st.Dev != parent.Dev || st.Ino == parent.Ino, nil
+}
+
+// DeviceID identifies the device holding path as major:minor, so paths on the
+// same disk can be told apart from those on another. Filesystems without a
+// block device, such as NFS, tmpfs and btrfs subvolumes, get an anonymous
+// 0:N number of their own.
+func DeviceID(path string) (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)), nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !windows && !darwin && !freebsd && !dragonfly
+
+package fsstat
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Stat is not implemented on this platform
+func Stat(path string) (Usage, error) {
+	return Usage{}, fmt.Errorf("statfs is not supported on %s", runtime.GOOS)
+}
+
+// IsMountPoint is not implemented on this platform
+func IsMountPoint(path string) (bool, error) {
+	return false, fmt.Errorf("mount point detection is not supported on %s", runtime.GOOS)
+}
+
+// DeviceID is not implemented on this platform
+func DeviceID(path string) (string, error) {
+	return "", fmt.Errorf("device lookup is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || dragonfly || windows
+
+package fsstat
+
+import "testing"
+
+func TestStat(t *testing.T) {
+	usage, err := Stat(t.TempDir())
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if usage.Available <= 0 || usage.Size < usage.Available {
+		t.Errorf("expected size >= available > 0, got %+v", usage)
+	}
+
+	if usage.Free < usage.Available {
+		t.Errorf("expected free to include available, got %+v", usage)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build windows
+
+package fsstat
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// Stat returns usage for the volume containing path using GetDiskFreeSpaceExW.
+// Windows has no root reserve, so Free and Available only differ when per-user
+// disk quotas apply.
+func Stat(path string) (Usage, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, &total, &free); err != nil {
+		return Usage{}, err
+	}
+
+	//nolint:gosec // G115: volume sizes fit in int64
+	return Usage{
+		Size:      int64(total),
+		Free:      int64(free),
+		Available: int64(available),
+	}, nil
+}
+
+// IsMountPoint reports whether path is the root of a volume, either a drive
+// or a volume mounted on an NTFS folder
+func IsMountPoint(path string) (bool, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false, err
+	}
+
+	volume, err := volumePath(name)
+	if err != nil {
+		return false, err
+	}
+
+	target := strings.TrimRight(filepath.Clean(path), `\`)
+
+	return strings.EqualFold(volume, target), nil
+}
+
+// DeviceID identifies the volume holding path by its root, e.g. C: or a
+// folder a volume is mounted on
+func DeviceID(path string) (string, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+
+	volume, err := volumePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(volume), nil
+}
+
+// volumePath returns the root of the volume holding name, without a trailing
+// backslash
+func volumePath(name *uint16) (string, error) {
+	buf := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(name, &buf[0], uint32(len(buf))); err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(windows.UTF16ToString(buf), `\`), nil
+}
@@ -0,0 +1,191 @@
+// Package groupmetrics serves GET /metrics/group/{name}, the series of a
+// single directory group or filesystem, so each team can scrape only its own
+// with a scrape config and credentials of its own
+package groupmetrics
+
+import (
+	"context"
+	"net/http"
+
+	"filesystem-exporter/internal/auth"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Pattern is the route the handler serves
+const Pattern = "GET /metrics/group/{name}"
+
+// Handler serves each item's series from the exporter's registry
+type Handler struct {
+	config         *config.Config
+	gatherer       prometheus.Gatherer
+	server         *auth.Authenticator
+	authenticators map[string]*auth.Authenticator // By item name, from group_metrics.auth
+	refresh        func(ctx context.Context)
+}
+
+// matcher reports whether a series, by its labels, belongs to an item
+type matcher func(labels map[string]string) bool
+
+// NewHandler creates a handler for the directory groups and filesystems in
+// cfg, looking each up as it's requested so groups added through the API are
+// served too. Requests need server's credentials, or those in
+// group_metrics.auth for the item; server may be nil to accept anonymous
+// requests to items without their own. refresh, if not nil, runs before
+// serving an on_scrape filesystem's series.
+func NewHandler(cfg *config.Config, gatherer prometheus.Gatherer, server *auth.Authenticator, m *metrics.FilesystemRegistry, refresh func(ctx context.Context)) (*Handler, error) {
+	h := &Handler{
+		config:         cfg,
+		gatherer:       gatherer,
+		server:         server,
+		authenticators: make(map[string]*auth.Authenticator, len(cfg.GroupMetrics.Auth)),
+		refresh:        refresh,
+	}
+
+	for name, credentials := range cfg.GroupMetrics.Auth {
+		authenticator, err := server.With(credentials, m)
+		if err != nil {
+			return nil, err
+		}
+
+		h.authenticators[name] = authenticator
+	}
+
+	return h, nil
+}
+
+// ServeHTTP serves the series of the item named in the path, or 404 for an
+// unknown one
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	// A directory group and a filesystem may share a name, and then share
+	// the endpoint
+	var matchers []matcher
+
+	onScrape := false
+
+	if _, exists := h.config.Directory(name); exists {
+		matchers = append(matchers, directoryMatcher(name))
+	}
+
+	for _, fs := range h.config.Filesystems {
+		if fs.Name == name {
+			matchers = append(matchers, filesystemMatcher(fs))
+			onScrape = fs.Collection == config.CollectionModeOnScrape
+		}
+	}
+
+	if len(matchers) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	handler := promhttp.HandlerFor(filter(h.gatherer, matchers), promhttp.HandlerOpts{EnableOpenMetrics: true})
+
+	if onScrape && h.refresh != nil {
+		next := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.refresh(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	authenticator := h.server
+	if own, ok := h.authenticators[name]; ok {
+		authenticator = own
+	}
+
+	if authenticator != nil {
+		handler = authenticator.Wrap("group_metrics", handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// directoryMatcher matches a directory group's series: those naming it,
+// other than the collection series of filesystems and buckets with the same
+// name
+func directoryMatcher(name string) matcher {
+	return func(labels map[string]string) bool {
+		itemType := itemType(labels)
+		if itemType != "" && itemType != "directory" {
+			return false
+		}
+
+		return itemName(labels) == name
+	}
+}
+
+// filesystemMatcher matches a filesystem's series: volume series with its
+// name and mount point, and the collection and job series naming it
+func filesystemMatcher(fs config.FilesystemConfig) matcher {
+	return func(labels map[string]string) bool {
+		if labels["volume"] == fs.Name && labels["mount_point"] == fs.MountPoint {
+			return true
+		}
+
+		return itemType(labels) == "filesystem" && itemName(labels) == fs.Name
+	}
+}
+
+// itemName returns the name of the item a series is about, from whichever
+// label holds it, or "" if none does
+func itemName(labels map[string]string) string {
+	return firstLabel(labels, "group", "item_name", "job_name")
+}
+
+// itemType returns the kind of item a series is about, from whichever label
+// holds it, or "" if none does
+func itemType(labels map[string]string) string {
+	return firstLabel(labels, "type", "item_type", "queue_type", "job_type")
+}
+
+// firstLabel returns the value of the first of names the series has
+func firstLabel(labels map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := labels[name]; ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// filter gathers only the series any of the matchers match, dropping families
+// left empty
+func filter(gatherer prometheus.Gatherer, matchers []matcher) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+
+		var filtered []*dto.MetricFamily
+
+		for _, family := range families {
+			var kept []*dto.Metric
+
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string, len(metric.GetLabel()))
+				for _, pair := range metric.GetLabel() {
+					labels[pair.GetName()] = pair.GetValue()
+				}
+
+				for _, match := range matchers {
+					if match(labels) {
+						kept = append(kept, metric)
+						break
+					}
+				}
+			}
+
+			if len(kept) > 0 {
+				family.Metric = kept
+				filtered = append(filtered, family)
+			}
+		}
+
+		return filtered, err
+	})
+}
@@ -0,0 +1,102 @@
+package groupmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"filesystem-exporter/internal/auth"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	registry := promexporter_metrics.NewRegistry("filesystem_exporter_test_info")
+	m := metrics.NewFilesystemRegistry(registry)
+
+	cfg := &config.Config{
+		Filesystems: []config.FilesystemConfig{{Name: "data", MountPoint: "/data", Device: "sdb1"}},
+		Directories: map[string]config.DirectoryGroup{
+			"uploads": {Path: "/srv/uploads"},
+			"logs":    {Path: "/var/log"},
+		},
+		GroupMetrics: config.GroupMetricsConfig{
+			Enabled: true,
+			Auth:    map[string]config.AuthConfig{"uploads": {BearerToken: "uploads"}},
+		},
+	}
+
+	m.VolumeSizeGauge.WithLabelValues("sdb1", "/data", "data").Set(1000)
+	m.DirectorySizeGauge.WithLabelValues("uploads", "/srv/uploads", "du", "disk_usage", "0").Set(10)
+	m.DirectorySizeGauge.WithLabelValues("logs", "/var/log", "du", "disk_usage", "0").Set(20)
+	m.CollectionSuccess.WithLabelValues("uploads", "3600", "directory").Inc()
+	m.CollectionSuccess.WithLabelValues("data", "60", "filesystem").Inc()
+
+	server, err := auth.New(config.AuthConfig{BearerToken: "server"}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := NewHandler(cfg, registry.GetRegistry(), server, m, nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Pattern, handler)
+
+	get := func(name, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics/group/"+name, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := get("uploads", "uploads")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with the group's token, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+
+	for _, expected := range []string{`directory="/srv/uploads"`, `filesystem_exporter_collection_success_total{group="uploads"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in the group's series, got:\n%s", expected, body)
+		}
+	}
+
+	for _, unexpected := range []string{`group="logs"`, `volume="data"`, `group="data"`} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("expected no %s in the group's series, got:\n%s", unexpected, body)
+		}
+	}
+
+	rec = get("data", "server")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `volume="data"`) || !strings.Contains(body, `group="data",interval_seconds="60",type="filesystem"`) || strings.Contains(body, `group="uploads"`) {
+		t.Errorf("expected only the filesystem's series with the server token, got %d:\n%s", rec.Code, body)
+	}
+
+	if rec := get("data", "uploads"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected another group's token to be rejected, got %d", rec.Code)
+	}
+
+	if rec := get("missing", "server"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown item, got %d", rec.Code)
+	}
+
+	// Groups are looked up per request, so one added later is served
+	cfg.Directories = map[string]config.DirectoryGroup{"scratch": {Path: "/scratch"}}
+
+	if rec := get("scratch", "server"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a group added after the handler, got %d", rec.Code)
+	}
+
+	if rec := get("logs", "server"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a removed group, got %d", rec.Code)
+	}
+}
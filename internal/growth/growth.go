@@ -0,0 +1,288 @@
+// Package growth estimates how fast volumes and directory groups grow, and
+// when volumes will fill, by linear regression over their recent sizes. A
+// least squares fit over the collections themselves copes with the long gaps
+// between scans of slow directory groups, which predict_linear in PromQL
+// struggles with.
+package growth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+)
+
+// maxSamples bounds each series' history. Collections closer together than
+// window/maxSamples are merged, e.g. to one every 5m over a 24h window.
+const maxSamples = 288
+
+// saveInterval is how often the history is saved to growth.state_file
+const saveInterval = time.Minute
+
+// Sample is an item's size at the end of a collection
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Bytes int64     `json:"bytes"`
+}
+
+// stateFile is the layout of growth.state_file
+type stateFile struct {
+	Series map[string][]Sample `json:"series"` // Keyed by item type and name
+}
+
+// Tracker keeps the recent sizes of each volume and directory group and
+// exports their growth after each collection
+type Tracker struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+
+	mu     sync.Mutex
+	series map[string][]Sample // Keyed by item type and name, oldest first
+	dirty  bool                // Changed since the last save
+
+	wg sync.WaitGroup
+}
+
+// NewTracker creates a growth tracker, starting from the history in
+// growth.state_file if there is one
+func NewTracker(cfg *config.Config, m *metrics.FilesystemRegistry) *Tracker {
+	t := &Tracker{
+		config:  cfg,
+		metrics: m,
+		series:  make(map[string][]Sample),
+	}
+
+	if path := cfg.Growth.StateFile; path != "" {
+		if err := t.load(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to load growth history, starting afresh", "file", path, "error", err)
+		}
+	}
+
+	return t
+}
+
+// Start saves the history to growth.state_file every minute until ctx is
+// cancelled. It does nothing without a state file.
+func (t *Tracker) Start(ctx context.Context) {
+	if t.config.Growth.StateFile == "" {
+		return
+	}
+
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.Save(); err != nil {
+					slog.Warn("Failed to save growth history", "file", t.config.Growth.StateFile, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until the save loop has exited after ctx was cancelled
+func (t *Tracker) Wait() {
+	t.wg.Wait()
+}
+
+// ObserveVolume records a collected filesystem's used bytes and exports its
+// growth, and how long its available space lasts while it grows
+func (t *Tracker) ObserveVolume(fs *config.FilesystemConfig, now time.Time, usedBytes, availableBytes int64) {
+	labels := t.metrics.ItemLabelValues(fs.Labels, fs.Device, fs.MountPoint, fs.Name)
+
+	perSecond, ok := t.observe("filesystem/"+fs.Name, now, usedBytes)
+	if !ok {
+		t.metrics.VolumeGrowthGauge.DeleteLabelValues(labels...)
+		t.metrics.VolumeFillETAGauge.DeleteLabelValues(labels...)
+
+		return
+	}
+
+	t.metrics.VolumeGrowthGauge.WithLabelValues(labels...).Set(perSecond * time.Hour.Seconds())
+
+	if perSecond <= 0 {
+		t.metrics.VolumeFillETAGauge.DeleteLabelValues(labels...)
+		return
+	}
+
+	t.metrics.VolumeFillETAGauge.WithLabelValues(labels...).Set(float64(max(availableBytes, 0)) / perSecond)
+}
+
+// ObserveDirectory records a collected directory group's total size and
+// exports its growth
+func (t *Tracker) ObserveDirectory(group string, now time.Time, sizeBytes int64) {
+	labels := t.metrics.ItemLabelValues(t.config.GetDirectoryLabels(group), group)
+
+	perSecond, ok := t.observe("directory/"+group, now, sizeBytes)
+	if !ok {
+		t.metrics.DirectoryGrowthGauge.DeleteLabelValues(labels...)
+		return
+	}
+
+	t.metrics.DirectoryGrowthGauge.WithLabelValues(labels...).Set(perSecond * time.Hour.Seconds())
+}
+
+// observe adds a sample to a series, dropping those that have left the
+// window, and returns the series' growth in bytes per second once it has
+// min_samples
+func (t *Tracker) observe(key string, now time.Time, bytes int64) (float64, bool) {
+	window := t.config.Growth.Window.Duration
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := t.series[key]
+
+	cutoff := now.Add(-window)
+	for len(samples) > 0 && samples[0].Time.Before(cutoff) {
+		samples = samples[1:]
+	}
+
+	// The latest sample is replaced until it is far enough from the one
+	// before it, so every other sample stays spaced out
+	sample := Sample{Time: now, Bytes: bytes}
+	if n := len(samples); n >= 2 && samples[n-1].Time.Sub(samples[n-2].Time) < window/maxSamples {
+		samples[n-1] = sample
+	} else {
+		samples = append(samples, sample)
+	}
+
+	t.series[key] = samples
+	t.dirty = true
+
+	if len(samples) < t.config.Growth.MinSamples {
+		return 0, false
+	}
+
+	return Slope(samples)
+}
+
+// Slope fits a line to the samples by least squares and returns its slope in
+// bytes per second. It returns false unless the samples span some time.
+func Slope(samples []Sample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	origin := samples[0].Time
+	n := float64(len(samples))
+
+	var meanX, meanY float64
+
+	for _, s := range samples {
+		meanX += s.Time.Sub(origin).Seconds()
+		meanY += float64(s.Bytes)
+	}
+
+	meanX /= n
+	meanY /= n
+
+	var sxy, sxx float64
+
+	for _, s := range samples {
+		dx := s.Time.Sub(origin).Seconds() - meanX
+		sxy += dx * (float64(s.Bytes) - meanY)
+		sxx += dx * dx
+	}
+
+	if sxx == 0 {
+		return 0, false
+	}
+
+	return sxy / sxx, true
+}
+
+// Save writes the history to growth.state_file if it changed since the last
+// save. The file is replaced in one step, so a crash never leaves half of it.
+// Series whose latest sample has left the window, such as those of items
+// removed from the config, are dropped.
+func (t *Tracker) Save() error {
+	path := t.config.Growth.StateFile
+	if path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+
+	cutoff := time.Now().Add(-t.config.Growth.Window.Duration)
+
+	for key, samples := range t.series {
+		if len(samples) == 0 || samples[len(samples)-1].Time.Before(cutoff) {
+			delete(t.series, key)
+		}
+	}
+
+	data, err := json.Marshal(stateFile{Series: t.series})
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	err = errors.Join(err, file.Close())
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+	}
+
+	return err
+}
+
+// load reads the history saved to path
+func (t *Tracker) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for key, samples := range state.Series {
+		t.series[key] = samples
+	}
+
+	slog.Info("Loaded growth history", "file", path, "series", len(state.Series))
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package growth
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestTracker(t *testing.T, stateFile string) (*Tracker, *metrics.FilesystemRegistry) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Growth: config.GrowthConfig{
+			Enabled:    true,
+			Window:     config.Duration{Duration: 24 * time.Hour},
+			MinSamples: 3,
+			StateFile:  stateFile,
+		},
+		Directories: map[string]config.DirectoryGroup{"uploads": {Path: "/srv/uploads"}},
+	}
+
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	return NewTracker(cfg, m), m
+}
+
+func TestSlope(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Noisy growth of about 1 GB an hour, scanned at irregular times
+	samples := []Sample{
+		{Time: start, Bytes: 10e9},
+		{Time: start.Add(90 * time.Minute), Bytes: 11.6e9},
+		{Time: start.Add(5 * time.Hour), Bytes: 14.9e9},
+		{Time: start.Add(11 * time.Hour), Bytes: 21.1e9},
+	}
+
+	perSecond, ok := Slope(samples)
+	if !ok {
+		t.Fatal("expected a slope")
+	}
+
+	if perHour := perSecond * 3600; math.Abs(perHour-1e9) > 0.05e9 {
+		t.Errorf("expected about 1 GB an hour, got %g", perHour)
+	}
+
+	if _, ok := Slope(samples[:1]); ok {
+		t.Error("expected no slope from one sample")
+	}
+
+	if _, ok := Slope([]Sample{{Time: start, Bytes: 1}, {Time: start, Bytes: 2}}); ok {
+		t.Error("expected no slope from samples at the same time")
+	}
+}
+
+func TestObserveVolume(t *testing.T) {
+	tracker, m := newTestTracker(t, "")
+	fs := &config.FilesystemConfig{Name: "data", MountPoint: "/data", Device: "sdb1"}
+	start := time.Now()
+
+	tracker.ObserveVolume(fs, start, 100e9, 900e9)
+	tracker.ObserveVolume(fs, start.Add(time.Hour), 102e9, 898e9)
+
+	if series := testutil.CollectAndCount(m.VolumeGrowthGauge); series != 0 {
+		t.Errorf("expected no estimate before min_samples, got %d series", series)
+	}
+
+	tracker.ObserveVolume(fs, start.Add(2*time.Hour), 104e9, 896e9)
+
+	if growth := testutil.ToFloat64(m.VolumeGrowthGauge.WithLabelValues("sdb1", "/data", "data")); math.Abs(growth-2e9) > 1 {
+		t.Errorf("expected growth of 2e9 bytes an hour, got %g", growth)
+	}
+
+	// 896 GB available at 2 GB an hour
+	if eta := testutil.ToFloat64(m.VolumeFillETAGauge.WithLabelValues("sdb1", "/data", "data")); math.Abs(eta-448*3600) > 1 {
+		t.Errorf("expected 448 hours until full, got %gs", eta)
+	}
+
+	// Shrinking brings the trend down and removes the ETA
+	tracker.ObserveVolume(fs, start.Add(3*time.Hour), 50e9, 950e9)
+
+	if series := testutil.CollectAndCount(m.VolumeFillETAGauge); series != 0 {
+		t.Errorf("expected no fill ETA while shrinking, got %d series", series)
+	}
+}
+
+func TestObserve_WindowAndSpacing(t *testing.T) {
+	tracker, _ := newTestTracker(t, "")
+	start := time.Now()
+
+	// Collected every minute; over a 24h window samples are kept 5m apart
+	for i := range 60 {
+		tracker.ObserveDirectory("uploads", start.Add(time.Duration(i)*time.Minute), int64(i))
+	}
+
+	samples := tracker.series["directory/uploads"]
+	if len(samples) != 13 {
+		t.Fatalf("expected 12 samples 5m apart and the latest, got %d", len(samples))
+	}
+
+	if last := samples[len(samples)-1]; last.Bytes != 59 {
+		t.Errorf("expected the latest sample to be kept, got %+v", last)
+	}
+
+	tracker.ObserveDirectory("uploads", start.Add(25*time.Hour), 100)
+
+	if samples := tracker.series["directory/uploads"]; len(samples) != 1 {
+		t.Errorf("expected samples older than the window to be dropped, got %d", len(samples))
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "growth.json")
+	tracker, _ := newTestTracker(t, path)
+	start := time.Now().Add(-time.Hour)
+
+	for i := range 3 {
+		tracker.ObserveDirectory("uploads", start.Add(time.Duration(i)*10*time.Minute), int64(i)*1000)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tracker.Start(ctx)
+	cancel()
+	tracker.Wait()
+
+	if err := tracker.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	restored, m := newTestTracker(t, path)
+	if samples := restored.series["directory/uploads"]; len(samples) != 3 {
+		t.Fatalf("expected 3 restored samples, got %d", len(samples))
+	}
+
+	// One more collection after the restart is enough for an estimate
+	restored.ObserveDirectory("uploads", start.Add(30*time.Minute), 3000)
+
+	if growth := testutil.ToFloat64(m.DirectoryGrowthGauge.WithLabelValues("uploads")); math.Abs(growth-6000) > 1 {
+		t.Errorf("expected growth of 6000 bytes an hour, got %g", growth)
+	}
+}
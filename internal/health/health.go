@@ -0,0 +1,214 @@
+// Package health judges the exporter's health and readiness from how its
+// items' collections are going, for /health and /ready
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/state"
+	"filesystem-exporter/internal/version"
+)
+
+// Reasons an item is unhealthy
+const (
+	ReasonFailing = "failing" // Failed failure_threshold collections in a row
+	ReasonStale   = "stale"   // No successful collection for stale_intervals intervals
+)
+
+// ItemSource provides the state of every item, as the coordinator does
+type ItemSource interface {
+	GetAllItems(ctx context.Context) []*state.ItemState
+}
+
+// Checker answers /health and /ready
+type Checker struct {
+	config  *config.Config
+	items   ItemSource
+	started time.Time // Stands in for the last success of items not yet collected
+}
+
+// NewChecker creates a checker of the items from source
+func NewChecker(cfg *config.Config, source ItemSource) *Checker {
+	return &Checker{
+		config:  cfg,
+		items:   source,
+		started: time.Now(),
+	}
+}
+
+// UnhealthyItem is an item that made the exporter degraded
+type UnhealthyItem struct {
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	Reason              string     `json:"reason"` // failing or stale
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+}
+
+// Report is the body of /health
+type Report struct {
+	Status    string          `json:"status"` // healthy or degraded
+	Timestamp int64           `json:"timestamp"`
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit"`
+	BuildDate string          `json:"build_date"`
+	Unhealthy []UnhealthyItem `json:"unhealthy,omitempty"`
+}
+
+// Health reports the items that have failed their last failure_threshold
+// collections, or not succeeded for stale_intervals of their interval
+func (c *Checker) Health(ctx context.Context) Report {
+	now := time.Now()
+
+	report := Report{
+		Status:    "healthy",
+		Timestamp: now.Unix(),
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+	}
+
+	for _, item := range c.items.GetAllItems(ctx) {
+		reason := c.itemReason(item, now)
+		if reason == "" {
+			continue
+		}
+
+		unhealthy := UnhealthyItem{
+			Name:                item.Name,
+			Type:                item.Type,
+			Reason:              reason,
+			ConsecutiveFailures: item.ConsecutiveFailures,
+		}
+
+		if !item.LastSuccessTime.IsZero() {
+			lastSuccess := item.LastSuccessTime
+			unhealthy.LastSuccess = &lastSuccess
+		}
+
+		report.Unhealthy = append(report.Unhealthy, unhealthy)
+	}
+
+	if len(report.Unhealthy) > 0 {
+		report.Status = "degraded"
+	}
+
+	return report
+}
+
+// itemReason is why an item is unhealthy, or empty if it isn't
+func (c *Checker) itemReason(item *state.ItemState, now time.Time) string {
+	if item.ConsecutiveFailures >= c.config.Health.FailureThreshold {
+		return ReasonFailing
+	}
+
+	interval := c.interval(item)
+	if interval <= 0 {
+		return ""
+	}
+
+	// Blacked out groups aren't collected, so aren't expected to succeed
+	if group, exists := c.config.Directory(item.Name); item.Type == "directory" && exists && group.InBlackoutWindow(now) {
+		return ""
+	}
+
+	lastSuccess := item.LastSuccessTime
+	if lastSuccess.IsZero() {
+		lastSuccess = c.started
+	}
+
+	if now.Sub(lastSuccess) > time.Duration(c.config.Health.StaleIntervals*float64(interval)) {
+		return ReasonStale
+	}
+
+	return ""
+}
+
+// interval is how often an item is meant to be collected at the most,
+// allowing for adaptive_interval stretching it
+func (c *Checker) interval(item *state.ItemState) time.Duration {
+	var interval time.Duration
+
+	switch item.Type {
+	case "filesystem":
+		for _, fs := range c.config.Filesystems {
+			if fs.Name == item.Name {
+				interval = fs.Interval.Duration
+			}
+		}
+	case "directory":
+		group, _ := c.config.Directory(item.Name)
+		interval = group.Interval.Duration
+	}
+
+	if c.config.AdaptiveInterval.Enabled {
+		interval = time.Duration(float64(interval) * c.config.AdaptiveInterval.MaxMultiplier)
+	}
+
+	return interval
+}
+
+// Readiness is the body of /ready
+type Readiness struct {
+	Status  string   `json:"status"`            // ready or not_ready
+	Pending []string `json:"pending,omitempty"` // type/name of each item without a successful collection yet
+}
+
+// Ready reports whether every item has been collected successfully at least
+// once, so its series are all in place
+func (c *Checker) Ready(ctx context.Context) Readiness {
+	readiness := Readiness{Status: "ready"}
+
+	for _, item := range c.items.GetAllItems(ctx) {
+		if item.LastSuccessTime.IsZero() {
+			readiness.Pending = append(readiness.Pending, item.Type+"/"+item.Name)
+		}
+	}
+
+	if len(readiness.Pending) > 0 {
+		readiness.Status = "not_ready"
+	}
+
+	return readiness
+}
+
+// HealthHandler serves /health: 200 while healthy, and while degraded too
+// unless fail_when_degraded says to answer 503
+func (c *Checker) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Health(r.Context())
+
+		status := http.StatusOK
+		if report.Status != "healthy" && c.config.Health.FailWhenDegraded {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, report)
+	})
+}
+
+// ReadyHandler serves /ready: 200 once ready and 503 until then
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readiness := c.Ready(r.Context())
+
+		status := http.StatusOK
+		if len(readiness.Pending) > 0 {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, readiness)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
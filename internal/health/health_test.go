@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/state"
+)
+
+type fakeSource []*state.ItemState
+
+func (f fakeSource) GetAllItems(context.Context) []*state.ItemState {
+	return f
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Filesystems: []config.FilesystemConfig{
+			{Name: "root", Interval: config.Duration{Duration: time.Minute}},
+		},
+		Directories: map[string]config.DirectoryGroup{
+			"home": {Interval: config.Duration{Duration: time.Hour}},
+		},
+		Health: config.HealthConfig{Enabled: true, FailureThreshold: 3, StaleIntervals: 2},
+	}
+}
+
+func TestHealth(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+
+	items := fakeSource{
+		{Name: "root", Type: "filesystem", LastSuccessTime: now.Add(-30 * time.Second)},
+		{Name: "home", Type: "directory", LastSuccessTime: now.Add(-time.Hour), ConsecutiveFailures: 2},
+	}
+
+	checker := NewChecker(cfg, items)
+
+	if report := checker.Health(context.Background()); report.Status != "healthy" || len(report.Unhealthy) != 0 {
+		t.Fatalf("expected healthy, got %+v", report)
+	}
+
+	// A third failure in a row, and a filesystem not collected for over two
+	// intervals
+	items[1].ConsecutiveFailures = 3
+	items[0].LastSuccessTime = now.Add(-3 * time.Minute)
+
+	report := checker.Health(context.Background())
+	if report.Status != "degraded" || len(report.Unhealthy) != 2 {
+		t.Fatalf("expected two unhealthy items, got %+v", report)
+	}
+
+	if report.Unhealthy[0].Reason != ReasonStale || report.Unhealthy[1].Reason != ReasonFailing {
+		t.Errorf("expected root stale and home failing, got %+v", report.Unhealthy)
+	}
+
+	rec := httptest.NewRecorder()
+	checker.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 while degraded by default, got %d", rec.Code)
+	}
+
+	cfg.Health.FailWhenDegraded = true
+
+	rec = httptest.NewRecorder()
+	checker.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with fail_when_degraded, got %d", rec.Code)
+	}
+}
+
+func TestHealth_NeverCollected(t *testing.T) {
+	items := fakeSource{{Name: "root", Type: "filesystem"}}
+	checker := NewChecker(testConfig(), items)
+
+	// Given time for a first collection from startup
+	if report := checker.Health(context.Background()); report.Status != "healthy" {
+		t.Errorf("expected healthy just after startup, got %+v", report)
+	}
+
+	checker.started = time.Now().Add(-5 * time.Minute)
+
+	if report := checker.Health(context.Background()); report.Status != "degraded" {
+		t.Errorf("expected an item never collected to go stale, got %+v", report)
+	}
+}
+
+func TestReady(t *testing.T) {
+	items := fakeSource{
+		{Name: "root", Type: "filesystem", LastSuccessTime: time.Now()},
+		{Name: "home", Type: "directory", ConsecutiveFailures: 1},
+	}
+
+	checker := NewChecker(testConfig(), items)
+
+	rec := httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before every item has succeeded, got %d", rec.Code)
+	}
+
+	if readiness := checker.Ready(context.Background()); len(readiness.Pending) != 1 || readiness.Pending[0] != "directory/home" {
+		t.Errorf("expected home pending, got %+v", readiness)
+	}
+
+	items[1].LastSuccessTime = time.Now()
+
+	rec = httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 once every item has succeeded, got %d", rec.Code)
+	}
+}
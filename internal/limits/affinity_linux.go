@@ -0,0 +1,40 @@
+//go:build linux
+
+package limits
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// PinThread locks the calling goroutine to its thread and restricts the
+// thread to cpus with sched_setaffinity(2). Commands started from the
+// goroutine inherit the mask. The returned function restores the thread's
+// previous CPUs and unlocks it; if that fails the goroutine stays locked, so
+// the narrowed thread isn't handed to other goroutines.
+func PinThread(cpus []int) (func(), error) {
+	runtime.LockOSThread()
+
+	var previous, set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &previous); err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("sched_getaffinity: %w", err)
+	}
+
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+
+	if err := unix.SchedSetaffinity(0, &set); err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("sched_setaffinity %v: %w", cpus, err)
+	}
+
+	return func() {
+		if err := unix.SchedSetaffinity(0, &previous); err == nil {
+			runtime.UnlockOSThread()
+		}
+	}, nil
+}
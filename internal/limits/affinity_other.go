@@ -0,0 +1,13 @@
+//go:build !linux
+
+package limits
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// PinThread is not implemented on this platform
+func PinThread([]int) (func(), error) {
+	return nil, fmt.Errorf("CPU affinity is not supported on %s", runtime.GOOS)
+}
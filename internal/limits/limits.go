@@ -0,0 +1,114 @@
+package limits
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filesystem-exporter/internal/config"
+)
+
+// Limits that can terminate a command
+const (
+	LimitCPU    = "cpu"
+	LimitMemory = "memory"
+)
+
+// Limiter applies resource limits to spawned commands. RLIMIT_CPU and
+// RLIMIT_NOFILE are set on the child right after it starts; the memory and
+// I/O limits are enforced by starting the child directly inside a cgroup.
+type Limiter struct {
+	cpuSeconds   uint64
+	maxOpenFiles uint64
+
+	// cgroup is the open cgroup v2 directory commands are started in, or nil
+	// when no memory limit is configured
+	cgroup *os.File
+
+	// groupCgroups are the cgroups of directory groups with an io_limit,
+	// which their commands are started in instead
+	groupCgroups map[string]*os.File
+}
+
+// New creates a limiter, creating and configuring the cgroup when a memory
+// limit is set, and one under io_cgroup_path for each directory group with
+// an I/O limit on its commands
+func New(cfg config.CommandLimitsConfig, directories map[string]config.DirectoryGroup) (*Limiter, error) {
+	l := &Limiter{
+		cpuSeconds:   cfg.CPUSeconds,
+		maxOpenFiles: cfg.MaxOpenFiles,
+		groupCgroups: make(map[string]*os.File),
+	}
+
+	if cfg.Memory > 0 {
+		cgroup, err := openCgroup(cfg.CgroupPath, cfg.Memory.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		l.cgroup = cgroup
+	}
+
+	for name, group := range directories {
+		if !group.IOLimit.LimitsCommands() {
+			continue
+		}
+
+		cgroup, err := openIOCgroup(filepath.Join(cfg.IOCgroupPath, strings.ReplaceAll(name, "/", "_")), group.Path, group.IOLimit, cfg.Memory.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("directory '%s' io_limit: %w", name, err)
+		}
+
+		l.groupCgroups[name] = cgroup
+	}
+
+	if err := checkSupported(l); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Output runs cmd like cmd.Output with the limits applied, in the cgroup of
+// the directory group it scans when that has one. started, when not nil, is
+// called with the pid once the command has started. When the command was
+// terminated by one of the limits, limit is LimitCPU or LimitMemory.
+func (l *Limiter) Output(cmd *exec.Cmd, group string, started func(pid int)) (output []byte, limit string, err error) {
+	var stdout bytes.Buffer
+
+	cmd.Stdout = &stdout
+
+	cgroup := l.cgroup
+	if groupCgroup, exists := l.groupCgroups[group]; exists {
+		cgroup = groupCgroup
+	}
+
+	l.prepare(cmd, cgroup)
+
+	oomKillsBefore := oomKills(cgroup)
+
+	if err := cmd.Start(); err != nil {
+		return nil, "", err
+	}
+
+	// There is a short window before the limits apply, which is fine for
+	// limits meant to stop runaway scans rather than the first few syscalls
+	if err := l.limitProcess(cmd.Process.Pid); err != nil {
+		slog.Warn("Failed to apply command resource limits", "command", cmd.Args[0], "pid", cmd.Process.Pid, "error", err)
+	}
+
+	if started != nil {
+		started(cmd.Process.Pid)
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		limit = l.terminatedBy(cmd.ProcessState, cgroup, oomKillsBefore)
+	}
+
+	return stdout.Bytes(), limit, err
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package limits
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"filesystem-exporter/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// checkSupported accepts every limit on Linux
+func checkSupported(*Limiter) error {
+	return nil
+}
+
+// openCgroup creates the cgroup v2 directory if needed, sets memory.max and
+// opens it so children can be cloned straight into it
+func openCgroup(path string, memoryBytes int64) (*os.File, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", path, err)
+	}
+
+	limit := strconv.FormatInt(memoryBytes, 10)
+	if err := os.WriteFile(filepath.Join(path, "memory.max"), []byte(limit), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to set memory.max in %s (is the memory controller enabled in cgroup.subtree_control?): %w", path, err)
+	}
+
+	// Without this the kernel swaps instead of enforcing the limit. Not every
+	// kernel has swap accounting, so failures are ignored.
+	_ = os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0o644)
+
+	cgroup, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup %s: %w", path, err)
+	}
+
+	return cgroup, nil
+}
+
+// openIOCgroup creates the cgroup v2 directory of a directory group, sets
+// io.max for the disk holding path, and memory.max when memoryBytes is set
+// so the group's commands keep the memory limit, and opens it
+func openIOCgroup(cgroupPath, path string, limit config.IOLimitConfig, memoryBytes int64) (*os.File, error) {
+	disk, err := diskOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cgroupPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", cgroupPath, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(cgroupPath, "io.max"), []byte(ioMax(disk, limit)), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to set io.max in %s (is the io controller enabled in cgroup.subtree_control?): %w", cgroupPath, err)
+	}
+
+	if memoryBytes > 0 {
+		if err := os.WriteFile(filepath.Join(cgroupPath, "memory.max"), []byte(strconv.FormatInt(memoryBytes, 10)), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to set memory.max in %s (is the memory controller enabled in cgroup.subtree_control?): %w", cgroupPath, err)
+		}
+
+		_ = os.WriteFile(filepath.Join(cgroupPath, "memory.swap.max"), []byte("0"), 0o644)
+	}
+
+	cgroup, err := os.Open(cgroupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup %s: %w", cgroupPath, err)
+	}
+
+	return cgroup, nil
+}
+
+// ioMax is the io.max line for a disk, "max" standing for what isn't limited
+func ioMax(disk string, limit config.IOLimitConfig) string {
+	rbps, riops := "max", "max"
+
+	if limit.ReadBytesPerSecond > 0 {
+		rbps = strconv.FormatInt(limit.ReadBytesPerSecond.Bytes(), 10)
+	}
+
+	if limit.ReadIOPS > 0 {
+		riops = strconv.Itoa(limit.ReadIOPS)
+	}
+
+	return fmt.Sprintf("%s rbps=%s riops=%s", disk, rbps, riops)
+}
+
+// diskOf returns the MAJ:MIN of the disk holding path. io.max only takes
+// whole disks, so for a partition it is the disk the partition is on.
+func diskOf(path string) (string, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return "", err
+	}
+
+	device := fmt.Sprintf("%d:%d", unix.Major(stat.Dev), unix.Minor(stat.Dev))
+	sysfs := filepath.Join("/sys/dev/block", device)
+
+	if _, err := os.Stat(sysfs); err != nil {
+		return "", fmt.Errorf("%s is not on a block device (device %s), so its I/O can't be limited", path, device)
+	}
+
+	if _, err := os.Stat(filepath.Join(sysfs, "partition")); err != nil {
+		return device, nil
+	}
+
+	// A partition's sysfs directory is inside its disk's
+	partition, err := filepath.EvalSymlinks(sysfs)
+	if err != nil {
+		return "", err
+	}
+
+	disk, err := os.ReadFile(filepath.Join(filepath.Dir(partition), "dev"))
+	if err != nil {
+		return "", fmt.Errorf("failed to find the disk of partition %s: %w", device, err)
+	}
+
+	return strings.TrimSpace(string(disk)), nil
+}
+
+// prepare makes the child start inside cgroup (clone3, Linux 5.7+)
+func (l *Limiter) prepare(cmd *exec.Cmd, cgroup *os.File) {
+	if cgroup == nil {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+}
+
+// limitProcess sets the rlimits of a running child with prlimit(2)
+func (l *Limiter) limitProcess(pid int) error {
+	var errs []error
+
+	if l.cpuSeconds > 0 {
+		// SIGXCPU at the soft limit, SIGKILL shortly after if it is ignored
+		errs = append(errs, prlimit(pid, syscall.RLIMIT_CPU, syscall.Rlimit{Cur: l.cpuSeconds, Max: l.cpuSeconds + 5}))
+	}
+
+	if l.maxOpenFiles > 0 {
+		errs = append(errs, prlimit(pid, syscall.RLIMIT_NOFILE, syscall.Rlimit{Cur: l.maxOpenFiles, Max: l.maxOpenFiles}))
+	}
+
+	return errors.Join(errs...)
+}
+
+func prlimit(pid, resource int, limit syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("prlimit resource %d: %w", resource, errno)
+	}
+
+	return nil
+}
+
+// terminatedBy works out whether a failed command was killed by a limit.
+// RLIMIT_NOFILE makes commands fail rather than die, so it is never reported.
+func (l *Limiter) terminatedBy(state *os.ProcessState, cgroup *os.File, oomKillsBefore int64) string {
+	if state == nil {
+		return ""
+	}
+
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	switch status.Signal() {
+	case syscall.SIGXCPU:
+		return LimitCPU
+	case syscall.SIGKILL:
+		if l.cpuSeconds > 0 && state.UserTime()+state.SystemTime() >= time.Duration(l.cpuSeconds)*time.Second {
+			return LimitCPU
+		}
+
+		if cgroup != nil && oomKills(cgroup) > oomKillsBefore {
+			return LimitMemory
+		}
+	}
+
+	return ""
+}
+
+// oomKills reads the oom_kill counter of a cgroup's memory.events
+func oomKills(cgroup *os.File) int64 {
+	if cgroup == nil {
+		return 0
+	}
+
+	data, err := os.ReadFile(filepath.Join(cgroup.Name(), "memory.events"))
+	if err != nil {
+		return 0
+	}
+
+	return parseOOMKills(data)
+}
+
+// parseOOMKills extracts oom_kill from memory.events formatted input
+func parseOOMKills(data []byte) int64 {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			count, _ := strconv.ParseInt(value, 10, 64)
+			return count
+		}
+	}
+
+	return 0
+}
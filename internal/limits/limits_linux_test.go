@@ -0,0 +1,165 @@
+//go:build linux
+
+package limits
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"filesystem-exporter/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+func TestOutput_CPULimit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	l, err := New(config.CommandLimitsConfig{CPUSeconds: 1}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	_, limit, err := l.Output(exec.Command("sh", "-c", "while :; do :; done"), "", nil)
+	if err == nil {
+		t.Fatal("expected the busy loop to be killed")
+	}
+
+	if limit != LimitCPU {
+		t.Errorf("expected limit %q, got %q (error %v)", LimitCPU, limit, err)
+	}
+}
+
+func TestOutput_NoLimitHit(t *testing.T) {
+	l, err := New(config.CommandLimitsConfig{CPUSeconds: 10, MaxOpenFiles: 64}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	output, limit, err := l.Output(exec.Command("echo", "ok"), "", nil)
+	if err != nil || limit != "" || string(output) != "ok\n" {
+		t.Errorf("unexpected result %q, %q, %v", output, limit, err)
+	}
+}
+
+func TestParseOOMKills(t *testing.T) {
+	data := []byte("low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\noom_group_kill 0\n")
+
+	if got := parseOOMKills(data); got != 2 {
+		t.Errorf("expected 2 oom kills, got %d", got)
+	}
+}
+
+func TestIOMax(t *testing.T) {
+	tests := []struct {
+		limit    config.IOLimitConfig
+		expected string
+	}{
+		{config.IOLimitConfig{ReadBytesPerSecond: 20 << 20}, "8:0 rbps=20971520 riops=max"},
+		{config.IOLimitConfig{ReadIOPS: 500}, "8:0 rbps=max riops=500"},
+		{config.IOLimitConfig{ReadBytesPerSecond: 1 << 20, ReadIOPS: 100}, "8:0 rbps=1048576 riops=100"},
+	}
+
+	for _, tt := range tests {
+		if got := ioMax("8:0", tt.limit); got != tt.expected {
+			t.Errorf("ioMax(%+v) = %q, expected %q", tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep not available: %v", err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	pid := cmd.Process.Pid
+	level := 7
+
+	if err := SetPriority(pid, config.DirectoryGroup{IOPrioClass: config.IOPrioClassBestEffort, IOPrioLevel: &level, Nice: 10}); err != nil {
+		t.Fatalf("SetPriority failed: %v", err)
+	}
+
+	ioprio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		t.Fatalf("ioprio_get failed: %v", errno)
+	}
+
+	if expected := uintptr(2<<ioprioClassShift | 7); ioprio != expected {
+		t.Errorf("expected ioprio %#x, got %#x", expected, ioprio)
+	}
+
+	// getpriority(2) returns 20 - nice
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+	if err != nil {
+		t.Fatalf("getpriority failed: %v", err)
+	}
+
+	if nice := 20 - prio; nice != 10 {
+		t.Errorf("expected nice 10, got %d", nice)
+	}
+	// The level doesn't apply to the idle class
+	if err := SetIOPriorityForProcess(pid, config.IOPrioClassIdle, 5); err != nil {
+		t.Fatalf("SetIOPriorityForProcess failed: %v", err)
+	}
+
+	ioprio, _, _ = unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if expected := uintptr(3 << ioprioClassShift); ioprio != expected {
+		t.Errorf("expected ioprio %#x, got %#x", expected, ioprio)
+	}
+
+	if err := SetIOPriorityForProcess(pid, "lowest", 0); err == nil {
+		t.Error("expected an unknown class to be rejected")
+	}
+}
+
+func TestPinThread(t *testing.T) {
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		t.Fatalf("sched_getaffinity failed: %v", err)
+	}
+
+	// Pin to the first CPU the test may use
+	cpu := 0
+	for !allowed.IsSet(cpu) {
+		cpu++
+	}
+
+	unpin, err := PinThread([]int{cpu})
+	if err != nil {
+		t.Fatalf("PinThread failed: %v", err)
+	}
+
+	var pinned unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &pinned); err != nil {
+		t.Fatalf("sched_getaffinity failed: %v", err)
+	}
+
+	if pinned.Count() != 1 || !pinned.IsSet(cpu) {
+		t.Errorf("expected the thread to be pinned to CPU %d, got %d CPUs", cpu, pinned.Count())
+	}
+
+	// Commands inherit the mask
+	output, err := exec.Command("grep", "Cpus_allowed_list", "/proc/self/status").Output()
+	if err == nil && !strings.Contains(string(output), fmt.Sprintf("\t%d\n", cpu)) {
+		t.Errorf("expected a command to inherit CPU %d, got %q", cpu, output)
+	}
+
+	unpin()
+
+	var restored unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &restored); err != nil {
+		t.Fatalf("sched_getaffinity failed: %v", err)
+	}
+
+	if restored != allowed {
+		t.Errorf("expected the thread's CPUs to be restored")
+	}
+}
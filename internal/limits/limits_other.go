@@ -0,0 +1,41 @@
+//go:build !linux
+
+package limits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"filesystem-exporter/internal/config"
+)
+
+// checkSupported rejects limits on platforms without prlimit and cgroups
+func checkSupported(*Limiter) error {
+	return fmt.Errorf("command limits are not supported on %s", runtime.GOOS)
+}
+
+// openCgroup is not implemented on this platform
+func openCgroup(string, int64) (*os.File, error) {
+	return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+}
+
+// openIOCgroup is not implemented on this platform
+func openIOCgroup(string, string, config.IOLimitConfig, int64) (*os.File, error) {
+	return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+}
+
+func (l *Limiter) prepare(*exec.Cmd, *os.File) {}
+
+func (l *Limiter) limitProcess(int) error {
+	return nil
+}
+
+func (l *Limiter) terminatedBy(*os.ProcessState, *os.File, int64) string {
+	return ""
+}
+
+func oomKills(*os.File) int64 {
+	return 0
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package limits
+
+import (
+	"errors"
+	"fmt"
+
+	"filesystem-exporter/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants, which x/sys/unix doesn't define
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var ioprioClasses = map[string]int{
+	config.IOPrioClassRealtime:   1,
+	config.IOPrioClassBestEffort: 2,
+	config.IOPrioClassIdle:       3,
+}
+
+// SetPriority applies a directory group's ioprio_class, ioprio_level and nice
+// to a running command. Both are per thread, so they reach what the command
+// starts from then on but not threads it already has.
+func SetPriority(pid int, group config.DirectoryGroup) error {
+	var errs []error
+
+	if group.IOPrioClass != "" {
+		errs = append(errs, SetIOPriorityForProcess(pid, group.IOPrioClass, group.GetIOPrioLevel()))
+	}
+
+	if group.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, group.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("setpriority: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetIOPriorityForProcess sets the I/O scheduling class and level of a
+// process with ioprio_set(2). x/sys has no wrapper, but its syscall numbers
+// are right for each architecture. The level is ignored for the idle class.
+func SetIOPriorityForProcess(pid int, class string, level int) error {
+	classValue, exists := ioprioClasses[class]
+	if !exists {
+		return fmt.Errorf("unknown I/O scheduling class '%s'", class)
+	}
+
+	if class == config.IOPrioClassIdle {
+		level = 0
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(classValue<<ioprioClassShift|level)); errno != 0 {
+		return fmt.Errorf("ioprio_set: %w", errno)
+	}
+
+	return nil
+}
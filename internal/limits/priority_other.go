@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package limits
+
+import (
+	"fmt"
+
+	"filesystem-exporter/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// SetPriority applies a directory group's nice to a running command. I/O
+// scheduling classes are Linux only, which validation enforces.
+func SetPriority(pid int, group config.DirectoryGroup) error {
+	if group.Nice == 0 {
+		return nil
+	}
+
+	if err := unix.Setpriority(unix.PRIO_PROCESS, pid, group.Nice); err != nil {
+		return fmt.Errorf("setpriority: %w", err)
+	}
+
+	return nil
+}
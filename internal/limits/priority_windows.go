@@ -0,0 +1,10 @@
+//go:build windows
+
+package limits
+
+import "filesystem-exporter/internal/config"
+
+// SetPriority does nothing on Windows, where no commands are spawned
+func SetPriority(int, config.DirectoryGroup) error {
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package logging sets up the default logger with a level for each component
+// and a limit on how often the same message is logged
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+// modulePrefix is where the exporter's own packages live. The component of a
+// package is its path beneath it, like collectors/zfs.
+const modulePrefix = "filesystem-exporter/internal/"
+
+// maxTrackedMessages bounds how many messages the rate limit keeps counts
+// for before forgetting those whose interval is over
+const maxTrackedMessages = 1000
+
+// handler filters records by their component's level and the rate limit
+// before passing them on
+type handler struct {
+	next    slog.Handler // Enabled for the lowest level of any component
+	levels  *levels
+	limiter *limiter // nil without logging.rate_limit
+}
+
+// levels holds the level of each component, looked up by the PC of the call
+// that logged
+type levels struct {
+	base       slog.Level
+	min        slog.Level
+	components map[string]slog.Level
+
+	byPC sync.Map // uintptr to slog.Level
+}
+
+// limiter counts the records of each message in the current interval
+type limiter struct {
+	burst    int
+	interval time.Duration
+
+	mu       sync.Mutex
+	messages map[limitKey]*limitState
+}
+
+type limitKey struct {
+	level   slog.Level
+	message string
+}
+
+type limitState struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// Configure sets the default logger as cfg.Logging, logging.levels and
+// logging.rate_limit say
+func Configure(cfg *config.Config) {
+	slog.SetDefault(slog.New(newHandler(cfg, func(level slog.Level) slog.Handler {
+		return newFormatHandler(os.Stdout, cfg.Logging.Format, level)
+	})))
+}
+
+// newHandler creates a handler passing records on to the one newNext creates
+// for the lowest level of any component
+func newHandler(cfg *config.Config, newNext func(slog.Level) slog.Handler) *handler {
+	l := &levels{
+		base:       parseLevel(cfg.Logging.Level),
+		components: make(map[string]slog.Level, len(cfg.LogLevels)),
+	}
+	l.min = l.base
+
+	for component, level := range cfg.LogLevels {
+		l.components[component] = parseLevel(level)
+		l.min = min(l.min, l.components[component])
+	}
+
+	h := &handler{next: newNext(l.min), levels: l}
+
+	if cfg.LogRateLimit.Burst > 0 {
+		h.limiter = &limiter{
+			burst:    cfg.LogRateLimit.Burst,
+			interval: cfg.LogRateLimit.Interval.Duration,
+			messages: make(map[limitKey]*limitState),
+		}
+	}
+
+	return h
+}
+
+// newFormatHandler creates a JSON or text handler, as promexporter would
+func newFormatHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+
+	if strings.EqualFold(format, "text") {
+		return slog.NewTextHandler(w, opts)
+	}
+
+	return slog.NewJSONHandler(w, opts)
+}
+
+// parseLevel parses a configured level, which validation has already checked
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Enabled implements slog.Handler. It can only rule out levels no component
+// logs at, since the component isn't known until the record is built.
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.min && h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levels.forPC(r.PC) {
+		return nil
+	}
+
+	if h.limiter != nil {
+		allowed, suppressed := h.limiter.allow(r.Level, r.Message, r.Time)
+		if !allowed {
+			return nil
+		}
+
+		if suppressed > 0 {
+			r = r.Clone()
+			r.AddAttrs(slog.Int("suppressed", suppressed))
+		}
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{next: h.next.WithAttrs(attrs), levels: h.levels, limiter: h.limiter}
+}
+
+// WithGroup implements slog.Handler
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), levels: h.levels, limiter: h.limiter}
+}
+
+// forPC returns the level of the component that logged from pc
+func (l *levels) forPC(pc uintptr) slog.Level {
+	if len(l.components) == 0 || pc == 0 {
+		return l.base
+	}
+
+	if level, ok := l.byPC.Load(pc); ok {
+		return level.(slog.Level)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	level := l.forComponent(component(frame.Function))
+	l.byPC.Store(pc, level)
+
+	return level
+}
+
+// forComponent returns the level of a component, or of the closest
+// component containing it, like collectors for collectors/zfs
+func (l *levels) forComponent(component string) slog.Level {
+	for component != "" {
+		if level, ok := l.components[component]; ok {
+			return level
+		}
+
+		i := strings.LastIndex(component, "/")
+		if i < 0 {
+			break
+		}
+
+		component = component[:i]
+	}
+
+	return l.base
+}
+
+// component returns the component of a fully qualified function name: its
+// package's path beneath internal, main for the command, or "" for a
+// dependency
+func component(function string) string {
+	if strings.HasPrefix(function, "main.") {
+		return "main"
+	}
+
+	rest, ok := strings.CutPrefix(function, modulePrefix)
+	if !ok {
+		return ""
+	}
+
+	// The package ends at the first dot after the last slash
+	dir, name := "", rest
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		dir, name = rest[:i+1], rest[i+1:]
+	}
+
+	pkg, _, _ := strings.Cut(name, ".")
+
+	return dir + pkg
+}
+
+// allow reports whether a record may be logged, and if so how many of the
+// same message were suppressed in the interval before
+func (l *limiter) allow(level slog.Level, message string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := limitKey{level: level, message: message}
+
+	state, ok := l.messages[key]
+	if !ok {
+		if len(l.messages) >= maxTrackedMessages {
+			l.forget(now)
+		}
+
+		state = &limitState{start: now}
+		l.messages[key] = state
+	}
+
+	suppressed := 0
+
+	if now.Sub(state.start) >= l.interval {
+		suppressed = state.suppressed
+		*state = limitState{start: now}
+	}
+
+	state.count++
+	if state.count > l.burst {
+		state.suppressed++
+		return false, 0
+	}
+
+	return true, suppressed
+}
+
+// forget drops the messages whose interval is over. Their suppressed counts
+// are lost.
+func (l *limiter) forget(now time.Time) {
+	for key, state := range l.messages {
+		if now.Sub(state.start) >= l.interval {
+			delete(l.messages, key)
+		}
+	}
+}
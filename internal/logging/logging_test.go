@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+func newTestLogger(t *testing.T, cfg *config.Config) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	h := newHandler(cfg, func(level slog.Level) slog.Handler {
+		return newFormatHandler(&buf, "text", level)
+	})
+
+	return slog.New(h), &buf
+}
+
+func TestComponent(t *testing.T) {
+	for function, expected := range map[string]string{
+		"filesystem-exporter/internal/scheduler.(*Scheduler).run":          "scheduler",
+		"filesystem-exporter/internal/collectors/zfs.(*Collector).Collect": "collectors/zfs",
+		"filesystem-exporter/internal/worker.(*Worker).processJob.func1":   "worker",
+		"main.main": "main",
+		"github.com/d0ugal/promexporter/app.(*App).Run":                         "",
+		"filesystem-exporter/internal/collectors/bucket.New[...].func2":         "collectors/bucket",
+		"filesystem-exporter/internal/logging.TestComponent":                    "logging",
+		"filesystem-exporter/internal/collectors/kubernetes.parseVolumes.func3": "collectors/kubernetes",
+	} {
+		if got := component(function); got != expected {
+			t.Errorf("component(%q) = %q, expected %q", function, got, expected)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	cfg := &config.Config{LogLevels: map[string]string{"collectors": "debug", "collectors/zfs": "error", "logging": "warn"}}
+	cfg.Logging.Level = "info"
+
+	logger, buf := newTestLogger(t, cfg)
+
+	// Logged from this package, whose component is logging
+	logger.Info("quiet")
+	logger.Warn("loud")
+
+	if strings.Contains(buf.String(), "quiet") || !strings.Contains(buf.String(), "loud") {
+		t.Errorf("expected only the warning, got:\n%s", buf)
+	}
+
+	h := logger.Handler().(*handler)
+
+	for component, expected := range map[string]slog.Level{
+		"collectors/docker": slog.LevelDebug,
+		"collectors/zfs":    slog.LevelError,
+		"scheduler":         slog.LevelInfo,
+		"":                  slog.LevelInfo,
+	} {
+		if got := h.levels.forComponent(component); got != expected {
+			t.Errorf("expected %s for %q, got %s", expected, component, got)
+		}
+	}
+
+	if !h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug records built while a component logs at debug")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.Config{LogRateLimit: config.LogRateLimitConfig{Burst: 2, Interval: config.Duration{Duration: time.Minute}}}
+	cfg.Logging.Level = "info"
+
+	h := newHandler(cfg, func(slog.Level) slog.Handler { return slog.DiscardHandler })
+
+	start := time.Now()
+
+	for i, expected := range []bool{true, true, false, false} {
+		if allowed, _ := h.limiter.allow(slog.LevelInfo, "tick", start.Add(time.Duration(i)*time.Second)); allowed != expected {
+			t.Errorf("record %d: expected allowed %t", i, expected)
+		}
+	}
+
+	if allowed, _ := h.limiter.allow(slog.LevelWarn, "tick", start); !allowed {
+		t.Error("expected another level of the same message counted separately")
+	}
+
+	allowed, suppressed := h.limiter.allow(slog.LevelInfo, "tick", start.Add(time.Minute))
+	if !allowed || suppressed != 2 {
+		t.Errorf("expected the next interval allowed with 2 suppressed, got %t and %d", allowed, suppressed)
+	}
+
+	logger, buf := newTestLogger(t, cfg)
+	for range 5 {
+		logger.Info("flood")
+	}
+
+	if n := strings.Count(buf.String(), "flood"); n != 2 {
+		t.Errorf("expected 2 records logged, got %d:\n%s", n, buf)
+	}
+}
@@ -1,31 +1,107 @@
 package metrics
 
 import (
+	"fmt"
+	"reflect"
+
 	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// QuotaGauges holds the usage and limit gauges for one kind of quota
+type QuotaGauges struct {
+	UsedBytes       *prometheus.GaugeVec
+	SoftLimitBytes  *prometheus.GaugeVec
+	HardLimitBytes  *prometheus.GaugeVec
+	UsedInodes      *prometheus.GaugeVec
+	SoftLimitInodes *prometheus.GaugeVec
+	HardLimitInodes *prometheus.GaugeVec
+}
+
 // FilesystemRegistry wraps the promexporter registry with filesystem-specific metrics
 type FilesystemRegistry struct {
 	*promexporter_metrics.Registry
 
+	// Static label names appended to the volume and directory series
+	customLabels []string
+
+	// Labels of the directory size series, and when each was measured with
+	// directory timestamps enabled (nil otherwise)
+	directoryLabels []string
+	directoryTimes  *sampleTimes
+
 	// Volume metrics (documented)
 	VolumeSizeGauge      *prometheus.GaugeVec
 	VolumeAvailableGauge *prometheus.GaugeVec
+	VolumeFreeGauge      *prometheus.GaugeVec
+	VolumeReservedGauge  *prometheus.GaugeVec
 	VolumeUsedRatioGauge *prometheus.GaugeVec
+	VolumeDriftGauge     *prometheus.GaugeVec
+	VolumeGrowthGauge    *prometheus.GaugeVec
+	VolumeFillETAGauge   *prometheus.GaugeVec
+
+	// Kubernetes PVC metrics
+	PVCSizeGauge      *prometheus.GaugeVec
+	PVCAvailableGauge *prometheus.GaugeVec
+	PVCUsedGauge      *prometheus.GaugeVec
+
+	// Docker metrics
+	DockerVolumeSizeGauge        *prometheus.GaugeVec
+	DockerImageSizeGauge         *prometheus.GaugeVec
+	DockerImageSharedSizeGauge   *prometheus.GaugeVec
+	DockerContainerWritableGauge *prometheus.GaugeVec
+	DockerLayersSizeGauge        prometheus.Gauge
+	DockerBuildCacheSizeGauge    prometheus.Gauge
+
+	// Object storage metrics
+	BucketSizeGauge    *prometheus.GaugeVec
+	BucketObjectsGauge *prometheus.GaugeVec
+
+	// Network mount probe metrics
+	VolumeReachableGauge       *prometheus.GaugeVec
+	VolumeProbeFailuresCounter *prometheus.CounterVec
 
 	// Directory metrics (documented)
-	DirectorySizeGauge *prometheus.GaugeVec
+	DirectorySizeGauge          *prometheus.GaugeVec
+	DirectorySizeSmoothedGauge  *prometheus.GaugeVec
+	DirectorySizeDeltaGauge     *prometheus.GaugeVec
+	DirectoryHardlinksSizeGauge *prometheus.GaugeVec
+	DirectoryTopNSizeGauge      *prometheus.GaugeVec
+	DirectoryLastModifiedGauge  *prometheus.GaugeVec
+	DirectoryPathInfo           *prometheus.GaugeVec
+	DirectoryExpectedSizeGauge  *prometheus.GaugeVec
+	DirectoryVarianceGauge      *prometheus.GaugeVec
+	DirectoryGrowthGauge        *prometheus.GaugeVec
+
+	// Sample mode estimates
+	DirectoryEstimateRelativeErrorGauge *prometheus.GaugeVec
+	DirectoryLastFullScanGauge          *prometheus.GaugeVec
+
+	// Directory ownership metrics
+	DirectoryOwnerSizeGauge      *prometheus.GaugeVec
+	DirectoryOwnerGroupSizeGauge *prometheus.GaugeVec
+	DirectoryCacheAdviceCounter  *prometheus.CounterVec
+	DirectoryScanErrorsCounter   *prometheus.CounterVec
+	DirectoryMissingGauge        *prometheus.GaugeVec
+
+	// Cardinality guard for directory groups with max_series set
+	SeriesDroppedCounter *prometheus.CounterVec
 
 	// Collection metrics (documented)
-	CollectionDuration      *prometheus.GaugeVec
-	CollectionSuccess       *prometheus.CounterVec
-	CollectionFailedCounter *prometheus.CounterVec
-	CollectionTotal         *prometheus.CounterVec
+	CollectionDuration       *prometheus.GaugeVec
+	CollectionDurationHist   *prometheus.HistogramVec
+	CommandDurationHist      *prometheus.HistogramVec
+	CommandMaxRSSGauge       *prometheus.GaugeVec
+	CollectionSuccess        *prometheus.CounterVec
+	CollectionFailedCounter  *prometheus.CounterVec
+	CollectionRetriesCounter *prometheus.CounterVec
+	CollectionTotal          *prometheus.CounterVec
 
 	// Additional operational metrics (used by collectors but not documented)
 	CollectionIntervalGauge     *prometheus.GaugeVec
+	EffectiveIntervalGauge      *prometheus.GaugeVec
+	CollectionPartialGauge      *prometheus.GaugeVec
 	CollectionTimestampGauge    *prometheus.GaugeVec
 	DirectoriesFailedCounter    *prometheus.CounterVec
 	DuLockWaitDurationGauge     *prometheus.GaugeVec
@@ -34,10 +110,53 @@ type FilesystemRegistry struct {
 	// Operational metrics
 	QueueDepthGauge          *prometheus.GaugeVec
 	QueueWaitSecondsGauge    *prometheus.GaugeVec
+	QueueEnqueueWaitSeconds  *prometheus.HistogramVec
+	QueueDequeueIdleSeconds  *prometheus.HistogramVec
+	QueueDroppedCounter      *prometheus.CounterVec
 	CollectionActiveGauge    *prometheus.GaugeVec
 	CollectionSkippedCounter *prometheus.CounterVec
+	CommandLimitKillsCounter *prometheus.CounterVec
+	CommandKilledCounter     *prometheus.CounterVec
+	CommandPriorityGauge     *prometheus.GaugeVec
+	WalkSandboxGauge         prometheus.Gauge
+	PanicsCounter            *prometheus.CounterVec
+	WorkerRestartsCounter    *prometheus.CounterVec
+	DigestsSentCounter       *prometheus.CounterVec
+	AlertFiringGauge         *prometheus.GaugeVec
+	WebhookNotifications     *prometheus.CounterVec
+	TLSCertificateExpiry     prometheus.Gauge
+	TLSReloadsCounter        *prometheus.CounterVec
+	AuthFailuresCounter      *prometheus.CounterVec
+	ProbesCounter            *prometheus.CounterVec
 	GoroutineCountGauge      prometheus.Gauge
 
+	// Item health metrics
+	ItemConsecutiveFailuresGauge *prometheus.GaugeVec
+	ItemEnabledGauge             *prometheus.GaugeVec
+
+	// Soft quota metrics
+	QuotaBytesGauge    *prometheus.GaugeVec
+	QuotaExceededGauge *prometheus.GaugeVec
+
+	// ZFS dataset metrics
+	ZFSDatasetUsedGauge          *prometheus.GaugeVec
+	ZFSDatasetAvailableGauge     *prometheus.GaugeVec
+	ZFSDatasetReferencedGauge    *prometheus.GaugeVec
+	ZFSDatasetCompressRatioGauge *prometheus.GaugeVec
+
+	// Btrfs metrics
+	BtrfsQgroupReferencedGauge *prometheus.GaugeVec
+	BtrfsQgroupExclusiveGauge  *prometheus.GaugeVec
+	BtrfsAllocationSizeGauge   *prometheus.GaugeVec
+	BtrfsAllocationUsedGauge   *prometheus.GaugeVec
+
+	// User and group quota metrics
+	UserQuota  QuotaGauges
+	GroupQuota QuotaGauges
+
+	// Project quota metrics for directory groups in project_quota mode
+	ProjectQuota QuotaGauges
+
 	// Per-job resource metrics (self-measurement)
 	JobCPUUserSeconds       *prometheus.GaugeVec
 	JobCPUSystemSeconds     *prometheus.GaugeVec
@@ -56,10 +175,18 @@ type FilesystemRegistry struct {
 	CollectionTimeoutSeconds *prometheus.GaugeVec
 }
 
-// NewFilesystemRegistry creates a new filesystem metrics registry
-func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *FilesystemRegistry {
+// NewFilesystemRegistry creates a new filesystem metrics registry. The
+// volume and directory series get customLabels on top of their own, for the
+// static labels configured on filesystems and directory groups.
+func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry, customLabels ...string) *FilesystemRegistry {
+	itemLabels := func(labels ...string) []string {
+		return append(labels, customLabels...)
+	}
+
 	filesystem := &FilesystemRegistry{
-		Registry: baseRegistry,
+		Registry:        baseRegistry,
+		customLabels:    customLabels,
+		directoryLabels: itemLabels("group", "directory", "mode", "size_mode", "subdirectory_level"),
 
 		// Volume metrics (documented)
 		VolumeSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
@@ -67,21 +194,153 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 				Name: "filesystem_exporter_volume_size_bytes",
 				Help: "Volume size in bytes",
 			},
-			[]string{"device", "mount_point", "volume"},
+			itemLabels("device", "mount_point", "volume"),
 		),
 		VolumeAvailableGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "filesystem_exporter_volume_available_bytes",
 				Help: "Volume available space in bytes",
 			},
-			[]string{"device", "mount_point", "volume"},
+			itemLabels("device", "mount_point", "volume"),
+		),
+		VolumeFreeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_free_bytes",
+				Help: "Volume free space in bytes, including space reserved for root",
+			},
+			itemLabels("device", "mount_point", "volume"),
+		),
+		VolumeReservedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_reserved_bytes",
+				Help: "Volume free space in bytes that is not available to unprivileged users",
+			},
+			itemLabels("device", "mount_point", "volume"),
 		),
 		VolumeUsedRatioGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "filesystem_exporter_volume_used_ratio",
 				Help: "Volume used space ratio (0-1)",
 			},
-			[]string{"device", "mount_point", "volume"},
+			itemLabels("device", "mount_point", "volume"),
+		),
+		VolumeDriftGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_directory_drift_bytes",
+				Help: "Volume used bytes minus the summed size of the directory groups covering it",
+			},
+			itemLabels("volume", "mount_point"),
+		),
+		VolumeGrowthGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_growth_bytes_per_hour",
+				Help: "Growth of volume used bytes per hour, by linear regression over the growth window",
+			},
+			itemLabels("device", "mount_point", "volume"),
+		),
+		VolumeFillETAGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_fill_eta_seconds",
+				Help: "Seconds until the volume has no available space at its growth rate (only while growing)",
+			},
+			itemLabels("device", "mount_point", "volume"),
+		),
+
+		// Kubernetes PVC metrics
+		PVCSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_pvc_size_bytes",
+				Help: "Total size of a PersistentVolume mounted into a pod in bytes",
+			},
+			[]string{"namespace", "pvc", "pod", "pod_uid", "volume"},
+		),
+		PVCAvailableGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_pvc_available_bytes",
+				Help: "Available space on a PersistentVolume mounted into a pod in bytes",
+			},
+			[]string{"namespace", "pvc", "pod", "pod_uid", "volume"},
+		),
+		PVCUsedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_pvc_used_bytes",
+				Help: "Used space on a PersistentVolume mounted into a pod in bytes",
+			},
+			[]string{"namespace", "pvc", "pod", "pod_uid", "volume"},
+		),
+
+		// Docker metrics
+		DockerVolumeSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_volume_size_bytes",
+				Help: "Disk usage of a Docker volume in bytes",
+			},
+			[]string{"volume", "driver"},
+		),
+		DockerImageSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_image_size_bytes",
+				Help: "Total size of a Docker image's layers in bytes",
+			},
+			[]string{"image_id", "image"},
+		),
+		DockerImageSharedSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_image_shared_size_bytes",
+				Help: "Size of a Docker image's layers that are shared with other images in bytes",
+			},
+			[]string{"image_id", "image"},
+		),
+		DockerContainerWritableGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_container_writable_bytes",
+				Help: "Size of a Docker container's writable layer in bytes",
+			},
+			[]string{"container", "image"},
+		),
+		DockerLayersSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_layers_size_bytes",
+				Help: "Total size of all Docker image layers in bytes, counting shared layers once",
+			},
+		),
+		DockerBuildCacheSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_docker_build_cache_size_bytes",
+				Help: "Total size of the Docker build cache in bytes",
+			},
+		),
+
+		// Object storage metrics
+		BucketSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_bucket_size_bytes",
+				Help: "Total size of the objects in a bucket in bytes",
+			},
+			[]string{"endpoint", "bucket"},
+		),
+		BucketObjectsGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_bucket_objects",
+				Help: "Number of objects in a bucket",
+			},
+			[]string{"endpoint", "bucket"},
+		),
+
+		// Network mount probe metrics
+		VolumeReachableGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_volume_reachable",
+				Help: "Whether a network mount answered its last statfs probe in time (1 = yes, 0 = no)",
+			},
+			itemLabels("volume", "mount_point"),
+		),
+		VolumeProbeFailuresCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_volume_probe_failures_total",
+				Help: "Total number of failed network mount probes",
+			},
+			itemLabels("volume", "mount_point", "reason"),
 		),
 
 		// Directory metrics (documented)
@@ -90,7 +349,130 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 				Name: "filesystem_exporter_directory_size_bytes",
 				Help: "Directory size in bytes",
 			},
-			[]string{"group", "directory", "mode", "subdirectory_level"},
+			itemLabels("group", "directory", "mode", "size_mode", "subdirectory_level"),
+		),
+		DirectorySizeSmoothedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_size_smoothed_bytes",
+				Help: "Exponential moving average of directory size in bytes",
+			},
+			itemLabels("group", "directory", "mode", "size_mode", "subdirectory_level"),
+		),
+		DirectorySizeDeltaGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_size_delta_bytes",
+				Help: "Change in a directory's size in bytes since its previous collection",
+			},
+			itemLabels("group", "directory"),
+		),
+		DirectoryHardlinksSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_size_with_hardlinks_bytes",
+				Help: "Size of a directory group in bytes counting every hardlink to a file",
+			},
+			itemLabels("group"),
+		),
+		DirectoryTopNSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_topn_size_bytes",
+				Help: "Size in bytes of the largest immediate children of a directory group",
+			},
+			itemLabels("group", "rank", "entry"),
+		),
+		DirectoryLastModifiedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_last_modified_timestamp",
+				Help: "Unix timestamp of the newest modification time seen in a directory's subtree",
+			},
+			itemLabels("group", "path"),
+		),
+		DirectoryEstimateRelativeErrorGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_estimate_relative_error",
+				Help: "Approximate relative standard error of a sampled directory size estimate (0 after a full walk)",
+			},
+			itemLabels("group"),
+		),
+		DirectoryGrowthGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_growth_bytes_per_hour",
+				Help: "Growth of a directory group's total size per hour, by linear regression over the growth window",
+			},
+			itemLabels("group"),
+		),
+		DirectoryLastFullScanGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_last_full_scan_timestamp",
+				Help: "Unix timestamp of the last full walk of a sample mode directory group",
+			},
+			itemLabels("group"),
+		),
+		DirectoryExpectedSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_expected_size_bytes",
+				Help: "Baseline size of a directory from expected_size or the baseline file",
+			},
+			itemLabels("group", "directory"),
+		),
+		DirectoryVarianceGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_size_variance_ratio",
+				Help: "Relative difference between a directory's measured and baseline size, (size - expected) / expected",
+			},
+			itemLabels("group", "directory"),
+		),
+		DirectoryPathInfo: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_path_info",
+				Help: "Configured and symlink-resolved root path of each directory group (always 1)",
+			},
+			itemLabels("group", "path", "canonical_path"),
+		),
+
+		// Directory ownership metrics
+		DirectoryOwnerSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_owner_size_bytes",
+				Help: "Disk usage in bytes of a directory group attributed to each owning user",
+			},
+			itemLabels("group", "uid", "user"),
+		),
+		DirectoryOwnerGroupSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_owner_group_size_bytes",
+				Help: "Disk usage in bytes of a directory group attributed to each owning group",
+			},
+			itemLabels("group", "gid", "owner_group"),
+		),
+		DirectoryCacheAdviceCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_directory_cache_advice_total",
+				Help: "Directories dropped from the page cache after being walked, by result",
+			},
+			itemLabels("group", "result"),
+		),
+		DirectoryScanErrorsCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_directory_scan_errors_total",
+				Help: "Entries du couldn't read while scanning a directory group, by reason",
+			},
+			itemLabels("group", "reason"),
+		),
+		DirectoryMissingGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_directory_missing",
+				Help: "1 for one collection after a directory measured before has disappeared and its series were deleted",
+			},
+			itemLabels("group", "directory"),
+		),
+
+		// Cardinality guard for directory groups with max_series set
+		SeriesDroppedCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_series_dropped_total",
+				Help: "Directories left out of a group's series to stay within max_series",
+			},
+			[]string{"group"},
 		),
 
 		// Collection metrics (documented)
@@ -101,6 +483,29 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 			[]string{"group", "interval_seconds", "type"},
 		),
+		CollectionDurationHist: promauto.With(baseRegistry.GetRegistry()).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "filesystem_exporter_collection_duration_histogram_seconds",
+				Help:    "Distribution of collection durations in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.01, 3, 14), // 10ms to ~4h
+			},
+			[]string{"group", "interval_seconds", "type"},
+		),
+		CommandDurationHist: promauto.With(baseRegistry.GetRegistry()).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "filesystem_exporter_command_duration_seconds",
+				Help:    "Distribution of external command run times in seconds, including failed runs",
+				Buckets: prometheus.ExponentialBuckets(0.01, 3, 14), // 10ms to ~4h
+			},
+			[]string{"command", "type", "group"},
+		),
+		CommandMaxRSSGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_command_max_rss_bytes",
+				Help: "Peak resident memory in bytes of the latest run of an external command",
+			},
+			[]string{"command", "type"},
+		),
 		CollectionSuccess: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "filesystem_exporter_collection_success_total",
@@ -108,12 +513,19 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 			[]string{"group", "interval_seconds", "type"},
 		),
+		CollectionRetriesCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_collection_retries_total",
+				Help: "Total number of failed collection attempts retried within the same job, by reason",
+			},
+			[]string{"group", "interval_seconds", "type", "reason"},
+		),
 		CollectionFailedCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "filesystem_exporter_collection_failed_total",
-				Help: "Total number of failed collections",
+				Help: "Total number of failed collections, by reason: timeout, permission, not_found, not_mounted, parse, panic or other",
 			},
-			[]string{"group", "interval_seconds", "type"},
+			[]string{"group", "interval_seconds", "type", "reason"},
 		),
 		CollectionTotal: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
 			prometheus.CounterOpts{
@@ -131,6 +543,20 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 			[]string{"group", "type"},
 		),
+		EffectiveIntervalGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_effective_interval_seconds",
+				Help: "Interval collections are currently scheduled at, after any adaptive_interval stretching",
+			},
+			[]string{"group", "type"},
+		),
+		CollectionPartialGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_collection_partial",
+				Help: "1 while an item's published sizes come from a collection that timed out part way, 0 after a complete one",
+			},
+			[]string{"group", "type"},
+		),
 		CollectionTimestampGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "filesystem_exporter_collection_timestamp",
@@ -175,10 +601,33 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 			[]string{"queue_type"},
 		),
+		QueueEnqueueWaitSeconds: promauto.With(baseRegistry.GetRegistry()).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "filesystem_exporter_queue_enqueue_wait_seconds",
+				Help:    "Time spent blocked enqueuing a job because the queue was full",
+				Buckets: prometheus.ExponentialBuckets(0.001, 4, 12),
+			},
+			[]string{"queue_type"},
+		),
+		QueueDequeueIdleSeconds: promauto.With(baseRegistry.GetRegistry()).NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "filesystem_exporter_queue_dequeue_idle_seconds",
+				Help:    "Time a worker spent idle waiting for its next job",
+				Buckets: prometheus.ExponentialBuckets(0.001, 4, 12),
+			},
+			[]string{"queue_type"},
+		),
+		QueueDroppedCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_queue_dropped_total",
+				Help: "Total number of jobs dropped instead of being queued",
+			},
+			[]string{"queue_type", "reason"},
+		),
 		CollectionActiveGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "filesystem_exporter_collection_active",
-				Help: "Currently running collection (1 if active, 0 if idle)",
+				Help: "Currently running collections (0 if idle; above 1 only for directories with device_concurrency)",
 			},
 			[]string{"queue_type"},
 		),
@@ -189,6 +638,95 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 			[]string{"queue_type", "item_name", "reason"},
 		),
+		CommandLimitKillsCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_command_limit_terminations_total",
+				Help: "Total number of external commands terminated by a configured resource limit",
+			},
+			[]string{"command", "limit"},
+		),
+		CommandKilledCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_command_killed_total",
+				Help: "Total number of signals sent to the process groups of timed out or aborted df and du commands",
+			},
+			[]string{"signal"},
+		),
+		CommandPriorityGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_command_priority_applied",
+				Help: "Whether the I/O and CPU priority of a directory group's latest command was applied (1) or failed (0)",
+			},
+			itemLabels("group"),
+		),
+		WalkSandboxGauge: promauto.With(baseRegistry.GetRegistry()).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_walk_sandbox_supported",
+				Help: "Whether the kernel supports Landlock, which sandboxed walks need (1) or not (0)",
+			},
+		),
+		PanicsCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_panics_total",
+				Help: "Total number of panics recovered, by component",
+			},
+			[]string{"component"},
+		),
+		WorkerRestartsCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_worker_restarts_total",
+				Help: "Total number of worker run loops restarted, by reason: stuck or exited",
+			},
+			[]string{"queue_type", "reason"},
+		),
+		DigestsSentCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_digests_sent_total",
+				Help: "Total number of capacity digest emails attempted, by result",
+			},
+			[]string{"result"},
+		),
+		AlertFiringGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_alert_firing",
+				Help: "Whether a built-in alert rule is firing for an item (1) or not (0)",
+			},
+			[]string{"rule", "item_type", "item_name"},
+		),
+		WebhookNotifications: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_webhook_notifications_total",
+				Help: "Total number of alert notifications sent to webhooks, by result",
+			},
+			[]string{"webhook", "result"},
+		),
+		TLSCertificateExpiry: promauto.With(baseRegistry.GetRegistry()).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_tls_certificate_expiry_timestamp_seconds",
+				Help: "Unix time the serving certificate expires (only when server.tls is configured)",
+			},
+		),
+		TLSReloadsCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_tls_reloads_total",
+				Help: "Total number of TLS certificate reloads after a file change, by result",
+			},
+			[]string{"result"},
+		),
+		AuthFailuresCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_http_auth_failures_total",
+				Help: "Total number of HTTP requests rejected for missing or wrong credentials, by server",
+			},
+			[]string{"server"},
+		),
+		ProbesCounter: promauto.With(baseRegistry.GetRegistry()).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "filesystem_exporter_probes_total",
+				Help: "Total number of /probe requests, by module and result",
+			},
+			[]string{"module", "result"},
+		),
 		GoroutineCountGauge: promauto.With(baseRegistry.GetRegistry()).NewGauge(
 			prometheus.GaugeOpts{
 				Name: "filesystem_exporter_goroutines",
@@ -196,6 +734,98 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 			},
 		),
 
+		// Item health metrics
+		ItemConsecutiveFailuresGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_item_consecutive_failures",
+				Help: "Number of consecutive failed collections per item (0 after a success)",
+			},
+			[]string{"item_name", "item_type"},
+		),
+		ItemEnabledGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_item_enabled",
+				Help: "Whether a configured item is collected (1) or disabled with enabled: false (0)",
+			},
+			[]string{"item_name", "item_type"},
+		),
+
+		// Soft quota metrics
+		QuotaBytesGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_quota_bytes",
+				Help: "Configured soft quota in bytes per item",
+			},
+			[]string{"item_name", "item_type"},
+		),
+		QuotaExceededGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_quota_exceeded",
+				Help: "Whether an item's measured usage exceeds its soft quota (1 if exceeded, 0 otherwise)",
+			},
+			[]string{"item_name", "item_type"},
+		),
+
+		// ZFS dataset metrics
+		ZFSDatasetUsedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_zfs_dataset_used_bytes",
+				Help: "Space consumed by a ZFS dataset and all its descendants in bytes",
+			},
+			[]string{"dataset", "pool"},
+		),
+		ZFSDatasetAvailableGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_zfs_dataset_available_bytes",
+				Help: "Space available to a ZFS dataset in bytes",
+			},
+			[]string{"dataset", "pool"},
+		),
+		ZFSDatasetReferencedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_zfs_dataset_referenced_bytes",
+				Help: "Data accessible by a ZFS dataset (possibly shared with other datasets) in bytes",
+			},
+			[]string{"dataset", "pool"},
+		),
+		ZFSDatasetCompressRatioGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_zfs_dataset_compress_ratio",
+				Help: "Compression ratio achieved for a ZFS dataset",
+			},
+			[]string{"dataset", "pool"},
+		),
+
+		// Btrfs metrics
+		BtrfsQgroupReferencedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_btrfs_qgroup_referenced_bytes",
+				Help: "Bytes referenced by a btrfs qgroup, including extents shared with other subvolumes",
+			},
+			[]string{"mount_point", "qgroupid", "path"},
+		),
+		BtrfsQgroupExclusiveGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_btrfs_qgroup_exclusive_bytes",
+				Help: "Bytes used exclusively by a btrfs qgroup, i.e. freed if it were deleted",
+			},
+			[]string{"mount_point", "qgroupid", "path"},
+		),
+		BtrfsAllocationSizeGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_btrfs_allocation_size_bytes",
+				Help: "Bytes allocated to btrfs block groups by type and profile",
+			},
+			[]string{"mount_point", "type", "profile"},
+		),
+		BtrfsAllocationUsedGauge: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "filesystem_exporter_btrfs_allocation_used_bytes",
+				Help: "Bytes used within btrfs block groups by type and profile",
+			},
+			[]string{"mount_point", "type", "profile"},
+		),
+
 		// Per-job resource metrics
 		JobCPUUserSeconds: promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -275,15 +905,221 @@ func NewFilesystemRegistry(baseRegistry *promexporter_metrics.Registry) *Filesys
 		),
 	}
 
+	filesystem.UserQuota = filesystem.newQuotaGauges(baseRegistry, "user", []string{"volume", "uid"})
+	filesystem.GroupQuota = filesystem.newQuotaGauges(baseRegistry, "group", []string{"volume", "gid"})
+	filesystem.ProjectQuota = filesystem.newQuotaGauges(baseRegistry, "project", []string{"group", "project_id"})
+
 	// Add metric metadata for UI (only documented metrics)
 	filesystem.AddMetricInfo("filesystem_exporter_volume_size_bytes", "Total size of volume in bytes", []string{"volume", "mount_point", "device"})
 	filesystem.AddMetricInfo("filesystem_exporter_volume_available_bytes", "Available space on volume in bytes", []string{"volume", "mount_point", "device"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_free_bytes", "Free space on volume in bytes, including space reserved for root", []string{"volume", "mount_point", "device"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_reserved_bytes", "Free space on volume reserved for root (free minus available)", []string{"volume", "mount_point", "device"})
 	filesystem.AddMetricInfo("filesystem_exporter_volume_used_ratio", "Ratio of used space on volume (0.0 to 1.0)", []string{"volume", "mount_point", "device"})
-	filesystem.AddMetricInfo("filesystem_exporter_directory_size_bytes", "Size of directory in bytes", []string{"group", "directory", "mode", "subdirectory_level"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_directory_drift_bytes", "Volume used bytes minus the summed size of its covered_by directory groups", []string{"volume", "mount_point"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_growth_bytes_per_hour", "Growth of volume used bytes per hour over the growth window (only with growth enabled)", []string{"volume", "mount_point", "device"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_fill_eta_seconds", "Seconds until the volume is full at its growth rate (only while growing)", []string{"volume", "mount_point", "device"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_reachable", "Whether a network mount answered its last statfs probe in time", []string{"volume", "mount_point"})
+	filesystem.AddMetricInfo("filesystem_exporter_volume_probe_failures_total", "Failed network mount probes by reason (timeout, stale or error)", []string{"volume", "mount_point", "reason"})
+	filesystem.AddMetricInfo("filesystem_exporter_pvc_size_bytes", "Total size of a PersistentVolume mounted into a pod", []string{"namespace", "pvc", "pod", "pod_uid", "volume"})
+	filesystem.AddMetricInfo("filesystem_exporter_pvc_available_bytes", "Available space on a PersistentVolume mounted into a pod", []string{"namespace", "pvc", "pod", "pod_uid", "volume"})
+	filesystem.AddMetricInfo("filesystem_exporter_pvc_used_bytes", "Used space on a PersistentVolume mounted into a pod", []string{"namespace", "pvc", "pod", "pod_uid", "volume"})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_volume_size_bytes", "Disk usage of a Docker volume", []string{"volume", "driver"})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_image_size_bytes", "Total size of a Docker image's layers", []string{"image_id", "image"})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_image_shared_size_bytes", "Size of a Docker image's layers shared with other images", []string{"image_id", "image"})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_container_writable_bytes", "Size of a Docker container's writable (overlay2 upper) layer", []string{"container", "image"})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_layers_size_bytes", "Total size of all Docker image layers, counting shared layers once", []string{})
+	filesystem.AddMetricInfo("filesystem_exporter_docker_build_cache_size_bytes", "Total size of the Docker build cache", []string{})
+	filesystem.AddMetricInfo("filesystem_exporter_bucket_size_bytes", "Total size of the objects in an S3-compatible bucket", []string{"endpoint", "bucket"})
+	filesystem.AddMetricInfo("filesystem_exporter_bucket_objects", "Number of objects in an S3-compatible bucket", []string{"endpoint", "bucket"})
+	filesystem.AddMetricInfo("filesystem_exporter_command_limit_terminations_total", "External commands terminated by command_limits (limit is cpu or memory)", []string{"command", "limit"})
+	filesystem.AddMetricInfo("filesystem_exporter_command_killed_total", "Signals sent to the process groups of timed out or aborted df and du commands (signal is SIGTERM or SIGKILL)", []string{"signal"})
+	filesystem.AddMetricInfo("filesystem_exporter_command_priority_applied", "Whether the priority of a directory group's latest command was applied (only for groups with ioprio_class or nice set)", []string{"group"})
+	filesystem.AddMetricInfo("filesystem_exporter_alert_firing", "Whether a built-in alert rule is firing for an item", []string{"rule", "item_type", "item_name"})
+	filesystem.AddMetricInfo("filesystem_exporter_webhook_notifications_total", "Alert notifications sent to webhooks (result is success, failure or dropped)", []string{"webhook", "result"})
+	filesystem.AddMetricInfo("filesystem_exporter_tls_certificate_expiry_timestamp_seconds", "Unix time the serving certificate expires (only when server.tls is configured)", []string{})
+	filesystem.AddMetricInfo("filesystem_exporter_tls_reloads_total", "TLS certificate reloads after a file change (result is success or failure)", []string{"result"})
+	filesystem.AddMetricInfo("filesystem_exporter_http_auth_failures_total", "HTTP requests rejected for missing or wrong credentials (server is metrics, probe, events, group_metrics or api)", []string{"server"})
+	filesystem.AddMetricInfo("filesystem_exporter_probes_total", "/probe requests (result is success, failure or rejected)", []string{"module", "result"})
+	filesystem.AddMetricInfo("filesystem_exporter_walk_sandbox_supported", "Whether the kernel supports Landlock, which sandboxed walks need", []string{})
+	filesystem.AddMetricInfo("filesystem_exporter_panics_total", "Total number of panics recovered, by component", []string{"component"})
+	filesystem.AddMetricInfo("filesystem_exporter_worker_restarts_total", "Total number of worker run loops restarted (reason is stuck or exited)", []string{"queue_type", "reason"})
+	filesystem.AddMetricInfo("filesystem_exporter_digests_sent_total", "Capacity digest emails attempted (result is success or failure)", []string{"result"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_size_bytes", "Size of directory in bytes", []string{"group", "directory", "mode", "size_mode", "subdirectory_level"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_size_smoothed_bytes", "Exponential moving average of directory size in bytes (only for groups with smoothing_alpha set)", []string{"group", "directory", "mode", "size_mode", "subdirectory_level"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_expected_size_bytes", "Baseline size of a directory (only for directories with a baseline)", []string{"group", "directory"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_size_variance_ratio", "(size - expected) / expected for directories with a non-zero baseline", []string{"group", "directory"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_path_info", "Configured and symlink-resolved root path of each directory group (always 1)", []string{"group", "path", "canonical_path"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_topn_size_bytes", "Size of the N largest immediate children of a directory group (only for groups with top_n set)", []string{"group", "rank", "entry"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_last_modified_timestamp", "Unix timestamp of the newest modification time in a directory's subtree (walk mode only)", []string{"group", "path"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_size_delta_bytes", "Change in a directory's size in bytes since its previous collection", []string{"group", "directory"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_growth_bytes_per_hour", "Growth of a directory group's total size per hour over the growth window (only with growth enabled)", []string{"group"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_size_with_hardlinks_bytes", "Size of a directory group in bytes counting every hardlink to a file (only for groups with hardlinks_total set)", []string{"group"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_estimate_relative_error", "Approximate relative standard error of a sampled directory size estimate (only for groups with mode sample)", []string{"group"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_last_full_scan_timestamp", "Unix timestamp of the last full walk of a sample mode directory group", []string{"group"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_owner_size_bytes", "Disk usage of a directory group per owning user (only for groups with group_by_owner set)", []string{"group", "uid", "user"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_cache_advice_total", "Directories dropped from the page cache after being walked (only for groups with drop_page_cache set)", []string{"group", "result"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_scan_errors_total", "Entries du couldn't read while scanning a directory group (reason is permission, not_found or other)", []string{"group", "reason"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_missing", "1 for one collection after a previously measured directory disappeared", []string{"group", "directory"})
+	filesystem.AddMetricInfo("filesystem_exporter_directory_owner_group_size_bytes", "Disk usage of a directory group per owning group (only for groups with group_by_owner set)", []string{"group", "gid", "owner_group"})
+	filesystem.AddMetricInfo("filesystem_exporter_series_dropped_total", "Directories left out of a group's series to stay within max_series (only for groups with max_series set)", []string{"group"})
 	filesystem.AddMetricInfo("filesystem_exporter_collection_duration_seconds", "Duration of collection in seconds", []string{"group", "interval_seconds", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_collection_duration_histogram_seconds", "Distribution of collection durations in seconds", []string{"group", "interval_seconds", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_command_duration_seconds", "Distribution of df, du, zfs and btrfs run times in seconds", []string{"command", "type", "group"})
+	filesystem.AddMetricInfo("filesystem_exporter_command_max_rss_bytes", "Peak resident memory of the latest df, du or exec scanner run", []string{"command", "type"})
 	filesystem.AddMetricInfo("filesystem_exporter_collection_success_total", "Total number of successful collections", []string{"group", "interval_seconds", "type"})
-	filesystem.AddMetricInfo("filesystem_exporter_collection_failed_total", "Total number of failed collections", []string{"group", "interval_seconds", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_collection_failed_total", "Total number of failed collections (reason is timeout, permission, not_found, not_mounted, parse, panic or other)", []string{"group", "interval_seconds", "type", "reason"})
+	filesystem.AddMetricInfo("filesystem_exporter_collection_retries_total", "Total number of failed collection attempts retried within the same job (reason as for collection_failed_total)", []string{"group", "interval_seconds", "type", "reason"})
 	filesystem.AddMetricInfo("filesystem_exporter_collection_total", "Total number of collections (successful and failed)", []string{"group", "interval_seconds", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_collection_partial", "1 while an item's published sizes come from a collection that timed out part way", []string{"group", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_effective_interval_seconds", "Interval collections are currently scheduled at", []string{"group", "type"})
+	filesystem.AddMetricInfo("filesystem_exporter_zfs_dataset_used_bytes", "Space consumed by a ZFS dataset and its descendants in bytes", []string{"dataset", "pool"})
+	filesystem.AddMetricInfo("filesystem_exporter_zfs_dataset_available_bytes", "Space available to a ZFS dataset in bytes", []string{"dataset", "pool"})
+	filesystem.AddMetricInfo("filesystem_exporter_zfs_dataset_referenced_bytes", "Data accessible by a ZFS dataset in bytes", []string{"dataset", "pool"})
+	filesystem.AddMetricInfo("filesystem_exporter_zfs_dataset_compress_ratio", "Compression ratio achieved for a ZFS dataset", []string{"dataset", "pool"})
+	filesystem.AddMetricInfo("filesystem_exporter_btrfs_qgroup_referenced_bytes", "Bytes referenced by a btrfs qgroup (including shared extents)", []string{"mount_point", "qgroupid", "path"})
+	filesystem.AddMetricInfo("filesystem_exporter_btrfs_qgroup_exclusive_bytes", "Bytes used exclusively by a btrfs qgroup", []string{"mount_point", "qgroupid", "path"})
+	filesystem.AddMetricInfo("filesystem_exporter_btrfs_allocation_size_bytes", "Bytes allocated to btrfs block groups by type and profile", []string{"mount_point", "type", "profile"})
+	filesystem.AddMetricInfo("filesystem_exporter_btrfs_allocation_used_bytes", "Bytes used within btrfs block groups by type and profile", []string{"mount_point", "type", "profile"})
+	filesystem.AddMetricInfo("filesystem_exporter_queue_enqueue_wait_seconds", "Time spent blocked enqueuing a job because the queue was full", []string{"queue_type"})
+	filesystem.AddMetricInfo("filesystem_exporter_queue_dequeue_idle_seconds", "Time a worker spent idle waiting for its next job", []string{"queue_type"})
+	filesystem.AddMetricInfo("filesystem_exporter_queue_dropped_total", "Total number of jobs dropped instead of being queued", []string{"queue_type", "reason"})
+	filesystem.AddMetricInfo("filesystem_exporter_quota_bytes", "Configured soft quota in bytes per item", []string{"item_name", "item_type"})
+	filesystem.AddMetricInfo("filesystem_exporter_quota_exceeded", "Whether an item's usage exceeds its soft quota (1 or 0)", []string{"item_name", "item_type"})
+	filesystem.AddMetricInfo("filesystem_exporter_item_consecutive_failures", "Number of consecutive failed collections per item", []string{"item_name", "item_type"})
+	filesystem.AddMetricInfo("filesystem_exporter_item_enabled", "Whether a configured item is collected or disabled", []string{"item_name", "item_type"})
 
 	return filesystem
 }
+
+// newQuotaGauges registers the filesystem_exporter_quota_<kind>_* gauges for
+// one kind of quota
+func (r *FilesystemRegistry) newQuotaGauges(baseRegistry *promexporter_metrics.Registry, kind string, labels []string) QuotaGauges {
+	gauge := func(suffix, help string) *prometheus.GaugeVec {
+		name := "filesystem_exporter_quota_" + kind + "_" + suffix
+		help = fmt.Sprintf(help, kind)
+		r.AddMetricInfo(name, help, labels)
+
+		return promauto.With(baseRegistry.GetRegistry()).NewGaugeVec(
+			prometheus.GaugeOpts{Name: name, Help: help},
+			labels,
+		)
+	}
+
+	return QuotaGauges{
+		UsedBytes:       gauge("used_bytes", "Bytes used per %s quota"),
+		SoftLimitBytes:  gauge("soft_limit_bytes", "Soft block limit in bytes per %s quota (0 = no limit)"),
+		HardLimitBytes:  gauge("hard_limit_bytes", "Hard block limit in bytes per %s quota (0 = no limit)"),
+		UsedInodes:      gauge("used_inodes", "Inodes used per %s quota"),
+		SoftLimitInodes: gauge("soft_limit_inodes", "Soft inode limit per %s quota (0 = no limit)"),
+		HardLimitInodes: gauge("hard_limit_inodes", "Hard inode limit per %s quota (0 = no limit)"),
+	}
+}
+
+// ItemLabelValues appends an item's static label values to values, in the
+// order the volume and directory series were created with. Labels the item
+// doesn't set are left empty.
+func (r *FilesystemRegistry) ItemLabelValues(labels map[string]string, values ...string) []string {
+	for _, name := range r.customLabels {
+		values = append(values, labels[name])
+	}
+
+	return values
+}
+
+// ItemLabels adds an item's static labels to labels, for series set with With
+func (r *FilesystemRegistry) ItemLabels(labels map[string]string, into prometheus.Labels) prometheus.Labels {
+	for _, name := range r.customLabels {
+		into[name] = labels[name]
+	}
+
+	return into
+}
+
+// Labels that hold the name of the item a series is about, and its kind
+var (
+	itemNameLabels = []string{"group", "item_name", "job_name"}
+	itemTypeLabels = []string{"type", "item_type", "queue_type", "job_type"}
+)
+
+// DeleteDirectoryGroupSeries deletes every series of a directory group, for
+// one removed at runtime: those naming it in an item name label, other than
+// series whose item type label says they are about another kind of item
+func (r *FilesystemRegistry) DeleteDirectoryGroupSeries(name string) {
+	for _, vec := range r.vecs() {
+		for _, nameLabel := range itemNameLabels {
+			if !hasLabel(vec, nameLabel) {
+				continue
+			}
+
+			typed := false
+
+			for _, typeLabel := range itemTypeLabels {
+				if hasLabel(vec, typeLabel) {
+					typed = true
+
+					vec.DeletePartialMatch(prometheus.Labels{nameLabel: name, typeLabel: "directory"})
+				}
+			}
+
+			if !typed {
+				vec.DeletePartialMatch(prometheus.Labels{nameLabel: name})
+			}
+		}
+	}
+
+	if r.directoryTimes != nil {
+		r.directoryTimes.deleteGroup(name)
+	}
+}
+
+// vecs returns every vec of the registry's series. They are found by
+// reflection so a vec added to FilesystemRegistry is never missed.
+func (r *FilesystemRegistry) vecs() []*prometheus.MetricVec {
+	var vecs []*prometheus.MetricVec
+
+	add := func(field any) {
+		switch vec := field.(type) {
+		case *prometheus.GaugeVec:
+			if vec != nil {
+				vecs = append(vecs, vec.MetricVec)
+			}
+		case *prometheus.CounterVec:
+			if vec != nil {
+				vecs = append(vecs, vec.MetricVec)
+			}
+		case *prometheus.HistogramVec:
+			if vec != nil {
+				vecs = append(vecs, vec.MetricVec)
+			}
+		}
+	}
+
+	v := reflect.ValueOf(r).Elem()
+
+	for i := range v.NumField() {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+
+		field := v.Field(i).Interface()
+
+		if quota, ok := field.(QuotaGauges); ok {
+			for _, gauge := range []*prometheus.GaugeVec{quota.UsedBytes, quota.SoftLimitBytes, quota.HardLimitBytes, quota.UsedInodes, quota.SoftLimitInodes, quota.HardLimitInodes} {
+				add(gauge)
+			}
+
+			continue
+		}
+
+		add(field)
+	}
+
+	return vecs
+}
+
+// hasLabel reports whether a vec's series have the label
+func hasLabel(vec *prometheus.MetricVec, label string) bool {
+	_, err := vec.CurryWith(prometheus.Labels{label: ""})
+
+	return err == nil
+}
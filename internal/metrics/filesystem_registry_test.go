@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"strings"
 	"testing"
 
 	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
@@ -19,10 +20,10 @@ func TestFilesystemRegistry(t *testing.T) {
 	registry.VolumeSizeGauge.With(prometheus.Labels{"device": "test", "mount_point": "/", "volume": "test"}).Set(1)
 	registry.VolumeAvailableGauge.With(prometheus.Labels{"device": "test", "mount_point": "/", "volume": "test"}).Set(1)
 	registry.VolumeUsedRatioGauge.With(prometheus.Labels{"device": "test", "mount_point": "/", "volume": "test"}).Set(1)
-	registry.DirectorySizeGauge.With(prometheus.Labels{"group": "test", "directory": "/", "mode": "test", "subdirectory_level": "0"}).Set(1)
+	registry.DirectorySizeGauge.With(prometheus.Labels{"group": "test", "directory": "/", "mode": "test", "size_mode": "disk_usage", "subdirectory_level": "0"}).Set(1)
 	registry.CollectionDuration.With(prometheus.Labels{"group": "test", "interval_seconds": "60", "type": "test"}).Set(1)
 	registry.CollectionSuccess.With(prometheus.Labels{"group": "test", "interval_seconds": "60", "type": "test"}).Inc()
-	registry.CollectionFailedCounter.With(prometheus.Labels{"group": "test", "interval_seconds": "60", "type": "test"}).Inc()
+	registry.CollectionFailedCounter.With(prometheus.Labels{"group": "test", "interval_seconds": "60", "type": "test", "reason": "other"}).Inc()
 	registry.CollectionTotal.With(prometheus.Labels{"group": "test", "interval_seconds": "60", "type": "test"}).Inc()
 
 	// Test that all documented metrics are registered
@@ -174,6 +175,7 @@ func TestCollectionMetrics(t *testing.T) {
 		"group":            "test",
 		"interval_seconds": "60",
 		"type":             "filesystem",
+		"reason":           "timeout",
 	}).Inc()
 
 	registry.CollectionTotal.With(prometheus.Labels{
@@ -206,6 +208,7 @@ func TestDirectoryMetrics(t *testing.T) {
 		"group":              "test",
 		"directory":          "/tmp",
 		"mode":               "du",
+		"size_mode":          "disk_usage",
 		"subdirectory_level": "0",
 	}).Set(1024000)
 
@@ -220,3 +223,53 @@ func TestDirectoryMetrics(t *testing.T) {
 		t.Errorf("Expected at least 1 metric family, got %d", metricFamilies)
 	}
 }
+
+func TestFilesystemRegistry_CustomLabels(t *testing.T) {
+	baseRegistry := promexporter_metrics.NewRegistry("test")
+	registry := NewFilesystemRegistry(baseRegistry, "team", "tier")
+
+	registry.VolumeSizeGauge.WithLabelValues(registry.ItemLabelValues(map[string]string{"team": "platform"}, "/dev/sda1", "/", "root")...).Set(1)
+	registry.DirectoryPathInfo.With(registry.ItemLabels(nil, prometheus.Labels{"group": "home", "path": "/home", "canonical_path": "/home"})).Set(1)
+
+	expected := `
+# HELP filesystem_exporter_volume_size_bytes Volume size in bytes
+# TYPE filesystem_exporter_volume_size_bytes gauge
+filesystem_exporter_volume_size_bytes{device="/dev/sda1",mount_point="/",team="platform",tier="",volume="root"} 1
+`
+	if err := testutil.GatherAndCompare(baseRegistry.GetRegistry(), strings.NewReader(expected), "filesystem_exporter_volume_size_bytes"); err != nil {
+		t.Error(err)
+	}
+
+	if count := testutil.CollectAndCount(registry.DirectoryPathInfo); count != 1 {
+		t.Errorf("expected an unlabelled group to still export its series, got %d", count)
+	}
+}
+
+func TestDeleteDirectoryGroupSeries(t *testing.T) {
+	baseRegistry := promexporter_metrics.NewRegistry("test")
+	registry := NewFilesystemRegistry(baseRegistry, "team")
+
+	for _, name := range []string{"scratch", "home"} {
+		registry.DirectorySizeGauge.WithLabelValues(registry.ItemLabelValues(nil, name, "/"+name, "du", "disk_usage", "0")...).Set(1)
+		registry.CollectionSuccess.WithLabelValues(name, "60", "directory").Inc()
+		registry.ItemEnabledGauge.WithLabelValues(name, "directory").Set(1)
+		registry.CollectionSkippedCounter.WithLabelValues("directory", name, "already_queued").Inc()
+	}
+
+	// A filesystem with the same name keeps its series
+	registry.CollectionSuccess.WithLabelValues("scratch", "60", "filesystem").Inc()
+
+	registry.DeleteDirectoryGroupSeries("scratch")
+
+	if count := testutil.CollectAndCount(registry.DirectorySizeGauge); count != 1 {
+		t.Errorf("expected only home's size series, got %d", count)
+	}
+
+	if count := testutil.CollectAndCount(registry.CollectionSuccess); count != 2 {
+		t.Errorf("expected home's and the filesystem's collection series, got %d", count)
+	}
+
+	if count := testutil.CollectAndCount(registry.ItemEnabledGauge) + testutil.CollectAndCount(registry.CollectionSkippedCounter); count != 2 {
+		t.Errorf("expected only home's item series, got %d", count)
+	}
+}
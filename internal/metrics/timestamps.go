@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleTimes holds when each directory size series was last measured, keyed
+// by its label values in the order of the directory size series' labels
+type sampleTimes struct {
+	labels []string
+
+	mu    sync.RWMutex
+	times map[string]time.Time
+}
+
+// timestampedVec exposes a vec's samples with the time they were measured,
+// for the series that have one, rather than leaving the scrape time to
+// Prometheus
+type timestampedVec struct {
+	vec   *prometheus.GaugeVec
+	times *sampleTimes
+}
+
+// EnableDirectoryTimestamps exposes the directory size series, and their
+// smoothed companions, with the time each was measured. It replaces their
+// registrations, so it must be called before the first scrape.
+func (r *FilesystemRegistry) EnableDirectoryTimestamps() error {
+	r.directoryTimes = &sampleTimes{
+		labels: r.directoryLabels,
+		times:  make(map[string]time.Time),
+	}
+
+	registry := r.GetRegistry()
+
+	for _, vec := range []*prometheus.GaugeVec{r.DirectorySizeGauge, r.DirectorySizeSmoothedGauge} {
+		registry.Unregister(vec)
+
+		if err := registry.Register(&timestampedVec{vec: vec, times: r.directoryTimes}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetDirectorySampleTime records when the directory size series with the
+// label values was measured. It does nothing unless directory timestamps are
+// enabled.
+func (r *FilesystemRegistry) SetDirectorySampleTime(t time.Time, values ...string) {
+	if r.directoryTimes == nil {
+		return
+	}
+
+	r.directoryTimes.mu.Lock()
+	r.directoryTimes.times[strings.Join(values, "\x00")] = t
+	r.directoryTimes.mu.Unlock()
+}
+
+// DeleteDirectorySampleTime forgets the time of a directory size series
+// that was deleted
+func (r *FilesystemRegistry) DeleteDirectorySampleTime(values ...string) {
+	if r.directoryTimes == nil {
+		return
+	}
+
+	r.directoryTimes.mu.Lock()
+	delete(r.directoryTimes.times, strings.Join(values, "\x00"))
+	r.directoryTimes.mu.Unlock()
+}
+
+// deleteGroup forgets the times of a directory group's series
+func (s *sampleTimes) deleteGroup(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.times {
+		if group, _, _ := strings.Cut(key, "\x00"); group == name {
+			delete(s.times, key)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (v *timestampedVec) Describe(ch chan<- *prometheus.Desc) {
+	v.vec.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (v *timestampedVec) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+
+	go func() {
+		v.vec.Collect(metrics)
+		close(metrics)
+	}()
+
+	for metric := range metrics {
+		if t, ok := v.times.lookup(metric); ok {
+			metric = prometheus.NewMetricWithTimestamp(t, metric)
+		}
+
+		ch <- metric
+	}
+}
+
+// lookup returns when a series was measured
+func (s *sampleTimes) lookup(metric prometheus.Metric) (time.Time, bool) {
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil {
+		return time.Time{}, false
+	}
+
+	byName := make(map[string]string, len(m.GetLabel()))
+	for _, pair := range m.GetLabel() {
+		byName[pair.GetName()] = pair.GetValue()
+	}
+
+	values := make([]string, len(s.labels))
+	for i, name := range s.labels {
+		values[i] = byName[name]
+	}
+
+	s.mu.RLock()
+	t, ok := s.times[strings.Join(values, "\x00")]
+	s.mu.RUnlock()
+
+	return t, ok
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+func TestEnableDirectoryTimestamps(t *testing.T) {
+	baseRegistry := promexporter_metrics.NewRegistry("test")
+	registry := NewFilesystemRegistry(baseRegistry, "team")
+
+	if err := registry.EnableDirectoryTimestamps(); err != nil {
+		t.Fatalf("EnableDirectoryTimestamps failed: %v", err)
+	}
+
+	measured := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	stamped := []string{"uploads", "/srv/uploads", "du", "disk_usage", "0", "platform"}
+	unstamped := []string{"uploads", "/srv/uploads/tmp", "du", "disk_usage", "1", "platform"}
+
+	registry.DirectorySizeGauge.WithLabelValues(stamped...).Set(10)
+	registry.DirectorySizeGauge.WithLabelValues(unstamped...).Set(20)
+	registry.SetDirectorySampleTime(measured, stamped...)
+
+	timestamps := func() map[string]int64 {
+		families, err := baseRegistry.GetRegistry().Gather()
+		if err != nil {
+			t.Fatalf("Gather failed: %v", err)
+		}
+
+		found := make(map[string]int64)
+
+		for _, family := range families {
+			if family.GetName() != "filesystem_exporter_directory_size_bytes" {
+				continue
+			}
+
+			for _, metric := range family.GetMetric() {
+				for _, pair := range metric.GetLabel() {
+					if pair.GetName() == "directory" {
+						found[pair.GetValue()] = metric.GetTimestampMs()
+					}
+				}
+			}
+		}
+
+		return found
+	}
+
+	found := timestamps()
+	if found["/srv/uploads"] != measured.UnixMilli() {
+		t.Errorf("expected the measured time on the stamped series, got %d", found["/srv/uploads"])
+	}
+
+	if ts, ok := found["/srv/uploads/tmp"]; !ok || ts != 0 {
+		t.Errorf("expected the unstamped series without a timestamp, got %d (present %v)", ts, ok)
+	}
+
+	registry.DeleteDirectorySampleTime(stamped...)
+
+	if ts := timestamps()["/srv/uploads"]; ts != 0 {
+		t.Errorf("expected no timestamp once forgotten, got %d", ts)
+	}
+}
@@ -0,0 +1,73 @@
+// Package mounts lists the filesystems mounted on the host, for bootstrapping
+// the filesystems section of a config
+package mounts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Mount is one entry of the mount table
+type Mount struct {
+	Device     string
+	MountPoint string
+	FSType     string
+}
+
+// pseudoFSTypes are kernel, virtual and in-memory filesystems that hold no
+// data worth monitoring
+var pseudoFSTypes = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devfs": true, "devpts": true, "devtmpfs": true,
+	"efivarfs": true, "fdescfs": true, "fusectl": true, "hugetlbfs": true, "mqueue": true,
+	"nsfs": true, "nullfs": true, "overlay": true, "proc": true, "pstore": true,
+	"ramfs": true, "rpc_pipefs": true, "securityfs": true, "squashfs": true, "sysfs": true,
+	"tmpfs": true, "tracefs": true,
+}
+
+// IsPseudo reports whether the mount is a virtual filesystem rather than
+// storage
+func (m Mount) IsPseudo() bool {
+	return pseudoFSTypes[m.FSType]
+}
+
+// mountEscapes decodes the octal escapes used in /proc/mounts
+var mountEscapes = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// parseMounts reads /proc/mounts formatted input. A mount point mounted over
+// is listed once, with the entry that shadows the others.
+func parseMounts(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		mount := Mount{
+			Device:     mountEscapes.Replace(fields[0]),
+			MountPoint: mountEscapes.Replace(fields[1]),
+			FSType:     fields[2],
+		}
+
+		if i, exists := index[mount.MountPoint]; exists {
+			mounts[i] = mount
+			continue
+		}
+
+		index[mount.MountPoint] = len(mounts)
+		mounts = append(mounts, mount)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	return mounts, nil
+}
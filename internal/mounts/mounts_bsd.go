@@ -0,0 +1,35 @@
+//go:build darwin || freebsd || dragonfly
+
+package mounts
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// List returns the mounts reported by getfsstat(2)
+func List() ([]Mount, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	stats := make([]unix.Statfs_t, n)
+
+	n, err = unix.Getfsstat(stats, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	mounts := make([]Mount, 0, n)
+	for _, st := range stats[:n] {
+		mounts = append(mounts, Mount{
+			Device:     unix.ByteSliceToString(st.Mntfromname[:]),
+			MountPoint: unix.ByteSliceToString(st.Mntonname[:]),
+			FSType:     unix.ByteSliceToString(st.Fstypename[:]),
+		})
+	}
+
+	return mounts, nil
+}
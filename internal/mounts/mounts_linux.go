@@ -0,0 +1,19 @@
+//go:build linux
+
+package mounts
+
+import (
+	"fmt"
+	"os"
+)
+
+// List returns the mounts in /proc/self/mounts
+func List() ([]Mount, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer f.Close()
+
+	return parseMounts(f)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows && !darwin && !freebsd && !dragonfly
+
+package mounts
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// List is not implemented on this platform
+func List() ([]Mount, error) {
+	return nil, fmt.Errorf("listing mounts is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,37 @@
+package mounts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	table := `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid 0 0
+/dev/sdb1 /mnt/backup\040disk ext4 rw 0 0
+/dev/sdc1 /data ext4 rw 0 0
+/dev/sdd1 /data xfs rw 0 0
+`
+
+	mounts, err := parseMounts(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mounts) != 4 {
+		t.Fatalf("expected 4 mounts, got %v", mounts)
+	}
+
+	if mounts[2].MountPoint != "/mnt/backup disk" {
+		t.Errorf("expected escaped spaces to be decoded, got %q", mounts[2].MountPoint)
+	}
+
+	// The later mount over /data shadows the earlier one
+	if mounts[3].Device != "/dev/sdd1" || mounts[3].FSType != "xfs" {
+		t.Errorf("expected the shadowing mount of /data, got %+v", mounts[3])
+	}
+
+	if mounts[0].IsPseudo() || !mounts[1].IsPseudo() {
+		t.Errorf("expected only proc to be a pseudo filesystem")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package mounts
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// List returns the drive letters in use, with the filesystem of each volume
+func List() ([]Mount, error) {
+	buf := make([]uint16, 256)
+
+	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drives: %w", err)
+	}
+
+	var mounts []Mount
+
+	// The buffer holds NUL separated root paths such as C:\
+	for start := 0; start < int(n); {
+		end := start
+		for end < int(n) && buf[end] != 0 {
+			end++
+		}
+
+		rootPtr := &buf[start]
+		root := windows.UTF16ToString(buf[start:end])
+		start = end + 1
+
+		mount := Mount{Device: root, MountPoint: root}
+
+		// Drives without media (an empty card reader) have no filesystem
+		fsName := make([]uint16, windows.MAX_PATH+1)
+		if err := windows.GetVolumeInformation(rootPtr, nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName))); err == nil {
+			mount.FSType = windows.UTF16ToString(fsName)
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, nil
+}
@@ -0,0 +1,142 @@
+// Package ncdu writes directory trees in the JSON export format of ncdu, so a
+// scan can be browsed with ncdu -f.
+package ncdu
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Entry is the record of a file or directory
+type Entry struct {
+	Name      string `json:"name"`
+	Asize     int64  `json:"asize,omitempty"` // Apparent size
+	Dsize     int64  `json:"dsize,omitempty"` // Disk usage of the entry itself
+	Dev       uint64 `json:"dev,omitempty"`
+	Ino       uint64 `json:"ino,omitempty"`
+	Hlnkc     bool   `json:"hlnkc,omitempty"` // Has more than one hardlink
+	Nlink     uint64 `json:"nlink,omitempty"`
+	Mtime     int64  `json:"mtime,omitempty"`
+	ReadError bool   `json:"read_error,omitempty"`
+	Excluded  string `json:"excluded,omitempty"` // e.g. "otherfs"
+	Notreg    bool   `json:"notreg,omitempty"`   // Neither a regular file nor a directory
+}
+
+// Writer streams a tree as an export. Entries are added depth first, each
+// directory before its contents, starting with the root, so only the path
+// to the current directory is held in memory.
+type Writer struct {
+	out  *bufio.Writer
+	dirs []string // Directories still open, innermost last
+	err  error
+
+	// The latest entry is held back, so a directory whose contents then
+	// can't be read is marked with a read error
+	pending      *Entry
+	pendingPath  string
+	pendingIsDir bool
+}
+
+// NewWriter starts an export on w, recording the program that made it
+func NewWriter(w io.Writer, progname, progver string, at time.Time) *Writer {
+	writer := &Writer{out: bufio.NewWriter(w)}
+
+	header, err := json.Marshal(map[string]any{
+		"progname":  progname,
+		"progver":   progver,
+		"timestamp": at.Unix(),
+	})
+	if err != nil {
+		writer.err = err
+		return writer
+	}
+
+	writer.write("[1,2,")
+	writer.write(string(header))
+
+	return writer
+}
+
+// Add writes the entry at path. The first entry added is the root and is
+// named by its full path; the rest are named by their last element. Adding
+// the latest path again only marks it with the new entry's read error.
+func (w *Writer) Add(path string, isDir bool, entry Entry) {
+	if w.pending != nil && path == w.pendingPath {
+		w.pending.ReadError = w.pending.ReadError || entry.ReadError
+		return
+	}
+
+	w.flush()
+
+	w.pending, w.pendingPath, w.pendingIsDir = &entry, path, isDir
+}
+
+// Close ends the export and flushes it, returning the first error writing it
+func (w *Writer) Close() error {
+	w.flush()
+	w.write(strings.Repeat("]", len(w.dirs)) + "]\n")
+	w.dirs = nil
+
+	if w.err == nil {
+		w.err = w.out.Flush()
+	}
+
+	return w.err
+}
+
+// flush writes the pending entry, first closing the directories it isn't in
+func (w *Writer) flush() {
+	entry, path := w.pending, w.pendingPath
+	w.pending = nil
+
+	if entry == nil || w.err != nil {
+		return
+	}
+
+	root := len(w.dirs) == 0
+
+	for len(w.dirs) > 0 && !within(w.dirs[len(w.dirs)-1], path) {
+		w.write("]")
+		w.dirs = w.dirs[:len(w.dirs)-1]
+	}
+
+	if root {
+		entry.Name = path
+	} else {
+		entry.Name = filepath.Base(path)
+	}
+
+	record, err := json.Marshal(entry)
+	if err != nil {
+		w.err = err
+		return
+	}
+
+	w.write(",")
+
+	if w.pendingIsDir {
+		w.write("[")
+		w.dirs = append(w.dirs, path)
+	}
+
+	w.write(string(record))
+}
+
+func (w *Writer) write(s string) {
+	if w.err == nil {
+		_, w.err = w.out.WriteString(s)
+	}
+}
+
+// within reports whether path is below dir
+func within(dir, path string) bool {
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+
+	return strings.HasPrefix(path, dir)
+}
@@ -0,0 +1,65 @@
+package ncdu
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewWriter(&buf, "filesystem-exporter", "test", time.Unix(1700000000, 0))
+	w.Add("/data", true, Entry{Asize: 4096, Dsize: 4096, Dev: 1})
+	w.Add("/data/a", true, Entry{Asize: 4096, Dsize: 4096})
+	w.Add("/data/a/file", false, Entry{Asize: 100, Dsize: 4096})
+	w.Add("/data/locked", true, Entry{Asize: 4096, Dsize: 4096})
+	w.Add("/data/locked", true, Entry{ReadError: true})
+	w.Add("/data/top", false, Entry{Asize: 10, Dsize: 4096})
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var export []any
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	names := func(v any) any {
+		switch node := v.(type) {
+		case map[string]any:
+			return node["name"]
+		case []any:
+			return node[0].(map[string]any)["name"]
+		}
+
+		return nil
+	}
+
+	if len(export) != 4 || export[0] != 1.0 || export[1] != 2.0 {
+		t.Fatalf("unexpected export layout: %s", buf.String())
+	}
+
+	root := export[3].([]any)
+
+	var children []any
+	for _, child := range root[1:] {
+		children = append(children, names(child))
+	}
+
+	if expected := []any{"a", "locked", "top"}; names(root) != "/data" || !reflect.DeepEqual(children, expected) {
+		t.Errorf("expected /data holding %v, got %v holding %v", expected, names(root), children)
+	}
+
+	if file := root[1].([]any)[1]; names(file) != "file" {
+		t.Errorf("expected file inside a, got %v", file)
+	}
+
+	locked := root[2].([]any)[0].(map[string]any)
+	if locked["read_error"] != true || locked["asize"] != 4096.0 {
+		t.Errorf("expected locked to keep its size and be marked unreadable, got %v", locked)
+	}
+}
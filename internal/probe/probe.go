@@ -0,0 +1,288 @@
+// Package probe serves GET /probe, which measures an ad-hoc path on each
+// scrape in the style of the snmp and blackbox exporters
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/walk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// timeoutOffset is left of Prometheus' scrape timeout for writing the
+// response, as the blackbox exporter does
+const timeoutOffset = 500 * time.Millisecond
+
+// errNotAllowed means a target is outside probe.allowed_paths
+var errNotAllowed = errors.New("target is not in probe.allowed_paths")
+
+// Handler runs one bounded collection of the requested target per request
+// and responds with only that collection's metrics
+type Handler struct {
+	config  *config.Config
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+	slots   chan struct{} // Limits concurrent probes to probe.max_concurrent
+}
+
+// NewHandler creates a new probe handler. tracer may be nil.
+func NewHandler(cfg *config.Config, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Handler {
+	return &Handler{
+		config:  cfg,
+		metrics: m,
+		tracer:  tracer,
+		slots:   make(chan struct{}, cfg.Probe.MaxConcurrent),
+	}
+}
+
+// ServeHTTP probes ?target= with ?module= (directory by default). A failed
+// probe still answers 200 with probe_success 0; a target that is missing or
+// outside the allowlist gets a 4xx so it shows up as a failed scrape. A W3C
+// traceparent header makes the probe's span a child of the caller's trace.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		module = config.ProbeModuleDirectory
+	}
+
+	ctx, span := h.startSpan(r.Context(), r, "probe", trace.WithAttributes(
+		attribute.String("probe.module", module),
+		attribute.String("probe.target", r.URL.Query().Get("target")),
+	))
+	defer span.End()
+
+	r = r.WithContext(ctx)
+
+	if module != config.ProbeModuleDirectory && module != config.ProbeModuleFilesystem {
+		http.Error(w, fmt.Sprintf("unknown module %q (must be directory or filesystem)", module), http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.resolve(r.URL.Query().Get("target"))
+	if err != nil {
+		h.metrics.ProbesCounter.WithLabelValues(module, "rejected").Inc()
+
+		status := http.StatusBadRequest
+
+		switch {
+		case errors.Is(err, errNotAllowed):
+			status = http.StatusForbidden
+		case errors.Is(err, fs.ErrNotExist):
+			status = http.StatusNotFound
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout(r))
+	defer cancel()
+
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	case <-ctx.Done():
+		h.metrics.ProbesCounter.WithLabelValues(module, "rejected").Inc()
+		http.Error(w, "timed out waiting for other probes to finish", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+
+	successGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe succeeded",
+	})
+	durationGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "How long the probe took",
+	})
+
+	registry.MustRegister(successGauge, durationGauge)
+
+	start := time.Now()
+
+	if module == config.ProbeModuleFilesystem {
+		err = probeFilesystem(registry, target)
+	} else {
+		err = probeDirectory(ctx, registry, target)
+	}
+
+	durationGauge.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		h.metrics.ProbesCounter.WithLabelValues(module, "failure").Inc()
+		slog.Warn("Probe failed", "module", module, "target", target, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		successGauge.Set(1)
+		h.metrics.ProbesCounter.WithLabelValues(module, "success").Inc()
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// startSpan starts the probe's span, continuing the trace in the request's
+// traceparent header if it has one
+func (h *Handler) startSpan(ctx context.Context, r *http.Request, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if h.tracer == nil || !h.tracer.IsEnabled() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+
+	return h.tracer.StartSpan(ctx, name, append(opts, trace.WithSpanKind(trace.SpanKindServer))...)
+}
+
+// resolve checks a target against the allowlist. It's checked as given first,
+// so a target outside the allowlist is refused without touching the
+// filesystem, then again with symlinks resolved so they can't lead out of an
+// allowed path, which means the target must exist.
+func (h *Handler) resolve(target string) (string, error) {
+	if target == "" {
+		return "", errors.New("target parameter is missing")
+	}
+
+	if !filepath.IsAbs(target) {
+		return "", fmt.Errorf("target must be an absolute path, got %q", target)
+	}
+
+	if !slices.ContainsFunc(h.config.Probe.AllowedPaths, func(allowed string) bool {
+		return within(filepath.Clean(allowed), filepath.Clean(target))
+	}) {
+		return "", errNotAllowed
+	}
+
+	path, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", err
+	}
+
+	// Allowed paths are checked against the allowed roots as configured, so
+	// one that is a link could still lead out of them
+	if config.ValidateResolvedPath(path, h.config.Security.AllowedRoots) != nil {
+		return "", errNotAllowed
+	}
+
+	for _, allowed := range h.config.Probe.AllowedPaths {
+		if resolved, err := filepath.EvalSymlinks(allowed); err == nil {
+			allowed = resolved
+		}
+
+		if within(allowed, path) {
+			return path, nil
+		}
+	}
+
+	return "", errNotAllowed
+}
+
+// timeout is probe.timeout, lowered to fit within Prometheus' scrape timeout
+func (h *Handler) timeout(r *http.Request) time.Duration {
+	timeout := h.config.Probe.Timeout.Duration
+
+	if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil {
+			scrapeTimeout := time.Duration(seconds*float64(time.Second)) - timeoutOffset
+			if scrapeTimeout > 0 && scrapeTimeout < timeout {
+				timeout = scrapeTimeout
+			}
+		}
+	}
+
+	return timeout
+}
+
+// probeDirectory walks the target without crossing filesystems
+func probeDirectory(ctx context.Context, registry *prometheus.Registry, target string) error {
+	result, err := walk.Walk(ctx, target, walk.Options{})
+	if err != nil {
+		return err
+	}
+
+	sizeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_directory_size_bytes",
+		Help: "Disk usage of the target directory's subtree",
+	})
+	lastModifiedGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_directory_last_modified_timestamp_seconds",
+		Help: "Newest modification time anywhere in the target directory's subtree",
+	})
+	errorsGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_directory_unreadable_entries",
+		Help: "Entries that couldn't be read and were left out of the size",
+	})
+
+	registry.MustRegister(sizeGauge, lastModifiedGauge, errorsGauge)
+
+	sizeGauge.Set(float64(result.Directories[target]))
+	lastModifiedGauge.Set(float64(result.LastModified[target].Unix()))
+	errorsGauge.Set(float64(result.Errors))
+
+	return nil
+}
+
+// probeFilesystem reports the capacity of the filesystem containing target
+func probeFilesystem(registry *prometheus.Registry, target string) error {
+	usage, err := fsstat.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	if usage.Size <= 0 {
+		return fmt.Errorf("filesystem containing %s reports a size of %d", target, usage.Size)
+	}
+
+	sizeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_filesystem_size_bytes",
+		Help: "Total size of the filesystem containing the target",
+	})
+	availableGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_filesystem_available_bytes",
+		Help: "Space available to unprivileged users on the filesystem containing the target",
+	})
+	usedRatioGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_filesystem_used_ratio",
+		Help: "Ratio of used to total space on the filesystem containing the target",
+	})
+
+	registry.MustRegister(sizeGauge, availableGauge, usedRatioGauge)
+
+	sizeGauge.Set(float64(usage.Size))
+	availableGauge.Set(float64(usage.Available))
+	usedRatioGauge.Set(float64(usage.Size-usage.Available) / float64(usage.Size))
+
+	return nil
+}
+
+// within reports whether path is dir or below it
+func within(dir, path string) bool {
+	if path == dir || dir == string(filepath.Separator) {
+		return true
+	}
+
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,140 @@
+package probe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+func newTestHandler(t *testing.T, allowed ...string) *Handler {
+	t.Helper()
+
+	cfg := &config.Config{Probe: config.ProbeConfig{
+		Enabled:       true,
+		AllowedPaths:  allowed,
+		Timeout:       config.Duration{Duration: 30 * time.Second},
+		MaxConcurrent: 1,
+	}}
+
+	return NewHandler(cfg, metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info")), nil)
+}
+
+func probe(h *Handler, module, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/probe?"+url.Values{"module": {module}, "target": {target}}.Encode(), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestProbe(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+
+	target := filepath.Join(allowed, "project")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(target, "data"), make([]byte, 64<<10), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandler(t, allowed)
+
+	rec := probe(h, "", target)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, expected := range []string{"probe_success 1", "probe_directory_size_bytes ", "probe_directory_unreadable_entries 0"} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, rec.Body)
+		}
+	}
+
+	if strings.Contains(rec.Body.String(), "filesystem_exporter_") {
+		t.Errorf("expected only the probe's metrics, got:\n%s", rec.Body)
+	}
+
+	if rec := probe(h, "filesystem", target); !strings.Contains(rec.Body.String(), "probe_filesystem_used_ratio") {
+		t.Errorf("expected filesystem metrics, got %d:\n%s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		module, target string
+		expected       int
+	}{
+		{"directory", outside, http.StatusForbidden},
+		{"directory", filepath.Join(allowed, "escape"), http.StatusForbidden},
+		{"directory", filepath.Join(allowed, "..", filepath.Base(outside)), http.StatusForbidden},
+		{"directory", filepath.Join(allowed, "missing"), http.StatusNotFound},
+		{"directory", filepath.Join(outside, "missing"), http.StatusForbidden},
+		{"directory", "relative", http.StatusBadRequest},
+		{"snmp", target, http.StatusBadRequest},
+	} {
+		if rec := probe(h, tt.module, tt.target); rec.Code != tt.expected {
+			t.Errorf("%s %s: expected %d, got %d", tt.module, tt.target, tt.expected, rec.Code)
+		}
+	}
+
+	// A file target fails the probe rather than the scrape
+	if rec := probe(h, "directory", filepath.Join(target, "data")); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "probe_success 0") {
+		t.Errorf("expected a failed probe for a file, got %d:\n%s", rec.Code, rec.Body)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := newTestHandler(t, "/")
+
+	req := httptest.NewRequest(http.MethodGet, "/probe", nil)
+	if timeout := h.timeout(req); timeout != 30*time.Second {
+		t.Errorf("expected probe.timeout without a header, got %s", timeout)
+	}
+
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+
+	if timeout := h.timeout(req); timeout != 9500*time.Millisecond {
+		t.Errorf("expected the scrape timeout less the offset, got %s", timeout)
+	}
+}
+
+func TestProbe_AllowedRoots(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	inside := filepath.Join(root, "projects")
+	if err := os.MkdirAll(inside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// An allowed path within the root that leads out of it
+	link := filepath.Join(root, "elsewhere")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandler(t, inside, link)
+	h.config.Security.AllowedRoots = []string{root}
+
+	if rec := probe(h, "directory", inside); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 within the root, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := probe(h, "directory", link); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 through a link out of the root, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,35 @@
+// Package pushgateway sends the results of one-shot runs to a Prometheus
+// Pushgateway, for scans run on a schedule Prometheus doesn't control
+package pushgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"filesystem-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Push replaces every metric previously pushed under the configured job and
+// grouping labels with the current contents of gatherer
+func Push(ctx context.Context, cfg config.PushgatewayConfig, gatherer prometheus.Gatherer) error {
+	pusher := push.New(cfg.URL, cfg.Job).
+		Gatherer(gatherer).
+		Client(&http.Client{Timeout: cfg.Timeout.Duration})
+
+	for name, value := range cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	if cfg.Username != "" {
+		pusher = pusher.BasicAuth(cfg.Username, cfg.Password)
+	}
+
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", cfg.URL, err)
+	}
+
+	return nil
+}
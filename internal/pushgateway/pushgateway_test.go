@@ -0,0 +1,61 @@
+package pushgateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPush(t *testing.T) {
+	var method, path, user, body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		user, _, _ = r.BasicAuth()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "filesystem_exporter_test_bytes", Help: "Test"})
+	gauge.Set(42)
+	registry.MustRegister(gauge)
+
+	cfg := config.PushgatewayConfig{
+		URL:      server.URL,
+		Job:      "filesystem_exporter",
+		Grouping: map[string]string{"instance": "nas"},
+		Username: "cron",
+		Password: "secret",
+		Timeout:  config.Duration{Duration: 5 * time.Second},
+	}
+
+	if err := Push(context.Background(), cfg, registry); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("expected PUT to replace the group, got %s", method)
+	}
+
+	if path != "/metrics/job/filesystem_exporter/instance/nas" {
+		t.Errorf("unexpected path %s", path)
+	}
+
+	if user != "cron" {
+		t.Errorf("expected basic auth user cron, got %q", user)
+	}
+
+	if !strings.Contains(body, "filesystem_exporter_test_bytes") {
+		t.Errorf("expected the gauge in the pushed body")
+	}
+}
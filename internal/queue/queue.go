@@ -1,11 +1,16 @@
 package queue
 
 import (
+	"container/heap"
 	"context"
+	"errors"
+	"sync"
 	"time"
 
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
 	"filesystem-exporter/internal/state"
-	"github.com/d0ugal/promexporter/tracing"
+	"filesystem-exporter/internal/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -19,29 +24,93 @@ type Job struct {
 	Path      string
 	Timeout   time.Duration
 	Interval  time.Duration
+	Priority  int // Higher priority jobs are taken first; equal ones in the order they were queued
 	CreatedAt time.Time
 	Context   context.Context // Context with trace span
+	Done      chan struct{}   // Closed by the worker once the job is over, whatever the outcome; nil if nobody waits
 }
 
-// Queue represents a job queue
+// Finish signals that the job is over by closing Done. Workers call it once
+// per job they take off the queue.
+func (j Job) Finish() {
+	if j.Done != nil {
+		close(j.Done)
+	}
+}
+
+// Reasons a job can be dropped instead of queued
+const (
+	DropReasonCancelled = "cancelled" // Context cancelled while blocked on a full queue
+	DropReasonFull      = "full"      // New job dropped from a full queue (drop_newest)
+	DropReasonEvicted   = "evicted"   // Oldest job dropped to make room for a new one (drop_oldest)
+	DropReasonCoalesced = "coalesced" // New job dropped from a full queue already holding one for the item (coalesce)
+)
+
+// ErrDropped is returned by Enqueue when the overflow policy dropped the job
+var ErrDropped = errors.New("job dropped from full queue")
+
+// Queue is a bounded priority queue of jobs
 type Queue struct {
-	jobs   chan Job
-	state  *state.Tracker
-	tracer *tracing.Tracer
-	name   string // "filesystem" or "directory"
+	policy  string // What Enqueue does when the queue is full, one of config.QueueOverflow*
+	state   *state.Tracker
+	metrics *metrics.FilesystemRegistry
+	tracer  *tracing.Tracer
+	name    string // "filesystem" or "directory"
+
+	mu       sync.Mutex
+	jobs     jobHeap
+	capacity int
+	seq      uint64        // Order jobs were queued in, to keep equal priorities first in, first out
+	changed  chan struct{} // Closed and replaced whenever a job is added or taken, waking blocked callers
+}
+
+// queuedJob is a job waiting in the queue
+type queuedJob struct {
+	job Job
+	seq uint64
+}
+
+// jobHeap orders waiting jobs by priority, then by when they were queued
+type jobHeap []queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x any) { *h = append(*h, x.(queuedJob)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return last
 }
 
-// NewQueue creates a new queue
-func NewQueue(name string, bufferSize int, stateTracker *state.Tracker, tracer *tracing.Tracer) *Queue {
+// NewQueue creates a new queue holding up to bufferSize jobs, with one of the
+// config.QueueOverflow* policies for when it's full
+func NewQueue(name string, bufferSize int, policy string, stateTracker *state.Tracker, m *metrics.FilesystemRegistry, tracer *tracing.Tracer) *Queue {
 	return &Queue{
-		jobs:   make(chan Job, bufferSize),
-		state:  stateTracker,
-		tracer: tracer,
-		name:   name,
+		policy:   policy,
+		state:    stateTracker,
+		metrics:  m,
+		tracer:   tracer,
+		name:     name,
+		capacity: max(bufferSize, 1),
+		changed:  make(chan struct{}),
 	}
 }
 
-// Enqueue adds a job to the queue
+// Enqueue adds a job to the queue. When the queue is full it blocks, or with
+// a dropping overflow policy returns ErrDropped or evicts the oldest job.
 func (q *Queue) Enqueue(ctx context.Context, job Job) error {
 	ctx, span := q.startSpan(ctx, "queue.enqueue", trace.WithAttributes(
 		attribute.String("queue.name", q.name),
@@ -49,6 +118,7 @@ func (q *Queue) Enqueue(ctx context.Context, job Job) error {
 		attribute.String("job.type", job.Type),
 		attribute.String("job.name", job.Name),
 		attribute.String("job.path", job.Path),
+		attribute.Int("job.priority", job.Priority),
 		attribute.Float64("job.timeout_seconds", job.Timeout.Seconds()),
 		attribute.Float64("job.interval_seconds", job.Interval.Seconds()),
 	))
@@ -59,29 +129,129 @@ func (q *Queue) Enqueue(ctx context.Context, job Job) error {
 	job.Context = ctx
 	job.CreatedAt = time.Now()
 
-	select {
-	case q.jobs <- job:
-		// Update queue depth
-		depth := len(q.jobs)
-		q.state.SetQueueDepth(ctx, q.name, depth)
-
-		span.SetAttributes(
-			attribute.Int("queue.depth_after", depth),
-			attribute.Float64("queue.wait_time_seconds", 0),
-		)
-		span.AddEvent("job_queued")
-
-		return nil
-	case <-ctx.Done():
-		err := ctx.Err()
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "context cancelled")
-
-		return err
+	q.mu.Lock()
+
+	for len(q.jobs) >= q.capacity {
+		switch q.policy {
+		case config.QueueOverflowDropNewest:
+			q.mu.Unlock()
+			span.SetAttributes(attribute.Bool("queue.dropped", true))
+
+			return q.drop(job, DropReasonFull)
+
+		case config.QueueOverflowCoalesce:
+			if q.waiting(job) {
+				q.mu.Unlock()
+				span.SetAttributes(attribute.Bool("queue.dropped", true))
+
+				return q.drop(job, DropReasonCoalesced)
+			}
+
+		case config.QueueOverflowDropOldest:
+			q.evictOldest()
+			continue
+		}
+
+		// Wait for a worker to take a job
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+			q.mu.Lock()
+		case <-ctx.Done():
+			err := ctx.Err()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "context cancelled")
+
+			q.metrics.QueueEnqueueWaitSeconds.WithLabelValues(q.name).Observe(time.Since(job.CreatedAt).Seconds())
+			q.metrics.QueueDroppedCounter.WithLabelValues(q.name, DropReasonCancelled).Inc()
+
+			return err
+		}
 	}
+
+	q.seq++
+	heap.Push(&q.jobs, queuedJob{job: job, seq: q.seq})
+	q.notify()
+
+	depth := len(q.jobs)
+
+	q.mu.Unlock()
+
+	q.state.SetQueueDepth(ctx, q.name, depth)
+	q.state.RecordQueued(ctx, q.name, job.ID, job.Name)
+
+	waitDuration := time.Since(job.CreatedAt)
+	q.metrics.QueueEnqueueWaitSeconds.WithLabelValues(q.name).Observe(waitDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Int("queue.depth_after", depth),
+		attribute.Float64("queue.wait_time_seconds", waitDuration.Seconds()),
+	)
+	span.AddEvent("job_queued")
+
+	return nil
+}
+
+// Waiting reports whether a job for the item is in the queue, not yet taken
+// by a worker
+func (q *Queue) Waiting(itemType, name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.waiting(Job{Type: itemType, Name: name})
 }
 
-// Dequeue removes and returns a job from the queue
+// waiting reports whether a job for the same item is in the queue (caller
+// must hold lock)
+func (q *Queue) waiting(job Job) bool {
+	for _, queued := range q.jobs {
+		if queued.job.Type == job.Type && queued.job.Name == job.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evictOldest drops the job that has waited longest, whatever its priority
+// (caller must hold lock)
+func (q *Queue) evictOldest() {
+	oldest := 0
+	for i := range q.jobs {
+		if q.jobs[i].seq < q.jobs[oldest].seq {
+			oldest = i
+		}
+	}
+
+	evicted := heap.Remove(&q.jobs, oldest).(queuedJob).job
+	q.metrics.QueueDroppedCounter.WithLabelValues(q.name, DropReasonEvicted).Inc()
+
+	if evictedSpan := trace.SpanFromContext(evicted.Context); evictedSpan.IsRecording() {
+		evictedSpan.AddEvent("job_evicted")
+	}
+
+	// Its waiter in the scheduler treats it as over
+	evicted.Finish()
+}
+
+// notify wakes callers blocked on the queue changing (caller must hold lock)
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// drop counts a job the overflow policy turned away
+func (q *Queue) drop(job Job, reason string) error {
+	q.metrics.QueueDroppedCounter.WithLabelValues(q.name, reason).Inc()
+	job.Finish()
+
+	return ErrDropped
+}
+
+// Dequeue removes and returns the highest priority job, waiting for one if
+// the queue is empty
 func (q *Queue) Dequeue(ctx context.Context) (Job, error) {
 	ctx, span := q.startSpan(ctx, "queue.dequeue", trace.WithAttributes(
 		attribute.String("queue.name", q.name),
@@ -90,31 +260,67 @@ func (q *Queue) Dequeue(ctx context.Context) (Job, error) {
 
 	waitStart := time.Now()
 
-	select {
-	case job := <-q.jobs:
-		waitDuration := time.Since(waitStart)
-
-		// Update queue depth
-		depth := len(q.jobs)
-		q.state.SetQueueDepth(ctx, q.name, depth)
-
-		span.SetAttributes(
-			attribute.String("job.id", job.ID),
-			attribute.String("job.type", job.Type),
-			attribute.String("job.name", job.Name),
-			attribute.Float64("queue.wait_time_seconds", waitDuration.Seconds()),
-			attribute.Int("queue.depth_after", depth),
-		)
-		span.AddEvent("job_dequeued")
-
-		return job, nil
-	case <-ctx.Done():
-		err := ctx.Err()
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "context cancelled")
-
-		return Job{}, err
+	q.mu.Lock()
+
+	for len(q.jobs) == 0 {
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+			q.mu.Lock()
+		case <-ctx.Done():
+			err := ctx.Err()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "context cancelled")
+
+			return Job{}, err
+		}
 	}
+
+	job := heap.Pop(&q.jobs).(queuedJob).job
+	q.notify()
+
+	depth := len(q.jobs)
+
+	q.mu.Unlock()
+
+	waitDuration := time.Since(waitStart)
+	q.metrics.QueueDequeueIdleSeconds.WithLabelValues(q.name).Observe(waitDuration.Seconds())
+
+	// Update queue depth
+	q.state.SetQueueDepth(ctx, q.name, depth)
+
+	span.SetAttributes(
+		attribute.String("job.id", job.ID),
+		attribute.String("job.type", job.Type),
+		attribute.String("job.name", job.Name),
+		attribute.Int("job.priority", job.Priority),
+		attribute.Float64("queue.wait_time_seconds", waitDuration.Seconds()),
+		attribute.Int("queue.depth_after", depth),
+	)
+	span.AddEvent("job_dequeued")
+
+	return job, nil
+}
+
+// Discard empties the queue, finishing the jobs that were waiting, and
+// returns how many there were. It is used on shutdown, once the workers have
+// stopped taking jobs.
+func (q *Queue) Discard(ctx context.Context) int {
+	q.mu.Lock()
+	discarded := q.jobs
+	q.jobs = nil
+	q.notify()
+	q.mu.Unlock()
+
+	q.state.SetQueueDepth(ctx, q.name, 0)
+
+	for _, queued := range discarded {
+		queued.job.Finish()
+	}
+
+	return len(discarded)
 }
 
 // Size returns the current queue size
@@ -124,17 +330,15 @@ func (q *Queue) Size(ctx context.Context) int {
 	))
 	defer span.End()
 
+	q.mu.Lock()
 	size := len(q.jobs)
+	q.mu.Unlock()
+
 	span.SetAttributes(attribute.Int("queue.size", size))
 
 	return size
 }
 
-// Channel returns the underlying channel (for use in select statements)
-func (q *Queue) Channel() <-chan Job {
-	return q.jobs
-}
-
 // startSpan is a helper to start an OTEL span
 func (q *Queue) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
 	if q.tracer != nil && q.tracer.IsEnabled() {
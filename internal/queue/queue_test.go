@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/state"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestQueue(t *testing.T, policy string) (*Queue, *metrics.FilesystemRegistry) {
+	t.Helper()
+
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	return NewQueue("directory", 2, policy, state.NewTracker(nil), m, nil), m
+}
+
+func TestEnqueue_OverflowPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		policy  string
+		next    string // Item enqueued once the queue is full
+		wantErr bool
+		reason  string
+		want    []string // Items left in the queue, oldest first
+	}{
+		{config.QueueOverflowDropNewest, "media", true, DropReasonFull, []string{"home", "srv"}},
+		{config.QueueOverflowDropOldest, "media", false, DropReasonEvicted, []string{"srv", "media"}},
+		{config.QueueOverflowCoalesce, "home", true, DropReasonCoalesced, []string{"home", "srv"}},
+	}
+
+	for _, tt := range tests {
+		q, m := newTestQueue(t, tt.policy)
+
+		first := Job{Type: "directory", Name: "home", Done: make(chan struct{})}
+		for _, job := range []Job{first, {Type: "directory", Name: "srv"}} {
+			if err := q.Enqueue(ctx, job); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+			}
+		}
+
+		err := q.Enqueue(ctx, Job{Type: "directory", Name: tt.next})
+		if gotErr := errors.Is(err, ErrDropped); gotErr != tt.wantErr {
+			t.Errorf("%s: expected dropped %v, got %v", tt.policy, tt.wantErr, err)
+		}
+
+		if got := testutil.ToFloat64(m.QueueDroppedCounter.WithLabelValues("directory", tt.reason)); got != 1 {
+			t.Errorf("%s: expected one job dropped as %s, got %v", tt.policy, tt.reason, got)
+		}
+
+		for _, want := range tt.want {
+			job, err := q.Dequeue(ctx)
+			if err != nil || job.Name != want {
+				t.Errorf("%s: expected %s next, got %s (%v)", tt.policy, want, job.Name, err)
+			}
+		}
+
+		if tt.policy == config.QueueOverflowDropOldest {
+			select {
+			case <-first.Done:
+			default:
+				t.Errorf("%s: expected the evicted job to be finished", tt.policy)
+			}
+		}
+	}
+}
+
+func TestDequeue_HighestPriorityFirst(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t, config.QueueOverflowBlock)
+
+	for _, job := range []Job{
+		{Type: "directory", Name: "archive", Priority: -1},
+		{Type: "directory", Name: "home"},
+		{Type: "directory", Name: "www", Priority: 10},
+	} {
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Make room; the queue holds two
+		if job.Name == "home" {
+			first, _ := q.Dequeue(ctx)
+			if first.Name != "home" {
+				t.Fatalf("expected home first, got %s", first.Name)
+			}
+		}
+	}
+
+	for _, want := range []string{"www", "archive"} {
+		if job, err := q.Dequeue(ctx); err != nil || job.Name != want {
+			t.Errorf("expected %s next, got %s (%v)", want, job.Name, err)
+		}
+	}
+}
+
+func TestWaiting(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t, config.QueueOverflowBlock)
+
+	if q.Waiting("directory", "home") {
+		t.Fatal("expected nothing waiting in an empty queue")
+	}
+
+	if err := q.Enqueue(ctx, Job{Type: "directory", Name: "home"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !q.Waiting("directory", "home") {
+		t.Error("expected home to be waiting")
+	}
+
+	if q.Waiting("directory", "www") {
+		t.Error("expected www not to be waiting")
+	}
+
+	if _, err := q.Dequeue(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if q.Waiting("directory", "home") {
+		t.Error("expected home not to be waiting once taken by a worker")
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t, config.QueueOverflowBlock)
+
+	job := Job{Type: "directory", Name: "home", Done: make(chan struct{})}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if discarded := q.Discard(ctx); discarded != 1 {
+		t.Errorf("expected 1 job discarded, got %d", discarded)
+	}
+
+	if size := q.Size(ctx); size != 0 {
+		t.Errorf("expected an empty queue, got %d jobs", size)
+	}
+
+	select {
+	case <-job.Done:
+	default:
+		t.Error("expected the discarded job to be finished")
+	}
+}
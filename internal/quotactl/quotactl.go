@@ -0,0 +1,161 @@
+package quotactl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filesystem-exporter/internal/utils"
+)
+
+// Kind selects which quota table to read
+type Kind int
+
+// Quota kinds, matching USRQUOTA, GRPQUOTA and PRJQUOTA
+const (
+	User Kind = iota
+	Group
+	Project
+)
+
+// String returns the config name of a quota kind
+func (k Kind) String() string {
+	switch k {
+	case User:
+		return "user"
+	case Group:
+		return "group"
+	case Project:
+		return "project"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Usage is the current usage and limits of one quota ID. Limits of 0 mean
+// no limit is set.
+type Usage struct {
+	ID              uint32
+	UsedBytes       uint64
+	SoftLimitBytes  uint64
+	HardLimitBytes  uint64
+	UsedInodes      uint64
+	SoftLimitInodes uint64
+	HardLimitInodes uint64
+}
+
+// DeviceForMount returns the block device mounted at mountPoint, as quotactl
+// needs the device rather than the mount point
+func DeviceForMount(mountPoint string) (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer f.Close()
+
+	return deviceFromMounts(f, mountPoint)
+}
+
+// DeviceForPath returns the block device of the filesystem containing path,
+// i.e. the one mounted at the longest mount point that is a prefix of path
+func DeviceForPath(path string) (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", fmt.Errorf("failed to read mount table: %w", err)
+	}
+	defer f.Close()
+
+	return deviceContaining(f, path)
+}
+
+// deviceContaining finds the device of the mount containing path in
+// /proc/mounts formatted input
+func deviceContaining(r io.Reader, path string) (string, error) {
+	path = filepath.Clean(path)
+	device, longest := "", -1
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		mountPoint := unescapeMountField(fields[1])
+
+		contains := path == mountPoint || mountPoint == "/" ||
+			strings.HasPrefix(path, mountPoint+string(filepath.Separator))
+		if contains && len(mountPoint) >= longest {
+			device, longest = unescapeMountField(fields[0]), len(mountPoint)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	if device == "" {
+		return "", fmt.Errorf("no mount found containing %s", path)
+	}
+
+	return device, nil
+}
+
+// deviceFromMounts finds the device for mountPoint in /proc/mounts formatted
+// input. The last matching entry wins since later mounts shadow earlier ones.
+func deviceFromMounts(r io.Reader, mountPoint string) (string, error) {
+	mountPoint = filepath.Clean(mountPoint)
+	device := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		if unescapeMountField(fields[1]) == mountPoint {
+			device = unescapeMountField(fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read mount table: %w", err)
+	}
+
+	if device == "" {
+		return "", fmt.Errorf("%s is %w", mountPoint, utils.ErrNotMounted)
+	}
+
+	return device, nil
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space etc.) used in
+// /proc/mounts
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			var value byte
+			if _, err := fmt.Sscanf(field[i+1:i+4], "%03o", &value); err == nil {
+				b.WriteByte(value)
+
+				i += 3
+
+				continue
+			}
+		}
+
+		b.WriteByte(field[i])
+	}
+
+	return b.String()
+}
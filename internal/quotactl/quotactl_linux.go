@@ -0,0 +1,159 @@
+//go:build linux
+
+package quotactl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// Q_GETQUOTA returns the quota of a single ID
+	qGetQuota = 0x800007
+	// Q_GETNEXTQUOTA returns the first ID >= the requested one that has a quota (Linux 4.6+)
+	qGetNextQuota = 0x800009
+	// QIF_DQBLKSIZE is the unit of the block limits in if_dqblk
+	qifDqblkSize = 1024
+	// FS_IOC_FSGETXATTR reads the extended attributes, including project ID, of an inode
+	fsIocFsGetXattr = 0x801c581f
+)
+
+// fsxattr mirrors struct fsxattr from <linux/fs.h>
+type fsxattr struct {
+	XFlags     uint32
+	ExtSize    uint32
+	NExtents   uint32
+	ProjID     uint32
+	CowExtSize uint32
+	Pad        [8]byte
+}
+
+// ifNextDqblk mirrors struct if_nextdqblk from <linux/quota.h>
+type ifNextDqblk struct {
+	BHardLimit uint64
+	BSoftLimit uint64
+	CurSpace   uint64
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+	ID         uint32
+}
+
+// ProjectID returns the project ID assigned to path, e.g. with
+// `xfs_quota -x -c 'project -s'`
+func ProjectID(path string) (uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var attr fsxattr
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocFsGetXattr, uintptr(unsafe.Pointer(&attr)))
+	if errno != 0 {
+		return 0, fmt.Errorf("failed to read project ID of %s: %w", path, errno)
+	}
+
+	return attr.ProjID, nil
+}
+
+// Get returns usage for a single quota ID of the given kind on device
+func Get(device string, kind Kind, id uint32) (Usage, error) {
+	devicePtr, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	// struct if_dqblk is struct if_nextdqblk without the trailing ID
+	var dq ifNextDqblk
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL,
+		qcmd(qGetQuota, kind),
+		uintptr(unsafe.Pointer(devicePtr)),
+		uintptr(id),
+		uintptr(unsafe.Pointer(&dq)),
+		0, 0)
+	if errno != 0 {
+		if errors.Is(errno, syscall.ESRCH) {
+			return Usage{}, fmt.Errorf("%s quotas are not enabled on %s", kind, device)
+		}
+
+		return Usage{}, fmt.Errorf("quotactl %s %d on %s failed: %w", kind, id, device, errno)
+	}
+
+	dq.ID = id
+
+	return usageOf(&dq), nil
+}
+
+// List returns usage for every ID with a quota entry of the given kind on
+// device. It requires quotas to be enabled on the filesystem and, for IDs
+// other than the caller's own, CAP_SYS_ADMIN.
+func List(device string, kind Kind) ([]Usage, error) {
+	devicePtr, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []Usage
+
+	for id := uint32(0); ; {
+		var dq ifNextDqblk
+
+		_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL,
+			qcmd(qGetNextQuota, kind),
+			uintptr(unsafe.Pointer(devicePtr)),
+			uintptr(id),
+			uintptr(unsafe.Pointer(&dq)),
+			0, 0)
+		if errno != 0 {
+			if errors.Is(errno, syscall.ENOENT) {
+				break
+			}
+
+			if errors.Is(errno, syscall.ESRCH) {
+				return nil, fmt.Errorf("%s quotas are not enabled on %s", kind, device)
+			}
+
+			return nil, fmt.Errorf("quotactl %s on %s failed: %w", kind, device, errno)
+		}
+
+		usages = append(usages, usageOf(&dq))
+
+		if dq.ID == math.MaxUint32 {
+			break
+		}
+
+		id = dq.ID + 1
+	}
+
+	return usages, nil
+}
+
+// qcmd builds a quotactl command like the QCMD macro. The command's top bit
+// is set, so it's built as a uint32 to keep from overflowing an int on 32-bit
+// architectures.
+func qcmd(cmd uint32, kind Kind) uintptr {
+	return uintptr(cmd<<8 | uint32(kind)&0xff)
+}
+
+// usageOf converts a kernel quota block into a Usage in bytes
+func usageOf(dq *ifNextDqblk) Usage {
+	return Usage{
+		ID:              dq.ID,
+		UsedBytes:       dq.CurSpace,
+		SoftLimitBytes:  dq.BSoftLimit * qifDqblkSize,
+		HardLimitBytes:  dq.BHardLimit * qifDqblkSize,
+		UsedInodes:      dq.CurInodes,
+		SoftLimitInodes: dq.ISoftLimit,
+		HardLimitInodes: dq.IHardLimit,
+	}
+}
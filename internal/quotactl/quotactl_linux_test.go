@@ -0,0 +1,50 @@
+//go:build linux
+
+package quotactl
+
+import "testing"
+
+func TestQcmd(t *testing.T) {
+	tests := []struct {
+		cmd      uint32
+		kind     Kind
+		expected uintptr
+	}{
+		{qGetQuota, User, 0x80000700},
+		{qGetNextQuota, Group, 0x80000901},
+		{qGetQuota, Project, 0x80000702},
+	}
+
+	for _, tt := range tests {
+		if got := qcmd(tt.cmd, tt.kind); got != tt.expected {
+			t.Errorf("qcmd(%#x, %s) = %#x, want %#x", tt.cmd, tt.kind, got, tt.expected)
+		}
+	}
+}
+
+func TestUsageOf(t *testing.T) {
+	usage := usageOf(&ifNextDqblk{
+		BHardLimit: 2048,
+		BSoftLimit: 1024,
+		CurSpace:   123456,
+		IHardLimit: 200,
+		ISoftLimit: 100,
+		CurInodes:  42,
+		ID:         1000,
+	})
+
+	expected := Usage{
+		ID:              1000,
+		UsedBytes:       123456,
+		SoftLimitBytes:  1024 * 1024,
+		HardLimitBytes:  2048 * 1024,
+		UsedInodes:      42,
+		SoftLimitInodes: 100,
+		HardLimitInodes: 200,
+	}
+
+	// Space is already in bytes, while block limits are in 1KiB units
+	if usage != expected {
+		t.Errorf("usageOf = %+v, want %+v", usage, expected)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package quotactl
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// List is not implemented on this platform
+func List(device string, kind Kind) ([]Usage, error) {
+	return nil, fmt.Errorf("quotactl is not supported on %s", runtime.GOOS)
+}
+
+// Get is not implemented on this platform
+func Get(device string, kind Kind, id uint32) (Usage, error) {
+	return Usage{}, fmt.Errorf("quotactl is not supported on %s", runtime.GOOS)
+}
+
+// ProjectID is not implemented on this platform
+func ProjectID(path string) (uint32, error) {
+	return 0, fmt.Errorf("project IDs are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,64 @@
+package quotactl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeviceFromMounts(t *testing.T) {
+	mounts := `sysfs /sys sysfs rw,nosuid 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+/dev/sda2 /home ext4 rw,relatime,usrquota,grpquota 0 0
+/dev/sdb1 /mnt/scratch\040space xfs rw,prjquota 0 0
+/dev/sdc1 /home ext4 rw,relatime 0 0
+`
+
+	tests := map[string]string{
+		"/":                  "/dev/sda1",
+		"/home/":             "/dev/sdc1", // later mounts shadow earlier ones
+		"/mnt/scratch space": "/dev/sdb1",
+	}
+
+	for mountPoint, expected := range tests {
+		device, err := deviceFromMounts(strings.NewReader(mounts), mountPoint)
+		if err != nil {
+			t.Errorf("deviceFromMounts(%s) returned error: %v", mountPoint, err)
+			continue
+		}
+
+		if device != expected {
+			t.Errorf("deviceFromMounts(%s) = %s, want %s", mountPoint, device, expected)
+		}
+	}
+
+	if _, err := deviceFromMounts(strings.NewReader(mounts), "/srv"); err == nil {
+		t.Error("expected error for a path that is not a mount point")
+	}
+}
+
+func TestDeviceContaining(t *testing.T) {
+	mounts := `/dev/sda1 / ext4 rw 0 0
+/dev/sdb1 /scratch xfs rw,prjquota 0 0
+/dev/sdc1 /scratch/fast xfs rw,prjquota 0 0
+`
+
+	tests := map[string]string{
+		"/scratch/projects/a": "/dev/sdb1",
+		"/scratch/fast/x":     "/dev/sdc1",
+		"/scratch":            "/dev/sdb1",
+		"/scratchpad":         "/dev/sda1",
+		"/etc":                "/dev/sda1",
+	}
+
+	for path, expected := range tests {
+		device, err := deviceContaining(strings.NewReader(mounts), path)
+		if err != nil {
+			t.Errorf("deviceContaining(%s) returned error: %v", path, err)
+			continue
+		}
+
+		if device != expected {
+			t.Errorf("deviceContaining(%s) = %s, want %s", path, device, expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Report is a point-in-time summary of the most recent scan results
+type Report struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Volumes     []Volume    `json:"volumes"`
+	Directories []Directory `json:"directories"`
+}
+
+// Volume is the last measured usage of a configured filesystem
+type Volume struct {
+	Name           string  `json:"name"`
+	MountPoint     string  `json:"mount_point"`
+	Device         string  `json:"device"`
+	SizeBytes      float64 `json:"size_bytes"`
+	AvailableBytes float64 `json:"available_bytes"`
+	UsedRatio      float64 `json:"used_ratio"`
+}
+
+// Directory is the last measured size of a directory series
+type Directory struct {
+	Group             string  `json:"group"`
+	Path              string  `json:"path"`
+	Mode              string  `json:"mode"`
+	SizeMode          string  `json:"size_mode"`
+	SubdirectoryLevel string  `json:"subdirectory_level"`
+	SizeBytes         float64 `json:"size_bytes"`
+}
+
+// Build assembles a report from the current values of the exporter's gauges
+func Build(gatherer prometheus.Gatherer) (*Report, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	volumes := make(map[string]*Volume)
+	report := &Report{
+		GeneratedAt: time.Now().UTC(),
+		Volumes:     []Volume{},
+		Directories: []Directory{},
+	}
+
+	for _, family := range families {
+		switch family.GetName() {
+		case "filesystem_exporter_volume_size_bytes":
+			for _, m := range family.GetMetric() {
+				volumeFor(volumes, m).SizeBytes = m.GetGauge().GetValue()
+			}
+		case "filesystem_exporter_volume_available_bytes":
+			for _, m := range family.GetMetric() {
+				volumeFor(volumes, m).AvailableBytes = m.GetGauge().GetValue()
+			}
+		case "filesystem_exporter_volume_used_ratio":
+			for _, m := range family.GetMetric() {
+				volumeFor(volumes, m).UsedRatio = m.GetGauge().GetValue()
+			}
+		case "filesystem_exporter_directory_size_bytes":
+			for _, m := range family.GetMetric() {
+				labels := labelMap(m)
+				report.Directories = append(report.Directories, Directory{
+					Group:             labels["group"],
+					Path:              labels["directory"],
+					Mode:              labels["mode"],
+					SizeMode:          labels["size_mode"],
+					SubdirectoryLevel: labels["subdirectory_level"],
+					SizeBytes:         m.GetGauge().GetValue(),
+				})
+			}
+		}
+	}
+
+	for _, volume := range volumes {
+		report.Volumes = append(report.Volumes, *volume)
+	}
+
+	sort.Slice(report.Volumes, func(i, j int) bool {
+		return report.Volumes[i].Name < report.Volumes[j].Name
+	})
+
+	sort.Slice(report.Directories, func(i, j int) bool {
+		if report.Directories[i].Group != report.Directories[j].Group {
+			return report.Directories[i].Group < report.Directories[j].Group
+		}
+
+		return report.Directories[i].Path < report.Directories[j].Path
+	})
+
+	return report, nil
+}
+
+// volumeFor returns the volume entry for a metric, creating it if needed
+func volumeFor(volumes map[string]*Volume, m *dto.Metric) *Volume {
+	labels := labelMap(m)
+	key := labels["volume"] + "\x00" + labels["mount_point"]
+
+	if volume, exists := volumes[key]; exists {
+		return volume
+	}
+
+	volume := &Volume{
+		Name:       labels["volume"],
+		MountPoint: labels["mount_point"],
+		Device:     labels["device"],
+	}
+	volumes[key] = volume
+
+	return volume
+}
+
+// labelMap converts a metric's label pairs into a map
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, pair := range m.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+
+	return labels
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SignatureAlgorithm identifies the signature scheme used in envelopes
+const SignatureAlgorithm = "ed25519"
+
+// Envelope wraps a JSON payload with a detached signature over its exact bytes.
+// Verifiers must check the signature against Payload as transmitted, before
+// re-encoding it.
+type Envelope struct {
+	Payload   json.RawMessage `json:"payload"`
+	Algorithm string          `json:"algorithm"`
+	KeyID     string          `json:"key_id"`
+	Signature []byte          `json:"signature"` // base64 encoded in JSON
+}
+
+// Signer signs JSON payloads with an Ed25519 private key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// LoadSigner reads a PEM encoded PKCS#8 Ed25519 private key, as produced by
+// `openssl genpkey -algorithm ed25519`. When keyID is empty it defaults to a
+// fingerprint of the public key.
+func LoadSigner(keyFile, keyID string) (*Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", keyFile, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", keyFile)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", keyFile, err)
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be an Ed25519 private key")
+	}
+
+	return NewSigner(key, keyID), nil
+}
+
+// NewSigner creates a signer from an in-memory key
+func NewSigner(key ed25519.PrivateKey, keyID string) *Signer {
+	if keyID == "" {
+		keyID = Fingerprint(key.Public().(ed25519.PublicKey))
+	}
+
+	return &Signer{key: key, keyID: keyID}
+}
+
+// Fingerprint returns a short stable identifier for a public key
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyID returns the identifier included in signed envelopes
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Seal marshals v and wraps it in a signed envelope
+func (s *Signer) Seal(v any) (*Envelope, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return &Envelope{
+		Payload:   payload,
+		Algorithm: SignatureAlgorithm,
+		KeyID:     s.keyID,
+		Signature: ed25519.Sign(s.key, payload),
+	}, nil
+}
+
+// Verify checks an envelope's signature with the given public key
+func Verify(pub ed25519.PublicKey, envelope *Envelope) bool {
+	if envelope.Algorithm != SignatureAlgorithm {
+		return false
+	}
+
+	return ed25519.Verify(pub, envelope.Payload, envelope.Signature)
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSigner_SealAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	signer := NewSigner(priv, "")
+	if signer.KeyID() != Fingerprint(pub) {
+		t.Errorf("expected default key ID to be the fingerprint, got %s", signer.KeyID())
+	}
+
+	envelope, err := signer.Seal(&Report{Volumes: []Volume{{Name: "root", SizeBytes: 100}}})
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	// Round-trip through JSON as a downstream consumer would
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+
+	var received Envelope
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("failed to unmarshal envelope: %v", err)
+	}
+
+	if !Verify(pub, &received) {
+		t.Fatal("expected signature to verify")
+	}
+
+	received.Payload = json.RawMessage(`{"volumes":[]}`)
+	if Verify(pub, &received) {
+		t.Fatal("expected tampered payload to fail verification")
+	}
+}
+
+func TestLoadSigner_PKCS8PEM(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	signer, err := LoadSigner(path, "prod-2026")
+	if err != nil {
+		t.Fatalf("LoadSigner failed: %v", err)
+	}
+
+	if signer.KeyID() != "prod-2026" {
+		t.Errorf("expected configured key ID, got %s", signer.KeyID())
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// itemKey identifies a scheduled filesystem or directory group
+type itemKey struct {
+	itemType string
+	name     string
+}
+
+// setInterval records the configured interval an item's ticker started with
+func (s *Scheduler) setInterval(itemType, name string, interval time.Duration) {
+	key := itemKey{itemType: itemType, name: name}
+
+	s.intervalMutex.Lock()
+	s.baseIntervals[key] = interval
+	s.effectiveIntervals[key] = interval
+	s.lastTicks[key] = time.Now()
+	s.intervalMutex.Unlock()
+
+	s.metrics.EffectiveIntervalGauge.With(prometheus.Labels{
+		"group": name,
+		"type":  itemType,
+	}).Set(interval.Seconds())
+}
+
+// adaptInterval re-times an item's ticker after a collection, from how long
+// the collection took, when adaptive_interval is enabled
+func (s *Scheduler) adaptInterval(ctx context.Context, itemType, name string) {
+	if !s.config.AdaptiveInterval.Enabled {
+		return
+	}
+
+	item := s.state.GetItemState(ctx, itemType, name)
+	if item == nil || item.LastDuration == 0 {
+		return
+	}
+
+	key := itemKey{itemType: itemType, name: name}
+
+	s.intervalMutex.Lock()
+
+	base, exists := s.baseIntervals[key]
+	if !exists {
+		s.intervalMutex.Unlock()
+		return
+	}
+
+	current := s.effectiveIntervals[key]
+	next := adaptedInterval(base, current, item.LastDuration, s.config.AdaptiveInterval.Threshold, s.config.AdaptiveInterval.MaxMultiplier)
+	s.effectiveIntervals[key] = next
+
+	s.intervalMutex.Unlock()
+
+	if next == current {
+		return
+	}
+
+	if ticker := s.ticker(itemType, name); ticker != nil {
+		ticker.Reset(next)
+	}
+
+	s.metrics.EffectiveIntervalGauge.With(prometheus.Labels{
+		"group": name,
+		"type":  itemType,
+	}).Set(next.Seconds())
+
+	slog.Info("Adjusted collection interval",
+		"item_type", itemType,
+		"item_name", name,
+		"duration", item.LastDuration,
+		"interval", base,
+		"effective_interval", next,
+	)
+}
+
+// ticker returns an item's ticker, if it has one
+func (s *Scheduler) ticker(itemType, name string) *time.Ticker {
+	switch itemType {
+	case "filesystem":
+		s.filesystemMutex.RLock()
+		defer s.filesystemMutex.RUnlock()
+
+		return s.filesystemTickers[name]
+	case "directory":
+		s.directoryMutex.RLock()
+		defer s.directoryMutex.RUnlock()
+
+		return s.directoryTickers[name]
+	}
+
+	return nil
+}
+
+// adaptedInterval is the interval at which a collection taking duration uses
+// the threshold share of it, kept between the configured interval and
+// maxMultiplier times it. Changes of less than a tenth of the current
+// interval are ignored so the ticker isn't re-timed after every collection,
+// except to return to the configured interval.
+func adaptedInterval(base, current, duration time.Duration, threshold, maxMultiplier float64) time.Duration {
+	next := time.Duration(float64(duration) / threshold)
+	next = min(max(next, base), time.Duration(float64(base)*maxMultiplier))
+	next = next.Round(time.Second)
+
+	if next != base && (next-current).Abs() < current/10 {
+		return current
+	}
+
+	return next
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptedInterval(t *testing.T) {
+	base := 10 * time.Minute
+
+	tests := []struct {
+		name     string
+		current  time.Duration
+		duration time.Duration
+		want     time.Duration
+	}{
+		{"fast scans keep the configured interval", base, time.Minute, base},
+		{"slow scans stretch it", base, 8 * time.Minute, 16 * time.Minute},
+		{"up to the max multiplier", base, 2 * time.Hour, 40 * time.Minute},
+		{"small changes are ignored", 16 * time.Minute, 8*time.Minute + 30*time.Second, 16 * time.Minute},
+		{"larger ones aren't", 16 * time.Minute, 10 * time.Minute, 20 * time.Minute},
+		{"faster scans shrink it back", 40 * time.Minute, 4 * time.Minute, base},
+		{"partly when still slow", 40 * time.Minute, 12 * time.Minute, 24 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if got := adaptedInterval(base, test.current, test.duration, 0.5, 4); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestCheckLiveness(t *testing.T) {
+	now := time.Now()
+
+	s := &Scheduler{
+		effectiveIntervals: map[itemKey]time.Duration{
+			{itemType: "filesystem", name: "root"}:   time.Minute,
+			{itemType: "directory", name: "backups"}: time.Hour,
+		},
+		lastTicks: map[itemKey]time.Time{
+			{itemType: "filesystem", name: "root"}:   now.Add(-2 * time.Minute),
+			{itemType: "directory", name: "backups"}: now.Add(-90 * time.Minute),
+		},
+	}
+
+	if err := s.CheckLiveness(now); err != nil {
+		t.Fatalf("Expected ticks within twice their intervals to be live, got %v", err)
+	}
+
+	s.lastTicks[itemKey{itemType: "filesystem", name: "root"}] = now.Add(-10 * time.Minute)
+
+	err := s.CheckLiveness(now)
+	if err == nil || err.Error() != "filesystem root not scheduled for 10m0s" {
+		t.Fatalf("Expected the late ticker to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tickSlack is how late past twice its interval an item's ticker may fire
+// before the scheduler counts as stuck
+const tickSlack = time.Minute
+
+// recordTick notes that an item's ticker fired
+func (s *Scheduler) recordTick(itemType, name string) {
+	s.intervalMutex.Lock()
+	s.lastTicks[itemKey{itemType: itemType, name: name}] = time.Now()
+	s.intervalMutex.Unlock()
+}
+
+// CheckLiveness returns an error naming each item whose ticker hasn't fired
+// for over twice its current interval. Ticker loops only block when
+// scheduling a job hangs, so that means the scheduler is stuck.
+func (s *Scheduler) CheckLiveness(now time.Time) error {
+	s.intervalMutex.Lock()
+	defer s.intervalMutex.Unlock()
+
+	var errs []error
+
+	for key, last := range s.lastTicks {
+		if since := now.Sub(last); since > 2*s.effectiveIntervals[key]+tickSlack {
+			errs = append(errs, fmt.Errorf("%s %s not scheduled for %s", key.itemType, key.name, since.Round(time.Second)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,16 +2,22 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
+	"filesystem-exporter/internal/collectors/mountprobe"
 	"filesystem-exporter/internal/config"
 	"filesystem-exporter/internal/metrics"
 	"filesystem-exporter/internal/queue"
 	"filesystem-exporter/internal/state"
-	"github.com/d0ugal/promexporter/tracing"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -28,12 +34,17 @@ type Scheduler struct {
 	filesystemQueue *queue.Queue
 	directoryQueue  *queue.Queue
 
+	// Optional network mount prober, used to skip items on unreachable mounts
+	prober *mountprobe.Prober
+
 	// Tickers for filesystems
 	filesystemTickers map[string]*time.Ticker
 	filesystemMutex   sync.RWMutex
 
-	// Tickers for directories
+	// Tickers for directories, and what stops each one's goroutine for
+	// groups removed at runtime
 	directoryTickers map[string]*time.Ticker
+	directoryCancels map[string]context.CancelFunc
 	directoryMutex   sync.RWMutex
 
 	// Track running jobs per item
@@ -41,8 +52,23 @@ type Scheduler struct {
 	directoryRunning  map[string]bool
 	runningMutex      sync.RWMutex
 
+	// Configured and current ticker intervals, which differ while
+	// adaptive_interval has stretched an item's
+	baseIntervals      map[itemKey]time.Duration
+	effectiveIntervals map[itemKey]time.Duration
+	intervalMutex      sync.Mutex
+
+	// When each item's ticker last fired, or started, for CheckLiveness.
+	// Guarded by intervalMutex.
+	lastTicks map[itemKey]time.Time
+
 	tracer             trace.Tracer
 	promexporterTracer *tracing.Tracer
+
+	// Lifecycle: done is closed when the Start context is cancelled and wg
+	// tracks every goroutine the scheduler spawns
+	done <-chan struct{}
+	wg   sync.WaitGroup
 }
 
 // NewScheduler creates a new scheduler
@@ -52,6 +78,7 @@ func NewScheduler(
 	s *state.Tracker,
 	fsQueue *queue.Queue,
 	dirQueue *queue.Queue,
+	prober *mountprobe.Prober,
 	tracer *tracing.Tracer,
 ) *Scheduler {
 	var otelTracer trace.Tracer
@@ -68,10 +95,15 @@ func NewScheduler(
 		state:              s,
 		filesystemQueue:    fsQueue,
 		directoryQueue:     dirQueue,
+		prober:             prober,
 		filesystemTickers:  make(map[string]*time.Ticker),
 		directoryTickers:   make(map[string]*time.Ticker),
+		directoryCancels:   make(map[string]context.CancelFunc),
 		filesystemRunning:  make(map[string]bool),
 		directoryRunning:   make(map[string]bool),
+		baseIntervals:      make(map[itemKey]time.Duration),
+		effectiveIntervals: make(map[itemKey]time.Duration),
+		lastTicks:          make(map[itemKey]time.Time),
 		tracer:             otelTracer,
 		promexporterTracer: tracer,
 	}
@@ -79,19 +111,52 @@ func NewScheduler(
 
 // Start initializes the scheduler
 func (s *Scheduler) Start(ctx context.Context) {
+	directories := s.config.DirectoryGroups()
+
 	ctx, span := s.startSpan(ctx, "scheduler.init", trace.WithAttributes(
 		attribute.Int("filesystem_count", len(s.config.Filesystems)),
-		attribute.Int("directory_count", len(s.config.Directories)),
+		attribute.Int("directory_count", len(directories)),
 	))
 	defer span.End()
 
+	s.done = ctx.Done()
+
 	slog.Info("Initializing scheduler",
 		"filesystems", len(s.config.Filesystems),
-		"directories", len(s.config.Directories),
+		"directories", len(directories),
 	)
 
-	// Register items in state tracker
+	s.registerItems(ctx)
+
+	// Start filesystem tickers
+	for _, fs := range s.config.Filesystems {
+		if fs.IsEnabled() {
+			s.startFilesystemTicker(ctx, fs)
+		}
+	}
+
+	// Start directory tickers
+	for name, dir := range directories {
+		if dir.IsEnabled() {
+			s.startDirectoryTicker(ctx, name, dir)
+		}
+	}
+
+	span.AddEvent("scheduler_initialized")
+	slog.Info("Scheduler initialized")
+}
+
+// registerItems registers every enabled item in the state tracker and
+// publishes its timeout, interval and quota. Disabled items are only
+// reported as disabled.
+func (s *Scheduler) registerItems(ctx context.Context) {
 	for _, fs := range s.config.Filesystems {
+		s.setItemEnabled("filesystem", fs.Name, fs.IsEnabled())
+
+		if !fs.IsEnabled() {
+			continue
+		}
+
 		s.state.RegisterItem(ctx, "filesystem", fs.Name)
 
 		// Set timeout metric
@@ -107,38 +172,127 @@ func (s *Scheduler) Start(ctx context.Context) {
 			"group": fs.Name,
 			"type":  "filesystem",
 		}).Set(float64(interval))
+
+		// Set quota metric
+		if fs.Quota > 0 {
+			s.metrics.QuotaBytesGauge.With(prometheus.Labels{
+				"item_name": fs.Name,
+				"item_type": "filesystem",
+			}).Set(float64(fs.Quota))
+		}
 	}
 
-	for name, dir := range s.config.Directories {
-		s.state.RegisterItem(ctx, "directory", name)
+	for name, dir := range s.config.DirectoryGroups() {
+		s.registerDirectory(ctx, name, dir)
+	}
+}
 
-		// Set timeout metric
-		timeout := s.config.GetDirectoryTimeout(dir)
-		s.metrics.CollectionTimeoutSeconds.With(prometheus.Labels{
+// registerDirectory registers a directory group as registerItems does
+func (s *Scheduler) registerDirectory(ctx context.Context, name string, dir config.DirectoryGroup) {
+	s.setItemEnabled("directory", name, dir.IsEnabled())
+
+	if !dir.IsEnabled() {
+		return
+	}
+
+	s.state.RegisterItem(ctx, "directory", name)
+
+	// Set timeout metric
+	timeout := s.config.GetDirectoryTimeout(dir)
+	s.metrics.CollectionTimeoutSeconds.With(prometheus.Labels{
+		"item_name": name,
+		"item_type": "directory",
+	}).Set(timeout.Seconds())
+
+	// Set interval metric
+	interval := s.config.GetDirectoryInterval(dir)
+	s.metrics.CollectionIntervalGauge.With(prometheus.Labels{
+		"group": name,
+		"type":  "directory",
+	}).Set(float64(interval))
+
+	// Set quota metric
+	if dir.Quota > 0 {
+		s.metrics.QuotaBytesGauge.With(prometheus.Labels{
 			"item_name": name,
 			"item_type": "directory",
-		}).Set(timeout.Seconds())
+		}).Set(float64(dir.Quota))
+	}
 
-		// Set interval metric
-		interval := s.config.GetDirectoryInterval(dir)
-		s.metrics.CollectionIntervalGauge.With(prometheus.Labels{
-			"group": name,
-			"type":  "directory",
-		}).Set(float64(interval))
+	s.metrics.DirectoryPathInfo.With(s.metrics.ItemLabels(dir.Labels, prometheus.Labels{
+		"group":          name,
+		"path":           dir.Path,
+		"canonical_path": s.config.GetDirectoryPath(dir),
+	})).Set(1)
+}
+
+// setItemEnabled publishes whether an item is collected
+func (s *Scheduler) setItemEnabled(itemType, name string, enabled bool) {
+	value := 0.0
+	if enabled {
+		value = 1
 	}
 
-	// Start filesystem tickers
+	s.metrics.ItemEnabledGauge.With(prometheus.Labels{
+		"item_name": name,
+		"item_type": itemType,
+	}).Set(value)
+}
+
+// Jobs registers every configured item and returns a job for each enabled
+// one, for one-shot runs that process them directly instead of through the
+// queues. Items on mounts that failed their last probe are skipped.
+func (s *Scheduler) Jobs(ctx context.Context) []queue.Job {
+	s.registerItems(ctx)
+
+	var jobs []queue.Job
+
 	for _, fs := range s.config.Filesystems {
-		s.startFilesystemTicker(ctx, fs)
+		if !fs.IsEnabled() || s.skipUnreachable(trace.SpanFromContext(ctx), "filesystem", fs.Name, fs.MountPoint) {
+			continue
+		}
+
+		jobs = append(jobs, queue.Job{
+			ID:       fmt.Sprintf("%s-%s-%d", "filesystem", fs.Name, time.Now().Unix()),
+			Type:     "filesystem",
+			Name:     fs.Name,
+			Path:     fs.MountPoint,
+			Timeout:  s.config.GetFilesystemTimeout(fs),
+			Interval: fs.Interval.Duration,
+			Priority: fs.Priority,
+			Context:  ctx,
+		})
 	}
 
-	// Start directory tickers
-	for name, dir := range s.config.Directories {
-		s.startDirectoryTicker(ctx, name, dir)
+	directories := s.config.DirectoryGroups()
+
+	for _, name := range slices.Sorted(maps.Keys(directories)) {
+		dir := directories[name]
+		path := s.config.GetDirectoryPath(dir)
+
+		if !dir.IsEnabled() || s.skipUnreachable(trace.SpanFromContext(ctx), "directory", name, path) {
+			continue
+		}
+
+		jobs = append(jobs, queue.Job{
+			ID:       fmt.Sprintf("%s-%s-%d", "directory", name, time.Now().Unix()),
+			Type:     "directory",
+			Name:     name,
+			Path:     path,
+			Timeout:  s.config.GetDirectoryTimeout(dir),
+			Interval: dir.Interval.Duration,
+			Priority: dir.Priority,
+			Context:  ctx,
+		})
 	}
 
-	span.AddEvent("scheduler_initialized")
-	slog.Info("Scheduler initialized")
+	// In the order the queues would take them: by priority, then filesystems
+	// in config order and directory groups by name
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Priority > jobs[j].Priority
+	})
+
+	return jobs
 }
 
 // startFilesystemTicker starts a ticker for a filesystem
@@ -171,6 +325,8 @@ func (s *Scheduler) startFilesystemTicker(ctx context.Context, fs config.Filesys
 	s.filesystemTickers[fs.Name] = ticker
 	s.filesystemMutex.Unlock()
 
+	s.setInterval("filesystem", fs.Name, intervalDuration)
+
 	// Initial collection - create a root span for it
 	spanCtx := context.WithoutCancel(ctx)
 	initCtx, initSpan := s.startSpan(spanCtx, "collection.cycle", trace.WithAttributes(
@@ -179,12 +335,12 @@ func (s *Scheduler) startFilesystemTicker(ctx context.Context, fs config.Filesys
 		attribute.Float64("interval_seconds", intervalDuration.Seconds()),
 		attribute.Bool("initial", true),
 	))
-	s.scheduleFilesystem(initCtx, fs, timeout, intervalDuration)
+	done := s.scheduleFilesystem(initCtx, fs, timeout, intervalDuration)
 	// End the cycle span when the job completes (async)
-	go s.waitForJobCompletionAndEndSpan(initCtx, initSpan, "filesystem", fs.Name, timeout)
+	s.goTracked(func() { s.waitForJobCompletionAndEndSpan(initCtx, initSpan, "filesystem", fs.Name, done) })
 
 	// Start goroutine for ticker
-	go func() {
+	s.goTracked(func() {
 		defer ticker.Stop()
 
 		for {
@@ -192,19 +348,24 @@ func (s *Scheduler) startFilesystemTicker(ctx context.Context, fs config.Filesys
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				// Create a new root span for each collection cycle
-				cycleCtx := context.WithoutCancel(ctx)
-				cycleCtx, cycleSpan := s.startSpan(cycleCtx, "collection.cycle", trace.WithAttributes(
-					attribute.String("item.type", "filesystem"),
-					attribute.String("item.name", fs.Name),
-					attribute.Float64("interval_seconds", intervalDuration.Seconds()),
-				))
-				s.scheduleFilesystem(cycleCtx, fs, timeout, intervalDuration)
-				// End the cycle span when the job completes (async)
-				go s.waitForJobCompletionAndEndSpan(cycleCtx, cycleSpan, "filesystem", fs.Name, timeout)
+				s.recordTick("filesystem", fs.Name)
+
+				// A panic skips this cycle rather than stopping the ticker
+				s.guard(func() {
+					// Create a new root span for each collection cycle
+					cycleCtx := context.WithoutCancel(ctx)
+					cycleCtx, cycleSpan := s.startSpan(cycleCtx, "collection.cycle", trace.WithAttributes(
+						attribute.String("item.type", "filesystem"),
+						attribute.String("item.name", fs.Name),
+						attribute.Float64("interval_seconds", intervalDuration.Seconds()),
+					))
+					done := s.scheduleFilesystem(cycleCtx, fs, timeout, intervalDuration)
+					// End the cycle span when the job completes (async)
+					s.goTracked(func() { s.waitForJobCompletionAndEndSpan(cycleCtx, cycleSpan, "filesystem", fs.Name, done) })
+				})
 			}
 		}
-	}()
+	})
 
 	span.AddEvent("filesystem_ticker_started")
 }
@@ -216,6 +377,8 @@ func (s *Scheduler) startDirectoryTicker(ctx context.Context, name string, dir c
 	))
 	defer span.End()
 
+	ctx, cancel := context.WithCancel(ctx)
+
 	interval := s.config.GetDirectoryInterval(dir)
 	intervalDuration := time.Duration(interval) * time.Second
 	timeout := s.config.GetDirectoryTimeout(dir)
@@ -237,8 +400,11 @@ func (s *Scheduler) startDirectoryTicker(ctx context.Context, name string, dir c
 
 	s.directoryMutex.Lock()
 	s.directoryTickers[name] = ticker
+	s.directoryCancels[name] = cancel
 	s.directoryMutex.Unlock()
 
+	s.setInterval("directory", name, intervalDuration)
+
 	// Initial collection - create a root span for it
 	initCtx := context.WithoutCancel(ctx)
 	initCtx, initSpan := s.startSpan(initCtx, "collection.cycle", trace.WithAttributes(
@@ -247,12 +413,12 @@ func (s *Scheduler) startDirectoryTicker(ctx context.Context, name string, dir c
 		attribute.Float64("interval_seconds", intervalDuration.Seconds()),
 		attribute.Bool("initial", true),
 	))
-	s.scheduleDirectory(initCtx, name, dir, timeout, intervalDuration)
+	done := s.scheduleDirectory(initCtx, name, dir, timeout, intervalDuration)
 	// End the cycle span when the job completes (async)
-	go s.waitForJobCompletionAndEndSpan(initCtx, initSpan, "directory", name, timeout)
+	s.goTracked(func() { s.waitForJobCompletionAndEndSpan(initCtx, initSpan, "directory", name, done) })
 
 	// Start goroutine for ticker
-	go func() {
+	s.goTracked(func() {
 		defer ticker.Stop()
 
 		for {
@@ -260,25 +426,166 @@ func (s *Scheduler) startDirectoryTicker(ctx context.Context, name string, dir c
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				// Create a new root span for each collection cycle
-				cycleCtx := context.WithoutCancel(ctx)
-				cycleCtx, cycleSpan := s.startSpan(cycleCtx, "collection.cycle", trace.WithAttributes(
-					attribute.String("item.type", "directory"),
-					attribute.String("item.name", name),
-					attribute.Float64("interval_seconds", intervalDuration.Seconds()),
-				))
-				s.scheduleDirectory(cycleCtx, name, dir, timeout, intervalDuration)
-				// End the cycle span when the job completes (async)
-				go s.waitForJobCompletionAndEndSpan(cycleCtx, cycleSpan, "directory", name, timeout)
+				s.recordTick("directory", name)
+
+				// A panic skips this cycle rather than stopping the ticker
+				s.guard(func() {
+					// Create a new root span for each collection cycle
+					cycleCtx := context.WithoutCancel(ctx)
+					cycleCtx, cycleSpan := s.startSpan(cycleCtx, "collection.cycle", trace.WithAttributes(
+						attribute.String("item.type", "directory"),
+						attribute.String("item.name", name),
+						attribute.Float64("interval_seconds", intervalDuration.Seconds()),
+					))
+					done := s.scheduleDirectory(cycleCtx, name, dir, timeout, intervalDuration)
+					// End the cycle span when the job completes (async)
+					s.goTracked(func() { s.waitForJobCompletionAndEndSpan(cycleCtx, cycleSpan, "directory", name, done) })
+				})
 			}
 		}
-	}()
+	})
 
 	span.AddEvent("directory_ticker_started")
 }
 
-// scheduleFilesystem schedules a filesystem collection job
-func (s *Scheduler) scheduleFilesystem(ctx context.Context, fs config.FilesystemConfig, timeout time.Duration, interval time.Duration) {
+// AddDirectory registers a directory group added at runtime and starts its
+// ticker, as Start does for the configured ones. ctx is the context the
+// scheduler was started with, which stops the ticker.
+func (s *Scheduler) AddDirectory(ctx context.Context, name string) {
+	dir, exists := s.config.Directory(name)
+	if !exists {
+		return
+	}
+
+	s.registerDirectory(ctx, name, dir)
+
+	if dir.IsEnabled() {
+		s.startDirectoryTicker(ctx, name, dir)
+	}
+}
+
+// RemoveDirectory stops the ticker of a directory group removed at runtime
+// and forgets its state and series. A job for it that is already queued or
+// running finishes, and the worker deletes the series it writes.
+func (s *Scheduler) RemoveDirectory(ctx context.Context, name string) {
+	s.directoryMutex.Lock()
+	cancel := s.directoryCancels[name]
+	delete(s.directoryTickers, name)
+	delete(s.directoryCancels, name)
+	s.directoryMutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	key := itemKey{itemType: "directory", name: name}
+
+	s.intervalMutex.Lock()
+	delete(s.baseIntervals, key)
+	delete(s.effectiveIntervals, key)
+	delete(s.lastTicks, key)
+	s.intervalMutex.Unlock()
+
+	s.state.UnregisterItem(ctx, "directory", name)
+	s.metrics.DeleteDirectoryGroupSeries(name)
+}
+
+// CollectAll schedules a collection of every enabled item straight away, as
+// if each ticker had fired, in a goroutine of its own since a full queue can
+// hold it up. Items whose previous job is still queued or running are
+// skipped as usual. ctx is the context the scheduler was started with.
+func (s *Scheduler) CollectAll(ctx context.Context) {
+	s.goTracked(func() {
+		scheduled, skipped := 0, 0
+
+		count := func(done bool) {
+			if done {
+				scheduled++
+			} else {
+				skipped++
+			}
+		}
+
+		for _, fs := range s.config.Filesystems {
+			if !fs.IsEnabled() {
+				continue
+			}
+
+			interval := time.Duration(s.config.GetFilesystemInterval(fs)) * time.Second
+			timeout := s.config.GetFilesystemTimeout(fs)
+
+			count(s.triggerCycle(ctx, "filesystem", fs.Name, interval, func(ctx context.Context) <-chan struct{} {
+				return s.scheduleFilesystem(ctx, fs, timeout, interval)
+			}))
+		}
+
+		directories := s.config.DirectoryGroups()
+
+		for _, name := range slices.Sorted(maps.Keys(directories)) {
+			dir := directories[name]
+			if !dir.IsEnabled() {
+				continue
+			}
+
+			interval := time.Duration(s.config.GetDirectoryInterval(dir)) * time.Second
+			timeout := s.config.GetDirectoryTimeout(dir)
+
+			count(s.triggerCycle(ctx, "directory", name, interval, func(ctx context.Context) <-chan struct{} {
+				return s.scheduleDirectory(ctx, name, dir, timeout, interval)
+			}))
+		}
+
+		slog.Info("Scheduled a collection of every item", "scheduled", scheduled, "skipped", skipped)
+	})
+}
+
+// triggerCycle runs a collection cycle outside an item's ticker, reporting
+// whether a job was scheduled
+func (s *Scheduler) triggerCycle(ctx context.Context, itemType, name string, interval time.Duration, schedule func(context.Context) <-chan struct{}) bool {
+	cycleCtx, cycleSpan := s.startSpan(context.WithoutCancel(ctx), "collection.cycle", trace.WithAttributes(
+		attribute.String("item.type", itemType),
+		attribute.String("item.name", name),
+		attribute.Float64("interval_seconds", interval.Seconds()),
+		attribute.Bool("triggered", true),
+	))
+	done := schedule(cycleCtx)
+	// End the cycle span when the job completes (async)
+	s.goTracked(func() { s.waitForJobCompletionAndEndSpan(cycleCtx, cycleSpan, itemType, name, done) })
+
+	return done != nil
+}
+
+// Diagnostics returns the configured and current interval of each item's
+// ticker, when it last fired and which items have a job queued or running
+func (s *Scheduler) Diagnostics() map[string]any {
+	tickers := make(map[string]any)
+
+	s.intervalMutex.Lock()
+	for key, interval := range s.baseIntervals {
+		tickers[key.itemType+"/"+key.name] = map[string]any{
+			"interval":           interval.String(),
+			"effective_interval": s.effectiveIntervals[key].String(),
+			"last_tick":          s.lastTicks[key],
+		}
+	}
+	s.intervalMutex.Unlock()
+
+	s.runningMutex.RLock()
+	running := map[string][]string{
+		"filesystem": slices.Sorted(maps.Keys(s.filesystemRunning)),
+		"directory":  slices.Sorted(maps.Keys(s.directoryRunning)),
+	}
+	s.runningMutex.RUnlock()
+
+	return map[string]any{
+		"tickers": tickers,
+		"running": running,
+	}
+}
+
+// scheduleFilesystem schedules a filesystem collection job. It returns a
+// channel closed once the job is over, or nil if it was skipped.
+func (s *Scheduler) scheduleFilesystem(ctx context.Context, fs config.FilesystemConfig, timeout time.Duration, interval time.Duration) <-chan struct{} {
 	ctx, span := s.startSpan(ctx, "scheduler.schedule", trace.WithAttributes(
 		attribute.String("item.type", "filesystem"),
 		attribute.String("item.name", fs.Name),
@@ -307,43 +614,23 @@ func (s *Scheduler) scheduleFilesystem(ctx context.Context, fs config.Filesystem
 			)
 			span.AddEvent("job_skipped")
 
-			return
+			return nil
 		}
 	}
 
-	// Mark as running (will be cleared when job completes)
+	if s.skipQueued(span, s.filesystemQueue, "filesystem", fs.Name) {
+		return nil
+	}
+
+	if s.skipUnreachable(span, "filesystem", fs.Name, fs.MountPoint) {
+		return nil
+	}
+
+	// Mark as running until the job is over
 	s.runningMutex.Lock()
 	s.filesystemRunning[fs.Name] = true
 	s.runningMutex.Unlock()
 
-	// Clear running flag when job completes (async check)
-	go func() {
-		// Wait for job to complete by checking state
-		ticker := time.NewTicker(100 * time.Millisecond)
-		defer ticker.Stop()
-
-		timeoutChan := time.After(timeout + 5*time.Second) // Wait a bit longer than timeout
-
-		for {
-			select {
-			case <-timeoutChan:
-				s.runningMutex.Lock()
-				delete(s.filesystemRunning, fs.Name)
-				s.runningMutex.Unlock()
-
-				return
-			case <-ticker.C:
-				if !s.state.IsRunning(ctx, "filesystem", fs.Name) {
-					s.runningMutex.Lock()
-					delete(s.filesystemRunning, fs.Name)
-					s.runningMutex.Unlock()
-
-					return
-				}
-			}
-		}
-	}()
-
 	// Create job
 	job := queue.Job{
 		ID:       fmt.Sprintf("%s-%s-%d", "filesystem", fs.Name, time.Now().Unix()),
@@ -352,19 +639,23 @@ func (s *Scheduler) scheduleFilesystem(ctx context.Context, fs config.Filesystem
 		Path:     fs.MountPoint,
 		Timeout:  timeout,
 		Interval: interval,
+		Priority: fs.Priority,
 		Context:  ctx,
+		Done:     make(chan struct{}),
 	}
 
 	// Enqueue
 	if err := s.filesystemQueue.Enqueue(ctx, job); err != nil {
-		slog.Error("Failed to enqueue filesystem job", "filesystem", fs.Name, "error", err)
+		if errors.Is(err, queue.ErrDropped) {
+			slog.Warn("Dropped filesystem job, queue is full", "filesystem", fs.Name, "overflow_policy", s.config.Queue.OverflowPolicy)
+		} else {
+			slog.Error("Failed to enqueue filesystem job", "filesystem", fs.Name, "error", err)
+		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		s.runningMutex.Lock()
-		s.filesystemRunning[fs.Name] = false
-		s.runningMutex.Unlock()
+		s.ClearRunning("filesystem", fs.Name)
 
-		return
+		return nil
 	}
 
 	span.SetAttributes(
@@ -373,10 +664,106 @@ func (s *Scheduler) scheduleFilesystem(ctx context.Context, fs config.Filesystem
 		attribute.Float64("job.interval_seconds", interval.Seconds()),
 	)
 	span.AddEvent("job_scheduled")
+
+	return job.Done
+}
+
+// ScrapeJob marks a filesystem running and returns a job collecting it for a
+// scrape, to be run directly rather than queued. It returns false if a job
+// for the filesystem is already queued or running, or its mount is
+// unreachable. The caller clears the running flag once the job is over.
+func (s *Scheduler) ScrapeJob(ctx context.Context, fs config.FilesystemConfig) (queue.Job, bool) {
+	s.runningMutex.Lock()
+	running := s.filesystemRunning[fs.Name]
+
+	if !running {
+		s.filesystemRunning[fs.Name] = true
+	}
+	s.runningMutex.Unlock()
+
+	if running {
+		return queue.Job{}, false
+	}
+
+	if s.skipUnreachable(trace.SpanFromContext(ctx), "filesystem", fs.Name, fs.MountPoint) {
+		s.ClearRunning("filesystem", fs.Name)
+		return queue.Job{}, false
+	}
+
+	return queue.Job{
+		ID:       fmt.Sprintf("%s-%s-%d", "filesystem", fs.Name, time.Now().Unix()),
+		Type:     "filesystem",
+		Name:     fs.Name,
+		Path:     fs.MountPoint,
+		Timeout:  s.config.GetFilesystemTimeout(fs),
+		Interval: fs.Interval.Duration,
+		Priority: fs.Priority,
+		Context:  ctx,
+	}, true
+}
+
+// skipQueued reports whether an item should be skipped because a job for it
+// is still waiting in its queue, so a slow worker doesn't build up a backlog
+// of identical jobs
+func (s *Scheduler) skipQueued(span trace.Span, q *queue.Queue, queueType, name string) bool {
+	if !q.Waiting(queueType, name) {
+		return false
+	}
+
+	slog.Debug("Skipping collection - previous job still queued",
+		"queue_type", queueType,
+		"item_name", name,
+	)
+	s.metrics.CollectionSkippedCounter.With(prometheus.Labels{
+		"queue_type": queueType,
+		"item_name":  name,
+		"reason":     "already_queued",
+	}).Inc()
+	span.SetAttributes(
+		attribute.Bool("scheduler.skipped", true),
+		attribute.String("scheduler.skip_reason", "already_queued"),
+	)
+	span.AddEvent("job_skipped")
+
+	return true
+}
+
+// skipUnreachable reports whether an item should be skipped because the mount
+// it lives on failed its last probe, so a hung network server doesn't tie up
+// a worker until the command times out
+func (s *Scheduler) skipUnreachable(span trace.Span, queueType, name, path string) bool {
+	if s.prober == nil {
+		return false
+	}
+
+	mountPoint, unreachable := s.prober.Unreachable(path)
+	if !unreachable {
+		return false
+	}
+
+	slog.Warn("Skipping collection - mount unreachable",
+		"queue_type", queueType,
+		"item_name", name,
+		"mount_point", mountPoint,
+	)
+	s.metrics.CollectionSkippedCounter.With(prometheus.Labels{
+		"queue_type": queueType,
+		"item_name":  name,
+		"reason":     "mount_unreachable",
+	}).Inc()
+	span.SetAttributes(
+		attribute.Bool("scheduler.skipped", true),
+		attribute.String("scheduler.skip_reason", "mount_unreachable"),
+		attribute.String("scheduler.unreachable_mount_point", mountPoint),
+	)
+	span.AddEvent("job_skipped")
+
+	return true
 }
 
-// scheduleDirectory schedules a directory collection job
-func (s *Scheduler) scheduleDirectory(ctx context.Context, name string, dir config.DirectoryGroup, timeout time.Duration, interval time.Duration) {
+// scheduleDirectory schedules a directory collection job. It returns a
+// channel closed once the job is over, or nil if it was skipped.
+func (s *Scheduler) scheduleDirectory(ctx context.Context, name string, dir config.DirectoryGroup, timeout time.Duration, interval time.Duration) <-chan struct{} {
 	ctx, span := s.startSpan(ctx, "scheduler.schedule", trace.WithAttributes(
 		attribute.String("item.type", "directory"),
 		attribute.String("item.name", name),
@@ -405,64 +792,66 @@ func (s *Scheduler) scheduleDirectory(ctx context.Context, name string, dir conf
 			)
 			span.AddEvent("job_skipped")
 
-			return
+			return nil
 		}
 	}
 
-	// Mark as running (will be cleared when job completes)
-	s.runningMutex.Lock()
-	s.directoryRunning[name] = true
-	s.runningMutex.Unlock()
+	if s.skipQueued(span, s.directoryQueue, "directory", name) {
+		return nil
+	}
 
-	// Clear running flag when job completes (async check)
-	go func() {
-		// Wait for job to complete by checking state
-		ticker := time.NewTicker(100 * time.Millisecond)
-		defer ticker.Stop()
+	if dir.InBlackoutWindow(time.Now()) {
+		slog.Debug("Skipping directory collection - inside blackout window", "directory", name)
+		s.metrics.CollectionSkippedCounter.With(prometheus.Labels{
+			"queue_type": "directory",
+			"item_name":  name,
+			"reason":     "blackout_window",
+		}).Inc()
+		span.SetAttributes(
+			attribute.Bool("scheduler.skipped", true),
+			attribute.String("scheduler.skip_reason", "blackout_window"),
+		)
+		span.AddEvent("job_skipped")
 
-		timeoutChan := time.After(timeout + 5*time.Second) // Wait a bit longer than timeout
+		return nil
+	}
 
-		for {
-			select {
-			case <-timeoutChan:
-				s.runningMutex.Lock()
-				delete(s.directoryRunning, name)
-				s.runningMutex.Unlock()
+	path := s.config.GetDirectoryPath(dir)
 
-				return
-			case <-ticker.C:
-				if !s.state.IsRunning(ctx, "directory", name) {
-					s.runningMutex.Lock()
-					delete(s.directoryRunning, name)
-					s.runningMutex.Unlock()
+	if s.skipUnreachable(span, "directory", name, path) {
+		return nil
+	}
 
-					return
-				}
-			}
-		}
-	}()
+	// Mark as running until the job is over
+	s.runningMutex.Lock()
+	s.directoryRunning[name] = true
+	s.runningMutex.Unlock()
 
 	// Create job
 	job := queue.Job{
 		ID:       fmt.Sprintf("%s-%s-%d", "directory", name, time.Now().Unix()),
 		Type:     "directory",
 		Name:     name,
-		Path:     dir.Path,
+		Path:     path,
 		Timeout:  timeout,
 		Interval: interval,
+		Priority: dir.Priority,
 		Context:  ctx,
+		Done:     make(chan struct{}),
 	}
 
 	// Enqueue
 	if err := s.directoryQueue.Enqueue(ctx, job); err != nil {
-		slog.Error("Failed to enqueue directory job", "directory", name, "error", err)
+		if errors.Is(err, queue.ErrDropped) {
+			slog.Warn("Dropped directory job, queue is full", "directory", name, "overflow_policy", s.config.Queue.OverflowPolicy)
+		} else {
+			slog.Error("Failed to enqueue directory job", "directory", name, "error", err)
+		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		s.runningMutex.Lock()
-		s.directoryRunning[name] = false
-		s.runningMutex.Unlock()
+		s.ClearRunning("directory", name)
 
-		return
+		return nil
 	}
 
 	span.SetAttributes(
@@ -471,6 +860,40 @@ func (s *Scheduler) scheduleDirectory(ctx context.Context, name string, dir conf
 		attribute.Float64("job.interval_seconds", interval.Seconds()),
 	)
 	span.AddEvent("job_scheduled")
+
+	return job.Done
+}
+
+// goTracked runs fn in a goroutine that Wait blocks on
+func (s *Scheduler) goTracked(fn func()) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		s.guard(fn)
+	}()
+}
+
+// guard runs fn, recovering and counting any panic in it
+func (s *Scheduler) guard(fn func()) {
+	defer utils.RecoverPanic(nil, s.metrics.PanicsCounter, "scheduler", nil)
+
+	fn()
+}
+
+// Wait blocks until every goroutine spawned by the scheduler has exited after
+// its Start context was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// DirectoryBusy reports whether a job for the directory group is queued or
+// running
+func (s *Scheduler) DirectoryBusy(name string) bool {
+	s.runningMutex.RLock()
+	defer s.runningMutex.RUnlock()
+
+	return s.directoryRunning[name]
 }
 
 // ClearRunning clears the running flag for an item
@@ -486,36 +909,31 @@ func (s *Scheduler) ClearRunning(queueType string, itemName string) {
 	}
 }
 
-// waitForJobCompletionAndEndSpan waits for a job to complete and then ends the cycle span
-func (s *Scheduler) waitForJobCompletionAndEndSpan(ctx context.Context, span trace.Span, itemType, itemName string, timeout time.Duration) {
-	// Wait for job to complete by checking state
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+// waitForJobCompletionAndEndSpan waits for the job scheduled in a cycle to
+// finish, then clears the item's running flag and ends the cycle span. done
+// is nil when the cycle was skipped.
+func (s *Scheduler) waitForJobCompletionAndEndSpan(ctx context.Context, span trace.Span, itemType, itemName string, done <-chan struct{}) {
+	if done == nil {
+		span.SetAttributes(attribute.Bool("cycle.skipped", true))
+		span.End()
 
-	timeoutChan := time.After(timeout + 5*time.Second) // Wait a bit longer than timeout
+		return
+	}
 
-	for {
-		select {
-		case <-timeoutChan:
-			span.SetAttributes(
-				attribute.Bool("cycle.timeout", true),
-			)
-			span.SetStatus(codes.Error, "job completion timeout")
-			span.End()
-
-			return
-		case <-ticker.C:
-			if !s.state.IsRunning(ctx, itemType, itemName) {
-				// Job completed successfully
-				span.SetAttributes(
-					attribute.Bool("cycle.completed", true),
-				)
-				span.SetStatus(codes.Ok, "job completed")
-				span.End()
+	select {
+	case <-s.done:
+		span.SetStatus(codes.Error, "scheduler stopped")
+		span.End()
+	case <-done:
+		s.ClearRunning(itemType, itemName)
 
-				return
-			}
-		}
+		span.SetAttributes(
+			attribute.Bool("cycle.completed", true),
+		)
+		span.SetStatus(codes.Ok, "job completed")
+		span.End()
+
+		s.adaptInterval(ctx, itemType, itemName)
 	}
 }
 
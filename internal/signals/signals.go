@@ -0,0 +1,126 @@
+// Package signals lets operators reach the exporter through Unix signals
+// when its API can't be reached: SIGUSR1 dumps its diagnostics and SIGUSR2
+// collects every item straight away
+package signals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"filesystem-exporter/internal/config"
+)
+
+// Target is what the signals act on, as the coordinator does
+type Target interface {
+	Diagnostics(ctx context.Context) map[string]any
+	CollectAll() bool
+}
+
+// Handler dumps the coordinator's diagnostics on SIGUSR1 and queues every
+// item on SIGUSR2. It must be started after the coordinator, so a SIGUSR2
+// that arrives straight away finds it running. Windows has neither signal,
+// so there it does nothing.
+type Handler struct {
+	dumpFile string // signals.dump_file; empty to log the diagnostics
+	target   Target
+
+	wg sync.WaitGroup
+}
+
+// NewHandler creates a handler acting on target
+func NewHandler(cfg *config.Config, target Target) *Handler {
+	return &Handler{dumpFile: cfg.Signals.DumpFile, target: target}
+}
+
+// Start starts waiting for the signals until ctx is cancelled
+func (h *Handler) Start(ctx context.Context) {
+	dump := make(chan os.Signal, 1)
+	collect := make(chan os.Signal, 1)
+
+	if !notify(dump, collect) {
+		return
+	}
+
+	h.wg.Add(1)
+
+	go func() {
+		defer h.wg.Done()
+		defer signal.Stop(dump)
+		defer signal.Stop(collect)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-dump:
+				h.dump(ctx)
+			case <-collect:
+				h.collect()
+			}
+		}
+	}()
+}
+
+// Stop blocks until the handler has stopped waiting after ctx was cancelled
+func (h *Handler) Stop() {
+	h.wg.Wait()
+}
+
+// dump writes the diagnostics to signals.dump_file, or logs them
+func (h *Handler) dump(ctx context.Context) {
+	diagnostics := h.target.Diagnostics(ctx)
+
+	if h.dumpFile == "" {
+		slog.Info("Diagnostics", "diagnostics", diagnostics)
+		return
+	}
+
+	if err := writeDump(h.dumpFile, diagnostics); err != nil {
+		slog.Error("Failed to dump diagnostics", "error", err)
+		return
+	}
+
+	slog.Info("Dumped diagnostics", "file", h.dumpFile)
+}
+
+// collect schedules a collection of every item
+func (h *Handler) collect() {
+	if !h.target.CollectAll() {
+		slog.Warn("Not collecting on signal, since collection isn't running")
+	}
+}
+
+// writeDump replaces path with the diagnostics as JSON, so a reader never
+// sees half a dump
+func writeDump(path string, diagnostics map[string]any) error {
+	data, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	_, err = file.Write(data)
+	err = errors.Join(err, file.Close())
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
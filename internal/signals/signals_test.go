@@ -0,0 +1,97 @@
+//go:build !windows
+
+package signals
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+)
+
+type fakeTarget struct {
+	collects atomic.Int32
+}
+
+func (f *fakeTarget) Diagnostics(_ context.Context) map[string]any {
+	return map[string]any{"scheduler": map[string]any{"tickers": map[string]any{}}}
+}
+
+func (f *fakeTarget) CollectAll() bool {
+	f.collects.Add(1)
+	return true
+}
+
+// start starts a handler and stops it when the test ends
+func start(t *testing.T, cfg *config.Config, target Target) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := NewHandler(cfg, target)
+	h.Start(ctx)
+
+	t.Cleanup(func() {
+		cancel()
+		h.Stop()
+	})
+}
+
+func TestHandler_DumpsToFile(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Signals.DumpFile = filepath.Join(t.TempDir(), "diagnostics.json")
+
+	start(t, cfg, &fakeTarget{})
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Failed to send SIGUSR1: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		data, err := os.ReadFile(cfg.Signals.DumpFile)
+		if err == nil {
+			var diagnostics map[string]any
+			if err := json.Unmarshal(data, &diagnostics); err != nil {
+				t.Fatalf("Dump isn't JSON: %v", err)
+			}
+
+			if _, ok := diagnostics["scheduler"]; !ok {
+				t.Errorf("Dump is missing the scheduler: %s", data)
+			}
+
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Diagnostics were not dumped: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandler_CollectsOnSIGUSR2(t *testing.T) {
+	target := &fakeTarget{}
+	start(t, &config.Config{}, target)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatalf("Failed to send SIGUSR2: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for target.collects.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Collection was not triggered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package signals
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notify relays SIGUSR1 to dump and SIGUSR2 to collect
+func notify(dump, collect chan<- os.Signal) bool {
+	signal.Notify(dump, syscall.SIGUSR1)
+	signal.Notify(collect, syscall.SIGUSR2)
+
+	return true
+}
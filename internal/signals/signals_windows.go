@@ -0,0 +1,10 @@
+//go:build windows
+
+package signals
+
+import "os"
+
+// notify does nothing, since Windows has no SIGUSR1 or SIGUSR2
+func notify(_, _ chan<- os.Signal) bool {
+	return false
+}
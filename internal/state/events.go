@@ -0,0 +1,105 @@
+package state
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Types of job lifecycle events
+const (
+	EventQueued   = "queued"
+	EventStarted  = "started"
+	EventFinished = "finished"
+	EventFailed   = "failed"
+)
+
+// eventBufferSize is how many events a subscriber can fall behind by before
+// further ones are dropped for it
+const eventBufferSize = 256
+
+// Event is a step in a job's lifecycle
+type Event struct {
+	Type            string    `json:"type"`      // queued, started, finished or failed
+	ItemType        string    `json:"item_type"` // "filesystem" or "directory"
+	Name            string    `json:"name"`
+	JobID           string    `json:"job_id"`
+	Time            time.Time `json:"time"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"` // How long a finished or failed job ran
+	Error           string    `json:"error,omitempty"`
+	Reason          string    `json:"reason,omitempty"` // Why a job failed: timeout, permission, not_found, parse or other
+}
+
+// Subscribe returns a channel receiving every job event from now on, and a
+// function to stop receiving them. Events are dropped rather than waited for
+// when the channel falls behind.
+func (t *Tracker) Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, eventBufferSize)
+
+	t.subscribersMu.Lock()
+	t.subscribers[events] = struct{}{}
+	t.subscribersMu.Unlock()
+
+	unsubscribe := func() {
+		t.subscribersMu.Lock()
+		defer t.subscribersMu.Unlock()
+
+		delete(t.subscribers, events)
+	}
+
+	return events, unsubscribe
+}
+
+// RecordQueued publishes that a job was queued. Running and finished jobs are
+// published as their state is recorded.
+func (t *Tracker) RecordQueued(ctx context.Context, queueType string, jobID string, itemName string) {
+	_, span := t.startSpan(ctx, "state.record_queued", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("job.id", jobID),
+		attribute.String("item.name", itemName),
+	))
+	defer span.End()
+
+	t.publish(Event{
+		Type:     EventQueued,
+		ItemType: queueType,
+		Name:     itemName,
+		JobID:    jobID,
+		Time:     time.Now(),
+	})
+}
+
+// publish sends an event to every subscriber with room for it
+func (t *Tracker) publish(event Event) {
+	t.subscribersMu.Lock()
+	defer t.subscribersMu.Unlock()
+
+	for events := range t.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// resultEvent is the event of a job's result
+func resultEvent(queueType string, itemName string, result JobResult) Event {
+	event := Event{
+		Type:            EventFinished,
+		ItemType:        queueType,
+		Name:            itemName,
+		JobID:           result.JobID,
+		Time:            result.EndTime,
+		DurationSeconds: result.Duration.Seconds(),
+	}
+
+	if result.Err != nil {
+		event.Type = EventFailed
+		event.Error = result.Err.Error()
+		event.Reason = result.Reason
+	}
+
+	return event
+}
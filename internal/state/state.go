@@ -2,10 +2,11 @@ package state
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/d0ugal/promexporter/tracing"
+	"filesystem-exporter/internal/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -18,27 +19,57 @@ type JobState struct {
 	Name      string
 	Path      string
 	StartedAt time.Time
+	Timeout   time.Duration // How long each of the job's commands and walks may run
 	TraceID   string
+	Commands  [][]string // argv of each external command run so far
+}
+
+// ErrorHistorySize is the number of recent failures retained per item
+const ErrorHistorySize = 10
+
+// ErrorRecord represents a single failed collection of an item
+type ErrorRecord struct {
+	JobID   string    `json:"job_id"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Reason  string    `json:"reason"` // timeout, permission, not_found, parse or other
+}
+
+// JobResult is the outcome of a finished job
+type JobResult struct {
+	JobID     string
+	EndTime   time.Time
+	Duration  time.Duration
+	SizeBytes int64  // Used bytes of a filesystem or total size of a directory group; 0 if nothing was measured
+	Err       error  // nil on success
+	Reason    string // Why the job failed: timeout, permission, not_found, parse or other
 }
 
 // ItemState represents the state of a monitored item
 type ItemState struct {
-	Name          string
-	Type          string // "filesystem" or "directory"
-	LastStartTime time.Time
-	LastEndTime   time.Time
-	LastDuration  time.Duration
-	Running       bool
-	RunningJobID  string
+	Name                string
+	Type                string // "filesystem" or "directory"
+	LastStartTime       time.Time
+	LastEndTime         time.Time
+	LastDuration        time.Duration
+	Running             bool
+	RunningJobID        string
+	ConsecutiveFailures int
+	RecentErrors        []ErrorRecord // Oldest first, capped at ErrorHistorySize
+	LastCommands        [][]string    // argv of each external command run by the latest job
+	LastSizeBytes       int64         // Latest measured total size (directory groups only)
+	LastSizeTime        time.Time     // When LastSizeBytes was measured; zero if never
+	LastResult          *JobResult    // Outcome of the latest finished job; nil if none has finished
+	LastSuccessTime     time.Time     // When the latest successful job finished; zero if none has
 }
 
 // Tracker manages the state of jobs and queues
 type Tracker struct {
 	mu sync.RWMutex
 
-	// Currently running jobs
-	runningFilesystem *JobState
-	runningDirectory  *JobState
+	// Currently running jobs by queue type and job ID. Directory jobs on
+	// different devices can run side by side.
+	running map[string]map[string]*JobState
 
 	// Per-item state tracking
 	filesystemStates map[string]*ItemState
@@ -48,6 +79,10 @@ type Tracker struct {
 	filesystemQueueDepth int
 	directoryQueueDepth  int
 
+	// Channels of Subscribe, which job events are published to
+	subscribersMu sync.Mutex
+	subscribers   map[chan Event]struct{}
+
 	// Tracer for OTEL spans
 	tracer *tracing.Tracer
 }
@@ -55,13 +90,18 @@ type Tracker struct {
 // NewTracker creates a new state tracker
 func NewTracker(tracer *tracing.Tracer) *Tracker {
 	return &Tracker{
+		running: map[string]map[string]*JobState{
+			"filesystem": make(map[string]*JobState),
+			"directory":  make(map[string]*JobState),
+		},
 		filesystemStates: make(map[string]*ItemState),
 		directoryStates:  make(map[string]*ItemState),
+		subscribers:      make(map[chan Event]struct{}),
 		tracer:           tracer,
 	}
 }
 
-// SetRunningJob sets the currently running job for a queue type
+// SetRunningJob adds a job to the running jobs of a queue type
 func (t *Tracker) SetRunningJob(ctx context.Context, queueType string, job *JobState) {
 	_, span := t.startSpan(ctx, "state.set_running_job", trace.WithAttributes(
 		attribute.String("queue.type", queueType),
@@ -73,23 +113,25 @@ func (t *Tracker) SetRunningJob(ctx context.Context, queueType string, job *JobS
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	switch queueType {
-	case "filesystem":
-		t.runningFilesystem = job
-		if state, exists := t.filesystemStates[job.Name]; exists {
-			state.Running = true
-			state.RunningJobID = job.ID
-			state.LastStartTime = job.StartedAt
-		}
-	case "directory":
-		t.runningDirectory = job
-		if state, exists := t.directoryStates[job.Name]; exists {
-			state.Running = true
-			state.RunningJobID = job.ID
-			state.LastStartTime = job.StartedAt
-		}
+	if jobs, exists := t.running[queueType]; exists {
+		jobs[job.ID] = job
 	}
 
+	if state, exists := t.getItemState(queueType, job.Name); exists {
+		state.Running = true
+		state.RunningJobID = job.ID
+		state.LastStartTime = job.StartedAt
+		state.LastCommands = nil
+	}
+
+	t.publish(Event{
+		Type:     EventStarted,
+		ItemType: queueType,
+		Name:     job.Name,
+		JobID:    job.ID,
+		Time:     job.StartedAt,
+	})
+
 	span.SetAttributes(
 		attribute.String("state.queue_type", queueType),
 		attribute.String("state.job_id", job.ID),
@@ -97,7 +139,7 @@ func (t *Tracker) SetRunningJob(ctx context.Context, queueType string, job *JobS
 	span.AddEvent("job_state_updated")
 }
 
-// ClearRunningJob clears the currently running job for a queue type
+// ClearRunningJob removes a job from the running jobs of a queue type
 func (t *Tracker) ClearRunningJob(ctx context.Context, queueType string, jobID string, duration time.Duration) {
 	_, span := t.startSpan(ctx, "state.clear_running_job", trace.WithAttributes(
 		attribute.String("queue.type", queueType),
@@ -109,16 +151,8 @@ func (t *Tracker) ClearRunningJob(ctx context.Context, queueType string, jobID s
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	var jobState *JobState
-
-	switch queueType {
-	case "filesystem":
-		jobState = t.runningFilesystem
-		t.runningFilesystem = nil
-	case "directory":
-		jobState = t.runningDirectory
-		t.runningDirectory = nil
-	}
+	jobState := t.running[queueType][jobID]
+	delete(t.running[queueType], jobID)
 
 	if jobState != nil {
 		if state, exists := t.getItemState(queueType, jobState.Name); exists {
@@ -132,6 +166,50 @@ func (t *Tracker) ClearRunningJob(ctx context.Context, queueType string, jobID s
 	span.AddEvent("job_state_cleared")
 }
 
+// RecordCommand appends the argv of an external command to a running job and
+// to its item's state
+func (t *Tracker) RecordCommand(ctx context.Context, queueType string, jobID string, argv []string) {
+	_, span := t.startSpan(ctx, "state.record_command", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("job.id", jobID),
+		attribute.StringSlice("command.argv", argv),
+	))
+	defer span.End()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := t.running[queueType][jobID]
+	if job == nil {
+		return
+	}
+
+	argv = append([]string(nil), argv...)
+	job.Commands = append(job.Commands, argv)
+
+	if state, exists := t.getItemState(queueType, job.Name); exists {
+		state.LastCommands = append(state.LastCommands, argv)
+	}
+}
+
+// RecordSize stores the latest measured total size of an item
+func (t *Tracker) RecordSize(ctx context.Context, queueType string, itemName string, sizeBytes int64) {
+	_, span := t.startSpan(ctx, "state.record_size", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("item.name", itemName),
+		attribute.Int64("item.size_bytes", sizeBytes),
+	))
+	defer span.End()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state, exists := t.getItemState(queueType, itemName); exists {
+		state.LastSizeBytes = sizeBytes
+		state.LastSizeTime = time.Now()
+	}
+}
+
 // IsRunning checks if a job is currently running for an item
 func (t *Tracker) IsRunning(ctx context.Context, queueType string, itemName string) bool {
 	_, span := t.startSpan(ctx, "state.is_running", trace.WithAttributes(
@@ -203,7 +281,8 @@ func (t *Tracker) GetQueueDepth(ctx context.Context, queueType string) int {
 	return depth
 }
 
-// GetRunningJob gets the currently running job for a queue type
+// GetRunningJob gets the longest running job for a queue type, or nil when
+// the queue is idle
 func (t *Tracker) GetRunningJob(ctx context.Context, queueType string) *JobState {
 	_, span := t.startSpan(ctx, "state.get_running_job", trace.WithAttributes(
 		attribute.String("queue.type", queueType),
@@ -215,11 +294,8 @@ func (t *Tracker) GetRunningJob(ctx context.Context, queueType string) *JobState
 
 	var job *JobState
 
-	switch queueType {
-	case "filesystem":
-		job = t.runningFilesystem
-	case "directory":
-		job = t.runningDirectory
+	if jobs := t.runningJobs(queueType); len(jobs) > 0 {
+		job = jobs[0]
 	}
 
 	if job != nil {
@@ -232,6 +308,41 @@ func (t *Tracker) GetRunningJob(ctx context.Context, queueType string) *JobState
 	return job
 }
 
+// GetRunningJobs gets all running jobs for a queue type, longest running first
+func (t *Tracker) GetRunningJobs(ctx context.Context, queueType string) []*JobState {
+	_, span := t.startSpan(ctx, "state.get_running_jobs", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+	))
+	defer span.End()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	jobs := t.runningJobs(queueType)
+	span.SetAttributes(attribute.Int("state.running_jobs", len(jobs)))
+
+	return jobs
+}
+
+// runningJobs lists the running jobs of a queue type, longest running first
+// (caller must hold lock)
+func (t *Tracker) runningJobs(queueType string) []*JobState {
+	jobs := make([]*JobState, 0, len(t.running[queueType]))
+	for _, job := range t.running[queueType] {
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].StartedAt.Equal(jobs[j].StartedAt) {
+			return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+		}
+
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	return jobs
+}
+
 // GetItemState gets the state for an item
 func (t *Tracker) GetItemState(ctx context.Context, queueType string, itemName string) *ItemState {
 	_, span := t.startSpan(ctx, "state.get_item_state", trace.WithAttributes(
@@ -258,17 +369,183 @@ func (t *Tracker) GetItemState(ctx context.Context, queueType string, itemName s
 	)
 
 	// Return a copy to avoid race conditions
+	return copyItemState(state)
+}
+
+// RecordResult stores the outcome of an item's latest job and returns the
+// number of consecutive failures including it. A success resets the count but
+// keeps the error history, so recent failures remain visible after recovery.
+func (t *Tracker) RecordResult(ctx context.Context, queueType string, itemName string, result JobResult) int {
+	_, span := t.startSpan(ctx, "state.record_result", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("item.name", itemName),
+		attribute.String("job.id", result.JobID),
+		attribute.Bool("job.failed", result.Err != nil),
+	))
+	defer span.End()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if result.EndTime.IsZero() {
+		result.EndTime = time.Now()
+	}
+
+	t.publish(resultEvent(queueType, itemName, result))
+
+	state, exists := t.getItemState(queueType, itemName)
+	if !exists {
+		span.SetAttributes(attribute.Bool("state.exists", false))
+		return 0
+	}
+
+	state.LastResult = &result
+
+	if result.Err == nil {
+		state.ConsecutiveFailures = 0
+		state.LastSuccessTime = result.EndTime
+
+		return 0
+	}
+
+	state.ConsecutiveFailures++
+	state.RecentErrors = append(state.RecentErrors, ErrorRecord{
+		JobID:   result.JobID,
+		Time:    result.EndTime,
+		Message: result.Err.Error(),
+		Reason:  result.Reason,
+	})
+
+	if len(state.RecentErrors) > ErrorHistorySize {
+		state.RecentErrors = state.RecentErrors[len(state.RecentErrors)-ErrorHistorySize:]
+	}
+
+	span.SetAttributes(
+		attribute.String("job.failure_reason", result.Reason),
+		attribute.Int("state.consecutive_failures", state.ConsecutiveFailures),
+	)
+
+	return state.ConsecutiveFailures
+}
+
+// FindItems returns copies of all item states with the given name. An empty
+// queueType matches both filesystems and directories.
+func (t *Tracker) FindItems(ctx context.Context, queueType string, itemName string) []*ItemState {
+	_, span := t.startSpan(ctx, "state.find_items", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("item.name", itemName),
+	))
+	defer span.End()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var items []*ItemState
+
+	for _, candidate := range []string{"filesystem", "directory"} {
+		if queueType != "" && queueType != candidate {
+			continue
+		}
+
+		if state, exists := t.getItemState(candidate, itemName); exists {
+			items = append(items, copyItemState(state))
+		}
+	}
+
+	span.SetAttributes(attribute.Int("state.items_found", len(items)))
+
+	return items
+}
+
+// FailingItems returns copies of the states of items whose latest job failed,
+// most consecutive failures first
+func (t *Tracker) FailingItems(ctx context.Context) []*ItemState {
+	_, span := t.startSpan(ctx, "state.failing_items")
+	defer span.End()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var items []*ItemState
+
+	for _, states := range []map[string]*ItemState{t.filesystemStates, t.directoryStates} {
+		for _, state := range states {
+			if state.ConsecutiveFailures > 0 {
+				items = append(items, copyItemState(state))
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ConsecutiveFailures != items[j].ConsecutiveFailures {
+			return items[i].ConsecutiveFailures > items[j].ConsecutiveFailures
+		}
+
+		return items[i].Name < items[j].Name
+	})
+
+	span.SetAttributes(attribute.Int("state.items_found", len(items)))
+
+	return items
+}
+
+// AllItems returns copies of the states of every item, filesystems first,
+// each sorted by name
+func (t *Tracker) AllItems(ctx context.Context) []*ItemState {
+	_, span := t.startSpan(ctx, "state.all_items")
+	defer span.End()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var items []*ItemState
+
+	for _, states := range []map[string]*ItemState{t.filesystemStates, t.directoryStates} {
+		start := len(items)
+
+		for _, state := range states {
+			items = append(items, copyItemState(state))
+		}
+
+		sort.Slice(items[start:], func(i, j int) bool { return items[start+i].Name < items[start+j].Name })
+	}
+
+	span.SetAttributes(attribute.Int("state.items_found", len(items)))
+
+	return items
+}
+
+// copyItemState returns a deep copy of an item state (caller must hold lock)
+func copyItemState(state *ItemState) *ItemState {
 	return &ItemState{
-		Name:          state.Name,
-		Type:          state.Type,
-		LastStartTime: state.LastStartTime,
-		LastEndTime:   state.LastEndTime,
-		LastDuration:  state.LastDuration,
-		Running:       state.Running,
-		RunningJobID:  state.RunningJobID,
+		Name:                state.Name,
+		Type:                state.Type,
+		LastStartTime:       state.LastStartTime,
+		LastEndTime:         state.LastEndTime,
+		LastDuration:        state.LastDuration,
+		Running:             state.Running,
+		RunningJobID:        state.RunningJobID,
+		ConsecutiveFailures: state.ConsecutiveFailures,
+		RecentErrors:        append([]ErrorRecord(nil), state.RecentErrors...),
+		LastCommands:        append([][]string(nil), state.LastCommands...),
+		LastSizeBytes:       state.LastSizeBytes,
+		LastSizeTime:        state.LastSizeTime,
+		LastResult:          copyJobResult(state.LastResult),
+		LastSuccessTime:     state.LastSuccessTime,
 	}
 }
 
+// copyJobResult returns a copy of a job result, or nil
+func copyJobResult(result *JobResult) *JobResult {
+	if result == nil {
+		return nil
+	}
+
+	copied := *result
+
+	return &copied
+}
+
 // RegisterItem registers an item for state tracking
 func (t *Tracker) RegisterItem(ctx context.Context, queueType string, itemName string) {
 	_, span := t.startSpan(ctx, "state.register_item", trace.WithAttributes(
@@ -295,6 +572,27 @@ func (t *Tracker) RegisterItem(ctx context.Context, queueType string, itemName s
 	span.AddEvent("item_registered")
 }
 
+// UnregisterItem forgets an item that is no longer configured
+func (t *Tracker) UnregisterItem(ctx context.Context, queueType string, itemName string) {
+	_, span := t.startSpan(ctx, "state.unregister_item", trace.WithAttributes(
+		attribute.String("queue.type", queueType),
+		attribute.String("item.name", itemName),
+	))
+	defer span.End()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch queueType {
+	case "filesystem":
+		delete(t.filesystemStates, itemName)
+	case "directory":
+		delete(t.directoryStates, itemName)
+	}
+
+	span.AddEvent("item_unregistered")
+}
+
 // GetAllStates returns all states (for debugging/status endpoint)
 func (t *Tracker) GetAllStates(ctx context.Context) map[string]any {
 	_, span := t.startSpan(ctx, "state.get_all_states")
@@ -305,29 +603,34 @@ func (t *Tracker) GetAllStates(ctx context.Context) map[string]any {
 
 	states := make(map[string]any)
 
-	// Running jobs
+	// Running jobs: the longest running of each queue type under running,
+	// and all of them under running_jobs
 	running := make(map[string]any)
-	if t.runningFilesystem != nil {
-		running["filesystem"] = map[string]any{
-			"id":         t.runningFilesystem.ID,
-			"name":       t.runningFilesystem.Name,
-			"path":       t.runningFilesystem.Path,
-			"started_at": t.runningFilesystem.StartedAt,
-			"trace_id":   t.runningFilesystem.TraceID,
+	runningJobs := make(map[string][]map[string]any)
+
+	for _, queueType := range []string{"filesystem", "directory"} {
+		jobs := make([]map[string]any, 0, len(t.running[queueType]))
+
+		for _, job := range t.runningJobs(queueType) {
+			jobs = append(jobs, map[string]any{
+				"id":         job.ID,
+				"name":       job.Name,
+				"path":       job.Path,
+				"started_at": job.StartedAt,
+				"trace_id":   job.TraceID,
+				"commands":   append([][]string(nil), job.Commands...),
+			})
 		}
-	}
 
-	if t.runningDirectory != nil {
-		running["directory"] = map[string]any{
-			"id":         t.runningDirectory.ID,
-			"name":       t.runningDirectory.Name,
-			"path":       t.runningDirectory.Path,
-			"started_at": t.runningDirectory.StartedAt,
-			"trace_id":   t.runningDirectory.TraceID,
+		if len(jobs) > 0 {
+			running[queueType] = jobs[0]
 		}
+
+		runningJobs[queueType] = jobs
 	}
 
 	states["running"] = running
+	states["running_jobs"] = runningJobs
 
 	// Queue depths
 	states["queue_depths"] = map[string]int{
@@ -339,26 +642,32 @@ func (t *Tracker) GetAllStates(ctx context.Context) map[string]any {
 	filesystemStates := make(map[string]any)
 	for name, state := range t.filesystemStates {
 		filesystemStates[name] = map[string]any{
-			"name":           state.Name,
-			"type":           state.Type,
-			"running":        state.Running,
-			"running_job_id": state.RunningJobID,
-			"last_start":     state.LastStartTime,
-			"last_end":       state.LastEndTime,
-			"last_duration":  state.LastDuration.Seconds(),
+			"name":                 state.Name,
+			"type":                 state.Type,
+			"running":              state.Running,
+			"running_job_id":       state.RunningJobID,
+			"last_start":           state.LastStartTime,
+			"last_end":             state.LastEndTime,
+			"last_duration":        state.LastDuration.Seconds(),
+			"consecutive_failures": state.ConsecutiveFailures,
+			"last_commands":        append([][]string(nil), state.LastCommands...),
+			"last_result":          jobResultState(state.LastResult),
 		}
 	}
 
 	directoryStates := make(map[string]any)
 	for name, state := range t.directoryStates {
 		directoryStates[name] = map[string]any{
-			"name":           state.Name,
-			"type":           state.Type,
-			"running":        state.Running,
-			"running_job_id": state.RunningJobID,
-			"last_start":     state.LastStartTime,
-			"last_end":       state.LastEndTime,
-			"last_duration":  state.LastDuration.Seconds(),
+			"name":                 state.Name,
+			"type":                 state.Type,
+			"running":              state.Running,
+			"running_job_id":       state.RunningJobID,
+			"last_start":           state.LastStartTime,
+			"last_end":             state.LastEndTime,
+			"last_duration":        state.LastDuration.Seconds(),
+			"consecutive_failures": state.ConsecutiveFailures,
+			"last_commands":        append([][]string(nil), state.LastCommands...),
+			"last_result":          jobResultState(state.LastResult),
 		}
 	}
 
@@ -368,6 +677,28 @@ func (t *Tracker) GetAllStates(ctx context.Context) map[string]any {
 	return states
 }
 
+// jobResultState is how a job result appears in GetAllStates, or nil
+func jobResultState(result *JobResult) map[string]any {
+	if result == nil {
+		return nil
+	}
+
+	resultState := map[string]any{
+		"job_id":     result.JobID,
+		"end_time":   result.EndTime,
+		"duration":   result.Duration.Seconds(),
+		"size_bytes": result.SizeBytes,
+		"success":    result.Err == nil,
+	}
+
+	if result.Err != nil {
+		resultState["error"] = result.Err.Error()
+		resultState["reason"] = result.Reason
+	}
+
+	return resultState
+}
+
 // getItemState is a helper that doesn't require locking (caller must hold lock)
 func (t *Tracker) getItemState(queueType string, itemName string) (*ItemState, bool) {
 	switch queueType {
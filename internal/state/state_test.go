@@ -0,0 +1,175 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTracker_RecordResultKeepsBoundedHistory(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(nil)
+	tracker.RegisterItem(ctx, "directory", "home")
+
+	for i := 0; i < ErrorHistorySize+5; i++ {
+		failures := tracker.RecordResult(ctx, "directory", "home", JobResult{
+			JobID:  fmt.Sprintf("job-%d", i),
+			Err:    errors.New("du failed"),
+			Reason: "timeout",
+		})
+		if failures != i+1 {
+			t.Fatalf("expected %d consecutive failures, got %d", i+1, failures)
+		}
+	}
+
+	item := tracker.GetItemState(ctx, "directory", "home")
+	if len(item.RecentErrors) != ErrorHistorySize {
+		t.Fatalf("expected %d recent errors, got %d", ErrorHistorySize, len(item.RecentErrors))
+	}
+
+	if item.RecentErrors[0].JobID != "job-5" {
+		t.Errorf("expected oldest retained error to be job-5, got %s", item.RecentErrors[0].JobID)
+	}
+
+	if item.RecentErrors[0].Reason != "timeout" {
+		t.Errorf("expected the failure reason to be kept, got %q", item.RecentErrors[0].Reason)
+	}
+
+	tracker.RecordResult(ctx, "directory", "home", JobResult{JobID: "job-ok", SizeBytes: 1024})
+
+	item = tracker.GetItemState(ctx, "directory", "home")
+	if item.ConsecutiveFailures != 0 {
+		t.Errorf("expected consecutive failures to reset, got %d", item.ConsecutiveFailures)
+	}
+
+	if len(item.RecentErrors) != ErrorHistorySize {
+		t.Errorf("expected error history to survive a success, got %d entries", len(item.RecentErrors))
+	}
+
+	if item.LastResult == nil || item.LastResult.JobID != "job-ok" || item.LastResult.SizeBytes != 1024 {
+		t.Errorf("expected the successful job as the last result, got %+v", item.LastResult)
+	}
+
+	if item.LastSuccessTime.IsZero() || !item.LastSuccessTime.Equal(item.LastResult.EndTime) {
+		t.Errorf("expected the success to be timed, got %v", item.LastSuccessTime)
+	}
+}
+
+func TestTracker_FindItemsMatchesBothTypes(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(nil)
+	tracker.RegisterItem(ctx, "filesystem", "data")
+	tracker.RegisterItem(ctx, "directory", "data")
+
+	if got := len(tracker.FindItems(ctx, "", "data")); got != 2 {
+		t.Errorf("expected 2 items for empty type, got %d", got)
+	}
+
+	if got := len(tracker.FindItems(ctx, "directory", "data")); got != 1 {
+		t.Errorf("expected 1 directory item, got %d", got)
+	}
+
+	if got := len(tracker.FindItems(ctx, "", "missing")); got != 0 {
+		t.Errorf("expected no items for unknown name, got %d", got)
+	}
+}
+
+func TestTracker_RecordCommandTracksLatestJob(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(nil)
+	tracker.RegisterItem(ctx, "directory", "home")
+
+	// Commands recorded with no running job are ignored
+	tracker.RecordCommand(ctx, "directory", "job-0", []string{"du", "-s", "/tmp"})
+
+	for i, argv := range [][]string{
+		{"du", "-s", "-x", "/home"},
+		{"du", "-x", "-d", "1", "/home"},
+	} {
+		tracker.SetRunningJob(ctx, "directory", &JobState{ID: fmt.Sprintf("job-%d", i), Name: "home"})
+		tracker.RecordCommand(ctx, "directory", fmt.Sprintf("job-%d", i), argv)
+
+		if job := tracker.GetRunningJob(ctx, "directory"); len(job.Commands) != 1 {
+			t.Fatalf("expected 1 command on running job, got %v", job.Commands)
+		}
+
+		tracker.ClearRunningJob(ctx, "directory", fmt.Sprintf("job-%d", i), 0)
+	}
+
+	item := tracker.GetItemState(ctx, "directory", "home")
+	if len(item.LastCommands) != 1 || item.LastCommands[0][2] != "-d" {
+		t.Errorf("expected only the latest job's command, got %v", item.LastCommands)
+	}
+}
+
+func TestTracker_ConcurrentRunningJobs(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(nil)
+	tracker.RegisterItem(ctx, "directory", "home")
+	tracker.RegisterItem(ctx, "directory", "media")
+
+	start := time.Now()
+	tracker.SetRunningJob(ctx, "directory", &JobState{ID: "job-home", Name: "home", StartedAt: start})
+	tracker.SetRunningJob(ctx, "directory", &JobState{ID: "job-media", Name: "media", StartedAt: start.Add(time.Second)})
+
+	// Commands go to the job that ran them
+	tracker.RecordCommand(ctx, "directory", "job-media", []string{"du", "-s", "/media"})
+
+	jobs := tracker.GetRunningJobs(ctx, "directory")
+	if len(jobs) != 2 || jobs[0].ID != "job-home" || jobs[1].ID != "job-media" {
+		t.Fatalf("expected both jobs, longest running first, got %v", jobs)
+	}
+
+	if len(jobs[0].Commands) != 0 || len(jobs[1].Commands) != 1 {
+		t.Errorf("expected the command on job-media only, got %v and %v", jobs[0].Commands, jobs[1].Commands)
+	}
+
+	tracker.ClearRunningJob(ctx, "directory", "job-home", time.Minute)
+
+	if job := tracker.GetRunningJob(ctx, "directory"); job == nil || job.ID != "job-media" {
+		t.Errorf("expected job-media to still be running, got %v", job)
+	}
+
+	if !tracker.IsRunning(ctx, "directory", "media") || tracker.IsRunning(ctx, "directory", "home") {
+		t.Error("expected only media to be running")
+	}
+}
+
+func TestTracker_SubscribePublishesJobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(nil)
+	tracker.RegisterItem(ctx, "directory", "home")
+
+	events, unsubscribe := tracker.Subscribe()
+
+	tracker.RecordQueued(ctx, "directory", "job-1", "home")
+	tracker.SetRunningJob(ctx, "directory", &JobState{ID: "job-1", Type: "directory", Name: "home", StartedAt: time.Now()})
+	tracker.RecordResult(ctx, "directory", "home", JobResult{JobID: "job-1", Duration: 2 * time.Second})
+	tracker.RecordResult(ctx, "directory", "home", JobResult{JobID: "job-2", Duration: time.Second, Err: errors.New("du failed"), Reason: "timeout"})
+
+	expected := []Event{
+		{Type: EventQueued, JobID: "job-1"},
+		{Type: EventStarted, JobID: "job-1"},
+		{Type: EventFinished, JobID: "job-1", DurationSeconds: 2},
+		{Type: EventFailed, JobID: "job-2", DurationSeconds: 1, Error: "du failed", Reason: "timeout"},
+	}
+
+	for _, want := range expected {
+		got := <-events
+		if got.Type != want.Type || got.JobID != want.JobID || got.ItemType != "directory" || got.Name != "home" ||
+			got.DurationSeconds != want.DurationSeconds || got.Error != want.Error || got.Reason != want.Reason {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	}
+
+	unsubscribe()
+	tracker.RecordQueued(ctx, "directory", "job-3", "home")
+
+	select {
+	case event := <-events:
+		t.Errorf("expected no events after unsubscribing, got %+v", event)
+	default:
+	}
+}
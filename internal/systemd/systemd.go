@@ -0,0 +1,166 @@
+// Package systemd tells systemd how the exporter is doing over the sd_notify
+// protocol, when it runs as a Type=notify service
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LivenessChecker reports whether collection is still making progress, as
+// the coordinator does
+type LivenessChecker interface {
+	CheckLiveness(ctx context.Context) error
+}
+
+// Notifier sends READY=1 once collection has started and, with WatchdogSec,
+// WATCHDOG=1 while the liveness check passes, so systemd restarts an
+// exporter that is stuck. It must be started after the coordinator, or
+// READY=1 would be sent before anything is being collected.
+type Notifier struct {
+	socket   string        // $NOTIFY_SOCKET; empty when not run by systemd
+	watchdog time.Duration // WatchdogSec; zero when disabled
+	liveness LivenessChecker
+
+	wg sync.WaitGroup
+}
+
+// NewNotifier creates a notifier from the environment systemd passes. It
+// unsets those variables so the commands the exporter runs can't notify
+// systemd as though they were the exporter.
+func NewNotifier(liveness LivenessChecker) *Notifier {
+	n := &Notifier{
+		socket:   os.Getenv("NOTIFY_SOCKET"),
+		liveness: liveness,
+	}
+
+	n.watchdog = watchdogInterval(os.Getenv("WATCHDOG_USEC"), os.Getenv("WATCHDOG_PID"), os.Getpid())
+
+	for _, name := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+		_ = os.Unsetenv(name)
+	}
+
+	return n
+}
+
+// Enabled reports whether the exporter was started by systemd with a
+// notification socket
+func (n *Notifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// Start sends READY=1 and starts the watchdog loop. It does nothing when not
+// run by systemd.
+func (n *Notifier) Start(ctx context.Context) {
+	if !n.Enabled() {
+		return
+	}
+
+	if err := n.notify("READY=1\nSTATUS=Collecting"); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+		return
+	}
+
+	slog.Info("Notified systemd of readiness", "watchdog", n.watchdog)
+
+	n.wg.Add(1)
+
+	go func() {
+		defer n.wg.Done()
+		n.run(ctx)
+	}()
+}
+
+// Stop blocks until the watchdog loop has exited after ctx was cancelled
+func (n *Notifier) Stop() {
+	n.wg.Wait()
+}
+
+// run pings the watchdog at half its interval while the liveness check
+// passes, and sends STOPPING=1 once ctx is cancelled
+func (n *Notifier) run(ctx context.Context) {
+	var tick <-chan time.Time
+
+	if n.watchdog > 0 {
+		ticker := time.NewTicker(n.watchdog / 2)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := n.notify("STOPPING=1"); err != nil {
+				slog.Warn("Failed to notify systemd", "error", err)
+			}
+
+			return
+		case <-tick:
+			n.ping(ctx)
+		}
+	}
+}
+
+// ping sends WATCHDOG=1 if collection is live. Otherwise it skips the ping,
+// so systemd restarts the exporter once WatchdogSec passes without one.
+func (n *Notifier) ping(ctx context.Context) {
+	if err := n.liveness.CheckLiveness(ctx); err != nil {
+		slog.Error("Liveness check failed, withholding the systemd watchdog ping", "error", err)
+
+		// Each line of a notification is an assignment
+		if err := n.notify("STATUS=Stuck: " + strings.ReplaceAll(err.Error(), "\n", "; ")); err != nil {
+			slog.Warn("Failed to notify systemd", "error", err)
+		}
+
+		return
+	}
+
+	if err := n.notify("WATCHDOG=1\nSTATUS=Collecting"); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
+}
+
+// notify sends a datagram of newline separated assignments to the
+// notification socket. An address starting with @ is in the abstract
+// namespace, which the net package handles.
+func (n *Notifier) notify(state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", n.socket, err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", n.socket, err)
+	}
+
+	return nil
+}
+
+// watchdogInterval parses WatchdogSec from $WATCHDOG_USEC. It is zero when
+// the variable is unset or invalid, or $WATCHDOG_PID names another process.
+func watchdogInterval(usec, watchdogPID string, pid int) time.Duration {
+	if usec == "" {
+		return 0
+	}
+
+	if watchdogPID != "" && watchdogPID != strconv.Itoa(pid) {
+		return 0
+	}
+
+	micros, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || micros <= 0 {
+		return 0
+	}
+
+	return time.Duration(micros) * time.Microsecond
+}
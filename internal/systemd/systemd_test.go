@@ -0,0 +1,148 @@
+//go:build !windows
+
+package systemd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLiveness struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (f *fakeLiveness) CheckLiveness(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+func (f *fakeLiveness) fail(err error) {
+	f.mu.Lock()
+	f.err = err
+	f.mu.Unlock()
+}
+
+// listen creates a notification socket and points $NOTIFY_SOCKET at it
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 4096)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive a notification: %v", err)
+	}
+
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	liveness := &fakeLiveness{}
+	notifier := NewNotifier(liveness)
+
+	if os.Getenv("NOTIFY_SOCKET") != "" || os.Getenv("WATCHDOG_USEC") != "" {
+		t.Error("Expected the systemd variables to be unset for child processes")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifier.Start(ctx)
+
+	if got := receive(t, conn); got != "READY=1\nSTATUS=Collecting" {
+		t.Errorf("Expected readiness first, got %q", got)
+	}
+
+	if got := receive(t, conn); got != "WATCHDOG=1\nSTATUS=Collecting" {
+		t.Errorf("Expected a watchdog ping, got %q", got)
+	}
+
+	liveness.fail(errors.Join(errors.New("directory worker stuck on home for 2h0m0s"), errors.New("filesystem root not scheduled for 5m0s")))
+
+	// A ping may have been sent before the check started failing
+	got := receive(t, conn)
+	if got == "WATCHDOG=1\nSTATUS=Collecting" {
+		got = receive(t, conn)
+	}
+
+	if want := "STATUS=Stuck: directory worker stuck on home for 2h0m0s; filesystem root not scheduled for 5m0s"; got != want {
+		t.Errorf("Expected %q while stuck, got %q", want, got)
+	}
+
+	cancel()
+
+	for got := receive(t, conn); got != "STOPPING=1"; got = receive(t, conn) {
+		if got != "STATUS=Stuck: directory worker stuck on home for 2h0m0s; filesystem root not scheduled for 5m0s" {
+			t.Fatalf("Expected STOPPING=1 after cancelling, got %q", got)
+		}
+	}
+
+	notifier.Stop()
+}
+
+func TestNotifier_NotUnderSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	notifier := NewNotifier(&fakeLiveness{})
+	if notifier.Enabled() {
+		t.Fatal("Expected the notifier to be disabled without $NOTIFY_SOCKET")
+	}
+
+	notifier.Start(context.Background())
+	notifier.Stop()
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		usec        string
+		watchdogPID string
+		want        time.Duration
+	}{
+		{name: "unset", want: 0},
+		{name: "any process", usec: "30000000", want: 30 * time.Second},
+		{name: "this process", usec: "30000000", watchdogPID: "42", want: 30 * time.Second},
+		{name: "another process", usec: "30000000", watchdogPID: "43", want: 0},
+		{name: "invalid", usec: "soon", want: 0},
+		{name: "zero", usec: "0", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := watchdogInterval(tt.usec, tt.watchdogPID, 42); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
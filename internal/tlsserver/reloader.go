@@ -0,0 +1,190 @@
+// Package tlsserver loads the certificate the exporter serves HTTPS with and
+// reloads it when its files change
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+)
+
+// credentials is one loaded set of certificate files
+type credentials struct {
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool // nil unless client certificates are required
+	versions    string         // Size and modification time of every file
+}
+
+// Reloader holds the current certificate and swaps it when the files on disk
+// change. Connections already established keep the certificate they were
+// made with; only new handshakes see a reloaded one.
+type Reloader struct {
+	config  config.TLSConfig
+	metrics *metrics.FilesystemRegistry
+
+	current atomic.Pointer[credentials]
+	wg      sync.WaitGroup
+}
+
+// NewReloader loads the configured certificate, key and client CA. Errors
+// here are fatal; once running, a failed reload keeps the old certificate.
+func NewReloader(cfg config.TLSConfig, m *metrics.FilesystemRegistry) (*Reloader, error) {
+	r := &Reloader{config: cfg, metrics: m}
+
+	creds, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	r.store(creds)
+
+	return r, nil
+}
+
+// TLSConfig returns a server config that picks up reloaded certificates on
+// the next handshake
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		creds := r.current.Load()
+
+		// The returned config replaces the base one for the handshake, so
+		// without the base's protocols HTTP/2 would never be negotiated
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*creds.certificate},
+			NextProtos:   base.NextProtos,
+		}
+
+		if creds.clientCAs != nil {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			cfg.ClientCAs = creds.clientCAs
+		}
+
+		return cfg, nil
+	}
+
+	return base
+}
+
+// Start starts checking the files for changes until ctx is cancelled
+func (r *Reloader) Start(ctx context.Context) {
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+}
+
+// Stop blocks until the check loop has exited after ctx was cancelled
+func (r *Reloader) Stop() {
+	r.wg.Wait()
+}
+
+// run polls the files rather than watching them, which also catches the
+// symlink swaps Kubernetes uses to update mounted secrets
+func (r *Reloader) run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.ReloadInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload loads the files again if any of them changed since the last load
+func (r *Reloader) reload() {
+	versions, err := r.versions()
+	if err != nil || versions == r.current.Load().versions {
+		// A missing file is usually a renewal in progress; the next check
+		// reports it if it persists
+		return
+	}
+
+	creds, err := r.load()
+	if err != nil {
+		r.metrics.TLSReloadsCounter.WithLabelValues("failure").Inc()
+		slog.Error("Failed to reload TLS certificate, keeping the current one", "cert_file", r.config.CertFile, "error", err)
+
+		return
+	}
+
+	r.store(creds)
+	r.metrics.TLSReloadsCounter.WithLabelValues("success").Inc()
+	slog.Info("Reloaded TLS certificate", "cert_file", r.config.CertFile, "expires", creds.certificate.Leaf.NotAfter)
+}
+
+// load reads and parses every configured file
+func (r *Reloader) load() (*credentials, error) {
+	// Stat first so a change made while loading is picked up next time
+	versions, err := r.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	creds := &credentials{certificate: &certificate, versions: versions}
+
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+
+		creds.clientCAs = x509.NewCertPool()
+		if !creds.clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", r.config.ClientCAFile)
+		}
+	}
+
+	return creds, nil
+}
+
+// store makes creds the certificate served from the next handshake
+func (r *Reloader) store(creds *credentials) {
+	r.current.Store(creds)
+	r.metrics.TLSCertificateExpiry.Set(float64(creds.certificate.Leaf.NotAfter.Unix()))
+}
+
+// versions identifies the current contents of the configured files
+func (r *Reloader) versions() (string, error) {
+	var versions string
+
+	for _, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		versions += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,198 @@
+package tlsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+)
+
+// writeCertificate writes a self-signed certificate and key for 127.0.0.1
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestServer(t *testing.T, cfg config.TLSConfig) (*Reloader, *httptest.Server) {
+	t.Helper()
+
+	registry := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+
+	reloader, err := NewReloader(cfg, registry)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return reloader, server
+}
+
+// servedName connects to server and returns the common name it presents
+func servedName(t *testing.T, server *httptest.Server, clientCert *tls.Certificate) (string, error) {
+	t.Helper()
+
+	cfg := &tls.Config{InsecureSkipVerify: true} //nolint:gosec // Self-signed test certificates
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), cfg)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// TLS 1.3 reports a rejected client certificate after the handshake
+	if _, err := conn.Write([]byte("GET / HTTP/1.0\r\n\r\n")); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return "", err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "first")
+
+	reloader, server := newTestServer(t, cfg)
+
+	if name, err := servedName(t, server, nil); err != nil || name != "first" {
+		t.Fatalf("expected the first certificate, got %q (%v)", name, err)
+	}
+
+	// Unchanged files aren't reloaded
+	before := reloader.current.Load()
+	reloader.reload()
+
+	if reloader.current.Load() != before {
+		t.Error("expected unchanged files to keep the loaded certificate")
+	}
+
+	// A broken renewal keeps the old certificate
+	if err := os.WriteFile(cfg.CertFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader.reload()
+
+	if name, err := servedName(t, server, nil); err != nil || name != "first" {
+		t.Fatalf("expected a failed reload to keep the first certificate, got %q (%v)", name, err)
+	}
+
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "second")
+	reloader.reload()
+
+	if name, err := servedName(t, server, nil); err != nil || name != "second" {
+		t.Fatalf("expected the renewed certificate, got %q (%v)", name, err)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.TLSConfig{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "client.crt"),
+	}
+
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "server")
+	writeCertificate(t, cfg.ClientCAFile, filepath.Join(dir, "client.key"), "client")
+
+	_, server := newTestServer(t, cfg)
+
+	if _, err := servedName(t, server, nil); err == nil {
+		t.Error("expected a connection without a client certificate to be rejected")
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(cfg.ClientCAFile, filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := servedName(t, server, &clientCert); err != nil || name != "server" {
+		t.Errorf("expected a trusted client certificate to be accepted, got %q (%v)", name, err)
+	}
+}
+
+func TestNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+
+	writeCertificate(t, cfg.CertFile, cfg.KeyFile, "server")
+
+	_, server := newTestServer(t, cfg)
+
+	for _, protocol := range []string{"h2", "http/1.1"} {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true, //nolint:gosec // Self-signed test certificates
+			NextProtos:         []string{protocol},
+		})
+		if err != nil {
+			t.Fatalf("handshake offering %s failed: %v", protocol, err)
+		}
+
+		if negotiated := conn.ConnectionState().NegotiatedProtocol; negotiated != protocol {
+			t.Errorf("expected %s to be negotiated, got %q", protocol, negotiated)
+		}
+
+		conn.Close()
+	}
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+
+	"filesystem-exporter/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// redacted replaces paths inside other values when paths are dropped
+const redacted = "[redacted]"
+
+// embeddedPath matches an absolute path inside a longer value, such as an
+// error message, after the start or a separator. A path ends at whitespace,
+// quotes, brackets and the colon error messages follow it with, so the rest
+// of a path containing a space is kept.
+var embeddedPath = regexp.MustCompile(`(^|[\s"'=(\[])((?:/|[A-Za-z]:\\)[^\s"'()\[\],:;]*)`)
+
+// scrubbingExporter removes paths, which often hold user names, from spans
+// before passing them on: attributes whose value is a path, and paths inside
+// other attributes, event attributes and error descriptions
+type scrubbingExporter struct {
+	sdktrace.SpanExporter
+	mode string // config.TracingScrubHash or config.TracingScrubDrop
+}
+
+// scrubbedSpan is a span with its paths scrubbed
+type scrubbedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+	status     sdktrace.Status
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *scrubbingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	scrubbed := make([]sdktrace.ReadOnlySpan, len(spans))
+
+	for i, span := range spans {
+		events := make([]sdktrace.Event, len(span.Events()))
+		for j, event := range span.Events() {
+			event.Attributes = e.scrubAttributes(event.Attributes)
+			events[j] = event
+		}
+
+		status := span.Status()
+		status.Description = e.scrubText(status.Description)
+
+		scrubbed[i] = &scrubbedSpan{
+			ReadOnlySpan: span,
+			attributes:   e.scrubAttributes(span.Attributes()),
+			events:       events,
+			status:       status,
+		}
+	}
+
+	return e.SpanExporter.ExportSpans(ctx, scrubbed)
+}
+
+// scrubAttributes returns attrs with their paths scrubbed
+func (e *scrubbingExporter) scrubAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	scrubbed := make([]attribute.KeyValue, 0, len(attrs))
+
+	for _, attr := range attrs {
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			value := attr.Value.AsString()
+			if isPath(value) || filepath.IsAbs(value) {
+				if e.mode == config.TracingScrubDrop {
+					continue
+				}
+
+				attr.Value = attribute.StringValue(hashPath(value))
+			} else {
+				attr.Value = attribute.StringValue(e.scrubText(value))
+			}
+
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for i, value := range values {
+				values[i] = e.scrubText(value)
+			}
+
+			attr.Value = attribute.StringSliceValue(values)
+		}
+
+		scrubbed = append(scrubbed, attr)
+	}
+
+	return scrubbed
+}
+
+// scrubText hashes or redacts each path in s
+func (e *scrubbingExporter) scrubText(s string) string {
+	return embeddedPath.ReplaceAllStringFunc(s, func(match string) string {
+		groups := embeddedPath.FindStringSubmatch(match)
+
+		if e.mode == config.TracingScrubDrop {
+			return groups[1] + redacted
+		}
+
+		return groups[1] + hashPath(groups[2])
+	})
+}
+
+// isPath reports whether the whole of s is one path
+func isPath(s string) bool {
+	loc := embeddedPath.FindStringSubmatchIndex(s)
+
+	return loc != nil && loc[4] == 0 && loc[5] == len(s)
+}
+
+// hashPath replaces a path with a short hash of it, so spans about the same
+// path can still be matched up
+func hashPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Attributes implements sdktrace.ReadOnlySpan
+func (s *scrubbedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+// Events implements sdktrace.ReadOnlySpan
+func (s *scrubbedSpan) Events() []sdktrace.Event {
+	return s.events
+}
+
+// Status implements sdktrace.ReadOnlySpan
+func (s *scrubbedSpan) Status() sdktrace.Status {
+	return s.status
+}
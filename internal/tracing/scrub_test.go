@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"filesystem-exporter/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// exportSpan records one span with paths in it and returns it as exported
+func exportSpan(t *testing.T, scrub string) tracetest.SpanStub {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := newProvider(&config.Config{TracingSampleRatio: 1, TracingScrubPaths: scrub}, exporter)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "directory.walk")
+	span.SetAttributes(
+		attribute.String("directory.path", "/home/alice"),
+		attribute.String("directory.name", "home"),
+		attribute.String("retry.error", "lstat /home/alice/.cache: permission denied"),
+		attribute.StringSlice("command.argv", []string{"du", "-s", "/home/alice"}),
+	)
+	span.RecordError(errors.New("open /home/alice/secret: permission denied"))
+	span.SetStatus(codes.Error, "walk of /home/alice failed")
+	span.End()
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+
+	return spans[0]
+}
+
+// allText joins every value of a span that could hold a path
+func allText(span tracetest.SpanStub) string {
+	var values []string
+
+	for _, attr := range span.Attributes {
+		values = append(values, string(attr.Key)+"="+attr.Value.Emit())
+	}
+
+	for _, event := range span.Events {
+		for _, attr := range event.Attributes {
+			values = append(values, string(attr.Key)+"="+attr.Value.Emit())
+		}
+	}
+
+	return strings.Join(append(values, span.Status.Description), "\n")
+}
+
+func TestScrub(t *testing.T) {
+	if text := allText(exportSpan(t, "")); strings.Count(text, "/home/alice") != 5 {
+		t.Errorf("expected paths kept without scrubbing, got:\n%s", text)
+	}
+
+	hashed := exportSpan(t, config.TracingScrubHash)
+	if text := allText(hashed); strings.Contains(text, "alice") {
+		t.Errorf("expected no paths, got:\n%s", text)
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range hashed.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+
+	path := attrs["directory.path"].AsString()
+	if !strings.HasPrefix(path, "sha256:") || attrs["command.argv"].AsStringSlice()[2] != path {
+		t.Errorf("expected the same path hashed the same way, got %q and %v", path, attrs["command.argv"].AsStringSlice())
+	}
+
+	if attrs["directory.name"].AsString() != "home" {
+		t.Errorf("expected other attributes kept, got %q", attrs["directory.name"].AsString())
+	}
+
+	if msg := attrs["retry.error"].AsString(); !strings.HasPrefix(msg, "lstat sha256:") || !strings.HasSuffix(msg, ": permission denied") {
+		t.Errorf("expected the path in the error hashed, got %q", msg)
+	}
+
+	dropped := exportSpan(t, config.TracingScrubDrop)
+	if text := allText(dropped); strings.Contains(text, "alice") || strings.Contains(text, "sha256:") {
+		t.Errorf("expected paths dropped, got:\n%s", text)
+	}
+
+	for _, attr := range dropped.Attributes {
+		if attr.Key == "directory.path" {
+			t.Errorf("expected the path attribute dropped, got %q", attr.Value.AsString())
+		}
+	}
+
+	if dropped.Status.Description != "walk of [redacted] failed" {
+		t.Errorf("expected the path in the status redacted, got %q", dropped.Status.Description)
+	}
+}
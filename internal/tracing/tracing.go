@@ -0,0 +1,128 @@
+// Package tracing exports the exporter's spans through a trace provider of
+// its own. promexporter's always samples and exports attributes as they are,
+// while this one keeps tracing.sample_ratio of traces and can scrub paths
+// from spans before they leave the process.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// shutdownTimeout bounds exporting the spans still buffered at shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Tracer starts the exporter's spans. The zero value is disabled.
+type Tracer struct {
+	tracer   trace.Tracer
+	provider *sdktrace.TracerProvider
+}
+
+// NewTracer sets up tracing as configured, making its provider the global
+// one. It returns a disabled tracer when tracing is disabled.
+func NewTracer(cfg *config.Config) (*Tracer, error) {
+	if !cfg.Tracing.IsEnabled() {
+		return &Tracer{}, nil
+	}
+
+	endpoint, err := url.Parse(cfg.Tracing.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint URL: %w", err)
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(endpoint.Path),
+		otlptracehttp.WithHeaders(cfg.Tracing.Headers),
+	}
+	if endpoint.Scheme != "https" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.New(context.Background(), resource.WithAttributes(
+		attribute.String("service.name", cfg.Tracing.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	provider := newProvider(cfg, exporter, sdktrace.WithResource(res))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	slog.Info("Exporting collection traces",
+		"endpoint", cfg.Tracing.Endpoint,
+		"sample_ratio", cfg.TracingSampleRatio,
+		"scrub_paths", cfg.TracingScrubPaths)
+
+	return &Tracer{
+		tracer:   provider.Tracer(cfg.Tracing.ServiceName),
+		provider: provider,
+	}, nil
+}
+
+// newProvider creates a provider sampling and scrubbing as configured, and
+// exporting to exporter. A trace continued from a caller keeps the caller's
+// sampling decision.
+func newProvider(cfg *config.Config, exporter sdktrace.SpanExporter, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	if cfg.TracingScrubPaths != "" {
+		exporter = &scrubbingExporter{SpanExporter: exporter, mode: cfg.TracingScrubPaths}
+	}
+
+	return sdktrace.NewTracerProvider(append(opts,
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)...)
+}
+
+// IsEnabled reports whether spans are exported
+func (t *Tracer) IsEnabled() bool {
+	return t.provider != nil
+}
+
+// StartSpan starts a span, or returns ctx's span when tracing is disabled
+func (t *Tracer) StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !t.IsEnabled() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return t.tracer.Start(ctx, name, opts...)
+}
+
+// Start implements app.Collector; there is nothing to start
+func (t *Tracer) Start(ctx context.Context) {}
+
+// Stop exports the spans still buffered, so those of the shutdown are kept
+func (t *Tracer) Stop() {
+	if !t.IsEnabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := t.provider.Shutdown(ctx); err != nil {
+		slog.Error("Failed to export the remaining spans", "error", err)
+	}
+}
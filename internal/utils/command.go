@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// localeEnv forces the C locale so command output is parseable regardless of
+// the host's language settings. Later entries override inherited ones.
+var localeEnv = []string{"LC_ALL=C", "LANG=C"}
+
+// commandWaitDelay bounds how long a killed command's output is waited for,
+// in case something it spawned still holds its pipes open
+const commandWaitDelay = 5 * time.Second
+
+// CommandContext is exec.CommandContext with the C locale forced for the
+// child process. Every external command whose output is parsed must be
+// started through it.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), localeEnv...)
+	cmd.WaitDelay = commandWaitDelay
+
+	return cmd
+}
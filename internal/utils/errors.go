@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// Reasons a collection failed, the reason label of collection_failed_total
+const (
+	FailureReasonTimeout    = "timeout"     // A command or walk ran out of time
+	FailureReasonPermission = "permission"  // Access to a path was denied
+	FailureReasonNotFound   = "not_found"   // A path or command doesn't exist
+	FailureReasonNotMounted = "not_mounted" // Nothing is mounted on a mount point
+	FailureReasonParse      = "parse"       // A command's output couldn't be understood
+	FailureReasonPanic      = "panic"       // The collection panicked
+	FailureReasonOther      = "other"
+)
+
+var (
+	// ErrTimeout marks errors from commands killed for running past their
+	// timeout, which exec reports as a plain signal
+	ErrTimeout = errors.New("timed out")
+
+	// ErrParse marks errors from command output that couldn't be parsed
+	ErrParse = errors.New("unexpected output")
+
+	// ErrPermission marks errors from paths access was denied to. It is
+	// fs.ErrPermission, so the errors os returns match it already.
+	ErrPermission = fs.ErrPermission
+
+	// ErrNotMounted marks errors from mount points with nothing mounted on
+	// them
+	ErrNotMounted = errors.New("not mounted")
+)
+
+// ClassifyFailure returns the reason a collection failed with err, from the
+// error chain or, for commands that exited with an error, their stderr. The
+// C locale commands are run with keeps the messages in English.
+func ClassifyFailure(err error) string {
+	switch {
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return FailureReasonTimeout
+	case errors.Is(err, ErrParse):
+		return FailureReasonParse
+	case errors.As(err, new(*PanicError)):
+		return FailureReasonPanic
+	case errors.Is(err, ErrPermission):
+		return FailureReasonPermission
+	case errors.Is(err, ErrNotMounted):
+		return FailureReasonNotMounted
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, exec.ErrNotFound):
+		return FailureReasonNotFound
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stderr := string(exitErr.Stderr)
+
+		switch {
+		case strings.Contains(stderr, "Permission denied"), strings.Contains(stderr, "Operation not permitted"):
+			return FailureReasonPermission
+		case strings.Contains(stderr, "No such file or directory"):
+			return FailureReasonNotFound
+		}
+	}
+
+	return FailureReasonOther
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("du command failed: %w", ErrTimeout), FailureReasonTimeout},
+		{fmt.Errorf("directory walk failed: %w", context.DeadlineExceeded), FailureReasonTimeout},
+		{fmt.Errorf("parse df output failed: %w from df", ErrParse), FailureReasonParse},
+		{&fs.PathError{Op: "stat", Path: "/root", Err: fs.ErrPermission}, FailureReasonPermission},
+		{fmt.Errorf("path does not exist: %w", fs.ErrNotExist), FailureReasonNotFound},
+		{&exec.Error{Name: "du", Err: exec.ErrNotFound}, FailureReasonNotFound},
+		{&exec.ExitError{Stderr: []byte("du: cannot read directory '/data/private': Permission denied\n")}, FailureReasonPermission},
+		{&exec.ExitError{Stderr: []byte("du: cannot access '/data/gone': No such file or directory\n")}, FailureReasonNotFound},
+		{fmt.Errorf("/var/lib/kubelet/pods/x is %w", ErrNotMounted), FailureReasonNotMounted},
+		{fmt.Errorf("open quota file: %w", ErrPermission), FailureReasonPermission},
+		{&PanicError{Value: "index out of range"}, FailureReasonPanic},
+		{errors.New("zpool is faulted"), FailureReasonOther},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyFailure(tt.err); got != tt.want {
+			t.Errorf("ClassifyFailure(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
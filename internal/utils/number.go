@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// digitGroupSeparators are the thousands separators used by common locales.
+// Space-like separators are not handled as callers split output on whitespace.
+var digitGroupSeparators = []string{",", ".", "'", "’"}
+
+// ParseLocalizedInt parses a non-negative integer from command output,
+// tolerating thousands separators such as "1,234,567", "1.234.567" or
+// "1'234'567". Separators are only accepted between well-formed groups of
+// three digits, so "1,5" or "12.34" are still rejected.
+func ParseLocalizedInt(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	if value, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return value, nil
+	}
+
+	for _, sep := range digitGroupSeparators {
+		if !strings.Contains(s, sep) {
+			continue
+		}
+
+		groups := strings.Split(s, sep)
+		if !validDigitGroups(groups) {
+			break
+		}
+
+		return strconv.ParseInt(strings.Join(groups, ""), 10, 64)
+	}
+
+	return 0, fmt.Errorf("invalid integer '%s'", s)
+}
+
+// validDigitGroups reports whether groups form a correctly grouped number:
+// a leading group of 1-3 digits followed by groups of exactly 3 digits
+func validDigitGroups(groups []string) bool {
+	for i, group := range groups {
+		if group == "" || len(group) > 3 || (i > 0 && len(group) != 3) {
+			return false
+		}
+
+		for _, r := range group {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ParseLocalizedFloat parses a decimal number that may use a decimal comma,
+// e.g. "1,50" as printed by some locales for "1.50"
+func ParseLocalizedFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	if !strings.Contains(s, ".") && strings.Count(s, ",") == 1 {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", s)
+	}
+
+	return value, nil
+}
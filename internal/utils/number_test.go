@@ -0,0 +1,65 @@
+package utils
+
+import "testing"
+
+func TestParseLocalizedInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"1234567", 1234567, false},
+		{" 42 ", 42, false},
+		{"1,234,567", 1234567, false},
+		{"1.234.567", 1234567, false},
+		{"1'234'567", 1234567, false},
+		{"1’234", 1234, false},
+		{"999", 999, false},
+		{"1,5", 0, true},
+		{"12.34", 0, true},
+		{"1,2345", 0, true},
+		{",123", 0, true},
+		{"1,234.567", 0, true},
+		{"12K", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		value, err := ParseLocalizedInt(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLocalizedInt(%q) expected error, got %d", tt.input, value)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseLocalizedInt(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+
+		if value != tt.expected {
+			t.Errorf("ParseLocalizedInt(%q) = %d, expected %d", tt.input, value, tt.expected)
+		}
+	}
+}
+
+func TestParseLocalizedFloat(t *testing.T) {
+	tests := map[string]float64{
+		"1.50": 1.5,
+		"1,50": 1.5,
+		"2":    2,
+	}
+
+	for input, expected := range tests {
+		value, err := ParseLocalizedFloat(input)
+		if err != nil || value != expected {
+			t.Errorf("ParseLocalizedFloat(%q) = %g, %v, expected %g", input, value, err, expected)
+		}
+	}
+
+	if _, err := ParseLocalizedFloat("1,234,5"); err == nil {
+		t.Error("expected error for ambiguous input")
+	}
+}
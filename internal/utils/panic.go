@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PanicError is a panic recovered by RecoverPanic
+type PanicError struct {
+	Value any    // The value passed to panic
+	Stack []byte // The stack of the goroutine that panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// RecoverPanic must be deferred directly. It recovers a panic in component,
+// logs it with its stack, records it to span (which may be nil) and counts
+// it in panics, so one bad path can't take down the exporter. With errp, the
+// panic is returned as a *PanicError through the caller's named error.
+func RecoverPanic(span trace.Span, panics *prometheus.CounterVec, component string, errp *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	err := &PanicError{Value: recovered, Stack: debug.Stack()}
+
+	slog.Error("Recovered from panic", "component", component, "panic", recovered, "stack", string(err.Stack))
+
+	if span != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	panics.WithLabelValues(component).Inc()
+
+	if errp != nil {
+		*errp = err
+	}
+}
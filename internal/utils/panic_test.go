@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "panics_total"}, []string{"component"})
+
+	collect := func() (err error) {
+		defer RecoverPanic(nil, panics, "directory_worker", &err)
+
+		var sizes map[string]int64
+		sizes["/data"] = 1 // Writing to a nil map panics
+
+		return nil
+	}
+
+	err := collect()
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected the panic as a *PanicError, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "assignment to entry in nil map") {
+		t.Errorf("Expected the panic value in the error, got %q", err)
+	}
+
+	if !strings.Contains(string(panicErr.Stack), "TestRecoverPanic") {
+		t.Errorf("Expected the stack of the panic, got %s", panicErr.Stack)
+	}
+
+	if got := testutil.ToFloat64(panics.WithLabelValues("directory_worker")); got != 1 {
+		t.Errorf("Expected 1 panic counted, got %v", got)
+	}
+
+	// Without a panic nothing is counted and the error is left alone
+	func() {
+		defer RecoverPanic(nil, panics, "scheduler", nil)
+	}()
+
+	if got := testutil.ToFloat64(panics.WithLabelValues("scheduler")); got != 0 {
+		t.Errorf("Expected no panics counted, got %v", got)
+	}
+}
@@ -13,82 +13,152 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
-// RetryWithBackoff implements exponential backoff retry logic with OpenTelemetry tracing
-func RetryWithBackoff(ctx context.Context, operation func() error, maxRetries int, initialDelay time.Duration) error {
+// RetryPolicy is how RetryWithBackoff retries a failed operation
+type RetryPolicy struct {
+	MaxAttempts  int           // Attempts in all, including the first; 1 or less never retries
+	InitialDelay time.Duration // Wait before the first retry
+	MaxDelay     time.Duration // Longest wait between attempts; zero for no limit
+	Multiplier   float64       // Growth of the wait after each retry; below 1 keeps it constant
+
+	// OnRetry, if set, is called with the attempt that failed and its error
+	// before each retry, to count retries
+	OnRetry func(attempt int, err error)
+}
+
+// nextDelay is the wait after delay, grown by the multiplier up to the maximum
+func (p RetryPolicy) nextDelay(delay time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		delay = time.Duration(float64(delay) * p.Multiplier)
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// RetryWithBackoff implements exponential backoff retry logic with OpenTelemetry
+// tracing. It stops retrying as soon as ctx is done, or when waiting for the
+// next attempt would outlast ctx's deadline; the error it returns then wraps
+// ctx's error as well as the last attempt's, so a job that ran out of time
+// counts as a timeout.
+func RetryWithBackoff(ctx context.Context, operation func() error, policy RetryPolicy) error {
+	if policy.MaxAttempts <= 1 {
+		return operation()
+	}
+
 	tracer := otel.Tracer("filesystem-exporter/utils")
 
 	ctx, span := tracer.Start(ctx, "retry_with_backoff")
 	defer span.End()
 
 	span.SetAttributes(
-		attribute.Int("retry.max_attempts", maxRetries+1),
-		attribute.String("retry.initial_delay", initialDelay.String()),
+		attribute.Int("retry.max_attempts", policy.MaxAttempts),
+		attribute.String("retry.initial_delay", policy.InitialDelay.String()),
+		attribute.String("retry.max_delay", policy.MaxDelay.String()),
 	)
 
 	var lastErr error
 
-	delay := initialDelay
+	delay := policy.InitialDelay
+	if policy.MaxDelay > 0 {
+		delay = min(delay, policy.MaxDelay)
+	}
+
+	attempts := 0
+
+	for attempts < policy.MaxAttempts {
+		attempts++
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
 		attemptCtx, attemptSpan := tracer.Start(ctx, "retry_attempt")
-		attemptSpan.SetAttributes(
-			attribute.Int("retry.attempt", attempt+1),
-			attribute.String("retry.delay", delay.String()),
-		)
+		attemptSpan.SetAttributes(attribute.Int("retry.attempt", attempts))
 
-		if err := operation(); err == nil {
+		err := operation()
+		if err == nil {
 			attemptSpan.SetAttributes(attribute.Bool("retry.success", true))
 			attemptSpan.End()
 			span.SetAttributes(
-				attribute.Int("retry.total_attempts", attempt+1),
+				attribute.Int("retry.total_attempts", attempts),
 				attribute.Bool("retry.final_success", true),
 			)
 
 			return nil
-		} else {
-			lastErr = err
-			attemptSpan.SetAttributes(
-				attribute.Bool("retry.success", false),
-				attribute.String("retry.error", err.Error()),
+		}
+
+		lastErr = err
+		attemptSpan.SetAttributes(
+			attribute.Bool("retry.success", false),
+			attribute.String("retry.error", err.Error()),
+		)
+		attemptSpan.End()
+
+		// Check if this error is non-retryable
+		if isNonRetryableError(err) {
+			slog.Warn("Operation failed with non-retryable error, skipping retries", "error", err)
+			span.SetAttributes(
+				attribute.Bool("retry.non_retryable", true),
+				attribute.String("retry.non_retryable_reason", getNonRetryableReason(err)),
 			)
-			attemptSpan.End()
 
-			// Check if this error is non-retryable
-			if isNonRetryableError(err) {
-				slog.Warn("Operation failed with non-retryable error, skipping retries", "error", err)
-				span.SetAttributes(
-					attribute.Bool("retry.non_retryable", true),
-					attribute.String("retry.non_retryable_reason", getNonRetryableReason(err)),
-				)
+			break
+		}
 
-				break
-			}
+		if attempts == policy.MaxAttempts {
+			break
+		}
 
-			if attempt < maxRetries {
-				slog.Warn("Operation failed, retrying", "attempt", attempt+1, "max_retries", maxRetries, "delay", delay, "error", err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			slog.Warn("Operation failed with no time left to retry", "attempt", attempts, "delay", delay, "error", err)
+			span.SetAttributes(attribute.Bool("retry.deadline_reached", true))
 
-				// Create a span for the backoff delay
-				_, backoffSpan := tracer.Start(attemptCtx, "retry_backoff_delay")
-				backoffSpan.SetAttributes(
-					attribute.String("retry.backoff_duration", delay.String()),
-					attribute.Int("retry.next_attempt", attempt+2),
-				)
+			return fmt.Errorf("operation failed after %d attempts, no time left to retry: %w: %w", attempts, context.DeadlineExceeded, lastErr)
+		}
 
-				time.Sleep(delay)
-				delay *= 2 // Exponential backoff
+		slog.Warn("Operation failed, retrying", "attempt", attempts, "max_attempts", policy.MaxAttempts, "delay", delay, "error", err)
 
-				backoffSpan.End()
-			}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempts, err)
 		}
+
+		// Create a span for the backoff delay
+		_, backoffSpan := tracer.Start(attemptCtx, "retry_backoff_delay")
+		backoffSpan.SetAttributes(
+			attribute.String("retry.backoff_duration", delay.String()),
+			attribute.Int("retry.next_attempt", attempts+1),
+		)
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			backoffSpan.End()
+			span.SetAttributes(attribute.Bool("retry.cancelled", true))
+
+			return fmt.Errorf("operation failed after %d attempts, retries cancelled: %w: %w", attempts, ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+
+		backoffSpan.End()
+
+		// The timer and ctx may have fired together
+		if ctx.Err() != nil {
+			span.SetAttributes(attribute.Bool("retry.cancelled", true))
+
+			return fmt.Errorf("operation failed after %d attempts, retries cancelled: %w: %w", attempts, ctx.Err(), lastErr)
+		}
+
+		delay = policy.nextDelay(delay)
 	}
 
 	span.SetAttributes(
-		attribute.Int("retry.total_attempts", maxRetries+1),
+		attribute.Int("retry.total_attempts", attempts),
 		attribute.Bool("retry.final_success", false),
 		attribute.String("retry.final_error", lastErr.Error()),
 	)
 
-	return fmt.Errorf("operation failed after %d attempts: %w", maxRetries+1, lastErr)
+	return fmt.Errorf("operation failed after %d attempts: %w", attempts, lastErr)
 }
 
 // isNonRetryableError checks if an error should not be retried
@@ -109,6 +179,12 @@ func isNonRetryableError(err error) bool {
 		return true
 	}
 
+	// A collection that ran out of time would only run out again, and a
+	// panic is a bug that would only repeat
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, new(*PanicError)) {
+		return true
+	}
+
 	// Check for exec.ExitError with specific exit codes that shouldn't be retried
 	var exitErr *exec.ExitError
 	if errors.As(err, &exitErr) {
@@ -138,5 +214,13 @@ func getNonRetryableReason(err error) string {
 		return "signal_killed"
 	}
 
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+
+	if errors.As(err, new(*PanicError)) {
+		return "panic"
+	}
+
 	return "unknown"
 }
@@ -3,7 +3,9 @@ package utils
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os/exec"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -16,7 +18,7 @@ func TestRetryWithBackoff_Success(t *testing.T) {
 	err := RetryWithBackoff(ctx, func() error {
 		attempts++
 		return nil // Success on first attempt
-	}, 3, 100*time.Millisecond)
+	}, RetryPolicy{MaxAttempts: 4, InitialDelay: 100 * time.Millisecond, Multiplier: 2})
 	if err != nil {
 		t.Errorf("Expected no error, got: %v", err)
 	}
@@ -37,7 +39,7 @@ func TestRetryWithBackoff_RetryableError(t *testing.T) {
 		}
 
 		return nil // Success on third attempt
-	}, 3, 10*time.Millisecond)
+	}, RetryPolicy{MaxAttempts: 4, InitialDelay: 10 * time.Millisecond, Multiplier: 2})
 	if err != nil {
 		t.Errorf("Expected no error after retries, got: %v", err)
 	}
@@ -57,7 +59,7 @@ func TestRetryWithBackoff_NonRetryableError_SignalKilled(t *testing.T) {
 	err := RetryWithBackoff(ctx, func() error {
 		attempts++
 		return killedErr
-	}, 3, 10*time.Millisecond)
+	}, RetryPolicy{MaxAttempts: 4, InitialDelay: 10 * time.Millisecond, Multiplier: 2})
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
@@ -83,7 +85,7 @@ func TestRetryWithBackoff_NonRetryableError_ContextCanceled(t *testing.T) {
 	err := RetryWithBackoff(ctx, func() error {
 		attempts++
 		return context.Canceled
-	}, 3, 10*time.Millisecond)
+	}, RetryPolicy{MaxAttempts: 4, InitialDelay: 10 * time.Millisecond, Multiplier: 2})
 
 	if attempts != 1 {
 		t.Errorf("Expected 1 attempt (no retries for context canceled), got: %d", attempts)
@@ -102,7 +104,7 @@ func TestRetryWithBackoff_MaxRetries(t *testing.T) {
 	err := RetryWithBackoff(ctx, func() error {
 		attempts++
 		return errors.New("persistent error")
-	}, 2, 10*time.Millisecond)
+	}, RetryPolicy{MaxAttempts: 3, InitialDelay: 10 * time.Millisecond, Multiplier: 2})
 	if err == nil {
 		t.Error("Expected error after max retries, got nil")
 	}
@@ -147,6 +149,11 @@ func TestIsNonRetryableError(t *testing.T) {
 			err:      nil,
 			expected: false,
 		},
+		{
+			name:     "timed out",
+			err:      fmt.Errorf("du command failed: %w", ErrTimeout),
+			expected: true,
+		},
 		{
 			name:     "exit status 1",
 			err:      errors.New("exit status 1"),
@@ -201,3 +208,106 @@ func TestGetNonRetryableReason(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryWithBackoff_MaxDelay(t *testing.T) {
+	var times []time.Time
+
+	err := RetryWithBackoff(context.Background(), func() error {
+		times = append(times, time.Now())
+		return errors.New("temporary error")
+	}, RetryPolicy{MaxAttempts: 4, InitialDelay: 20 * time.Millisecond, MaxDelay: 30 * time.Millisecond, Multiplier: 10})
+	if err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Fatalf("Expected failure after 4 attempts, got %v", err)
+	}
+
+	// 20ms, then 200ms capped to 30ms, twice
+	if total := times[3].Sub(times[0]); total < 80*time.Millisecond || total > 500*time.Millisecond {
+		t.Errorf("Expected the delays to be capped at max_delay, took %s", total)
+	}
+}
+
+func TestRetryWithBackoff_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+
+	err := RetryWithBackoff(ctx, func() error {
+		attempts++
+		return errors.New("temporary error")
+	}, RetryPolicy{MaxAttempts: 5, InitialDelay: time.Minute, Multiplier: 2})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if attempts != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected no retry that would outlast the deadline, got %d attempts in %s", attempts, time.Since(start))
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) || ClassifyFailure(err) != FailureReasonTimeout {
+		t.Errorf("Expected the error to count as a timeout, got %v", err)
+	}
+}
+
+func TestRetryWithBackoff_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+
+	err := RetryWithBackoff(ctx, func() error {
+		attempts++
+		if attempts == 1 {
+			time.AfterFunc(20*time.Millisecond, cancel)
+		}
+
+		return errors.New("temporary error")
+	}, RetryPolicy{MaxAttempts: 5, InitialDelay: time.Minute, Multiplier: 2})
+	if err == nil || !strings.Contains(err.Error(), "temporary error") {
+		t.Fatalf("Expected the last error, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Expected the wait to end when cancelled, got %d attempts", attempts)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the error to wrap the cancellation, got %v", err)
+	}
+}
+
+func TestRetryWithBackoff_OnRetry(t *testing.T) {
+	var retried []int
+
+	attempts := 0
+
+	err := RetryWithBackoff(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("temporary error")
+		}
+
+		return nil
+	}, RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: time.Millisecond,
+		Multiplier:   2,
+		OnRetry:      func(attempt int, _ error) { retried = append(retried, attempt) },
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	if !slices.Equal(retried, []int{1, 2}) {
+		t.Errorf("Expected OnRetry after attempts 1 and 2, got %v", retried)
+	}
+}
+
+func TestRetryWithBackoff_SingleAttempt(t *testing.T) {
+	want := errors.New("zpool is faulted")
+
+	err := RetryWithBackoff(context.Background(), func() error { return want }, RetryPolicy{MaxAttempts: 1})
+	if err != want { //nolint:errorlint // Returned unwrapped
+		t.Errorf("Expected the error unwrapped without retries, got %v", err)
+	}
+}
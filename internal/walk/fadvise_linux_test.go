@@ -0,0 +1,36 @@
+//go:build linux
+
+package walk
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalk_DropCache(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a", "one.bin"), 4096)
+	writeFile(t, filepath.Join(root, "a", "deep", "two.bin"), 4096)
+	writeFile(t, filepath.Join(root, "b", "three.bin"), 4096)
+
+	result, err := Walk(context.Background(), root, Options{MaxDepth: 1, DropCache: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	// root, a, a/deep and b are each advised once they have been read
+	if result.CacheAdvised != 4 || result.CacheAdviceErrors != 0 {
+		t.Errorf("expected 4 directories advised without errors, got %d advised and %d errors",
+			result.CacheAdvised, result.CacheAdviceErrors)
+	}
+
+	result, err = Walk(context.Background(), root, Options{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if result.CacheAdvised != 0 {
+		t.Errorf("expected no advice without DropCache, got %d", result.CacheAdvised)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package walk
+
+import "errors"
+
+// adviseDontNeed is unavailable where posix_fadvise isn't supported
+func adviseDontNeed(string) error {
+	return errors.ErrUnsupported
+}
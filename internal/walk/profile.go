@@ -0,0 +1,113 @@
+package walk
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Subtree is how long a walk spent reading one subtree
+type Subtree struct {
+	Path     string
+	Start    time.Time
+	Duration time.Duration // Including any sleeps to keep within FilesPerSecond
+	Entries  int           // Entries read, including the subtree's own directory
+	Errors   int           // Entries that could not be read
+}
+
+// profiler times the subtrees at one depth below the root as the walk passes
+// through them, keeping the slowest. The walk is depth first, so it reads
+// each subtree in one stretch and only needs the clock between them.
+type profiler struct {
+	root  string
+	depth int
+	keep  int
+
+	current      *Subtree
+	startEntries int
+	startErrors  int
+
+	slowest []Subtree // Slowest first, at most keep
+}
+
+// newProfiler returns a profiler keeping the keep slowest subtrees at depth
+// below root, or nil when keep is 0
+func newProfiler(root string, depth, keep int) *profiler {
+	if keep <= 0 {
+		return nil
+	}
+
+	return &profiler{root: root, depth: max(depth, 1), keep: keep}
+}
+
+// enter is called with each entry before it is counted, along with how many
+// entries and errors the walk has counted so far
+func (p *profiler) enter(path string, entries, errors int) {
+	if p == nil {
+		return
+	}
+
+	if p.current != nil && (path == p.current.Path || isWithin(p.current.Path, path)) {
+		return
+	}
+
+	now := time.Now()
+	p.close(now, entries, errors)
+
+	rel := strings.TrimPrefix(path[len(p.root):], string(filepath.Separator))
+
+	components := strings.Split(rel, string(filepath.Separator))
+	if len(components) < p.depth {
+		// Above the profiled depth, such as a file in the root
+		return
+	}
+
+	p.current = &Subtree{
+		Path:  filepath.Join(p.root, filepath.Join(components[:p.depth]...)),
+		Start: now,
+	}
+	p.startEntries, p.startErrors = entries, errors
+}
+
+// finish closes the subtree being read when the walk ends, and returns the
+// slowest subtrees
+func (p *profiler) finish(entries, errors int) []Subtree {
+	if p == nil {
+		return nil
+	}
+
+	p.close(time.Now(), entries, errors)
+
+	return p.slowest
+}
+
+// close ends the subtree being read, if any, keeping it if it is among the
+// slowest
+func (p *profiler) close(now time.Time, entries, errors int) {
+	if p.current == nil {
+		return
+	}
+
+	subtree := *p.current
+	subtree.Duration = now.Sub(subtree.Start)
+	subtree.Entries = entries - p.startEntries
+	subtree.Errors = errors - p.startErrors
+	p.current = nil
+
+	i := len(p.slowest)
+	for i > 0 && p.slowest[i-1].Duration < subtree.Duration {
+		i--
+	}
+
+	if i >= p.keep {
+		return
+	}
+
+	p.slowest = append(p.slowest, Subtree{})
+	copy(p.slowest[i+1:], p.slowest[i:])
+	p.slowest[i] = subtree
+
+	if len(p.slowest) > p.keep {
+		p.slowest = p.slowest[:p.keep]
+	}
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package walk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalk_Sandbox(t *testing.T) {
+	if !SandboxSupported() {
+		t.Skip("Landlock is not available")
+	}
+
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	writeFile(t, filepath.Join(root, "a", "one.bin"), 4096)
+	writeFile(t, filepath.Join(outside, "secret", "big.bin"), 1<<20)
+
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	open, err := Walk(context.Background(), root, Options{Symlinks: SymlinksAlways})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if open.Directories[root] < 1<<20 {
+		t.Fatalf("expected the followed link to count the file outside, got %d bytes", open.Directories[root])
+	}
+
+	sandboxed, err := Walk(context.Background(), root, Options{Symlinks: SymlinksAlways, Sandbox: true})
+	if err != nil {
+		t.Fatalf("sandboxed Walk failed: %v", err)
+	}
+
+	if sandboxed.Directories[root] >= 1<<20 || sandboxed.Errors == 0 {
+		t.Errorf("expected the sandbox to stop the link being read, got %d bytes and %d errors",
+			sandboxed.Directories[root], sandboxed.Errors)
+	}
+
+	if sandboxed.Entries[filepath.Join(root, "a")] == 0 {
+		t.Errorf("expected the tree inside the sandbox to be counted")
+	}
+
+	// The confinement stays with the walk's own thread
+	if _, err := os.ReadDir(outside); err != nil {
+		t.Errorf("expected the caller to still read outside the sandbox: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package walk
+
+// SandboxSupported reports whether walks can be sandboxed, which needs
+// Linux's Landlock
+func SandboxSupported() bool {
+	return false
+}
+
+// confine is unavailable without Landlock
+func confine(string) error {
+	return ErrSandboxUnavailable
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package walk
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inodesAvailable is whether statOf reports device and inode numbers, which
+// tell when a followed symlink leads somewhere already counted
+const inodesAvailable = true
+
+// statOf extracts allocation, device and ownership details from a file
+func statOf(info fs.FileInfo) fileStat {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{usage: info.Size()}
+	}
+
+	// st_blocks is always in 512-byte units regardless of the filesystem block size
+	return fileStat{
+		usage: stat.Blocks * 512,
+		dev:   stat.Dev,
+		ino:   stat.Ino,
+		nlink: uint64(stat.Nlink),
+		uid:   stat.Uid,
+		gid:   stat.Gid,
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package walk
+
+import "io/fs"
+
+// inodesAvailable is whether statOf reports device and inode numbers, which
+// tell when a followed symlink leads somewhere already counted
+const inodesAvailable = false
+
+// statOf falls back to the apparent size on platforms where allocated
+// blocks, device IDs, link counts and owners aren't available
+func statOf(info fs.FileInfo) fileStat {
+	return fileStat{usage: info.Size()}
+}
@@ -0,0 +1,557 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Result holds the outcome of walking a directory tree
+type Result struct {
+	// Directories maps each directory up to the requested depth (including the
+	// root) to the disk usage in bytes of its whole subtree, like du -d
+	Directories map[string]int64
+	// LastModified maps the same directories to the newest modification time
+	// seen anywhere in their subtree, including the directories themselves
+	LastModified map[string]time.Time
+	// Entries maps each immediate child of the root (files and directories)
+	// to the disk usage in bytes of its subtree, like du -a -d 1
+	Entries map[string]int64
+	// OwnerUsage maps file owner uid to the disk usage in bytes of the files
+	// they own. Only populated when Options.ByOwner is set.
+	OwnerUsage map[uint32]int64
+	// GroupUsage maps file group gid to disk usage in bytes. Only populated
+	// when Options.ByOwner is set.
+	GroupUsage map[uint32]int64
+	// HardlinkedBytes is the usage of further links to files already counted.
+	// Like du, each file is counted once however many links it has, so
+	// Directories[root] + HardlinkedBytes is the size counting every link.
+	HardlinkedBytes int64
+	// SymlinkLoops counts followed symlinks that were skipped because they
+	// lead back to a directory containing them
+	SymlinkLoops int
+	// Errors counts entries that could not be read and were skipped
+	Errors int
+	// Throttled is how long the walk slept to keep within
+	// Options.FilesPerSecond
+	Throttled time.Duration
+	// CacheAdvised counts directories whose cached pages were dropped after
+	// being read. Only populated when Options.DropCache is set.
+	CacheAdvised int
+	// CacheAdviceErrors counts directories the advice could not be applied to
+	CacheAdviceErrors int
+	// SlowestSubtrees are the subtrees the walk spent longest in, slowest
+	// first. Only populated when Options.SlowestSubtrees is set.
+	SlowestSubtrees []Subtree
+}
+
+// ErrSandboxUnavailable is returned for walks with Options.Sandbox set where
+// the kernel can't confine them
+var ErrSandboxUnavailable = errors.New("sandboxed walks need Landlock, which is not available")
+
+// paceGranularity is the shortest sleep a walk limited by FilesPerSecond takes
+const paceGranularity = 10 * time.Millisecond
+
+// Options controls how a tree is walked
+type Options struct {
+	// MaxDepth is the deepest directory level reported (0 = root only)
+	MaxDepth int
+	// ByOwner attributes usage to the owning uid and gid of each entry
+	ByOwner bool
+	// DropCache advises the kernel to drop each directory's cached pages
+	// once it has been read, so a large walk doesn't evict the page cache
+	DropCache bool
+	// Apparent measures files by their length, like du --apparent-size,
+	// instead of the blocks allocated to them
+	Apparent bool
+	// Symlinks is which symbolic links are followed rather than counted as
+	// links. Only Linux can tell when a link leads back into the tree, so
+	// elsewhere they are never followed.
+	Symlinks Symlinks
+	// FilesPerSecond caps how many entries are read per second, sleeping
+	// whenever the walk gets ahead (0 = unlimited)
+	FilesPerSecond int
+	// Sandbox confines the walk, with Landlock, to reading beneath root, so
+	// nothing outside it can be read whatever the tree holds. Entries it
+	// can't read, such as the targets of links out of it, are counted as
+	// errors. The walk fails with ErrSandboxUnavailable where Landlock isn't.
+	Sandbox bool
+	// Visit, when set, is called for every entry the walk comes across, in
+	// depth first order with each directory before its contents
+	Visit func(Visit)
+	// SlowestSubtrees is how many of the subtrees at MaxDepth (or the root's
+	// immediate children when that is 0) to time, keeping the slowest
+	SlowestSubtrees int
+}
+
+// Visit describes an entry a walk came across
+type Visit struct {
+	// Path is where the entry was found, which is below the link for
+	// entries reached through a followed symlink
+	Path  string
+	IsDir bool
+	// Info is nil when the entry couldn't be read
+	Info fs.FileInfo
+	// Usage is the disk usage of the entry itself, whatever Apparent is
+	Usage int64
+	Dev   uint64
+	Ino   uint64
+	Nlink uint64
+	// OtherFS marks a directory on another filesystem, which isn't walked
+	OtherFS bool
+	// Err is why the entry, or a directory's contents, couldn't be read. A
+	// directory whose contents can't be read is visited a second time with it.
+	Err error
+}
+
+// Symlinks is a policy for following symbolic links
+type Symlinks int
+
+// Which symbolic links a walk follows
+const (
+	SymlinksNever      Symlinks = iota // Count links themselves, like du
+	SymlinksWithinRoot                 // Follow links to targets inside the root
+	SymlinksAlways                     // Follow every link, like du -L
+)
+
+// fileStat holds the platform-specific details the walker needs per entry
+type fileStat struct {
+	usage int64
+	dev   uint64
+	ino   uint64
+	nlink uint64 // 0 where link counts aren't available
+	uid   uint32
+	gid   uint32
+}
+
+// fileID identifies a file across its hardlinks
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// Walk computes disk usage for root without spawning external commands. Like
+// du -x it does not cross filesystem boundaries, and a file with several
+// hardlinks is counted once, where its first link is found. Unreadable
+// entries are skipped and counted rather than failing the whole walk. A walk
+// ended by ctx returns, along with the error, a result holding only
+// SlowestSubtrees, which then includes the subtree it was in.
+func Walk(ctx context.Context, root string, opts Options) (*Result, error) {
+	root = filepath.Clean(root)
+
+	if opts.Sandbox {
+		return walkSandboxed(ctx, root, opts)
+	}
+
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rootInfo.IsDir() {
+		return nil, &fs.PathError{Op: "walk", Path: root, Err: errors.New("not a directory")}
+	}
+
+	rootStat := opts.stat(rootInfo)
+
+	result := &Result{
+		Directories:  map[string]int64{root: rootStat.usage},
+		LastModified: map[string]time.Time{root: rootInfo.ModTime()},
+		Entries:      make(map[string]int64),
+	}
+
+	if opts.ByOwner {
+		result.OwnerUsage = map[uint32]int64{rootStat.uid: rootStat.usage}
+		result.GroupUsage = map[uint32]int64{rootStat.gid: rootStat.usage}
+	}
+
+	visited := 0
+	start := time.Now()
+	profile := newProfiler(root, opts.MaxDepth, opts.SlowestSubtrees)
+
+	// pace sleeps until the walk is back within FilesPerSecond. Short waits
+	// are left to build up, so it doesn't sleep for every entry.
+	pace := func() error {
+		if opts.FilesPerSecond <= 0 {
+			return nil
+		}
+
+		wait := time.Until(start.Add(time.Duration(visited) * time.Second / time.Duration(opts.FilesPerSecond)))
+		if wait < paceGranularity {
+			return nil
+		}
+
+		result.Throttled += wait
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	// Files with more than one link, so later links aren't counted again.
+	// When following symlinks a file or directory can also be reached by
+	// more than one path, so then everything is recorded.
+	following := opts.Symlinks != SymlinksNever && inodesAvailable
+	seen := make(map[fileID]bool)
+
+	if following {
+		seen[fileID{dev: rootStat.dev, ino: rootStat.ino}] = true
+	}
+
+	var realRoot string
+	if opts.Symlinks == SymlinksWithinRoot {
+		if realRoot, err = filepath.EvalSymlinks(root); err != nil {
+			return nil, err
+		}
+	}
+
+	// Directories still being read. WalkDir goes depth first, so once an
+	// entry outside the top directory is visited that directory is done.
+	var reading []string
+	if opts.DropCache {
+		reading = append(reading, root)
+	}
+
+	finishReading := func(next string) {
+		for len(reading) > 0 {
+			dir := reading[len(reading)-1]
+			if next != "" && isWithin(dir, next) {
+				return
+			}
+
+			reading = reading[:len(reading)-1]
+			if err := adviseDontNeed(dir); err != nil {
+				result.CacheAdviceErrors++
+			} else {
+				result.CacheAdvised++
+			}
+		}
+	}
+
+	visit := func(v Visit) {
+		if opts.Visit == nil {
+			return
+		}
+
+		if v.Info != nil {
+			stat := statOf(v.Info)
+			v.Usage, v.Dev, v.Ino, v.Nlink = stat.usage, stat.dev, stat.ino, stat.nlink
+		}
+
+		opts.Visit(v)
+	}
+
+	visit(Visit{Path: root, IsDir: true, Info: rootInfo})
+
+	// count adds an entry, found at path below root, to the results
+	count := func(path string, isDir bool, info fs.FileInfo) error {
+		stat := opts.stat(info)
+		usage := stat.usage
+
+		// Don't cross filesystem boundaries (du -x)
+		if isDir && stat.dev != rootStat.dev {
+			visit(Visit{Path: path, IsDir: true, Info: info, OtherFS: true})
+			return fs.SkipDir
+		}
+
+		if following || (!isDir && stat.nlink > 1) {
+			id := fileID{dev: stat.dev, ino: stat.ino}
+			if seen[id] {
+				if isDir {
+					return fs.SkipDir
+				}
+
+				if stat.nlink > 1 {
+					result.HardlinkedBytes += usage
+					visit(Visit{Path: path, Info: info})
+				}
+
+				return nil
+			}
+
+			seen[id] = true
+		}
+
+		visit(Visit{Path: path, IsDir: isDir, Info: info})
+
+		if opts.DropCache {
+			finishReading(path)
+
+			if isDir {
+				reading = append(reading, path)
+			}
+		}
+
+		if opts.ByOwner {
+			result.OwnerUsage[stat.uid] += usage
+			result.GroupUsage[stat.gid] += usage
+		}
+
+		rel := path[len(root):]
+		rel = strings.TrimPrefix(rel, string(filepath.Separator))
+		components := strings.Split(rel, string(filepath.Separator))
+
+		modTime := info.ModTime()
+
+		result.Entries[filepath.Join(root, components[0])] += usage
+		result.Directories[root] += usage
+		result.touch(root, modTime)
+
+		// Attribute usage to every ancestor directory within the depth limit.
+		// Files only contribute to their parents; directories to themselves too.
+		ancestors := len(components) - 1
+		if isDir {
+			ancestors = len(components)
+		}
+
+		ancestors = min(ancestors, opts.MaxDepth)
+		for i := 1; i <= ancestors; i++ {
+			dir := filepath.Join(root, filepath.Join(components[:i]...))
+			result.Directories[dir] += usage
+			result.touch(dir, modTime)
+		}
+
+		return nil
+	}
+
+	// walkTree walks real, reporting its entries as if it were at base.
+	// Directories reached through a symlink are walked on their own and
+	// counted under the link.
+	var walkTree func(base, real string) error
+
+	// follow counts what the symlink at path, really at real, points to
+	follow := func(path, real string, d fs.DirEntry) error {
+		target, err := filepath.EvalSymlinks(real)
+		if err != nil {
+			// Dangling, like du -L reports
+			result.Errors++
+			visit(Visit{Path: path, Err: err})
+
+			return nil
+		}
+
+		if opts.Symlinks == SymlinksWithinRoot && target != realRoot && !isWithin(realRoot, target) {
+			info, err := d.Info()
+			if err != nil {
+				result.Errors++
+				visit(Visit{Path: path, Err: err})
+
+				return nil
+			}
+
+			return count(path, false, info)
+		}
+
+		info, err := os.Stat(target)
+		if err != nil {
+			result.Errors++
+			visit(Visit{Path: path, Err: err})
+
+			return nil
+		}
+
+		if !info.IsDir() {
+			return count(path, false, info)
+		}
+
+		stat := statOf(info)
+		if seen[fileID{dev: stat.dev, ino: stat.ino}] {
+			// Already counted, or one of the link's own parents
+			parent, err := filepath.EvalSymlinks(filepath.Dir(real))
+			if err == nil && (target == parent || isWithin(target, parent)) {
+				result.SymlinkLoops++
+			}
+
+			return nil
+		}
+
+		return walkTree(path, target)
+	}
+
+	walkTree = func(base, real string) error {
+		return filepath.WalkDir(real, func(realPath string, d fs.DirEntry, err error) error {
+			path := filepath.Join(base, realPath[len(real):])
+
+			if err != nil {
+				if path == root {
+					return err
+				}
+
+				profile.enter(path, visited, result.Errors)
+				result.Errors++
+				visit(Visit{Path: path, IsDir: d != nil && d.IsDir(), Err: err})
+
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+
+				return nil
+			}
+
+			if path == root {
+				return nil
+			}
+
+			profile.enter(path, visited, result.Errors)
+
+			visited++
+			if visited%1024 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			if err := pace(); err != nil {
+				return err
+			}
+
+			if following && d.Type()&fs.ModeSymlink != 0 {
+				return follow(path, realPath, d)
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				result.Errors++
+				visit(Visit{Path: path, IsDir: d.IsDir(), Err: err})
+
+				return nil
+			}
+
+			return count(path, d.IsDir(), info)
+		})
+	}
+
+	walkErr := walkTree(root, root)
+
+	finishReading("")
+
+	result.SlowestSubtrees = profile.finish(visited, result.Errors)
+
+	if walkErr != nil {
+		// Where the time went still explains a walk that ran out of it
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(walkErr, ctxErr) && result.SlowestSubtrees != nil {
+			return &Result{SlowestSubtrees: result.SlowestSubtrees}, walkErr
+		}
+
+		return nil, walkErr
+	}
+
+	return result, nil
+}
+
+// walkSandboxed walks root on a thread confined to it. The confinement can't
+// be lifted, so the thread is left locked to its goroutine and exits with it
+// rather than going back to run other work. Threads the runtime starts in
+// the meantime aren't cloned from it, so they aren't confined.
+func walkSandboxed(ctx context.Context, root string, opts Options) (*Result, error) {
+	type outcome struct {
+		result *Result
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	opts.Sandbox = false
+
+	go func() {
+		runtime.LockOSThread()
+
+		if err := confine(root); err != nil {
+			done <- outcome{err: err}
+			return
+		}
+
+		result, err := Walk(ctx, root, opts)
+		done <- outcome{result: result, err: err}
+	}()
+
+	o := <-done
+
+	return o.result, o.err
+}
+
+// stat is statOf, with the usage replaced by the length when opts.Apparent
+// is set
+func (opts Options) stat(info fs.FileInfo) fileStat {
+	stat := statOf(info)
+	if opts.Apparent {
+		stat.usage = info.Size()
+	}
+
+	return stat
+}
+
+// Entry is an immediate child of a directory
+type Entry struct {
+	Path  string
+	IsDir bool
+	Usage int64 // Disk usage of the entry itself, excluding any subtree
+}
+
+// List returns root's own disk usage and its immediate children that are on
+// the same filesystem, without descending into them. Of opts only Apparent
+// applies.
+func List(root string, opts Options) (int64, []Entry, error) {
+	root = filepath.Clean(root)
+
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rootStat := opts.stat(rootInfo)
+
+	dirEntries, err := os.ReadDir(root)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries := make([]Entry, 0, len(dirEntries))
+
+	for _, d := range dirEntries {
+		info, err := d.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+
+		stat := opts.stat(info)
+		if d.IsDir() && stat.dev != rootStat.dev {
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Path:  filepath.Join(root, d.Name()),
+			IsDir: d.IsDir(),
+			Usage: stat.usage,
+		})
+	}
+
+	return rootStat.usage, entries, nil
+}
+
+// isWithin reports whether path is below dir
+func isWithin(dir, path string) bool {
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+
+	return strings.HasPrefix(path, dir)
+}
+
+// touch records modTime for dir if it is newer than what has been seen so far
+func (r *Result) touch(dir string, modTime time.Time) {
+	if modTime.After(r.LastModified[dir]) {
+		r.LastModified[dir] = modTime
+	}
+}
@@ -0,0 +1,351 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestWalk_DepthAndEntries(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "top.bin"), 64*1024)
+	writeFile(t, filepath.Join(root, "a", "one.bin"), 32*1024)
+	writeFile(t, filepath.Join(root, "a", "deep", "two.bin"), 16*1024)
+	writeFile(t, filepath.Join(root, "b", "three.bin"), 8*1024)
+
+	result, err := Walk(context.Background(), root, Options{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	for _, dir := range []string{root, filepath.Join(root, "a"), filepath.Join(root, "b")} {
+		if _, ok := result.Directories[dir]; !ok {
+			t.Errorf("expected directory %s in results", dir)
+		}
+	}
+
+	if _, ok := result.Directories[filepath.Join(root, "a", "deep")]; ok {
+		t.Errorf("did not expect depth-2 directory with MaxDepth 1")
+	}
+
+	if len(result.Entries) != 3 {
+		t.Errorf("expected 3 immediate entries, got %d: %v", len(result.Entries), result.Entries)
+	}
+
+	// The subtree of "a" includes the nested file, so it must outweigh "b"
+	if result.Directories[filepath.Join(root, "a")] <= result.Directories[filepath.Join(root, "b")] {
+		t.Errorf("expected a (%d) to be larger than b (%d)",
+			result.Directories[filepath.Join(root, "a")], result.Directories[filepath.Join(root, "b")])
+	}
+
+	// The root includes every entry plus its own directory blocks
+	var entriesTotal int64
+	for _, size := range result.Entries {
+		entriesTotal += size
+	}
+
+	if result.Directories[root] < entriesTotal {
+		t.Errorf("root size %d smaller than sum of entries %d", result.Directories[root], entriesTotal)
+	}
+
+	if result.Entries[filepath.Join(root, "a")] != result.Directories[filepath.Join(root, "a")] {
+		t.Errorf("entry and directory size for a should match: %d != %d",
+			result.Entries[filepath.Join(root, "a")], result.Directories[filepath.Join(root, "a")])
+	}
+}
+
+func TestWalk_NotADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	writeFile(t, file, 1)
+
+	if _, err := Walk(context.Background(), file, Options{}); err == nil {
+		t.Fatal("expected error walking a regular file")
+	}
+}
+
+func TestWalk_LastModified(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	recent := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeFile(t, filepath.Join(root, "a", "old.bin"), 1)
+	writeFile(t, filepath.Join(root, "b", "nested", "recent.bin"), 1)
+
+	for path, modTime := range map[string]time.Time{
+		filepath.Join(root, "a", "old.bin"):              old,
+		filepath.Join(root, "a"):                         old,
+		filepath.Join(root, "b", "nested", "recent.bin"): recent,
+		filepath.Join(root, "b", "nested"):               old,
+		filepath.Join(root, "b"):                         old,
+		root:                                             old,
+	} {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	result, err := Walk(context.Background(), root, Options{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if got := result.LastModified[filepath.Join(root, "a")]; !got.Equal(old) {
+		t.Errorf("expected a last modified %v, got %v", old, got)
+	}
+
+	// The nested file is below MaxDepth but still counts towards its ancestors
+	if got := result.LastModified[filepath.Join(root, "b")]; !got.Equal(recent) {
+		t.Errorf("expected b last modified %v, got %v", recent, got)
+	}
+
+	if got := result.LastModified[root]; !got.Equal(recent) {
+		t.Errorf("expected root last modified %v, got %v", recent, got)
+	}
+}
+
+func TestWalk_Apparent(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "small.bin"), 100)
+
+	// Sparse, so on most filesystems it has far fewer blocks than its length
+	sparse, err := os.Create(filepath.Join(root, "sparse.bin"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := sparse.Truncate(1 << 20); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	_ = sparse.Close()
+
+	result, err := Walk(context.Background(), root, Options{Apparent: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	for name, expected := range map[string]int64{"small.bin": 100, "sparse.bin": 1 << 20} {
+		if got := result.Entries[filepath.Join(root, name)]; got != expected {
+			t.Errorf("expected apparent size %d for %s, got %d", expected, name, got)
+		}
+	}
+
+	_, entries, err := List(root, Options{Apparent: true})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Path == filepath.Join(root, "small.bin") && entry.Usage != 100 {
+			t.Errorf("expected List to report 100 bytes for small.bin, got %d", entry.Usage)
+		}
+	}
+}
+
+func TestWalk_Hardlinks(t *testing.T) {
+	root := t.TempDir()
+	original := filepath.Join(root, "a", "backup.bin")
+	writeFile(t, original, 64*1024)
+
+	if err := os.MkdirAll(filepath.Join(root, "b"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if err := os.Link(original, filepath.Join(root, "b", "backup.bin")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	result, err := Walk(context.Background(), root, Options{MaxDepth: 1, Apparent: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	info, err := os.Stat(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statOf(info).nlink == 0 {
+		t.Skip("link counts not available on this platform")
+	}
+
+	// Only one of the two directories holds the file's size
+	a, b := result.Directories[filepath.Join(root, "a")], result.Directories[filepath.Join(root, "b")]
+	if min(a, b) >= 64*1024 || max(a, b) < 64*1024 {
+		t.Errorf("expected the file to be counted in one directory only, got a=%d b=%d", a, b)
+	}
+
+	if result.HardlinkedBytes != 64*1024 {
+		t.Errorf("expected 64KiB of further links, got %d", result.HardlinkedBytes)
+	}
+}
+
+func TestWalk_Symlinks(t *testing.T) {
+	if !inodesAvailable {
+		t.Skip("symlinks are never followed on this platform")
+	}
+
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	writeFile(t, filepath.Join(root, "releases", "1", "app.bin"), 64*1024)
+	writeFile(t, filepath.Join(outside, "shared.bin"), 32*1024)
+
+	for link, target := range map[string]string{
+		"current": filepath.Join("releases", "1"),
+		"loop":    ".",
+		"shared":  outside,
+		"missing": "nowhere",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+
+	walkWith := func(symlinks Symlinks) *Result {
+		t.Helper()
+
+		result, err := Walk(context.Background(), root, Options{MaxDepth: 1, Apparent: true, Symlinks: symlinks})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+
+		return result
+	}
+
+	never := walkWith(SymlinksNever)
+	if _, ok := never.Directories[filepath.Join(root, "current")]; ok {
+		t.Error("did not expect a directory for the current link when not following symlinks")
+	}
+
+	if never.Directories[filepath.Join(root, "releases")] < 64*1024 {
+		t.Errorf("expected releases to hold the app, got %d", never.Directories[filepath.Join(root, "releases")])
+	}
+
+	within := walkWith(SymlinksWithinRoot)
+
+	// current comes first, so the release is counted there and not again
+	// under releases
+	if within.Directories[filepath.Join(root, "current")] < 64*1024 {
+		t.Errorf("expected current to hold the app, got %d", within.Directories[filepath.Join(root, "current")])
+	}
+
+	if within.Directories[filepath.Join(root, "releases")] >= 64*1024 {
+		t.Errorf("expected the app to be counted once, releases has %d", within.Directories[filepath.Join(root, "releases")])
+	}
+
+	if within.Entries[filepath.Join(root, "shared")] >= 32*1024 {
+		t.Errorf("did not expect the link outside the root to be followed, got %d", within.Entries[filepath.Join(root, "shared")])
+	}
+
+	if within.SymlinkLoops != 1 {
+		t.Errorf("expected the loop to be detected once, got %d", within.SymlinkLoops)
+	}
+
+	if within.Errors != 1 {
+		t.Errorf("expected the dangling link to be counted as an error, got %d", within.Errors)
+	}
+
+	always := walkWith(SymlinksAlways)
+	if always.Directories[filepath.Join(root, "shared")] < 32*1024 {
+		t.Errorf("expected the link outside the root to be followed, got %d", always.Directories[filepath.Join(root, "shared")])
+	}
+}
+
+func TestWalk_FilesPerSecond(t *testing.T) {
+	root := t.TempDir()
+	for i := range 20 {
+		writeFile(t, filepath.Join(root, "dir", string(rune('a'+i))), 10)
+	}
+
+	start := time.Now()
+
+	// 21 entries at 100 per second take at least 200ms
+	result, err := Walk(context.Background(), root, Options{FilesPerSecond: 100})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the walk to be paced, it took %s", elapsed)
+	}
+
+	if result.Throttled <= 0 {
+		t.Errorf("expected Throttled to be recorded, got %s", result.Throttled)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := Walk(ctx, root, Options{FilesPerSecond: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a paced walk to stop with its context, got %v", err)
+	}
+}
+
+func TestWalk_SlowestSubtrees(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a", "one.bin"), 10)
+	writeFile(t, filepath.Join(root, "a", "deep", "two.bin"), 10)
+	writeFile(t, filepath.Join(root, "b", "three.bin"), 10)
+
+	result, err := Walk(context.Background(), root, Options{SlowestSubtrees: 10})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	entries := make(map[string]int)
+
+	for i, subtree := range result.SlowestSubtrees {
+		entries[subtree.Path] = subtree.Entries
+
+		if i > 0 && subtree.Duration > result.SlowestSubtrees[i-1].Duration {
+			t.Errorf("expected the slowest subtree first, got %+v", result.SlowestSubtrees)
+		}
+	}
+
+	// a, a/deep, a/deep/two.bin and a/one.bin; b and b/three.bin
+	if entries[filepath.Join(root, "a")] != 4 || entries[filepath.Join(root, "b")] != 2 || len(entries) != 2 {
+		t.Errorf("expected subtrees a with 4 entries and b with 2, got %v", entries)
+	}
+
+	result, err = Walk(context.Background(), root, Options{MaxDepth: 2, SlowestSubtrees: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if len(result.SlowestSubtrees) != 1 {
+		t.Errorf("expected only the slowest subtree to be kept, got %+v", result.SlowestSubtrees)
+	}
+
+	// A walk that runs out of time still says where it was
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err = Walk(ctx, root, Options{FilesPerSecond: 1, SlowestSubtrees: 10})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the walk to stop with its context, got %v", err)
+	}
+
+	if result == nil || len(result.SlowestSubtrees) == 0 || result.SlowestSubtrees[0].Duration < 40*time.Millisecond {
+		t.Errorf("expected the subtree the walk stopped in, got %+v", result)
+	}
+
+	if result != nil && result.Directories != nil {
+		t.Error("expected no sizes from a walk that stopped")
+	}
+}
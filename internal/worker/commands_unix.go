@@ -0,0 +1,579 @@
+//go:build !windows
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"filesystem-exporter/internal/audit"
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/limits"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sys/unix"
+)
+
+// recordCommand records the exact argv of an external command on the span and
+// in the running job's state, so surprising results can be reproduced by hand
+func (w *Worker) recordCommand(ctx context.Context, span trace.Span, argv []string) {
+	span.SetAttributes(attribute.StringSlice("command.argv", argv))
+	jobID, _ := ctx.Value(jobIDKey{}).(string)
+	w.state.RecordCommand(ctx, w.queueType, jobID, argv)
+
+	slog.Debug("Running command", "queue_type", w.queueType, "argv", argv)
+}
+
+// command creates a df or du command in its own process group. When ctx is
+// done the whole group is sent SIGTERM, so descendants don't outlive it, and
+// SIGKILL if any of it is still running after command_limits.kill_grace.
+func (w *Worker) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := utils.CommandContext(ctx, name, args...)
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+
+	grace := w.config.CommandLimits.KillGrace.Duration
+
+	cmd.Cancel = func() error {
+		return w.killProcessGroup(cmd.Process.Pid, grace)
+	}
+
+	// Wait only gives up on the command's output once the grace has passed
+	cmd.WaitDelay += grace
+
+	return cmd
+}
+
+// killProcessGroup sends SIGTERM to a process group, waits up to grace for
+// all of it to exit and sends SIGKILL to what's left
+func (w *Worker) killProcessGroup(pgid int, grace time.Duration) error {
+	if err := unix.Kill(-pgid, unix.SIGTERM); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return os.ErrProcessDone
+		}
+
+		return err
+	}
+
+	w.metrics.CommandKilledCounter.WithLabelValues("SIGTERM").Inc()
+
+	deadline := time.Now().Add(grace)
+
+	for time.Now().Before(deadline) {
+		if !processGroupAlive(pgid) {
+			return nil
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := unix.Kill(-pgid, unix.SIGKILL); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return nil
+		}
+
+		return err
+	}
+
+	w.metrics.CommandKilledCounter.WithLabelValues("SIGKILL").Inc()
+	slog.Warn("Killed command still running after SIGTERM", "pgid", pgid, "kill_grace", grace)
+
+	return nil
+}
+
+// commandOutput runs cmd like cmd.Output, applying command_limits when
+// configured and counting commands the limits terminated. group is the
+// directory group being scanned, whose io_limit cgroup the command runs in,
+// or empty for commands of no group. The command's resource usage is added
+// to the job's.
+func (w *Worker) commandOutput(ctx context.Context, span trace.Span, cmd *exec.Cmd, group string) (output []byte, err error) {
+	start := time.Now()
+
+	defer func() {
+		w.recordCommandUsage(ctx, span, cmd)
+
+		jobID, _ := ctx.Value(jobIDKey{}).(string)
+		audit.Command(ctx, cmd, jobID, start, len(output), err)
+	}()
+
+	if err := w.setRunAs(cmd, group); err != nil {
+		return nil, err
+	}
+
+	started := w.priorityHook(cmd, group)
+
+	if w.limiter == nil {
+		if started == nil {
+			return cmd.Output()
+		}
+
+		return startedOutput(cmd, started)
+	}
+
+	output, limit, err := w.limiter.Output(cmd, group, started)
+	if limit != "" {
+		command := filepath.Base(cmd.Args[0])
+
+		w.metrics.CommandLimitKillsCounter.WithLabelValues(command, limit).Inc()
+		span.SetAttributes(attribute.String("command.error_type", limit+"_limit"))
+		slog.Error("Command terminated by resource limit", "command", command, "limit", limit, "argv", cmd.Args)
+
+		err = fmt.Errorf("%s terminated by the %s limit: %w", command, limit, err)
+	}
+
+	return output, err
+}
+
+// setRunAs makes cmd start as the user and group that group's commands, or
+// with no group security's, are configured to run as. Supplementary groups
+// are dropped when the exporter is privileged enough to.
+func (w *Worker) setRunAs(cmd *exec.Cmd, group string) error {
+	runAs, err := w.config.GetDirectoryRunAs(group)
+	if err != nil || runAs == nil {
+		return err
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:         runAs.UID,
+		Gid:         runAs.GID,
+		Groups:      []uint32{},
+		NoSetGroups: os.Geteuid() != 0,
+	}
+
+	return nil
+}
+
+// recordCommandUsage records the CPU time and peak memory of a finished
+// command from its rusage, on the span, the job and max_rss_bytes
+func (w *Worker) recordCommandUsage(ctx context.Context, span trace.Span, cmd *exec.Cmd) {
+	// Not set when the command didn't start
+	if cmd.ProcessState == nil {
+		return
+	}
+
+	rusage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return
+	}
+
+	user, system := cmd.ProcessState.UserTime(), cmd.ProcessState.SystemTime()
+	maxRSS := int64(rusage.Maxrss) //nolint:unconvert // An int32 on some 32-bit architectures
+
+	// ru_maxrss is in bytes on macOS and KiB elsewhere
+	if runtime.GOOS != "darwin" {
+		maxRSS *= 1024
+	}
+
+	w.metrics.CommandMaxRSSGauge.WithLabelValues(filepath.Base(cmd.Args[0]), w.queueType).Set(float64(maxRSS))
+
+	span.SetAttributes(
+		attribute.Float64("command.cpu_user_seconds", user.Seconds()),
+		attribute.Float64("command.cpu_system_seconds", system.Seconds()),
+		attribute.Int64("command.max_rss_bytes", maxRSS),
+	)
+
+	if usage := commandUsageFrom(ctx); usage != nil {
+		usage.add(user, system, maxRSS)
+	}
+}
+
+// priorityHook returns what gives a command of group the group's ioprio_class
+// and nice once it has started, recording whether that worked, or nil when
+// the group sets neither
+func (w *Worker) priorityHook(cmd *exec.Cmd, group string) func(pid int) {
+	dirConfig, exists := w.config.Directory(group)
+	if !exists || (dirConfig.IOPrioClass == "" && dirConfig.Nice == 0) {
+		return nil
+	}
+
+	return func(pid int) {
+		applied := 1.0
+
+		if err := limits.SetPriority(pid, dirConfig); err != nil {
+			applied = 0
+			slog.Warn("Failed to set command priority", "command", cmd.Args[0], "pid", pid, "group", group, "error", err)
+		}
+
+		w.metrics.CommandPriorityGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, group)...).Set(applied)
+	}
+}
+
+// startedOutput runs cmd like cmd.Output, calling started with its pid once
+// it has started
+func startedOutput(cmd *exec.Cmd, started func(pid int)) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+
+	// Kept for the exit error, as cmd.Output does, so failures can be classified
+	captureStderr := cmd.Stderr == nil
+	if captureStderr {
+		cmd.Stderr = &stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	started(cmd.Process.Pid)
+
+	err := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if captureStderr && errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+
+	return stdout.Bytes(), err
+}
+
+// checkExecAllowed refuses to spawn external commands when security.no_exec is set.
+// Validation already rejects configs that need exec; this is a last line of defence.
+func (w *Worker) checkExecAllowed(command string) error {
+	if w.config.Security.NoExec {
+		return fmt.Errorf("refusing to run %s: external commands are disabled by security.no_exec", command)
+	}
+
+	return nil
+}
+
+// executeDfCommand executes the df command for one or more mount points. group
+// is the filesystem being collected, or empty for a batch of them.
+func (w *Worker) executeDfCommand(ctx context.Context, group string, mountPoints ...string) ([]byte, error) {
+	ctx, span := w.startSpan(ctx, "command.df", trace.WithAttributes(
+		attribute.StringSlice("command.mount_points", mountPoints),
+	))
+	defer span.End()
+
+	if err := w.checkExecAllowed("df"); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	args := slices.Clone(dfArgs)
+
+	// POSIX output keeps each filesystem on one line, so rows can be told apart
+	if len(mountPoints) > 1 && !slices.Contains(args, "-P") {
+		args = append(args, "-P")
+	}
+
+	cmd := w.command(timeoutCtx, "df", append(args, mountPoints...)...)
+	w.recordCommand(ctx, span, cmd.Args)
+
+	execStart := time.Now()
+	output, err := w.commandOutput(ctx, span, cmd, "")
+	execDuration := time.Since(execStart)
+	w.metrics.CommandDurationHist.WithLabelValues("df", w.queueType, group).Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			slog.Error("df command timed out", "mount_points", mountPoints, "duration", execDuration)
+			err = fmt.Errorf("%w after %s: %w", utils.ErrTimeout, execDuration.Round(time.Millisecond), err)
+		}
+
+		span.RecordError(err)
+
+		return nil, err
+	}
+
+	span.AddEvent("command_completed")
+
+	return output, nil
+}
+
+// parseDuPartialOutput parses what du wrote before it was stopped. du prints
+// each directory once it has finished with it, so those sizes are complete,
+// but a line cut off mid-write is dropped.
+func (w *Worker) parseDuPartialOutput(ctx context.Context, output []byte, path string) map[string]int64 {
+	end := bytes.LastIndexByte(output, '\n')
+	if end < 0 {
+		return nil
+	}
+
+	sizes, err := w.parseDuOutputWithDepth(ctx, output[:end+1], path)
+	if err != nil {
+		return nil
+	}
+
+	return sizes
+}
+
+// runDu runs a du command for a directory group, counting the entries it
+// couldn't read in filesystem_exporter_directory_scan_errors_total. du exits
+// with status 1 after skipping entries but still sizes everything else, so
+// its output is then used as a partial result, unless the path itself
+// couldn't be read.
+func (w *Worker) runDu(ctx context.Context, span trace.Span, group, path string, cmd *exec.Cmd) ([]byte, error) {
+	stderr := newDuStderr(path)
+	cmd.Stderr = stderr
+
+	output, err := w.commandOutput(ctx, span, cmd, group)
+	stderr.finish()
+
+	labels := w.config.GetDirectoryLabels(group)
+	for reason, count := range stderr.skipped {
+		w.metrics.DirectoryScanErrorsCounter.WithLabelValues(w.metrics.ItemLabelValues(labels, group, reason)...).Add(float64(count))
+	}
+
+	skipped := stderr.total()
+	span.SetAttributes(attribute.Int("du.skipped_entries", skipped))
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return output, err
+	}
+
+	// Keep the messages for ClassifyFailure, since exec only captures them
+	// when nothing else reads stderr
+	exitErr.Stderr = stderr.head
+
+	if exitErr.ExitCode() != 1 || skipped == 0 || stderr.rootFailed || len(output) == 0 {
+		return output, err
+	}
+
+	slog.Warn("du skipped entries it couldn't read, using the partial result",
+		"group", group,
+		"path", path,
+		"skipped", skipped,
+		"permission", stderr.skipped[utils.FailureReasonPermission],
+		"not_found", stderr.skipped[utils.FailureReasonNotFound],
+	)
+	span.AddEvent("du_partial_result")
+
+	return output, nil
+}
+
+// duArgs are the du arguments for a group's size_mode and follow_symlinks,
+// with the number of bytes in the units du then reports
+func (w *Worker) duArgs(group string) ([]string, int64) {
+	var args []string
+
+	if dirConfig, _ := w.config.Directory(group); dirConfig.FollowSymlinks == config.FollowSymlinksAlways {
+		args = append(args, "-L")
+	}
+
+	if w.config.GetDirectorySizeMode(group) == config.SizeModeApparent {
+		return append(args, duApparentArgs...), 1
+	}
+
+	return args, 1024
+}
+
+// executeDuCommand executes the du command for a directory group, returning
+// its size in bytes
+func (w *Worker) executeDuCommand(ctx context.Context, group, path string, timeout time.Duration) (int64, error) {
+	ctx, span := w.startSpan(ctx, "command.du", trace.WithAttributes(
+		attribute.String("command.path", path),
+		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	if err := w.checkExecAllowed("du"); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	groupArgs, unit := w.duArgs(group)
+
+	args := append([]string{"-s", "-x"}, groupArgs...)
+	args = append(args, path)
+	cmd := w.command(timeoutCtx, "du", args...)
+	w.recordCommand(ctx, span, cmd.Args)
+
+	execStart := time.Now()
+	output, err := w.runDu(ctx, span, group, path, cmd)
+	execDuration := time.Since(execStart)
+	w.metrics.CommandDurationHist.WithLabelValues("du", w.queueType, group).Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			slog.Error("du command timed out", "path", path, "duration", execDuration, "timeout", timeout)
+			err = fmt.Errorf("%w after %s: %w", utils.ErrTimeout, execDuration.Round(time.Millisecond), err)
+		}
+
+		span.RecordError(err)
+
+		return 0, err
+	}
+
+	// Parse output
+	size, err := w.parseDuOutput(ctx, output)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	span.AddEvent("command_completed")
+
+	return size * unit, nil
+}
+
+// executeDuCommandWithDepth executes du with --max-depth to collect subdirectories
+// Extra arguments are inserted before the path (e.g. "-a" to include files)
+// Returns a map of path -> size in bytes. When du times out, the directories it
+// finished before then are returned along with the error.
+func (w *Worker) executeDuCommandWithDepth(ctx context.Context, group, path string, maxDepth int, timeout time.Duration, extraArgs ...string) (map[string]int64, error) {
+	ctx, span := w.startSpan(ctx, "command.du_depth", trace.WithAttributes(
+		attribute.String("command.path", path),
+		attribute.Int("command.max_depth", maxDepth),
+		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	if err := w.checkExecAllowed("du"); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Use du with -d (max depth) to get all subdirectories in one pass
+	// -x: don't cross filesystem boundaries
+	// -d: maximum depth to traverse (0 = base dir only, 1 = base + direct subdirs, etc.)
+	// Note: BusyBox du uses -d instead of --max-depth
+	// Note: We don't use -s (summarize) here because it conflicts with -d
+	groupArgs, unit := w.duArgs(group)
+
+	args := append([]string{"-x", "-d", strconv.Itoa(maxDepth)}, groupArgs...)
+	args = append(args, extraArgs...)
+	args = append(args, path)
+	cmd := w.command(timeoutCtx, "du", args...)
+	w.recordCommand(ctx, span, cmd.Args)
+
+	execStart := time.Now()
+	output, err := w.runDu(ctx, span, group, path, cmd)
+	execDuration := time.Since(execStart)
+	w.metrics.CommandDurationHist.WithLabelValues("du", w.queueType, group).Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			slog.Error("du command with depth timed out", "path", path, "max_depth", maxDepth, "duration", execDuration, "timeout", timeout)
+			err = fmt.Errorf("%w after %s: %w", utils.ErrTimeout, execDuration.Round(time.Millisecond), err)
+
+			span.RecordError(err)
+
+			return scaleDuSizes(w.parseDuPartialOutput(ctx, output, path), unit), err
+		}
+
+		span.RecordError(err)
+
+		return nil, err
+	}
+
+	// Parse output to extract all subdirectory sizes
+	subdirSizes, err := w.parseDuOutputWithDepth(ctx, output, path)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("command.subdirectories_found", len(subdirSizes)),
+	)
+	span.AddEvent("command_completed")
+
+	return scaleDuSizes(subdirSizes, unit), nil
+}
+
+// scaleDuSizes converts sizes du reported in units of unit bytes to bytes
+func scaleDuSizes(sizes map[string]int64, unit int64) map[string]int64 {
+	for path, size := range sizes {
+		sizes[path] = size * unit
+	}
+
+	return sizes
+}
+
+// executeScanCommand runs a mode exec group's scanner and returns its output
+func (w *Worker) executeScanCommand(ctx context.Context, group, path string, argv []string, timeout time.Duration) ([]byte, error) {
+	command := filepath.Base(argv[0])
+
+	ctx, span := w.startSpan(ctx, "command.scan", trace.WithAttributes(
+		attribute.String("command.name", command),
+		attribute.String("command.path", path),
+		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+	))
+	defer span.End()
+
+	if err := w.checkExecAllowed(command); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := w.command(timeoutCtx, argv[0], argv[1:]...)
+	w.recordCommand(ctx, span, cmd.Args)
+
+	execStart := time.Now()
+	output, err := w.commandOutput(ctx, span, cmd, group)
+	execDuration := time.Since(execStart)
+	w.metrics.CommandDurationHist.WithLabelValues(command, w.queueType, group).Observe(execDuration.Seconds())
+
+	span.SetAttributes(
+		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
+		attribute.Int("command.output_size_bytes", len(output)),
+	)
+
+	if err != nil {
+		if timeoutCtx.Err() == context.DeadlineExceeded {
+			span.SetAttributes(attribute.String("command.error_type", "timeout"))
+			slog.Error("Scanner command timed out", "command", command, "path", path, "duration", execDuration, "timeout", timeout)
+			err = fmt.Errorf("%w after %s: %w", utils.ErrTimeout, execDuration.Round(time.Millisecond), err)
+		}
+
+		span.RecordError(err)
+
+		return nil, err
+	}
+
+	span.AddEvent("command_completed")
+
+	return output, nil
+}
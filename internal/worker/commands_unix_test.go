@@ -0,0 +1,121 @@
+//go:build !windows
+
+package worker
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCommand_KillsProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The backgrounded sleeps hold the output pipe open, so the command only
+	// returns once the whole group is gone
+	tests := []struct {
+		name    string
+		script  string
+		sigkill bool
+	}{
+		{"exits on SIGTERM", "sleep 30 & wait", false},
+		{"ignores SIGTERM", "trap '' TERM; sleep 30 & wait", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Worker{
+				metrics: metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info")),
+				config: &config.Config{
+					CommandLimits: config.CommandLimitsConfig{KillGrace: config.Duration{Duration: 500 * time.Millisecond}},
+				},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+
+			if _, err := w.command(ctx, "sh", "-c", tt.script).Output(); err == nil {
+				t.Fatal("expected the command to be killed")
+			}
+
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the process group to be killed promptly, took %s", elapsed)
+			}
+
+			if terms := testutil.ToFloat64(w.metrics.CommandKilledCounter.WithLabelValues("SIGTERM")); terms != 1 {
+				t.Errorf("expected 1 SIGTERM, got %g", terms)
+			}
+
+			wantKills := 0.0
+			if tt.sigkill {
+				wantKills = 1
+			}
+
+			if kills := testutil.ToFloat64(w.metrics.CommandKilledCounter.WithLabelValues("SIGKILL")); kills != wantKills {
+				t.Errorf("expected %g SIGKILL, got %g", wantKills, kills)
+			}
+		})
+	}
+}
+
+func TestParseDuPartialOutput(t *testing.T) {
+	w := &Worker{}
+
+	// Killed while writing /srv/data/c, before the root's own line
+	output := []byte("120\t/srv/data/a/x\n300\t/srv/data/a\n75\t/srv/data/b\n9")
+
+	sizes := w.parseDuPartialOutput(context.Background(), output, "/srv/data")
+
+	want := map[string]int64{"/srv/data/a/x": 120, "/srv/data/a": 300, "/srv/data/b": 75}
+	if len(sizes) != len(want) {
+		t.Fatalf("expected %d directories, got %v", len(want), sizes)
+	}
+
+	for path, size := range want {
+		if sizes[path] != size {
+			t.Errorf("expected %s to be %d, got %d", path, size, sizes[path])
+		}
+	}
+
+	if sizes := w.parseDuPartialOutput(context.Background(), []byte("12"), "/srv/data"); len(sizes) != 0 {
+		t.Errorf("expected nothing from a cut off first line, got %v", sizes)
+	}
+}
+
+func TestCommandOutput_RecordsUsage(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	w := &Worker{
+		metrics:   metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info")),
+		config:    &config.Config{},
+		queueType: "directory",
+	}
+
+	usage := &commandUsage{}
+	ctx := context.WithValue(context.Background(), commandUsageKey{}, usage)
+
+	if _, err := w.commandOutput(ctx, trace.SpanFromContext(ctx), exec.Command("sh", "-c", "echo ok"), ""); err != nil {
+		t.Fatalf("commandOutput failed: %v", err)
+	}
+
+	if usage.maxRSS <= 0 {
+		t.Errorf("expected the command's peak memory to be added to the job, got %d", usage.maxRSS)
+	}
+
+	if rss := testutil.ToFloat64(w.metrics.CommandMaxRSSGauge.WithLabelValues("sh", "directory")); rss != float64(usage.maxRSS) {
+		t.Errorf("expected max_rss_bytes %d, got %g", usage.maxRSS, rss)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// df, du and Unix scanners don't exist on Windows. Validation only allows the statfs and
+// walk modes there, so these are never reached by a valid config.
+
+func (w *Worker) executeDfCommand(_ context.Context, _ string, _ ...string) ([]byte, error) {
+	return nil, fmt.Errorf("df is not available on windows, use mode statfs")
+}
+
+func (w *Worker) executeDuCommand(_ context.Context, _, _ string, _ time.Duration) (int64, error) {
+	return 0, fmt.Errorf("du is not available on windows, use mode walk")
+}
+
+func (w *Worker) executeDuCommandWithDepth(_ context.Context, _, _ string, _ int, _ time.Duration, _ ...string) (map[string]int64, error) {
+	return nil, fmt.Errorf("du is not available on windows, use mode walk")
+}
+
+func (w *Worker) executeScanCommand(_ context.Context, _, _ string, _ []string, _ time.Duration) ([]byte, error) {
+	return nil, fmt.Errorf("mode exec is not available on windows, use mode walk")
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/queue"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runLoops is how many jobs the worker runs at once: one, unless
+// device_concurrency lets directory jobs on different devices overlap
+func (w *Worker) runLoops() int {
+	if w.queueType == "directory" && w.config.DeviceConcurrency.Enabled {
+		return w.config.DeviceConcurrency.MaxWorkers
+	}
+
+	return 1
+}
+
+// deviceKey is the lock a directory job takes: its group's concurrency_group
+// when set, or else the device holding its path. It is empty when the device
+// can't be determined, and the job then runs without a lock.
+func (w *Worker) deviceKey(job queue.Job) string {
+	if group, exists := w.config.Directory(job.Name); exists && group.ConcurrencyGroup != "" {
+		return "group:" + group.ConcurrencyGroup
+	}
+
+	device, err := fsstat.DeviceID(job.Path)
+	if err != nil {
+		slog.Debug("Could not determine device, collecting without a device lock",
+			"group", job.Name,
+			"path", job.Path,
+			"error", err,
+		)
+
+		return ""
+	}
+
+	return "device:" + device
+}
+
+// acquireDevice waits until no other directory job holds the lock for job's
+// device and takes it. The returned function releases it. It gives up with
+// the context's error if ctx is done first.
+func (w *Worker) acquireDevice(ctx context.Context, job queue.Job) (func(), error) {
+	if w.runLoops() == 1 {
+		return func() {}, nil
+	}
+
+	key := w.deviceKey(job)
+	if key == "" {
+		return func() {}, nil
+	}
+
+	ctx, span := w.startSpan(ctx, "worker.acquire_device", trace.WithAttributes(
+		attribute.String("job.name", job.Name),
+		attribute.String("device.key", key),
+	))
+	defer span.End()
+
+	w.deviceMutex.Lock()
+
+	lock, exists := w.deviceLocks[key]
+	if !exists {
+		lock = make(chan struct{}, 1)
+		w.deviceLocks[key] = lock
+	}
+
+	w.deviceMutex.Unlock()
+
+	waitStart := time.Now()
+
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return nil, ctx.Err()
+	}
+
+	wait := time.Since(waitStart)
+
+	w.deviceMutex.Lock()
+	w.lockWaits[job.Name] = wait
+	w.deviceMutex.Unlock()
+
+	span.SetAttributes(attribute.Float64("device.wait_seconds", wait.Seconds()))
+
+	return func() { <-lock }, nil
+}
+
+// lockWait is how long the latest collection of a group waited for its device
+func (w *Worker) lockWait(groupName string) time.Duration {
+	w.deviceMutex.Lock()
+	defer w.deviceMutex.Unlock()
+
+	return w.lockWaits[groupName]
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package worker
+
+// BSD and macOS df report 512-byte blocks and may split columns differently
+// unless asked for POSIX output in 1K blocks
+var dfArgs = []string{"-k", "-P"}
+
+// FreeBSD and macOS du measure apparent sizes in bytes with these; NetBSD and
+// OpenBSD du have no apparent size option, so groups there need mode walk
+var duApparentArgs = []string{"-A", "-B", "1"}
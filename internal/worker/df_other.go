@@ -0,0 +1,10 @@
+//go:build !windows && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package worker
+
+// GNU coreutils and BusyBox df already report 1K blocks
+var dfArgs []string
+
+// GNU coreutils du measures apparent sizes in bytes with these. BusyBox du
+// has no apparent size option, so groups using it need mode walk.
+var duApparentArgs = []string{"--apparent-size", "-B1"}
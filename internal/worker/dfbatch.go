@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dfBatchFreshness is the share of an interval a batched df's rows are
+// reused for. Filesystems sharing an interval are scheduled together, so
+// their jobs all run well within it.
+const dfBatchFreshness = 4
+
+// dfBatch is the last batched df run for an interval
+type dfBatch struct {
+	taken time.Time
+	rows  map[string]dfUsage // By mount point; nil when the run failed
+}
+
+// dfBatches holds the last batched df run for each interval, with df_batch
+type dfBatches struct {
+	mu      sync.Mutex
+	batches map[time.Duration]*dfBatch
+}
+
+// batchedUsage returns a filesystem's usage from a batched df run, if
+// df_batch is enabled and the run has a row for it
+func (w *Worker) batchedUsage(ctx context.Context, fsConfig *config.FilesystemConfig) (dfUsage, bool) {
+	if !w.config.DfBatch.Enabled {
+		return dfUsage{}, false
+	}
+
+	return w.batchedDf(ctx, fsConfig)
+}
+
+// batchedDf returns a filesystem's usage from one df run covering every df
+// mode filesystem with its interval, running df if the last run for that
+// interval is stale. It returns false when the filesystem has no row, or the
+// run failed, for processFilesystem to run df for it alone and report the
+// failure.
+func (w *Worker) batchedDf(ctx context.Context, fsConfig *config.FilesystemConfig) (dfUsage, bool) {
+	interval := fsConfig.Interval.Duration
+
+	w.dfBatches.mu.Lock()
+	defer w.dfBatches.mu.Unlock()
+
+	batch := w.dfBatches.batches[interval]
+	if batch == nil || time.Since(batch.taken) > interval/dfBatchFreshness {
+		batch = w.runDfBatch(ctx, interval)
+
+		if w.dfBatches.batches == nil {
+			w.dfBatches.batches = make(map[time.Duration]*dfBatch)
+		}
+
+		w.dfBatches.batches[interval] = batch
+	}
+
+	usage, ok := batch.rows[filepath.Clean(fsConfig.MountPoint)]
+
+	return usage, ok
+}
+
+// runDfBatch runs df for every enabled df mode filesystem with the interval
+func (w *Worker) runDfBatch(ctx context.Context, interval time.Duration) *dfBatch {
+	var mountPoints []string
+
+	for _, fs := range w.config.Filesystems {
+		if fs.IsEnabled() && fs.Mode == config.FilesystemModeDf && fs.Interval.Duration == interval {
+			mountPoints = append(mountPoints, fs.MountPoint)
+		}
+	}
+
+	ctx, span := w.startSpan(ctx, "filesystem.df_batch", trace.WithAttributes(
+		attribute.String("df_batch.interval", interval.String()),
+		attribute.Int("df_batch.filesystems", len(mountPoints)),
+	))
+	defer span.End()
+
+	batch := &dfBatch{taken: time.Now()}
+
+	output, err := w.executeDfCommand(ctx, "", mountPoints...)
+	if err != nil {
+		slog.Warn("Batched df failed, running df for each filesystem", "interval", interval, "filesystems", len(mountPoints), "error", err)
+		span.RecordError(err)
+
+		return batch
+	}
+
+	batch.rows = parseDfRows(output)
+
+	span.SetAttributes(attribute.Int("df_batch.rows", len(batch.rows)))
+
+	return batch
+}
+
+// parseDfRows parses POSIX df output for several filesystems into their
+// usage by mount point, skipping rows that can't be parsed
+func parseDfRows(output []byte) map[string]dfUsage {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+
+	blockSize := dfBlockSize(lines[0])
+	rows := make(map[string]dfUsage, len(lines)-1)
+
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+
+		// The mount point is everything after the capacity column
+		usage, capacity, ok := parseDfFields(fields, blockSize)
+		if !ok || capacity == len(fields)-1 {
+			continue
+		}
+
+		rows[filepath.Clean(strings.Join(fields[capacity+1:], " "))] = usage
+	}
+
+	return rows
+}
@@ -0,0 +1,93 @@
+//go:build !windows
+
+package worker
+
+import (
+	"bytes"
+	"strings"
+
+	"filesystem-exporter/internal/utils"
+)
+
+// duStderrLimit caps how much of du's stderr is kept for error messages.
+// Every line is still counted.
+const duStderrLimit = 32 << 10
+
+// duStderr collects du's stderr as it runs, counting the entries du couldn't
+// read by reason. du reports each one on a line of its own and carries on.
+type duStderr struct {
+	root string // The path du was run on
+
+	head       []byte         // The start of the output
+	partial    []byte         // An incomplete last line
+	skipped    map[string]int // Entries skipped, by utils.FailureReason*
+	rootFailed bool           // Whether root itself couldn't be read
+}
+
+func newDuStderr(root string) *duStderr {
+	return &duStderr{root: root, skipped: make(map[string]int)}
+}
+
+func (d *duStderr) Write(p []byte) (int, error) {
+	if room := duStderrLimit - len(d.head); room > 0 {
+		d.head = append(d.head, p[:min(room, len(p))]...)
+	}
+
+	d.partial = append(d.partial, p...)
+
+	for {
+		end := bytes.IndexByte(d.partial, '\n')
+		if end < 0 {
+			break
+		}
+
+		d.countLine(string(d.partial[:end]))
+		d.partial = d.partial[end+1:]
+	}
+
+	return len(p), nil
+}
+
+// finish counts a last line without a newline, once du has exited
+func (d *duStderr) finish() {
+	d.countLine(string(d.partial))
+	d.partial = nil
+}
+
+// total is the number of entries du skipped
+func (d *duStderr) total() int {
+	total := 0
+	for _, count := range d.skipped {
+		total += count
+	}
+
+	return total
+}
+
+func (d *duStderr) countLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	// GNU du quotes paths as 'path' in the C locale, as does BusyBox
+	if strings.Contains(line, "'"+d.root+"'") {
+		d.rootFailed = true
+	}
+
+	d.skipped[duErrorReason(line)]++
+}
+
+// duErrorReason classifies a line of du's stderr, e.g. "du: cannot read
+// directory 'x': Permission denied"
+func duErrorReason(line string) string {
+	switch {
+	case strings.Contains(line, "Permission denied"), strings.Contains(line, "Operation not permitted"):
+		return utils.FailureReasonPermission
+	case strings.Contains(line, "No such file or directory"):
+		// Usually an entry deleted while du was scanning
+		return utils.FailureReasonNotFound
+	}
+
+	return utils.FailureReasonOther
+}
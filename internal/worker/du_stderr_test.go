@@ -0,0 +1,55 @@
+//go:build !windows
+
+package worker
+
+import (
+	"testing"
+
+	"filesystem-exporter/internal/utils"
+)
+
+func TestDuStderr(t *testing.T) {
+	stderr := newDuStderr("/srv/data")
+
+	// Written in pieces that split lines, as a pipe delivers them
+	for _, chunk := range []string{
+		"du: cannot read directory '/srv/data/private': Permission denied\ndu: cannot ",
+		"access '/srv/data/tmp/x': No such file or directory\n",
+		"du: can't open '/srv/data/locked': Operation not permitted\n",
+		"du: fts_read failed: '/srv/data/odd': Input/output error",
+	} {
+		if _, err := stderr.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stderr.finish()
+
+	want := map[string]int{
+		utils.FailureReasonPermission: 2,
+		utils.FailureReasonNotFound:   1,
+		utils.FailureReasonOther:      1,
+	}
+
+	for reason, count := range want {
+		if stderr.skipped[reason] != count {
+			t.Errorf("expected %d %s errors, got %d", count, reason, stderr.skipped[reason])
+		}
+	}
+
+	if stderr.total() != 4 {
+		t.Errorf("expected 4 skipped entries, got %d", stderr.total())
+	}
+
+	if stderr.rootFailed {
+		t.Error("expected only entries under the root to have failed")
+	}
+
+	if _, err := stderr.Write([]byte("du: cannot read directory '/srv/data': Permission denied\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !stderr.rootFailed {
+		t.Error("expected the root itself to have failed")
+	}
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"filesystem-exporter/internal/ncdu"
+	"filesystem-exporter/internal/version"
+	"filesystem-exporter/internal/walk"
+)
+
+// ncduExport writes a walk to a temporary file next to a group's
+// ncdu_export, which replaces the export once the walk completes, so ncdu
+// never opens a half written scan
+type ncduExport struct {
+	path   string
+	file   *os.File
+	writer *ncdu.Writer
+	dev    uint64 // Of the last entry with a device, which later ones inherit
+}
+
+// newNcduExport starts an export to path
+func newNcduExport(path string) (*ncduExport, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ncduExport{
+		path:   path,
+		file:   file,
+		writer: ncdu.NewWriter(file, "filesystem-exporter", version.Version, time.Now()),
+	}, nil
+}
+
+// visit adds an entry the walk came across, for walk.Options.Visit
+func (e *ncduExport) visit(v walk.Visit) {
+	entry := ncdu.Entry{ReadError: v.Err != nil}
+	isDir := v.IsDir
+
+	if v.Info != nil {
+		entry.Asize = v.Info.Size()
+		entry.Dsize = v.Usage
+		entry.Mtime = v.Info.ModTime().Unix()
+		entry.Notreg = !v.IsDir && !v.Info.Mode().IsRegular()
+
+		// ncdu counts a file with several links once by its inode
+		if !v.IsDir && v.Nlink > 1 {
+			entry.Hlnkc = true
+			entry.Ino = v.Ino
+			entry.Nlink = v.Nlink
+		}
+
+		if v.Dev != e.dev {
+			entry.Dev = v.Dev
+			e.dev = v.Dev
+		}
+	}
+
+	// Excluded directories are listed without contents, like files
+	if v.OtherFS {
+		entry.Excluded = "otherfs"
+		isDir = false
+	}
+
+	e.writer.Add(v.Path, isDir, entry)
+}
+
+// finish completes the export and moves it into place
+func (e *ncduExport) finish() error {
+	err := errors.Join(e.writer.Close(), e.file.Close())
+	if err == nil {
+		err = os.Rename(e.file.Name(), e.path)
+	}
+
+	if err != nil {
+		_ = os.Remove(e.file.Name())
+	}
+
+	return err
+}
+
+// abort discards the export, leaving the previous one in place
+func (e *ncduExport) abort() {
+	_ = e.file.Close()
+	_ = os.Remove(e.file.Name())
+}
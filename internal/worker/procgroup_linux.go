@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// processGroupAlive reports whether any process in the group is still
+// running. Zombies don't count: in a container the exporter is often PID 1
+// and inherits orphaned commands it never reaps.
+func processGroupAlive(pgid int) bool {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+
+		stat, err := os.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+
+		state, group, ok := parseProcStat(stat)
+		if ok && group == pgid && state != 'Z' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseProcStat extracts the state and process group from /proc/<pid>/stat,
+// whose fields follow the command name in parentheses
+func parseProcStat(stat []byte) (byte, int, bool) {
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	// state ppid pgrp ...
+	fields := bytes.Fields(stat[end+1:])
+	if len(fields) < 3 || len(fields[0]) != 1 {
+		return 0, 0, false
+	}
+
+	group, err := strconv.Atoi(string(fields[2]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return fields[0][0], group, true
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package worker
+
+import "golang.org/x/sys/unix"
+
+// processGroupAlive reports whether any process in the group still exists
+func processGroupAlive(pgid int) bool {
+	// Signal 0 only checks for the group's existence
+	return unix.Kill(-pgid, 0) == nil
+}
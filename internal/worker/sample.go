@@ -0,0 +1,263 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/queue"
+	"filesystem-exporter/internal/walk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampleBaseline is the outcome of a sample mode group's last full walk
+type sampleBaseline struct {
+	scannedAt time.Time
+	sizes     map[string]int64 // Subtree usage of each immediate child
+}
+
+// samplePoint is a sampled directory's size at the last full walk and now
+type samplePoint struct {
+	baseline float64
+	current  float64
+}
+
+// processDirectorySample estimates a group's size by rescanning a random
+// share of its immediate subdirectories and scaling the rest by how much the
+// sample changed since the last full walk. A full walk replaces the estimate
+// every full_scan_interval.
+func (w *Worker) processDirectorySample(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup) error {
+	ctx, span := w.startSpan(ctx, "directory.sample", trace.WithAttributes(
+		attribute.String("directory.path", job.Path),
+		attribute.Float64("sample.fraction", dirConfig.SampleFraction),
+	))
+	defer span.End()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	w.sampleMutex.Lock()
+	baseline := w.samples[job.Name]
+	w.sampleMutex.Unlock()
+
+	if baseline == nil || time.Since(baseline.scannedAt) >= dirConfig.FullScanInterval.Duration {
+		span.SetAttributes(attribute.Bool("sample.full_scan", true))
+
+		return w.fullSampleScan(timeoutCtx, span, job, dirConfig)
+	}
+
+	opts := walk.Options{
+		DropCache:      dirConfig.DropPageCache,
+		Apparent:       dirConfig.SizeMode == config.SizeModeApparent,
+		Sandbox:        dirConfig.Sandbox,
+		FilesPerSecond: dirConfig.IOLimit.FilesPerSecond,
+	}
+
+	rootUsage, entries, err := walk.List(job.Path, opts)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to list %s: %w", job.Path, err)
+	}
+
+	total := float64(rootUsage)
+
+	var (
+		candidates    []string
+		baselineTotal float64
+		skipped       int
+	)
+
+	for _, entry := range entries {
+		if !entry.IsDir {
+			total += float64(entry.Usage)
+			continue
+		}
+
+		if size, exists := baseline.sizes[entry.Path]; exists {
+			candidates = append(candidates, entry.Path)
+			baselineTotal += float64(size)
+
+			continue
+		}
+
+		// New since the last full walk, so there's nothing to scale from
+		size, err := w.walkSubtree(timeoutCtx, job.Name, entry, opts)
+		if err != nil {
+			if timeoutCtx.Err() != nil {
+				span.RecordError(err)
+				return fmt.Errorf("directory sample failed: %w", err)
+			}
+
+			skipped++
+		}
+
+		total += float64(size)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	n := sampleSize(len(candidates), dirConfig.SampleFraction)
+	points := make([]samplePoint, 0, n)
+
+	for _, path := range candidates[:n] {
+		entry := walk.Entry{Path: path, IsDir: true}
+
+		size, err := w.walkSubtree(timeoutCtx, job.Name, entry, opts)
+		if err != nil {
+			if timeoutCtx.Err() != nil {
+				span.RecordError(err)
+				return fmt.Errorf("directory sample failed: %w", err)
+			}
+
+			// Removed or unreadable since the last full walk
+			skipped++
+		}
+
+		points = append(points, samplePoint{baseline: float64(baseline.sizes[path]), current: float64(size)})
+	}
+
+	estimate, stdErr := estimateRatio(points, baselineTotal, len(candidates))
+	total += estimate
+
+	relativeError := 0.0
+	if total > 0 {
+		relativeError = stdErr / total
+	}
+
+	if skipped > 0 {
+		slog.Warn("Directory sample skipped unreadable subdirectories", "group", job.Name, "path", job.Path, "skipped", skipped)
+	}
+
+	sizeBytes := int64(math.Round(total))
+
+	w.updateDirectoryMetrics(ctx, job.Name, job.Path, config.DirectoryModeSample, sizeBytes, 0)
+	w.recordGroupTotal(ctx, job.Name, sizeBytes, dirConfig)
+	w.metrics.DirectoryEstimateRelativeErrorGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, job.Name)...).Set(relativeError)
+
+	span.SetAttributes(
+		attribute.Int("sample.population", len(candidates)),
+		attribute.Int("sample.size", len(points)),
+		attribute.Float64("sample.relative_error", relativeError),
+		attribute.Int64("directory.size_bytes", sizeBytes),
+	)
+	span.AddEvent("directory_collected")
+
+	return nil
+}
+
+// fullSampleScan walks the whole tree for an exact size and keeps the size
+// of each immediate child as the baseline for later samples
+func (w *Worker) fullSampleScan(ctx context.Context, span trace.Span, job queue.Job, dirConfig config.DirectoryGroup) error {
+	walkStart := time.Now()
+
+	result, err := walk.Walk(ctx, job.Path, walk.Options{
+		DropCache:      dirConfig.DropPageCache,
+		Apparent:       dirConfig.SizeMode == config.SizeModeApparent,
+		Sandbox:        dirConfig.Sandbox,
+		FilesPerSecond: dirConfig.IOLimit.FilesPerSecond,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("directory walk failed: %w", err)
+	}
+
+	w.recordCacheAdvice(job.Name, result)
+
+	if result.Errors > 0 {
+		slog.Warn("Directory walk skipped unreadable entries", "group", job.Name, "path", job.Path, "errors", result.Errors)
+	}
+
+	w.sampleMutex.Lock()
+	w.samples[job.Name] = &sampleBaseline{scannedAt: walkStart, sizes: result.Entries}
+	w.sampleMutex.Unlock()
+
+	sizeBytes := result.Directories[job.Path]
+
+	w.updateDirectoryMetrics(ctx, job.Name, job.Path, config.DirectoryModeSample, sizeBytes, 0)
+	w.recordGroupTotal(ctx, job.Name, sizeBytes, dirConfig)
+	w.metrics.DirectoryEstimateRelativeErrorGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, job.Name)...).Set(0)
+	w.metrics.DirectoryLastFullScanGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, job.Name)...).Set(float64(walkStart.Unix()))
+
+	span.SetAttributes(
+		attribute.Float64("walk.duration_seconds", time.Since(walkStart).Seconds()),
+		attribute.Int64("directory.size_bytes", sizeBytes),
+	)
+	span.AddEvent("directory_collected")
+
+	return nil
+}
+
+// walkSubtree returns the disk usage of an entry's subtree. On error the
+// entry's own usage is returned, as a full walk would count it.
+func (w *Worker) walkSubtree(ctx context.Context, groupName string, entry walk.Entry, opts walk.Options) (int64, error) {
+	result, err := walk.Walk(ctx, entry.Path, opts)
+	if err != nil {
+		return entry.Usage, err
+	}
+
+	w.recordCacheAdvice(groupName, result)
+
+	return result.Directories[entry.Path], nil
+}
+
+// sampleSize is how many of n directories to rescan. At least two are taken
+// so the spread of the sample can be measured.
+func sampleSize(n int, fraction float64) int {
+	return min(n, max(2, int(math.Ceil(float64(n)*fraction))))
+}
+
+// estimateRatio extrapolates the current total of a population of
+// directories, whose total at the last full walk was baselineTotal, from a
+// simple random sample. It uses the ratio of current to baseline sizes in the
+// sample and returns the estimate with its approximate standard error.
+func estimateRatio(sample []samplePoint, baselineTotal float64, population int) (float64, float64) {
+	n := len(sample)
+	if n == 0 {
+		return baselineTotal, 0
+	}
+
+	var sampledBaseline, sampledCurrent float64
+
+	for _, point := range sample {
+		sampledBaseline += point.baseline
+		sampledCurrent += point.current
+	}
+
+	// residual is how far a directory is from the sample's overall trend. If
+	// the sampled directories were all empty, fall back to absolute growth.
+	var estimate float64
+
+	residual := func(p samplePoint) float64 { return p.current - p.baseline }
+
+	if sampledBaseline > 0 {
+		ratio := sampledCurrent / sampledBaseline
+		estimate = ratio * baselineTotal
+		residual = func(p samplePoint) float64 { return p.current - ratio*p.baseline }
+	} else {
+		estimate = baselineTotal + (sampledCurrent-sampledBaseline)*float64(population)/float64(n)
+	}
+
+	if n < 2 || n >= population {
+		return estimate, 0
+	}
+
+	var sumSquares float64
+
+	for _, point := range sample {
+		r := residual(point)
+		sumSquares += r * r
+	}
+
+	variance := sumSquares / float64(n-1)
+	size := float64(population)
+	finiteCorrection := 1 - float64(n)/size
+
+	return estimate, math.Sqrt(size * size * finiteCorrection * variance / float64(n))
+}
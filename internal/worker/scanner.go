@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/queue"
+	"filesystem-exporter/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scanPathPlaceholder is replaced by the group's path in a scanner's command
+const scanPathPlaceholder = "{path}"
+
+// processDirectoryExec collects a directory group by running its scanner
+// command, whose sizes are exported like du's
+func (w *Worker) processDirectoryExec(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup, subdirectoryLevels int) error {
+	ctx, span := w.startSpan(ctx, "directory.exec", trace.WithAttributes(
+		attribute.String("directory.path", job.Path),
+	))
+	defer span.End()
+
+	output, err := w.executeScanCommand(ctx, job.Name, job.Path, scanArgs(dirConfig.Command, job.Path), job.Timeout)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("scanner command failed: %w", err)
+	}
+
+	sizes, err := w.parseScanOutput(output, job.Path, subdirectoryLevels)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("scanner command failed: %w", err)
+	}
+
+	w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeExec, sizes)
+
+	span.SetAttributes(attribute.Int("directory.subdirectories_collected", len(sizes)))
+	span.AddEvent("directory_collected")
+
+	return nil
+}
+
+// scanArgs is a scanner's argv for path: every {path} in command replaced
+// by it, or path appended when there is none
+func scanArgs(command []string, path string) []string {
+	argv := make([]string, 0, len(command)+1)
+	substituted := false
+
+	for _, arg := range command {
+		if strings.Contains(arg, scanPathPlaceholder) {
+			arg = strings.ReplaceAll(arg, scanPathPlaceholder, path)
+			substituted = true
+		}
+
+		argv = append(argv, arg)
+	}
+
+	if !substituted {
+		argv = append(argv, path)
+	}
+
+	return argv
+}
+
+// parseScanOutput parses a scanner's output: one "<bytes> <path>" line per
+// directory, the size a non-negative integer and the path, which may contain
+// spaces, either absolute or relative to root. Every path must be root or
+// below it and root itself must be reported. Directories deeper than
+// maxDepth are ignored; a directory reported twice takes its last size.
+func (w *Worker) parseScanOutput(output []byte, root string, maxDepth int) (map[string]int64, error) {
+	root = filepath.Clean(root)
+	below := strings.TrimRight(root, string(filepath.Separator)) + string(filepath.Separator)
+	sizes := make(map[string]int64)
+
+	for i, line := range strings.Split(string(output), "\n") {
+		text := strings.TrimLeft(strings.TrimRight(line, "\r"), " \t")
+		if text == "" {
+			continue
+		}
+
+		sep := strings.IndexAny(text, " \t")
+		if sep < 0 {
+			return nil, fmt.Errorf("%w on line %d: expected \"<bytes> <path>\", got %q", utils.ErrParse, i+1, text)
+		}
+
+		sizeStr, path := text[:sep], strings.TrimLeft(text[sep:], " \t")
+
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("%w on line %d: invalid size %q", utils.ErrParse, i+1, sizeStr)
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+
+		path = filepath.Clean(path)
+		if path != root && !strings.HasPrefix(path, below) {
+			return nil, fmt.Errorf("%w on line %d: %s is outside %s", utils.ErrParse, i+1, path, root)
+		}
+
+		if w.calculateSubdirectoryLevel(root, path) > maxDepth {
+			continue
+		}
+
+		sizes[path] = size
+	}
+
+	if _, exists := sizes[root]; !exists {
+		return nil, fmt.Errorf("%w: no size reported for %s itself", utils.ErrParse, root)
+	}
+
+	return sizes, nil
+}
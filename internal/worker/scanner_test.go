@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"filesystem-exporter/internal/utils"
+)
+
+func TestScanArgs(t *testing.T) {
+	tests := []struct {
+		command  []string
+		expected []string
+	}{
+		{[]string{"scan", "--bytes"}, []string{"scan", "--bytes", "/data"}},
+		{[]string{"scan", "--root={path}", "--depth", "2"}, []string{"scan", "--root=/data", "--depth", "2"}},
+	}
+
+	for _, tt := range tests {
+		if argv := scanArgs(tt.command, "/data"); !reflect.DeepEqual(argv, tt.expected) {
+			t.Errorf("scanArgs(%q) = %q, expected %q", tt.command, argv, tt.expected)
+		}
+	}
+}
+
+func TestParseScanOutput(t *testing.T) {
+	w := &Worker{}
+
+	output := "4096 /data\n1024\tprojects\n  512 /data/my docs\n64 /data/projects/deep\r\n\n"
+
+	sizes, err := w.parseScanOutput([]byte(output), "/data/", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]int64{"/data": 4096, "/data/projects": 1024, "/data/my docs": 512}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected %v, got %v", expected, sizes)
+	}
+
+	for name, invalid := range map[string]string{
+		"no path":       "4096\n",
+		"negative size": "-1 /data\n",
+		"size in KiB":   "4K /data\n",
+		"outside root":  "4096 /data\n10 /data-old\n",
+		"escapes root":  "4096 /data\n10 ../etc\n",
+		"no root":       "10 /data/projects\n",
+	} {
+		if _, err := w.parseScanOutput([]byte(invalid), "/data", 1); !errors.Is(err, utils.ErrParse) {
+			t.Errorf("%s: expected a parse error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/queue"
+)
+
+// otherDirectory is the directory label of the series that sums the
+// directories the other strategy leaves out of a level
+const otherDirectory = "__other__"
+
+// directorySeriesKey identifies one of a group's directory size series
+type directorySeriesKey struct {
+	path  string
+	level int
+}
+
+// directorySize is one directory measured by a collection
+type directorySize struct {
+	path      string
+	level     int
+	sizeBytes int64
+}
+
+// exportDirectorySizes sets the size series of every directory a du or walk
+// collection measured, capped at the group's max_series, and deletes those
+// of directories exported last time but not this time. It returns the paths
+// that were exported.
+func (w *Worker) exportDirectorySizes(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup, mode string, sizes map[string]int64) map[string]bool {
+	series := make([]directorySize, 0, len(sizes))
+
+	for path, sizeBytes := range sizes {
+		level := w.calculateSubdirectoryLevel(job.Path, path)
+		series = append(series, directorySize{path: path, level: level, sizeBytes: sizeBytes})
+
+		if level == 0 {
+			w.recordGroupTotal(ctx, job.Name, sizeBytes, dirConfig)
+		}
+	}
+
+	if dirConfig.MaxSeries > 0 {
+		var dropped int
+
+		series, dropped = limitDirectorySeries(series, dirConfig.MaxSeries, dirConfig.MaxSeriesStrategy)
+		if dropped > 0 {
+			w.metrics.SeriesDroppedCounter.WithLabelValues(job.Name).Add(float64(dropped))
+		}
+	}
+
+	w.forgetDirectorySeries(job.Name, mode, dirConfig.Labels, series, sizes)
+
+	exported := make(map[string]bool, len(series))
+
+	for _, s := range series {
+		w.updateDirectoryMetrics(ctx, job.Name, s.path, mode, s.sizeBytes, s.level)
+		exported[s.path] = true
+	}
+
+	return exported
+}
+
+// exportPartialDirectorySizes sets the size series of the directories a du
+// collection that timed out finished before it was stopped. Series of
+// directories it didn't reach are left as they were. A capped group only has
+// the series it already exports updated, since the directories that weren't
+// reached can't be ranked. It returns how many series were updated.
+func (w *Worker) exportPartialDirectorySizes(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup, mode string, sizes map[string]int64) int {
+	w.seriesMutex.Lock()
+	exported := w.series[job.Name]
+	w.seriesMutex.Unlock()
+
+	updated := 0
+
+	for path, sizeBytes := range sizes {
+		level := w.calculateSubdirectoryLevel(job.Path, path)
+
+		if dirConfig.MaxSeries > 0 && !exported[directorySeriesKey{path: path, level: level}] {
+			continue
+		}
+
+		w.updateDirectoryMetrics(ctx, job.Name, path, mode, sizeBytes, level)
+		updated++
+	}
+
+	return updated
+}
+
+// limitDirectorySeries keeps at most maxSeries of a collection's directories.
+// The group's root is always kept and the rest are ranked by size. With the
+// other strategy, each level's leftovers are summed into a __other__ entry,
+// which takes up one of the slots, so the levels still add up. It returns the
+// entries to export and how many directories were left out.
+func limitDirectorySeries(sizes []directorySize, maxSeries int, strategy string) ([]directorySize, int) {
+	if len(sizes) <= maxSeries {
+		return sizes, 0
+	}
+
+	var kept, rest []directorySize
+
+	levels := make(map[int]bool)
+
+	for _, s := range sizes {
+		if s.level == 0 {
+			kept = append(kept, s)
+			continue
+		}
+
+		rest = append(rest, s)
+		levels[s.level] = true
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i].sizeBytes != rest[j].sizeBytes {
+			return rest[i].sizeBytes > rest[j].sizeBytes
+		}
+
+		return rest[i].path < rest[j].path
+	})
+
+	slots := maxSeries - len(kept)
+	if strategy == config.SeriesStrategyOther {
+		slots -= len(levels)
+	}
+
+	slots = max(0, min(slots, len(rest)))
+	kept = append(kept, rest[:slots]...)
+
+	if strategy == config.SeriesStrategyOther {
+		others := make(map[int]int64)
+		for _, s := range rest[slots:] {
+			others[s.level] += s.sizeBytes
+		}
+
+		for level, sizeBytes := range others {
+			kept = append(kept, directorySize{path: otherDirectory, level: level, sizeBytes: sizeBytes})
+		}
+	}
+
+	return kept, len(rest) - slots
+}
+
+// forgetDirectorySeries deletes the series a group exported last time but
+// not this time, so directories that were deleted or fell out of the largest
+// ones don't linger with stale sizes. Directories that weren't measured at
+// all are reported by filesystem_exporter_directory_missing until the next
+// collection.
+func (w *Worker) forgetDirectorySeries(groupName, mode string, labels map[string]string, series []directorySize, measured map[string]int64) {
+	// __other__ is exported once per level, so the level is part of the key
+	current := make(map[directorySeriesKey]bool, len(series))
+	for _, s := range series {
+		current[directorySeriesKey{path: s.path, level: s.level}] = true
+	}
+
+	w.seriesMutex.Lock()
+	previous := w.series[groupName]
+	w.series[groupName] = current
+	wasMissing := w.missing[groupName]
+	w.seriesMutex.Unlock()
+
+	for _, directory := range wasMissing {
+		w.metrics.DirectoryMissingGauge.DeleteLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...)
+	}
+
+	sizeMode := w.config.GetDirectorySizeMode(groupName)
+
+	var missing []string
+
+	for key := range previous {
+		if current[key] {
+			continue
+		}
+
+		directory := w.directoryLabel(groupName, key.path)
+
+		sizeValues := w.metrics.ItemLabelValues(labels, groupName, directory, mode, sizeMode, strconv.Itoa(key.level))
+
+		w.metrics.DirectorySizeGauge.DeleteLabelValues(sizeValues...)
+		w.metrics.DirectorySizeSmoothedGauge.DeleteLabelValues(sizeValues...)
+		w.metrics.DeleteDirectorySampleTime(sizeValues...)
+		w.metrics.DirectoryLastModifiedGauge.DeleteLabelValues(w.metrics.ItemLabelValues(labels, groupName, key.path)...)
+		w.metrics.DuLockWaitDurationGauge.DeleteLabelValues(groupName, key.path)
+		w.metrics.DirectorySizeDeltaGauge.DeleteLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...)
+		w.forgetSize(groupName, key.path)
+
+		if _, exists := measured[key.path]; exists || key.path == otherDirectory {
+			continue
+		}
+
+		// Gone from the tree rather than left out by max_series
+		w.metrics.DirectoryExpectedSizeGauge.DeleteLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...)
+		w.metrics.DirectoryVarianceGauge.DeleteLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...)
+		w.metrics.DirectoryMissingGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...).Set(1)
+		w.forgetSmoothed(groupName, key.path)
+
+		missing = append(missing, directory)
+	}
+
+	w.seriesMutex.Lock()
+	w.missing[groupName] = missing
+	w.seriesMutex.Unlock()
+}
@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"filesystem-exporter/internal/queue"
+	"filesystem-exporter/internal/state"
+	"filesystem-exporter/internal/utils"
+)
+
+// stuckJobTimeouts is how many of its timeouts a job may run for before its
+// run loop counts as stuck. A directory job runs several commands or walks,
+// each with the timeout, and each is killed when it runs out, so only I/O
+// the kernel won't interrupt, like a hung NFS server's, gets that far.
+const stuckJobTimeouts = 4
+
+// StuckAfter is how long a job of an item with the given timeout may run
+// before Supervise abandons it, or zero if it may run forever: stuckJobTimeouts
+// of its timeouts for each attempt its retry policy allows, and the waits
+// between them
+func (w *Worker) StuckAfter(itemType, name string, timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return 0
+	}
+
+	policy := w.retryPolicy(itemType, name)
+	attempts := time.Duration(max(policy.MaxAttempts, 1))
+
+	return attempts*stuckJobTimeouts*timeout + (attempts-1)*policy.MaxDelay
+}
+
+// Reasons a run loop is restarted, the reason label of worker_restarts_total
+const (
+	RestartReasonStuck  = "stuck"  // Its job ran past StuckAfter
+	RestartReasonExited = "exited" // Its goroutine ended while the worker was running
+)
+
+// runLoop is one of a worker's run loops, with the job it is processing
+type runLoop struct {
+	release sync.Once // Releases the loop's hold on the worker's WaitGroup
+
+	mu        sync.Mutex
+	job       *queue.Job
+	jobState  *state.JobState
+	started   time.Time
+	cancel    context.CancelFunc // Cancels the job in progress
+	abandoned bool               // Replaced by another loop, so it exits after its job
+	exited    bool
+}
+
+// begin records the job the loop is starting
+func (l *runLoop) begin(job *queue.Job, jobState *state.JobState, cancel context.CancelFunc) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.job, l.jobState, l.started, l.cancel = job, jobState, jobState.StartedAt, cancel
+}
+
+// end records that the loop's job is over
+func (l *runLoop) end() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.job, l.jobState, l.cancel = nil, nil, nil
+}
+
+// isAbandoned reports whether the loop has been replaced. A nil loop, for
+// jobs run by Process, never is.
+func (l *runLoop) isAbandoned() bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.abandoned
+}
+
+// startLoop starts a run loop with the contexts Start was given
+func (w *Worker) startLoop() {
+	loop := &runLoop{}
+
+	w.loopsMutex.Lock()
+	w.loops = append(w.loops, loop)
+	ctx, jobsCtx := w.runCtx, w.jobsCtx
+	w.loopsMutex.Unlock()
+
+	w.wg.Add(1)
+
+	go func() {
+		defer loop.release.Do(w.wg.Done)
+
+		defer func() {
+			loop.mu.Lock()
+			loop.exited = true
+			loop.mu.Unlock()
+		}()
+
+		w.run(ctx, jobsCtx, loop)
+	}()
+}
+
+// Supervise restarts the run loops whose job has run past StuckAfter, or
+// whose goroutine has ended while the worker is running. A stuck job is
+// cancelled and recorded as a timeout, and its goroutine left to exit
+// whenever its I/O returns; until then its item isn't scheduled again.
+// Shutdown doesn't wait for abandoned loops.
+func (w *Worker) Supervise(ctx context.Context, now time.Time) {
+	w.loopsMutex.Lock()
+	loops := slices.Clone(w.loops)
+	running := w.runCtx != nil && w.runCtx.Err() == nil
+	w.loopsMutex.Unlock()
+
+	if !running {
+		return
+	}
+
+	for _, loop := range loops {
+		reason := w.checkLoop(ctx, loop, now)
+		if reason == "" {
+			continue
+		}
+
+		w.loopsMutex.Lock()
+		w.loops = slices.DeleteFunc(w.loops, func(l *runLoop) bool { return l == loop })
+		w.loopsMutex.Unlock()
+
+		loop.release.Do(w.wg.Done)
+
+		w.metrics.WorkerRestartsCounter.WithLabelValues(w.queueType, reason).Inc()
+		w.startLoop()
+	}
+}
+
+// checkLoop returns why a run loop needs restarting, or empty if it doesn't.
+// A stuck loop's job is cancelled and recorded as failed.
+func (w *Worker) checkLoop(ctx context.Context, loop *runLoop, now time.Time) string {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+
+	if loop.abandoned {
+		return ""
+	}
+
+	if loop.exited {
+		slog.Error("Worker run loop exited unexpectedly, restarting it", "queue_type", w.queueType)
+		return RestartReasonExited
+	}
+
+	if loop.job == nil {
+		return ""
+	}
+
+	running := now.Sub(loop.started)
+
+	stuckAfter := w.StuckAfter(loop.job.Type, loop.job.Name, loop.job.Timeout)
+	if stuckAfter == 0 || running <= stuckAfter {
+		return ""
+	}
+
+	loop.abandoned = true
+	loop.cancel()
+
+	slog.Error("Job stuck past its timeout, abandoning it and restarting the worker run loop",
+		"queue_type", w.queueType,
+		"job_id", loop.job.ID,
+		"job_name", loop.job.Name,
+		"job_path", loop.job.Path,
+		"running", running,
+		"timeout", loop.job.Timeout,
+		"error_class", utils.FailureReasonTimeout,
+		"trace_id", loop.jobState.TraceID,
+	)
+
+	w.state.ClearRunningJob(ctx, w.queueType, loop.job.ID, running)
+
+	w.recordFailure(ctx, *loop.job, state.JobResult{
+		JobID:    loop.job.ID,
+		Duration: running,
+		Err:      fmt.Errorf("abandoned after running for %s, stuck past its %s timeout: %w", running.Round(time.Second), loop.job.Timeout, utils.ErrTimeout),
+		Reason:   utils.FailureReasonTimeout,
+	})
+
+	return RestartReasonStuck
+}
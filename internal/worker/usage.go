@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// commandUsage adds up the CPU time and peak memory of the commands a job
+// runs, for its resource metrics
+type commandUsage struct {
+	mutex  sync.Mutex
+	user   time.Duration
+	system time.Duration
+	maxRSS int64
+}
+
+// commandUsageKey is the context key of the running job's commandUsage
+type commandUsageKey struct{}
+
+// add records a finished command
+func (u *commandUsage) add(user, system time.Duration, maxRSS int64) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.user += user
+	u.system += system
+	u.maxRSS = max(u.maxRSS, maxRSS)
+}
+
+// cpuSeconds returns the user and system CPU time of the commands so far
+func (u *commandUsage) cpuSeconds() (user, system float64) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.user.Seconds(), u.system.Seconds()
+}
+
+// commandUsageFrom returns the usage of the job ctx belongs to, or nil
+// outside a job
+func commandUsageFrom(ctx context.Context) *commandUsage {
+	usage, _ := ctx.Value(commandUsageKey{}).(*commandUsage)
+	return usage
+}
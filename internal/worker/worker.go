@@ -2,21 +2,33 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
-	"os/exec"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"filesystem-exporter/internal/alerts"
 	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/fsstat"
+	"filesystem-exporter/internal/growth"
+	"filesystem-exporter/internal/limits"
 	"filesystem-exporter/internal/metrics"
 	"filesystem-exporter/internal/queue"
+	"filesystem-exporter/internal/quotactl"
 	"filesystem-exporter/internal/state"
-	"github.com/d0ugal/promexporter/tracing"
+	"filesystem-exporter/internal/tracing"
+	"filesystem-exporter/internal/utils"
+	"filesystem-exporter/internal/walk"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -30,10 +42,70 @@ type Worker struct {
 	config    *config.Config
 	tracer    *tracing.Tracer
 	queueType string // "filesystem" or "directory"
+
+	// Optional resource limits for spawned commands
+	limiter *limits.Limiter
+
+	// Optional threshold alerts, evaluated after each collection
+	alerts *alerts.Manager
+
+	// Optional growth estimates, updated after each collection
+	growth *growth.Tracker
+
+	// Exponential moving averages of directory sizes, keyed by group and path
+	emaMutex sync.Mutex
+	ema      map[string]float64
+
+	// Directory sizes at their previous collection, keyed by group and path
+	lastSizesMutex sync.Mutex
+	lastSizes      map[string]int64
+
+	// Baselines from the last full walk of each sample mode group
+	sampleMutex sync.Mutex
+	samples     map[string]*sampleBaseline
+
+	// Directory series exported by the last collection of each group, so
+	// those of directories that disappear or fall out of max_series can be
+	// deleted, and the directory labels last reported missing
+	seriesMutex sync.Mutex
+	series      map[string]map[directorySeriesKey]bool
+	missing     map[string][]string
+
+	// Cached uid/gid -> name lookups for owner breakdowns
+	ownerNames sync.Map
+
+	// With df_batch, the last df run covering each interval's filesystems
+	dfBatches dfBatches
+
+	// With device_concurrency, a lock per device or concurrency_group that
+	// directory jobs hold while they run, and how long each group last
+	// waited for its lock
+	deviceMutex sync.Mutex
+	deviceLocks map[string]chan struct{}
+	lockWaits   map[string]time.Duration
+
+	// Cancels the jobs the run loops are processing. Stopping the worker
+	// only stops it taking new jobs, so those in progress can finish first.
+	abortMutex sync.Mutex
+	abort      context.CancelFunc
+
+	// Tracks the run loops so Wait can block until they have exited
+	wg sync.WaitGroup
+
+	// The run loops, and the contexts Start gave them, so Supervise can
+	// replace stuck ones
+	loopsMutex sync.Mutex
+	loops      []*runLoop
+	runCtx     context.Context
+	jobsCtx    context.Context
 }
 
+// jobIDKey is the context key of the ID of the job a command runs for, so
+// it's recorded against the right one of several running jobs
+type jobIDKey struct{}
+
 // NewWorker creates a new worker
-func NewWorker(q *queue.Queue, m *metrics.FilesystemRegistry, s *state.Tracker, cfg *config.Config, tracer *tracing.Tracer, queueType string) *Worker {
+func NewWorker(q *queue.Queue, m *metrics.FilesystemRegistry, s *state.Tracker, cfg *config.Config, limiter *limits.Limiter, alertManager *alerts.Manager, growthTracker *growth.Tracker, tracer *tracing.Tracer, queueType string) *Worker {
 	return &Worker{
 		queue:     q,
 		metrics:   m,
@@ -41,40 +113,112 @@ func NewWorker(q *queue.Queue, m *metrics.FilesystemRegistry, s *state.Tracker,
 		config:    cfg,
 		tracer:    tracer,
 		queueType: queueType,
+		limiter:   limiter,
+		alerts:    alertManager,
+		growth:    growthTracker,
+		ema:       make(map[string]float64),
+		lastSizes: make(map[string]int64),
+		samples:   make(map[string]*sampleBaseline),
+		series:    make(map[string]map[directorySeriesKey]bool),
+		missing:   make(map[string][]string),
+
+		deviceLocks: make(map[string]chan struct{}),
+		lockWaits:   make(map[string]time.Duration),
 	}
 }
 
-// Start starts the worker goroutine
+// Start starts the worker goroutines: one, or with device_concurrency up to
+// max_workers for directory jobs. Cancelling ctx stops them taking new jobs;
+// jobs in progress run until they finish or Abort is called.
 func (w *Worker) Start(ctx context.Context) {
+	loops := w.runLoops()
+
 	ctx, span := w.startSpan(ctx, "worker.start", trace.WithAttributes(
 		attribute.String("worker.queue_type", w.queueType),
+		attribute.Int("worker.run_loops", loops),
 	))
 	defer span.End()
 
-	go w.run(ctx)
+	jobsCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+
+	w.abortMutex.Lock()
+	w.abort = abort
+	w.abortMutex.Unlock()
+
+	w.loopsMutex.Lock()
+	w.loops = nil
+	w.runCtx, w.jobsCtx = ctx, jobsCtx
+	w.loopsMutex.Unlock()
+
+	for range loops {
+		w.startLoop()
+	}
 
 	span.AddEvent("worker_started")
 }
 
-// run is the main worker loop
-func (w *Worker) run(ctx context.Context) {
+// Wait blocks until the worker's run loops have exited after its context was
+// cancelled, including any job that was in progress. Loops abandoned by
+// Supervise aren't waited for.
+func (w *Worker) Wait() {
+	w.wg.Wait()
+}
+
+// Abort cancels the jobs in progress, killing their commands. It is safe to
+// call at any time, and more than once.
+func (w *Worker) Abort() {
+	w.abortMutex.Lock()
+	defer w.abortMutex.Unlock()
+
+	if w.abort != nil {
+		w.abort()
+	}
+}
+
+// run is the main worker loop. It takes jobs until ctx is cancelled or
+// Supervise abandons it, and jobsCtx aborts the one in progress.
+func (w *Worker) run(ctx, jobsCtx context.Context, loop *runLoop) {
 	slog.Info("Worker started", "queue_type", w.queueType)
 
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("Worker stopping", "queue_type", w.queueType)
-			return
-		case job := <-w.queue.Channel():
-			w.processJob(ctx, job)
+	for ctx.Err() == nil && !loop.isAbandoned() {
+		// Dequeue records the time spent idle
+		job, err := w.queue.Dequeue(ctx)
+		if err != nil {
+			break
 		}
+
+		w.metrics.QueueWaitSecondsGauge.WithLabelValues(w.queueType).Set(time.Since(job.CreatedAt).Seconds())
+
+		w.processJob(ctx, jobsCtx, job, loop)
 	}
+
+	slog.Info("Worker stopping", "queue_type", w.queueType)
 }
 
-// processJob processes a single job
-func (w *Worker) processJob(_ context.Context, job queue.Job) {
-	// Use job context which has the trace span
-	ctx := job.Context
+// Process runs a single job directly, without going through the queue. It
+// is used for one-shot runs; the outcome is recorded in the state tracker.
+func (w *Worker) Process(ctx context.Context, job queue.Job) {
+	w.processJob(ctx, ctx, job, nil)
+}
+
+// processJob processes a single job for loop, which is nil for jobs run by
+// Process. A job still waiting for its device when stopCtx is cancelled is
+// abandoned, and abortCtx cancels it wherever it is.
+func (w *Worker) processJob(stopCtx, abortCtx context.Context, job queue.Job, loop *runLoop) {
+	// Tell the scheduler the job is over, however it ends
+	defer job.Finish()
+
+	// A directory group removed while its job was queued or running keeps
+	// none of the series the job wrote
+	defer w.forgetRemovedGroup(job)
+
+	// Use job context which has the trace span, but abort the job along with
+	// the worker's jobs
+	ctx, cancel := context.WithCancel(job.Context)
+	defer cancel()
+
+	stop := context.AfterFunc(abortCtx, cancel)
+	defer stop()
 
 	ctx, span := w.startSpan(ctx, "worker.process_job", trace.WithAttributes(
 		attribute.String("worker.queue_type", w.queueType),
@@ -86,6 +230,46 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 	))
 	defer span.End()
 
+	// Keeps the worker running if anything else about the job panics
+	defer utils.RecoverPanic(span, w.metrics.PanicsCounter, w.component(), nil)
+
+	ctx = context.WithValue(ctx, jobIDKey{}, job.ID)
+
+	usage := &commandUsage{}
+	ctx = context.WithValue(ctx, commandUsageKey{}, usage)
+
+	// Directory jobs sharing a device take turns; the wait isn't part of the
+	// collection's duration
+	waitCtx, waitCancel := context.WithCancel(ctx)
+	stopWait := context.AfterFunc(stopCtx, waitCancel)
+
+	release, err := w.acquireDevice(waitCtx, job)
+
+	stopWait()
+	waitCancel()
+
+	if err != nil {
+		slog.Info("Job abandoned while waiting for its device",
+			"queue_type", w.queueType,
+			"job_id", job.ID,
+			"job_name", job.Name,
+			"error", err,
+		)
+
+		return
+	}
+	defer release()
+
+	// Run the job, and the commands it starts, on cpu.affinity's CPUs
+	if cpus := w.config.CPU.AffinityCPUs(); cpus != nil {
+		unpin, err := limits.PinThread(cpus)
+		if err != nil {
+			slog.Warn("Failed to pin job to its CPUs", "job_name", job.Name, "cpus", w.config.CPU.Affinity, "error", err)
+		} else {
+			defer unpin()
+		}
+	}
+
 	startTime := time.Now()
 
 	// Track memory usage
@@ -99,12 +283,16 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 		Name:      job.Name,
 		Path:      job.Path,
 		StartedAt: startTime,
+		Timeout:   job.Timeout,
 		//nolint:contextcheck // Context is from job, not inherited
 		TraceID: trace.SpanFromContext(ctx).SpanContext().TraceID().String(),
 	}
 	//nolint:contextcheck // Context is from job, not inherited
 	w.state.SetRunningJob(ctx, w.queueType, jobState)
 
+	loop.begin(&job, jobState, cancel)
+	defer loop.end()
+
 	slog.Info("Processing job",
 		"queue_type", w.queueType,
 		"job_id", job.ID,
@@ -113,28 +301,27 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 		"trace_id", jobState.TraceID,
 	)
 
-	var err error
+	//nolint:contextcheck // Context is from job, not inherited
+	sizeBytes, err := w.collect(ctx, span, job, startTime)
 
-	switch job.Type {
-	case "filesystem":
-		//nolint:contextcheck // Context is from job, not inherited
-		err = w.processFilesystem(ctx, job)
-	case "directory":
-		//nolint:contextcheck // Context is from job, not inherited
-		err = w.processDirectory(ctx, job)
-	default:
-		err = fmt.Errorf("unknown job type: %s", job.Type)
+	duration := time.Since(startTime)
+
+	result := state.JobResult{
+		JobID:     job.ID,
+		Duration:  duration,
+		SizeBytes: sizeBytes,
+		Err:       err,
 	}
 
-	duration := time.Since(startTime)
+	if err != nil {
+		result.Reason = utils.ClassifyFailure(err)
+	}
 
 	runtime.ReadMemStats(&memEnd)
 
-	// Calculate resource usage
-	// Note: CPU time measurement requires process stats which we'll get from command execution
-	// For now, we'll track it per command execution
-	cpuUserSecs := 0.0
-	cpuSystemSecs := 0.0
+	// CPU time of the commands the job ran; a native walk's is the exporter's own
+	cpuUserSecs, cpuSystemSecs := usage.cpuSeconds()
+
 	// memAllocated can be negative if GC runs during job execution, so clamp to 0
 	var memAllocatedDelta int64
 	if memEnd.Alloc > memStart.Alloc {
@@ -157,12 +344,24 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 		memPeak = 0
 	}
 
+	// Supervise already recorded the job as failed when it abandoned it
+	if loop.isAbandoned() {
+		slog.Warn("Abandoned job finished",
+			"queue_type", w.queueType,
+			"job_id", job.ID,
+			"job_name", job.Name,
+			"duration", duration,
+			"error", err,
+		)
+
+		return
+	}
+
 	// Clear running job state
 	//nolint:contextcheck // Context is from job, not inherited
 	w.state.ClearRunningJob(ctx, w.queueType, job.ID, duration)
 
-	// Note: Scheduler will clear its running flag when it detects the job is complete
-	// via the state tracker check
+	// The scheduler clears its running flag once job.Finish closes Done
 
 	// Update resource metrics
 	//nolint:contextcheck // Context is from job, not inherited
@@ -180,21 +379,17 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("job.failure_reason", result.Reason))
 
-		labels := []string{
-			job.Name,
-			strconv.Itoa(int(job.Interval.Seconds())),
-			job.Type,
-		}
-
-		w.metrics.CollectionFailedCounter.WithLabelValues(labels...).Inc()
-		w.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+		//nolint:contextcheck // Context is from job, not inherited
+		w.recordFailure(ctx, job, result)
 
 		slog.Error("Job failed",
 			"queue_type", w.queueType,
 			"job_id", job.ID,
 			"job_name", job.Name,
 			"error", err,
+			"error_class", result.Reason,
 			"duration", duration,
 			"trace_id", jobState.TraceID,
 		)
@@ -213,12 +408,26 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 	w.metrics.CollectionSuccess.WithLabelValues(labels...).Inc()
 	w.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
 
+	//nolint:contextcheck // Context is from job, not inherited
+	w.state.RecordResult(ctx, w.queueType, job.Name, result)
+	w.metrics.ItemConsecutiveFailuresGauge.WithLabelValues(job.Name, job.Type).Set(0)
+
+	if w.alerts != nil {
+		w.alerts.ObserveFailures(job.Type, job.Name, 0)
+	}
+
 	w.metrics.CollectionDuration.WithLabelValues(
 		job.Name,
 		strconv.Itoa(int(job.Interval.Seconds())),
 		job.Type,
 	).Set(duration.Seconds())
 
+	w.metrics.CollectionDurationHist.WithLabelValues(
+		job.Name,
+		strconv.Itoa(int(job.Interval.Seconds())),
+		job.Type,
+	).Observe(duration.Seconds())
+
 	// Update collection timestamp for alerting compatibility
 	w.metrics.CollectionTimestampGauge.WithLabelValues(
 		job.Name,
@@ -253,8 +462,118 @@ func (w *Worker) processJob(_ context.Context, job queue.Job) {
 	}
 }
 
-// processFilesystem processes a filesystem collection job
-func (w *Worker) processFilesystem(ctx context.Context, job queue.Job) error {
+// forgetRemovedGroup deletes the state and series of a directory job whose
+// group was removed through the API
+func (w *Worker) forgetRemovedGroup(job queue.Job) {
+	if job.Type != "directory" {
+		return
+	}
+
+	if _, exists := w.config.Directory(job.Name); exists {
+		return
+	}
+
+	w.state.UnregisterItem(context.Background(), "directory", job.Name)
+	w.metrics.DeleteDirectoryGroupSeries(job.Name)
+}
+
+// recordFailure counts a failed job and records it in the item's state
+func (w *Worker) recordFailure(ctx context.Context, job queue.Job, result state.JobResult) {
+	labels := []string{
+		job.Name,
+		strconv.Itoa(int(job.Interval.Seconds())),
+		job.Type,
+	}
+
+	w.metrics.CollectionFailedCounter.WithLabelValues(append(labels, result.Reason)...).Inc()
+	w.metrics.CollectionTotal.WithLabelValues(labels...).Inc()
+
+	failures := w.state.RecordResult(ctx, w.queueType, job.Name, result)
+	w.metrics.ItemConsecutiveFailuresGauge.WithLabelValues(job.Name, job.Type).Set(float64(failures))
+
+	if w.alerts != nil {
+		w.alerts.ObserveFailures(job.Type, job.Name, failures)
+	}
+}
+
+// collect runs a job's collection, retried as its retry policy says,
+// returning the used bytes it measured. A panic fails the job rather than
+// the worker.
+func (w *Worker) collect(ctx context.Context, span trace.Span, job queue.Job, startTime time.Time) (sizeBytes int64, err error) {
+	defer utils.RecoverPanic(span, w.metrics.PanicsCounter, w.component(), &err)
+
+	policy := w.retryPolicy(job.Type, job.Name)
+	policy.OnRetry = func(_ int, err error) {
+		w.metrics.CollectionRetriesCounter.WithLabelValues(
+			job.Name,
+			strconv.Itoa(int(job.Interval.Seconds())),
+			job.Type,
+			utils.ClassifyFailure(err),
+		).Inc()
+	}
+
+	err = utils.RetryWithBackoff(ctx, func() (err error) {
+		sizeBytes, err = w.collectOnce(ctx, job, startTime)
+		return err
+	}, policy)
+
+	return sizeBytes, err
+}
+
+// collectOnce makes one attempt at a job's collection
+func (w *Worker) collectOnce(ctx context.Context, job queue.Job, startTime time.Time) (sizeBytes int64, err error) {
+	switch job.Type {
+	case "filesystem":
+		return w.processFilesystem(ctx, job)
+	case "directory":
+		err = w.processDirectory(ctx, job)
+		if err == nil {
+			w.metrics.CollectionPartialGauge.WithLabelValues(job.Name, "directory").Set(0)
+		}
+
+		// The group's total is recorded as soon as it's measured
+		if item := w.state.GetItemState(ctx, w.queueType, job.Name); item != nil && !item.LastSizeTime.Before(startTime) {
+			sizeBytes = item.LastSizeBytes
+		}
+
+		return sizeBytes, err
+	default:
+		return 0, fmt.Errorf("unknown job type: %s", job.Type)
+	}
+}
+
+// retryPolicy is the retry policy of an item
+func (w *Worker) retryPolicy(itemType, name string) utils.RetryPolicy {
+	retry := w.config.Retry
+
+	switch itemType {
+	case "filesystem":
+		for _, fs := range w.config.Filesystems {
+			if fs.Name == name {
+				retry = w.config.GetFilesystemRetry(fs)
+			}
+		}
+	case "directory":
+		group, _ := w.config.Directory(name)
+		retry = w.config.GetDirectoryRetry(group)
+	}
+
+	return utils.RetryPolicy{
+		MaxAttempts:  retry.MaxAttempts,
+		InitialDelay: retry.InitialDelay.Duration,
+		MaxDelay:     retry.MaxDelay.Duration,
+		Multiplier:   retry.Multiplier,
+	}
+}
+
+// component is the worker's component label on panics_total
+func (w *Worker) component() string {
+	return w.queueType + "_worker"
+}
+
+// processFilesystem processes a filesystem collection job, returning the
+// used bytes it measured
+func (w *Worker) processFilesystem(ctx context.Context, job queue.Job) (int64, error) {
 	ctx, span := w.startSpan(ctx, "filesystem.collect", trace.WithAttributes(
 		attribute.String("filesystem.name", job.Name),
 		attribute.String("filesystem.mount_point", job.Path),
@@ -275,41 +594,64 @@ func (w *Worker) processFilesystem(ctx context.Context, job queue.Job) error {
 		err := fmt.Errorf("filesystem config not found: %s", job.Name)
 		span.RecordError(err)
 
-		return err
+		return 0, err
 	}
 
-	// Execute df command
-	output, err := w.executeDfCommand(ctx, job.Path)
-	if err != nil {
-		span.RecordError(err)
-		return fmt.Errorf("df command failed: %w", err)
-	}
+	var usage fsstat.Usage
 
-	// Parse df output
-	sizeKB, availableKB, err := w.parseDfOutput(ctx, output)
-	if err != nil {
-		span.RecordError(err)
-		return fmt.Errorf("parse df output failed: %w", err)
+	if fsConfig.Mode == config.FilesystemModeStatfs {
+		var err error
+
+		usage, err = w.statFilesystem(ctx, job.Path)
+		if err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("statfs failed: %w", err)
+		}
+	} else if batched, ok := w.batchedUsage(ctx, fsConfig); ok {
+		span.SetAttributes(attribute.Bool("filesystem.df_batched", true))
+
+		usage = batched.bytes()
+	} else {
+		// Execute df command
+		output, err := w.executeDfCommand(ctx, job.Name, job.Path)
+		if err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("df command failed: %w", err)
+		}
+
+		// Parse df output
+		parsed, err := w.parseDfOutput(ctx, output)
+		if err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("parse df output failed: %w", err)
+		}
+
+		usage = parsed.bytes()
 	}
 
-	// Convert to bytes
-	sizeBytes := sizeKB * 1024
-	availableBytes := availableKB * 1024
+	sizeBytes, availableBytes := usage.Size, usage.Available
 	usedBytes := sizeBytes - availableBytes
 	usedRatio := float64(usedBytes) / float64(sizeBytes)
 
 	// Update metrics
-	w.updateFilesystemMetrics(ctx, fsConfig, sizeBytes, availableBytes, usedRatio)
+	w.updateFilesystemMetrics(ctx, fsConfig, usage, usedRatio)
+	w.updateQuotaMetrics(job.Name, "filesystem", usedBytes, fsConfig.Quota)
+	w.updateDriftMetric(ctx, fsConfig, usedBytes)
+
+	if w.growth != nil {
+		w.growth.ObserveVolume(fsConfig, time.Now(), usedBytes, availableBytes)
+	}
 
 	span.SetAttributes(
 		attribute.Int64("filesystem.size_bytes", sizeBytes),
 		attribute.Int64("filesystem.available_bytes", availableBytes),
+		attribute.Int64("filesystem.free_bytes", usage.Free),
 		attribute.Int64("filesystem.used_bytes", usedBytes),
 		attribute.Float64("filesystem.used_ratio", usedRatio),
 	)
 	span.AddEvent("filesystem_collected")
 
-	return nil
+	return usedBytes, nil
 }
 
 // processDirectory processes a directory collection job
@@ -321,7 +663,7 @@ func (w *Worker) processDirectory(ctx context.Context, job queue.Job) error {
 	defer span.End()
 
 	// Find directory config
-	dirConfig, exists := w.config.Directories[job.Name]
+	dirConfig, exists := w.config.Directory(job.Name)
 	if !exists {
 		err := fmt.Errorf("directory config not found: %s", job.Name)
 		span.RecordError(err)
@@ -345,44 +687,76 @@ func (w *Worker) processDirectory(ctx context.Context, job queue.Job) error {
 		subdirectoryLevels = 0
 	}
 
-	// Collect directory and subdirectories based on subdirectory_levels
-	if subdirectoryLevels == 0 {
+	switch dirConfig.Mode {
+	case config.DirectoryModeWalk:
+		return w.processDirectoryWalk(ctx, job, dirConfig, subdirectoryLevels)
+	case config.DirectoryModeProjectQuota:
+		return w.processDirectoryProjectQuota(ctx, job, dirConfig)
+	case config.DirectoryModeSample:
+		return w.processDirectorySample(ctx, job, dirConfig)
+	case config.DirectoryModeExec:
+		return w.processDirectoryExec(ctx, job, dirConfig, subdirectoryLevels)
+	}
+
+	// Collect directory and subdirectories based on subdirectory_levels.
+	// Ranking top entries needs the immediate children, so a group with top_n
+	// has du list them even when it only exports its own size.
+	if subdirectoryLevels == 0 && dirConfig.TopN == 0 {
 		// Just collect the directory itself
-		sizeKB, err := w.executeDuCommand(ctx, job.Path, job.Timeout)
+		sizeBytes, err := w.executeDuCommand(ctx, job.Name, job.Path, job.Timeout)
 		if err != nil {
 			span.RecordError(err)
 			return fmt.Errorf("du command failed: %w", err)
 		}
 
-		// Convert to bytes
-		sizeBytes := sizeKB * 1024
-
 		// Update metrics
-		w.updateDirectoryMetrics(ctx, job.Name, job.Path, sizeBytes, 0)
+		w.updateDirectoryMetrics(ctx, job.Name, job.Path, config.DirectoryModeDu, sizeBytes, 0)
+		w.recordGroupTotal(ctx, job.Name, sizeBytes, dirConfig)
 
-		span.SetAttributes(
-			attribute.Int64("directory.size_bytes", sizeBytes),
-			attribute.Int64("directory.size_kb", sizeKB),
-		)
+		span.SetAttributes(attribute.Int64("directory.size_bytes", sizeBytes))
 	} else {
 		// Collect directory and all subdirectories up to specified depth
-		subdirSizes, err := w.executeDuCommandWithDepth(ctx, job.Path, subdirectoryLevels, job.Timeout)
+		sizes, err := w.executeDuCommandWithDepth(ctx, job.Name, job.Path, max(subdirectoryLevels, 1), job.Timeout)
+		exported := w.withinLevels(job.Path, sizes, subdirectoryLevels)
+
 		if err != nil {
+			// The directories du finished before timing out are still published,
+			// but the collection fails without the group's total
+			if len(exported) > 0 {
+				updated := w.exportPartialDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, exported)
+				w.metrics.CollectionPartialGauge.WithLabelValues(job.Name, "directory").Set(1)
+
+				slog.Warn("Published the directories du finished before timing out",
+					"group", job.Name,
+					"directories", updated,
+				)
+				span.SetAttributes(attribute.Int("directory.partial_directories", updated))
+			}
+
 			span.RecordError(err)
+
 			return fmt.Errorf("du command with depth failed: %w", err)
 		}
 
-		// Update metrics for each subdirectory found
-		for path, sizeKB := range subdirSizes {
-			sizeBytes := sizeKB * 1024
-			// Calculate subdirectory level (depth from base path)
-			level := w.calculateSubdirectoryLevel(job.Path, path)
-			w.updateDirectoryMetrics(ctx, job.Name, path, sizeBytes, level)
-		}
+		w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, exported)
 
 		span.SetAttributes(
-			attribute.Int("directory.subdirectories_collected", len(subdirSizes)),
+			attribute.Int("directory.subdirectories_collected", len(exported)),
 		)
+
+		if dirConfig.TopN > 0 {
+			w.collectTopEntries(ctx, job, dirConfig, sizes)
+		}
+	}
+
+	if dirConfig.GroupByOwner {
+		// du can't attribute usage to owners, so this needs a separate native
+		// pass. It reads the whole tree again with its own timeout, which is the
+		// price of group_by_owner in du mode; mode walk does both in one pass.
+		if err := w.collectOwnerUsage(ctx, job, dirConfig); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("owner usage collection failed: %w", err)
+		}
 	}
 
 	span.AddEvent("directory_collected")
@@ -390,141 +764,433 @@ func (w *Worker) processDirectory(ctx context.Context, job queue.Job) error {
 	return nil
 }
 
-// executeDfCommand executes the df command
-func (w *Worker) executeDfCommand(ctx context.Context, mountPoint string) ([]byte, error) {
-	ctx, span := w.startSpan(ctx, "command.df", trace.WithAttributes(
-		attribute.String("command.mount_point", mountPoint),
+// processDirectoryWalk collects a directory group with the native walker
+// instead of du, producing the same series
+func (w *Worker) processDirectoryWalk(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup, subdirectoryLevels int) error {
+	ctx, span := w.startSpan(ctx, "directory.walk", trace.WithAttributes(
+		attribute.String("directory.path", job.Path),
+		attribute.Int("walk.max_depth", subdirectoryLevels),
+		attribute.Float64("walk.timeout_seconds", job.Timeout.Seconds()),
 	))
 	defer span.End()
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	timeoutCtx, cancel := context.WithTimeout(ctx, job.Timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(timeoutCtx, "df", mountPoint)
+	opts := walk.Options{
+		MaxDepth:  subdirectoryLevels,
+		ByOwner:   dirConfig.GroupByOwner,
+		DropCache: dirConfig.DropPageCache,
+		Apparent:  dirConfig.SizeMode == config.SizeModeApparent,
+		Symlinks:  walkSymlinks(dirConfig.FollowSymlinks),
+		Sandbox:   dirConfig.Sandbox,
 
-	execStart := time.Now()
-	output, err := cmd.Output()
-	execDuration := time.Since(execStart)
+		FilesPerSecond: dirConfig.IOLimit.FilesPerSecond,
+	}
 
-	span.SetAttributes(
-		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
-		attribute.Int("command.output_size_bytes", len(output)),
-	)
+	if w.tracer != nil && w.tracer.IsEnabled() {
+		opts.SlowestSubtrees = subtreeSpans
+	}
+
+	// A failed export doesn't fail the collection
+	var export *ncduExport
+
+	if dirConfig.NcduExport != "" {
+		var err error
+
+		if export, err = newNcduExport(dirConfig.NcduExport); err != nil {
+			slog.Warn("Could not start ncdu export", "group", job.Name, "path", dirConfig.NcduExport, "error", err)
+			span.RecordError(err)
+		} else {
+			opts.Visit = export.visit
+		}
+	}
+
+	walkStart := time.Now()
+	result, err := walk.Walk(timeoutCtx, job.Path, opts)
+	walkDuration := time.Since(walkStart)
+
+	span.SetAttributes(attribute.Float64("walk.duration_seconds", walkDuration.Seconds()))
+
+	if result != nil {
+		w.recordSubtreeSpans(ctx, result.SlowestSubtrees)
+	}
 
 	if err != nil {
+		if export != nil {
+			export.abort()
+		}
+
 		if timeoutCtx.Err() == context.DeadlineExceeded {
-			span.SetAttributes(attribute.String("command.error_type", "timeout"))
-			slog.Error("df command timed out", "mount_point", mountPoint, "duration", execDuration)
+			span.SetAttributes(attribute.String("walk.error_type", "timeout"))
+			slog.Error("Directory walk timed out", "path", job.Path, "duration", walkDuration, "timeout", job.Timeout)
 		}
 
 		span.RecordError(err)
 
-		return nil, err
+		return fmt.Errorf("directory walk failed: %w", err)
 	}
 
-	span.AddEvent("command_completed")
+	if export != nil {
+		if err := export.finish(); err != nil {
+			slog.Warn("Could not write ncdu export", "group", job.Name, "path", dirConfig.NcduExport, "error", err)
+			span.RecordError(err)
+		} else {
+			span.AddEvent("ncdu_exported")
+		}
+	}
 
-	return output, nil
-}
+	if result.Errors > 0 {
+		slog.Warn("Directory walk skipped unreadable entries",
+			"group", job.Name,
+			"path", job.Path,
+			"errors", result.Errors,
+		)
+	}
 
-// executeDuCommand executes the du command
-func (w *Worker) executeDuCommand(ctx context.Context, path string, timeout time.Duration) (int64, error) {
-	ctx, span := w.startSpan(ctx, "command.du", trace.WithAttributes(
-		attribute.String("command.path", path),
-		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
-	))
-	defer span.End()
+	if result.SymlinkLoops > 0 {
+		slog.Debug("Directory walk skipped symlinks leading back into the tree",
+			"group", job.Name,
+			"path", job.Path,
+			"loops", result.SymlinkLoops,
+		)
+	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	w.recordCacheAdvice(job.Name, result)
+
+	exported := w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeWalk, result.Directories)
+
+	for path, modTime := range result.LastModified {
+		if !exported[path] {
+			continue
+		}
+
+		w.metrics.DirectoryLastModifiedGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, job.Name, path)...).Set(float64(modTime.Unix()))
+	}
 
-	cmd := exec.CommandContext(timeoutCtx, "du", "-s", "-x", path)
+	if dirConfig.TopN > 0 {
+		w.exportTopEntries(job.Name, result.Entries, dirConfig.TopN)
+	}
+
+	if dirConfig.GroupByOwner {
+		w.exportOwnerUsage(job.Name, result)
+	}
 
-	execStart := time.Now()
-	output, err := cmd.Output()
-	execDuration := time.Since(execStart)
+	if dirConfig.HardlinksTotal {
+		total := result.Directories[filepath.Clean(job.Path)] + result.HardlinkedBytes
+		w.metrics.DirectoryHardlinksSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(dirConfig.Labels, job.Name)...).Set(float64(total))
+	}
 
 	span.SetAttributes(
-		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
-		attribute.Int("command.output_size_bytes", len(output)),
+		attribute.Int("directory.subdirectories_collected", len(result.Directories)),
+		attribute.Int64("walk.hardlinked_bytes", result.HardlinkedBytes),
+		attribute.Int("walk.symlink_loops", result.SymlinkLoops),
+		attribute.Int("walk.errors", result.Errors),
+		attribute.Float64("walk.throttled_seconds", result.Throttled.Seconds()),
 	)
+	span.AddEvent("directory_collected")
 
-	if err != nil {
-		if timeoutCtx.Err() == context.DeadlineExceeded {
-			span.SetAttributes(attribute.String("command.error_type", "timeout"))
-			slog.Error("du command timed out", "path", path, "duration", execDuration, "timeout", timeout)
+	return nil
+}
+
+// subtreeSpans is how many of a walk's slowest subtrees get a span each
+const subtreeSpans = 10
+
+// recordSubtreeSpans adds a span under the walk's for each of its slowest
+// subtrees, covering the time the walk spent in it
+func (w *Worker) recordSubtreeSpans(ctx context.Context, subtrees []walk.Subtree) {
+	for i, subtree := range subtrees {
+		_, span := w.startSpan(ctx, "directory.walk.subtree",
+			trace.WithTimestamp(subtree.Start),
+			trace.WithAttributes(
+				attribute.String("subtree.path", subtree.Path),
+				attribute.Int("subtree.rank", i+1),
+				attribute.Int("subtree.entries", subtree.Entries),
+				attribute.Int("subtree.errors", subtree.Errors),
+				attribute.Float64("subtree.duration_seconds", subtree.Duration.Seconds()),
+			),
+		)
+		span.End(trace.WithTimestamp(subtree.Start.Add(subtree.Duration)))
+	}
+}
+
+// walkSymlinks maps a group's follow_symlinks to the walker's policy
+func walkSymlinks(followSymlinks string) walk.Symlinks {
+	switch followSymlinks {
+	case config.FollowSymlinksWithinRoot:
+		return walk.SymlinksWithinRoot
+	case config.FollowSymlinksAlways:
+		return walk.SymlinksAlways
+	}
+
+	return walk.SymlinksNever
+}
+
+// processDirectoryProjectQuota reads a group's size from the project quota
+// covering its path instead of scanning the tree
+func (w *Worker) processDirectoryProjectQuota(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup) error {
+	_, span := w.startSpan(ctx, "directory.project_quota", trace.WithAttributes(
+		attribute.String("directory.path", job.Path),
+	))
+	defer span.End()
+
+	projectID := dirConfig.ProjectID
+	if projectID == 0 {
+		var err error
+
+		projectID, err = quotactl.ProjectID(job.Path)
+		if err != nil {
+			span.RecordError(err)
+			return err
 		}
 
-		span.RecordError(err)
+		if projectID == 0 {
+			err := fmt.Errorf("%s has no project ID assigned; set project_id or assign one with xfs_quota", job.Path)
+			span.RecordError(err)
 
-		return 0, err
+			return err
+		}
 	}
 
-	// Parse output
-	sizeKB, err := w.parseDuOutput(ctx, output)
+	device, err := quotactl.DeviceForPath(job.Path)
 	if err != nil {
 		span.RecordError(err)
-		return 0, err
+		return err
 	}
 
-	span.AddEvent("command_completed")
+	usage, err := quotactl.Get(device, quotactl.Project, projectID)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
 
-	return sizeKB, nil
+	//nolint:gosec // G115: quota usage in bytes fits in int64 for any real filesystem
+	sizeBytes := int64(usage.UsedBytes)
+
+	w.updateDirectoryMetrics(ctx, job.Name, job.Path, config.DirectoryModeProjectQuota, sizeBytes, 0)
+	w.recordGroupTotal(ctx, job.Name, sizeBytes, dirConfig)
+
+	id := strconv.FormatUint(uint64(projectID), 10)
+	gauges := w.metrics.ProjectQuota
+	gauges.UsedBytes.WithLabelValues(job.Name, id).Set(float64(usage.UsedBytes))
+	gauges.SoftLimitBytes.WithLabelValues(job.Name, id).Set(float64(usage.SoftLimitBytes))
+	gauges.HardLimitBytes.WithLabelValues(job.Name, id).Set(float64(usage.HardLimitBytes))
+	gauges.UsedInodes.WithLabelValues(job.Name, id).Set(float64(usage.UsedInodes))
+	gauges.SoftLimitInodes.WithLabelValues(job.Name, id).Set(float64(usage.SoftLimitInodes))
+	gauges.HardLimitInodes.WithLabelValues(job.Name, id).Set(float64(usage.HardLimitInodes))
+
+	span.SetAttributes(
+		attribute.Int64("quota.project_id", int64(projectID)),
+		attribute.String("quota.device", device),
+		attribute.Int64("directory.size_bytes", sizeBytes),
+	)
+
+	return nil
 }
 
-// executeDuCommandWithDepth executes du with --max-depth to collect subdirectories
-// Returns a map of path -> size in KB
-func (w *Worker) executeDuCommandWithDepth(ctx context.Context, path string, maxDepth int, timeout time.Duration) (map[string]int64, error) {
-	ctx, span := w.startSpan(ctx, "command.du_depth", trace.WithAttributes(
-		attribute.String("command.path", path),
-		attribute.Int("command.max_depth", maxDepth),
-		attribute.Float64("command.timeout_seconds", timeout.Seconds()),
+// statFilesystem reads filesystem usage with statfs(2) instead of running df
+func (w *Worker) statFilesystem(ctx context.Context, mountPoint string) (fsstat.Usage, error) {
+	_, span := w.startSpan(ctx, "syscall.statfs", trace.WithAttributes(
+		attribute.String("statfs.mount_point", mountPoint),
 	))
 	defer span.End()
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	usage, err := fsstat.Stat(mountPoint)
+	if err != nil {
+		span.RecordError(err)
+		return fsstat.Usage{}, err
+	}
 
-	// Use du with -d (max depth) to get all subdirectories in one pass
-	// -x: don't cross filesystem boundaries
-	// -d: maximum depth to traverse (0 = base dir only, 1 = base + direct subdirs, etc.)
-	// Note: BusyBox du uses -d instead of --max-depth
-	// Note: We don't use -s (summarize) here because it conflicts with -d
-	cmd := exec.CommandContext(timeoutCtx, "du", "-x", "-d", strconv.Itoa(maxDepth), path)
+	return usage, nil
+}
 
-	execStart := time.Now()
-	output, err := cmd.Output()
-	execDuration := time.Since(execStart)
+// withinLevels returns the sizes of the directories at most levels below
+// basePath
+func (w *Worker) withinLevels(basePath string, sizes map[string]int64, levels int) map[string]int64 {
+	within := make(map[string]int64, len(sizes))
 
-	span.SetAttributes(
-		attribute.Float64("command.duration_seconds", execDuration.Seconds()),
-		attribute.Int("command.output_size_bytes", len(output)),
-	)
+	for path, size := range sizes {
+		if w.calculateSubdirectoryLevel(basePath, path) <= levels {
+			within[path] = size
+		}
+	}
+
+	return within
+}
+
+// collectTopEntries exports the N largest immediate children (files and directories)
+// of the job path, replacing any ranks published by the previous collection. The
+// directories come from the du collection's sizes and the files from a listing of
+// the job path, so the tree isn't read a second time. A failed listing is logged
+// and leaves the previous ranks in place without failing the collection.
+func (w *Worker) collectTopEntries(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup, sizes map[string]int64) {
+	_, span := w.startSpan(ctx, "directory.top_entries", trace.WithAttributes(
+		attribute.String("directory.name", job.Name),
+		attribute.Int("directory.top_n", dirConfig.TopN),
+	))
+	defer span.End()
 
+	_, children, err := walk.List(job.Path, walk.Options{Apparent: dirConfig.SizeMode == config.SizeModeApparent})
 	if err != nil {
-		if timeoutCtx.Err() == context.DeadlineExceeded {
-			span.SetAttributes(attribute.String("command.error_type", "timeout"))
-			slog.Error("du command with depth timed out", "path", path, "max_depth", maxDepth, "duration", execDuration, "timeout", timeout)
+		slog.Warn("Could not list entries to rank", "group", job.Name, "path", job.Path, "error", err)
+		span.RecordError(err)
+
+		return
+	}
+
+	entryBytes := make(map[string]int64, len(children))
+
+	for path, size := range sizes {
+		if w.calculateSubdirectoryLevel(job.Path, path) == 1 {
+			entryBytes[filepath.Clean(path)] = size
 		}
+	}
 
-		span.RecordError(err)
+	for _, child := range children {
+		// du has already measured directories, including links it followed
+		if _, measured := entryBytes[child.Path]; !child.IsDir && !measured {
+			entryBytes[child.Path] = child.Usage
+		}
+	}
+
+	exported := w.exportTopEntries(job.Name, entryBytes, dirConfig.TopN)
+
+	span.SetAttributes(attribute.Int("directory.top_entries_exported", exported))
+}
 
-		return nil, err
+// exportTopEntries publishes the topN largest of the given path -> bytes entries
+// for a group, replacing any ranks published by the previous collection.
+// Returns the number of entries exported.
+func (w *Worker) exportTopEntries(groupName string, entrySizes map[string]int64, topN int) int {
+	type entry struct {
+		name      string
+		sizeBytes int64
 	}
 
-	// Parse output to extract all subdirectory sizes
-	subdirSizes, err := w.parseDuOutputWithDepth(ctx, output, path)
+	entries := make([]entry, 0, len(entrySizes))
+	for path, sizeBytes := range entrySizes {
+		entries = append(entries, entry{name: filepath.Base(path), sizeBytes: sizeBytes})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].sizeBytes != entries[j].sizeBytes {
+			return entries[i].sizeBytes > entries[j].sizeBytes
+		}
+
+		return entries[i].name < entries[j].name
+	})
+
+	if len(entries) > topN {
+		entries = entries[:topN]
+	}
+
+	// Ranks are reassigned every collection, so drop the previous set first
+	w.metrics.DirectoryTopNSizeGauge.DeletePartialMatch(map[string]string{"group": groupName})
+
+	for i, e := range entries {
+		w.metrics.DirectoryTopNSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(w.config.GetDirectoryLabels(groupName),
+			groupName,
+			strconv.Itoa(i+1),
+			e.name,
+		)...).Set(float64(e.sizeBytes))
+	}
+
+	return len(entries)
+}
+
+// collectOwnerUsage walks the job path to attribute disk usage to file owners.
+// It's a second full read of the tree after du's, given the job's timeout again.
+func (w *Worker) collectOwnerUsage(ctx context.Context, job queue.Job, dirConfig config.DirectoryGroup) error {
+	ctx, span := w.startSpan(ctx, "directory.owner_usage", trace.WithAttributes(
+		attribute.String("directory.name", job.Name),
+	))
+	defer span.End()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	result, err := walk.Walk(timeoutCtx, job.Path, walk.Options{
+		ByOwner:   true,
+		DropCache: dirConfig.DropPageCache,
+		Apparent:  dirConfig.SizeMode == config.SizeModeApparent,
+		Symlinks:  walkSymlinks(dirConfig.FollowSymlinks),
+		Sandbox:   dirConfig.Sandbox,
+
+		FilesPerSecond: dirConfig.IOLimit.FilesPerSecond,
+	})
 	if err != nil {
 		span.RecordError(err)
-		return nil, err
+		return err
 	}
 
+	w.recordCacheAdvice(job.Name, result)
+	w.exportOwnerUsage(job.Name, result)
+
 	span.SetAttributes(
-		attribute.Int("command.subdirectories_found", len(subdirSizes)),
+		attribute.Int("directory.owners", len(result.OwnerUsage)),
+		attribute.Int("walk.errors", result.Errors),
 	)
-	span.AddEvent("command_completed")
 
-	return subdirSizes, nil
+	return nil
+}
+
+// recordCacheAdvice counts the directories a walk dropped from the page cache
+func (w *Worker) recordCacheAdvice(groupName string, result *walk.Result) {
+	labels := w.config.GetDirectoryLabels(groupName)
+
+	if result.CacheAdvised > 0 {
+		w.metrics.DirectoryCacheAdviceCounter.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, "applied")...).Add(float64(result.CacheAdvised))
+	}
+
+	if result.CacheAdviceErrors > 0 {
+		w.metrics.DirectoryCacheAdviceCounter.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, "failed")...).Add(float64(result.CacheAdviceErrors))
+		slog.Warn("Failed to drop walked directories from the page cache", "group", groupName, "directories", result.CacheAdviceErrors)
+	}
+}
+
+// exportOwnerUsage publishes per-uid and per-gid usage for a group, dropping
+// owners that no longer own anything
+func (w *Worker) exportOwnerUsage(groupName string, result *walk.Result) {
+	w.metrics.DirectoryOwnerSizeGauge.DeletePartialMatch(map[string]string{"group": groupName})
+	w.metrics.DirectoryOwnerGroupSizeGauge.DeletePartialMatch(map[string]string{"group": groupName})
+
+	labels := w.config.GetDirectoryLabels(groupName)
+
+	for uid, sizeBytes := range result.OwnerUsage {
+		id := strconv.FormatUint(uint64(uid), 10)
+		w.metrics.DirectoryOwnerSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, id, w.lookupOwnerName("user", id))...).Set(float64(sizeBytes))
+	}
+
+	for gid, sizeBytes := range result.GroupUsage {
+		id := strconv.FormatUint(uint64(gid), 10)
+		w.metrics.DirectoryOwnerGroupSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, id, w.lookupOwnerName("group", id))...).Set(float64(sizeBytes))
+	}
+}
+
+// lookupOwnerName resolves a uid or gid to its name, falling back to the
+// numeric ID when it isn't known to the host (common inside containers)
+func (w *Worker) lookupOwnerName(kind, id string) string {
+	key := kind + ":" + id
+	if name, ok := w.ownerNames.Load(key); ok {
+		return name.(string)
+	}
+
+	name := id
+
+	switch kind {
+	case "user":
+		if u, err := user.LookupId(id); err == nil {
+			name = u.Username
+		}
+	case "group":
+		if g, err := user.LookupGroupId(id); err == nil {
+			name = g.Name
+		}
+	}
+
+	w.ownerNames.Store(key, name)
+
+	return name
 }
 
 // parseDuOutputWithDepth parses du output with depth information
@@ -561,7 +1227,7 @@ func (w *Worker) parseDuOutputWithDepth(ctx context.Context, output []byte, base
 		dirPath := strings.TrimSpace(parts[1])
 
 		// Parse size (in KB)
-		sizeKB, err := strconv.ParseInt(sizeStr, 10, 64)
+		sizeKB, err := utils.ParseLocalizedInt(sizeStr)
 		if err != nil {
 			span.SetAttributes(
 				attribute.String("parse.error", "invalid size"),
@@ -613,98 +1279,135 @@ func (w *Worker) calculateSubdirectoryLevel(basePath, subdirPath string) int {
 	return level + 1
 }
 
-// parseDfOutput parses df command output
-func (w *Worker) parseDfOutput(ctx context.Context, output []byte) (sizeKB, availableKB int64, err error) {
+// parseDfOutput parses df output into sizes in KiB. It copes with GNU,
+// BusyBox and BSD/macOS df: device names that wrap onto their own line or
+// contain spaces, 512-byte or 1K blocks, and the inode columns macOS adds.
+func (w *Worker) parseDfOutput(ctx context.Context, output []byte) (usage dfUsage, err error) {
 	_, span := w.startSpan(ctx, "parse.df_output", trace.WithAttributes(
 		attribute.Int("output.size_bytes", len(output)),
 	))
 	defer span.End()
 
-	lines := strings.Split(string(output), "\n")
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	if len(lines) < 2 {
-		err := fmt.Errorf("unexpected df output format: %d lines", len(lines))
+		err := fmt.Errorf("%w from df: %d lines", utils.ErrParse, len(lines))
 		span.RecordError(err)
 
-		return 0, 0, err
+		return dfUsage{}, err
 	}
 
-	// Find the stats line (second line or later that has numeric values)
-	var statsLine string
+	blockSize := dfBlockSize(lines[0])
 
-	for i := 1; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "" {
-			continue
-		}
+	// Join the data lines in case the device name wrapped onto its own line
+	usage, _, ok := parseDfFields(strings.Fields(strings.Join(lines[1:], " ")), blockSize)
+	if !ok {
+		err = fmt.Errorf("%w from df: no stats line", utils.ErrParse)
+		span.RecordError(err)
 
-		parts := strings.Fields(line)
-		if len(parts) >= 4 {
-			// Try to parse first field as number
-			if _, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
-				statsLine = line
-				break
-			}
-		}
+		return dfUsage{}, err
 	}
 
-	if statsLine == "" {
-		// Try last non-empty line
-		for i := len(lines) - 1; i >= 1; i-- {
-			line := strings.TrimSpace(lines[i])
-			if line != "" {
-				parts := strings.Fields(line)
-				if len(parts) >= 4 {
-					if _, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-						statsLine = line
-						break
-					}
-				}
-			}
-		}
-	}
+	span.SetAttributes(
+		attribute.Int64("parse.block_size", blockSize),
+		attribute.Int64("parse.size_kb", usage.sizeKB),
+		attribute.Int64("parse.used_kb", usage.usedKB),
+		attribute.Int64("parse.available_kb", usage.availableKB),
+	)
 
-	if statsLine == "" {
-		err := fmt.Errorf("could not find stats line in df output")
-		span.RecordError(err)
+	return usage, nil
+}
+
+// dfUsage is one filesystem's row of df output, in 1K blocks
+type dfUsage struct {
+	sizeKB      int64
+	usedKB      int64
+	availableKB int64
+}
 
-		return 0, 0, err
+// bytes converts the row to bytes. df's used column counts every allocated
+// block, so what's left is free space including any root reserve.
+func (u dfUsage) bytes() fsstat.Usage {
+	return fsstat.Usage{
+		Size:      u.sizeKB * 1024,
+		Free:      (u.sizeKB - u.usedKB) * 1024,
+		Available: u.availableKB * 1024,
 	}
+}
 
-	parts := strings.Fields(statsLine)
-	if len(parts) < 4 {
-		err := fmt.Errorf("unexpected df output format: %d fields", len(parts))
-		span.RecordError(err)
+// parseDfFields finds the size, used and available columns in the fields of
+// a df row, returning them and the index of the capacity column after them.
+// They are the three numbers right before the first capacity column, which
+// skips over device names containing spaces.
+func parseDfFields(fields []string, blockSize int64) (usage dfUsage, capacity int, ok bool) {
+	for i := 3; i < len(fields); i++ {
+		if !isDfCapacity(fields[i]) {
+			continue
+		}
 
-		return 0, 0, err
+		size, sizeErr := utils.ParseLocalizedInt(fields[i-3])
+		used, usedErr := utils.ParseLocalizedInt(fields[i-2])
+		available, availableErr := utils.ParseLocalizedInt(fields[i-1])
+
+		if sizeErr != nil || usedErr != nil || availableErr != nil {
+			continue
+		}
+
+		return dfUsage{
+			sizeKB:      size * blockSize / 1024,
+			usedKB:      used * blockSize / 1024,
+			availableKB: available * blockSize / 1024,
+		}, i, true
 	}
 
-	// Parse size and available
-	if sizeKB, err = strconv.ParseInt(parts[0], 10, 64); err == nil {
-		// Multi-line format
-		if len(parts) >= 3 {
-			availableKB, err = strconv.ParseInt(parts[2], 10, 64)
+	return dfUsage{}, 0, false
+}
+
+// dfBlockSizePattern matches the size column header, e.g. "1K-blocks",
+// "512-blocks", "1024-blocks" or the localized "1K-Blöcke"
+var dfBlockSizePattern = regexp.MustCompile(`^(\d+)([KkMm]?)-`)
+
+// dfBlockSize returns the unit of df's size columns from its header line.
+// GNU and BusyBox default to 1K blocks, BSD and macOS to 512-byte blocks.
+func dfBlockSize(header string) int64 {
+	for _, field := range strings.Fields(header) {
+		match := dfBlockSizePattern.FindStringSubmatch(field)
+		if match == nil {
+			continue
 		}
-	} else {
-		// Single-line format
-		if len(parts) >= 4 {
-			sizeKB, err = strconv.ParseInt(parts[1], 10, 64)
-			if err == nil {
-				availableKB, err = strconv.ParseInt(parts[3], 10, 64)
-			}
+
+		size, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || size == 0 {
+			break
+		}
+
+		switch strings.ToUpper(match[2]) {
+		case "K":
+			size *= 1024
+		case "M":
+			size *= 1024 * 1024
 		}
+
+		return size
 	}
 
-	if err != nil {
-		span.RecordError(err)
-		return 0, 0, fmt.Errorf("failed to parse df output: %w", err)
+	return 1024
+}
+
+// isDfCapacity reports whether a df field is the capacity column: a
+// percentage, or "-" for filesystems without blocks
+func isDfCapacity(field string) bool {
+	if field == "-" {
+		return true
 	}
 
-	span.SetAttributes(
-		attribute.Int64("parse.size_kb", sizeKB),
-		attribute.Int64("parse.available_kb", availableKB),
-	)
+	percent, found := strings.CutSuffix(field, "%")
+	if !found || percent == "" {
+		return false
+	}
+
+	_, err := strconv.Atoi(percent)
 
-	return sizeKB, availableKB, nil
+	return err == nil
 }
 
 // parseDuOutput parses du command output
@@ -716,16 +1419,16 @@ func (w *Worker) parseDuOutput(ctx context.Context, output []byte) (int64, error
 
 	parts := strings.Fields(string(output))
 	if len(parts) < 2 {
-		err := fmt.Errorf("unexpected du output format")
+		err := fmt.Errorf("%w from du", utils.ErrParse)
 		span.RecordError(err)
 
 		return 0, err
 	}
 
-	sizeKB, err := strconv.ParseInt(parts[0], 10, 64)
+	sizeKB, err := utils.ParseLocalizedInt(parts[0])
 	if err != nil {
 		span.RecordError(err)
-		return 0, fmt.Errorf("failed to parse directory size: %w", err)
+		return 0, fmt.Errorf("%w from du: failed to parse directory size: %w", utils.ErrParse, err)
 	}
 
 	span.SetAttributes(attribute.Int64("parse.size_kb", sizeKB))
@@ -733,7 +1436,8 @@ func (w *Worker) parseDuOutput(ctx context.Context, output []byte) (int64, error
 	return sizeKB, nil
 }
 
-// validatePath validates a path
+// validatePath checks a directory group's path exists and, through any
+// symlinks, stays within security.allowed_roots
 func (w *Worker) validatePath(ctx context.Context, path string) error {
 	_, span := w.startSpan(ctx, "validate.path", trace.WithAttributes(
 		attribute.String("path", path),
@@ -742,25 +1446,22 @@ func (w *Worker) validatePath(ctx context.Context, path string) error {
 
 	if _, err := os.Stat(path); err != nil {
 		span.RecordError(err)
-		return fmt.Errorf("path does not exist: %s", path)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("path does not exist: %s: %w", path, err)
+		}
+
+		return fmt.Errorf("path is not accessible: %w", err)
 	}
 
-	if !filepath.IsAbs(path) {
-		err := fmt.Errorf("path must be absolute: %s", path)
+	if err := config.ValidatePath(path, w.config.Security.AllowedRoots); err != nil {
 		span.RecordError(err)
-
 		return err
 	}
 
-	// Basic sanitization
-	dangerousPatterns := []string{"..", "~", "*", "?", "["}
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(path, pattern) {
-			err := fmt.Errorf("path contains dangerous pattern '%s': %s", pattern, path)
-			span.RecordError(err)
-
-			return err
-		}
+	if err := config.ValidateResolvedPath(path, w.config.Security.AllowedRoots); err != nil {
+		span.RecordError(err)
+		return err
 	}
 
 	span.AddEvent("validation_completed")
@@ -769,62 +1470,231 @@ func (w *Worker) validatePath(ctx context.Context, path string) error {
 }
 
 // updateFilesystemMetrics updates filesystem metrics
-func (w *Worker) updateFilesystemMetrics(ctx context.Context, fs *config.FilesystemConfig, sizeBytes, availableBytes int64, usedRatio float64) {
+func (w *Worker) updateFilesystemMetrics(ctx context.Context, fs *config.FilesystemConfig, usage fsstat.Usage, usedRatio float64) {
 	_, span := w.startSpan(ctx, "worker.update_metrics", trace.WithAttributes(
 		attribute.String("metric.type", "filesystem"),
 	))
 	defer span.End()
 
-	w.metrics.VolumeSizeGauge.WithLabelValues(
+	w.metrics.VolumeSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(fs.Labels,
+		fs.Device,
+		fs.MountPoint,
+		fs.Name,
+	)...).Set(float64(usage.Size))
+
+	w.metrics.VolumeAvailableGauge.WithLabelValues(w.metrics.ItemLabelValues(fs.Labels,
 		fs.Device,
 		fs.MountPoint,
 		fs.Name,
-	).Set(float64(sizeBytes))
+	)...).Set(float64(usage.Available))
 
-	w.metrics.VolumeAvailableGauge.WithLabelValues(
+	w.metrics.VolumeFreeGauge.WithLabelValues(w.metrics.ItemLabelValues(fs.Labels,
 		fs.Device,
 		fs.MountPoint,
 		fs.Name,
-	).Set(float64(availableBytes))
+	)...).Set(float64(usage.Free))
 
-	w.metrics.VolumeUsedRatioGauge.WithLabelValues(
+	// Blocks only root may use, such as ext4's 5% reserve. Quotas and
+	// filesystems that count free space loosely can leave less available
+	// than free for other reasons, but never a negative reserve.
+	w.metrics.VolumeReservedGauge.WithLabelValues(w.metrics.ItemLabelValues(fs.Labels,
 		fs.Device,
 		fs.MountPoint,
 		fs.Name,
-	).Set(usedRatio)
+	)...).Set(float64(max(usage.Free-usage.Available, 0)))
+
+	w.metrics.VolumeUsedRatioGauge.WithLabelValues(w.metrics.ItemLabelValues(fs.Labels,
+		fs.Device,
+		fs.MountPoint,
+		fs.Name,
+	)...).Set(usedRatio)
+
+	if w.alerts != nil {
+		w.alerts.ObserveUsedRatio(fs.Name, fs.MountPoint, usedRatio)
+	}
 
 	span.AddEvent("metrics_updated")
 }
 
 // updateDirectoryMetrics updates directory metrics
-func (w *Worker) updateDirectoryMetrics(ctx context.Context, groupName, path string, sizeBytes int64, subdirectoryLevel int) {
+func (w *Worker) updateDirectoryMetrics(ctx context.Context, groupName, path, mode string, sizeBytes int64, subdirectoryLevel int) {
 	_, span := w.startSpan(ctx, "worker.update_metrics", trace.WithAttributes(
 		attribute.String("metric.type", "directory"),
 	))
 	defer span.End()
 
-	w.metrics.DirectorySizeGauge.WithLabelValues(
+	labels := w.config.GetDirectoryLabels(groupName)
+	directory := w.directoryLabel(groupName, path)
+	sizeMode := w.config.GetDirectorySizeMode(groupName)
+
+	values := w.metrics.ItemLabelValues(labels,
 		groupName,
-		path,
-		"du",
+		directory,
+		mode,
+		sizeMode,
 		strconv.Itoa(subdirectoryLevel),
-	).Set(float64(sizeBytes))
+	)
+
+	w.metrics.DirectorySizeGauge.WithLabelValues(values...).Set(float64(sizeBytes))
+
+	if group, exists := w.config.Directory(groupName); exists && group.SmoothingAlpha > 0 {
+		w.metrics.DirectorySizeSmoothedGauge.WithLabelValues(values...).Set(w.smooth(groupName, path, float64(sizeBytes), group.SmoothingAlpha))
+	}
+
+	// Set after the values, so a scrape in between never pairs the previous
+	// value with this time
+	w.metrics.SetDirectorySampleTime(time.Now(), values...)
+
+	// __other__ sums whichever directories missed out, so it has no delta
+	if path != otherDirectory {
+		if delta, ok := w.sizeDelta(groupName, path, sizeBytes); ok {
+			w.metrics.DirectorySizeDeltaGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...).Set(float64(delta))
+		}
+	}
+
+	if expected, exists := w.config.GetExpectedSize(groupName, path); exists {
+		w.metrics.DirectoryExpectedSizeGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...).Set(float64(expected))
+
+		if expected > 0 {
+			variance := float64(sizeBytes-expected) / float64(expected)
+			w.metrics.DirectoryVarianceGauge.WithLabelValues(w.metrics.ItemLabelValues(labels, groupName, directory)...).Set(variance)
+		}
+	}
 
 	w.metrics.DirectoriesProcessedCounter.WithLabelValues(
 		groupName,
-		"du",
+		mode,
 	).Inc()
 
-	// Update du_lock_wait_duration_seconds for alerting compatibility
-	// Set to 0 since new architecture uses separate queues (no global lock contention)
+	// How long the collection waited for its device, always 0 unless
+	// device_concurrency is enabled
 	w.metrics.DuLockWaitDurationGauge.WithLabelValues(
 		groupName,
 		path,
-	).Set(0)
+	).Set(w.lockWait(groupName).Seconds())
 
 	span.AddEvent("metrics_updated")
 }
 
+// directoryLabel is the directory label of path in the form the group's
+// label_path asks for
+func (w *Worker) directoryLabel(groupName, path string) string {
+	group, exists := w.config.Directory(groupName)
+	if !exists || path == otherDirectory {
+		return path
+	}
+
+	return w.config.GetDirectoryLabel(group, path)
+}
+
+// smooth folds a new sample into the exponential moving average for a path.
+// The first sample seeds the average so the smoothed series starts at the raw value.
+func (w *Worker) smooth(groupName, path string, value, alpha float64) float64 {
+	w.emaMutex.Lock()
+	defer w.emaMutex.Unlock()
+
+	key := groupName + "\x00" + path
+
+	prev, exists := w.ema[key]
+	if !exists {
+		w.ema[key] = value
+		return value
+	}
+
+	next := alpha*value + (1-alpha)*prev
+	w.ema[key] = next
+
+	return next
+}
+
+// sizeDelta records a directory's size and returns the change since its
+// previous collection, or false on its first
+func (w *Worker) sizeDelta(groupName, path string, sizeBytes int64) (int64, bool) {
+	w.lastSizesMutex.Lock()
+	defer w.lastSizesMutex.Unlock()
+
+	key := groupName + "\x00" + path
+
+	prev, exists := w.lastSizes[key]
+	w.lastSizes[key] = sizeBytes
+
+	return sizeBytes - prev, exists
+}
+
+// forgetSize drops the size of a directory whose series was deleted, so the
+// delta of one that comes back isn't taken across the gap
+func (w *Worker) forgetSize(groupName, path string) {
+	w.lastSizesMutex.Lock()
+	defer w.lastSizesMutex.Unlock()
+
+	delete(w.lastSizes, groupName+"\x00"+path)
+}
+
+// forgetSmoothed drops the moving average of a directory that disappeared, so
+// one that reappears starts afresh
+func (w *Worker) forgetSmoothed(groupName, path string) {
+	w.emaMutex.Lock()
+	defer w.emaMutex.Unlock()
+
+	delete(w.ema, groupName+"\x00"+path)
+}
+
+// recordGroupTotal handles the total size of a directory group's root path:
+// quota breach checks, size alerts and the state used for filesystem drift
+func (w *Worker) recordGroupTotal(ctx context.Context, groupName string, sizeBytes int64, dirConfig config.DirectoryGroup) {
+	w.updateQuotaMetrics(groupName, "directory", sizeBytes, dirConfig.Quota)
+	w.state.RecordSize(ctx, "directory", groupName, sizeBytes)
+
+	if w.alerts != nil {
+		w.alerts.ObserveSize(groupName, sizeBytes)
+	}
+
+	if w.growth != nil {
+		w.growth.ObserveDirectory(groupName, time.Now(), sizeBytes)
+	}
+}
+
+// updateDriftMetric exports the difference between a filesystem's used bytes
+// and the sum of the directory groups configured to cover it. It is skipped
+// until every covering group has been measured at least once.
+func (w *Worker) updateDriftMetric(ctx context.Context, fsConfig *config.FilesystemConfig, usedBytes int64) {
+	if len(fsConfig.CoveredBy) == 0 {
+		return
+	}
+
+	var covered int64
+
+	for _, groupName := range fsConfig.CoveredBy {
+		item := w.state.GetItemState(ctx, "directory", groupName)
+		if item == nil || item.LastSizeTime.IsZero() {
+			slog.Debug("Skipping drift calculation until all covering groups are measured",
+				"filesystem", fsConfig.Name,
+				"group", groupName,
+			)
+
+			return
+		}
+
+		covered += item.LastSizeBytes
+	}
+
+	w.metrics.VolumeDriftGauge.WithLabelValues(w.metrics.ItemLabelValues(fsConfig.Labels, fsConfig.Name, fsConfig.MountPoint)...).Set(float64(usedBytes - covered))
+}
+
+// updateQuotaMetrics flags whether an item's usage exceeds its soft quota
+func (w *Worker) updateQuotaMetrics(itemName, itemType string, usedBytes int64, quota config.ByteSize) {
+	if quota <= 0 {
+		return
+	}
+
+	exceeded := 0.0
+	if usedBytes > quota.Bytes() {
+		exceeded = 1
+	}
+
+	w.metrics.QuotaExceededGauge.WithLabelValues(itemName, itemType).Set(exceeded)
+}
+
 // updateResourceMetrics updates resource usage metrics
 func (w *Worker) updateResourceMetrics(ctx context.Context, job queue.Job, duration time.Duration, cpuUser, cpuSystem float64, memAllocated, memPeak int64) {
 	_, span := w.startSpan(ctx, "worker.update_resource_metrics")
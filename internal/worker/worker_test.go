@@ -0,0 +1,529 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"filesystem-exporter/internal/config"
+	"filesystem-exporter/internal/metrics"
+	"filesystem-exporter/internal/queue"
+	"filesystem-exporter/internal/state"
+	"filesystem-exporter/internal/utils"
+	promexporter_metrics "github.com/d0ugal/promexporter/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Regression samples captured from appliances whose default locale groups digits
+
+func TestParseDfOutput_Localized(t *testing.T) {
+	w := &Worker{}
+
+	tests := map[string]string{
+		"C locale": `Filesystem     1K-blocks      Used Available Use% Mounted on
+/dev/sda1      976762584 488381292 488381292  50% /volume1
+`,
+		"en_US grouping": `Filesystem     1K-blocks      Used Available Use% Mounted on
+/dev/sda1    976,762,584 488,381,292 488,381,292  50% /volume1
+`,
+		"de_DE grouping": `Dateisystem    1K-Blöcke   Benutzt Verfügbar Verw% Eingehängt auf
+/dev/sda1    976.762.584 488.381.292 488.381.292  50% /volume1
+`,
+		"wrapped device name": `Filesystem     1K-blocks      Used Available Use% Mounted on
+/dev/mapper/vg1000-lv
+               976'762'584 488'381'292 488'381'292  50% /volume1
+`,
+	}
+
+	for name, output := range tests {
+		usage, err := w.parseDfOutput(context.Background(), []byte(output))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		if usage.sizeKB != 976762584 || usage.availableKB != 488381292 {
+			t.Errorf("%s: got size %d available %d", name, usage.sizeKB, usage.availableKB)
+		}
+	}
+}
+
+// Samples captured from macOS 14 and FreeBSD 14
+
+func TestParseDfOutput_BSD(t *testing.T) {
+	w := &Worker{}
+
+	tests := map[string]struct {
+		output      string
+		sizeKB      int64
+		availableKB int64
+	}{
+		"macOS default 512-byte blocks with inode columns": {
+			output: `Filesystem     512-blocks      Used Available Capacity iused      ifree %iused  Mounted on
+/dev/disk3s5    965595304 812343216 107684680    89% 2117420 538423400    0%   /System/Volumes/Data
+`,
+			sizeKB:      482797652,
+			availableKB: 53842340,
+		},
+		"macOS -k -P": {
+			output: `Filesystem 1024-blocks      Used Available Capacity  Mounted on
+/dev/disk3s5   482797652 406171608  53842340    89%    /System/Volumes/Data
+`,
+			sizeKB:      482797652,
+			availableKB: 53842340,
+		},
+		"macOS autofs map with a space in its name": {
+			output: `Filesystem    1024-blocks Used Available Capacity  Mounted on
+map auto_home           0    0         0   100%    /System/Volumes/Data/home
+`,
+			sizeKB:      0,
+			availableKB: 0,
+		},
+		"FreeBSD ZFS dataset": {
+			output: `Filesystem         1024-blocks    Used    Avail Capacity  Mounted on
+zroot/usr/home       429145568 8472008 420673560     2%    /usr/home
+`,
+			sizeKB:      429145568,
+			availableKB: 420673560,
+		},
+		"macOS SMB share": {
+			output: `Filesystem                        512-blocks       Used  Available Capacity iused ifree %iused  Mounted on
+//nas@nas.local/Time Machine       7812500000 3906250000 3906250000    50%     0     0  100%   /Volumes/Time Machine
+`,
+			sizeKB:      3906250000,
+			availableKB: 1953125000,
+		},
+	}
+
+	for name, tt := range tests {
+		usage, err := w.parseDfOutput(context.Background(), []byte(tt.output))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		if usage.sizeKB != tt.sizeKB || usage.availableKB != tt.availableKB {
+			t.Errorf("%s: got size %d available %d, expected %d and %d", name, usage.sizeKB, usage.availableKB, tt.sizeKB, tt.availableKB)
+		}
+	}
+
+	if _, err := w.parseDfOutput(context.Background(), []byte("df: /missing: No such file or directory\n")); err == nil {
+		t.Error("expected an error for output without a stats line")
+	}
+}
+
+func TestParseDuOutput_Localized(t *testing.T) {
+	w := &Worker{}
+
+	for _, output := range []string{"1234567\t/srv/data\n", "1,234,567\t/srv/data\n", "1.234.567\t/srv/data\n"} {
+		sizeKB, err := w.parseDuOutput(context.Background(), []byte(output))
+		if err != nil || sizeKB != 1234567 {
+			t.Errorf("parseDuOutput(%q) = %d, %v", output, sizeKB, err)
+		}
+	}
+
+	if _, err := w.parseDuOutput(context.Background(), []byte("1,5G\t/srv/data\n")); err == nil {
+		t.Error("expected error for human readable size")
+	}
+}
+
+func TestParseDuOutputWithDepth_Localized(t *testing.T) {
+	w := &Worker{}
+
+	output := "1.024\t/srv/data/a\n2.048\t/srv/data/b/\n3.072\t/srv/data\n"
+
+	sizes, err := w.parseDuOutputWithDepth(context.Background(), []byte(output), "/srv/data")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]int64{"/srv/data/a": 1024, "/srv/data/b": 2048, "/srv/data": 3072}
+	for path, size := range expected {
+		if sizes[path] != size {
+			t.Errorf("expected %s to be %d, got %d", path, size, sizes[path])
+		}
+	}
+}
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		n        int
+		fraction float64
+		expected int
+	}{
+		{0, 0.1, 0},
+		{1, 0.1, 1},
+		{10, 0.1, 2},
+		{100, 0.1, 10},
+		{101, 0.1, 11},
+		{20, 1, 20},
+	}
+
+	for _, tt := range tests {
+		if got := sampleSize(tt.n, tt.fraction); got != tt.expected {
+			t.Errorf("sampleSize(%d, %g) = %d, expected %d", tt.n, tt.fraction, got, tt.expected)
+		}
+	}
+}
+
+func TestEstimateRatio(t *testing.T) {
+	// Every sampled directory grew by 10%, so the estimate scales the whole
+	// population by the same and there's no spread to report
+	uniform := []samplePoint{{100, 110}, {200, 220}}
+
+	estimate, stdErr := estimateRatio(uniform, 1000, 10)
+	if math.Abs(estimate-1100) > 1e-9 || stdErr > 1e-6 {
+		t.Errorf("expected 1100 with no error, got %g ± %g", estimate, stdErr)
+	}
+
+	// Uneven growth gives a non-zero error that shrinks as more is sampled
+	uneven := []samplePoint{{100, 150}, {200, 200}, {100, 90}}
+
+	estimate, stdErr = estimateRatio(uneven, 1000, 10)
+	if math.Abs(estimate-1100) > 1e-9 || stdErr <= 0 {
+		t.Errorf("expected 1100 with some error, got %g ± %g", estimate, stdErr)
+	}
+
+	_, wholeErr := estimateRatio(uneven, 400, 3)
+	if wholeErr != 0 {
+		t.Errorf("expected no error when every directory is sampled, got %g", wholeErr)
+	}
+
+	// Empty baselines fall back to the average absolute growth
+	estimate, _ = estimateRatio([]samplePoint{{0, 10}, {0, 30}}, 0, 4)
+	if estimate != 80 {
+		t.Errorf("expected 80 from growth of empty directories, got %g", estimate)
+	}
+}
+
+func TestLimitDirectorySeries(t *testing.T) {
+	sizes := []directorySize{
+		{"/srv", 0, 1000},
+		{"/srv/a", 1, 500},
+		{"/srv/b", 1, 300},
+		{"/srv/c", 1, 100},
+		{"/srv/a/x", 2, 400},
+		{"/srv/a/y", 2, 50},
+	}
+
+	if kept, dropped := limitDirectorySeries(sizes, 6, config.SeriesStrategyOther); len(kept) != 6 || dropped != 0 {
+		t.Errorf("expected everything within the cap to be kept, got %d kept and %d dropped", len(kept), dropped)
+	}
+
+	kept, dropped := limitDirectorySeries(sizes, 3, config.SeriesStrategyTop)
+	if dropped != 3 || len(kept) != 3 || kept[0].path != "/srv" || kept[1].path != "/srv/a" || kept[2].path != "/srv/a/x" {
+		t.Errorf("expected the root and the two largest directories, got %v (%d dropped)", kept, dropped)
+	}
+
+	// The root and one __other__ per level take three of the five slots
+	kept, dropped = limitDirectorySeries(sizes, 5, config.SeriesStrategyOther)
+	if dropped != 3 || len(kept) != 5 {
+		t.Fatalf("expected 5 series with 3 directories dropped, got %v (%d dropped)", kept, dropped)
+	}
+
+	others := make(map[int]int64)
+	for _, s := range kept {
+		if s.path == otherDirectory {
+			others[s.level] = s.sizeBytes
+		}
+	}
+
+	if others[1] != 400 || others[2] != 50 {
+		t.Errorf("expected the leftovers summed per level, got %v", others)
+	}
+}
+
+func TestAcquireDevice_SerializesConcurrencyGroup(t *testing.T) {
+	w := &Worker{
+		queueType: "directory",
+		config: &config.Config{
+			DeviceConcurrency: config.DeviceConcurrencyConfig{Enabled: true, MaxWorkers: 2},
+			Directories: map[string]config.DirectoryGroup{
+				"photos":  {Path: "/mnt/nas/photos", ConcurrencyGroup: "nas"},
+				"backups": {Path: "/mnt/nas/backups", ConcurrencyGroup: "nas"},
+			},
+		},
+		deviceLocks: make(map[string]chan struct{}),
+		lockWaits:   make(map[string]time.Duration),
+	}
+
+	release, err := w.acquireDevice(context.Background(), queue.Job{Name: "photos", Path: "/mnt/nas/photos"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The second group of the same concurrency group waits its turn
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := w.acquireDevice(ctx, queue.Job{Name: "backups", Path: "/mnt/nas/backups"}); err == nil {
+		t.Fatal("expected backups to wait while photos holds the lock")
+	}
+
+	release()
+
+	release, err = w.acquireDevice(context.Background(), queue.Job{Name: "backups", Path: "/mnt/nas/backups"})
+	if err != nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+
+	release()
+}
+
+func TestExportDirectorySizes_ExpiresDeletedDirectories(t *testing.T) {
+	dirConfig := config.DirectoryGroup{Path: "/srv/data", SubdirectoryLevels: 1}
+	cfg := &config.Config{Directories: map[string]config.DirectoryGroup{"data": dirConfig}}
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	w := NewWorker(nil, m, state.NewTracker(nil), cfg, nil, nil, nil, nil, "directory")
+
+	ctx := context.Background()
+	job := queue.Job{Type: "directory", Name: "data", Path: "/srv/data"}
+
+	w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, map[string]int64{
+		"/srv/data": 300, "/srv/data/a": 100, "/srv/data/b": 200,
+	})
+
+	if series := testutil.CollectAndCount(m.DirectorySizeGauge); series != 3 {
+		t.Fatalf("expected 3 size series, got %d", series)
+	}
+
+	// b was deleted
+	w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, map[string]int64{
+		"/srv/data": 100, "/srv/data/a": 100,
+	})
+
+	if series := testutil.CollectAndCount(m.DirectorySizeGauge); series != 2 {
+		t.Errorf("expected b's size series to be deleted, got %d series", series)
+	}
+
+	if missing := testutil.ToFloat64(m.DirectoryMissingGauge.WithLabelValues("data", "/srv/data/b")); missing != 1 {
+		t.Errorf("expected b to be reported missing, got %g", missing)
+	}
+
+	if delta := testutil.ToFloat64(m.DirectorySizeDeltaGauge.WithLabelValues("data", "/srv/data")); delta != -200 {
+		t.Errorf("expected the group to have shrunk by 200 bytes, got %g", delta)
+	}
+
+	if series := testutil.CollectAndCount(m.DirectorySizeDeltaGauge); series != 2 {
+		t.Errorf("expected b's delta series to be deleted, got %d series", series)
+	}
+
+	// Reported for one collection only
+	w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, map[string]int64{
+		"/srv/data": 100, "/srv/data/a": 100,
+	})
+
+	if series := testutil.CollectAndCount(m.DirectoryMissingGauge); series != 0 {
+		t.Errorf("expected the missing series to be deleted, got %d", series)
+	}
+
+	// b is recreated, and its delta starts afresh rather than spanning the gap
+	w.exportDirectorySizes(ctx, job, dirConfig, config.DirectoryModeDu, map[string]int64{
+		"/srv/data": 150, "/srv/data/a": 100, "/srv/data/b": 50,
+	})
+
+	if series := testutil.CollectAndCount(m.DirectorySizeDeltaGauge); series != 2 {
+		t.Errorf("expected no delta for b's first collection back, got %d series", series)
+	}
+}
+
+func TestCollectTopEntries_RanksFilesWithDuDirectories(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(root, "big.bin"), make([]byte, 3000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	dirConfig := config.DirectoryGroup{Path: root, TopN: 2, SizeMode: config.SizeModeApparent}
+	cfg := &config.Config{Directories: map[string]config.DirectoryGroup{"data": dirConfig}}
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	w := NewWorker(nil, m, state.NewTracker(nil), cfg, nil, nil, nil, nil, "directory")
+
+	job := queue.Job{Type: "directory", Name: "data", Path: root}
+
+	// The directory's size is du's, not the listing's
+	w.collectTopEntries(context.Background(), job, dirConfig, map[string]int64{
+		root: 8000, root + "/sub": 5000, root + "/sub/deeper": 4000,
+	})
+
+	if series := testutil.CollectAndCount(m.DirectoryTopNSizeGauge); series != 2 {
+		t.Fatalf("expected 2 ranked entries, got %d", series)
+	}
+
+	if size := testutil.ToFloat64(m.DirectoryTopNSizeGauge.WithLabelValues("data", "1", "sub")); size != 5000 {
+		t.Errorf("expected sub ranked first with 5000 bytes, got %g", size)
+	}
+
+	if size := testutil.ToFloat64(m.DirectoryTopNSizeGauge.WithLabelValues("data", "2", "big.bin")); size != 3000 {
+		t.Errorf("expected big.bin ranked second with 3000 bytes, got %g", size)
+	}
+}
+
+func TestValidatePath_ExoticNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't allow some of the names")
+	}
+
+	base := t.TempDir()
+	allowed := filepath.Join(base, "media")
+
+	cfg := &config.Config{Security: config.SecurityConfig{AllowedRoots: []string{allowed}}}
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	w := NewWorker(nil, m, state.NewTracker(nil), cfg, nil, nil, nil, nil, "directory")
+
+	for _, name := range []string{"Movies [1080p]", "what?", "~backup", "*starred*", "two  spaces", "-rf", "Ünïcödé", "it's \"quoted\""} {
+		path := filepath.Join(allowed, name)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+
+		if err := w.validatePath(context.Background(), path); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	// A link can't lead out of the allowed roots
+	if err := os.Symlink(base, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	for _, path := range []string{base, filepath.Join(allowed, "escape"), allowed + "/../media", allowed + "/missing"} {
+		if err := w.validatePath(context.Background(), path); err == nil {
+			t.Errorf("expected %s to be rejected", path)
+		}
+	}
+}
+
+func TestSupervise_RestartsStuckLoop(t *testing.T) {
+	cfg := &config.Config{Directories: map[string]config.DirectoryGroup{"nfs": {Path: "/mnt/nfs"}}}
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	tracker := state.NewTracker(nil)
+	q := queue.NewQueue("directory", 10, "drop_oldest", tracker, m, nil)
+	w := NewWorker(q, m, tracker, cfg, nil, nil, nil, nil, "directory")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Start(ctx)
+
+	// A loop whose job is stuck in I/O that ignores its cancellation
+	started := time.Now()
+	job := queue.Job{ID: "job-1", Type: "directory", Name: "nfs", Path: "/mnt/nfs", Timeout: time.Minute, Interval: time.Hour}
+	jobState := &state.JobState{ID: job.ID, Type: job.Type, Name: job.Name, StartedAt: started, Timeout: job.Timeout}
+	tracker.RegisterItem(ctx, "directory", "nfs")
+	tracker.SetRunningJob(ctx, "directory", jobState)
+
+	cancelled := false
+	stuck := &runLoop{}
+	stuck.begin(&job, jobState, func() { cancelled = true })
+
+	w.wg.Add(1)
+	w.loopsMutex.Lock()
+	w.loops = append(w.loops, stuck)
+	w.loopsMutex.Unlock()
+
+	w.Supervise(ctx, started.Add(3*time.Minute))
+
+	if cancelled || stuck.isAbandoned() {
+		t.Fatal("expected a job within its timeouts to be left running")
+	}
+
+	w.Supervise(ctx, started.Add(5*time.Minute))
+
+	if !cancelled || !stuck.isAbandoned() {
+		t.Fatal("expected the stuck job to be cancelled and its loop abandoned")
+	}
+
+	if restarts := testutil.ToFloat64(m.WorkerRestartsCounter.WithLabelValues("directory", RestartReasonStuck)); restarts != 1 {
+		t.Errorf("expected 1 restart, got %g", restarts)
+	}
+
+	w.loopsMutex.Lock()
+	loops := len(w.loops)
+	w.loopsMutex.Unlock()
+
+	if loops != 2 {
+		t.Errorf("expected the original loop and a replacement, got %d loops", loops)
+	}
+
+	if running := tracker.GetRunningJobs(ctx, "directory"); len(running) != 0 {
+		t.Errorf("expected the stuck job to be cleared, got %d running", len(running))
+	}
+
+	if item := tracker.GetItemState(ctx, "directory", "nfs"); item == nil || item.ConsecutiveFailures != 1 {
+		t.Errorf("expected the stuck job to be recorded as a failure, got %+v", item)
+	}
+
+	// Shutdown doesn't wait for the abandoned loop
+	cancel()
+	w.Wait()
+}
+
+func TestCollect_CountsRetries(t *testing.T) {
+	cfg := &config.Config{Retry: config.RetryConfig{MaxAttempts: 3, InitialDelay: config.Duration{Duration: time.Millisecond}, Multiplier: 2}}
+	m := metrics.NewFilesystemRegistry(promexporter_metrics.NewRegistry("filesystem_exporter_test_info"))
+	tracker := state.NewTracker(nil)
+	q := queue.NewQueue("directory", 10, "drop_oldest", tracker, m, nil)
+	w := NewWorker(q, m, tracker, cfg, nil, nil, nil, nil, "directory")
+
+	job := queue.Job{ID: "job-1", Type: "unknown", Name: "data", Interval: time.Minute}
+
+	_, span := noop.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+
+	_, err := w.collect(context.Background(), span, job, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected the collection to fail after 3 attempts, got %v", err)
+	}
+
+	if retries := testutil.ToFloat64(m.CollectionRetriesCounter.WithLabelValues("data", "60", "unknown", utils.FailureReasonOther)); retries != 2 {
+		t.Errorf("expected 2 retries, got %g", retries)
+	}
+}
+
+func TestParseDfRows(t *testing.T) {
+	output := `Filesystem                        1024-blocks      Used Available Capacity Mounted on
+/dev/sda1                            41152736  20576368  18463356      53% /
+//nas/media share                  1073741824 536870912 536870912      50% /mnt/nas media
+tmpfs                                       0         0         0       - /run/empty
+df: /mnt/gone: No such file or directory
+`
+
+	rows := parseDfRows([]byte(output))
+
+	want := map[string]dfUsage{
+		"/":              {sizeKB: 41152736, usedKB: 20576368, availableKB: 18463356},
+		"/mnt/nas media": {sizeKB: 1073741824, usedKB: 536870912, availableKB: 536870912},
+		"/run/empty":     {},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %v", len(want), rows)
+	}
+
+	for mountPoint, usage := range want {
+		if rows[mountPoint] != usage {
+			t.Errorf("expected %+v for %q, got %+v", usage, mountPoint, rows[mountPoint])
+		}
+	}
+
+	bsd := "Filesystem 512-blocks Used Available Capacity Mounted on\n/dev/ada0p2 1000 500 500 50% /\n"
+	if rows := parseDfRows([]byte(bsd)); rows["/"] != (dfUsage{sizeKB: 500, usedKB: 250, availableKB: 250}) {
+		t.Errorf("expected 512-byte blocks to be converted, got %+v", rows["/"])
+	}
+}
+
+func TestDfUsage_Bytes(t *testing.T) {
+	// ext4 with its 5% root reserve: used and available don't add up to size
+	usage := dfUsage{sizeKB: 1000, usedKB: 400, availableKB: 550}.bytes()
+
+	if usage.Size != 1024000 || usage.Free != 614400 || usage.Available != 563200 {
+		t.Errorf("expected size 1024000, free 614400 and available 563200, got %+v", usage)
+	}
+}